@@ -13,6 +13,7 @@ import (
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/approval"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
@@ -50,6 +51,7 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 	msgBus := bus.NewMessageBus()
 	defer msgBus.Close()
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	defer agentLoop.Stop()
 
 	// Print agent startup info (only for interactive mode)
 	startupInfo := agentLoop.GetStartupInfo()
@@ -61,6 +63,11 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 		})
 
 	if message != "" {
+		stdin := bufio.NewReader(os.Stdin)
+		setTerminalApprover(agentLoop, func(prompt string) (string, error) {
+			fmt.Print(prompt)
+			return stdin.ReadString('\n')
+		})
 		ctx := context.Background()
 		response, err := agentLoop.ProcessDirect(ctx, message, sessionKey)
 		if err != nil {
@@ -94,6 +101,12 @@ func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	}
 	defer rl.Close()
 
+	setTerminalApprover(agentLoop, func(p string) (string, error) {
+		rl.SetPrompt(p)
+		defer rl.SetPrompt(prompt)
+		return rl.Readline()
+	})
+
 	for {
 		line, err := rl.Readline()
 		if err != nil {
@@ -128,6 +141,10 @@ func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 
 func simpleInteractiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	reader := bufio.NewReader(os.Stdin)
+	setTerminalApprover(agentLoop, func(prompt string) (string, error) {
+		fmt.Print(prompt)
+		return reader.ReadString('\n')
+	})
 	for {
 		fmt.Print(fmt.Sprintf("%s You: ", internal.Logo))
 		line, err := reader.ReadString('\n')
@@ -160,3 +177,29 @@ func simpleInteractiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 		fmt.Printf("\n%s %s\n\n", internal.Logo, response)
 	}
 }
+
+// setTerminalApprover answers tool approval prompts for CLI messages on the
+// terminal. The agent waits inside ProcessDirect while the prompt is open, so
+// readLine is free to use the same input as the chat loop.
+func setTerminalApprover(agentLoop *agent.AgentLoop, readLine func(prompt string) (string, error)) {
+	approvals := agentLoop.Approvals()
+	if approvals == nil {
+		return
+	}
+	approvals.SetPrompter("cli", func(ctx context.Context, req *approval.Request) error {
+		fmt.Printf("\n⚠️  Approval needed (%s): %s %s\n", req.ID, req.Tool, req.ArgsPreview())
+		answer, err := readLine("Allow? [y]es / [a]lways this session / [N]o: ")
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			approvals.Resolve(req.ID, true, false, "cli")
+		case "a", "always":
+			approvals.Resolve(req.ID, true, true, "cli")
+		default:
+			approvals.Resolve(req.ID, false, false, "cli")
+		}
+		return nil
+	})
+}
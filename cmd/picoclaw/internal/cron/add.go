@@ -17,6 +17,13 @@ func newAddCommand(storePath func() string) *cobra.Command {
 		deliver bool
 		channel string
 		to      string
+		tz      string
+		misfire string
+		limit   int
+		retries int
+		backoff int64
+		timeout int
+		overlap string
 	)
 
 	cmd := &cobra.Command{
@@ -33,14 +40,27 @@ func newAddCommand(storePath func() string) *cobra.Command {
 				everyMS := every * 1000
 				schedule = cron.CronSchedule{Kind: "every", EveryMS: &everyMS}
 			} else {
-				schedule = cron.CronSchedule{Kind: "cron", Expr: cronExp}
+				schedule = cron.CronSchedule{Kind: "cron", Expr: cronExp, TZ: tz}
 			}
+			schedule.Misfire = misfire
+			schedule.MisfireLimit = limit
 
 			cs := cron.NewCronService(storePath(), nil)
 			job, err := cs.AddJob(name, schedule, message, deliver, channel, to)
 			if err != nil {
 				return fmt.Errorf("error adding job: %w", err)
 			}
+			if retries > 0 || timeout > 0 || overlap != "" {
+				if retries > 0 {
+					job.Retry = &cron.RetryPolicy{MaxRetries: retries, BackoffMS: backoff * 1000}
+				}
+				job.TimeoutSec = timeout
+				job.Overlap = overlap
+				if err := cs.UpdateJob(job); err != nil {
+					cs.RemoveJob(job.ID)
+					return fmt.Errorf("error adding job: %w", err)
+				}
+			}
 
 			fmt.Printf("✓ Added job '%s' (%s)\n", job.Name, job.ID)
 
@@ -52,6 +72,13 @@ func newAddCommand(storePath func() string) *cobra.Command {
 	cmd.Flags().StringVarP(&message, "message", "m", "", "Message for agent")
 	cmd.Flags().Int64VarP(&every, "every", "e", 0, "Run every N seconds")
 	cmd.Flags().StringVarP(&cronExp, "cron", "c", "", "Cron expression (e.g. '0 9 * * *')")
+	cmd.Flags().StringVar(&tz, "tz", "", "Time zone for --cron (e.g. 'Europe/Berlin'; default: server's)")
+	cmd.Flags().StringVar(&misfire, "misfire", "", "Missed runs policy: skip, run_once (default) or run_all")
+	cmd.Flags().IntVar(&limit, "misfire-limit", 0, "Most missed runs to catch up with run_all (default 10)")
+	cmd.Flags().IntVar(&retries, "retries", 0, "Retry a failed run up to N times")
+	cmd.Flags().Int64Var(&backoff, "retry-backoff", 30, "Seconds before the first retry, doubled for each retry")
+	cmd.Flags().IntVar(&timeout, "timeout", 0, "Stop a run after N seconds (default: job_timeout_minutes)")
+	cmd.Flags().StringVar(&overlap, "overlap", "", "When still running at the next run: skip (default), queue or allow")
 	cmd.Flags().BoolVarP(&deliver, "deliver", "d", false, "Deliver response to channel")
 	cmd.Flags().StringVar(&to, "to", "", "Recipient for delivery")
 	cmd.Flags().StringVar(&channel, "channel", "", "Channel for delivery")
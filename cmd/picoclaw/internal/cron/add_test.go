@@ -21,6 +21,13 @@ func TestNewAddSubcommand(t *testing.T) {
 
 	assert.NotNil(t, cmd.Flags().Lookup("every"))
 	assert.NotNil(t, cmd.Flags().Lookup("cron"))
+	assert.NotNil(t, cmd.Flags().Lookup("tz"))
+	assert.NotNil(t, cmd.Flags().Lookup("misfire"))
+	assert.NotNil(t, cmd.Flags().Lookup("misfire-limit"))
+	assert.NotNil(t, cmd.Flags().Lookup("retries"))
+	assert.NotNil(t, cmd.Flags().Lookup("retry-backoff"))
+	assert.NotNil(t, cmd.Flags().Lookup("timeout"))
+	assert.NotNil(t, cmd.Flags().Lookup("overlap"))
 	assert.NotNil(t, cmd.Flags().Lookup("deliver"))
 	assert.NotNil(t, cmd.Flags().Lookup("to"))
 	assert.NotNil(t, cmd.Flags().Lookup("channel"))
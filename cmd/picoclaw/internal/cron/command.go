@@ -38,6 +38,8 @@ func NewCronCommand() *cobra.Command {
 		newRemoveCommand(func() string { return storePath }),
 		newEnableCommand(func() string { return storePath }),
 		newDisableCommand(func() string { return storePath }),
+		newHistoryCommand(func() string { return storePath }),
+		newRunCommand(),
 	)
 
 	return cmd
@@ -1,10 +1,18 @@
 package cron
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 func cronListCmd(storePath string) {
@@ -24,6 +32,9 @@ func cronListCmd(storePath string) {
 			schedule = fmt.Sprintf("every %ds", *job.Schedule.EveryMS/1000)
 		} else if job.Schedule.Kind == "cron" {
 			schedule = job.Schedule.Expr
+			if job.Schedule.TZ != "" {
+				schedule += " (" + job.Schedule.TZ + ")"
+			}
 		} else {
 			schedule = "one-time"
 		}
@@ -31,7 +42,10 @@ func cronListCmd(storePath string) {
 		nextRun := "scheduled"
 		if job.State.NextRunAtMS != nil {
 			nextTime := time.UnixMilli(*job.State.NextRunAtMS)
-			nextRun = nextTime.Format("2006-01-02 15:04")
+			if loc, err := cron.LoadLocation(job.Schedule.TZ); err == nil {
+				nextTime = nextTime.In(loc)
+			}
+			nextRun = nextTime.Format("2006-01-02 15:04 MST")
 		}
 
 		status := "enabled"
@@ -43,7 +57,104 @@ func cronListCmd(storePath string) {
 		fmt.Printf("    Schedule: %s\n", schedule)
 		fmt.Printf("    Status: %s\n", status)
 		fmt.Printf("    Next run: %s\n", nextRun)
+		if job.State.LastStatus == "error" {
+			fmt.Printf("    Last error: %s\n", job.State.LastError)
+		}
+		if job.Schedule.Misfire != "" {
+			fmt.Printf("    Misfire: %s\n", job.Schedule.Misfire)
+		}
+	}
+}
+
+func cronHistoryCmd(storePath, jobID string) {
+	cs := cron.NewCronService(storePath, nil)
+	runs, found := cs.History(jobID)
+	if !found {
+		fmt.Printf("✗ Job %s not found\n", jobID)
+		return
+	}
+	if len(runs) == 0 {
+		fmt.Printf("Job %s has not run yet.\n", jobID)
+		return
+	}
+
+	fmt.Printf("\nRuns of %s (newest first):\n", jobID)
+	fmt.Println("----------------")
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		started := time.UnixMilli(run.StartedAtMS).Format("2006-01-02 15:04:05")
+		duration := time.Duration(run.DurationMS) * time.Millisecond
+		fmt.Printf("  %s  %-5s  %s", started, run.Status, duration)
+		if run.Attempt > 0 {
+			fmt.Printf("  retry %d", run.Attempt)
+		}
+		if run.PromptTokens > 0 || run.CompletionTokens > 0 {
+			fmt.Printf("  %d+%d tokens", run.PromptTokens, run.CompletionTokens)
+		}
+		fmt.Println()
+		if run.Error != "" {
+			fmt.Printf("    Error: %s\n", run.Error)
+		} else if run.Output != "" {
+			fmt.Printf("    Output: %s\n", run.Output)
+		}
+	}
+}
+
+// cronRunCmd runs a job once through a local agent, printing what it would
+// send instead of delivering it to the job's channel.
+func cronRunCmd(jobID string) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating provider: %w", err)
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.ModelName = modelID
+	}
+
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	defer agentLoop.Stop()
+
+	execTimeout := time.Duration(cfg.Tools.Cron.ExecTimeoutMinutes) * time.Minute
+	cs := internal.SetupCronTool(
+		agentLoop,
+		msgBus,
+		cfg.WorkspacePath(),
+		cfg.Agents.Defaults.RestrictToWorkspace,
+		execTimeout,
+		cfg,
+	)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	go func() {
+		for {
+			msg, ok := msgBus.SubscribeOutbound(ctx)
+			if !ok {
+				return
+			}
+			fmt.Printf("→ %s:%s\n%s\n", msg.Channel, msg.ChatID, msg.Content)
+		}
+	}()
+
+	run, err := cs.RunJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Duration(run.DurationMS) * time.Millisecond
+	if run.Status != "ok" {
+		return fmt.Errorf("job %s failed after %s: %s", jobID, duration, run.Error)
 	}
+	fmt.Printf("✓ Job %s ran in %s\n", jobID, duration)
+	return nil
 }
 
 func cronRemoveCmd(storePath, jobID string) {
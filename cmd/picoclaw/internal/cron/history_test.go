@@ -0,0 +1,19 @@
+package cron
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHistorySubcommand(t *testing.T) {
+	fn := func() string { return "" }
+	cmd := newHistoryCommand(fn)
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "Show recent runs of a job", cmd.Short)
+
+	assert.True(t, cmd.HasExample())
+}
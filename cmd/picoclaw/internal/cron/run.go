@@ -0,0 +1,15 @@
+package cron
+
+import "github.com/spf13/cobra"
+
+func newRunCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "run",
+		Short:   "Run a job now",
+		Args:    cobra.ExactArgs(1),
+		Example: `picoclaw cron run 1`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return cronRunCmd(args[0])
+		},
+	}
+}
@@ -0,0 +1,19 @@
+package cron
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRunSubcommand(t *testing.T) {
+	cmd := newRunCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "Run a job now", cmd.Short)
+
+	assert.True(t, cmd.HasExample())
+	assert.Error(t, cmd.Args(cmd, nil))
+}
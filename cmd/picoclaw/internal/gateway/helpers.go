@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"time"
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
@@ -17,6 +16,7 @@ import (
 	_ "github.com/sipeed/picoclaw/pkg/channels/feishu"
 	_ "github.com/sipeed/picoclaw/pkg/channels/line"
 	_ "github.com/sipeed/picoclaw/pkg/channels/maixcam"
+	_ "github.com/sipeed/picoclaw/pkg/channels/mqtt"
 	_ "github.com/sipeed/picoclaw/pkg/channels/onebot"
 	_ "github.com/sipeed/picoclaw/pkg/channels/pico"
 	_ "github.com/sipeed/picoclaw/pkg/channels/qq"
@@ -25,8 +25,6 @@ import (
 	_ "github.com/sipeed/picoclaw/pkg/channels/wecom"
 	_ "github.com/sipeed/picoclaw/pkg/channels/whatsapp"
 	_ "github.com/sipeed/picoclaw/pkg/channels/whatsapp_native"
-	"github.com/sipeed/picoclaw/pkg/config"
-	"github.com/sipeed/picoclaw/pkg/cron"
 	"github.com/sipeed/picoclaw/pkg/devices"
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
@@ -35,6 +33,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/state"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/triggers"
 )
 
 func gatewayCmd(debug bool) error {
@@ -81,7 +80,7 @@ func gatewayCmd(debug bool) error {
 
 	// Setup cron tool and service
 	execTimeout := time.Duration(cfg.Tools.Cron.ExecTimeoutMinutes) * time.Minute
-	cronService := setupCronTool(
+	cronService := internal.SetupCronTool(
 		agentLoop,
 		msgBus,
 		cfg.WorkspacePath(),
@@ -115,6 +114,13 @@ func gatewayCmd(debug bool) error {
 		return tools.SilentResult(response)
 	})
 
+	stateManager := state.NewManager(cfg.WorkspacePath())
+	triggerService, err := triggers.NewService(cfg.Triggers, cfg.WorkspacePath(), stateManager)
+	if err != nil {
+		return fmt.Errorf("error configuring triggers: %w", err)
+	}
+	triggerService.SetBus(msgBus)
+
 	// Create media store for file lifecycle management with TTL cleanup
 	mediaStore := media.NewFileMediaStoreWithCleanup(media.MediaCleanerConfig{
 		Enabled:  cfg.Tools.MediaCleanup.Enabled,
@@ -156,28 +162,42 @@ func gatewayCmd(debug bool) error {
 	}
 	fmt.Println("✓ Heartbeat service started")
 
-	stateManager := state.NewManager(cfg.WorkspacePath())
 	deviceService := devices.NewService(devices.Config{
 		Enabled:    cfg.Devices.Enabled,
 		MonitorUSB: cfg.Devices.MonitorUSB,
 	}, stateManager)
 	deviceService.SetBus(msgBus)
+	deviceService.AddListener(triggerService.HandleDeviceEvent)
 	if err := deviceService.Start(ctx); err != nil {
 		fmt.Printf("Error starting device service: %v\n", err)
 	} else if cfg.Devices.Enabled {
 		fmt.Println("✓ Device event service started")
 	}
 
+	if err := triggerService.Start(ctx); err != nil {
+		fmt.Printf("Error starting trigger service: %v\n", err)
+	} else if cfg.Triggers.Enabled {
+		fmt.Println("✓ Trigger service started")
+	}
+
+	// Background provider health probes feed the same cooldown tracker as the fallback chain
+	healthProber := providers.NewHealthProber(cfg.ModelList, agentLoop.Cooldown())
+	healthProber.Start(ctx)
+
 	// Setup shared HTTP server with health endpoints and webhook handlers
 	healthServer := health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port)
+	setupProviderHealth(healthServer, agentLoop, healthProber, cfg.Gateway.FailoverAlertMinutes)
 	addr := fmt.Sprintf("%s:%d", cfg.Gateway.Host, cfg.Gateway.Port)
 	channelManager.SetupHTTPServer(addr, healthServer)
+	if triggerService.HasWebhooks() {
+		channelManager.AddWebhookHandler(triggerService)
+	}
 
 	if err := channelManager.StartAll(ctx); err != nil {
 		fmt.Printf("Error starting channels: %v\n", err)
 	}
 
-	fmt.Printf("✓ Health endpoints available at http://%s:%d/health and /ready\n", cfg.Gateway.Host, cfg.Gateway.Port)
+	fmt.Printf("✓ Health endpoints available at http://%s:%d/health, /ready and /providers\n", cfg.Gateway.Host, cfg.Gateway.Port)
 
 	go agentLoop.Run(ctx)
 
@@ -198,7 +218,9 @@ func gatewayCmd(debug bool) error {
 	defer shutdownCancel()
 
 	channelManager.StopAll(shutdownCtx)
+	triggerService.Stop()
 	deviceService.Stop()
+	healthProber.Stop()
 	heartbeatService.Stop()
 	cronService.Stop()
 	mediaStore.Stop()
@@ -208,28 +230,44 @@ func gatewayCmd(debug bool) error {
 	return nil
 }
 
-func setupCronTool(
+// setupProviderHealth exposes provider cooldown and probe state on /providers
+// and fails /ready once the primary provider has been failing over for too long.
+func setupProviderHealth(
+	healthServer *health.Server,
 	agentLoop *agent.AgentLoop,
-	msgBus *bus.MessageBus,
-	workspace string,
-	restrict bool,
-	execTimeout time.Duration,
-	cfg *config.Config,
-) *cron.CronService {
-	cronStorePath := filepath.Join(workspace, "cron", "jobs.json")
-
-	// Create cron service
-	cronService := cron.NewCronService(cronStorePath, nil)
-
-	// Create and register CronTool
-	cronTool := tools.NewCronTool(cronService, agentLoop, msgBus, workspace, restrict, execTimeout, cfg)
-	agentLoop.RegisterTool(cronTool)
-
-	// Set the onJob handler
-	cronService.SetOnJob(func(job *cron.CronJob) (string, error) {
-		result := cronTool.ExecuteJob(context.Background(), job)
-		return result, nil
+	prober *providers.HealthProber,
+	alertMinutes int,
+) {
+	healthServer.SetProvidersFunc(func() any {
+		status := map[string]any{
+			"cooldowns": agentLoop.Cooldown().Snapshot(),
+			"probes":    prober.Status(),
+		}
+		if primary, ok := agentLoop.PrimaryCandidate(); ok {
+			status["primary"] = map[string]any{
+				"provider":    primary.Provider,
+				"model":       primary.Model,
+				"available":   agentLoop.Cooldown().IsAvailable(primary.Provider),
+				"failing_for": agentLoop.Cooldown().FailingFor(primary.Provider).Round(time.Second).String(),
+			}
+		}
+		return status
 	})
 
-	return cronService
+	if alertMinutes <= 0 {
+		return
+	}
+	threshold := time.Duration(alertMinutes) * time.Minute
+	healthServer.RegisterLiveCheck("primary_provider", func() (bool, string) {
+		primary, ok := agentLoop.PrimaryCandidate()
+		if !ok {
+			return true, "no primary model configured"
+		}
+		failingFor := agentLoop.Cooldown().FailingFor(primary.Provider)
+		if failingFor >= threshold {
+			return false, fmt.Sprintf("primary provider %s has been failing over for %s",
+				primary.Provider, failingFor.Round(time.Second))
+		}
+		return true, fmt.Sprintf("primary provider %s healthy", primary.Provider)
+	})
 }
@@ -1,12 +1,18 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
 const Logo = "🦞"
@@ -50,3 +56,36 @@ func FormatBuildInfo() (string, string) {
 func GetVersion() string {
 	return version
 }
+
+// SetupCronTool creates the cron service for workspace and registers the cron
+// tool with every agent of agentLoop. The caller starts and stops the service.
+func SetupCronTool(
+	agentLoop *agent.AgentLoop,
+	msgBus *bus.MessageBus,
+	workspace string,
+	restrict bool,
+	execTimeout time.Duration,
+	cfg *config.Config,
+) *cron.CronService {
+	cronStorePath := filepath.Join(workspace, "cron", "jobs.json")
+
+	// Create cron service
+	cronService := cron.NewCronService(cronStorePath, nil)
+
+	// Create and register CronTool
+	cronTool := tools.NewCronTool(cronService, agentLoop, msgBus, workspace, restrict, execTimeout, cfg)
+	agentLoop.RegisterTool(cronTool)
+
+	// Set the onJob handler
+	cronService.SetOnJob(func(ctx context.Context, job *cron.CronJob) (cron.JobResult, error) {
+		return cronTool.ExecuteJob(ctx, job)
+	})
+	cronService.SetConcurrency(cfg.Tools.Cron.MaxConcurrentJobs)
+	cronService.SetJobTimeout(time.Duration(cfg.Tools.Cron.JobTimeoutMinutes) * time.Minute)
+	alertChannel, alertChatID := cfg.Tools.Cron.AlertChannel, cfg.Tools.Cron.AlertChatID
+	cronService.SetOnAlert(cfg.Tools.Cron.AlertAfterFailures, func(job *cron.CronJob, failures int) {
+		cronTool.SendAlert(job, failures, alertChannel, alertChatID)
+	})
+
+	return cronService
+}
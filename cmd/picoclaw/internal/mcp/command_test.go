@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMCPCommand(t *testing.T) {
+	cmd := NewMCPCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "mcp", cmd.Use)
+	assert.Equal(t, "Model Context Protocol integration", cmd.Short)
+
+	assert.True(t, cmd.HasSubCommands())
+
+	serve, _, err := cmd.Find([]string{"serve"})
+	require.NoError(t, err)
+	assert.Equal(t, "serve", serve.Name())
+
+	for _, flag := range []string{"agent", "http", "token", "no-chat", "debug"} {
+		assert.NotNil(t, serve.Flags().Lookup(flag), flag)
+	}
+}
+
+func TestGuardHTTP(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name   string
+		token  string
+		header map[string]string
+		want   int
+	}{
+		{"no token, no origin", "", nil, http.StatusOK},
+		{"no token, local origin", "", map[string]string{"Origin": "http://localhost:3000"}, http.StatusOK},
+		{"no token, foreign origin", "", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"token missing", "s3cret", nil, http.StatusUnauthorized},
+		{"token wrong", "s3cret", map[string]string{"Authorization": "Bearer nope"}, http.StatusUnauthorized},
+		{"token ok", "s3cret", map[string]string{"Authorization": "Bearer s3cret"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			guardHTTP(tt.token, ok).ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	assert.True(t, isLoopbackAddr("127.0.0.1:18795"))
+	assert.True(t, isLoopbackAddr("localhost:18795"))
+	assert.True(t, isLoopbackAddr("[::1]:18795"))
+	assert.False(t, isLoopbackAddr("0.0.0.0:18795"))
+	assert.False(t, isLoopbackAddr(":18795"))
+}
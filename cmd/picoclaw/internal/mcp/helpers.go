@@ -0,0 +1,270 @@
+package mcp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+type serveOptions struct {
+	agentID  string
+	httpAddr string
+	token    string
+	noChat   bool
+	debug    bool
+}
+
+func serveCmd(opts serveOptions) error {
+	// In stdio mode stdout carries the protocol; anything else that prints
+	// (startup banners, tool warnings) has to go to stderr.
+	protocolOut := os.Stdout
+	if opts.httpAddr == "" {
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = protocolOut }()
+	}
+
+	if opts.debug {
+		logger.SetLevel(logger.DEBUG)
+	}
+	if opts.token == "" {
+		opts.token = os.Getenv("PICOCLAW_MCP_TOKEN")
+	}
+	if opts.httpAddr != "" && opts.token == "" && !isLoopbackAddr(opts.httpAddr) {
+		return fmt.Errorf("refusing to serve on non-loopback address %s without --token", opts.httpAddr)
+	}
+
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating provider: %w", err)
+	}
+	if modelID != "" {
+		cfg.Agents.Defaults.ModelName = modelID
+	}
+
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	defer agentLoop.Stop()
+
+	execTimeout := time.Duration(cfg.Tools.Cron.ExecTimeoutMinutes) * time.Minute
+	cronService := internal.SetupCronTool(
+		agentLoop,
+		msgBus,
+		cfg.WorkspacePath(),
+		cfg.Agents.Defaults.RestrictToWorkspace,
+		execTimeout,
+		cfg,
+	)
+	if err := cronService.Start(); err != nil {
+		logger.WarnCF("mcp", "Failed to start cron service", map[string]any{"error": err.Error()})
+	}
+	defer cronService.Stop()
+
+	instance, ok := agentLoop.GetAgent(opts.agentID)
+	if !ok {
+		return fmt.Errorf("agent %q not found", opts.agentID)
+	}
+
+	served := buildServedRegistry(instance, agentLoop, opts.noChat)
+	server := mcp.NewToolServer(
+		mcp.Implementation{Name: "picoclaw", Version: internal.GetVersion()},
+		served,
+		"mcp",
+		instance.ID,
+	)
+	if approvals := agentLoop.Approvals(); approvals != nil {
+		server.SetApprovals(approvals, instance.ID)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Nothing delivers outbound messages here; log them so cron and message
+	// tool output is not silently lost and the bus never fills up.
+	go drainOutbound(ctx, msgBus)
+
+	logger.InfoCF("mcp", "Serving agent tools over MCP", map[string]any{
+		"agent":     instance.ID,
+		"tools":     served.Count(),
+		"workspace": instance.Workspace,
+		"restrict":  cfg.Agents.Defaults.RestrictToWorkspace,
+	})
+
+	if opts.httpAddr == "" {
+		return server.ServeStdio(ctx, os.Stdin, protocolOut)
+	}
+	return serveHTTP(ctx, opts.httpAddr, opts.token, server)
+}
+
+// buildServedRegistry copies the agent's tools into a registry for MCP
+// clients and adds the chat tool. The chat tool must not land in the agent's
+// own registry, or the model could call itself.
+func buildServedRegistry(instance *agent.AgentInstance, agentLoop *agent.AgentLoop, noChat bool) *tools.ToolRegistry {
+	served := tools.NewToolRegistry()
+	for _, name := range instance.Tools.List() {
+		if tool, ok := instance.Tools.Get(name); ok {
+			served.Register(tool)
+		}
+	}
+	if !noChat {
+		served.Register(newChatTool(agentLoop, instance.ID))
+	}
+	return served
+}
+
+func drainOutbound(ctx context.Context, msgBus *bus.MessageBus) {
+	for {
+		msg, ok := msgBus.SubscribeOutbound(ctx)
+		if !ok {
+			return
+		}
+		logger.InfoCF("mcp", "Outbound message (not delivered in mcp serve)", map[string]any{
+			"channel": msg.Channel,
+			"chat_id": msg.ChatID,
+			"content": msg.Content,
+		})
+	}
+}
+
+func serveHTTP(ctx context.Context, addr, token string, server *mcp.ToolServer) error {
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", guardHTTP(token, server))
+
+	httpServer := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+	fmt.Fprintf(os.Stderr, "✓ MCP server listening on http://%s/mcp\n", addr)
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer shutdownCancel()
+		return httpServer.Shutdown(shutdownCtx)
+	}
+}
+
+// guardHTTP requires the bearer token when one is set. Without a token the
+// server only listens on loopback, and requests from browser pages of other
+// origins are rejected to prevent DNS rebinding.
+func guardHTTP(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !isLoopbackHost(u.Hostname()) {
+				http.Error(w, "forbidden origin", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return isLoopbackHost(host)
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// chatTool runs a full agent turn for MCP clients, keeping history per
+// named session.
+type chatTool struct {
+	agentLoop *agent.AgentLoop
+	agentID   string
+}
+
+func newChatTool(agentLoop *agent.AgentLoop, agentID string) *chatTool {
+	return &chatTool{agentLoop: agentLoop, agentID: agentID}
+}
+
+func (t *chatTool) Name() string {
+	return "chat"
+}
+
+func (t *chatTool) Description() string {
+	return "Send a message to the PicoClaw agent and get its reply. The agent can use all of its " +
+		"tools and skills. Conversation history is kept per session."
+}
+
+func (t *chatTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"message": map[string]any{
+				"type":        "string",
+				"description": "Message for the agent",
+			},
+			"session": map[string]any{
+				"type":        "string",
+				"description": "Session name; messages in the same session share history (default: \"default\")",
+			},
+		},
+		"required": []string{"message"},
+	}
+}
+
+func (t *chatTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	message, _ := args["message"].(string)
+	if strings.TrimSpace(message) == "" {
+		return tools.ErrorResult("message is required")
+	}
+	session, _ := args["session"].(string)
+	if session == "" {
+		session = "default"
+	}
+
+	response, err := t.agentLoop.ProcessDirect(ctx, message, chatSessionKey(t.agentID, session))
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("agent error: %v", err)).WithError(err)
+	}
+	return tools.NewToolResult(response)
+}
+
+// chatSessionKey scopes MCP chat sessions to the served agent.
+func chatSessionKey(agentID, session string) string {
+	return fmt.Sprintf("agent:%s:mcp:%s", agentID, session)
+}
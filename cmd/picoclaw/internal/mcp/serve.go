@@ -0,0 +1,34 @@
+package mcp
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newServeCommand() *cobra.Command {
+	var opts serveOptions
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve an agent's tools over MCP (stdio by default)",
+		Long: `Expose the tools of a PicoClaw agent to MCP clients such as editors and
+desktop assistants, plus a "chat" tool that runs a full agent turn.
+
+Without --http the server speaks MCP over stdin/stdout. With --http it
+serves the streamable HTTP transport on the given address at /mcp.`,
+		Example: `  picoclaw mcp serve
+  picoclaw mcp serve --agent main --http 127.0.0.1:18795
+  picoclaw mcp serve --http 0.0.0.0:18795 --token s3cret`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return serveCmd(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.agentID, "agent", "a", "", "Agent whose tools to expose (default agent if empty)")
+	cmd.Flags().StringVar(&opts.httpAddr, "http", "", "Serve streamable HTTP on this address instead of stdio")
+	cmd.Flags().StringVar(&opts.token, "token", "", "Bearer token required by the HTTP server (env PICOCLAW_MCP_TOKEN)")
+	cmd.Flags().BoolVar(&opts.noChat, "no-chat", false, "Do not expose the chat tool")
+	cmd.Flags().BoolVarP(&opts.debug, "debug", "d", false, "Enable debug logging")
+
+	return cmd
+}
@@ -0,0 +1,46 @@
+package workspace
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/history"
+)
+
+func NewWorkspaceCommand() *cobra.Command {
+	var store *history.Store
+
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Inspect and restore versions of workspace files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			store = history.NewStore(cfg.WorkspacePath(), cfg.Tools.History)
+			return nil
+		},
+	}
+
+	storeFn := func() (*history.Store, error) {
+		if store == nil {
+			return nil, fmt.Errorf("file history is not initialized")
+		}
+		return store, nil
+	}
+
+	cmd.AddCommand(
+		newLogCommand(storeFn),
+		newDiffCommand(storeFn),
+		newRestoreCommand(storeFn),
+	)
+
+	return cmd
+}
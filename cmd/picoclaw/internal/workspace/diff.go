@@ -0,0 +1,31 @@
+package workspace
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/history"
+)
+
+func newDiffCommand(storeFn func() (*history.Store, error)) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <path> [version]",
+		Short: "Show how a version differs from the current file",
+		Long: "Show how a recorded version differs from the current file. Without a version, " +
+			"the last version that differs from the current file is used.",
+		Args:    cobra.RangeArgs(1, 2),
+		Example: `picoclaw workspace diff MEMORY.md 12`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			store, err := storeFn()
+			if err != nil {
+				return err
+			}
+			seq, err := parseVersion(args)
+			if err != nil {
+				return err
+			}
+			return workspaceDiffCmd(store, args[0], seq)
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,20 @@
+package workspace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDiffSubcommand(t *testing.T) {
+	cmd := newDiffCommand(nil)
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "diff <path> [version]", cmd.Use)
+	assert.Equal(t, "Show how a version differs from the current file", cmd.Short)
+
+	assert.False(t, cmd.HasFlags())
+	assert.Error(t, cmd.Args(cmd, nil))
+}
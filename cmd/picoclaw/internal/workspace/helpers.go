@@ -0,0 +1,90 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/history"
+)
+
+// parseVersion returns the optional version argument after the path, which
+// may be written as 12 or #12.
+func parseVersion(args []string) (int64, error) {
+	if len(args) < 2 {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(strings.TrimPrefix(args[1], "#"), 10, 64)
+	if err != nil || seq <= 0 {
+		return 0, fmt.Errorf("invalid version %q", args[1])
+	}
+	return seq, nil
+}
+
+func workspaceLogCmd(store *history.Store, path string, limit int) error {
+	entries, err := store.Log(path)
+	if err != nil {
+		return err
+	}
+	fmt.Println(history.FormatLog(entries, path == "", limit))
+	return nil
+}
+
+// fileVersion is a recorded version of a file next to its current content.
+type fileVersion struct {
+	entry   history.Entry
+	content []byte
+	current []byte
+	exists  bool
+}
+
+// selectVersion picks the version of path to diff against or restore.
+func selectVersion(store *history.Store, path string, seq int64) (*fileVersion, error) {
+	rel, ok := store.Rel(path)
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the workspace", path)
+	}
+	entries, err := store.Log(rel)
+	if err != nil {
+		return nil, err
+	}
+	current, err := os.ReadFile(store.AbsPath(rel))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	v := &fileVersion{current: current, exists: err == nil}
+	if v.entry, err = history.SelectVersion(entries, seq, current, v.exists); err != nil {
+		return nil, fmt.Errorf("%s: %w", rel, err)
+	}
+	if v.content, err = store.Content(v.entry); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func workspaceDiffCmd(store *history.Store, path string, seq int64) error {
+	v, err := selectVersion(store, path, seq)
+	if err != nil {
+		return err
+	}
+	fmt.Print(strings.TrimSuffix(history.Diff(v.entry, v.content, v.current, v.exists), "\n") + "\n")
+	return nil
+}
+
+func workspaceRestoreCmd(store *history.Store, path string, seq int64) error {
+	v, err := selectVersion(store, path, seq)
+	if err != nil {
+		return err
+	}
+	if err := store.Restore(v.entry.Seq); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", v.entry.Path, err)
+	}
+	if v.entry.Deleted() {
+		fmt.Printf("✓ Removed %s (version #%d records its deletion)\n", v.entry.Path, v.entry.Seq)
+		return nil
+	}
+	fmt.Printf("✓ Restored %s to version #%d\n", v.entry.Path, v.entry.Seq)
+	return nil
+}
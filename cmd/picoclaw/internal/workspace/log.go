@@ -0,0 +1,33 @@
+package workspace
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/history"
+)
+
+func newLogCommand(storeFn func() (*history.Store, error)) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:     "log [path]",
+		Short:   "List recorded versions of workspace files",
+		Args:    cobra.MaximumNArgs(1),
+		Example: `picoclaw workspace log MEMORY.md`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			store, err := storeFn()
+			if err != nil {
+				return err
+			}
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return workspaceLogCmd(store, path, limit)
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Maximum versions to list")
+
+	return cmd
+}
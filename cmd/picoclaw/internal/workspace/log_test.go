@@ -0,0 +1,20 @@
+package workspace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogSubcommand(t *testing.T) {
+	cmd := newLogCommand(nil)
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "log [path]", cmd.Use)
+	assert.Equal(t, "List recorded versions of workspace files", cmd.Short)
+
+	assert.True(t, cmd.HasFlags())
+	assert.NotNil(t, cmd.Flags().ShorthandLookup("n"))
+}
@@ -0,0 +1,31 @@
+package workspace
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/history"
+)
+
+func newRestoreCommand(storeFn func() (*history.Store, error)) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore <path> [version]",
+		Short: "Restore a file to a recorded version",
+		Long: "Restore a file to a recorded version. Without a version, the last version that " +
+			"differs from the current file is restored, which undoes the most recent change.",
+		Args:    cobra.RangeArgs(1, 2),
+		Example: `picoclaw workspace restore MEMORY.md`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			store, err := storeFn()
+			if err != nil {
+				return err
+			}
+			seq, err := parseVersion(args)
+			if err != nil {
+				return err
+			}
+			return workspaceRestoreCmd(store, args[0], seq)
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,43 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/history"
+)
+
+func TestNewRestoreSubcommand(t *testing.T) {
+	cmd := newRestoreCommand(nil)
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "restore <path> [version]", cmd.Use)
+	assert.Equal(t, "Restore a file to a recorded version", cmd.Short)
+
+	assert.False(t, cmd.HasFlags())
+}
+
+func TestWorkspaceRestoreCmd(t *testing.T) {
+	workspace := t.TempDir()
+	store := history.NewStore(workspace, config.FileHistoryConfig{})
+	path := filepath.Join(workspace, "SOUL.md")
+	require.NoError(t, os.WriteFile(path, []byte("v2\n"), 0o600))
+	require.NoError(t, store.Record(history.Change{Path: path, Old: []byte("v1\n"), New: []byte("v2\n")}))
+
+	require.NoError(t, workspaceRestoreCmd(store, "SOUL.md", 0))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "v1\n", string(data))
+
+	_, err = parseVersion([]string{"SOUL.md", "#2"})
+	assert.NoError(t, err)
+	_, err = parseVersion([]string{"SOUL.md", "latest"})
+	assert.Error(t, err)
+	assert.Error(t, workspaceRestoreCmd(store, "SOUL.md", 42))
+}
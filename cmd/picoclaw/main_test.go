@@ -37,11 +37,13 @@ func TestNewPicoclawCommand(t *testing.T) {
 		"auth",
 		"cron",
 		"gateway",
+		"mcp",
 		"migrate",
 		"onboard",
 		"skills",
 		"status",
 		"version",
+		"workspace",
 	}
 
 	subcommands := cmd.Commands()
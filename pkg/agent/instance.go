@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/netpolicy"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
@@ -28,9 +30,11 @@ type AgentInstance struct {
 	Sessions       *session.SessionManager
 	ContextBuilder *ContextBuilder
 	Tools          *tools.ToolRegistry
+	Processes      *tools.ProcessManager
 	Subagents      *config.SubagentsConfig
 	SkillsFilter   []string
 	Candidates     []providers.FallbackCandidate
+	NetworkPolicy  *netpolicy.Policy
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -48,12 +52,16 @@ func NewAgentInstance(
 
 	restrict := defaults.RestrictToWorkspace
 	toolsRegistry := tools.NewToolRegistry()
+	toolsRegistry.SetFilter(resolveToolFilter(agentCfg))
 	toolsRegistry.Register(tools.NewReadFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewWriteFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewListDirTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewGrepTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewGlobTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewExecToolWithConfig(workspace, restrict, cfg))
 	toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewApplyPatchTool(workspace, restrict))
 
 	sessionsDir := filepath.Join(workspace, "sessions")
 	sessionsManager := session.NewSessionManager(sessionsDir)
@@ -151,9 +159,45 @@ func NewAgentInstance(
 		Subagents:      subagents,
 		SkillsFilter:   skillsFilter,
 		Candidates:     candidates,
+		NetworkPolicy:  resolveNetworkPolicy(agentCfg, cfg),
 	}
 }
 
+// resolveNetworkPolicy builds the outbound network policy for an agent from
+// the global policy and the agent's own domain lists.
+func resolveNetworkPolicy(agentCfg *config.AgentConfig, cfg *config.Config) *netpolicy.Policy {
+	policy := netpolicy.Default()
+	if cfg != nil {
+		p, err := netpolicy.New(cfg.Tools.Network)
+		if err != nil {
+			logger.ErrorCF("agent", "Invalid network policy, using the default",
+				map[string]any{"error": err.Error()})
+		} else {
+			policy = p
+		}
+	}
+	if agentCfg != nil && agentCfg.Network != nil {
+		policy = policy.WithDomains(agentCfg.Network.AllowedDomains, agentCfg.Network.DeniedDomains)
+	}
+	return policy
+}
+
+// resolveToolFilter builds the filter for the agent's tools.allow and
+// tools.deny lists. A malformed list denies every tool rather than fall back
+// to the full tool set.
+func resolveToolFilter(agentCfg *config.AgentConfig) *tools.ToolFilter {
+	if agentCfg == nil || agentCfg.Tools == nil {
+		return nil
+	}
+	filter, err := tools.NewToolFilter(agentCfg.Tools.Allow, agentCfg.Tools.Deny)
+	if err != nil {
+		logger.ErrorCF("agent", "Invalid tool filter, disabling all tools",
+			map[string]any{"agent_id": agentCfg.ID, "error": err.Error()})
+		filter, _ = tools.NewToolFilter(nil, []string{"*"})
+	}
+	return filter
+}
+
 // resolveAgentWorkspace determines the workspace directory for an agent.
 func resolveAgentWorkspace(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
 	if agentCfg != nil && strings.TrimSpace(agentCfg.Workspace) != "" {
@@ -167,3 +167,33 @@ func TestNewAgentInstance_ResolveCandidatesFromModelListAliasWithoutProtocol(t *
 		t.Fatalf("candidate model = %q, want %q", agent.Candidates[0].Model, "glm-5")
 	}
 }
+
+func TestNewAgentInstance_ToolFilter(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{Workspace: tmpDir, Model: "test-model"},
+		},
+	}
+
+	agentCfg := &config.AgentConfig{
+		ID:    "public",
+		Tools: &config.AgentToolsConfig{Allow: []string{"fs"}, Deny: []string{"write_file", "edit_file"}},
+	}
+	agent := NewAgentInstance(agentCfg, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	for _, name := range []string{"exec", "write_file", "edit_file"} {
+		if _, ok := agent.Tools.Get(name); ok {
+			t.Errorf("tool %q registered despite the agent's tool filter", name)
+		}
+	}
+	if _, ok := agent.Tools.Get("read_file"); !ok {
+		t.Error("read_file not registered")
+	}
+
+	// A malformed pattern must not leave the agent with every tool.
+	agentCfg.Tools = &config.AgentToolsConfig{Deny: []string{"exec["}}
+	agent = NewAgentInstance(agentCfg, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	if n := agent.Tools.Count(); n != 0 {
+		t.Errorf("invalid tool filter left %d tools", n)
+	}
+}
@@ -17,11 +17,14 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/sipeed/picoclaw/pkg/approval"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/history"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
@@ -39,20 +42,25 @@ type AgentLoop struct {
 	running        atomic.Bool
 	summarizing    sync.Map
 	fallback       *providers.FallbackChain
+	cooldown       *providers.CooldownTracker
 	channelManager *channels.Manager
 	mediaStore     media.MediaStore
+	mcp            *mcp.Manager
+	approvals      *approval.Manager
 }
 
 // processOptions configures how a message is processed
 type processOptions struct {
-	SessionKey      string // Session identifier for history/context
-	Channel         string // Target channel for tool execution
-	ChatID          string // Target chat ID for tool execution
-	UserMessage     string // User message content (may include prefix)
-	DefaultResponse string // Response when LLM returns empty
-	EnableSummary   bool   // Whether to trigger summarization
-	SendResponse    bool   // Whether to send response via bus
-	NoHistory       bool   // If true, don't load session history (for heartbeat)
+	SessionKey      string   // Session identifier for history/context
+	Channel         string   // Target channel for tool execution
+	ChatID          string   // Target chat ID for tool execution
+	UserMessage     string   // User message content (may include prefix)
+	Media           []string // media:// refs attached to the user message
+	DefaultResponse string   // Response when LLM returns empty
+	EnableSummary   bool     // Whether to trigger summarization
+	SendResponse    bool     // Whether to send response via bus
+	PublishMedia    bool     // Whether to publish tool media even when SendResponse is false
+	NoHistory       bool     // If true, don't load session history (for heartbeat)
 }
 
 const defaultResponse = "I've completed processing but have no response to give. Increase `max_tool_iterations` in config.json."
@@ -63,9 +71,11 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	// Register shared tools to all agents
 	registerSharedTools(cfg, msgBus, registry, provider)
 
-	// Set up shared fallback chain
-	cooldown := providers.NewCooldownTracker()
-	fallbackChain := providers.NewFallbackChain(cooldown)
+	// Connect MCP servers; their tools are (re)registered on every connect
+	mcpManager := mcp.NewManager(cfg.Tools.MCP)
+	mcpManager.Start(context.Background(), func(s *mcp.Server) {
+		registerMCPTools(registry, s)
+	})
 
 	// Create state manager using default agent's workspace for channel recording
 	defaultAgent := registry.GetDefaultAgent()
@@ -74,6 +84,25 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		stateManager = state.NewManager(defaultAgent.Workspace)
 	}
 
+	// Set up shared fallback chain; cooldowns persist next to the workspace state
+	// so a restart doesn't hammer a provider that was failing.
+	var cooldown *providers.CooldownTracker
+	if defaultAgent != nil {
+		cooldown = providers.NewPersistentCooldownTracker(
+			filepath.Join(defaultAgent.Workspace, "state", "provider_cooldown.json"))
+	} else {
+		cooldown = providers.NewCooldownTracker()
+	}
+	fallbackChain := providers.NewFallbackChain(cooldown)
+	if ca, ok := provider.(providers.CooldownAware); ok {
+		ca.SetCooldownTracker(cooldown)
+	}
+
+	var approvals *approval.Manager
+	if cfg.Tools.Approval.Enabled && defaultAgent != nil {
+		approvals = newApprovalManager(cfg.Tools.Approval, getGlobalConfigDir(), msgBus)
+	}
+
 	return &AgentLoop{
 		bus:         msgBus,
 		cfg:         cfg,
@@ -81,6 +110,57 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		state:       stateManager,
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
+		cooldown:    cooldown,
+		mcp:         mcpManager,
+		approvals:   approvals,
+	}
+}
+
+// newApprovalManager builds the tool approval manager. An invalid policy
+// must not silently let every call through, so it falls back to asking for
+// all of them.
+func newApprovalManager(cfg config.ApprovalConfig, stateDir string, msgBus *bus.MessageBus) *approval.Manager {
+	m, err := approval.NewManager(cfg, stateDir, msgBus)
+	if err == nil {
+		return m
+	}
+	logger.ErrorCF("agent", "Invalid tool approval policy, asking for every tool call",
+		map[string]any{"error": err.Error()})
+	m, _ = approval.NewManager(config.ApprovalConfig{
+		Enabled:        true,
+		Default:        string(approval.ModeAsk),
+		TimeoutSeconds: cfg.TimeoutSeconds,
+		AuditLog:       cfg.AuditLog,
+	}, stateDir, msgBus)
+	return m
+}
+
+// registerMCPTools adds the tools of an MCP server to every agent the
+// server's allowlist admits. The server's earlier tools are removed first, so
+// tools it no longer lists after list_changed or a reconnect go away.
+func registerMCPTools(registry *AgentRegistry, server *mcp.Server) {
+	serverTools := server.Tools()
+	prefix := server.Prefix()
+	for _, agentID := range registry.ListAgentIDs() {
+		if !server.AllowsAgent(agentID) {
+			continue
+		}
+		agent, ok := registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		for _, name := range agent.Tools.List() {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			// The prefix may be empty or shared, so check who owns the tool.
+			if tool, ok := agent.Tools.Get(name); ok && server.Owns(tool) {
+				agent.Tools.Unregister(name)
+			}
+		}
+		for _, tool := range serverTools {
+			agent.Tools.Register(tool)
+		}
 	}
 }
 
@@ -91,12 +171,43 @@ func registerSharedTools(
 	registry *AgentRegistry,
 	provider providers.LLMProvider,
 ) {
+	imageGen := createImageGenerator(cfg)
+	var mqttPublish *tools.MQTTPublishTool // one broker connection for all agents
+	if cfg.Tools.MQTT.Enabled {
+		mqttPublish = tools.NewMQTTPublishTool(cfg.Channels.MQTT, cfg.Tools.MQTT.AllowedTopics)
+	}
+	histories := make(map[string]*history.Store) // agents may share a workspace
+	// Search providers call fixed API hosts, which an agent's domain
+	// allowlist would block, so they only get the global policy.
+	searchPolicy := resolveNetworkPolicy(nil, cfg)
+
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
 		if !ok {
 			continue
 		}
 
+		// Versions of the files the agent writes, kept in the workspace
+		if cfg.Tools.History.Enabled {
+			store := histories[agent.Workspace]
+			if store == nil {
+				store = history.NewStore(agent.Workspace, cfg.Tools.History)
+				histories[agent.Workspace] = store
+			}
+			for _, name := range []string{"write_file", "edit_file", "append_file", "apply_patch"} {
+				if tool, ok := agent.Tools.Get(name); ok {
+					if versioned, ok := tool.(interface{ SetHistory(*history.Store) }); ok {
+						versioned.SetHistory(store)
+					}
+				}
+			}
+			agent.Tools.Register(tools.NewFileHistoryTool(
+				agent.Workspace,
+				cfg.Agents.Defaults.RestrictToWorkspace,
+				store,
+			))
+		}
+
 		// Web tools
 		if searchTool := tools.NewWebSearchTool(tools.WebSearchToolOptions{
 			BraveAPIKey:          cfg.Tools.Web.Brave.APIKey,
@@ -112,14 +223,68 @@ func registerSharedTools(
 			PerplexityMaxResults: cfg.Tools.Web.Perplexity.MaxResults,
 			PerplexityEnabled:    cfg.Tools.Web.Perplexity.Enabled,
 			Proxy:                cfg.Tools.Web.Proxy,
+			Network:              searchPolicy,
 		}); searchTool != nil {
 			agent.Tools.Register(searchTool)
 		}
-		agent.Tools.Register(tools.NewWebFetchToolWithProxy(50000, cfg.Tools.Web.Proxy))
+		fetchTool := tools.NewWebFetchToolWithProxy(50000, cfg.Tools.Web.Proxy)
+		fetchTool.SetNetworkPolicy(agent.NetworkPolicy)
+		agent.Tools.Register(fetchTool)
+		if cfg.Tools.HTTPRequest.Enabled {
+			httpTool := tools.NewHTTPRequestTool(cfg.Tools.HTTPRequest, cfg.Tools.Network, agent.ID)
+			httpTool.SetNetworkPolicy(agent.NetworkPolicy)
+			agent.Tools.Register(httpTool)
+		}
+		if cfg.Tools.Browser.Enabled {
+			browserTool := tools.NewBrowserTool(cfg.Tools.Browser)
+			browserTool.SetNetworkPolicy(agent.NetworkPolicy)
+			agent.Tools.Register(browserTool)
+		}
 
-		// Hardware tools (I2C, SPI) - Linux only, returns error on other platforms
+		// Image generation backed by a model_list entry
+		if imageGen != nil {
+			agent.Tools.Register(tools.NewImageGenerateTool(
+				imageGen,
+				agent.Workspace,
+				cfg.Agents.Defaults.RestrictToWorkspace,
+				cfg.Tools.ImageGen.Size,
+				cfg.Tools.ImageGen.MaxN,
+			))
+		}
+
+		// Background exec processes, announced through the system channel when they exit
+		if tool, ok := agent.Tools.Get("exec"); ok {
+			if execTool, ok := tool.(*tools.ExecTool); ok {
+				agent.Processes = tools.NewProcessManager(
+					msgBus,
+					cfg.Tools.Exec.MaxBackgroundProcesses,
+					cfg.Tools.Exec.BackgroundOutputKB*1024,
+				)
+				agent.Processes.SetMaxRuntime(
+					time.Duration(cfg.Tools.Exec.BackgroundMaxRuntimeMinutes) * time.Minute)
+				execTool.SetProcessManager(agent.Processes)
+				agent.Tools.Register(tools.NewProcessTool(agent.Processes))
+			}
+		}
+
+		// Hardware tools (I2C, SPI, and GPIO, PWM, ADC and serial when enabled in the config) - Linux only
 		agent.Tools.Register(tools.NewI2CTool())
 		agent.Tools.Register(tools.NewSPITool())
+		if cfg.Tools.GPIO.Enabled {
+			agent.Tools.Register(tools.NewGPIOTool(cfg.Tools.GPIO.Lines))
+		}
+		if cfg.Tools.PWM.Enabled {
+			agent.Tools.Register(tools.NewPWMTool(cfg.Tools.PWM.Channels))
+		}
+		if cfg.Tools.ADC.Enabled {
+			agent.Tools.Register(tools.NewADCTool(cfg.Tools.ADC.Channels))
+		}
+		if cfg.Tools.Serial.Enabled {
+			agent.Tools.Register(tools.NewSerialTool(cfg.Tools.Serial.AllowedPorts, cfg.Tools.Serial.DefaultBaud))
+		}
+		if mqttPublish != nil {
+			agent.Tools.Register(mqttPublish)
+		}
 
 		// Message tool
 		messageTool := tools.NewMessageTool()
@@ -149,6 +314,7 @@ func registerSharedTools(
 		// Spawn tool with allowlist checker
 		subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace, msgBus)
 		subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
+		subagentManager.SetToolFilter(agent.Tools.Filter())
 		spawnTool := tools.NewSpawnTool(subagentManager)
 		currentAgentID := agentID
 		spawnTool.SetAllowlistChecker(func(targetAgentID string) bool {
@@ -158,17 +324,49 @@ func registerSharedTools(
 	}
 }
 
+// createImageGenerator builds the generator for the image_generate tool, or
+// returns nil when the tool is disabled or misconfigured.
+func createImageGenerator(cfg *config.Config) providers.ImageGenerator {
+	imgCfg := cfg.Tools.ImageGen
+	if !imgCfg.Enabled {
+		return nil
+	}
+	if imgCfg.Model == "" {
+		logger.WarnCF("agent", "image_generate enabled but tools.image_gen.model is empty", nil)
+		return nil
+	}
+	mc, err := cfg.GetModelConfig(imgCfg.Model)
+	if err != nil {
+		logger.WarnCF("agent", "image_generate model not found in model_list", map[string]any{
+			"model": imgCfg.Model,
+			"error": err.Error(),
+		})
+		return nil
+	}
+	gen, err := providers.CreateImageGeneratorFromConfig(mc)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to create image generator", map[string]any{
+			"model": imgCfg.Model,
+			"error": err.Error(),
+		})
+		return nil
+	}
+	return gen
+}
+
 func (al *AgentLoop) Run(ctx context.Context) error {
 	al.running.Store(true)
 
+	inbound := make(chan bus.InboundMessage, 64)
+	go al.readInbound(ctx, inbound)
+
 	for al.running.Load() {
 		select {
 		case <-ctx.Done():
 			return nil
-		default:
-			msg, ok := al.bus.ConsumeInbound(ctx)
+		case msg, ok := <-inbound:
 			if !ok {
-				continue
+				return nil
 			}
 
 			// Process message
@@ -232,8 +430,52 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 	return nil
 }
 
+// readInbound feeds inbound messages to Run. Approval replies are handled
+// here as soon as they arrive, because the message whose tool call is waiting
+// for them is still being processed and holds up the queue.
+func (al *AgentLoop) readInbound(ctx context.Context, out chan<- bus.InboundMessage) {
+	defer close(out)
+	for al.running.Load() {
+		msg, ok := al.bus.ConsumeInbound(ctx)
+		if !ok {
+			return
+		}
+		if al.approvals != nil && al.approvals.HandleReply(ctx, msg) {
+			continue
+		}
+		select {
+		case out <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (al *AgentLoop) Stop() {
 	al.running.Store(false)
+	if al.mcp != nil {
+		al.mcp.Close()
+	}
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		if agent.Processes != nil {
+			agent.Processes.Close()
+		}
+		if tool, ok := agent.Tools.Get("browser"); ok {
+			if browserTool, ok := tool.(*tools.BrowserTool); ok {
+				browserTool.Close()
+			}
+		}
+	}
+}
+
+// Approvals returns the tool approval manager, or nil when approvals are
+// disabled.
+func (al *AgentLoop) Approvals() *approval.Manager {
+	return al.approvals
 }
 
 func (al *AgentLoop) RegisterTool(tool tools.Tool) {
@@ -244,13 +486,74 @@ func (al *AgentLoop) RegisterTool(tool tools.Tool) {
 	}
 }
 
+// GetAgent returns the agent with the given ID, or the default agent when
+// agentID is empty.
+func (al *AgentLoop) GetAgent(agentID string) (*AgentInstance, bool) {
+	if agentID == "" {
+		agent := al.registry.GetDefaultAgent()
+		return agent, agent != nil
+	}
+	return al.registry.GetAgent(agentID)
+}
+
+// Cooldown returns the provider cooldown tracker shared by all agents' fallback chains.
+func (al *AgentLoop) Cooldown() *providers.CooldownTracker {
+	return al.cooldown
+}
+
+// PrimaryCandidate returns the first fallback candidate of the default agent,
+// i.e. the provider/model that should be serving traffic when all is well.
+func (al *AgentLoop) PrimaryCandidate() (providers.FallbackCandidate, bool) {
+	agent := al.registry.GetDefaultAgent()
+	if agent == nil || len(agent.Candidates) == 0 {
+		return providers.FallbackCandidate{}, false
+	}
+	return agent.Candidates[0], true
+}
+
 func (al *AgentLoop) SetChannelManager(cm *channels.Manager) {
 	al.channelManager = cm
 }
 
-// SetMediaStore injects a MediaStore for media lifecycle management.
+// SetMediaStore injects a MediaStore for media lifecycle management and
+// hands it to tools that produce media.
 func (al *AgentLoop) SetMediaStore(s media.MediaStore) {
 	al.mediaStore = s
+
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		for _, name := range agent.Tools.List() {
+			if tool, ok := agent.Tools.Get(name); ok {
+				if mt, ok := tool.(tools.MediaStoreAware); ok {
+					mt.SetMediaStore(s)
+				}
+			}
+		}
+	}
+}
+
+// resolveMediaPaths maps media:// refs to local file paths so providers with
+// native multimodal input can attach them. Unresolvable refs are dropped.
+func (al *AgentLoop) resolveMediaPaths(refs []string) []string {
+	if len(refs) == 0 || al.mediaStore == nil {
+		return nil
+	}
+	paths := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		path, err := al.mediaStore.Resolve(ref)
+		if err != nil {
+			logger.DebugCF("agent", "Failed to resolve media ref", map[string]any{
+				"ref":   ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
 }
 
 // inferMediaType determines the media type ("image", "audio", "video", "file")
@@ -366,19 +669,7 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	}
 
 	// Route to determine agent and session key
-	route := al.registry.ResolveRoute(routing.RouteInput{
-		Channel:    msg.Channel,
-		AccountID:  msg.Metadata["account_id"],
-		Peer:       extractPeer(msg),
-		ParentPeer: extractParentPeer(msg),
-		GuildID:    msg.Metadata["guild_id"],
-		TeamID:     msg.Metadata["team_id"],
-	})
-
-	agent, ok := al.registry.GetAgent(route.AgentID)
-	if !ok {
-		agent = al.registry.GetDefaultAgent()
-	}
+	route, agent := al.routeMessage(msg)
 	if agent == nil {
 		return "", fmt.Errorf("no agent available for route (agent_id=%s)", route.AgentID)
 	}
@@ -408,9 +699,11 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		Channel:         msg.Channel,
 		ChatID:          msg.ChatID,
 		UserMessage:     msg.Content,
+		Media:           msg.Media,
 		DefaultResponse: defaultResponse,
 		EnableSummary:   true,
 		SendResponse:    false,
+		PublishMedia:    msg.Channel != "cli", // Run publishes the final text; tool media goes out here
 	})
 }
 
@@ -500,7 +793,7 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		history,
 		summary,
 		opts.UserMessage,
-		nil,
+		al.resolveMediaPaths(opts.Media),
 		opts.Channel,
 		opts.ChatID,
 	)
@@ -706,6 +999,7 @@ func (al *AgentLoop) runLLMIteration(
 				})
 			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
+		providers.RecordUsage(ctx, response.Usage)
 
 		go al.handleReasoning(ctx, response.Reasoning, opts.Channel, al.targetReasoningChannelID(opts.Channel))
 
@@ -809,14 +1103,21 @@ func (al *AgentLoop) runLLMIteration(
 				}
 			}
 
-			toolResult := agent.Tools.ExecuteWithContext(
-				ctx,
-				tc.Name,
-				tc.Arguments,
-				opts.Channel,
-				opts.ChatID,
-				asyncCallback,
-			)
+			var toolResult *tools.ToolResult
+			if err := al.checkApproval(ctx, agent, opts, tc.Name, tc.Arguments); err != nil {
+				toolResult = tools.ErrorResult(
+					fmt.Sprintf("Tool call %s was not approved: %v", tc.Name, err),
+				).WithError(err)
+			} else {
+				toolResult = agent.Tools.ExecuteWithContext(
+					ctx,
+					tc.Name,
+					tc.Arguments,
+					opts.Channel,
+					opts.ChatID,
+					asyncCallback,
+				)
+			}
 
 			// Send ForUser content to user immediately if not Silent
 			if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
@@ -833,7 +1134,7 @@ func (al *AgentLoop) runLLMIteration(
 			}
 
 			// If tool returned media refs, publish them as outbound media
-			if len(toolResult.Media) > 0 && opts.SendResponse {
+			if len(toolResult.Media) > 0 && (opts.SendResponse || opts.PublishMedia) {
 				parts := make([]bus.MediaPart, 0, len(toolResult.Media))
 				for _, ref := range toolResult.Media {
 					part := bus.MediaPart{Ref: ref}
@@ -875,6 +1176,28 @@ func (al *AgentLoop) runLLMIteration(
 	return finalContent, iteration, nil
 }
 
+// checkApproval applies the tool approval policy to a call, pausing until
+// the originating chat answers when the policy asks.
+func (al *AgentLoop) checkApproval(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+	name string,
+	args map[string]any,
+) error {
+	if al.approvals == nil {
+		return nil
+	}
+	return al.approvals.Check(ctx, approval.Call{
+		AgentID:    agent.ID,
+		SessionKey: opts.SessionKey,
+		Channel:    opts.Channel,
+		ChatID:     opts.ChatID,
+		Tool:       name,
+		Args:       args,
+	})
+}
+
 // updateToolContexts updates the context for tools that need channel/chatID info.
 func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID string) {
 	// Use ContextualTool interface instead of type assertions
@@ -991,6 +1314,18 @@ func (al *AgentLoop) GetStartupInfo() map[string]any {
 		"ids":   al.registry.ListAgentIDs(),
 	}
 
+	// MCP servers info
+	if al.mcp != nil && len(al.mcp.Servers()) > 0 {
+		servers := make(map[string]any)
+		for _, s := range al.mcp.Servers() {
+			servers[s.Name()] = map[string]any{
+				"connected": s.Connected(),
+				"tools":     len(s.ToolInfos()),
+			}
+		}
+		info["mcp"] = servers
+	}
+
 	return info
 }
 
@@ -1176,6 +1511,33 @@ func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
 	return totalChars * 2 / 5
 }
 
+// routeMessage resolves the route for an inbound message and the agent that
+// handles it, falling back to the default agent. The agent is nil when no
+// agent is configured.
+func (al *AgentLoop) routeMessage(msg bus.InboundMessage) (routing.ResolvedRoute, *AgentInstance) {
+	route := al.registry.ResolveRoute(routing.RouteInput{
+		Channel:    msg.Channel,
+		AccountID:  msg.Metadata["account_id"],
+		Peer:       extractPeer(msg),
+		ParentPeer: extractParentPeer(msg),
+		GuildID:    msg.Metadata["guild_id"],
+		TeamID:     msg.Metadata["team_id"],
+	})
+
+	agent, ok := al.registry.GetAgent(route.AgentID)
+	// An agent-scoped session key (ProcessDirect callers such as MCP chat)
+	// selects its own agent when that agent exists.
+	if parsed := routing.ParseAgentSessionKey(msg.SessionKey); parsed != nil {
+		if scoped, exists := al.registry.GetAgent(parsed.AgentID); exists {
+			agent, ok = scoped, true
+		}
+	}
+	if !ok {
+		agent = al.registry.GetDefaultAgent()
+	}
+	return route, agent
+}
+
 func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage) (string, bool) {
 	content := strings.TrimSpace(msg.Content)
 	if !strings.HasPrefix(content, "/") {
@@ -1213,7 +1575,7 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 
 	case "/list":
 		if len(args) < 1 {
-			return "Usage: /list [models|channels|agents]", true
+			return "Usage: /list [models|channels|agents|tools]", true
 		}
 		switch args[0] {
 		case "models":
@@ -1230,6 +1592,12 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 		case "agents":
 			agentIDs := al.registry.ListAgentIDs()
 			return fmt.Sprintf("Registered agents: %s", strings.Join(agentIDs, ", ")), true
+		case "tools":
+			_, agent := al.routeMessage(msg)
+			if agent == nil {
+				return "No agent configured", true
+			}
+			return formatToolList(agent), true
 		default:
 			return fmt.Sprintf("Unknown list target: %s", args[0]), true
 		}
@@ -1261,11 +1629,86 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 		default:
 			return fmt.Sprintf("Unknown switch target: %s", target), true
 		}
+
+	case "/clear":
+		route, agent := al.routeMessage(msg)
+		if agent == nil {
+			return "No agent configured", true
+		}
+		sessionKey := route.SessionKey
+		if strings.HasPrefix(msg.SessionKey, "agent:") {
+			sessionKey = msg.SessionKey
+		}
+		agent.Sessions.TruncateHistory(sessionKey, 0)
+		agent.Sessions.SetSummary(sessionKey, "")
+		agent.Sessions.Save(sessionKey)
+		// Background processes belong to the conversation, so they go with it.
+		if agent.Processes != nil {
+			if n := agent.Processes.KillSession(msg.Channel, msg.ChatID); n > 0 {
+				return fmt.Sprintf("Conversation cleared; stopped %d background process(es)", n), true
+			}
+		}
+		return "Conversation cleared", true
+
+	case "/cron":
+		route, agent := al.routeMessage(msg)
+		if agent == nil {
+			return "No agent configured", true
+		}
+		// The routed agent's registry only holds tools its filter allows.
+		tool, ok := agent.Tools.Get("cron")
+		if !ok {
+			return "Scheduled jobs are not available", true
+		}
+		ct, ok := tool.(commandTool)
+		if !ok {
+			return "Scheduled jobs are not available", true
+		}
+		opts := processOptions{SessionKey: route.SessionKey, Channel: msg.Channel, ChatID: msg.ChatID}
+		if strings.HasPrefix(msg.SessionKey, "agent:") {
+			opts.SessionKey = msg.SessionKey
+		}
+		return ct.HandleCommand(ctx, msg.Channel, msg.ChatID, args, func(toolArgs map[string]any) error {
+			return al.checkApproval(ctx, agent, opts, "cron", toolArgs)
+		}), true
 	}
 
 	return "", false
 }
 
+// formatToolList describes the tools an agent can use and the allow and deny
+// lists that restrict them, for /list tools.
+func formatToolList(agent *AgentInstance) string {
+	var sb strings.Builder
+	names := agent.Tools.List()
+	fmt.Fprintf(&sb, "Tools for agent %s (%d):\n", agent.ID, len(names))
+	if len(names) == 0 {
+		sb.WriteString("(none)\n")
+	} else {
+		sb.WriteString(strings.Join(names, ", ") + "\n")
+	}
+	allow, deny := agent.Tools.Filter().Rules()
+	if len(allow) > 0 {
+		fmt.Fprintf(&sb, "Allowed: %s\n", strings.Join(allow, ", "))
+	}
+	if len(deny) > 0 {
+		fmt.Fprintf(&sb, "Denied: %s\n", strings.Join(deny, ", "))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// commandTool is a tool that also serves a slash command, such as /cron.
+// The command is scoped to the chat it came from, and authorize applies the
+// approval policy to the tool call it makes.
+type commandTool interface {
+	HandleCommand(
+		ctx context.Context,
+		channel, chatID string,
+		args []string,
+		authorize func(args map[string]any) error,
+	) string
+}
+
 // extractPeer extracts the routing peer from the inbound message's structured Peer field.
 func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	if msg.Peer.Kind == "" {
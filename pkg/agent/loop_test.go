@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -798,3 +801,275 @@ func TestHandleReasoning(t *testing.T) {
 		}
 	})
 }
+
+func TestProcessDirect_AgentScopedSessionKeySelectsAgent(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true, Workspace: filepath.Join(tmpDir, "main")},
+				{ID: "helper", Workspace: filepath.Join(tmpDir, "helper")},
+			},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "done"})
+	key := "agent:helper:mcp:default"
+	if _, err := al.ProcessDirect(context.Background(), "hello", key); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+
+	helper, _ := al.GetAgent("helper")
+	main, _ := al.GetAgent("")
+	if main.ID != "main" {
+		t.Fatalf("default agent = %q, want main", main.ID)
+	}
+	if len(helper.Sessions.GetHistory(key)) == 0 {
+		t.Error("expected the helper agent to handle the agent-scoped session")
+	}
+	if len(main.Sessions.GetHistory(key)) != 0 {
+		t.Error("default agent should not have handled the agent-scoped session")
+	}
+}
+
+func TestHandleCommand_ListToolsUsesRoutedAgent(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "main", Default: true, Workspace: filepath.Join(tmpDir, "main")},
+				{
+					ID:        "public",
+					Workspace: filepath.Join(tmpDir, "public"),
+					Tools:     &config.AgentToolsConfig{Deny: []string{"exec", "hardware"}},
+				},
+			},
+		},
+		Bindings: []config.AgentBinding{
+			{AgentID: "public", Match: config.BindingMatch{Channel: "discord"}},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	public, _ := al.GetAgent("public")
+	for _, name := range []string{"exec", "process", "i2c", "spi"} {
+		if _, ok := public.Tools.Get(name); ok {
+			t.Errorf("public agent has denied tool %q", name)
+		}
+	}
+	if _, ok := public.Tools.Get("web_fetch"); !ok {
+		t.Error("public agent misses web_fetch")
+	}
+
+	response, handled := al.handleCommand(context.Background(), bus.InboundMessage{
+		Channel: "discord",
+		ChatID:  "general",
+		Content: "/list tools",
+	})
+	if !handled || !strings.Contains(response, "Tools for agent public") ||
+		!strings.Contains(response, "Denied: exec, hardware") || strings.Contains(response, "i2c") {
+		t.Errorf("/list tools on discord = %q", response)
+	}
+
+	response, _ = al.handleCommand(context.Background(), bus.InboundMessage{
+		Channel: "telegram",
+		ChatID:  "1",
+		Content: "/list tools",
+	})
+	if !strings.Contains(response, "Tools for agent main") || !strings.Contains(response, "exec") {
+		t.Errorf("/list tools on telegram = %q", response)
+	}
+}
+
+func TestHandleCommand_ClearKillsBackgroundProcesses(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("background processes use POSIX shell commands")
+	}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	defer al.Stop()
+
+	msg := bus.InboundMessage{Channel: "telegram", ChatID: "1", Content: "/clear"}
+	route, agent := al.routeMessage(msg)
+	agent.Sessions.AddMessage(route.SessionKey, "user", "hello")
+
+	tool, ok := agent.Tools.Get("exec")
+	if !ok {
+		t.Fatal("exec tool not registered")
+	}
+	execTool := tool.(*tools.ExecTool)
+	execTool.SetContext("telegram", "1")
+	res := execTool.Execute(context.Background(), map[string]any{"command": "sleep 30", "background": true})
+	if res.IsError {
+		t.Fatalf("background exec failed: %s", res.ForLLM)
+	}
+
+	response, handled := al.handleCommand(context.Background(), msg)
+	if !handled || !strings.Contains(response, "stopped 1 background process") {
+		t.Errorf("/clear = %q", response)
+	}
+	if history := agent.Sessions.GetHistory(route.SessionKey); len(history) != 0 {
+		t.Errorf("history after /clear = %+v", history)
+	}
+	for _, proc := range agent.Processes.List("telegram", "1") {
+		if proc.Running() {
+			t.Errorf("process %s still running after /clear", proc.ID)
+		}
+	}
+}
+
+// toolCallMockProvider requests one call of tool, then answers with the
+// content of the tool result it got back.
+type toolCallMockProvider struct {
+	tool string
+}
+
+func (m *toolCallMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	if last.Role == "tool" {
+		return &providers.LLMResponse{Content: last.Content}, nil
+	}
+	return &providers.LLMResponse{
+		ToolCalls: []providers.ToolCall{{
+			ID:        "call_1",
+			Name:      m.tool,
+			Arguments: map[string]any{"command": "rm -rf build"},
+		}},
+	}, nil
+}
+
+func (m *toolCallMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+// countingTool counts its executions.
+type countingTool struct {
+	calls atomic.Int32
+}
+
+func (c *countingTool) Name() string        { return "counted" }
+func (c *countingTool) Description() string { return "Counts calls" }
+func (c *countingTool) Parameters() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+func (c *countingTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	c.calls.Add(1)
+	return tools.SilentResult("tool ran")
+}
+
+func newApprovalTestLoop(t *testing.T, approvalCfg config.ApprovalConfig) (*AgentLoop, *bus.MessageBus, *countingTool) {
+	t.Helper()
+	approvalCfg.Enabled = true
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Tools: config.ToolsConfig{Approval: approvalCfg},
+	}
+	msgBus := bus.NewMessageBus()
+	t.Cleanup(msgBus.Close)
+	al := NewAgentLoop(cfg, msgBus, &toolCallMockProvider{tool: "counted"})
+	tool := &countingTool{}
+	al.RegisterTool(tool)
+	return al, msgBus, tool
+}
+
+func TestAgentLoop_ApprovalNeverBlocksTool(t *testing.T) {
+	al, _, tool := newApprovalTestLoop(t, config.ApprovalConfig{
+		Rules: []config.ApprovalRule{{Tool: "counted", Pattern: `rm\s+-rf`, Policy: "never"}},
+	})
+
+	response := testHelper{al: al}.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user1",
+		ChatID:   "42",
+		Content:  "clean up",
+	})
+	if tool.calls.Load() != 0 {
+		t.Error("a tool call denied by policy must not run")
+	}
+	if !strings.Contains(response, "was not approved: denied by policy") {
+		t.Errorf("response = %q", response)
+	}
+}
+
+func TestAgentLoop_ApprovalPromptAndReply(t *testing.T) {
+	al, msgBus, tool := newApprovalTestLoop(t, config.ApprovalConfig{
+		Tools: map[string]string{"counted": "ask"},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go al.Run(ctx)
+	defer al.Stop()
+
+	msgBus.PublishInbound(ctx, bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user1",
+		ChatID:   "42",
+		Content:  "clean up",
+	})
+
+	prompt, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || len(prompt.Buttons) == 0 {
+		t.Fatalf("expected an approval prompt with buttons, got %+v", prompt)
+	}
+	if prompt.ChatID != "42" || tool.calls.Load() != 0 {
+		t.Fatalf("prompt routed to %q, tool calls %d", prompt.ChatID, tool.calls.Load())
+	}
+
+	// The reply arrives while the first message is still being processed.
+	msgBus.PublishInbound(ctx, bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user1",
+		ChatID:   "42",
+		Content:  prompt.Buttons[0][0].Data,
+	})
+
+	var replies []string
+	for len(replies) < 2 {
+		msg, ok := msgBus.SubscribeOutbound(ctx)
+		if !ok {
+			t.Fatalf("timed out waiting for replies, got %q", replies)
+		}
+		replies = append(replies, msg.Content)
+	}
+	if tool.calls.Load() != 1 {
+		t.Errorf("tool calls = %d, want 1 after approval", tool.calls.Load())
+	}
+	if !strings.HasPrefix(replies[0], "Approved counted") || replies[1] != "tool ran" {
+		t.Errorf("replies = %q", replies)
+	}
+}
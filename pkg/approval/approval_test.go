@@ -0,0 +1,223 @@
+package approval
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestPolicy_Evaluate(t *testing.T) {
+	p, err := NewPolicy(config.ApprovalConfig{
+		Default: "always",
+		Tools:   map[string]string{"exec": "ask", "write_file": "never"},
+		Rules: []config.ApprovalRule{
+			{Tool: "exec", Pattern: `^(ls|pwd)\b`, Policy: "always"},
+			{Tool: "*", Pattern: `/etc/`, Policy: "never"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tool string
+		args map[string]any
+		want Match
+	}{
+		{"exec", map[string]any{"command": "ls -la"}, Match{ModeAlways, "exec#rule0"}},
+		{"exec", map[string]any{"command": "rm -rf build"}, Match{ModeAsk, "exec"}},
+		{"read_file", map[string]any{"path": "/etc/shadow"}, Match{ModeNever, "read_file#rule1"}},
+		{"http", map[string]any{"headers": map[string]any{"x": []any{"/etc/passwd"}}}, Match{ModeNever, "http#rule1"}},
+		{"write_file", map[string]any{"path": "notes.md"}, Match{ModeNever, "write_file"}},
+		{"read_file", map[string]any{"path": "notes.md"}, Match{ModeAlways, "read_file"}},
+	}
+	for _, tt := range tests {
+		if got := p.Evaluate(tt.tool, tt.args); got != tt.want {
+			t.Errorf("Evaluate(%s, %v) = %+v, want %+v", tt.tool, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestNewPolicy_RejectsInvalidConfig(t *testing.T) {
+	for _, cfg := range []config.ApprovalConfig{
+		{Default: "maybe"},
+		{Tools: map[string]string{"exec": "sometimes"}},
+		{Rules: []config.ApprovalRule{{Pattern: "(", Policy: "ask"}}},
+	} {
+		if _, err := NewPolicy(cfg); err == nil {
+			t.Errorf("NewPolicy(%+v) should fail", cfg)
+		}
+	}
+}
+
+func newTestManager(t *testing.T, cfg config.ApprovalConfig) (*Manager, *bus.MessageBus, string) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	t.Cleanup(msgBus.Close)
+	stateDir := t.TempDir()
+	m, err := NewManager(cfg, stateDir, msgBus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, msgBus, filepath.Join(stateDir, "approvals.jsonl")
+}
+
+var execCall = Call{
+	AgentID:    "main",
+	SessionKey: "s1",
+	Channel:    "telegram",
+	ChatID:     "42",
+	Tool:       "exec",
+	Args:       map[string]any{"command": "make deploy"},
+}
+
+// answer waits for the prompt of the next call and replies with content
+// from the given chat.
+func answer(t *testing.T, m *Manager, msgBus *bus.MessageBus, chatID, content string) (bus.OutboundMessage, bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	prompt, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("no approval prompt")
+	}
+	content = strings.ReplaceAll(content, "<id>", promptID(prompt))
+	handled := m.HandleReply(ctx, bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "telegram:7",
+		ChatID:   chatID,
+		Content:  content,
+	})
+	return prompt, handled
+}
+
+func promptID(prompt bus.OutboundMessage) string {
+	return strings.Fields(strings.TrimPrefix(prompt.Buttons[0][0].Data, "/approve "))[0]
+}
+
+func TestManager_AskApproveAndRemember(t *testing.T) {
+	m, msgBus, auditPath := newTestManager(t, config.ApprovalConfig{Tools: map[string]string{"exec": "ask"}})
+
+	done := make(chan error, 1)
+	go func() { done <- m.Check(context.Background(), execCall) }()
+	prompt, handled := answer(t, m, msgBus, "42", "/approve@picobot <id> always")
+	if !handled {
+		t.Fatal("reply not handled")
+	}
+	if prompt.Channel != "telegram" || prompt.ChatID != "42" || !strings.Contains(prompt.Content, "make deploy") {
+		t.Errorf("prompt = %+v", prompt)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Check() = %v, want approval", err)
+	}
+
+	// Remembered for the session without another prompt.
+	if err := m.Check(context.Background(), execCall); err != nil {
+		t.Errorf("remembered Check() = %v", err)
+	}
+	other := execCall
+	other.SessionKey = "s2"
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := m.Check(ctx, other); err == nil {
+		t.Error("another session must be asked again")
+	}
+
+	f, err := os.Open(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var decisions []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("bad audit line %q: %v", scanner.Text(), err)
+		}
+		decisions = append(decisions, entry.Decision+"/"+entry.DecidedBy)
+	}
+	want := []string{"approved/telegram:7", "approved/session", "canceled/"}
+	if strings.Join(decisions, ",") != strings.Join(want, ",") {
+		t.Errorf("audit decisions = %v, want %v", decisions, want)
+	}
+}
+
+func TestManager_DenyAndWrongChat(t *testing.T) {
+	m, msgBus, _ := newTestManager(t, config.ApprovalConfig{Default: "ask"})
+
+	done := make(chan error, 1)
+	go func() { done <- m.Check(context.Background(), execCall) }()
+	prompt, handled := answer(t, m, msgBus, "99", "/approve <id>")
+	if !handled {
+		t.Fatal("reply not handled")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if reply, _ := msgBus.SubscribeOutbound(ctx); !strings.Contains(reply.Content, "No pending approval") {
+		t.Errorf("reply from another chat = %q", reply.Content)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("another chat resolved the request: %v", err)
+	default:
+	}
+
+	m.HandleReply(ctx, bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "telegram:7",
+		ChatID:   "42",
+		Content:  "/deny " + promptID(prompt),
+	})
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "denied by telegram:7") {
+		t.Errorf("Check() = %v, want denial", err)
+	}
+
+	if m.HandleReply(ctx, bus.InboundMessage{Channel: "telegram", ChatID: "42", Content: "approve the plan"}) {
+		t.Error("ordinary messages must not be consumed")
+	}
+}
+
+func TestManager_TimeoutAndNoApprover(t *testing.T) {
+	m, msgBus, _ := newTestManager(t, config.ApprovalConfig{Default: "ask"})
+	m.timeout = 50 * time.Millisecond
+
+	err := m.Check(context.Background(), execCall)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Check() = %v, want timeout", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msgBus.SubscribeOutbound(ctx) // prompt
+	if notice, _ := msgBus.SubscribeOutbound(ctx); !strings.Contains(notice.Content, "timed out") {
+		t.Errorf("timeout notice = %q", notice.Content)
+	}
+
+	internal := execCall
+	internal.Channel = "subagent"
+	if err := m.Check(context.Background(), internal); err == nil {
+		t.Error("calls from channels that cannot prompt must be denied")
+	}
+}
+
+func TestManager_CustomPrompter(t *testing.T) {
+	m, _, _ := newTestManager(t, config.ApprovalConfig{Default: "ask"})
+	m.SetPrompter("cli", func(ctx context.Context, req *Request) error {
+		m.Resolve(req.ID, true, false, "cli")
+		return nil
+	})
+
+	call := execCall
+	call.Channel, call.ChatID = "cli", "direct"
+	if err := m.Check(context.Background(), call); err != nil {
+		t.Errorf("Check() = %v, want approval from the prompter", err)
+	}
+}
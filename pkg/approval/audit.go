@@ -0,0 +1,68 @@
+package approval
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// auditEntry is one line of the approval audit log.
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	ID         string    `json:"id,omitempty"`
+	AgentID    string    `json:"agent_id,omitempty"`
+	SessionKey string    `json:"session_key,omitempty"`
+	Channel    string    `json:"channel,omitempty"`
+	ChatID     string    `json:"chat_id,omitempty"`
+	Tool       string    `json:"tool"`
+	Args       string    `json:"args"`
+	Rule       string    `json:"rule"`
+	Decision   string    `json:"decision"`             // approved, denied, timeout or canceled
+	DecidedBy  string    `json:"decided_by,omitempty"` // approver ID, "policy", "session" or "no_approver"
+	Remember   bool      `json:"remember,omitempty"`
+	WaitMS     int64     `json:"wait_ms,omitempty"`
+}
+
+// auditLog appends entries to a JSON Lines file. An empty path disables it.
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newAuditLog(path string) *auditLog {
+	return &auditLog{path: path}
+}
+
+func (a *auditLog) write(entry auditEntry) {
+	if a.path == "" {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+		logger.WarnCF("approval", "Failed to create audit log directory",
+			map[string]any{"path": a.path, "error": err.Error()})
+		return
+	}
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		logger.WarnCF("approval", "Failed to open audit log",
+			map[string]any{"path": a.path, "error": err.Error()})
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		logger.WarnCF("approval", "Failed to write audit log",
+			map[string]any{"path": a.path, "error": err.Error()})
+	}
+}
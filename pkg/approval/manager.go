@@ -0,0 +1,369 @@
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	defaultTimeout  = 5 * time.Minute
+	maxPromptArgLen = 500
+)
+
+// Call describes a tool call that may need approval.
+type Call struct {
+	AgentID    string
+	SessionKey string
+	Channel    string
+	ChatID     string
+	Tool       string
+	Args       map[string]any
+}
+
+// Request is an approval prompt waiting for a decision.
+type Request struct {
+	Call
+	ID      string
+	Key     string
+	Created time.Time
+	Timeout time.Duration
+
+	done chan decision
+}
+
+// ArgsPreview returns the call arguments as truncated JSON.
+func (r *Request) ArgsPreview() string {
+	return argsPreview(r.Args)
+}
+
+func argsPreview(args map[string]any) string {
+	data, _ := json.Marshal(args)
+	return utils.Truncate(string(data), maxPromptArgLen)
+}
+
+type decision struct {
+	approved bool
+	remember bool
+	by       string
+}
+
+// Prompter delivers the prompt for req to a human. A prompter may return as
+// soon as the prompt is sent, or block until the user answers and call
+// Manager.Resolve itself.
+type Prompter func(ctx context.Context, req *Request) error
+
+// Manager evaluates tool calls against a Policy and tracks pending prompts,
+// remembered per-session decisions and the audit log.
+type Manager struct {
+	policy  *Policy
+	timeout time.Duration
+	bus     *bus.MessageBus
+	audit   *auditLog
+
+	mu         sync.Mutex
+	pending    map[string]*Request
+	remembered map[string]bool // sessionKey + "\x00" + match key -> approved
+	prompters  map[string]Prompter
+}
+
+// NewManager builds a Manager from cfg. Prompts for channels without a
+// registered Prompter are published on msgBus; the audit log defaults to
+// approvals.jsonl in stateDir, which must lie outside any agent workspace so
+// the agent cannot rewrite its own audit trail.
+func NewManager(cfg config.ApprovalConfig, stateDir string, msgBus *bus.MessageBus) (*Manager, error) {
+	policy, err := NewPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	auditPath := cfg.AuditLog
+	if auditPath == "" && stateDir != "" {
+		auditPath = filepath.Join(stateDir, "approvals.jsonl")
+	}
+	return &Manager{
+		policy:     policy,
+		timeout:    timeout,
+		bus:        msgBus,
+		audit:      newAuditLog(auditPath),
+		pending:    make(map[string]*Request),
+		remembered: make(map[string]bool),
+		prompters:  make(map[string]Prompter),
+	}, nil
+}
+
+// SetPrompter routes prompts for calls from channel to p instead of the bus.
+func (m *Manager) SetPrompter(channel string, p Prompter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompters[channel] = p
+}
+
+// Mode returns the policy for tool before any argument rules are applied.
+func (m *Manager) Mode(tool string) Mode {
+	return m.policy.Evaluate(tool, nil).Mode
+}
+
+// Check decides whether call may run. For "ask" it sends a prompt to the
+// originating chat and blocks until a decision, the timeout or ctx ends. It
+// returns nil when the call may run and otherwise an error saying why not.
+func (m *Manager) Check(ctx context.Context, call Call) error {
+	match := m.policy.Evaluate(call.Tool, call.Args)
+	switch match.Mode {
+	case ModeAlways:
+		return nil
+	case ModeNever:
+		m.record(call, "", match.Key, "denied", "policy", false, 0)
+		return errors.New("denied by policy")
+	}
+
+	memKey := call.SessionKey + "\x00" + match.Key
+	m.mu.Lock()
+	approved, remembered := m.remembered[memKey]
+	prompter := m.prompters[call.Channel]
+	m.mu.Unlock()
+	if remembered {
+		if approved {
+			m.record(call, "", match.Key, "approved", "session", false, 0)
+			return nil
+		}
+		m.record(call, "", match.Key, "denied", "session", false, 0)
+		return errors.New("denied earlier in this session")
+	}
+
+	if prompter == nil {
+		if m.bus == nil || call.Channel == "" || constants.IsInternalChannel(call.Channel) {
+			m.record(call, "", match.Key, "denied", "no_approver", false, 0)
+			return fmt.Errorf("approval required but channel %q cannot prompt for it", call.Channel)
+		}
+		prompter = m.busPrompter
+	}
+
+	req := m.newRequest(call, match.Key)
+	if err := prompter(ctx, req); err != nil {
+		m.remove(req.ID)
+		m.record(call, req.ID, match.Key, "denied", "no_approver", false, 0)
+		return fmt.Errorf("could not send approval prompt: %w", err)
+	}
+
+	logger.InfoCF("approval", "Waiting for tool approval",
+		map[string]any{
+			"id":      req.ID,
+			"tool":    call.Tool,
+			"channel": call.Channel,
+			"chat_id": call.ChatID,
+		})
+
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+	select {
+	case d := <-req.done:
+		if d.remember {
+			m.mu.Lock()
+			m.remembered[memKey] = d.approved
+			m.mu.Unlock()
+		}
+		wait := time.Since(req.Created)
+		if d.approved {
+			m.record(call, req.ID, match.Key, "approved", d.by, d.remember, wait)
+			return nil
+		}
+		m.record(call, req.ID, match.Key, "denied", d.by, d.remember, wait)
+		return fmt.Errorf("denied by %s", d.by)
+	case <-timer.C:
+		m.remove(req.ID)
+		m.record(call, req.ID, match.Key, "timeout", "", false, m.timeout)
+		m.reply(ctx, call.Channel, call.ChatID,
+			fmt.Sprintf("Approval %s timed out; %s was not run.", req.ID, call.Tool))
+		return fmt.Errorf("approval timed out after %s", m.timeout)
+	case <-ctx.Done():
+		m.remove(req.ID)
+		m.record(call, req.ID, match.Key, "canceled", "", false, time.Since(req.Created))
+		return ctx.Err()
+	}
+}
+
+// Resolve records the decision for the pending request id. It returns the
+// request, or false when no such request is pending.
+func (m *Manager) Resolve(id string, approved, remember bool, by string) (*Request, bool) {
+	m.mu.Lock()
+	req, ok := m.pending[id]
+	delete(m.pending, id)
+	m.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	req.done <- decision{approved: approved, remember: remember, by: by}
+	return req, true
+}
+
+// Pending returns the request with the given id if it is still waiting.
+func (m *Manager) Pending(id string) (*Request, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	req, ok := m.pending[id]
+	return req, ok
+}
+
+// HandleReply consumes "/approve <id> [always]" and "/deny <id> [always]"
+// replies. It reports whether msg was such a reply; other messages are left
+// for the agent. Only the chat that received a prompt can answer it.
+func (m *Manager) HandleReply(ctx context.Context, msg bus.InboundMessage) bool {
+	fields := strings.Fields(msg.Content)
+	if len(fields) == 0 {
+		return false
+	}
+	cmd, _, _ := strings.Cut(fields[0], "@") // Telegram appends @botname in groups
+	var approved bool
+	switch cmd {
+	case "/approve":
+		approved = true
+	case "/deny":
+	default:
+		return false
+	}
+
+	if len(fields) < 2 {
+		m.reply(ctx, msg.Channel, msg.ChatID, fmt.Sprintf("Usage: %s <id> [always]", cmd))
+		return true
+	}
+	id := fields[1]
+	remember := len(fields) > 2 && strings.EqualFold(fields[2], "always")
+
+	req, ok := m.Pending(id)
+	if !ok || req.Channel != msg.Channel || req.ChatID != msg.ChatID {
+		m.reply(ctx, msg.Channel, msg.ChatID, fmt.Sprintf("No pending approval with id %s.", id))
+		return true
+	}
+	if _, ok := m.Resolve(id, approved, remember, approverName(msg)); !ok {
+		m.reply(ctx, msg.Channel, msg.ChatID, fmt.Sprintf("No pending approval with id %s.", id))
+		return true
+	}
+
+	verb := "Denied"
+	if approved {
+		verb = "Approved"
+	}
+	text := fmt.Sprintf("%s %s (%s).", verb, req.Tool, id)
+	if remember {
+		text = fmt.Sprintf("%s %s (%s); remembered for this session.", verb, req.Tool, id)
+	}
+	m.reply(ctx, msg.Channel, msg.ChatID, text)
+	return true
+}
+
+func approverName(msg bus.InboundMessage) string {
+	switch {
+	case msg.Sender.CanonicalID != "":
+		return msg.Sender.CanonicalID
+	case msg.SenderID != "":
+		return msg.SenderID
+	default:
+		return "user"
+	}
+}
+
+// busPrompter publishes the prompt to the originating chat. Channels that
+// support buttons render Buttons; the others show the reply commands.
+func (m *Manager) busPrompter(ctx context.Context, req *Request) error {
+	return m.bus.PublishOutbound(ctx, bus.OutboundMessage{
+		Channel: req.Channel,
+		ChatID:  req.ChatID,
+		Content: PromptText(req),
+		Buttons: [][]bus.Button{{
+			{Label: "Approve", Data: "/approve " + req.ID},
+			{Label: "Always allow", Data: "/approve " + req.ID + " always"},
+			{Label: "Deny", Data: "/deny " + req.ID},
+		}},
+	})
+}
+
+// PromptText describes req and how to answer it.
+func PromptText(req *Request) string {
+	return fmt.Sprintf("Approval needed (%s)\nTool: %s\nArguments: %s\n\n"+
+		"Reply /approve %s or /deny %s; add \"always\" to remember the decision for this session. "+
+		"Expires in %s.",
+		req.ID, req.Tool, req.ArgsPreview(), req.ID, req.ID, req.Timeout)
+}
+
+func (m *Manager) newRequest(call Call, key string) *Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := newID()
+	for m.pending[id] != nil {
+		id = newID()
+	}
+	req := &Request{
+		Call:    call,
+		ID:      id,
+		Key:     key,
+		Created: time.Now(),
+		Timeout: m.timeout,
+		done:    make(chan decision, 1),
+	}
+	m.pending[id] = req
+	return req
+}
+
+func (m *Manager) remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, id)
+}
+
+func (m *Manager) reply(ctx context.Context, channel, chatID, content string) {
+	if m.bus == nil || constants.IsInternalChannel(channel) {
+		return
+	}
+	m.bus.PublishOutbound(ctx, bus.OutboundMessage{Channel: channel, ChatID: chatID, Content: content})
+}
+
+func (m *Manager) record(call Call, id, key, result, by string, remember bool, wait time.Duration) {
+	logger.InfoCF("approval", "Tool approval decision",
+		map[string]any{
+			"id":         id,
+			"tool":       call.Tool,
+			"session":    call.SessionKey,
+			"decision":   result,
+			"decided_by": by,
+		})
+	m.audit.write(auditEntry{
+		Time:       time.Now().UTC(),
+		ID:         id,
+		AgentID:    call.AgentID,
+		SessionKey: call.SessionKey,
+		Channel:    call.Channel,
+		ChatID:     call.ChatID,
+		Tool:       call.Tool,
+		Args:       argsPreview(call.Args),
+		Rule:       key,
+		Decision:   result,
+		DecidedBy:  by,
+		Remember:   remember,
+		WaitMS:     wait.Milliseconds(),
+	})
+}
+
+func newID() string {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%06x", time.Now().UnixNano()&0xffffff)
+	}
+	return hex.EncodeToString(b)
+}
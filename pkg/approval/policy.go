@@ -0,0 +1,144 @@
+// Package approval asks a human before risky tool calls run. A Policy maps
+// each call to always, never or ask; a Manager turns "ask" into a prompt on
+// the originating chat and waits for the reply.
+package approval
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Mode is the approval policy applied to a tool call.
+type Mode string
+
+const (
+	ModeAlways Mode = "always" // run without asking
+	ModeNever  Mode = "never"  // reject without asking
+	ModeAsk    Mode = "ask"    // prompt the originating chat
+)
+
+func parseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeAlways, ModeNever, ModeAsk:
+		return m, nil
+	case "":
+		return ModeAlways, nil
+	default:
+		return "", fmt.Errorf("unknown approval policy %q (want always, never or ask)", s)
+	}
+}
+
+type rule struct {
+	tool    string
+	pattern *regexp.Regexp
+	mode    Mode
+}
+
+// Policy is the compiled form of config.ApprovalConfig.
+type Policy struct {
+	def   Mode
+	tools map[string]Mode
+	rules []rule
+}
+
+// Match is the outcome of evaluating a call against a Policy. Key names the
+// tool or rule that decided it; remembered session decisions are stored per
+// Key, so "always allow" on a pattern rule does not approve the whole tool.
+type Match struct {
+	Mode Mode
+	Key  string
+}
+
+// NewPolicy compiles the rules of cfg.
+func NewPolicy(cfg config.ApprovalConfig) (*Policy, error) {
+	def, err := parseMode(cfg.Default)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{def: def, tools: make(map[string]Mode, len(cfg.Tools))}
+	for tool, s := range cfg.Tools {
+		m, err := parseMode(s)
+		if err != nil {
+			return nil, fmt.Errorf("tools.%s: %w", tool, err)
+		}
+		p.tools[tool] = m
+	}
+	for i, r := range cfg.Rules {
+		m, err := parseMode(r.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		compiled := rule{tool: r.Tool, mode: m}
+		if compiled.tool == "*" {
+			compiled.tool = ""
+		}
+		if r.Pattern != "" {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rules[%d]: invalid pattern %q: %w", i, r.Pattern, err)
+			}
+			compiled.pattern = re
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+// Evaluate returns the policy for calling tool with args: the first matching
+// rule wins, then the per-tool policy, then the default.
+func (p *Policy) Evaluate(tool string, args map[string]any) Match {
+	var values []string
+	for i, r := range p.rules {
+		if r.tool != "" && r.tool != tool {
+			continue
+		}
+		if r.pattern != nil {
+			if values == nil {
+				values = stringArgs(args, nil)
+			}
+			if !matchAny(r.pattern, values) {
+				continue
+			}
+		}
+		return Match{Mode: r.mode, Key: fmt.Sprintf("%s#rule%d", tool, i)}
+	}
+	if m, ok := p.tools[tool]; ok {
+		return Match{Mode: m, Key: tool}
+	}
+	return Match{Mode: p.def, Key: tool}
+}
+
+func matchAny(re *regexp.Regexp, values []string) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringArgs collects every string value in args, including those nested in
+// lists and objects.
+func stringArgs(v any, out []string) []string {
+	switch val := v.(type) {
+	case string:
+		out = append(out, val)
+	case map[string]any:
+		for _, item := range val {
+			out = stringArgs(item, out)
+		}
+	case []any:
+		for _, item := range val {
+			out = stringArgs(item, out)
+		}
+	case []string:
+		out = append(out, val...)
+	}
+	if out == nil {
+		out = []string{}
+	}
+	return out
+}
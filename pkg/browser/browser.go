@@ -0,0 +1,418 @@
+package browser
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/netpolicy"
+)
+
+const (
+	defaultWidth  = 1280
+	defaultHeight = 800
+
+	// launchTimeout bounds how long Chromium may take to start listening.
+	launchTimeout = 20 * time.Second
+)
+
+// binaryNames are the Chromium executables looked for in PATH.
+var binaryNames = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "headless_shell",
+}
+
+// Options configures Start.
+type Options struct {
+	// CDPURL connects to a running browser instead of launching one. It is
+	// either the DevTools WebSocket URL or the http:// address of its
+	// debugging port. The browser must be able to reach the policy proxy,
+	// which listens on the loopback interface of this host.
+	CDPURL string
+	// BinaryPath is the Chromium executable to launch. When empty, the
+	// usual names are looked up in PATH.
+	BinaryPath string
+	// Policy limits where pages may connect; nil uses netpolicy.Default.
+	Policy *netpolicy.Policy
+	// Width and Height set the viewport size of new pages.
+	Width, Height int
+}
+
+// Browser is a connection to a Chromium instance, launched by Start or
+// already running.
+type Browser struct {
+	conn      *conn
+	proxy     *policyProxy
+	policy    *netpolicy.Policy
+	width     int
+	height    int
+	userAgent string
+
+	cmd     *exec.Cmd
+	exited  chan struct{}
+	dataDir string
+
+	mu    sync.Mutex
+	pages map[string]*Page // by session ID
+}
+
+// Start launches a headless Chromium, or connects to opts.CDPURL.
+func Start(ctx context.Context, opts Options) (*Browser, error) {
+	policy := opts.Policy
+	if policy == nil {
+		policy = netpolicy.Default()
+	}
+	proxy, err := startProxy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("start proxy: %w", err)
+	}
+	b := &Browser{
+		proxy:  proxy,
+		policy: policy,
+		width:  opts.Width,
+		height: opts.Height,
+		pages:  make(map[string]*Page),
+	}
+	if b.width <= 0 || b.height <= 0 {
+		b.width, b.height = defaultWidth, defaultHeight
+	}
+
+	wsURL := ""
+	if opts.CDPURL != "" {
+		wsURL, err = resolveCDPURL(ctx, opts.CDPURL)
+	} else {
+		wsURL, err = b.launch(ctx, opts.BinaryPath)
+	}
+	if err == nil {
+		b.conn, err = dial(ctx, wsURL)
+	}
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	b.conn.handle("", b.onEvent)
+
+	var version struct {
+		Product   string `json:"product"`
+		UserAgent string `json:"userAgent"`
+	}
+	if err := b.conn.call(ctx, "", "Browser.getVersion", nil, &version); err != nil {
+		b.Close()
+		return nil, err
+	}
+	// Some sites turn away headless browsers by their user agent.
+	b.userAgent = strings.Replace(version.UserAgent, "HeadlessChrome/", "Chrome/", 1)
+	if err := b.conn.call(ctx, "", "Target.setDiscoverTargets", map[string]any{"discover": true}, nil); err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	logger.InfoCF("browser", "Browser started", map[string]any{
+		"product":  version.Product,
+		"launched": b.cmd != nil,
+	})
+	return b, nil
+}
+
+// resolveCDPURL returns the WebSocket URL of the browser at raw.
+func resolveCDPURL(ctx context.Context, raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid CDP URL: %w", err)
+	}
+	switch u.Scheme {
+	case "ws", "wss":
+		return raw, nil
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("invalid CDP URL %q: use ws://, wss://, http:// or https://", raw)
+	}
+
+	versionURL := *u
+	versionURL.Path = strings.TrimSuffix(u.Path, "/") + "/json/version"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, versionURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("query %s: %w", versionURL.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("query %s: %s", versionURL.String(), resp.Status)
+	}
+	var version struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&version); err != nil {
+		return "", fmt.Errorf("query %s: %w", versionURL.String(), err)
+	}
+	wsURL, err := url.Parse(version.WebSocketDebuggerURL)
+	if err != nil || wsURL.Host == "" {
+		return "", fmt.Errorf("query %s: no webSocketDebuggerUrl in response", versionURL.String())
+	}
+	// The browser reports the address it listens on, which is often not the
+	// one it is reached at from here (containers, port forwarding).
+	wsURL.Host = u.Host
+	if u.Scheme == "https" {
+		wsURL.Scheme = "wss"
+	}
+	return wsURL.String(), nil
+}
+
+// chromiumArgs returns the command line for a headless Chromium that sends
+// all of its traffic through the policy proxy at proxyURL.
+func chromiumArgs(dataDir, proxyURL string, width, height int) []string {
+	return []string{
+		"--headless=new",
+		"--remote-debugging-port=0",
+		"--user-data-dir=" + dataDir,
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--disable-gpu",
+		"--disable-extensions",
+		"--disable-background-networking",
+		"--disable-sync",
+		"--disable-dev-shm-usage",
+		"--mute-audio",
+		"--hide-scrollbars",
+		"--proxy-server=" + proxyURL,
+		"--proxy-bypass-list=<-loopback>",
+		// WebRTC sends UDP directly, past the proxy; without this a page
+		// could reach LAN hosts or learn local addresses over STUN.
+		"--force-webrtc-ip-handling-policy=disable_non_proxied_udp",
+		fmt.Sprintf("--window-size=%d,%d", width, height),
+	}
+}
+
+// launch starts a headless Chromium and returns its DevTools URL.
+func (b *Browser) launch(ctx context.Context, binary string) (string, error) {
+	if binary == "" {
+		for _, name := range binaryNames {
+			if path, err := exec.LookPath(name); err == nil {
+				binary = path
+				break
+			}
+		}
+		if binary == "" {
+			return "", errors.New("no Chromium browser found in PATH; install chromium, " +
+				"or set tools.browser.binary_path or tools.browser.cdp_url")
+		}
+	}
+
+	dataDir, err := os.MkdirTemp("", "picoclaw-browser-")
+	if err != nil {
+		return "", err
+	}
+	b.dataDir = dataDir
+
+	args := chromiumArgs(dataDir, b.proxy.URL(), b.width, b.height)
+	// The sandbox cannot be set up for root, which is common in containers.
+	if os.Geteuid() == 0 {
+		args = append(args, "--no-sandbox")
+	}
+	args = append(args, "about:blank")
+
+	// A pipe of our own, unlike cmd.StderrPipe, may still be read after
+	// Wait returns.
+	stderr, stderrWriter, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(binary, args...)
+	cmd.Stderr = stderrWriter
+	err = cmd.Start()
+	stderrWriter.Close()
+	if err != nil {
+		stderr.Close()
+		return "", fmt.Errorf("launch %s: %w", binary, err)
+	}
+	b.cmd = cmd
+	b.exited = make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(b.exited)
+	}()
+
+	type launchResult struct {
+		wsURL  string
+		output []string
+	}
+	found := make(chan launchResult, 1)
+	go func() {
+		defer stderr.Close()
+		scanner := bufio.NewScanner(stderr)
+		var output []string
+		for scanner.Scan() {
+			line := scanner.Text()
+			if wsURL, ok := strings.CutPrefix(line, "DevTools listening on "); ok {
+				found <- launchResult{wsURL: strings.TrimSpace(wsURL)}
+				// Keep reading so the browser never blocks on a full pipe.
+				_, _ = io.Copy(io.Discard, stderr)
+				return
+			}
+			if len(output) < 20 {
+				output = append(output, line)
+			}
+		}
+		found <- launchResult{output: output}
+	}()
+
+	timer := time.NewTimer(launchTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-found:
+		if res.wsURL == "" {
+			return "", fmt.Errorf("%s exited before DevTools was ready: %s",
+				binary, strings.TrimSpace(strings.Join(res.output, "\n")))
+		}
+		return res.wsURL, nil
+	case <-timer.C:
+		return "", fmt.Errorf("%s did not start within %v", binary, launchTimeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// onEvent handles browser-level events.
+func (b *Browser) onEvent(method string, params json.RawMessage) {
+	if method != "Target.detachedFromTarget" {
+		return
+	}
+	var ev struct {
+		SessionID string `json:"sessionId"`
+	}
+	if json.Unmarshal(params, &ev) != nil {
+		return
+	}
+	b.mu.Lock()
+	page := b.pages[ev.SessionID]
+	delete(b.pages, ev.SessionID)
+	b.mu.Unlock()
+	if page != nil {
+		page.detach()
+	}
+}
+
+// NewPage opens a blank page in a new browser context, so that pages do not
+// share cookies or storage.
+func (b *Browser) NewPage(ctx context.Context) (*Page, error) {
+	var created struct {
+		BrowserContextID string `json:"browserContextId"`
+	}
+	err := b.conn.call(ctx, "", "Target.createBrowserContext", map[string]any{
+		"disposeOnDetach": true,
+		"proxyServer":     b.proxy.URL(),
+		"proxyBypassList": "<-loopback>",
+	}, &created)
+	if err != nil {
+		return nil, err
+	}
+	p := &Page{browser: b, contextID: created.BrowserContextID, detached: make(chan struct{})}
+
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	err = b.conn.call(ctx, "", "Target.createTarget", map[string]any{
+		"url":              "about:blank",
+		"browserContextId": p.contextID,
+	}, &target)
+	if err != nil {
+		p.dispose()
+		return nil, err
+	}
+	p.targetID = target.TargetID
+
+	var attached struct {
+		SessionID string `json:"sessionId"`
+	}
+	err = b.conn.call(ctx, "", "Target.attachToTarget", map[string]any{
+		"targetId": p.targetID,
+		"flatten":  true,
+	}, &attached)
+	if err != nil {
+		p.dispose()
+		return nil, err
+	}
+	p.sessionID = attached.SessionID
+	b.conn.handle(p.sessionID, p.onEvent)
+	b.mu.Lock()
+	b.pages[p.sessionID] = p
+	b.mu.Unlock()
+
+	type command struct {
+		method string
+		params any
+	}
+	setup := []command{
+		{"Page.enable", nil},
+		{"Runtime.enable", nil},
+		{"Emulation.setDeviceMetricsOverride", map[string]any{
+			"width":             b.width,
+			"height":            b.height,
+			"deviceScaleFactor": 1,
+			"mobile":            false,
+		}},
+	}
+	if b.userAgent != "" {
+		setup = append(setup, command{"Network.setUserAgentOverride", map[string]any{"userAgent": b.userAgent}})
+	}
+	for _, s := range setup {
+		if err := p.call(ctx, s.method, s.params, nil); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Closed reports whether the connection to the browser has gone away.
+func (b *Browser) Closed() bool {
+	return b.conn == nil || b.conn.closed()
+}
+
+// Launched reports whether Start launched the browser process, as opposed
+// to connecting to a running one.
+func (b *Browser) Launched() bool {
+	return b.cmd != nil
+}
+
+// Close closes the connection and the proxy. A launched browser is shut
+// down and its profile removed.
+func (b *Browser) Close() error {
+	graceful := false
+	if b.conn != nil {
+		if b.cmd != nil && !b.conn.closed() {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			graceful = b.conn.call(ctx, "", "Browser.close", nil, nil) == nil
+			cancel()
+		}
+		b.conn.close()
+	}
+	if b.cmd != nil {
+		if !graceful {
+			_ = b.cmd.Process.Kill()
+		}
+		select {
+		case <-b.exited:
+		case <-time.After(3 * time.Second):
+			_ = b.cmd.Process.Kill()
+			<-b.exited
+		}
+	}
+	if b.dataDir != "" {
+		_ = os.RemoveAll(b.dataDir)
+	}
+	return b.proxy.Close()
+}
@@ -0,0 +1,462 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/netpolicy"
+)
+
+// fakeCDP is a DevTools endpoint that answers the commands the package
+// sends, records them, and lets tests push events.
+type fakeCDP struct {
+	server *httptest.Server
+
+	mu    sync.Mutex
+	calls []cdpRequest
+	ws    *websocket.Conn
+	wsMu  sync.Mutex
+}
+
+func startFakeCDP(t *testing.T) *fakeCDP {
+	t.Helper()
+	f := &fakeCDP{}
+	upgrader := websocket.Upgrader{}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.ws = ws
+		f.mu.Unlock()
+		for {
+			var req struct {
+				cdpRequest
+				Params json.RawMessage `json:"params"`
+			}
+			if err := ws.ReadJSON(&req); err != nil {
+				return
+			}
+			req.cdpRequest.Params = req.Params
+			f.mu.Lock()
+			f.calls = append(f.calls, req.cdpRequest)
+			f.mu.Unlock()
+			result, cdpErr := f.answer(req.Method, req.Params)
+			msg := map[string]any{"id": req.ID, "sessionId": req.SessionID}
+			if cdpErr != nil {
+				msg["error"] = cdpErr
+			} else {
+				msg["result"] = result
+			}
+			f.send(msg)
+		}
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeCDP) url() string {
+	return "ws" + strings.TrimPrefix(f.server.URL, "http") + "/devtools/browser/test"
+}
+
+func (f *fakeCDP) send(msg any) {
+	f.wsMu.Lock()
+	defer f.wsMu.Unlock()
+	_ = f.ws.WriteJSON(msg)
+}
+
+func (f *fakeCDP) answer(method string, params json.RawMessage) (any, *Error) {
+	switch method {
+	case "Browser.getVersion":
+		return map[string]any{
+			"product":   "HeadlessChrome/120.0",
+			"userAgent": "Mozilla/5.0 HeadlessChrome/120.0 Safari/537.36",
+		}, nil
+	case "Target.createBrowserContext":
+		return map[string]any{"browserContextId": "ctx1"}, nil
+	case "Target.createTarget":
+		return map[string]any{"targetId": "target1"}, nil
+	case "Target.attachToTarget":
+		return map[string]any{"sessionId": "session1"}, nil
+	case "Page.navigate":
+		var p struct{ URL string }
+		_ = json.Unmarshal(params, &p)
+		if strings.Contains(p.URL, "unreachable") {
+			return map[string]any{"errorText": "net::ERR_NAME_NOT_RESOLVED"}, nil
+		}
+		return map[string]any{"frameId": "frame1"}, nil
+	case "Page.captureScreenshot":
+		return map[string]any{"data": base64.StdEncoding.EncodeToString([]byte("\x89PNG"))}, nil
+	case "Page.getLayoutMetrics":
+		return map[string]any{"cssContentSize": map[string]any{"width": 1280, "height": 50000}}, nil
+	case "Runtime.evaluate":
+		var p struct{ Expression string }
+		_ = json.Unmarshal(params, &p)
+		return evaluateResult(p.Expression), nil
+	case "Missing.method":
+		return nil, &Error{Code: -32601, Message: "'Missing.method' wasn't found"}
+	}
+	return map[string]any{}, nil
+}
+
+func evaluateResult(expr string) map[string]any {
+	value := func(v any) map[string]any {
+		return map[string]any{"result": map[string]any{"type": "object", "value": v}}
+	}
+	switch {
+	case expr == "document.readyState":
+		return value("complete")
+	case strings.Contains(expr, "window.__picoclawRefs = refs"):
+		return value("# Welcome\n[1 link \"More\" -> /more] [2 textbox \"Search\"]")
+	case strings.Contains(expr, "document.title"):
+		return value(map[string]any{"title": "Example", "url": "https://example.com/"})
+	case strings.Contains(expr, "getBoundingClientRect"):
+		return value(map[string]any{"x": 100, "y": 50, "w": 80, "h": 20})
+	case strings.Contains(expr, "el.focus()"):
+		return value("text")
+	case expr == "throw":
+		return map[string]any{
+			"result": map[string]any{"type": "object"},
+			"exceptionDetails": map[string]any{
+				"text":      "Uncaught",
+				"exception": map[string]any{"description": "Error: boom\n    at <anonymous>:1:7"},
+			},
+		}
+	case expr == "undefined":
+		return map[string]any{"result": map[string]any{"type": "undefined"}}
+	}
+	return value(42)
+}
+
+// called returns the recorded calls of method.
+func (f *fakeCDP) called(method string) []cdpRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []cdpRequest
+	for _, c := range f.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func startTestBrowser(t *testing.T) (*Browser, *fakeCDP) {
+	t.Helper()
+	f := startFakeCDP(t)
+	b, err := Start(context.Background(), Options{CDPURL: f.url()})
+	if err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b, f
+}
+
+func TestPage_Actions(t *testing.T) {
+	b, f := startTestBrowser(t)
+	ctx := context.Background()
+	if b.Launched() {
+		t.Error("Launched() = true for a CDP URL")
+	}
+
+	page, err := b.NewPage(ctx)
+	if err != nil {
+		t.Fatalf("NewPage() error: %v", err)
+	}
+	if calls := f.called("Target.createBrowserContext"); len(calls) != 1 ||
+		!strings.Contains(string(calls[0].Params.(json.RawMessage)), b.proxy.URL()) {
+		t.Errorf("browser context not set up with the proxy: %+v", calls)
+	}
+	ua := f.called("Network.setUserAgentOverride")
+	if len(ua) != 1 || ua[0].SessionID != "session1" ||
+		strings.Contains(string(ua[0].Params.(json.RawMessage)), "Headless") {
+		t.Errorf("user agent override = %+v", ua)
+	}
+
+	if err := page.Navigate(ctx, "https://example.com/"); err != nil {
+		t.Fatalf("Navigate() error: %v", err)
+	}
+	text, err := page.Snapshot(ctx)
+	if err != nil || !strings.Contains(text, `[2 textbox "Search"]`) {
+		t.Errorf("Snapshot() = %q, %v", text, err)
+	}
+	title, pageURL, err := page.Info(ctx)
+	if err != nil || title != "Example" || pageURL != "https://example.com/" {
+		t.Errorf("Info() = %q, %q, %v", title, pageURL, err)
+	}
+
+	if err := page.Click(ctx, 1); err != nil {
+		t.Fatalf("Click() error: %v", err)
+	}
+	if mouse := f.called("Input.dispatchMouseEvent"); len(mouse) != 3 ||
+		!strings.Contains(string(mouse[1].Params.(json.RawMessage)), `"x":100`) {
+		t.Errorf("mouse events = %+v", mouse)
+	}
+
+	if err := page.Type(ctx, 2, "picoclaw", true, true); err != nil {
+		t.Fatalf("Type() error: %v", err)
+	}
+	if ins := f.called("Input.insertText"); len(ins) != 1 ||
+		string(ins[0].Params.(json.RawMessage)) != `{"text":"picoclaw"}` {
+		t.Errorf("insertText = %+v", ins)
+	}
+	if keys := f.called("Input.dispatchKeyEvent"); len(keys) != 2 {
+		t.Errorf("key events = %+v", keys)
+	}
+
+	png, err := page.Screenshot(ctx, true)
+	if err != nil || string(png) != "\x89PNG" {
+		t.Errorf("Screenshot() = %q, %v", png, err)
+	}
+	shot := f.called("Page.captureScreenshot")
+	if len(shot) != 1 || !strings.Contains(string(shot[0].Params.(json.RawMessage)), `"height":10000`) {
+		t.Errorf("full page screenshot not capped: %+v", shot)
+	}
+
+	if value, err := page.Evaluate(ctx, "6 * 7"); err != nil || string(value) != "42" {
+		t.Errorf("Evaluate() = %s, %v", value, err)
+	}
+	if value, err := page.Evaluate(ctx, "undefined"); err != nil || string(value) != "null" {
+		t.Errorf("Evaluate(undefined) = %s, %v", value, err)
+	}
+	if _, err := page.Evaluate(ctx, "throw"); err == nil || err.Error() != "javascript error: Error: boom" {
+		t.Errorf("Evaluate(throw) error = %v", err)
+	}
+
+	if err := page.Close(); err != nil {
+		t.Errorf("Close() error: %v", err)
+	}
+	if len(f.called("Target.disposeBrowserContext")) != 1 {
+		t.Error("browser context not disposed")
+	}
+	if err := page.Navigate(ctx, "https://example.com/"); !errors.Is(err, ErrPageClosed) {
+		t.Errorf("Navigate() after Close = %v, want ErrPageClosed", err)
+	}
+}
+
+func TestPage_NavigateErrors(t *testing.T) {
+	b, f := startTestBrowser(t)
+	ctx := context.Background()
+	page, err := b.NewPage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{"file:///etc/passwd", "http://127.0.0.1:8080/", "http://169.254.169.254/latest/"} {
+		var blocked *netpolicy.BlockedError
+		err := page.Navigate(ctx, u)
+		if err == nil || (!errors.As(err, &blocked) && !strings.Contains(err.Error(), "network policy")) {
+			t.Errorf("Navigate(%q) = %v, want a policy error", u, err)
+		}
+	}
+	if n := len(f.called("Page.navigate")); n != 0 {
+		t.Errorf("blocked URLs reached the browser %d times", n)
+	}
+
+	err = page.Navigate(ctx, "https://unreachable.example/")
+	if err == nil || !strings.Contains(err.Error(), "ERR_NAME_NOT_RESOLVED") {
+		t.Errorf("Navigate() = %v", err)
+	}
+}
+
+func TestPage_DialogsAndDetach(t *testing.T) {
+	b, f := startTestBrowser(t)
+	ctx := context.Background()
+	page, err := b.NewPage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.send(map[string]any{
+		"sessionId": "session1",
+		"method":    "Page.javascriptDialogOpening",
+		"params":    map[string]any{"type": "alert", "message": "Saved!"},
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for len(f.called("Page.handleJavaScriptDialog")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if calls := f.called("Page.handleJavaScriptDialog"); len(calls) != 1 ||
+		!strings.Contains(string(calls[0].Params.(json.RawMessage)), `"accept":true`) {
+		t.Errorf("dialog not accepted: %+v", calls)
+	}
+	if dialogs := page.Dialogs(); len(dialogs) != 1 || dialogs[0] != "alert: Saved!" {
+		t.Errorf("Dialogs() = %v", dialogs)
+	}
+	if dialogs := page.Dialogs(); len(dialogs) != 0 {
+		t.Errorf("Dialogs() not cleared: %v", dialogs)
+	}
+
+	// The tab crashed or was closed by someone else.
+	f.send(map[string]any{
+		"method": "Target.detachedFromTarget",
+		"params": map[string]any{"sessionId": "session1"},
+	})
+	deadline = time.Now().Add(5 * time.Second)
+	for !page.Closed() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !page.Closed() {
+		t.Fatal("page not closed after detach")
+	}
+
+	b.Close()
+	if !b.Closed() {
+		t.Error("Closed() = false after Close")
+	}
+	if _, err := b.NewPage(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("NewPage() after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestConn_Errors(t *testing.T) {
+	f := startFakeCDP(t)
+	c, err := dial(context.Background(), f.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+
+	var cdpErr *Error
+	if err := c.call(context.Background(), "", "Missing.method", nil, nil); !errors.As(err, &cdpErr) ||
+		cdpErr.Method != "Missing.method" || cdpErr.Code != -32601 {
+		t.Errorf("call() error = %v", err)
+	}
+
+	f.mu.Lock()
+	ws := f.ws
+	f.mu.Unlock()
+	ws.Close()
+	<-c.done
+	if err := c.call(context.Background(), "", "Browser.getVersion", nil, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("call() on a dropped connection = %v, want ErrClosed", err)
+	}
+}
+
+func TestResolveCDPURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json/version" {
+			http.NotFound(w, r)
+			return
+		}
+		// Browsers in containers report the address they listen on.
+		_, _ = w.Write([]byte(`{"webSocketDebuggerUrl": "ws://0.0.0.0:9222/devtools/browser/abc"}`))
+	}))
+	defer server.Close()
+
+	got, err := resolveCDPURL(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	if want := "ws://" + u.Host + "/devtools/browser/abc"; got != want {
+		t.Errorf("resolveCDPURL() = %q, want %q", got, want)
+	}
+
+	if got, err := resolveCDPURL(context.Background(), "ws://host:9222/devtools/browser/x"); err != nil ||
+		got != "ws://host:9222/devtools/browser/x" {
+		t.Errorf("resolveCDPURL(ws) = %q, %v", got, err)
+	}
+	if _, err := resolveCDPURL(context.Background(), "ftp://host"); err == nil {
+		t.Error("resolveCDPURL(ftp) succeeded")
+	}
+}
+
+func TestChromiumArgs(t *testing.T) {
+	args := chromiumArgs("/tmp/profile", "http://127.0.0.1:8080", 1280, 800)
+	for _, want := range []string{
+		"--proxy-server=http://127.0.0.1:8080",
+		"--proxy-bypass-list=<-loopback>",
+		"--force-webrtc-ip-handling-policy=disable_non_proxied_udp",
+		"--window-size=1280,800",
+	} {
+		if !slices.Contains(args, want) {
+			t.Errorf("args %v lack %s", args, want)
+		}
+	}
+}
+
+func TestPolicyProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello from " + r.URL.Path))
+	}))
+	defer target.Close()
+	tlsTarget := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secure"))
+	}))
+	defer tlsTarget.Close()
+
+	clientFor := func(proxy *policyProxy, base *http.Client) *http.Client {
+		proxyURL, _ := url.Parse(proxy.URL())
+		transport := base.Transport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+		return &http.Client{Transport: transport, Timeout: 5 * time.Second}
+	}
+
+	// The default policy blocks loopback, where the targets listen.
+	proxy, err := startProxy(netpolicy.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	client := clientFor(proxy, tlsTarget.Client())
+
+	resp, err := client.Get(target.URL + "/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("plain HTTP to loopback: status %d, want 403", resp.StatusCode)
+	}
+	if _, err := client.Get(tlsTarget.URL); err == nil {
+		t.Error("CONNECT to loopback succeeded")
+	}
+	u, _ := url.Parse(tlsTarget.URL)
+	var blocked *netpolicy.BlockedError
+	if err := proxy.blocked(u.Host); !errors.As(err, &blocked) {
+		t.Errorf("blocked(%q) = %v", u.Host, err)
+	}
+
+	// With private addresses allowed, both pass through.
+	policy, err := netpolicy.New(config.NetworkConfig{AllowPrivate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err := startProxy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer open.Close()
+	client = clientFor(open, tlsTarget.Client())
+
+	for _, tt := range []struct{ url, want string }{
+		{target.URL + "/page", "hello from /page"},
+		{tlsTarget.URL, "secure"},
+	} {
+		resp, err := client.Get(tt.url)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.url, err)
+		}
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body[:n]) != tt.want {
+			t.Errorf("GET %s = %d %q", tt.url, resp.StatusCode, body[:n])
+		}
+	}
+}
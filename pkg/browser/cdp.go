@@ -0,0 +1,199 @@
+// Package browser drives a Chromium browser over the Chrome DevTools
+// Protocol (CDP). It launches a local headless browser or connects to a
+// configured one, opens pages in isolated browser contexts and routes their
+// traffic through a proxy that enforces the network policy.
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Error is an error returned by the browser for a CDP command.
+type Error struct {
+	Method  string
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s (%d)", e.Method, e.Message, e.Code)
+}
+
+// ErrClosed is returned for commands on a closed connection.
+var ErrClosed = errors.New("browser connection closed")
+
+type cdpRequest struct {
+	ID        int64  `json:"id"`
+	SessionID string `json:"sessionId,omitempty"`
+	Method    string `json:"method"`
+	Params    any    `json:"params"`
+}
+
+// cdpMessage is a command response (ID set) or an event (Method set).
+type cdpMessage struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *Error          `json:"error,omitempty"`
+}
+
+// EventHandler receives the events of one CDP session. It runs on the read
+// loop, so it must not block or wait for commands.
+type EventHandler func(method string, params json.RawMessage)
+
+// conn is a CDP connection to the browser endpoint. Page sessions share it
+// through flattened session IDs.
+type conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	nextID   int64
+	pending  map[int64]chan cdpMessage
+	handlers map[string]EventHandler // by session ID
+	err      error
+	done     chan struct{}
+}
+
+func dial(ctx context.Context, wsURL string) (*conn, error) {
+	dialer := websocket.Dialer{ReadBufferSize: 64 * 1024, WriteBufferSize: 64 * 1024}
+	ws, _, err := dialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", wsURL, err)
+	}
+	// Screenshots of long pages are large.
+	ws.SetReadLimit(64 << 20)
+	c := &conn{
+		ws:       ws,
+		pending:  make(map[int64]chan cdpMessage),
+		handlers: make(map[string]EventHandler),
+		done:     make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// call sends method with params to the session (the browser itself when
+// sessionID is empty) and decodes the result into result, if not nil.
+func (c *conn) call(ctx context.Context, sessionID, method string, params, result any) error {
+	ch := make(chan cdpMessage, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if params == nil {
+		params = struct{}{}
+	}
+	c.writeMu.Lock()
+	err := c.ws.WriteJSON(cdpRequest{ID: id, SessionID: sessionID, Method: method, Params: params})
+	c.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			msg.Error.Method = method
+			return msg.Error
+		}
+		if result != nil && len(msg.Result) > 0 {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				return fmt.Errorf("%s: decode result: %w", method, err)
+			}
+		}
+		return nil
+	case <-c.done:
+		return c.closedErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handle registers fn for the events of sessionID; nil removes it.
+func (c *conn) handle(sessionID string, fn EventHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fn == nil {
+		delete(c.handlers, sessionID)
+	} else {
+		c.handlers[sessionID] = fn
+	}
+}
+
+func (c *conn) readLoop() {
+	for {
+		var msg cdpMessage
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			c.shutdown(err)
+			return
+		}
+		c.mu.Lock()
+		if msg.ID != 0 {
+			if ch, ok := c.pending[msg.ID]; ok {
+				ch <- msg
+			}
+			c.mu.Unlock()
+			continue
+		}
+		fn := c.handlers[msg.SessionID]
+		c.mu.Unlock()
+		if fn != nil && msg.Method != "" {
+			fn(msg.Method, msg.Params)
+		}
+	}
+}
+
+func (c *conn) shutdown(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = fmt.Errorf("%w: %v", ErrClosed, err)
+	close(c.done)
+}
+
+func (c *conn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// closed reports whether the connection has gone away.
+func (c *conn) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *conn) close() {
+	c.writeMu.Lock()
+	_ = c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.writeMu.Unlock()
+	c.ws.Close()
+	c.shutdown(errors.New("closed"))
+}
@@ -0,0 +1,391 @@
+package browser
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// loadTimeout bounds how long actions wait for a page to finish loading.
+	// Slow pages are used as far as they got.
+	loadTimeout = 30 * time.Second
+
+	// maxScreenshotHeight caps full page screenshots of endless pages.
+	maxScreenshotHeight = 10000
+)
+
+// ErrPageClosed is returned for actions on a closed page.
+var ErrPageClosed = errors.New("page closed")
+
+// Page is a browser tab in a browser context of its own.
+type Page struct {
+	browser   *Browser
+	contextID string
+	targetID  string
+	sessionID string
+
+	detached   chan struct{}
+	detachOnce sync.Once
+
+	mu      sync.Mutex
+	dialogs []string
+}
+
+// onEvent handles the events of the page session.
+func (p *Page) onEvent(method string, params json.RawMessage) {
+	switch method {
+	case "Page.javascriptDialogOpening":
+		var ev struct {
+			Type          string `json:"type"`
+			Message       string `json:"message"`
+			DefaultPrompt string `json:"defaultPrompt"`
+		}
+		if json.Unmarshal(params, &ev) != nil {
+			return
+		}
+		p.mu.Lock()
+		p.dialogs = append(p.dialogs, ev.Type+": "+ev.Message)
+		p.mu.Unlock()
+		// Dialogs block the page until they are answered, and events must not
+		// wait for commands.
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = p.call(ctx, "Page.handleJavaScriptDialog", map[string]any{
+				"accept":     true,
+				"promptText": ev.DefaultPrompt,
+			}, nil)
+		}()
+	case "Inspector.detached":
+		p.detach()
+	}
+}
+
+func (p *Page) detach() {
+	p.detachOnce.Do(func() { close(p.detached) })
+}
+
+func (p *Page) call(ctx context.Context, method string, params, result any) error {
+	if p.Closed() {
+		return ErrPageClosed
+	}
+	return p.browser.conn.call(ctx, p.sessionID, method, params, result)
+}
+
+// Closed reports whether the page or its browser has gone away.
+func (p *Page) Closed() bool {
+	select {
+	case <-p.detached:
+		return true
+	default:
+		return p.browser.Closed()
+	}
+}
+
+// Navigate loads rawURL and waits for it to finish loading.
+func (p *Page) Navigate(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := p.browser.policy.CheckURL(u); err != nil {
+		return err
+	}
+	var res struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := p.call(ctx, "Page.navigate", map[string]any{"url": u.String()}, &res); err != nil {
+		return err
+	}
+	if res.ErrorText != "" {
+		if err := p.browser.proxy.blocked(u.Host); err != nil {
+			return fmt.Errorf("navigate to %s: %w", u.String(), err)
+		}
+		if strings.Contains(res.ErrorText, "TUNNEL_CONNECTION_FAILED") {
+			return fmt.Errorf("navigate to %s: %s (the site is unreachable or blocked by the network policy)",
+				u.String(), res.ErrorText)
+		}
+		return fmt.Errorf("navigate to %s: %s", u.String(), res.ErrorText)
+	}
+	return p.waitLoad(ctx)
+}
+
+// waitLoad waits until the document has loaded, or loadTimeout passed.
+func (p *Page) waitLoad(ctx context.Context) error {
+	deadline := time.Now().Add(loadTimeout)
+	for time.Now().Before(deadline) {
+		var state string
+		// Evaluation fails while the old document is torn down.
+		if err := p.evaluate(ctx, "document.readyState", &state); err == nil && state == "complete" {
+			return nil
+		} else if errors.Is(err, ErrPageClosed) || errors.Is(err, ErrClosed) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// settle gives an action time to start a navigation, and waits for it.
+func (p *Page) settle(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(300 * time.Millisecond):
+	}
+	return p.waitLoad(ctx)
+}
+
+type remoteObject struct {
+	Type                string          `json:"type"`
+	Value               json.RawMessage `json:"value"`
+	UnserializableValue string          `json:"unserializableValue"`
+	Description         string          `json:"description"`
+}
+
+// Evaluate runs a JavaScript expression in the page, awaiting promises, and
+// returns its value as JSON. Values that cannot be serialized are returned
+// as their description, undefined as null.
+func (p *Page) Evaluate(ctx context.Context, expression string) (json.RawMessage, error) {
+	var res struct {
+		Result           remoteObject `json:"result"`
+		ExceptionDetails *struct {
+			Text      string        `json:"text"`
+			Exception *remoteObject `json:"exception"`
+		} `json:"exceptionDetails"`
+	}
+	err := p.call(ctx, "Runtime.evaluate", map[string]any{
+		"expression":    expression,
+		"returnByValue": true,
+		"awaitPromise":  true,
+		"userGesture":   true,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	if d := res.ExceptionDetails; d != nil {
+		msg := d.Text
+		if d.Exception != nil && d.Exception.Description != "" {
+			// The description is the error with its stack trace.
+			msg, _, _ = strings.Cut(d.Exception.Description, "\n    at ")
+		}
+		return nil, fmt.Errorf("javascript error: %s", msg)
+	}
+	switch {
+	case len(res.Result.Value) > 0:
+		return res.Result.Value, nil
+	case res.Result.UnserializableValue != "":
+		return json.Marshal(res.Result.UnserializableValue)
+	case res.Result.Type != "undefined" && res.Result.Description != "":
+		return json.Marshal(res.Result.Description)
+	}
+	return json.RawMessage("null"), nil
+}
+
+// evaluate runs expression and decodes its value into result.
+func (p *Page) evaluate(ctx context.Context, expression string, result any) error {
+	value, err := p.Evaluate(ctx, expression)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, result)
+}
+
+// Info returns the title and URL of the page.
+func (p *Page) Info(ctx context.Context) (title, pageURL string, err error) {
+	var info struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	}
+	if err := p.evaluate(ctx, "({title: document.title, url: location.href})", &info); err != nil {
+		return "", "", err
+	}
+	return info.Title, info.URL, nil
+}
+
+// Snapshot returns the page as text in which interactive elements are
+// marked with the refs Click and Type take.
+func (p *Page) Snapshot(ctx context.Context) (string, error) {
+	var text string
+	err := p.evaluate(ctx, snapshotJS, &text)
+	return text, err
+}
+
+// Click clicks the element with ref from the last snapshot like a user
+// would, and waits for a navigation it starts.
+func (p *Page) Click(ctx context.Context, ref int) error {
+	var box struct {
+		X, Y, W, H float64
+	}
+	err := p.evaluate(ctx, fmt.Sprintf(`(() => {
+  const el = (%s)(%d);
+  el.scrollIntoView({block: 'center', inline: 'center'});
+  const r = el.getBoundingClientRect();
+  return {x: r.left + r.width / 2, y: r.top + r.height / 2, w: r.width, h: r.height};
+})()`, elementJS, ref), &box)
+	if err != nil {
+		return err
+	}
+
+	if box.W == 0 || box.H == 0 {
+		// Nothing to point at, e.g. a visually hidden checkbox.
+		if _, err := p.Evaluate(ctx, fmt.Sprintf("(%s)(%d).click()", elementJS, ref)); err != nil {
+			return err
+		}
+		return p.settle(ctx)
+	}
+	for _, typ := range []string{"mouseMoved", "mousePressed", "mouseReleased"} {
+		err := p.call(ctx, "Input.dispatchMouseEvent", map[string]any{
+			"type":       typ,
+			"x":          box.X,
+			"y":          box.Y,
+			"button":     "left",
+			"clickCount": 1,
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return p.settle(ctx)
+}
+
+// Type enters text into the element with ref from the last snapshot,
+// replacing its content when replace is set, and presses Enter afterwards
+// when submit is set. For select elements, the option with text as its
+// label or value is chosen.
+func (p *Page) Type(ctx context.Context, ref int, text string, replace, submit bool) error {
+	textJSON, _ := json.Marshal(text)
+	var kind string
+	err := p.evaluate(ctx, fmt.Sprintf(`(() => {
+  const el = (%s)(%d);
+  const text = %s;
+  el.scrollIntoView({block: 'center'});
+  el.focus();
+  if (el.tagName === 'SELECT') {
+    const want = text.trim().toLowerCase();
+    const opt = Array.from(el.options).find(o => o.text.trim().toLowerCase() === want || o.value === text);
+    if (!opt) throw new Error('no option ' + JSON.stringify(text));
+    el.value = opt.value;
+    el.dispatchEvent(new Event('input', {bubbles: true}));
+    el.dispatchEvent(new Event('change', {bubbles: true}));
+    return 'select';
+  }
+  if (%t) {
+    if ('value' in el) {
+      // Through the prototype setter, so that frameworks notice the change.
+      const setter = Object.getOwnPropertyDescriptor(Object.getPrototypeOf(el), 'value');
+      if (setter && setter.set) setter.set.call(el, ''); else el.value = '';
+      el.dispatchEvent(new Event('input', {bubbles: true}));
+    } else {
+      el.textContent = '';
+    }
+  }
+  return 'text';
+})()`, elementJS, ref, textJSON, replace), &kind)
+	if err != nil {
+		return err
+	}
+
+	if kind != "select" && text != "" {
+		if err := p.call(ctx, "Input.insertText", map[string]any{"text": text}, nil); err != nil {
+			return err
+		}
+	}
+	if submit {
+		for _, typ := range []string{"keyDown", "keyUp"} {
+			params := map[string]any{
+				"type":                  typ,
+				"key":                   "Enter",
+				"code":                  "Enter",
+				"windowsVirtualKeyCode": 13,
+				"nativeVirtualKeyCode":  13,
+			}
+			if typ == "keyDown" {
+				params["text"] = "\r"
+			}
+			if err := p.call(ctx, "Input.dispatchKeyEvent", params, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return p.settle(ctx)
+}
+
+// Screenshot returns a PNG of the viewport, or of the whole page up to
+// maxScreenshotHeight pixels when fullPage is set.
+func (p *Page) Screenshot(ctx context.Context, fullPage bool) ([]byte, error) {
+	params := map[string]any{"format": "png"}
+	if fullPage {
+		var metrics struct {
+			CSSContentSize struct {
+				Width  float64 `json:"width"`
+				Height float64 `json:"height"`
+			} `json:"cssContentSize"`
+		}
+		if err := p.call(ctx, "Page.getLayoutMetrics", nil, &metrics); err != nil {
+			return nil, err
+		}
+		size := metrics.CSSContentSize
+		if size.Width > 0 && size.Height > 0 {
+			params["captureBeyondViewport"] = true
+			params["clip"] = map[string]any{
+				"x":      0,
+				"y":      0,
+				"width":  size.Width,
+				"height": min(size.Height, maxScreenshotHeight),
+				"scale":  1,
+			}
+		}
+	}
+	var res struct {
+		Data string `json:"data"`
+	}
+	if err := p.call(ctx, "Page.captureScreenshot", params, &res); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(res.Data)
+}
+
+// Dialogs returns the messages of the dialogs the page opened since the
+// last call. Dialogs are accepted automatically.
+func (p *Page) Dialogs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dialogs := p.dialogs
+	p.dialogs = nil
+	return dialogs
+}
+
+// Close closes the page and disposes its browser context.
+func (p *Page) Close() error {
+	b := p.browser
+	if p.sessionID != "" {
+		b.conn.handle(p.sessionID, nil)
+		b.mu.Lock()
+		delete(b.pages, p.sessionID)
+		b.mu.Unlock()
+	}
+	p.detach()
+	return p.dispose()
+}
+
+func (p *Page) dispose() error {
+	if p.browser.Closed() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return p.browser.conn.call(ctx, "", "Target.disposeBrowserContext",
+		map[string]any{"browserContextId": p.contextID}, nil)
+}
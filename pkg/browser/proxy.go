@@ -0,0 +1,221 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/netpolicy"
+)
+
+// hopHeaders are removed when forwarding plain HTTP requests.
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// policyProxy is an HTTP proxy on the loopback interface that only connects
+// to destinations the network policy allows. Pages are pointed at it, so
+// every request they make, including redirects and subresources, is
+// checked against the address that is actually dialed.
+type policyProxy struct {
+	dial      func(ctx context.Context, network, address string) (net.Conn, error)
+	transport *http.Transport
+	server    *http.Server
+	listener  net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{} // tunnels, closed on shutdown
+	blocks map[string]error      // recent policy blocks by host name
+}
+
+// maxBlocks bounds the blocks remembered for error messages.
+const maxBlocks = 64
+
+func startProxy(policy *netpolicy.Policy) (*policyProxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	client := &http.Client{Transport: transport}
+	policy.Apply(client)
+
+	p := &policyProxy{
+		dial:      policy.Dialer(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}),
+		transport: transport,
+		listener:  ln,
+		conns:     make(map[net.Conn]struct{}),
+		blocks:    make(map[string]error),
+	}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 30 * time.Second}
+	go p.server.Serve(ln)
+	return p, nil
+}
+
+// URL is the proxy address to give the browser.
+func (p *policyProxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+func (p *policyProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a proxy", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Upgrade") != "" {
+		http.Error(w, "upgrade over plain HTTP is not supported", http.StatusNotImplemented)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		p.fail(w, r.URL.Host, err)
+		return
+	}
+	defer resp.Body.Close()
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// tunnel handles CONNECT, used for HTTPS and secure WebSockets.
+func (p *policyProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	target, err := p.dial(r.Context(), "tcp", r.Host)
+	if err != nil {
+		p.fail(w, r.Host, err)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		target.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hj.Hijack()
+	if err != nil {
+		target.Close()
+		return
+	}
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		client.Close()
+		target.Close()
+		return
+	}
+
+	p.track(client, target)
+	go func() {
+		defer p.untrack(client, target)
+		done := make(chan struct{}, 2)
+		go func() {
+			// The client may have sent data along with the CONNECT request.
+			if n := buf.Reader.Buffered(); n > 0 {
+				b, _ := buf.Reader.Peek(n)
+				_, _ = target.Write(b)
+			}
+			_, _ = io.Copy(target, client)
+			closeWrite(target)
+			done <- struct{}{}
+		}()
+		go func() {
+			_, _ = io.Copy(client, target)
+			closeWrite(client)
+			done <- struct{}{}
+		}()
+		<-done
+		<-done
+	}()
+}
+
+func (p *policyProxy) fail(w http.ResponseWriter, host string, err error) {
+	var blocked *netpolicy.BlockedError
+	if errors.As(err, &blocked) {
+		logger.WarnCF("browser", "Blocked by network policy", map[string]any{
+			"host":  host,
+			"error": err.Error(),
+		})
+		p.mu.Lock()
+		if len(p.blocks) >= maxBlocks {
+			clear(p.blocks)
+		}
+		p.blocks[hostname(host)] = err
+		p.mu.Unlock()
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+// blocked returns the error the policy last blocked host with, if any.
+// Browsers only report a failed tunnel, not why it failed.
+func (p *policyProxy) blocked(host string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.blocks[hostname(host)]
+}
+
+func hostname(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(strings.Trim(hostport, "[]"))
+}
+
+func (p *policyProxy) track(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		p.conns[c] = struct{}{}
+	}
+}
+
+func (p *policyProxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+		delete(p.conns, c)
+	}
+}
+
+func (p *policyProxy) Close() error {
+	err := p.server.Close()
+	p.mu.Lock()
+	for c := range p.conns {
+		c.Close()
+	}
+	p.mu.Unlock()
+	p.transport.CloseIdleConnections()
+	return err
+}
+
+func closeWrite(c net.Conn) {
+	if tc, ok := c.(interface{ CloseWrite() error }); ok {
+		_ = tc.CloseWrite()
+		return
+	}
+	c.Close()
+}
@@ -0,0 +1,161 @@
+package browser
+
+// snapshotJS renders the visible page as text. Block elements start new
+// lines, headings and list items get Markdown markers, and interactive
+// elements are shown inline as [ref role "name" ...]. The elements are
+// kept in window.__picoclawRefs so that later actions can find them by ref,
+// including inside open shadow roots.
+const snapshotJS = `(() => {
+  const refs = [null];
+  window.__picoclawRefs = refs;
+  const skip = new Set(['SCRIPT', 'STYLE', 'NOSCRIPT', 'TEMPLATE', 'HEAD', 'META', 'LINK', 'SVG', 'CANVAS',
+    'IFRAME', 'OBJECT', 'EMBED']);
+  const leaf = new Set(['A', 'BUTTON', 'INPUT', 'SELECT', 'TEXTAREA', 'SUMMARY']);
+  const roles = new Set(['button', 'link', 'checkbox', 'radio', 'tab', 'menuitem', 'menuitemcheckbox',
+    'menuitemradio', 'option', 'switch', 'textbox', 'searchbox', 'combobox', 'slider', 'treeitem']);
+  const clean = s => (s || '').replace(/\s+/g, ' ').trim();
+  const cut = (s, n) => s.length > n ? s.slice(0, n - 3) + '...' : s;
+  const lines = [];
+  let buf = '', prefix = '', visited = 0, truncated = false;
+
+  const flush = () => {
+    const t = clean(buf);
+    buf = '';
+    if (t) {
+      lines.push(prefix + t);
+      prefix = '';
+    }
+  };
+  const interactive = el => {
+    const tag = el.tagName;
+    if (tag === 'A') return el.hasAttribute('href');
+    if (leaf.has(tag)) return el.type !== 'hidden';
+    const role = el.getAttribute('role');
+    if (role && roles.has(role)) return true;
+    if (el.isContentEditable && !(el.parentElement && el.parentElement.isContentEditable)) return true;
+    return el.hasAttribute('onclick') || (el.hasAttribute('tabindex') && el.tabIndex >= 0);
+  };
+  const roleOf = el => {
+    const role = el.getAttribute('role');
+    if (role) return role;
+    switch (el.tagName) {
+      case 'A': return 'link';
+      case 'BUTTON': case 'SUMMARY': return 'button';
+      case 'SELECT': return 'combobox';
+      case 'TEXTAREA': return 'textbox';
+      case 'INPUT': {
+        const type = (el.type || 'text').toLowerCase();
+        if (type === 'checkbox' || type === 'radio') return type;
+        if (['button', 'submit', 'reset', 'image'].includes(type)) return 'button';
+        if (type === 'range') return 'slider';
+        if (type === 'search') return 'searchbox';
+        return type === 'password' ? 'password' : 'textbox';
+      }
+    }
+    return el.isContentEditable ? 'textbox' : 'clickable';
+  };
+  const nameOf = el => {
+    let n = el.getAttribute('aria-label');
+    const labelledBy = el.getAttribute('aria-labelledby');
+    if (!n && labelledBy) {
+      n = labelledBy.split(/\s+/).map(id => {
+        const l = document.getElementById(id);
+        return l ? l.innerText : '';
+      }).join(' ');
+    }
+    if (!n && el.labels && el.labels.length) n = el.labels[0].innerText;
+    if (!n && el.tagName === 'INPUT' && ['button', 'submit', 'reset'].includes(el.type)) n = el.value;
+    if (!n && !['INPUT', 'TEXTAREA', 'SELECT'].includes(el.tagName)) n = el.innerText;
+    if (!n) {
+      const img = el.querySelector('img[alt]');
+      if (img) n = img.alt;
+    }
+    n = n || el.getAttribute('title') || el.getAttribute('placeholder') || el.getAttribute('name') || '';
+    return cut(clean(n), 80);
+  };
+  const describe = (el, ref) => {
+    const role = roleOf(el);
+    let s = '[' + ref + ' ' + role;
+    const name = nameOf(el);
+    if (name) s += ' ' + JSON.stringify(name);
+    if (el.tagName === 'A') {
+      const href = el.getAttribute('href');
+      if (href && !href.startsWith('javascript:')) s += ' -> ' + cut(href, 100);
+    }
+    if (role === 'checkbox' || role === 'radio' || role === 'switch') {
+      s += (el.checked || el.getAttribute('aria-checked') === 'true') ? ' checked' : ' unchecked';
+    } else if (el.tagName === 'SELECT') {
+      const selected = el.selectedOptions[0];
+      if (selected) s += ' selected=' + JSON.stringify(clean(selected.text));
+      s += ' options=' + JSON.stringify(Array.from(el.options).slice(0, 20).map(o => clean(o.text)));
+    } else if ((el.tagName === 'INPUT' || el.tagName === 'TEXTAREA') && role !== 'button' && role !== 'password' &&
+      el.value) {
+      s += ' value=' + JSON.stringify(cut(el.value, 100));
+    }
+    if (el.disabled) s += ' disabled';
+    return s + ']';
+  };
+
+  const walkChildren = parent => {
+    for (let n = parent.firstChild; n; n = n.nextSibling) walk(n);
+  };
+  const walk = node => {
+    if (truncated) return;
+    if (++visited > 20000) {
+      truncated = true;
+      return;
+    }
+    if (node.nodeType === Node.TEXT_NODE) {
+      buf += node.textContent;
+      return;
+    }
+    if (node.nodeType !== Node.ELEMENT_NODE) return;
+    const el = node;
+    const tag = el.tagName.toUpperCase();
+    if (skip.has(tag) || el.getAttribute('aria-hidden') === 'true') return;
+    if (tag === 'SLOT') {
+      el.assignedNodes({flatten: true}).forEach(walk);
+      return;
+    }
+    if (tag === 'BR') {
+      flush();
+      return;
+    }
+    const style = getComputedStyle(el);
+    if (style.display === 'none' || style.visibility === 'hidden') return;
+    const cell = style.display === 'table-cell';
+    const block = !cell && style.display !== 'contents' && !style.display.startsWith('inline');
+    if (block) flush();
+    if (/^H[1-6]$/.test(tag)) prefix = '#'.repeat(+tag[1]) + ' ';
+    else if (tag === 'LI') prefix = '- ';
+    if (tag === 'IMG') {
+      const alt = clean(el.alt);
+      if (alt) buf += ' [image ' + JSON.stringify(cut(alt, 80)) + '] ';
+      return;
+    }
+    if (interactive(el)) {
+      refs.push(el);
+      buf += ' ' + describe(el, refs.length - 1) + ' ';
+      if (leaf.has(tag)) {
+        if (block) flush();
+        return;
+      }
+    }
+    walkChildren(el.shadowRoot || el);
+    if (cell) buf += ' | ';
+    if (block) flush();
+  };
+
+  if (document.body) walkChildren(document.body);
+  flush();
+  if (truncated) lines.push('[snapshot truncated: page too large]');
+  return lines.join('\n');
+})()`
+
+// elementJS evaluates to a function that finds the element of a ref from
+// the last snapshot.
+const elementJS = `(ref) => {
+  const el = (window.__picoclawRefs || [])[ref];
+  if (!el || !el.isConnected) throw new Error('element [' + ref + '] not found, take a new snapshot');
+  return el;
+}`
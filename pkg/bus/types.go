@@ -30,9 +30,18 @@ type InboundMessage struct {
 }
 
 type OutboundMessage struct {
-	Channel string `json:"channel"`
-	ChatID  string `json:"chat_id"`
-	Content string `json:"content"`
+	Channel string     `json:"channel"`
+	ChatID  string     `json:"chat_id"`
+	Content string     `json:"content"`
+	Buttons [][]Button `json:"buttons,omitempty"` // rows of inline buttons, rendered where supported
+}
+
+// Button is an inline reply button. Pressing it delivers Data back to the
+// agent as an inbound message from the user who pressed it, so channels
+// without buttons can show Data as a command to type instead.
+type Button struct {
+	Label string `json:"label"`
+	Data  string `json:"data"`
 }
 
 // MediaPart describes a single media attachment to send.
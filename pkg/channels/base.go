@@ -233,25 +233,13 @@ func (c *BaseChannel) HandleMessage(
 	metadata map[string]string,
 	senderOpts ...bus.SenderInfo,
 ) {
-	// Use SenderInfo-based allow check when available, else fall back to string
 	var sender bus.SenderInfo
 	if len(senderOpts) > 0 {
 		sender = senderOpts[0]
 	}
-	if sender.CanonicalID != "" || sender.PlatformID != "" {
-		if !c.IsAllowedSender(sender) {
-			return
-		}
-	} else {
-		if !c.IsAllowed(senderID) {
-			return
-		}
-	}
-
-	// Set SenderID to canonical if available, otherwise keep the raw senderID
-	resolvedSenderID := senderID
-	if sender.CanonicalID != "" {
-		resolvedSenderID = sender.CanonicalID
+	resolvedSenderID, ok := c.resolveSender(senderID, sender)
+	if !ok {
+		return
 	}
 
 	scope := BuildMediaScope(c.name, chatID, messageID)
@@ -292,10 +280,50 @@ func (c *BaseChannel) HandleMessage(
 		}
 	}
 
+	c.publishInbound(ctx, msg)
+}
+
+// HandleAction publishes the data of a pressed inline button (see
+// bus.Button) as an inbound message from sender. Unlike HandleMessage it does
+// not start typing, reaction or placeholder indicators, since the press
+// answers a message the agent already sent.
+func (c *BaseChannel) HandleAction(ctx context.Context, peer bus.Peer, chatID, data string, sender bus.SenderInfo) {
+	resolvedSenderID, ok := c.resolveSender(sender.PlatformID, sender)
+	if !ok {
+		return
+	}
+	c.publishInbound(ctx, bus.InboundMessage{
+		Channel:  c.name,
+		SenderID: resolvedSenderID,
+		Sender:   sender,
+		ChatID:   chatID,
+		Content:  data,
+		Peer:     peer,
+	})
+}
+
+// resolveSender applies the allowlist and returns the sender ID to record:
+// the canonical ID when available, otherwise the raw senderID.
+func (c *BaseChannel) resolveSender(senderID string, sender bus.SenderInfo) (string, bool) {
+	// Use SenderInfo-based allow check when available, else fall back to string
+	if sender.CanonicalID != "" || sender.PlatformID != "" {
+		if !c.IsAllowedSender(sender) {
+			return "", false
+		}
+	} else if !c.IsAllowed(senderID) {
+		return "", false
+	}
+	if sender.CanonicalID != "" {
+		return sender.CanonicalID, true
+	}
+	return senderID, true
+}
+
+func (c *BaseChannel) publishInbound(ctx context.Context, msg bus.InboundMessage) {
 	if err := c.bus.PublishInbound(ctx, msg); err != nil {
 		logger.ErrorCF("channels", "Failed to publish inbound message", map[string]any{
 			"channel": c.name,
-			"chat_id": chatID,
+			"chat_id": msg.ChatID,
 			"error":   err.Error(),
 		})
 	}
@@ -68,6 +68,7 @@ func (c *DiscordChannel) Start(ctx context.Context) error {
 	c.botUserID = botUser.ID
 
 	c.session.AddHandler(c.handleMessage)
+	c.session.AddHandler(c.handleInteraction)
 
 	if err := c.session.Open(); err != nil {
 		return fmt.Errorf("failed to open discord session: %w", err)
@@ -121,6 +122,10 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 		return nil
 	}
 
+	if len(msg.Buttons) > 0 {
+		return c.sendWithButtons(ctx, channelID, msg.Content, msg.Buttons)
+	}
+
 	return c.sendChunk(ctx, channelID, msg.Content)
 }
 
@@ -268,6 +273,86 @@ func (c *DiscordChannel) sendChunk(ctx context.Context, channelID, content strin
 	}
 }
 
+// sendWithButtons sends content with the buttons as message components whose
+// custom IDs are the button data.
+func (c *DiscordChannel) sendWithButtons(ctx context.Context, channelID, content string, rows [][]bus.Button) error {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	components := make([]discordgo.MessageComponent, 0, len(rows))
+	for _, row := range rows {
+		buttons := make([]discordgo.MessageComponent, 0, len(row))
+		for _, b := range row {
+			buttons = append(buttons, discordgo.Button{
+				Label:    b.Label,
+				Style:    discordgo.PrimaryButton,
+				CustomID: b.Data,
+			})
+		}
+		components = append(components, discordgo.ActionsRow{Components: buttons})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content:    content,
+			Components: components,
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("discord send: %w", channels.ErrTemporary)
+		}
+		return nil
+	case <-sendCtx.Done():
+		return sendCtx.Err()
+	}
+}
+
+// handleInteraction turns a button press into an inbound message carrying
+// the button's custom ID.
+func (c *DiscordChannel) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i == nil || i.Interaction == nil || i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+
+	// Acknowledge right away; Discord shows an error after 3 seconds otherwise
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		logger.DebugCF("discord", "Failed to acknowledge interaction", map[string]any{
+			"error": err.Error(),
+		})
+	}
+
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	data := i.MessageComponentData().CustomID
+	if user == nil || data == "" {
+		return
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "discord",
+		PlatformID:  user.ID,
+		CanonicalID: identity.BuildCanonicalID("discord", user.ID),
+		Username:    user.Username,
+		DisplayName: user.Username,
+	}
+
+	peer := bus.Peer{Kind: "channel", ID: i.ChannelID}
+	if i.GuildID == "" {
+		peer = bus.Peer{Kind: "direct", ID: user.ID}
+	}
+
+	c.HandleAction(c.ctx, peer, i.ChannelID, data, sender)
+}
+
 // appendContent safely appends content to existing text
 func appendContent(content, suffix string) string {
 	if content == "" {
@@ -132,7 +132,11 @@ func (m *Manager) preSend(ctx context.Context, name string, msg bus.OutboundMess
 		}
 	}
 
-	// 3. Try editing placeholder
+	// 3. Try editing placeholder. Messages with buttons are sent fresh and
+	// leave the placeholder for the final response.
+	if len(msg.Buttons) > 0 {
+		return false
+	}
 	if v, loaded := m.placeholders.LoadAndDelete(key); loaded {
 		if entry, ok := v.(placeholderEntry); ok && entry.id != "" {
 			if editor, ok := ch.(MessageEditor); ok {
@@ -263,6 +267,10 @@ func (m *Manager) initChannels() error {
 		m.initChannel("pico", "Pico")
 	}
 
+	if m.config.Channels.MQTT.Enabled && m.config.Channels.MQTT.Broker != "" {
+		m.initChannel("mqtt", "MQTT")
+	}
+
 	logger.InfoCF("channels", "Channel initialization completed", map[string]any{
 		"enabled_channels": len(m.channels),
 	})
@@ -307,6 +315,18 @@ func (m *Manager) SetupHTTPServer(addr string, healthServer *health.Server) {
 	}
 }
 
+// AddWebhookHandler mounts a handler that is not a channel, such as event
+// triggers, on the shared HTTP server. Call it after SetupHTTPServer.
+func (m *Manager) AddWebhookHandler(wh WebhookHandler) {
+	if m.mux == nil {
+		return
+	}
+	m.mux.Handle(wh.WebhookPath(), wh)
+	logger.InfoCF("channels", "Webhook handler registered", map[string]any{
+		"path": wh.WebhookPath(),
+	})
+}
+
 func (m *Manager) StartAll(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -463,9 +483,12 @@ func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker)
 			}
 			if maxLen > 0 && len([]rune(msg.Content)) > maxLen {
 				chunks := SplitMessage(msg.Content, maxLen)
-				for _, chunk := range chunks {
+				for i, chunk := range chunks {
 					chunkMsg := msg
 					chunkMsg.Content = chunk
+					if i < len(chunks)-1 {
+						chunkMsg.Buttons = nil // buttons go with the last chunk
+					}
 					m.sendWithRetry(ctx, name, w, chunkMsg)
 				}
 			} else {
@@ -0,0 +1,396 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mqttclient"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const topicPlaceholder = "{topic}"
+
+// MQTTChannel subscribes to topics on an MQTT broker and turns the messages
+// into inbound messages, one chat per topic. Replies are published to the
+// reply topic derived from the topic the message arrived on.
+//
+// A message is plain text, or a JSON object with a "text" field and
+// optionally "client_id" (who sent it) and "reply_to" (where to answer).
+// MQTT 3.1.1 does not tell subscribers who published a message, and any
+// publisher can write any client_id, so the allowlist matches topics unless
+// TrustClientID is set. reply_to is honored only for AllowedReplyTopics.
+type MQTTChannel struct {
+	*channels.BaseChannel
+	config config.MQTTConfig
+	client paho.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	replyTo map[string]string // chat ID (topic) -> reply_to of its last message
+}
+
+// NewMQTTChannel creates an MQTT channel. It does not connect until Start.
+func NewMQTTChannel(cfg config.MQTTConfig, messageBus *bus.MessageBus) (*MQTTChannel, error) {
+	if len(cfg.Topics) == 0 {
+		return nil, fmt.Errorf("mqtt topics are required")
+	}
+	for _, filter := range cfg.Topics {
+		if err := mqttclient.ValidateFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	for _, filter := range cfg.AllowedReplyTopics {
+		if err := mqttclient.ValidateFilter(filter); err != nil {
+			return nil, fmt.Errorf("invalid mqtt allowed_reply_topics: %w", err)
+		}
+	}
+	if cfg.ReplyTopic == "" {
+		cfg.ReplyTopic = topicPlaceholder + "/reply"
+	}
+	if err := mqttclient.ValidateTopic(strings.ReplaceAll(cfg.ReplyTopic, topicPlaceholder, "t")); err != nil {
+		return nil, fmt.Errorf("invalid mqtt reply_topic: %w", err)
+	}
+
+	opts, err := mqttclient.NewOptions(cfg, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The allowlist matches topic filters as well as client IDs, which the
+	// base channel cannot do, so the channel checks it itself.
+	base := channels.NewBaseChannel("mqtt", cfg, messageBus, nil,
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID))
+
+	c := &MQTTChannel{
+		BaseChannel: base,
+		config:      cfg,
+		replyTo:     make(map[string]string),
+	}
+	opts.SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(c.onConnectionLost)
+	c.client = paho.NewClient(opts)
+	return c, nil
+}
+
+// Start connects to the broker. A broker that refuses the connection (bad
+// credentials, client ID not allowed) is an error; an unreachable one is
+// retried in the background.
+func (c *MQTTChannel) Start(ctx context.Context) error {
+	logger.InfoCF("mqtt", "Starting MQTT channel", map[string]any{
+		"broker": c.config.Broker,
+		"topics": c.config.Topics,
+	})
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	if err := c.connect(); err != nil {
+		if refused(err) {
+			c.cancel()
+			return fmt.Errorf("mqtt connect: %w", err)
+		}
+		logger.WarnCF("mqtt", "MQTT broker not reachable, retrying in background", map[string]any{
+			"broker": c.config.Broker,
+			"error":  err.Error(),
+		})
+		go c.retryConnect()
+	}
+
+	c.SetRunning(true)
+	logger.InfoC("mqtt", "MQTT channel started")
+	return nil
+}
+
+func (c *MQTTChannel) connect() error {
+	ctx, cancel := context.WithTimeout(c.ctx, mqttclient.ConnectTimeout+time.Second)
+	defer cancel()
+	token := c.client.Connect()
+	if err := mqttclient.Wait(ctx, token); err != nil {
+		return connectError{err: err, token: token}
+	}
+	return nil
+}
+
+// retryConnect keeps trying to connect until it succeeds or the channel
+// stops. Once connected, the client reconnects by itself.
+func (c *MQTTChannel) retryConnect() {
+	delay := time.Second
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		err := c.connect()
+		if err == nil {
+			return
+		}
+		logger.DebugCF("mqtt", "MQTT connect failed", map[string]any{"error": err.Error()})
+		delay = min(delay*2, time.Minute)
+	}
+}
+
+// connectError keeps the token of a failed connect so that refused can look
+// at the broker's return code.
+type connectError struct {
+	err   error
+	token paho.Token
+}
+
+func (e connectError) Error() string { return e.err.Error() }
+func (e connectError) Unwrap() error { return e.err }
+
+// refused reports whether the broker answered the connect with a refusal
+// (return codes 1-5), which retrying will not fix.
+func refused(err error) bool {
+	var ce connectError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	ct, ok := ce.token.(*paho.ConnectToken)
+	if !ok {
+		return false
+	}
+	rc := ct.ReturnCode()
+	return rc >= packets.ErrRefusedBadProtocolVersion && rc <= packets.ErrRefusedNotAuthorised
+}
+
+// Stop disconnects from the broker.
+func (c *MQTTChannel) Stop(ctx context.Context) error {
+	logger.InfoC("mqtt", "Stopping MQTT channel")
+	c.SetRunning(false)
+	c.client.Disconnect(250)
+	if c.cancel != nil {
+		c.cancel()
+	}
+	logger.InfoC("mqtt", "MQTT channel stopped")
+	return nil
+}
+
+// onConnect subscribes to the configured topics. It runs after every
+// (re)connect because the session is not persisted on the broker.
+func (c *MQTTChannel) onConnect(client paho.Client) {
+	filters := make(map[string]byte, len(c.config.Topics))
+	for _, filter := range c.config.Topics {
+		filters[filter] = byte(c.config.QoS)
+	}
+	token := client.SubscribeMultiple(filters, c.handleMessage)
+	go func() {
+		if err := mqttclient.Wait(c.ctx, token); err != nil {
+			logger.ErrorCF("mqtt", "Failed to subscribe", map[string]any{
+				"topics": c.config.Topics,
+				"error":  err.Error(),
+			})
+			return
+		}
+		logger.InfoCF("mqtt", "Subscribed", map[string]any{"topics": c.config.Topics})
+	}()
+}
+
+func (c *MQTTChannel) onConnectionLost(_ paho.Client, err error) {
+	logger.WarnCF("mqtt", "MQTT connection lost, reconnecting", map[string]any{"error": err.Error()})
+}
+
+// Send publishes msg to the reply topic of its chat.
+func (c *MQTTChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if msg.ChatID == "" {
+		return fmt.Errorf("mqtt chat ID (topic) is empty: %w", channels.ErrSendFailed)
+	}
+	topic := c.replyTopic(msg.ChatID)
+	if err := mqttclient.ValidateTopic(topic); err != nil {
+		return fmt.Errorf("mqtt reply topic: %v: %w", err, channels.ErrSendFailed)
+	}
+
+	token := c.client.Publish(topic, byte(c.config.QoS), c.config.Retain, msg.Content)
+	if err := mqttclient.Wait(ctx, token); err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		return fmt.Errorf("mqtt publish to %s: %v: %w", topic, err, channels.ErrTemporary)
+	}
+	return nil
+}
+
+// replyTopic is the reply_to of the chat's last message, or the configured
+// reply topic with "{topic}" replaced by the chat's topic.
+func (c *MQTTChannel) replyTopic(chatID string) string {
+	c.mu.Lock()
+	replyTo := c.replyTo[chatID]
+	c.mu.Unlock()
+	if replyTo != "" {
+		return replyTo
+	}
+	return strings.ReplaceAll(c.config.ReplyTopic, topicPlaceholder, chatID)
+}
+
+// isReplyTopic reports whether the channel publishes replies to topic, so
+// that a subscription such as "picoclaw/#" does not feed replies back in.
+func (c *MQTTChannel) isReplyTopic(topic string) bool {
+	c.mu.Lock()
+	for _, replyTo := range c.replyTo {
+		if topic == replyTo {
+			c.mu.Unlock()
+			return true
+		}
+	}
+	c.mu.Unlock()
+
+	prefix, suffix, ok := strings.Cut(c.config.ReplyTopic, topicPlaceholder)
+	if !ok {
+		return topic == c.config.ReplyTopic
+	}
+	return len(topic) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(topic, prefix) && strings.HasSuffix(topic, suffix)
+}
+
+func (c *MQTTChannel) handleMessage(_ paho.Client, m paho.Message) {
+	topic := m.Topic()
+	if c.isReplyTopic(topic) {
+		return
+	}
+	// Retained messages are old state, not something someone just said; acting
+	// on them would replay the last command on every restart.
+	if m.Retained() {
+		logger.DebugCF("mqtt", "Ignoring retained message", map[string]any{"topic": topic})
+		return
+	}
+
+	content, clientID, replyTo := parsePayload(m.Payload())
+	if content == "" {
+		return
+	}
+	if !c.allowed(clientID, topic) {
+		logger.DebugCF("mqtt", "Message rejected by allowlist", map[string]any{
+			"topic":     topic,
+			"client_id": clientID,
+		})
+		return
+	}
+
+	replyOK := c.replyToAllowed(replyTo)
+	if replyTo != "" && !replyOK {
+		logger.WarnCF("mqtt", "Ignoring reply_to outside allowed_reply_topics", map[string]any{
+			"topic":    topic,
+			"reply_to": replyTo,
+		})
+	}
+	c.mu.Lock()
+	if replyOK {
+		c.replyTo[topic] = replyTo
+	} else {
+		delete(c.replyTo, topic)
+	}
+	c.mu.Unlock()
+
+	// A claimed client ID is the sender only when the config trusts it.
+	senderID := topic
+	if clientID != "" && c.config.TrustClientID {
+		senderID = clientID
+	}
+	sender := bus.SenderInfo{
+		Platform:    "mqtt",
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID("mqtt", senderID),
+	}
+	metadata := map[string]string{
+		"platform": "mqtt",
+		"topic":    topic,
+		"qos":      strconv.Itoa(int(m.Qos())),
+	}
+	if clientID != "" {
+		metadata["client_id"] = clientID
+	}
+	var messageID string
+	if m.MessageID() != 0 {
+		messageID = strconv.Itoa(int(m.MessageID()))
+	}
+
+	logger.DebugCF("mqtt", "Received message", map[string]any{
+		"topic":     topic,
+		"client_id": clientID,
+		"preview":   utils.Truncate(content, 50),
+	})
+
+	c.HandleMessage(c.ctx, bus.Peer{Kind: "channel", ID: topic}, messageID, senderID, topic, content, nil, metadata,
+		sender)
+}
+
+// parsePayload extracts the text of a message and, for JSON payloads, the
+// optional client_id and reply_to fields. Other payloads, including JSON
+// without a text field such as sensor readings, are used verbatim.
+func parsePayload(payload []byte) (content, clientID, replyTo string) {
+	raw := strings.TrimSpace(strings.ToValidUTF8(string(payload), "�"))
+	if strings.HasPrefix(raw, "{") {
+		var msg struct {
+			Text     string `json:"text"`
+			ClientID string `json:"client_id"`
+			ReplyTo  string `json:"reply_to"`
+		}
+		if json.Unmarshal(payload, &msg) == nil && msg.Text != "" {
+			return strings.TrimSpace(msg.Text), msg.ClientID, msg.ReplyTo
+		}
+	}
+	return raw, "", ""
+}
+
+// replyToAllowed reports whether replies may go to a payload's reply_to.
+func (c *MQTTChannel) replyToAllowed(replyTo string) bool {
+	if replyTo == "" || mqttclient.ValidateTopic(replyTo) != nil {
+		return false
+	}
+	for _, filter := range c.config.AllowedReplyTopics {
+		if mqttclient.MatchTopic(filter, replyTo) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllowed reports whether senderID, a topic or a trusted client ID, is
+// allowed.
+func (c *MQTTChannel) IsAllowed(senderID string) bool {
+	return c.allowed(senderID, senderID)
+}
+
+// IsAllowedSender reports whether sender is allowed.
+func (c *MQTTChannel) IsAllowedSender(sender bus.SenderInfo) bool {
+	return c.IsAllowed(sender.PlatformID)
+}
+
+// allowed matches allow_from entries as topic filters against the topic and,
+// with trust_client_id, against the client ID, with or without an "mqtt:"
+// prefix. An empty allowlist allows everyone.
+func (c *MQTTChannel) allowed(clientID, topic string) bool {
+	if len(c.config.AllowFrom) == 0 {
+		return true
+	}
+	for _, entry := range c.config.AllowFrom {
+		entry = strings.TrimPrefix(strings.TrimSpace(entry), "mqtt:")
+		if entry == "" {
+			continue
+		}
+		if c.config.TrustClientID && clientID != "" && entry == clientID {
+			return true
+		}
+		if topic != "" && mqttclient.ValidateFilter(entry) == nil && mqttclient.MatchTopic(entry, topic) {
+			return true
+		}
+	}
+	return false
+}
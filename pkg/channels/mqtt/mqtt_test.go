@@ -0,0 +1,376 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// startBroker runs an in-process broker and returns its URL. Messages
+// published by clients are delivered to the returned channel.
+func startBroker(t *testing.T, ledger *auth.Ledger, tlsCfg *tls.Config) (*mochi.Server, string, chan packets.Packet) {
+	t.Helper()
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if ledger != nil {
+		if err := server.AddHook(new(auth.Hook), &auth.Options{Ledger: ledger}); err != nil {
+			t.Fatal(err)
+		}
+	} else if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme := "tcp"
+	if tlsCfg != nil {
+		ln = tls.NewListener(ln, tlsCfg)
+		scheme = "ssl"
+	}
+	if err := server.AddListener(listeners.NewNet("test", ln)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	published := make(chan packets.Packet, 16)
+	err = server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		if pk.Origin != mochi.InlineClientId {
+			published <- pk
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, scheme + "://" + ln.Addr().String(), published
+}
+
+func newTestChannel(t *testing.T, server *mochi.Server, cfg config.MQTTConfig) (*MQTTChannel, *bus.MessageBus) {
+	t.Helper()
+	if len(cfg.Topics) == 0 {
+		cfg.Topics = []string{"picoclaw/in/#"}
+	}
+	cfg.ClientID = "picoclaw-test"
+	mb := bus.NewMessageBus()
+	ch, err := NewMQTTChannel(cfg, mb)
+	if err != nil {
+		t.Fatalf("NewMQTTChannel() error = %v", err)
+	}
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { ch.Stop(context.Background()) })
+
+	// onConnect subscribes asynchronously.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if cl, ok := server.Clients.Get(cfg.ClientID); ok && cl.State.Subscriptions.Len() == len(cfg.Topics) {
+			return ch, mb
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("channel did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func receive(t *testing.T, mb *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := mb.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func expectNone(t *testing.T, mb *bus.MessageBus) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if msg, ok := mb.ConsumeInbound(ctx); ok {
+		t.Fatalf("unexpected inbound message: %+v", msg)
+	}
+}
+
+func expectPublish(t *testing.T, published chan packets.Packet) packets.Packet {
+	t.Helper()
+	select {
+	case pk := <-published:
+		return pk
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing published")
+		return packets.Packet{}
+	}
+}
+
+func TestMQTTChannel_PlainTextAndReply(t *testing.T) {
+	server, url, published := startBroker(t, nil, nil)
+	ch, mb := newTestChannel(t, server, config.MQTTConfig{Broker: url, QoS: 1})
+
+	if err := server.Publish("picoclaw/in/kitchen", []byte("  turn on the light "), false, 1); err != nil {
+		t.Fatal(err)
+	}
+	msg := receive(t, mb)
+	if msg.Channel != "mqtt" || msg.ChatID != "picoclaw/in/kitchen" || msg.Content != "turn on the light" {
+		t.Fatalf("inbound = %+v", msg)
+	}
+	if msg.SenderID != "mqtt:picoclaw/in/kitchen" || msg.Peer.Kind != "channel" {
+		t.Errorf("sender = %q, peer = %+v", msg.SenderID, msg.Peer)
+	}
+	if msg.Metadata["topic"] != "picoclaw/in/kitchen" {
+		t.Errorf("metadata = %v", msg.Metadata)
+	}
+
+	err := ch.Send(context.Background(), bus.OutboundMessage{Channel: "mqtt", ChatID: msg.ChatID, Content: "done"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	pk := expectPublish(t, published)
+	if pk.TopicName != "picoclaw/in/kitchen/reply" || string(pk.Payload) != "done" || pk.FixedHeader.Qos != 1 {
+		t.Errorf("published %s %q qos %d", pk.TopicName, pk.Payload, pk.FixedHeader.Qos)
+	}
+	// The reply topic matches the subscription but must not come back in.
+	expectNone(t, mb)
+}
+
+func TestMQTTChannel_JSONPayloadReplyTo(t *testing.T) {
+	server, url, published := startBroker(t, nil, nil)
+	ch, mb := newTestChannel(t, server, config.MQTTConfig{
+		Broker:             url,
+		Retain:             true,
+		AllowedReplyTopics: config.FlexibleStringSlice{"devices/+/answer"},
+	})
+
+	payload := `{"text":"status?","client_id":"sensor-1","reply_to":"devices/sensor-1/answer"}`
+	if err := server.Publish("picoclaw/in/sensors", []byte(payload), false, 0); err != nil {
+		t.Fatal(err)
+	}
+	msg := receive(t, mb)
+	// The client ID is not trusted, so the topic stays the sender.
+	if msg.Content != "status?" || msg.SenderID != "mqtt:picoclaw/in/sensors" {
+		t.Fatalf("inbound = %+v", msg)
+	}
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: msg.ChatID, Content: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	pk := expectPublish(t, published)
+	if pk.TopicName != "devices/sensor-1/answer" || !pk.FixedHeader.Retain {
+		t.Errorf("published to %s retain=%v", pk.TopicName, pk.FixedHeader.Retain)
+	}
+
+	// A reply_to outside allowed_reply_topics, such as an actuator topic,
+	// is ignored and the reply goes to the default reply topic.
+	payload = `{"text":"open","reply_to":"home/door/set"}`
+	if err := server.Publish("picoclaw/in/sensors", []byte(payload), false, 0); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, mb)
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: msg.ChatID, Content: "no"}); err != nil {
+		t.Fatal(err)
+	}
+	if pk := expectPublish(t, published); pk.TopicName != "picoclaw/in/sensors/reply" {
+		t.Errorf("published to %s, want the default reply topic", pk.TopicName)
+	}
+
+	// JSON without a text field, such as a sensor reading, is used verbatim.
+	if err := server.Publish("picoclaw/in/sensors", []byte(`{"temp":21.5}`), false, 0); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, mb); msg.Content != `{"temp":21.5}` || msg.SenderID != "mqtt:picoclaw/in/sensors" {
+		t.Errorf("inbound = %+v", msg)
+	}
+}
+
+func TestMQTTChannel_AllowFrom(t *testing.T) {
+	for _, trust := range []bool{false, true} {
+		server, url, _ := startBroker(t, nil, nil)
+		_, mb := newTestChannel(t, server, config.MQTTConfig{
+			Broker:        url,
+			AllowFrom:     config.FlexibleStringSlice{"picoclaw/in/trusted/+", "mqtt:panel"},
+			TrustClientID: trust,
+		})
+		testAllowFrom(t, server, mb, trust)
+	}
+}
+
+func testAllowFrom(t *testing.T, server *mochi.Server, mb *bus.MessageBus, trustClientID bool) {
+	t.Helper()
+	for _, tc := range []struct {
+		topic, payload string
+		allowed        bool
+	}{
+		{"picoclaw/in/trusted/door", "open", true},
+		{"picoclaw/in/other", "open", false},
+		{"picoclaw/in/trusted/door/deeper", "open", false},
+		// Anyone can claim a client ID, so it only counts when trusted.
+		{"picoclaw/in/other", `{"text":"hi","client_id":"panel"}`, trustClientID},
+		{"picoclaw/in/other", `{"text":"hi","client_id":"intruder"}`, false},
+	} {
+		if err := server.Publish(tc.topic, []byte(tc.payload), false, 0); err != nil {
+			t.Fatal(err)
+		}
+		if tc.allowed {
+			if msg := receive(t, mb); msg.ChatID != tc.topic {
+				t.Errorf("%s %s: got message for %s", tc.topic, tc.payload, msg.ChatID)
+			}
+		} else {
+			expectNone(t, mb)
+		}
+	}
+}
+
+func TestMQTTChannel_IgnoresRetained(t *testing.T) {
+	server, url, _ := startBroker(t, nil, nil)
+	// Retained before the channel subscribes, so the broker replays it.
+	if err := server.Publish("picoclaw/in/lamp", []byte("toggle"), true, 0); err != nil {
+		t.Fatal(err)
+	}
+	_, mb := newTestChannel(t, server, config.MQTTConfig{Broker: url})
+	expectNone(t, mb)
+}
+
+func TestMQTTChannel_Credentials(t *testing.T) {
+	ledger := &auth.Ledger{Auth: auth.AuthRules{{Username: "agent", Password: "secret", Allow: true}}}
+	server, url, _ := startBroker(t, ledger, nil)
+
+	_, mb := newTestChannel(t, server, config.MQTTConfig{Broker: url, Username: "agent", Password: "secret"})
+	if err := server.Publish("picoclaw/in/x", []byte("hello"), false, 0); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, mb)
+
+	ch, err := NewMQTTChannel(config.MQTTConfig{
+		Broker:   url,
+		ClientID: "picoclaw-bad",
+		Username: "agent",
+		Password: "wrong",
+		Topics:   []string{"#"},
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.Start(context.Background()); err == nil {
+		ch.Stop(context.Background())
+		t.Fatal("Start() with a wrong password succeeded")
+	}
+}
+
+func TestMQTTChannel_TLS(t *testing.T) {
+	cert, caFile := selfSignedCert(t)
+	server, url, _ := startBroker(t, nil, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	_, mb := newTestChannel(t, server, config.MQTTConfig{Broker: url, CAFile: caFile})
+	if err := server.Publish("picoclaw/in/secure", []byte("hi"), false, 0); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, mb); msg.Content != "hi" {
+		t.Errorf("inbound = %+v", msg)
+	}
+}
+
+func TestNewMQTTChannel_InvalidConfig(t *testing.T) {
+	for name, cfg := range map[string]config.MQTTConfig{
+		"no broker":      {Topics: []string{"a"}},
+		"no topics":      {Broker: "tcp://127.0.0.1:1883"},
+		"bad filter":     {Broker: "tcp://127.0.0.1:1883", Topics: []string{"a/#/b"}},
+		"wildcard reply": {Broker: "tcp://127.0.0.1:1883", Topics: []string{"a"}, ReplyTopic: "out/#"},
+		"bad reply filter": {
+			Broker:             "tcp://127.0.0.1:1883",
+			Topics:             []string{"a"},
+			AllowedReplyTopics: config.FlexibleStringSlice{"out/#/x"},
+		},
+		"bad qos":         {Broker: "tcp://127.0.0.1:1883", Topics: []string{"a"}, QoS: 3},
+		"missing ca file": {Broker: "ssl://127.0.0.1:8883", Topics: []string{"a"}, CAFile: "/nonexistent"},
+	} {
+		if _, err := NewMQTTChannel(cfg, bus.NewMessageBus()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParsePayload(t *testing.T) {
+	tests := []struct {
+		payload, content, clientID, replyTo string
+	}{
+		{"hello", "hello", "", ""},
+		{`{"text":" hi ","client_id":"c1","reply_to":"r/1"}`, "hi", "c1", "r/1"},
+		{`{"text":""}`, `{"text":""}`, "", ""},
+		{`{"broken"`, `{"broken"`, "", ""},
+		{"", "", "", ""},
+	}
+	for _, tt := range tests {
+		content, clientID, replyTo := parsePayload([]byte(tt.payload))
+		if content != tt.content || clientID != tt.clientID || replyTo != tt.replyTo {
+			t.Errorf("parsePayload(%q) = %q, %q, %q", tt.payload, content, clientID, replyTo)
+		}
+	}
+}
+
+// selfSignedCert returns a certificate for 127.0.0.1 and the path of a PEM
+// file holding it, to be used as the CA.
+func selfSignedCert(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test broker"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, caFile
+}
@@ -19,6 +19,9 @@ import (
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// maxSectionTextLen is Slack's limit for the text of a section block.
+const maxSectionTextLen = 3000
+
 type SlackChannel struct {
 	*channels.BaseChannel
 	config       config.SlackConfig
@@ -121,6 +124,9 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	opts := []slack.MsgOption{
 		slack.MsgOptionText(msg.Content, false),
 	}
+	if len(msg.Buttons) > 0 {
+		opts = append(opts, slack.MsgOptionBlocks(buttonBlocks(msg.Content, msg.Buttons)...))
+	}
 
 	if threadTS != "" {
 		opts = append(opts, slack.MsgOptionTS(threadTS))
@@ -131,6 +137,11 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("slack send: %w", channels.ErrTemporary)
 	}
 
+	// A message with buttons asks the user something; it does not answer them
+	if len(msg.Buttons) > 0 {
+		return nil
+	}
+
 	if ref, ok := c.pendingAcks.LoadAndDelete(msg.ChatID); ok {
 		msgRef := ref.(slackMessageRef)
 		c.api.AddReaction("white_check_mark", slack.ItemRef{
@@ -241,6 +252,7 @@ func (c *SlackChannel) eventLoop() {
 				if event.Request != nil {
 					c.socketClient.Ack(*event.Request)
 				}
+				c.handleInteractive(event)
 			}
 		}
 	}
@@ -522,6 +534,61 @@ func (c *SlackChannel) stripBotMention(text string) string {
 	return strings.TrimSpace(text)
 }
 
+// buttonBlocks renders content followed by the buttons as Block Kit blocks.
+// Button values carry the button data.
+func buttonBlocks(content string, rows [][]bus.Button) []slack.Block {
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			utils.Truncate(content, maxSectionTextLen), false, false), nil, nil),
+	}
+	for r, row := range rows {
+		elements := make([]slack.BlockElement, 0, len(row))
+		for i, b := range row {
+			elements = append(elements, slack.NewButtonBlockElement(
+				fmt.Sprintf("picoclaw_button_%d_%d", r, i), b.Data,
+				slack.NewTextBlockObject(slack.PlainTextType, b.Label, false, false)))
+		}
+		blocks = append(blocks, slack.NewActionBlock(fmt.Sprintf("picoclaw_buttons_%d", r), elements...))
+	}
+	return blocks
+}
+
+// handleInteractive turns a Block Kit button press into an inbound message
+// carrying the button value, in the chat (and thread) of the pressed message.
+func (c *SlackChannel) handleInteractive(event socketmode.Event) {
+	callback, ok := event.Data.(slack.InteractionCallback)
+	if !ok || callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+
+	channelID := callback.Channel.ID
+	if channelID == "" {
+		channelID = callback.Container.ChannelID
+	}
+	chatID := channelID
+	if threadTS := callback.Message.ThreadTimestamp; threadTS != "" {
+		chatID = channelID + "/" + threadTS
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "slack",
+		PlatformID:  callback.User.ID,
+		CanonicalID: identity.BuildCanonicalID("slack", callback.User.ID),
+		Username:    callback.User.Name,
+	}
+	peer := bus.Peer{Kind: "channel", ID: channelID}
+	if strings.HasPrefix(channelID, "D") {
+		peer = bus.Peer{Kind: "direct", ID: callback.User.ID}
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.Value == "" {
+			continue
+		}
+		c.HandleAction(c.ctx, peer, chatID, action.Value, sender)
+	}
+}
+
 func parseSlackChatID(chatID string) (channelID, threadTS string) {
 	parts := strings.SplitN(chatID, "/", 2)
 	channelID = parts[0]
@@ -128,6 +128,11 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return c.commands.Show(ctx, message)
 	}, th.CommandEqual("show"))
 
+	// /list tools depends on the agent the chat is routed to, so the agent loop answers it.
+	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
+		return c.handleMessage(ctx, &message)
+	}, th.CommandEqualArgv("list", "tools"))
+
 	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
 		return c.commands.List(ctx, message)
 	}, th.CommandEqual("list"))
@@ -136,6 +141,10 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return c.handleMessage(ctx, &message)
 	}, th.AnyMessage())
 
+	bh.HandleCallbackQuery(func(ctx *th.Context, query telego.CallbackQuery) error {
+		return c.handleCallbackQuery(ctx, query)
+	}, th.AnyCallbackQueryWithMessage())
+
 	c.SetRunning(true)
 	logger.InfoCF("telegram", "Telegram bot connected", map[string]any{
 		"username": c.bot.Username(),
@@ -178,6 +187,9 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	// Typing/placeholder handled by Manager.preSend — just send the message
 	tgMsg := tu.Message(tu.ID(chatID), htmlContent)
 	tgMsg.ParseMode = telego.ModeHTML
+	if len(msg.Buttons) > 0 {
+		tgMsg.ReplyMarkup = inlineKeyboard(msg.Buttons)
+	}
 
 	if _, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
 		logger.ErrorCF("telegram", "HTML parse failed, falling back to plain text", map[string]any{
@@ -509,6 +521,51 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 	return nil
 }
 
+// inlineKeyboard converts bus buttons to a Telegram inline keyboard whose
+// callback data is the button data.
+func inlineKeyboard(rows [][]bus.Button) *telego.InlineKeyboardMarkup {
+	keyboard := make([][]telego.InlineKeyboardButton, 0, len(rows))
+	for _, row := range rows {
+		buttons := make([]telego.InlineKeyboardButton, 0, len(row))
+		for _, b := range row {
+			buttons = append(buttons, tu.InlineKeyboardButton(b.Label).WithCallbackData(b.Data))
+		}
+		keyboard = append(keyboard, buttons)
+	}
+	return tu.InlineKeyboard(keyboard...)
+}
+
+// handleCallbackQuery turns an inline button press into an inbound message
+// carrying the button data.
+func (c *TelegramChannel) handleCallbackQuery(ctx context.Context, query telego.CallbackQuery) error {
+	if err := c.bot.AnswerCallbackQuery(ctx, tu.CallbackQuery(query.ID)); err != nil {
+		logger.DebugCF("telegram", "Failed to answer callback query", map[string]any{
+			"error": err.Error(),
+		})
+	}
+	if query.Data == "" {
+		return nil
+	}
+
+	platformID := fmt.Sprintf("%d", query.From.ID)
+	sender := bus.SenderInfo{
+		Platform:    "telegram",
+		PlatformID:  platformID,
+		CanonicalID: identity.BuildCanonicalID("telegram", platformID),
+		Username:    query.From.Username,
+		DisplayName: query.From.FirstName,
+	}
+
+	chat := query.Message.GetChat()
+	peer := bus.Peer{Kind: "direct", ID: platformID}
+	if chat.Type != "private" {
+		peer = bus.Peer{Kind: "group", ID: fmt.Sprintf("%d", chat.ID)}
+	}
+
+	c.HandleAction(c.ctx, peer, fmt.Sprintf("%d", chat.ID), query.Data, sender)
+	return nil
+}
+
 func (c *TelegramChannel) downloadPhoto(ctx context.Context, fileID string) string {
 	file, err := c.bot.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
 	if err != nil {
@@ -41,7 +41,8 @@ func (c *cmd) Help(ctx context.Context, message telego.Message) error {
 	msg := `/start - Start the bot
 /help - Show this help message
 /show [model|channel] - Show current configuration
-/list [models|channels] - List available options
+/list [models|channels|tools] - List available options
+/clear - Clear the conversation and stop its background processes
 	`
 	_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
 		ChatID: telego.ChatID{ID: message.Chat.ID},
@@ -104,7 +105,7 @@ func (c *cmd) List(ctx context.Context, message telego.Message) error {
 	if args == "" {
 		_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
 			ChatID: telego.ChatID{ID: message.Chat.ID},
-			Text:   "Usage: /list [models|channels]",
+			Text:   "Usage: /list [models|channels|tools]",
 			ReplyParameters: &telego.ReplyParameters{
 				MessageID: message.MessageID,
 			},
@@ -58,6 +58,7 @@ type Config struct {
 	Tools     ToolsConfig     `json:"tools"`
 	Heartbeat HeartbeatConfig `json:"heartbeat"`
 	Devices   DevicesConfig   `json:"devices"`
+	Triggers  TriggersConfig  `json:"triggers"`
 }
 
 // MarshalJSON implements custom JSON marshaling for Config
@@ -130,13 +131,30 @@ func (m AgentModelConfig) MarshalJSON() ([]byte, error) {
 }
 
 type AgentConfig struct {
-	ID        string            `json:"id"`
-	Default   bool              `json:"default,omitempty"`
-	Name      string            `json:"name,omitempty"`
-	Workspace string            `json:"workspace,omitempty"`
-	Model     *AgentModelConfig `json:"model,omitempty"`
-	Skills    []string          `json:"skills,omitempty"`
-	Subagents *SubagentsConfig  `json:"subagents,omitempty"`
+	ID        string              `json:"id"`
+	Default   bool                `json:"default,omitempty"`
+	Name      string              `json:"name,omitempty"`
+	Workspace string              `json:"workspace,omitempty"`
+	Model     *AgentModelConfig   `json:"model,omitempty"`
+	Skills    []string            `json:"skills,omitempty"`
+	Subagents *SubagentsConfig    `json:"subagents,omitempty"`
+	Network   *AgentNetworkConfig `json:"network,omitempty"`
+	Tools     *AgentToolsConfig   `json:"tools,omitempty"`
+}
+
+// AgentToolsConfig limits the tools an agent gets. Entries are tool names,
+// wildcards such as "mcp_*", or the groups fs, web, hardware, exec, agents,
+// skills and mcp. Deny wins over allow; an empty allow list allows all tools.
+type AgentToolsConfig struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// AgentNetworkConfig narrows the global network policy for one agent: hosts must
+// also be in AllowedDomains (when set) and not in DeniedDomains.
+type AgentNetworkConfig struct {
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	DeniedDomains  []string `json:"denied_domains,omitempty"`
 }
 
 type SubagentsConfig struct {
@@ -204,6 +222,7 @@ type ChannelsConfig struct {
 	WeCom    WeComConfig    `json:"wecom"`
 	WeComApp WeComAppConfig `json:"wecom_app"`
 	Pico     PicoConfig     `json:"pico"`
+	MQTT     MQTTConfig     `json:"mqtt"`
 }
 
 // GroupTriggerConfig controls when the bot responds in group chats.
@@ -372,6 +391,35 @@ type PicoConfig struct {
 	Placeholder     PlaceholderConfig   `json:"placeholder,omitempty"`
 }
 
+// MQTTConfig connects to an MQTT broker. Messages on Topics become inbound
+// messages and replies are published to ReplyTopic, where "{topic}" stands
+// for the topic the message arrived on. The mqtt_publish tool uses the same
+// broker settings, whether or not the channel is enabled.
+type MQTTConfig struct {
+	Enabled            bool                `json:"enabled"                 env:"PICOCLAW_CHANNELS_MQTT_ENABLED"`
+	Broker             string              `json:"broker"                  env:"PICOCLAW_CHANNELS_MQTT_BROKER"`
+	ClientID           string              `json:"client_id"               env:"PICOCLAW_CHANNELS_MQTT_CLIENT_ID"`
+	Username           string              `json:"username"                env:"PICOCLAW_CHANNELS_MQTT_USERNAME"`
+	Password           string              `json:"password"                env:"PICOCLAW_CHANNELS_MQTT_PASSWORD"`
+	CAFile             string              `json:"ca_file,omitempty"       env:"PICOCLAW_CHANNELS_MQTT_CA_FILE"`
+	CertFile           string              `json:"cert_file,omitempty"     env:"PICOCLAW_CHANNELS_MQTT_CERT_FILE"`
+	KeyFile            string              `json:"key_file,omitempty"      env:"PICOCLAW_CHANNELS_MQTT_KEY_FILE"`
+	InsecureSkipVerify bool                `json:"insecure_skip_verify,omitempty"`
+	Topics             []string            `json:"topics"                  env:"PICOCLAW_CHANNELS_MQTT_TOPICS"`
+	ReplyTopic         string              `json:"reply_topic"             env:"PICOCLAW_CHANNELS_MQTT_REPLY_TOPIC"`
+	QoS                int                 `json:"qos"                     env:"PICOCLAW_CHANNELS_MQTT_QOS"`
+	Retain             bool                `json:"retain"                  env:"PICOCLAW_CHANNELS_MQTT_RETAIN"`
+	AllowFrom          FlexibleStringSlice `json:"allow_from"              env:"PICOCLAW_CHANNELS_MQTT_ALLOW_FROM"`
+	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_MQTT_REASONING_CHANNEL_ID"`
+	// TrustClientID lets allow_from match the client_id field of JSON
+	// payloads. Publishers choose that field themselves, so it is not
+	// authenticated.
+	TrustClientID bool `json:"trust_client_id,omitempty" env:"PICOCLAW_CHANNELS_MQTT_TRUST_CLIENT_ID"`
+	// AllowedReplyTopics are the topic filters a payload's reply_to may
+	// name. Without them reply_to is ignored.
+	AllowedReplyTopics FlexibleStringSlice `json:"allowed_reply_topics,omitempty" env:"PICOCLAW_CHANNELS_MQTT_ALLOWED_REPLY_TOPICS"`
+}
+
 type HeartbeatConfig struct {
 	Enabled  bool `json:"enabled"  env:"PICOCLAW_HEARTBEAT_ENABLED"`
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
@@ -382,6 +430,35 @@ type DevicesConfig struct {
 	MonitorUSB bool `json:"monitor_usb" env:"PICOCLAW_DEVICES_MONITOR_USB"`
 }
 
+// TriggersConfig turns external events into agent messages. Rules are keyed
+// by trigger name.
+type TriggersConfig struct {
+	Enabled bool                     `json:"enabled"         env:"PICOCLAW_TRIGGERS_ENABLED"`
+	Rules   map[string]TriggerConfig `json:"rules,omitempty"`
+}
+
+// TriggerConfig describes one trigger. Type is "webhook", "file" or
+// "device"; the fields below Token apply to one type each.
+type TriggerConfig struct {
+	Disabled   bool   `json:"disabled,omitempty"`
+	Type       string `json:"type"`
+	Agent      string `json:"agent,omitempty"`        // agent ID; default agent when empty
+	Session    string `json:"session,omitempty"`      // session name; "trigger:<name>" when empty
+	Channel    string `json:"channel,omitempty"`      // where replies go; last active chat when empty
+	ChatID     string `json:"chat_id,omitempty"`      // with Channel
+	Prompt     string `json:"prompt,omitempty"`       // text/template for the message; a summary when empty
+	DebounceMS int    `json:"debounce_ms,omitempty"`  // fold events closer than this; default 1000
+	MaxPerHour int    `json:"max_per_hour,omitempty"` // messages per hour; default 60
+
+	Token     string   `json:"token,omitempty"`     // webhook: bearer token or HMAC-SHA256 key (required)
+	Paths     []string `json:"paths,omitempty"`     // file: workspace files or directories to watch
+	Recursive bool     `json:"recursive,omitempty"` // file: watch subdirectories too
+	// Events filters event kinds; empty means all. file: create, write,
+	// remove, rename. device: add, remove, change.
+	Events      []string `json:"events,omitempty"`
+	DeviceKinds []string `json:"device_kinds,omitempty"` // device: usb, bluetooth, ...; empty means all
+}
+
 type ProvidersConfig struct {
 	Anthropic     ProviderConfig       `json:"anthropic"`
 	OpenAI        OpenAIProviderConfig `json:"openai"`
@@ -470,10 +547,40 @@ type ModelConfig struct {
 	ConnectMode string `json:"connect_mode,omitempty"` // Connection mode: stdio, grpc
 	Workspace   string `json:"workspace,omitempty"`    // Workspace path for CLI-based providers
 
+	// Azure OpenAI (azure/<deployment>)
+	APIVersion string `json:"api_version,omitempty"` // api-version query parameter (default: 2024-10-21)
+
+	// Google Gemini (gemini/<model>): use the native generateContent API
+	// instead of the OpenAI-compatible one at api_base (opt-in)
+	NativeAPI bool `json:"native_api,omitempty"`
+
+	// AWS Bedrock (bedrock/<model-id>); empty fields fall back to the standard AWS_* environment variables
+	AWSRegion          string `json:"aws_region,omitempty"`
+	AWSAccessKeyID     string `json:"aws_access_key_id,omitempty"`
+	AWSSecretAccessKey string `json:"aws_secret_access_key,omitempty"`
+	AWSSessionToken    string `json:"aws_session_token,omitempty"`
+
 	// Optional optimizations
 	RPM            int    `json:"rpm,omitempty"`              // Requests per minute limit
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
 	RequestTimeout int    `json:"request_timeout,omitempty"`
+
+	// Load balancing across entries sharing the same model_name (optional)
+	LoadBalance string `json:"load_balance,omitempty"` // round_robin (default), weighted, least_latency
+	Weight      int    `json:"weight,omitempty"`       // relative weight for the weighted strategy (default: 1)
+
+	// Background health probing (optional)
+	HealthCheck *HealthCheckConfig `json:"health_check,omitempty"`
+}
+
+// HealthCheckConfig enables a periodic background probe for a model_list entry.
+// Method "models" lists models on OpenAI-compatible endpoints (free);
+// "chat" sends a one-token completion and works with every protocol.
+type HealthCheckConfig struct {
+	Enabled         bool   `json:"enabled"`
+	Method          string `json:"method,omitempty"`           // models | chat (default: chat)
+	IntervalSeconds int    `json:"interval_seconds,omitempty"` // default: 300
+	TimeoutSeconds  int    `json:"timeout_seconds,omitempty"`  // default: 30
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -490,6 +597,11 @@ func (c *ModelConfig) Validate() error {
 type GatewayConfig struct {
 	Host string `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
 	Port int    `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	// FailoverAlertMinutes marks /ready as failing once the primary model's
+	// provider has been failing over for this long. A provider that is out of
+	// cooldown and has not failed for an hour no longer counts as failing.
+	// 0 disables the check.
+	FailoverAlertMinutes int `json:"failover_alert_minutes,omitempty" env:"PICOCLAW_GATEWAY_FAILOVER_ALERT_MINUTES"`
 }
 
 type BraveConfig struct {
@@ -528,11 +640,48 @@ type WebToolsConfig struct {
 
 type CronToolsConfig struct {
 	ExecTimeoutMinutes int `json:"exec_timeout_minutes" env:"PICOCLAW_TOOLS_CRON_EXEC_TIMEOUT_MINUTES"` // 0 means no timeout
+	// Timezone is the IANA time zone for cron expressions when the chat has
+	// none set; empty uses the server's local time zone.
+	Timezone string `json:"timezone,omitempty" env:"PICOCLAW_TOOLS_CRON_TIMEZONE"`
+	// AlertAfterFailures sends an alert once a job has failed this many runs
+	// in a row; 0 disables alerts. Alerts go to AlertChannel/AlertChatID, or
+	// to the job's own chat when those are empty.
+	AlertAfterFailures int    `json:"alert_after_failures" env:"PICOCLAW_TOOLS_CRON_ALERT_AFTER_FAILURES"`
+	AlertChannel       string `json:"alert_channel,omitempty" env:"PICOCLAW_TOOLS_CRON_ALERT_CHANNEL"`
+	AlertChatID        string `json:"alert_chat_id,omitempty" env:"PICOCLAW_TOOLS_CRON_ALERT_CHAT_ID"`
+	// MaxConcurrentJobs bounds the jobs running at once.
+	MaxConcurrentJobs int `json:"max_concurrent_jobs" env:"PICOCLAW_TOOLS_CRON_MAX_CONCURRENT_JOBS"`
+	// JobTimeoutMinutes bounds a whole job run, for jobs without their own
+	// timeout; 0 means no limit.
+	JobTimeoutMinutes int `json:"job_timeout_minutes" env:"PICOCLAW_TOOLS_CRON_JOB_TIMEOUT_MINUTES"`
 }
 
 type ExecConfig struct {
-	EnableDenyPatterns bool     `json:"enable_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"`
-	CustomDenyPatterns []string `json:"custom_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"`
+	EnableDenyPatterns     bool              `json:"enable_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"`
+	CustomDenyPatterns     []string          `json:"custom_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"`
+	Sandbox                ExecSandboxConfig `json:"sandbox"`
+	MaxBackgroundProcesses int               `json:"max_background_processes" env:"PICOCLAW_TOOLS_EXEC_MAX_BACKGROUND_PROCESSES"`
+	BackgroundOutputKB     int               `json:"background_output_kb" env:"PICOCLAW_TOOLS_EXEC_BACKGROUND_OUTPUT_KB"`
+	// BackgroundMaxRuntimeMinutes stops background processes that run
+	// longer; 0 means no limit.
+	BackgroundMaxRuntimeMinutes int `json:"background_max_runtime_minutes" env:"PICOCLAW_TOOLS_EXEC_BACKGROUND_MAX_RUNTIME_MINUTES"`
+}
+
+// ExecSandboxConfig runs exec commands in a Linux namespace sandbox: the
+// system is mounted read-only, only the workspace and WritablePaths are
+// writable, and a seccomp filter blocks dangerous system calls. When the
+// kernel does not allow unprivileged user namespaces, exec falls back to the
+// deny-pattern guard. Limits of 0 are unlimited; MemoryMB caps address space.
+// MaxProcesses is RLIMIT_NPROC, which counts every process of the user
+// PicoClaw runs as, not only those in the sandbox.
+type ExecSandboxConfig struct {
+	Enabled       bool     `json:"enabled"         env:"PICOCLAW_TOOLS_EXEC_SANDBOX_ENABLED"`
+	DenyNetwork   bool     `json:"deny_network"    env:"PICOCLAW_TOOLS_EXEC_SANDBOX_DENY_NETWORK"`
+	CPUSeconds    int      `json:"cpu_seconds"     env:"PICOCLAW_TOOLS_EXEC_SANDBOX_CPU_SECONDS"`
+	MemoryMB      int      `json:"memory_mb"       env:"PICOCLAW_TOOLS_EXEC_SANDBOX_MEMORY_MB"`
+	MaxProcesses  int      `json:"max_processes"   env:"PICOCLAW_TOOLS_EXEC_SANDBOX_MAX_PROCESSES"`
+	ReadOnlyPaths []string `json:"read_only_paths" env:"PICOCLAW_TOOLS_EXEC_SANDBOX_READ_ONLY_PATHS"`
+	WritablePaths []string `json:"writable_paths"  env:"PICOCLAW_TOOLS_EXEC_SANDBOX_WRITABLE_PATHS"`
 }
 
 type MediaCleanupConfig struct {
@@ -542,11 +691,179 @@ type MediaCleanupConfig struct {
 }
 
 type ToolsConfig struct {
-	Web          WebToolsConfig     `json:"web"`
-	Cron         CronToolsConfig    `json:"cron"`
-	Exec         ExecConfig         `json:"exec"`
-	Skills       SkillsToolsConfig  `json:"skills"`
-	MediaCleanup MediaCleanupConfig `json:"media_cleanup"`
+	Web          WebToolsConfig         `json:"web"`
+	Cron         CronToolsConfig        `json:"cron"`
+	Exec         ExecConfig             `json:"exec"`
+	Skills       SkillsToolsConfig      `json:"skills"`
+	MediaCleanup MediaCleanupConfig     `json:"media_cleanup"`
+	ImageGen     ImageGenToolConfig     `json:"image_gen"`
+	MCP          MCPConfig              `json:"mcp"`
+	Approval     ApprovalConfig         `json:"approval"`
+	History      FileHistoryConfig      `json:"history"`
+	Network      NetworkConfig          `json:"network"`
+	GPIO         GPIOToolsConfig        `json:"gpio"`
+	PWM          PWMToolsConfig         `json:"pwm"`
+	ADC          ADCToolsConfig         `json:"adc"`
+	Serial       SerialToolsConfig      `json:"serial"`
+	MQTT         MQTTToolsConfig        `json:"mqtt"`
+	Browser      BrowserToolsConfig     `json:"browser"`
+	HTTPRequest  HTTPRequestToolsConfig `json:"http_request"`
+}
+
+// GPIOToolsConfig enables the gpio tool for the listed lines only. Lines maps
+// a chip name ("gpiochip0") to line offsets.
+type GPIOToolsConfig struct {
+	Enabled bool             `json:"enabled" env:"PICOCLAW_TOOLS_GPIO_ENABLED"`
+	Lines   map[string][]int `json:"lines"`
+}
+
+// PWMToolsConfig enables the pwm tool for the listed channels only. Channels
+// maps a chip name ("pwmchip0") to channel numbers.
+type PWMToolsConfig struct {
+	Enabled  bool             `json:"enabled"  env:"PICOCLAW_TOOLS_PWM_ENABLED"`
+	Channels map[string][]int `json:"channels"`
+}
+
+// SerialToolsConfig enables the serial tool for ports matching AllowedPorts,
+// glob patterns such as "/dev/ttyUSB*" or "/dev/serial/by-id/*".
+type SerialToolsConfig struct {
+	Enabled      bool     `json:"enabled"       env:"PICOCLAW_TOOLS_SERIAL_ENABLED"`
+	AllowedPorts []string `json:"allowed_ports" env:"PICOCLAW_TOOLS_SERIAL_ALLOWED_PORTS"`
+	DefaultBaud  int      `json:"default_baud"  env:"PICOCLAW_TOOLS_SERIAL_DEFAULT_BAUD"`
+}
+
+// MQTTToolsConfig enables the mqtt_publish tool, which publishes through the
+// broker configured under channels.mqtt. AllowedTopics are topic filters such
+// as "home/+/set"; the tool can publish to any topic when it is empty.
+type MQTTToolsConfig struct {
+	Enabled       bool     `json:"enabled"        env:"PICOCLAW_TOOLS_MQTT_ENABLED"`
+	AllowedTopics []string `json:"allowed_topics" env:"PICOCLAW_TOOLS_MQTT_ALLOWED_TOPICS"`
+}
+
+// BrowserToolsConfig enables the browser tool. It launches a headless
+// Chromium found in PATH (or BinaryPath), or connects to the browser at
+// CDPURL. Tabs idle for IdleTimeout seconds are closed.
+type BrowserToolsConfig struct {
+	Enabled     bool   `json:"enabled"      env:"PICOCLAW_TOOLS_BROWSER_ENABLED"`
+	CDPURL      string `json:"cdp_url"      env:"PICOCLAW_TOOLS_BROWSER_CDP_URL"`
+	BinaryPath  string `json:"binary_path"  env:"PICOCLAW_TOOLS_BROWSER_BINARY_PATH"`
+	IdleTimeout int    `json:"idle_timeout" env:"PICOCLAW_TOOLS_BROWSER_IDLE_TIMEOUT"`
+}
+
+// HTTPRequestToolsConfig enables the http_request tool. Profiles are named
+// credentials for APIs the agent may call; the tool adds their secrets to
+// requests, so they never appear in the conversation.
+type HTTPRequestToolsConfig struct {
+	Enabled        bool                         `json:"enabled"         env:"PICOCLAW_TOOLS_HTTP_REQUEST_ENABLED"`
+	MaxResponseKB  int                          `json:"max_response_kb" env:"PICOCLAW_TOOLS_HTTP_REQUEST_MAX_RESPONSE_KB"`
+	TimeoutSeconds int                          `json:"timeout_seconds" env:"PICOCLAW_TOOLS_HTTP_REQUEST_TIMEOUT_SECONDS"`
+	Profiles       map[string]HTTPProfileConfig `json:"profiles,omitempty"`
+}
+
+// HTTPProfileConfig is one set of credentials. They are only sent to
+// AllowedHosts, which default to the host of BaseURL. Those hosts may be on
+// private networks, since they were listed explicitly. Credentials go over
+// https and to the listed or default port only, unless AllowHTTP is set.
+type HTTPProfileConfig struct {
+	BaseURL      string            `json:"base_url,omitempty"`
+	AllowedHosts []string          `json:"allowed_hosts,omitempty"` // "host", "host:port" or "scheme://host:port"
+	AllowHTTP    bool              `json:"allow_http,omitempty"`    // send credentials unencrypted over http
+	Headers      map[string]string `json:"headers,omitempty"`
+	BearerToken  string            `json:"bearer_token,omitempty"`
+	Username     string            `json:"username,omitempty"` // HTTP basic auth
+	Password     string            `json:"password,omitempty"`
+	Query        map[string]string `json:"query,omitempty"`  // e.g. an API key parameter
+	Agents       []string          `json:"agents,omitempty"` // agent IDs that may use it; empty means all
+}
+
+// ADCToolsConfig enables the adc tool for the listed channels only. Channels
+// maps an IIO device ("iio:device0") to channel names ("voltage0").
+type ADCToolsConfig struct {
+	Enabled  bool                `json:"enabled"  env:"PICOCLAW_TOOLS_ADC_ENABLED"`
+	Channels map[string][]string `json:"channels"`
+}
+
+// NetworkConfig is the outbound network policy for web_fetch and web search.
+// Loopback, private, link-local and shared address ranges are blocked unless
+// AllowPrivate is set; cloud metadata addresses stay blocked unless listed in
+// AllowedCIDRs. DeniedCIDRs win over everything else. Domains match
+// themselves and their subdomains. Responses are cut off at MaxResponseKB.
+type NetworkConfig struct {
+	AllowPrivate   bool     `json:"allow_private"   env:"PICOCLAW_TOOLS_NETWORK_ALLOW_PRIVATE"`
+	AllowedCIDRs   []string `json:"allowed_cidrs"   env:"PICOCLAW_TOOLS_NETWORK_ALLOWED_CIDRS"`
+	DeniedCIDRs    []string `json:"denied_cidrs"    env:"PICOCLAW_TOOLS_NETWORK_DENIED_CIDRS"`
+	AllowedDomains []string `json:"allowed_domains" env:"PICOCLAW_TOOLS_NETWORK_ALLOWED_DOMAINS"`
+	DeniedDomains  []string `json:"denied_domains"  env:"PICOCLAW_TOOLS_NETWORK_DENIED_DOMAINS"`
+	MaxResponseKB  int      `json:"max_response_kb" env:"PICOCLAW_TOOLS_NETWORK_MAX_RESPONSE_KB"`
+}
+
+// FileHistoryConfig keeps earlier versions of files the agent writes in
+// .history under the workspace. Versions beyond MaxVersions per file, older
+// than MaxAgeDays or over MaxSizeMB in total are pruned, oldest first; the
+// newest version of each file is always kept. Files larger than MaxFileSizeKB
+// are not versioned.
+type FileHistoryConfig struct {
+	Enabled       bool `json:"enabled"          env:"PICOCLAW_TOOLS_HISTORY_ENABLED"`
+	MaxVersions   int  `json:"max_versions"     env:"PICOCLAW_TOOLS_HISTORY_MAX_VERSIONS"`
+	MaxAgeDays    int  `json:"max_age_days"     env:"PICOCLAW_TOOLS_HISTORY_MAX_AGE_DAYS"`
+	MaxSizeMB     int  `json:"max_size_mb"      env:"PICOCLAW_TOOLS_HISTORY_MAX_SIZE_MB"`
+	MaxFileSizeKB int  `json:"max_file_size_kb" env:"PICOCLAW_TOOLS_HISTORY_MAX_FILE_SIZE_KB"`
+}
+
+// ApprovalConfig decides which tool calls need a human to approve them first.
+// Each call gets a policy: "always" runs it, "never" rejects it and "ask"
+// sends an approval prompt to the originating chat. Rules are checked in
+// order and match when Pattern (a regular expression) matches any string
+// argument of the call; otherwise Tools, then Default, decide. AuditLog
+// defaults to ~/.picoclaw/approvals.jsonl, outside every agent workspace.
+type ApprovalConfig struct {
+	Enabled        bool              `json:"enabled"                  env:"PICOCLAW_TOOLS_APPROVAL_ENABLED"`
+	Default        string            `json:"default"                  env:"PICOCLAW_TOOLS_APPROVAL_DEFAULT"`
+	TimeoutSeconds int               `json:"timeout_seconds"          env:"PICOCLAW_TOOLS_APPROVAL_TIMEOUT_SECONDS"`
+	AuditLog       string            `json:"audit_log,omitempty"      env:"PICOCLAW_TOOLS_APPROVAL_AUDIT_LOG"`
+	Tools          map[string]string `json:"tools,omitempty"`
+	Rules          []ApprovalRule    `json:"rules,omitempty"`
+}
+
+// ApprovalRule applies Policy to calls of Tool ("" or "*" for any tool) whose
+// arguments match Pattern. An empty Pattern matches every call of the tool.
+type ApprovalRule struct {
+	Tool    string `json:"tool,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Policy  string `json:"policy"`
+}
+
+// ImageGenToolConfig configures the image_generate tool. Model refers to a
+// model_name in model_list whose protocol serves an images API.
+type ImageGenToolConfig struct {
+	Enabled bool   `json:"enabled"         env:"PICOCLAW_TOOLS_IMAGE_GEN_ENABLED"`
+	Model   string `json:"model"           env:"PICOCLAW_TOOLS_IMAGE_GEN_MODEL"`
+	Size    string `json:"size,omitempty"  env:"PICOCLAW_TOOLS_IMAGE_GEN_SIZE"` // default size, e.g. "1024x1024"
+	MaxN    int    `json:"max_n,omitempty" env:"PICOCLAW_TOOLS_IMAGE_GEN_MAX_N"`
+}
+
+// MCPConfig lists external Model Context Protocol servers whose tools are
+// registered next to the built-in ones.
+type MCPConfig struct {
+	Enabled bool                       `json:"enabled"           env:"PICOCLAW_TOOLS_MCP_ENABLED"`
+	Servers map[string]MCPServerConfig `json:"servers,omitempty"`
+}
+
+// MCPServerConfig describes one MCP server. Stdio servers set Command; remote
+// servers set URL and use the streamable HTTP transport unless Transport is "sse".
+type MCPServerConfig struct {
+	Disabled       bool              `json:"disabled,omitempty"`
+	Transport      string            `json:"transport,omitempty"` // stdio, http or sse; inferred when empty
+	Command        string            `json:"command,omitempty"`
+	Args           []string          `json:"args,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	Dir            string            `json:"dir,omitempty"`
+	URL            string            `json:"url,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	ToolPrefix     *string           `json:"tool_prefix,omitempty"` // default "mcp_<name>_"; "" disables prefixing
+	Tools          []string          `json:"tools,omitempty"`       // server tool names to expose; empty means all
+	Agents         []string          `json:"agents,omitempty"`      // agent IDs that get the tools; empty means all
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"`
 }
 
 type SkillsToolsConfig struct {
@@ -729,6 +1046,12 @@ func (c *Config) GetModelConfig(modelName string) (*ModelConfig, error) {
 	return &matches[idx], nil
 }
 
+// GetModelConfigs returns copies of every ModelConfig entry with the given
+// model_name, in config order. Used to build per-request load balancers.
+func (c *Config) GetModelConfigs(modelName string) []ModelConfig {
+	return c.findMatches(modelName)
+}
+
 // findMatches finds all ModelConfig entries with the given model_name.
 func (c *Config) findMatches(modelName string) []ModelConfig {
 	var matches []ModelConfig
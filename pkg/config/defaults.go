@@ -130,6 +130,14 @@ func DefaultConfig() *Config {
 				MaxConnections: 100,
 				AllowFrom:      FlexibleStringSlice{},
 			},
+			MQTT: MQTTConfig{
+				Enabled:    false,
+				Broker:     "tcp://127.0.0.1:1883",
+				Topics:     []string{"picoclaw/in/#"},
+				ReplyTopic: "{topic}/reply",
+				QoS:        1,
+				AllowFrom:  FlexibleStringSlice{},
+			},
 		},
 		Providers: ProvidersConfig{
 			OpenAI: OpenAIProviderConfig{WebSearch: true},
@@ -289,8 +297,9 @@ func DefaultConfig() *Config {
 			},
 		},
 		Gateway: GatewayConfig{
-			Host: "127.0.0.1",
-			Port: 18790,
+			Host:                 "127.0.0.1",
+			Port:                 18790,
+			FailoverAlertMinutes: 60,
 		},
 		Tools: ToolsConfig{
 			MediaCleanup: MediaCleanupConfig{
@@ -298,6 +307,11 @@ func DefaultConfig() *Config {
 				MaxAge:   30,
 				Interval: 5,
 			},
+			ImageGen: ImageGenToolConfig{
+				Enabled: false,
+				Size:    "1024x1024",
+				MaxN:    4,
+			},
 			Web: WebToolsConfig{
 				Proxy: "",
 				Brave: BraveConfig{
@@ -317,9 +331,15 @@ func DefaultConfig() *Config {
 			},
 			Cron: CronToolsConfig{
 				ExecTimeoutMinutes: 5,
+				AlertAfterFailures: 3,
+				MaxConcurrentJobs:  2,
+				JobTimeoutMinutes:  30,
 			},
 			Exec: ExecConfig{
-				EnableDenyPatterns: true,
+				EnableDenyPatterns:          true,
+				MaxBackgroundProcesses:      4,
+				BackgroundOutputKB:          64,
+				BackgroundMaxRuntimeMinutes: 120,
 			},
 			Skills: SkillsToolsConfig{
 				Registries: SkillsRegistriesConfig{
@@ -334,6 +354,33 @@ func DefaultConfig() *Config {
 					TTLSeconds: 300,
 				},
 			},
+			Approval: ApprovalConfig{
+				Enabled:        false,
+				Default:        "always",
+				TimeoutSeconds: 300,
+			},
+			History: FileHistoryConfig{
+				Enabled:       true,
+				MaxVersions:   20,
+				MaxAgeDays:    30,
+				MaxSizeMB:     16,
+				MaxFileSizeKB: 512,
+			},
+			Network: NetworkConfig{
+				AllowPrivate:  false,
+				MaxResponseKB: 4096,
+			},
+			Serial: SerialToolsConfig{
+				AllowedPorts: []string{"/dev/ttyUSB*", "/dev/ttyACM*", "/dev/serial/by-id/*"},
+				DefaultBaud:  115200,
+			},
+			Browser: BrowserToolsConfig{
+				IdleTimeout: 300,
+			},
+			HTTPRequest: HTTPRequestToolsConfig{
+				MaxResponseKB:  1024,
+				TimeoutSeconds: 30,
+			},
 		},
 		Heartbeat: HeartbeatConfig{
 			Enabled:  true,
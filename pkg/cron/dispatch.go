@@ -0,0 +1,155 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Overlap policies decide what happens when a job is due while its previous
+// run is still going.
+const (
+	OverlapSkip  = "skip"  // drop the new run (the default)
+	OverlapQueue = "queue" // start the new run when the current one ends
+	OverlapAllow = "allow" // run both at once
+)
+
+const (
+	// defaultConcurrency is how many jobs run at once unless SetConcurrency
+	// says otherwise.
+	defaultConcurrency = 2
+	// stopTimeout is how long Stop waits for cancelled jobs to return.
+	stopTimeout = 5 * time.Second
+)
+
+// Validate checks the job's schedule and run options.
+func (job *CronJob) Validate() error {
+	if err := job.Schedule.Validate(); err != nil {
+		return err
+	}
+	switch job.Overlap {
+	case "", OverlapSkip, OverlapQueue, OverlapAllow:
+	default:
+		return fmt.Errorf("unknown overlap policy %q (use %s, %s or %s)",
+			job.Overlap, OverlapSkip, OverlapQueue, OverlapAllow)
+	}
+	if job.TimeoutSec < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if job.Retry != nil && job.Retry.MaxRetries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	return nil
+}
+
+func (job *CronJob) overlapPolicy() string {
+	if job.Overlap == "" {
+		return OverlapSkip
+	}
+	return job.Overlap
+}
+
+// SetConcurrency sets how many jobs may run at once. It must be called
+// before Start.
+func (cs *CronService) SetConcurrency(n int) {
+	if n <= 0 {
+		n = defaultConcurrency
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.slots = make(chan struct{}, n)
+}
+
+// SetJobTimeout sets the timeout for runs of jobs without their own. Zero
+// means no timeout.
+func (cs *CronService) SetJobTimeout(d time.Duration) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.jobTimeout = d
+}
+
+// startRunUnsafe marks a due job as running and moves its schedule on to the
+// following run.
+func (cs *CronService) startRunUnsafe(job *CronJob, nowMS int64) {
+	cs.active[job.ID]++
+	job.State.Queued = false
+	switch {
+	case job.State.PendingRuns > 0:
+		job.State.PendingRuns--
+		job.State.NextRunAtMS = &nowMS
+	case job.Schedule.Kind == "at":
+		job.State.NextRunAtMS = nil
+	default:
+		job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, nowMS)
+	}
+}
+
+func (cs *CronService) releaseUnsafe(jobID string) {
+	if cs.active[jobID] <= 1 {
+		delete(cs.active, jobID)
+	} else {
+		cs.active[jobID]--
+	}
+}
+
+// dispatch runs a started job on the worker pool.
+func (cs *CronService) dispatch(ctx context.Context, jobID string) {
+	cs.mu.RLock()
+	slots := cs.slots
+	cs.mu.RUnlock()
+
+	cs.wg.Add(1)
+	go func() {
+		defer cs.wg.Done()
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			cs.abandonRun(jobID)
+			return
+		}
+		defer func() { <-slots }()
+		cs.executeJobByID(ctx, jobID, false)
+	}()
+}
+
+// abandonRun gives back a run that never started because the service
+// stopped, so it is handled as missed on the next start.
+func (cs *CronService) abandonRun(jobID string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.releaseUnsafe(jobID)
+	for i := range cs.store.Jobs {
+		job := &cs.store.Jobs[i]
+		if job.ID == jobID {
+			now := time.Now().UnixMilli()
+			job.State.NextRunAtMS = &now
+			if err := cs.saveStoreUnsafe(); err != nil {
+				log.Printf("[cron] failed to save store: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// RunJob runs a job now, whether or not it is due or enabled, and waits for
+// it to finish. The run is recorded in the job's history but does not
+// change its schedule.
+func (cs *CronService) RunJob(ctx context.Context, jobID string) (CronRun, error) {
+	cs.mu.Lock()
+	found := false
+	for i := range cs.store.Jobs {
+		if cs.store.Jobs[i].ID == jobID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		cs.mu.Unlock()
+		return CronRun{}, fmt.Errorf("job %s not found", jobID)
+	}
+	cs.active[jobID]++
+	cs.mu.Unlock()
+
+	return cs.executeJobByID(ctx, jobID, true), nil
+}
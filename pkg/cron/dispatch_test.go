@@ -0,0 +1,234 @@
+package cron
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingService returns a started service whose jobs block until release
+// is closed or their context ends. started receives the ID of each run.
+func blockingService(t *testing.T) (cs *CronService, started chan string, release chan struct{}) {
+	t.Helper()
+	started = make(chan string, 10)
+	release = make(chan struct{})
+	handler := func(ctx context.Context, job *CronJob) (JobResult, error) {
+		started <- job.ID
+		if job.Name == "fast" {
+			return JobResult{}, nil
+		}
+		select {
+		case <-release:
+			return JobResult{}, nil
+		case <-ctx.Done():
+			return JobResult{}, ctx.Err()
+		}
+	}
+	cs = NewCronService(filepath.Join(t.TempDir(), "jobs.json"), handler)
+	if err := cs.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(cs.Stop)
+	return cs, started, release
+}
+
+func addDueJob(t *testing.T, cs *CronService, name, overlap string) string {
+	t.Helper()
+	job, err := cs.AddJob(name, CronSchedule{Kind: "every", EveryMS: int64Ptr(3600000)}, "hi", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+	job.Overlap = overlap
+	job.State.NextRunAtMS = int64Ptr(time.Now().UnixMilli())
+	if err := cs.UpdateJob(job); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+	return job.ID
+}
+
+func makeDue(t *testing.T, cs *CronService, jobID string) {
+	t.Helper()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for i := range cs.store.Jobs {
+		if cs.store.Jobs[i].ID == jobID {
+			cs.store.Jobs[i].State.NextRunAtMS = int64Ptr(time.Now().UnixMilli())
+		}
+	}
+}
+
+func waitStarted(t *testing.T, started chan string, want string) {
+	t.Helper()
+	select {
+	case id := <-started:
+		if id != want {
+			t.Fatalf("started %s, want %s", id, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("job %s did not start", want)
+	}
+}
+
+func TestCheckJobsDoesNotWaitForSlowJobs(t *testing.T) {
+	cs, started, release := blockingService(t)
+	defer close(release)
+
+	slow := addDueJob(t, cs, "slow", "")
+	cs.checkJobs()
+	waitStarted(t, started, slow)
+
+	fast := addDueJob(t, cs, "fast", "")
+	cs.checkJobs()
+	waitStarted(t, started, fast)
+}
+
+func TestOverlapPolicies(t *testing.T) {
+	cs, started, release := blockingService(t)
+
+	skip := addDueJob(t, cs, "skip", OverlapSkip)
+	queue := addDueJob(t, cs, "queue", OverlapQueue)
+	cs.checkJobs()
+	got := map[string]bool{<-started: true, <-started: true}
+	if !got[skip] || !got[queue] {
+		t.Fatalf("started %v", got)
+	}
+
+	makeDue(t, cs, skip)
+	makeDue(t, cs, queue)
+	cs.checkJobs()
+	select {
+	case id := <-started:
+		t.Fatalf("job %s started while its previous run was going", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for _, job := range cs.ListJobs(true) {
+		due := *job.State.NextRunAtMS <= time.Now().UnixMilli()
+		if job.ID == skip && due {
+			t.Error("skip: run should have moved to the next schedule")
+		}
+		if job.ID == queue && !due {
+			t.Error("queue: run should still be due")
+		}
+	}
+
+	close(release)
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		cs.checkJobs()
+		select {
+		case id := <-started:
+			if id != queue {
+				t.Fatalf("started %s, want queued job %s", id, queue)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queued run did not start")
+		}
+	}
+}
+
+// TestQueuedRunIsNotAMisfire checks that a run held back behind a run that
+// takes longer than misfireGrace still starts, even with misfire=skip.
+func TestQueuedRunIsNotAMisfire(t *testing.T) {
+	cs, started, release := blockingService(t)
+
+	queue := addDueJob(t, cs, "queue", OverlapQueue)
+	cs.mu.Lock()
+	for i := range cs.store.Jobs {
+		if cs.store.Jobs[i].ID == queue {
+			cs.store.Jobs[i].Schedule.Misfire = MisfireSkip
+		}
+	}
+	cs.mu.Unlock()
+	cs.checkJobs()
+	waitStarted(t, started, queue)
+
+	// The next run comes due and waits while the current one keeps going
+	// for more than a minute.
+	makeDue(t, cs, queue)
+	cs.checkJobs()
+	cs.mu.Lock()
+	for i := range cs.store.Jobs {
+		if cs.store.Jobs[i].ID == queue {
+			cs.store.Jobs[i].State.NextRunAtMS = int64Ptr(time.Now().Add(-2 * time.Minute).UnixMilli())
+		}
+	}
+	cs.mu.Unlock()
+
+	close(release)
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		cs.checkJobs()
+		select {
+		case id := <-started:
+			if id != queue {
+				t.Fatalf("started %s, want queued job %s", id, queue)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queued run was dropped as a misfire")
+		}
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	wait := func(ctx context.Context, _ *CronJob) (JobResult, error) {
+		<-ctx.Done()
+		return JobResult{}, ctx.Err()
+	}
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), wait)
+	cs.SetJobTimeout(50 * time.Millisecond)
+	job, err := cs.AddJob("job", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+	next := *job.State.NextRunAtMS
+
+	run, err := cs.RunJob(context.Background(), job.ID)
+	if err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	if run.Status != "error" || !strings.Contains(run.Error, "timed out") || !run.Manual {
+		t.Errorf("run = %+v, want a manual timed out run", run)
+	}
+	if j := cs.ListJobs(true)[0]; *j.State.NextRunAtMS != next || j.State.ConsecutiveFailures != 0 {
+		t.Errorf("manual run changed the schedule: %+v", j.State)
+	}
+
+	if _, err := cs.RunJob(context.Background(), "missing"); err == nil {
+		t.Error("RunJob of a missing job should fail")
+	}
+}
+
+func TestStopCancelsRunningJobs(t *testing.T) {
+	cs, started, _ := blockingService(t)
+	id := addDueJob(t, cs, "slow", "")
+	cs.checkJobs()
+	waitStarted(t, started, id)
+
+	cs.Stop()
+
+	runs, _ := cs.History(id)
+	if len(runs) != 1 || !strings.Contains(runs[0].Error, "cancelled") {
+		t.Errorf("history = %+v, want one cancelled run", runs)
+	}
+}
+
+func TestJobValidate(t *testing.T) {
+	job := CronJob{Schedule: CronSchedule{Kind: "every", EveryMS: int64Ptr(1000)}, Overlap: "sometimes"}
+	if err := job.Validate(); err == nil {
+		t.Error("expected error for unknown overlap policy")
+	}
+	job.Overlap = OverlapQueue
+	job.TimeoutSec = -1
+	if err := job.Validate(); err == nil {
+		t.Error("expected error for negative timeout")
+	}
+}
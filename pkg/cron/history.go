@@ -0,0 +1,102 @@
+package cron
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxRunHistory bounds the runs kept per job.
+	maxRunHistory = 20
+	// maxOutputExcerpt bounds the output kept per run, in bytes.
+	maxOutputExcerpt = 500
+
+	defaultRetryBackoff = 30 * time.Second
+	maxRetryBackoff     = time.Hour
+)
+
+// JobResult is what a JobHandler reports about a run.
+type JobResult struct {
+	Output           string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// CronRun records one run of a job.
+type CronRun struct {
+	StartedAtMS      int64  `json:"startedAtMs"`
+	EndedAtMS        int64  `json:"endedAtMs"`
+	DurationMS       int64  `json:"durationMs"`
+	Status           string `json:"status"`            // "ok" or "error"
+	Attempt          int    `json:"attempt,omitempty"` // retry number; 0 is the scheduled run
+	Manual           bool   `json:"manual,omitempty"`  // started with RunJob
+	Output           string `json:"output,omitempty"`  // excerpt
+	Error            string `json:"error,omitempty"`
+	PromptTokens     int    `json:"promptTokens,omitempty"`
+	CompletionTokens int    `json:"completionTokens,omitempty"`
+}
+
+// RetryPolicy retries failed runs with exponential backoff.
+type RetryPolicy struct {
+	MaxRetries int `json:"maxRetries"`
+	// BackoffMS is the delay before the first retry (default 30s). It doubles
+	// with every retry, up to an hour.
+	BackoffMS int64 `json:"backoffMs,omitempty"`
+}
+
+// delay returns how long to wait before retry number attempt (from 1).
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := defaultRetryBackoff
+	if p.BackoffMS > 0 {
+		d = time.Duration(p.BackoffMS) * time.Millisecond
+	}
+	for i := 1; i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryBackoff)
+}
+
+// AlertHandler is called when a job has failed failures runs in a row.
+type AlertHandler func(job *CronJob, failures int)
+
+// SetOnAlert calls handler once a job has failed after runs in a row,
+// retries included. after <= 0 disables alerts.
+func (cs *CronService) SetOnAlert(after int, handler AlertHandler) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.alertAfter = after
+	cs.onAlert = handler
+}
+
+// History returns the recorded runs of a job, oldest first.
+func (cs *CronService) History(jobID string) ([]CronRun, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	for i := range cs.store.Jobs {
+		if cs.store.Jobs[i].ID == jobID {
+			return append([]CronRun(nil), cs.store.Jobs[i].History...), true
+		}
+	}
+	return nil, false
+}
+
+// recordRun appends run to the job's history, dropping the oldest runs.
+func recordRun(job *CronJob, run CronRun) {
+	run.Output = excerpt(run.Output)
+	run.Error = excerpt(run.Error)
+	job.History = append(job.History, run)
+	if n := len(job.History) - maxRunHistory; n > 0 {
+		job.History = append([]CronRun(nil), job.History[n:]...)
+	}
+}
+
+func excerpt(s string) string {
+	if len(s) <= maxOutputExcerpt {
+		return s
+	}
+	cut := maxOutputExcerpt
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
@@ -0,0 +1,140 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runDue starts a run of the job as checkJobs would and waits for it.
+func runDue(cs *CronService, jobID string) {
+	cs.mu.Lock()
+	for i := range cs.store.Jobs {
+		if cs.store.Jobs[i].ID == jobID {
+			cs.startRunUnsafe(&cs.store.Jobs[i], time.Now().UnixMilli())
+		}
+	}
+	cs.mu.Unlock()
+	cs.executeJobByID(context.Background(), jobID, false)
+}
+
+func TestExecuteJobRecordsHistory(t *testing.T) {
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, *CronJob) (JobResult, error) {
+		return JobResult{Output: strings.Repeat("x", 2*maxOutputExcerpt), PromptTokens: 12, CompletionTokens: 3}, nil
+	})
+	job, err := cs.AddJob("job", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+
+	for range maxRunHistory + 5 {
+		runDue(cs, job.ID)
+	}
+
+	runs, ok := cs.History(job.ID)
+	if !ok {
+		t.Fatal("History: job not found")
+	}
+	if len(runs) != maxRunHistory {
+		t.Fatalf("history has %d runs, want %d", len(runs), maxRunHistory)
+	}
+	run := runs[len(runs)-1]
+	if run.Status != "ok" || run.PromptTokens != 12 || run.CompletionTokens != 3 {
+		t.Errorf("unexpected run: %+v", run)
+	}
+	if len(run.Output) > maxOutputExcerpt+len("…") {
+		t.Errorf("output excerpt has %d bytes", len(run.Output))
+	}
+	if run.EndedAtMS < run.StartedAtMS || run.DurationMS != run.EndedAtMS-run.StartedAtMS {
+		t.Errorf("inconsistent timing: %+v", run)
+	}
+}
+
+func TestExecuteJobRetriesWithBackoff(t *testing.T) {
+	fail := true
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, *CronJob) (JobResult, error) {
+		if fail {
+			return JobResult{}, errors.New("boom")
+		}
+		return JobResult{Output: "done"}, nil
+	})
+	job, err := cs.AddJob("job", CronSchedule{Kind: "cron", Expr: "0 9 * * *"}, "hi", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+	job.Retry = &RetryPolicy{MaxRetries: 2, BackoffMS: 1000}
+	if err := cs.UpdateJob(job); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+
+	nextRun := func() time.Duration {
+		j := cs.ListJobs(true)[0]
+		return time.Until(time.UnixMilli(*j.State.NextRunAtMS))
+	}
+
+	runDue(cs, job.ID)
+	if d := nextRun(); d <= 0 || d > time.Second {
+		t.Errorf("first retry in %s, want about 1s", d)
+	}
+	runDue(cs, job.ID)
+	if d := nextRun(); d <= time.Second || d > 2*time.Second {
+		t.Errorf("second retry in %s, want about 2s", d)
+	}
+	runDue(cs, job.ID)
+	if d := nextRun(); d <= 2*time.Second {
+		t.Errorf("after retries run in %s, want the next scheduled run", d)
+	}
+
+	j := cs.ListJobs(true)[0]
+	if j.State.RetryAttempt != 0 || j.State.ConsecutiveFailures != 1 {
+		t.Errorf("state after exhausted retries: %+v", j.State)
+	}
+	for i, run := range j.History {
+		if run.Attempt != i || run.Status != "error" {
+			t.Errorf("run %d: attempt %d, status %s", i, run.Attempt, run.Status)
+		}
+	}
+
+	fail = false
+	runDue(cs, job.ID)
+	if j := cs.ListJobs(true)[0]; j.State.ConsecutiveFailures != 0 || j.State.LastStatus != "ok" {
+		t.Errorf("state after success: %+v", j.State)
+	}
+}
+
+func TestExecuteJobAlertsAfterConsecutiveFailures(t *testing.T) {
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, *CronJob) (JobResult, error) {
+		return JobResult{}, errors.New("boom")
+	})
+	var alerts []int
+	cs.SetOnAlert(2, func(job *CronJob, failures int) {
+		alerts = append(alerts, failures)
+		if job.State.LastError != "boom" {
+			t.Errorf("alert job has LastError %q", job.State.LastError)
+		}
+	})
+	job, err := cs.AddJob("job", CronSchedule{Kind: "every", EveryMS: int64Ptr(60000)}, "hi", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+
+	for range 4 {
+		runDue(cs, job.ID)
+	}
+	if len(alerts) != 1 || alerts[0] != 2 {
+		t.Errorf("alerts = %v, want one alert after 2 failures", alerts)
+	}
+}
+
+func TestRetryPolicyDelayIsCapped(t *testing.T) {
+	p := &RetryPolicy{MaxRetries: 20}
+	if d := p.delay(1); d != defaultRetryBackoff {
+		t.Errorf("delay(1) = %s, want %s", d, defaultRetryBackoff)
+	}
+	if d := p.delay(20); d != maxRetryBackoff {
+		t.Errorf("delay(20) = %s, want %s", d, maxRetryBackoff)
+	}
+}
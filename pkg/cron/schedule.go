@@ -0,0 +1,167 @@
+package cron
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adhocore/gronx"
+)
+
+// Misfire policies decide what happens to runs that were missed because
+// PicoClaw was not running or the device was asleep.
+const (
+	MisfireSkip    = "skip"     // drop missed runs and wait for the next one
+	MisfireRunOnce = "run_once" // run once to catch up (the default)
+	MisfireRunAll  = "run_all"  // run every missed run, up to MisfireLimit
+)
+
+const (
+	// defaultMisfireLimit caps catch-up runs for MisfireRunAll.
+	defaultMisfireLimit = 10
+	// misfireGrace is how late a run may start before it counts as missed.
+	misfireGrace = time.Minute
+)
+
+// LoadLocation returns the time zone for tz; empty means the server's local
+// time zone.
+func LoadLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", tz)
+	}
+	return loc, nil
+}
+
+// Validate checks that the schedule can be run.
+func (s *CronSchedule) Validate() error {
+	switch s.Kind {
+	case "at":
+		if s.AtMS == nil {
+			return fmt.Errorf("one-time schedule needs a time")
+		}
+	case "every":
+		if s.EveryMS == nil || *s.EveryMS <= 0 {
+			return fmt.Errorf("interval must be positive")
+		}
+	case "cron":
+		if !gronx.New().IsValid(s.Expr) {
+			return fmt.Errorf("invalid cron expression %q", s.Expr)
+		}
+	default:
+		return fmt.Errorf("unknown schedule kind %q", s.Kind)
+	}
+	if _, err := LoadLocation(s.TZ); err != nil {
+		return err
+	}
+	switch s.Misfire {
+	case "", MisfireSkip, MisfireRunOnce, MisfireRunAll:
+	default:
+		return fmt.Errorf("unknown misfire policy %q (use %s, %s or %s)",
+			s.Misfire, MisfireSkip, MisfireRunOnce, MisfireRunAll)
+	}
+	if s.MisfireLimit < 0 {
+		return fmt.Errorf("misfire limit must not be negative")
+	}
+	return nil
+}
+
+func (s *CronSchedule) misfirePolicy() string {
+	if s.Misfire == "" {
+		return MisfireRunOnce
+	}
+	return s.Misfire
+}
+
+func (s *CronSchedule) misfireLimit() int {
+	if s.MisfireLimit > 0 {
+		return s.MisfireLimit
+	}
+	return defaultMisfireLimit
+}
+
+// NextCronTime returns the first time after after that matches expr in the
+// time zone tz. Expressions match wall-clock time: on the day clocks go
+// forward, runs in the skipped hour happen when the clocks jump; when they go
+// back, the repeated hour runs only once.
+func NextCronTime(expr, tz string, after time.Time) (time.Time, error) {
+	loc, err := LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// gronx steps through fields with time.Date, which is ambiguous around
+	// DST changes, so it works on the wall clock expressed in UTC.
+	wall := wallClock(after.In(loc))
+	for range 4 {
+		next, err := gronx.NextTickAfter(expr, wall, false)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if t := fromWallClock(next, loc); t.After(after) {
+			return t, nil
+		}
+		wall = next
+	}
+	return time.Time{}, fmt.Errorf("no run time found for %q", expr)
+}
+
+// wallClock returns the wall-clock reading of t as a UTC time.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// fromWallClock returns the first instant at which clocks in loc show wall.
+// A wall time skipped by a DST change maps to the moment of the change.
+func fromWallClock(wall time.Time, loc *time.Location) time.Time {
+	guess := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+	var candidates []time.Time
+	for _, probe := range []time.Duration{-24 * time.Hour, 24 * time.Hour} {
+		_, offset := guess.Add(probe).Zone()
+		candidates = append(candidates, wall.Add(-time.Duration(offset)*time.Second))
+	}
+	lo, hi := candidates[0], candidates[1]
+	if hi.Before(lo) {
+		lo, hi = hi, lo
+	}
+	for _, c := range []time.Time{lo, hi} {
+		if wallClock(c.In(loc)).Equal(wall) {
+			return c.In(loc)
+		}
+	}
+	// wall falls into a gap: find the first instant past it.
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2).Truncate(time.Second)
+		if wallClock(mid.In(loc)).After(wall) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi.In(loc)
+}
+
+// missedRuns counts the runs of s due from fromMS up to nowMS, at most limit.
+func missedRuns(s *CronSchedule, fromMS, nowMS int64, limit int) int {
+	switch s.Kind {
+	case "every":
+		if s.EveryMS == nil || *s.EveryMS <= 0 {
+			return 1
+		}
+		return int(min((nowMS-fromMS) / *s.EveryMS + 1, int64(limit)))
+	case "cron":
+		count := 1
+		t := time.UnixMilli(fromMS)
+		for count < limit {
+			next, err := NextCronTime(s.Expr, s.TZ, t)
+			if err != nil || next.UnixMilli() > nowMS {
+				break
+			}
+			count++
+			t = next
+		}
+		return count
+	}
+	return 1
+}
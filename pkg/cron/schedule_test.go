@@ -0,0 +1,221 @@
+package cron
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, tz string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	return loc
+}
+
+func TestNextCronTime_TimeZone(t *testing.T) {
+	loc := mustLoad(t, "Asia/Tokyo")
+	after := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC) // 09:00 in Tokyo
+
+	next, err := NextCronTime("0 9 * * *", "Asia/Tokyo", after)
+	if err != nil {
+		t.Fatalf("NextCronTime: %v", err)
+	}
+	want := time.Date(2026, 3, 3, 9, 0, 0, 0, loc)
+	if !next.Equal(want) {
+		t.Errorf("next = %v, want %v", next, want)
+	}
+
+	next, err = NextCronTime("0 9 * * *", "UTC", after)
+	if err != nil {
+		t.Fatalf("NextCronTime: %v", err)
+	}
+	if want := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("next = %v, want %v", next, want)
+	}
+}
+
+func TestNextCronTime_SpringForward(t *testing.T) {
+	loc := mustLoad(t, "America/New_York")
+	// Clocks jump from 02:00 EST to 03:00 EDT on 2026-03-08.
+	after := time.Date(2026, 3, 8, 0, 0, 0, 0, loc)
+
+	next, err := NextCronTime("30 2 * * *", "America/New_York", after)
+	if err != nil {
+		t.Fatalf("NextCronTime: %v", err)
+	}
+	want := time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC) // 03:00 EDT
+	if !next.Equal(want) {
+		t.Errorf("next = %v, want %v", next.UTC(), want)
+	}
+
+	next, err = NextCronTime("30 2 * * *", "America/New_York", next)
+	if err != nil {
+		t.Fatalf("NextCronTime: %v", err)
+	}
+	if want := time.Date(2026, 3, 9, 2, 30, 0, 0, loc); !next.Equal(want) {
+		t.Errorf("next = %v, want %v", next, want)
+	}
+}
+
+func TestNextCronTime_FallBackRunsOnce(t *testing.T) {
+	loc := mustLoad(t, "America/New_York")
+	// 01:00-02:00 happens twice on 2026-11-01.
+	after := time.Date(2026, 11, 1, 0, 0, 0, 0, loc)
+
+	first, err := NextCronTime("30 1 * * *", "America/New_York", after)
+	if err != nil {
+		t.Fatalf("NextCronTime: %v", err)
+	}
+	if want := time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC); !first.Equal(want) { // 01:30 EDT
+		t.Errorf("first = %v, want %v", first.UTC(), want)
+	}
+
+	second, err := NextCronTime("30 1 * * *", "America/New_York", first)
+	if err != nil {
+		t.Fatalf("NextCronTime: %v", err)
+	}
+	if want := time.Date(2026, 11, 2, 1, 30, 0, 0, loc); !second.Equal(want) {
+		t.Errorf("second = %v, want %v", second, want)
+	}
+}
+
+func TestCronScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule CronSchedule
+		wantErr  bool
+	}{
+		{"cron", CronSchedule{Kind: "cron", Expr: "0 9 * * *", TZ: "UTC"}, false},
+		{"bad expr", CronSchedule{Kind: "cron", Expr: "not cron"}, true},
+		{"bad tz", CronSchedule{Kind: "cron", Expr: "0 9 * * *", TZ: "Mars/Olympus"}, true},
+		{"every", CronSchedule{Kind: "every", EveryMS: int64Ptr(1000), Misfire: MisfireRunAll}, false},
+		{"zero interval", CronSchedule{Kind: "every", EveryMS: int64Ptr(0)}, true},
+		{"bad misfire", CronSchedule{Kind: "every", EveryMS: int64Ptr(1000), Misfire: "sometimes"}, true},
+		{"negative limit", CronSchedule{Kind: "every", EveryMS: int64Ptr(1000), MisfireLimit: -1}, true},
+		{"unknown kind", CronSchedule{Kind: "yearly"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// addOverdueJob adds a job whose next run was due an hour ago.
+func addOverdueJob(t *testing.T, cs *CronService, schedule CronSchedule) string {
+	t.Helper()
+	job, err := cs.AddJob("overdue", schedule, "hello", false, "cli", "direct")
+	if err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+	past := time.Now().Add(-time.Hour).UnixMilli()
+	job.State.NextRunAtMS = &past
+	if err := cs.UpdateJob(job); err != nil {
+		t.Fatalf("UpdateJob: %v", err)
+	}
+	return job.ID
+}
+
+func TestRecomputeNextRuns_Misfire(t *testing.T) {
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+	every := func(misfire string) CronSchedule {
+		return CronSchedule{Kind: "every", EveryMS: int64Ptr(10 * 60 * 1000), Misfire: misfire, MisfireLimit: 3}
+	}
+	skipID := addOverdueJob(t, cs, every(MisfireSkip))
+	onceID := addOverdueJob(t, cs, every(""))
+	allID := addOverdueJob(t, cs, every(MisfireRunAll))
+
+	before := time.Now().UnixMilli()
+	cs.mu.Lock()
+	cs.recomputeNextRuns()
+	cs.mu.Unlock()
+
+	jobs := make(map[string]CronJob)
+	for _, job := range cs.ListJobs(true) {
+		jobs[job.ID] = job
+	}
+
+	if next := *jobs[skipID].State.NextRunAtMS; next <= time.Now().UnixMilli() {
+		t.Errorf("skip: next run %d should be in the future", next)
+	}
+	if next := *jobs[onceID].State.NextRunAtMS; next < before || next > time.Now().UnixMilli() {
+		t.Errorf("run_once: next run %d should be now", next)
+	}
+	if got := jobs[onceID].State.PendingRuns; got != 0 {
+		t.Errorf("run_once: pending runs = %d, want 0", got)
+	}
+	// Six runs were missed in the hour; the limit allows three.
+	if got := jobs[allID].State.PendingRuns; got != 2 {
+		t.Errorf("run_all: pending runs = %d, want 2", got)
+	}
+}
+
+func TestMisfireSkipDisablesOneTimeJob(t *testing.T) {
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+	at := time.Now().Add(time.Hour).UnixMilli()
+	id := addOverdueJob(t, cs, CronSchedule{Kind: "at", AtMS: &at, Misfire: MisfireSkip})
+
+	cs.mu.Lock()
+	cs.recomputeNextRuns()
+	cs.mu.Unlock()
+
+	// One-time jobs added through AddJob are deleted after they run, so a
+	// skipped one is removed as well.
+	for _, job := range cs.ListJobs(true) {
+		if job.ID == id {
+			t.Fatalf("skipped one-time job still stored: %+v", job)
+		}
+	}
+}
+
+func TestExecuteJobRunsPendingCatchUps(t *testing.T) {
+	runs := 0
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), func(context.Context, *CronJob) (JobResult, error) {
+		runs++
+		return JobResult{}, nil
+	})
+	id := addOverdueJob(t, cs, CronSchedule{
+		Kind: "every", EveryMS: int64Ptr(10 * 60 * 1000), Misfire: MisfireRunAll, MisfireLimit: 3,
+	})
+	cs.mu.Lock()
+	cs.recomputeNextRuns()
+	cs.mu.Unlock()
+
+	for range 3 {
+		runDue(cs, id)
+	}
+	if runs != 3 {
+		t.Fatalf("runs = %d, want 3", runs)
+	}
+	job := cs.ListJobs(true)[0]
+	if job.State.PendingRuns != 0 || *job.State.NextRunAtMS <= time.Now().UnixMilli() {
+		t.Errorf("after catching up: pending %d, next %d", job.State.PendingRuns, *job.State.NextRunAtMS)
+	}
+}
+
+func TestSetTimezone(t *testing.T) {
+	cs := NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+	if err := cs.SetTimezone("telegram", "42", "Nowhere/Special"); err == nil {
+		t.Fatal("expected error for unknown time zone")
+	}
+	if err := cs.SetTimezone("telegram", "42", "UTC"); err != nil {
+		t.Fatalf("SetTimezone: %v", err)
+	}
+	reloaded := NewCronService(cs.storePath, nil)
+	if got := reloaded.Timezone("telegram", "42"); got != "UTC" {
+		t.Errorf("Timezone = %q, want UTC", got)
+	}
+	if err := reloaded.SetTimezone("telegram", "42", ""); err != nil {
+		t.Fatalf("SetTimezone clear: %v", err)
+	}
+	if got := reloaded.Timezone("telegram", "42"); got != "" {
+		t.Errorf("Timezone after clear = %q, want empty", got)
+	}
+}
@@ -1,9 +1,11 @@
 package cron
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -20,7 +22,13 @@ type CronSchedule struct {
 	AtMS    *int64 `json:"atMs,omitempty"`
 	EveryMS *int64 `json:"everyMs,omitempty"`
 	Expr    string `json:"expr,omitempty"`
-	TZ      string `json:"tz,omitempty"`
+	TZ      string `json:"tz,omitempty"` // IANA time zone for Expr; empty is the server's
+
+	// Misfire is the policy for runs missed while PicoClaw was not running:
+	// MisfireSkip, MisfireRunOnce (default) or MisfireRunAll, which runs at
+	// most MisfireLimit of them (default 10).
+	Misfire      string `json:"misfire,omitempty"`
+	MisfireLimit int    `json:"misfireLimit,omitempty"`
 }
 
 type CronPayload struct {
@@ -37,6 +45,14 @@ type CronJobState struct {
 	LastRunAtMS *int64 `json:"lastRunAtMs,omitempty"`
 	LastStatus  string `json:"lastStatus,omitempty"`
 	LastError   string `json:"lastError,omitempty"`
+	PendingRuns int    `json:"pendingRuns,omitempty"` // catch-up runs left after the current one
+	// Queued marks a due run held back until the previous run finishes; it
+	// is late because of the overlap policy, not missed.
+	Queued bool `json:"queued,omitempty"`
+	// RetryAttempt is the number of the next retry of a failed run; 0 means
+	// the next run is a scheduled one.
+	RetryAttempt        int `json:"retryAttempt,omitempty"`
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
 }
 
 type CronJob struct {
@@ -49,23 +65,41 @@ type CronJob struct {
 	CreatedAtMS    int64        `json:"createdAtMs"`
 	UpdatedAtMS    int64        `json:"updatedAtMs"`
 	DeleteAfterRun bool         `json:"deleteAfterRun"`
+	Retry          *RetryPolicy `json:"retry,omitempty"`
+	TimeoutSec     int          `json:"timeoutSec,omitempty"` // 0 uses the service default
+	Overlap        string       `json:"overlap,omitempty"`    // see OverlapSkip
+	History        []CronRun    `json:"history,omitempty"`    // most recent runs, oldest first
 }
 
 type CronStore struct {
 	Version int       `json:"version"`
 	Jobs    []CronJob `json:"jobs"`
+	// Timezones holds the default time zone of each chat, keyed by
+	// "channel:chatID".
+	Timezones map[string]string `json:"timezones,omitempty"`
 }
 
-type JobHandler func(job *CronJob) (string, error)
+// JobHandler runs a job. ctx is cancelled when the job times out or the
+// service stops.
+type JobHandler func(ctx context.Context, job *CronJob) (JobResult, error)
 
 type CronService struct {
-	storePath string
-	store     *CronStore
-	onJob     JobHandler
-	mu        sync.RWMutex
-	running   bool
-	stopChan  chan struct{}
-	gronx     *gronx.Gronx
+	storePath  string
+	store      *CronStore
+	onJob      JobHandler
+	onAlert    AlertHandler
+	alertAfter int
+	mu         sync.RWMutex
+	running    bool
+	stopChan   chan struct{}
+	gronx      *gronx.Gronx
+
+	ctx        context.Context // cancelled by Stop
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	slots      chan struct{}  // bounds the jobs running at once
+	active     map[string]int // runs in progress per job ID
+	jobTimeout time.Duration
 }
 
 func NewCronService(storePath string, onJob JobHandler) *CronService {
@@ -73,6 +107,9 @@ func NewCronService(storePath string, onJob JobHandler) *CronService {
 		storePath: storePath,
 		onJob:     onJob,
 		gronx:     gronx.New(),
+		ctx:       context.Background(),
+		slots:     make(chan struct{}, defaultConcurrency),
+		active:    make(map[string]int),
 	}
 	// Initialize and load store on creation
 	cs.loadStore()
@@ -96,6 +133,7 @@ func (cs *CronService) Start() error {
 		return fmt.Errorf("failed to save store: %w", err)
 	}
 
+	cs.ctx, cs.cancel = context.WithCancel(context.Background())
 	cs.stopChan = make(chan struct{})
 	cs.running = true
 	go cs.runLoop(cs.stopChan)
@@ -103,11 +141,12 @@ func (cs *CronService) Start() error {
 	return nil
 }
 
+// Stop stops scheduling, cancels the running jobs and waits briefly for them
+// to finish.
 func (cs *CronService) Stop() {
 	cs.mu.Lock()
-	defer cs.mu.Unlock()
-
 	if !cs.running {
+		cs.mu.Unlock()
 		return
 	}
 
@@ -116,6 +155,19 @@ func (cs *CronService) Stop() {
 		close(cs.stopChan)
 		cs.stopChan = nil
 	}
+	cs.cancel()
+	cs.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		cs.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		log.Printf("[cron] jobs still running after %s, not waiting", stopTimeout)
+	}
 }
 
 func (cs *CronService) runLoop(stopChan chan struct{}) {
@@ -143,38 +195,56 @@ func (cs *CronService) checkJobs() {
 	now := time.Now().UnixMilli()
 	var dueJobIDs []string
 
-	// Collect jobs that are due (we need to copy them to execute outside lock)
+	// Collect jobs that are due and advance their schedule before unlocking
+	// to avoid duplicate execution.
+	var skipped []string
 	for i := range cs.store.Jobs {
 		job := &cs.store.Jobs[i]
-		if job.Enabled && job.State.NextRunAtMS != nil && *job.State.NextRunAtMS <= now {
-			dueJobIDs = append(dueJobIDs, job.ID)
+		if !job.Enabled || job.State.NextRunAtMS == nil || *job.State.NextRunAtMS > now {
+			continue
 		}
-	}
-
-	// Reset next run for due jobs before unlocking to avoid duplicate execution.
-	dueMap := make(map[string]bool, len(dueJobIDs))
-	for _, jobID := range dueJobIDs {
-		dueMap[jobID] = true
-	}
-	for i := range cs.store.Jobs {
-		if dueMap[cs.store.Jobs[i].ID] {
-			cs.store.Jobs[i].State.NextRunAtMS = nil
+		running := cs.active[job.ID] > 0
+		if running && job.overlapPolicy() != OverlapAllow {
+			// Catch-up runs and retries always wait for the current run.
+			if job.overlapPolicy() == OverlapSkip && job.State.PendingRuns == 0 && job.State.RetryAttempt == 0 {
+				log.Printf("[cron] job %s (%s) is still running, skipping this run", job.Name, job.ID)
+				job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+			} else {
+				job.State.Queued = true
+			}
+			continue
 		}
+		// A run that is far behind was missed, e.g. while the device slept.
+		if !running && !job.State.Queued && *job.State.NextRunAtMS < now-misfireGrace.Milliseconds() && !cs.handleMisfire(job, now) {
+			if !job.Enabled && job.DeleteAfterRun {
+				skipped = append(skipped, job.ID)
+			}
+			continue
+		}
+		cs.startRunUnsafe(job, now)
+		dueJobIDs = append(dueJobIDs, job.ID)
+	}
+	for _, jobID := range skipped {
+		cs.removeJobUnsafe(jobID)
 	}
 
 	if err := cs.saveStoreUnsafe(); err != nil {
 		log.Printf("[cron] failed to save store: %v", err)
 	}
 
+	ctx := cs.ctx
 	cs.mu.Unlock()
 
 	// Execute jobs outside lock.
 	for _, jobID := range dueJobIDs {
-		cs.executeJobByID(jobID)
+		cs.dispatch(ctx, jobID)
 	}
 }
 
-func (cs *CronService) executeJobByID(jobID string) {
+// executeJobByID runs a job that startRunUnsafe marked as running and
+// records the outcome. Manual runs leave retries, failure counts and the
+// schedule alone.
+func (cs *CronService) executeJobByID(ctx context.Context, jobID string, manual bool) CronRun {
 	startTime := time.Now().UnixMilli()
 
 	cs.mu.RLock()
@@ -187,20 +257,41 @@ func (cs *CronService) executeJobByID(jobID string) {
 			break
 		}
 	}
+	timeout := cs.jobTimeout
+	onJob := cs.onJob
 	cs.mu.RUnlock()
 
 	if callbackJob == nil {
-		return
+		cs.mu.Lock()
+		cs.releaseUnsafe(jobID)
+		cs.mu.Unlock()
+		return CronRun{}
+	}
+	if callbackJob.TimeoutSec > 0 {
+		timeout = time.Duration(callbackJob.TimeoutSec) * time.Second
 	}
 
+	var result JobResult
 	var err error
-	if cs.onJob != nil {
-		_, err = cs.onJob(callbackJob)
+	if onJob != nil {
+		runCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			runCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		result, err = onJob(runCtx, callbackJob)
+		switch {
+		case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+			err = fmt.Errorf("timed out after %s", timeout)
+		case ctx.Err() != nil:
+			err = fmt.Errorf("cancelled: %w", ctx.Err())
+		}
+		cancel()
 	}
+	endTime := time.Now().UnixMilli()
 
 	// Now acquire lock to update state
 	cs.mu.Lock()
-	defer cs.mu.Unlock()
+	cs.releaseUnsafe(jobID)
 
 	var job *CronJob
 	for i := range cs.store.Jobs {
@@ -210,37 +301,79 @@ func (cs *CronService) executeJobByID(jobID string) {
 		}
 	}
 	if job == nil {
+		cs.mu.Unlock()
 		log.Printf("[cron] job %s disappeared before state update", jobID)
-		return
+		return CronRun{}
 	}
 
 	job.State.LastRunAtMS = &startTime
 	job.UpdatedAtMS = time.Now().UnixMilli()
 
+	run := CronRun{
+		StartedAtMS:      startTime,
+		EndedAtMS:        endTime,
+		DurationMS:       endTime - startTime,
+		Status:           "ok",
+		Manual:           manual,
+		Output:           result.Output,
+		PromptTokens:     result.PromptTokens,
+		CompletionTokens: result.CompletionTokens,
+	}
+	if !manual {
+		run.Attempt = job.State.RetryAttempt
+	}
 	if err != nil {
 		job.State.LastStatus = "error"
 		job.State.LastError = err.Error()
+		run.Status = "error"
+		run.Error = err.Error()
 	} else {
 		job.State.LastStatus = "ok"
 		job.State.LastError = ""
 	}
-
-	// Compute next run time
-	if job.Schedule.Kind == "at" {
-		if job.DeleteAfterRun {
-			cs.removeJobUnsafe(job.ID)
+	recordRun(job, run)
+
+	var alertJob *CronJob
+	switch {
+	case manual:
+	case err != nil && job.Retry != nil && job.State.RetryAttempt < job.Retry.MaxRetries:
+		job.State.RetryAttempt++
+		delay := job.Retry.delay(job.State.RetryAttempt)
+		next := endTime + delay.Milliseconds()
+		job.State.NextRunAtMS = &next
+		log.Printf("[cron] job %s (%s) failed, retry %d/%d in %s",
+			job.Name, job.ID, job.State.RetryAttempt, job.Retry.MaxRetries, delay)
+	default:
+		job.State.RetryAttempt = 0
+		if err != nil {
+			job.State.ConsecutiveFailures++
+			if cs.alertAfter > 0 && job.State.ConsecutiveFailures == cs.alertAfter {
+				jobCopy := *job
+				alertJob = &jobCopy
+			}
 		} else {
-			job.Enabled = false
-			job.State.NextRunAtMS = nil
+			job.State.ConsecutiveFailures = 0
+		}
+		// A one-time job is done once nothing is left to run.
+		if job.Schedule.Kind == "at" && job.State.NextRunAtMS == nil && cs.active[job.ID] == 0 {
+			if job.DeleteAfterRun {
+				cs.removeJobUnsafe(job.ID)
+			} else {
+				job.Enabled = false
+			}
 		}
-	} else {
-		nextRun := cs.computeNextRun(&job.Schedule, time.Now().UnixMilli())
-		job.State.NextRunAtMS = nextRun
 	}
 
 	if err := cs.saveStoreUnsafe(); err != nil {
 		log.Printf("[cron] failed to save store: %v", err)
 	}
+	onAlert := cs.onAlert
+	cs.mu.Unlock()
+
+	if alertJob != nil && onAlert != nil {
+		onAlert(alertJob, alertJob.State.ConsecutiveFailures)
+	}
+	return run
 }
 
 func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int64 {
@@ -264,9 +397,7 @@ func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int6
 			return nil
 		}
 
-		// Use gronx to calculate next run time
-		now := time.UnixMilli(nowMS)
-		nextTime, err := gronx.NextTickAfter(schedule.Expr, now, false)
+		nextTime, err := NextCronTime(schedule.Expr, schedule.TZ, time.UnixMilli(nowMS))
 		if err != nil {
 			log.Printf("[cron] failed to compute next run for expr '%s': %v", schedule.Expr, err)
 			return nil
@@ -279,14 +410,62 @@ func (cs *CronService) computeNextRun(schedule *CronSchedule, nowMS int64) *int6
 	return nil
 }
 
+// recomputeNextRuns schedules the enabled jobs on start. Runs that were due
+// while PicoClaw was not running are handled by each job's misfire policy.
 func (cs *CronService) recomputeNextRuns() {
 	now := time.Now().UnixMilli()
+	var skipped []string
 	for i := range cs.store.Jobs {
 		job := &cs.store.Jobs[i]
-		if job.Enabled {
-			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+		if !job.Enabled {
+			continue
+		}
+		// Nothing runs before the start, so a queued run was missed too.
+		job.State.Queued = false
+		if job.State.NextRunAtMS != nil && *job.State.NextRunAtMS <= now {
+			if !cs.handleMisfire(job, now) && !job.Enabled && job.DeleteAfterRun {
+				skipped = append(skipped, job.ID)
+			}
+			continue
+		}
+		job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, now)
+	}
+	for _, jobID := range skipped {
+		cs.removeJobUnsafe(jobID)
+	}
+}
+
+// handleMisfire applies the misfire policy to a job whose run is overdue.
+// It reports whether the job should run now; skipped one-time jobs are
+// disabled.
+func (cs *CronService) handleMisfire(job *CronJob, nowMS int64) bool {
+	policy := job.Schedule.misfirePolicy()
+	limit := job.Schedule.misfireLimit()
+	if policy != MisfireRunAll {
+		limit = 100 // only counted for the log
+	}
+	missed := missedRuns(&job.Schedule, *job.State.NextRunAtMS, nowMS, limit)
+
+	if policy == MisfireSkip {
+		log.Printf("[cron] job %s (%s): skipping %d missed run(s)", job.Name, job.ID, missed)
+		if job.Schedule.Kind == "at" {
+			job.Enabled = false
+			job.State.NextRunAtMS = nil
+			job.State.LastStatus = "skipped"
+		} else {
+			job.State.NextRunAtMS = cs.computeNextRun(&job.Schedule, nowMS)
 		}
+		return false
+	}
+
+	runs := 1
+	if policy == MisfireRunAll {
+		runs = missed
 	}
+	log.Printf("[cron] job %s (%s): missed %d run(s), catching up with %d", job.Name, job.ID, missed, runs)
+	job.State.PendingRuns = runs - 1
+	job.State.NextRunAtMS = &nowMS
+	return true
 }
 
 func (cs *CronService) getNextWakeMS() *int64 {
@@ -350,6 +529,10 @@ func (cs *CronService) AddJob(
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+
 	now := time.Now().UnixMilli()
 
 	// One-time tasks (at) should be deleted after execution
@@ -387,6 +570,9 @@ func (cs *CronService) UpdateJob(job *CronJob) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
+	if err := job.Validate(); err != nil {
+		return err
+	}
 	for i := range cs.store.Jobs {
 		if cs.store.Jobs[i].ID == job.ID {
 			cs.store.Jobs[i] = *job
@@ -439,6 +625,7 @@ func (cs *CronService) EnableJob(jobID string, enabled bool) *CronJob {
 			} else {
 				job.State.NextRunAtMS = nil
 			}
+			job.State.Queued = false
 
 			if err := cs.saveStoreUnsafe(); err != nil {
 				log.Printf("[cron] failed to save store after enable: %v", err)
@@ -486,6 +673,36 @@ func (cs *CronService) Status() map[string]any {
 	}
 }
 
+// SetTimezone sets the default time zone for cron jobs created in a chat.
+// An empty tz clears it.
+func (cs *CronService) SetTimezone(channel, chatID, tz string) error {
+	if tz != "" {
+		if _, err := LoadLocation(tz); err != nil {
+			return err
+		}
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	key := channel + ":" + chatID
+	if tz == "" {
+		delete(cs.store.Timezones, key)
+	} else {
+		if cs.store.Timezones == nil {
+			cs.store.Timezones = make(map[string]string)
+		}
+		cs.store.Timezones[key] = tz
+	}
+	return cs.saveStoreUnsafe()
+}
+
+// Timezone returns the default time zone of a chat, or "" if none is set.
+func (cs *CronService) Timezone(channel, chatID string) string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.store.Timezones[channel+":"+chatID]
+}
+
 func generateID() string {
 	// Use crypto/rand for better uniqueness under concurrent access
 	b := make([]byte, 8)
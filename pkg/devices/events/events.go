@@ -33,6 +33,7 @@ type DeviceEvent struct {
 	Product      string            // Product name or ID
 	Serial       string            // Serial number if available
 	Capabilities string            // Human-readable capability description
+	Ports        []string          // Serial ports of the device, e.g. /dev/ttyUSB0
 	Raw          map[string]string // Raw properties for extensibility
 }
 
@@ -53,5 +54,8 @@ func (e *DeviceEvent) FormatMessage() string {
 	if e.Serial != "" {
 		msg += "Serial: " + e.Serial + "\n"
 	}
+	for _, port := range e.Ports {
+		msg += "Port: " + port + "\n"
+	}
 	return msg
 }
@@ -7,8 +7,10 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/devices/events"
 	"github.com/sipeed/picoclaw/pkg/logger"
@@ -34,6 +36,12 @@ var usbClassToCapability = map[string]string{
 	"ff": "Vendor Specific",
 }
 
+// portSettle is how long the add event of a USB device waits for its serial
+// ports to appear, so that one event reports both.
+const portSettle = 2 * time.Second
+
+var serialPortName = regexp.MustCompile(`^/dev/tty(USB|ACM)\d+$`)
+
 type USBMonitor struct {
 	cmd *exec.Cmd
 	mu  sync.Mutex
@@ -54,7 +62,9 @@ func (m *USBMonitor) Start(ctx context.Context) (<-chan *events.DeviceEvent, err
 	// udevadm monitor outputs: UDEV/KERNEL [timestamp] action devpath (subsystem)
 	// Followed by KEY=value lines, empty line separates events
 	// Use -s/--subsystem-match (eudev) or --udev-subsystem-match (systemd udev)
-	cmd := exec.CommandContext(ctx, "udevadm", "monitor", "--property", "--subsystem-match=usb")
+	// tty events tell which serial port a USB device got
+	cmd := exec.CommandContext(ctx, "udevadm", "monitor", "--property",
+		"--subsystem-match=usb", "--subsystem-match=tty")
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, fmt.Errorf("udevadm stdout pipe: %w", err)
@@ -67,8 +77,16 @@ func (m *USBMonitor) Start(ctx context.Context) (<-chan *events.DeviceEvent, err
 	m.cmd = cmd
 	eventCh := make(chan *events.DeviceEvent, 16)
 
+	merger := newUSBEvents(portSettle, func(ev *events.DeviceEvent) {
+		select {
+		case eventCh <- ev:
+		case <-ctx.Done():
+		}
+	})
+
 	go func() {
 		defer close(eventCh)
+		defer merger.close()
 		scanner := bufio.NewScanner(stdout)
 		var props map[string]string
 		var action string
@@ -79,13 +97,7 @@ func (m *USBMonitor) Start(ctx context.Context) (<-chan *events.DeviceEvent, err
 			if line == "" {
 				// End of event block - only process UDEV events (skip KERNEL to avoid duplicate/incomplete notifications)
 				if isUdev && props != nil && (action == "add" || action == "remove") {
-					if ev := parseUSBEvent(action, props); ev != nil {
-						select {
-						case eventCh <- ev:
-						case <-ctx.Done():
-							return
-						}
-					}
+					merger.handle(action, props)
 				}
 				props = nil
 				action = ""
@@ -160,7 +172,54 @@ func parseUSBEvent(action string, props map[string]string) *events.DeviceEvent {
 		return nil
 	}
 	ev.Kind = events.KindUSB
+	setUSBIdentity(ev, props)
+
+	ev.DeviceID = props["DEVPATH"]
+	if bus := props["BUSNUM"]; bus != "" {
+		if dev := props["DEVNUM"]; dev != "" {
+			ev.DeviceID = bus + ":" + dev
+		}
+	}
+
+	// Map USB class to capability
+	if class := props["ID_USB_CLASS"]; class != "" {
+		ev.Capabilities = usbClassToCapability[strings.ToLower(class)]
+	}
+	if ev.Capabilities == "" {
+		ev.Capabilities = "USB Device"
+	}
+
+	return ev
+}
 
+// parseTTYEvent returns an event for a USB serial port, or nil for other tty
+// devices.
+func parseTTYEvent(action string, props map[string]string) *events.DeviceEvent {
+	port := props["DEVNAME"]
+	if props["SUBSYSTEM"] != "tty" || !serialPortName.MatchString(port) {
+		return nil
+	}
+	ev := &events.DeviceEvent{
+		Kind:         events.KindUSB,
+		DeviceID:     port,
+		Capabilities: "Serial Port",
+		Ports:        []string{port},
+		Raw:          props,
+	}
+	switch action {
+	case "add":
+		ev.Action = events.ActionAdd
+	case "remove":
+		ev.Action = events.ActionRemove
+	default:
+		return nil
+	}
+	setUSBIdentity(ev, props)
+	return ev
+}
+
+// setUSBIdentity fills vendor, product and serial from udev properties.
+func setUSBIdentity(ev *events.DeviceEvent, props map[string]string) {
 	ev.Vendor = props["ID_VENDOR"]
 	if ev.Vendor == "" {
 		ev.Vendor = props["ID_VENDOR_ID"]
@@ -178,20 +237,91 @@ func parseUSBEvent(action string, props map[string]string) *events.DeviceEvent {
 	}
 
 	ev.Serial = props["ID_SERIAL_SHORT"]
-	ev.DeviceID = props["DEVPATH"]
-	if bus := props["BUSNUM"]; bus != "" {
-		if dev := props["DEVNUM"]; dev != "" {
-			ev.DeviceID = bus + ":" + dev
+}
+
+type pendingUSB struct {
+	ev    *events.DeviceEvent
+	timer *time.Timer
+}
+
+// usbEvents holds back the add event of a USB device for a moment and adds
+// the serial ports that appear under it. Other events pass straight through.
+type usbEvents struct {
+	settle time.Duration
+	out    func(*events.DeviceEvent)
+
+	mu      sync.Mutex
+	pending map[string]*pendingUSB // by DEVPATH
+	closed  bool
+}
+
+func newUSBEvents(settle time.Duration, out func(*events.DeviceEvent)) *usbEvents {
+	return &usbEvents{settle: settle, out: out, pending: make(map[string]*pendingUSB)}
+}
+
+func (u *usbEvents) handle(action string, props map[string]string) {
+	devpath := props["DEVPATH"]
+
+	if ev := parseUSBEvent(action, props); ev != nil {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		// A device removed before its add event went out: send that first.
+		if p, ok := u.pending[devpath]; ok {
+			p.timer.Stop()
+			delete(u.pending, devpath)
+			u.emitLocked(p.ev)
 		}
+		if ev.Action != events.ActionAdd {
+			u.emitLocked(ev)
+			return
+		}
+		p := &pendingUSB{ev: ev}
+		p.timer = time.AfterFunc(u.settle, func() { u.flush(devpath, p) })
+		u.pending[devpath] = p
+		return
 	}
 
-	// Map USB class to capability
-	if class := props["ID_USB_CLASS"]; class != "" {
-		ev.Capabilities = usbClassToCapability[strings.ToLower(class)]
+	// Serial ports go away with their device, so only additions matter.
+	ev := parseTTYEvent(action, props)
+	if ev == nil || ev.Action != events.ActionAdd {
+		return
 	}
-	if ev.Capabilities == "" {
-		ev.Capabilities = "USB Device"
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for parent, p := range u.pending {
+		if strings.HasPrefix(devpath, parent+"/") {
+			p.ev.Ports = append(p.ev.Ports, ev.Ports...)
+			return
+		}
+	}
+	// The port belongs to a device that was already reported, e.g. because
+	// its driver was loaded later.
+	u.emitLocked(ev)
+}
+
+func (u *usbEvents) flush(devpath string, p *pendingUSB) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.pending[devpath] != p {
+		return
 	}
+	delete(u.pending, devpath)
+	u.emitLocked(p.ev)
+}
 
-	return ev
+func (u *usbEvents) emitLocked(ev *events.DeviceEvent) {
+	if !u.closed {
+		u.out(ev)
+	}
+}
+
+// close drops held events; nothing is emitted afterwards.
+func (u *usbEvents) close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = true
+	for _, p := range u.pending {
+		p.timer.Stop()
+	}
+	u.pending = nil
 }
@@ -0,0 +1,102 @@
+package sources
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+)
+
+type eventLog struct {
+	mu     sync.Mutex
+	events []*events.DeviceEvent
+}
+
+func (l *eventLog) add(ev *events.DeviceEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, ev)
+}
+
+func (l *eventLog) wait(t *testing.T, n int) []*events.DeviceEvent {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		l.mu.Lock()
+		got := slices.Clone(l.events)
+		l.mu.Unlock()
+		if len(got) >= n {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("got %d events, want %d", len(l.events), n)
+	return nil
+}
+
+const boardPath = "/devices/platform/usb1/1-1"
+
+func usbProps(action string) map[string]string {
+	return map[string]string{
+		"ACTION":    action,
+		"SUBSYSTEM": "usb",
+		"DEVTYPE":   "usb_device",
+		"DEVPATH":   boardPath,
+		"ID_VENDOR": "FTDI",
+		"ID_MODEL":  "FT232R",
+	}
+}
+
+func ttyProps(action, name string) map[string]string {
+	return map[string]string{
+		"ACTION":    action,
+		"SUBSYSTEM": "tty",
+		"DEVPATH":   boardPath + "/1-1:1.0/" + name + "/tty/" + name,
+		"DEVNAME":   "/dev/" + name,
+		"ID_VENDOR": "FTDI",
+		"ID_MODEL":  "FT232R",
+	}
+}
+
+func TestUSBEventsAddSerialPorts(t *testing.T) {
+	var log eventLog
+	u := newUSBEvents(50*time.Millisecond, log.add)
+	defer u.close()
+
+	u.handle("add", usbProps("add"))
+	u.handle("add", ttyProps("add", "ttyUSB0"))
+	u.handle("add", ttyProps("add", "tty1")) // not a USB serial port
+
+	got := log.wait(t, 1)
+	if len(got) != 1 || got[0].Action != events.ActionAdd || !slices.Equal(got[0].Ports, []string{"/dev/ttyUSB0"}) {
+		t.Fatalf("events = %+v, want one add with /dev/ttyUSB0", got)
+	}
+
+	// A port that shows up later is reported on its own.
+	u.handle("add", ttyProps("add", "ttyUSB1"))
+	u.handle("remove", ttyProps("remove", "ttyUSB1"))
+	u.handle("remove", usbProps("remove"))
+	got = log.wait(t, 3)
+	if got[1].Capabilities != "Serial Port" || got[1].Ports[0] != "/dev/ttyUSB1" || got[1].Vendor != "FTDI" {
+		t.Errorf("late port event = %+v", got[1])
+	}
+	if got[2].Action != events.ActionRemove || len(got) != 3 {
+		t.Errorf("events = %+v, want a remove last and no tty remove", got)
+	}
+}
+
+func TestUSBEventsRemoveBeforeSettle(t *testing.T) {
+	var log eventLog
+	u := newUSBEvents(time.Hour, log.add)
+	defer u.close()
+
+	u.handle("add", usbProps("add"))
+	u.handle("remove", usbProps("remove"))
+
+	got := log.wait(t, 2)
+	if got[0].Action != events.ActionAdd || got[1].Action != events.ActionRemove {
+		t.Errorf("events = %v, %v; want add then remove", got[0].Action, got[1].Action)
+	}
+}
@@ -0,0 +1,485 @@
+// Package extract turns fetched documents into text for the model: the main
+// content of HTML pages as Markdown, and the text of PDF files.
+package extract
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Document is the readable content of a page.
+type Document struct {
+	Title    string
+	Markdown string
+}
+
+var (
+	reSpaces   = regexp.MustCompile(`[ \t\r\n\f]+`)
+	reBlank    = regexp.MustCompile(`\n{3,}`)
+	reUnlikely = regexp.MustCompile(`(?i)comment|disqus|footer|header|menu|nav|sidebar|sponsor|advert|` +
+		`(^|[-_ ])ads?([-_ ]|$)|share|social|cookie|consent|banner|popup|modal|related|breadcrumb|` +
+		`subscribe|newsletter|promo|skip-link`)
+	reLikely = regexp.MustCompile(`(?i)article|body|content|main|post|entry|story|text|blog`)
+)
+
+// removedTags never hold readable content.
+var removedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true, atom.Svg: true,
+	atom.Canvas: true, atom.Iframe: true, atom.Object: true, atom.Embed: true, atom.Form: true,
+	atom.Button: true, atom.Input: true, atom.Select: true, atom.Textarea: true, atom.Nav: true,
+	atom.Aside: true, atom.Dialog: true,
+}
+
+var blockTags = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true, atom.Body: true,
+	atom.Center: true, atom.Dd: true, atom.Details: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.H1: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Header: true,
+	atom.Hgroup: true, atom.Hr: true, atom.Html: true, atom.Li: true, atom.Main: true, atom.Nav: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true, atom.Summary: true,
+	atom.Table: true, atom.Tbody: true, atom.Td: true, atom.Tfoot: true, atom.Th: true,
+	atom.Thead: true, atom.Tr: true, atom.Ul: true,
+}
+
+// HTML extracts the main content of an HTML page as Markdown. Navigation,
+// sidebars, footers and similar boilerplate are dropped; headings, links,
+// lists, tables, code blocks and images are kept. Relative links are
+// resolved against base when it is set.
+func HTML(page []byte, base *url.URL) (Document, error) {
+	root, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return Document{}, err
+	}
+	doc := Document{Title: pageTitle(root)}
+	body := findFirst(root, atom.Body)
+	if body == nil {
+		body = root
+	}
+	prune(body, false)
+	r := &renderer{base: base}
+	doc.Markdown = cleanMarkdown(r.container(mainContent(body), "\n\n"))
+	return doc, nil
+}
+
+func pageTitle(root *html.Node) string {
+	var title, ogTitle string
+	walk(root, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.Title:
+			if title == "" {
+				title = collapse(textContent(n))
+			}
+		case atom.Meta:
+			if attr(n, "property") == "og:title" && ogTitle == "" {
+				ogTitle = collapse(attr(n, "content"))
+			}
+		}
+		return true
+	})
+	if ogTitle != "" {
+		return strings.TrimSpace(ogTitle)
+	}
+	return strings.TrimSpace(title)
+}
+
+// prune removes boilerplate from the tree. Page-level headers and footers
+// go, but those inside an article are kept since they carry its title.
+func prune(n *html.Node, inContent bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.CommentNode:
+			n.RemoveChild(c)
+		case c.Type != html.ElementNode:
+		case removedTags[c.DataAtom], isHidden(c), isUnlikely(c),
+			!inContent && (c.DataAtom == atom.Header || c.DataAtom == atom.Footer):
+			n.RemoveChild(c)
+		default:
+			prune(c, inContent || c.DataAtom == atom.Article || c.DataAtom == atom.Main)
+		}
+		c = next
+	}
+}
+
+func isHidden(n *html.Node) bool {
+	style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+	return hasAttr(n, "hidden") || attr(n, "aria-hidden") == "true" ||
+		strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+func isUnlikely(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Html, atom.Body, atom.Article, atom.Main, atom.A, atom.Table, atom.Tbody, atom.Tr,
+		atom.Td, atom.Th, atom.Pre, atom.Code:
+		return false
+	}
+	if role := attr(n, "role"); role == "navigation" || role == "banner" || role == "complementary" ||
+		role == "contentinfo" || role == "dialog" {
+		return true
+	}
+	match := attr(n, "class") + " " + attr(n, "id")
+	return reUnlikely.MatchString(match) && !reLikely.MatchString(match)
+}
+
+// mainContent picks the node holding the page's content: the largest
+// article or main element when there is one, otherwise the element whose
+// paragraphs score best.
+func mainContent(body *html.Node) *html.Node {
+	var best *html.Node
+	bestLen := 0
+	walk(body, func(n *html.Node) bool {
+		if n.DataAtom == atom.Article || n.DataAtom == atom.Main || attr(n, "role") == "main" {
+			if l := len(collapse(textContent(n))); l > bestLen {
+				best, bestLen = n, l
+			}
+		}
+		return true
+	})
+	if best != nil && bestLen >= 140 {
+		return best
+	}
+
+	scores := make(map[*html.Node]float64)
+	walk(body, func(n *html.Node) bool {
+		if n.DataAtom != atom.P && n.DataAtom != atom.Pre && n.DataAtom != atom.Td &&
+			n.DataAtom != atom.Blockquote {
+			return true
+		}
+		text := collapse(textContent(n))
+		if len(text) < 25 || n.Parent == nil {
+			return true
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		scores[n.Parent] += score
+		if gp := n.Parent.Parent; gp != nil {
+			scores[gp] += score / 2
+		}
+		return true
+	})
+	var top *html.Node
+	topScore := 0.0
+	for n, score := range scores {
+		score *= 1 - linkDensity(n)
+		if score > topScore {
+			top, topScore = n, score
+		}
+	}
+	if top == nil {
+		return body
+	}
+	return top
+}
+
+func linkDensity(n *html.Node) float64 {
+	total := len(collapse(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	walk(n, func(c *html.Node) bool {
+		if c.DataAtom == atom.A {
+			links += len(collapse(textContent(c)))
+			return false
+		}
+		return true
+	})
+	return float64(links) / float64(total)
+}
+
+// renderer writes a node tree as Markdown.
+type renderer struct {
+	base *url.URL
+}
+
+// container renders the children of n as blocks joined by sep. Runs of
+// inline content become paragraphs.
+func (r *renderer) container(n *html.Node, sep string) string {
+	var blocks []string
+	var inline strings.Builder
+	flush := func() {
+		if s := cleanInline(inline.String()); s != "" {
+			blocks = append(blocks, s)
+		}
+		inline.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.DataAtom] {
+			flush()
+			if b := r.block(c); b != "" {
+				blocks = append(blocks, b)
+			}
+			continue
+		}
+		inline.WriteString(r.inline(c))
+	}
+	flush()
+	return strings.Join(blocks, sep)
+}
+
+func (r *renderer) block(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.ReplaceAll(cleanInline(r.inlineChildren(n)), "\n", " ")
+		if text == "" {
+			return ""
+		}
+		level := int(n.Data[1] - '0')
+		return strings.Repeat("#", level) + " " + text
+	case atom.Pre:
+		code := strings.Trim(textContent(n), "\n")
+		if code == "" {
+			return ""
+		}
+		return "```\n" + code + "\n```"
+	case atom.Blockquote:
+		inner := r.container(n, "\n\n")
+		if inner == "" {
+			return ""
+		}
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return strings.Join(lines, "\n")
+	case atom.Ul, atom.Ol:
+		return r.list(n)
+	case atom.Table:
+		return r.table(n)
+	case atom.Hr:
+		return "---"
+	case atom.Dt:
+		if text := cleanInline(r.inlineChildren(n)); text != "" {
+			return "**" + text + "**"
+		}
+		return ""
+	case atom.Li:
+		return r.container(n, "\n")
+	}
+	return r.container(n, "\n\n")
+}
+
+func (r *renderer) list(n *html.Node) string {
+	var items []string
+	num := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		num = start
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		content := r.container(c, "\n")
+		if content == "" {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.ReplaceAll(content, "\n", "\n"+indent))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (r *renderer) table(n *html.Node) string {
+	var rows [][]string
+	layout := false
+	walk(n, func(c *html.Node) bool {
+		switch {
+		case c != n && c.DataAtom == atom.Table:
+			layout = true
+			return false
+		case c.DataAtom == atom.Tr:
+			var row []string
+			for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+					text := strings.ReplaceAll(r.container(cell, " "), "\n", " ")
+					row = append(row, strings.ReplaceAll(text, "|", `\|`))
+				}
+			}
+			if len(row) > 0 {
+				rows = append(rows, row)
+			}
+			return false
+		}
+		return true
+	})
+	if layout || len(rows) == 0 {
+		// Tables used for page layout are rendered as plain content.
+		return r.container(n, "\n\n")
+	}
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	if cols == 1 {
+		var lines []string
+		for _, row := range rows {
+			lines = append(lines, row[0])
+		}
+		return strings.Join(lines, "\n")
+	}
+	var sb strings.Builder
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			sb.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (r *renderer) inlineChildren(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(r.inline(c))
+	}
+	return sb.String()
+}
+
+func (r *renderer) inline(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return reSpaces.ReplaceAllString(n.Data, " ")
+	}
+	if n.Type != html.ElementNode {
+		return ""
+	}
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.A:
+		text := strings.TrimSpace(strings.ReplaceAll(r.inlineChildren(n), "\n", " "))
+		href := r.resolve(attr(n, "href"))
+		if text == "" || href == "" {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case atom.Img:
+		src := r.resolve(attr(n, "src"))
+		if src == "" {
+			return ""
+		}
+		return "![" + collapse(attr(n, "alt")) + "](" + src + ")"
+	case atom.Strong, atom.B:
+		return wrapInline(r.inlineChildren(n), "**")
+	case atom.Em, atom.I:
+		return wrapInline(r.inlineChildren(n), "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(r.inlineChildren(n), "~~")
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		return wrapInline(collapse(textContent(n)), "`")
+	}
+	if blockTags[n.DataAtom] {
+		// Block content inside inline content, such as a div in a link.
+		return " " + r.inlineChildren(n) + " "
+	}
+	return r.inlineChildren(n)
+}
+
+// wrapInline puts marker around text, keeping surrounding spaces outside.
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	trail := text[len(lead)+len(trimmed):]
+	return lead + marker + trimmed + marker + trail
+}
+
+// resolve returns href as an absolute link, or "" for links that lead
+// nowhere useful such as scripts, in-page anchors and inline data.
+func (r *renderer) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	lower := strings.ToLower(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(lower, "javascript:") ||
+		strings.HasPrefix(lower, "data:") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if r.base != nil {
+		u = r.base.ResolveReference(u)
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(u.String(), "(", "%28"), ")", "%29")
+}
+
+func cleanInline(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(reSpaces.ReplaceAllString(line, " "))
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+func cleanMarkdown(s string) string {
+	return strings.TrimSpace(reBlank.ReplaceAllString(s, "\n\n"))
+}
+
+func collapse(s string) string {
+	return strings.TrimSpace(reSpaces.ReplaceAllString(s, " "))
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+// walk calls fn for n and its descendants; fn returns false to skip the
+// children of a node.
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(n, func(c *html.Node) bool {
+		if found == nil && c.DataAtom == a {
+			found = c
+		}
+		return found == nil
+	})
+	return found
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
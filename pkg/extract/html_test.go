@@ -0,0 +1,64 @@
+package extract
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTML_Article(t *testing.T) {
+	page := `<html><head><title>Site | Post</title><meta property="og:title" content="My Post"></head><body>
+<header class="site-header"><a href="/">Home</a></header>
+<nav><a href="/about">About</a></nav>
+<div class="sidebar"><a href="/popular">Popular post</a></div>
+<article>
+  <header><h1>My  Post</h1></header>
+  <p>This is the <b>first</b> paragraph, with a <a href="../other">relative link</a> and enough text.</p>
+  <ul><li>one</li><li>two<ul><li>nested</li></ul></li></ul>
+  <ol start="3"><li>three</li><li>four</li></ol>
+  <table><tr><th>Name</th><th>Qty</th></tr><tr><td>Apple | Pie</td><td>3</td></tr></table>
+  <pre><code>x := 1
+y := 2</code></pre>
+  <blockquote><p>Quoted</p></blockquote>
+  <p>Line<br>break <img src="/img.png" alt="pic"> <code>inline</code> <a href="javascript:void(0)">js</a></p>
+  <div class="share-buttons">Share on X</div>
+  <p style="display:none">hidden</p>
+</article>
+<footer>Copyright</footer><script>track()</script></body></html>`
+	base, _ := url.Parse("https://example.com/blog/post")
+	doc, err := HTML([]byte(page), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "My Post" {
+		t.Errorf("Title = %q", doc.Title)
+	}
+	want := "# My Post\n\n" +
+		"This is the **first** paragraph, with a [relative link](https://example.com/other) and enough text.\n\n" +
+		"- one\n- two\n  - nested\n\n" +
+		"3. three\n4. four\n\n" +
+		"| Name | Qty |\n| --- | --- |\n| Apple \\| Pie | 3 |\n\n" +
+		"```\nx := 1\ny := 2\n```\n\n" +
+		"> Quoted\n\n" +
+		"Line\nbreak ![pic](https://example.com/img.png) `inline` js"
+	if doc.Markdown != want {
+		t.Errorf("Markdown =\n%s\n\nwant\n%s", doc.Markdown, want)
+	}
+}
+
+func TestHTML_ScoresContentWithoutArticle(t *testing.T) {
+	para := "<p>Readable paragraph text, long enough to count, with commas, and more words here.</p>"
+	page := `<html><body>
+<div id="menu"><ul><li><a href="/a">Link A</a></li><li><a href="/b">Link B</a></li></ul></div>
+<div class="links"><p><a href="/1">A paragraph that is only a long link to somewhere else entirely</a></p></div>
+<div class="story">` + strings.Repeat(para, 3) + `</div>
+<div class="copyright">(c) Example</div></body></html>`
+	doc, err := HTML([]byte(page), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(doc.Markdown, "Readable paragraph") != 3 || strings.Contains(doc.Markdown, "Link A") ||
+		strings.Contains(doc.Markdown, "only a long link") || strings.Contains(doc.Markdown, "Example") {
+		t.Errorf("Markdown = %q", doc.Markdown)
+	}
+}
@@ -0,0 +1,1000 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrEncryptedPDF is returned for encrypted PDF files, whose text cannot be
+// read without decrypting them first.
+var ErrEncryptedPDF = errors.New("encrypted PDF files are not supported")
+
+// ErrPDFTooLarge is returned when a PDF's compressed streams decompress to
+// more data than allowed.
+var ErrPDFTooLarge = errors.New("PDF streams decompress to more data than allowed")
+
+// DefaultMaxPDFStream caps the decompressed size of one PDF stream when the
+// caller sets no limit.
+const DefaultMaxPDFStream = 4 * 1024 * 1024
+
+// maxPDFDocumentStreams is how many times the per-stream limit all streams of
+// a document may decompress to together.
+const maxPDFDocumentStreams = 4
+
+// maxPDFNesting caps how deeply arrays and dictionaries may nest. Deeper
+// values are skipped instead of parsed, so hostile files cannot overflow the
+// stack.
+const maxPDFNesting = 100
+
+// PDF extracts the text of a PDF file, page by page. It reads the objects
+// directly instead of following the cross-reference table, so damaged or
+// truncated files still give the text of the pages that are present.
+// Scanned documents have no text; the result is empty for them.
+//
+// maxStream caps the decompressed size of each stream, and all streams
+// together may decompress to four times as much; zero means
+// DefaultMaxPDFStream. Pages past the document limit are left out.
+func PDF(data []byte, maxStream int64) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return "", errors.New("not a PDF file")
+	}
+	if maxStream <= 0 {
+		maxStream = DefaultMaxPDFStream
+	}
+	f := &pdfFile{
+		objects:   make(map[int]pdfObject),
+		fonts:     make(map[int]*pdfFont),
+		maxStream: maxStream,
+		budget:    maxStream * maxPDFDocumentStreams,
+	}
+	f.scan(data)
+	if f.encrypted {
+		return "", ErrEncryptedPDF
+	}
+
+	var pages []string
+	for _, page := range f.pages() {
+		text := cleanPDFText(f.pageText(page))
+		if text != "" {
+			pages = append(pages, text)
+		}
+	}
+	if len(pages) == 0 && f.budget <= 0 {
+		return "", ErrPDFTooLarge
+	}
+	if len(pages) <= 1 {
+		return strings.Join(pages, ""), nil
+	}
+	var sb strings.Builder
+	for i, text := range pages {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(&sb, "## Page %d\n\n%s", i+1, text)
+	}
+	return sb.String(), nil
+}
+
+type (
+	pdfName    string
+	pdfString  []byte
+	pdfKeyword string
+	pdfDict    map[string]any
+	pdfRef     struct{ num, gen int }
+)
+
+type pdfObject struct {
+	value  any
+	stream []byte // raw stream data, nil when the object has none
+}
+
+type pdfFile struct {
+	objects   map[int]pdfObject
+	fonts     map[int]*pdfFont
+	encrypted bool
+	maxStream int64 // decompressed bytes allowed per stream
+	budget    int64 // decompressed bytes left for the whole document
+}
+
+var reObjHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// scan reads every "N G obj ... endobj" in data, including the objects
+// packed into object streams. Later definitions win, as they do for
+// incrementally updated files.
+func (f *pdfFile) scan(data []byte) {
+	pos := 0
+	for {
+		loc := reObjHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num := atoiBytes(data[pos+loc[2] : pos+loc[3]])
+		p := &pdfParser{lex: pdfLexer{data: data, pos: pos + loc[1]}}
+		value, _ := p.value()
+		obj := pdfObject{value: value}
+		end := p.lex.pos
+		if dict, ok := value.(pdfDict); ok {
+			if start, ok := streamStart(data, end); ok {
+				obj.stream, end = streamData(data, start, dict)
+			}
+			if _, ok := dict["Encrypt"]; ok {
+				f.encrypted = true
+			}
+		}
+		f.objects[num] = obj
+		pos = max(end, pos+loc[1])
+	}
+	// Trailers of files with a classic cross-reference table.
+	if idx := bytes.LastIndex(data, []byte("trailer")); idx >= 0 {
+		p := &pdfParser{lex: pdfLexer{data: data, pos: idx + len("trailer")}}
+		if v, _ := p.value(); v != nil {
+			if dict, ok := v.(pdfDict); ok && dict["Encrypt"] != nil {
+				f.encrypted = true
+			}
+		}
+	}
+
+	var streams []int
+	for num, obj := range f.objects {
+		if dict, ok := obj.value.(pdfDict); ok && dict["Type"] == pdfName("ObjStm") {
+			streams = append(streams, num)
+		}
+	}
+	sort.Ints(streams)
+	for _, num := range streams {
+		f.scanObjectStream(f.objects[num])
+	}
+}
+
+func (f *pdfFile) scanObjectStream(obj pdfObject) {
+	data, err := f.decode(obj)
+	if err != nil {
+		return
+	}
+	dict := obj.value.(pdfDict)
+	n, _ := f.resolve(dict["N"]).(float64)
+	first, _ := f.resolve(dict["First"]).(float64)
+	header := &pdfParser{lex: pdfLexer{data: data}}
+	for range int(n) {
+		num, ok1 := header.value()
+		offset, ok2 := header.value()
+		numF, isNum := num.(float64)
+		offsetF, isOffset := offset.(float64)
+		if !ok1 || !ok2 || !isNum || !isOffset {
+			return
+		}
+		start := int(first) + int(offsetF)
+		if start < 0 || start >= len(data) {
+			continue
+		}
+		if _, exists := f.objects[int(numF)]; exists {
+			continue
+		}
+		p := &pdfParser{lex: pdfLexer{data: data, pos: start}}
+		if value, ok := p.value(); ok {
+			f.objects[int(numF)] = pdfObject{value: value}
+		}
+	}
+}
+
+// streamStart returns where the data of a stream following an object's
+// dictionary at pos begins.
+func streamStart(data []byte, pos int) (int, bool) {
+	for pos < len(data) && isPDFSpace(data[pos]) {
+		pos++
+	}
+	if !bytes.HasPrefix(data[pos:], []byte("stream")) {
+		return 0, false
+	}
+	pos += len("stream")
+	if pos < len(data) && data[pos] == '\r' {
+		pos++
+	}
+	if pos < len(data) && data[pos] == '\n' {
+		pos++
+	}
+	return pos, true
+}
+
+// streamData returns the raw stream data starting at start and the position
+// after it. It trusts a direct /Length only when "endstream" follows it.
+func streamData(data []byte, start int, dict pdfDict) ([]byte, int) {
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end >= start && end <= len(data) {
+			rest := bytes.TrimLeft(data[end:min(end+16, len(data))], " \t\r\n")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				return data[start:end], end
+			}
+		}
+	}
+	idx := bytes.Index(data[start:], []byte("endstream"))
+	if idx < 0 {
+		return data[start:], len(data)
+	}
+	return bytes.TrimRight(data[start:start+idx], "\r\n"), start + idx
+}
+
+func (f *pdfFile) resolve(v any) any {
+	for range 16 {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.objects[ref.num].value
+	}
+	return nil
+}
+
+func (f *pdfFile) dict(v any) pdfDict {
+	d, _ := f.resolve(v).(pdfDict)
+	return d
+}
+
+// decode returns the decoded data of a stream object.
+func (f *pdfFile) decode(obj pdfObject) ([]byte, error) {
+	dict, _ := obj.value.(pdfDict)
+	data := obj.stream
+	var filters []any
+	switch v := f.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{v}
+	case []any:
+		filters = v
+	}
+	for _, filter := range filters {
+		var err error
+		switch f.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			if f.budget <= 0 {
+				return nil, ErrPDFTooLarge
+			}
+			data, err = inflate(data, min(f.maxStream, f.budget))
+			f.budget -= int64(len(data))
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			data, err = decodeHex(data)
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data, err = decodeASCII85(data)
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %v", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// inflate decompresses zlib data, keeping what could be read from a
+// damaged stream. Output past limit bytes is dropped.
+func inflate(data []byte, limit int64) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, limit))
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+func decodeHex(data []byte) ([]byte, error) {
+	var digits []byte
+	for _, b := range data {
+		if b == '>' {
+			break
+		}
+		if !isPDFSpace(b) {
+			digits = append(digits, b)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	_, err := hex.Decode(out, digits)
+	return out, err
+}
+
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if idx := bytes.Index(data, []byte("~>")); idx >= 0 {
+		data = data[:idx]
+	}
+	out := make([]byte, len(data))
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+// pdfPage is a page with the resources it inherits from the page tree.
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages returns the pages in document order, falling back to object order
+// when the page tree cannot be followed.
+func (f *pdfFile) pages() []pdfPage {
+	var pages []pdfPage
+	seen := make(map[int]bool)
+	var visit func(v any, resources pdfDict, depth int)
+	visit = func(v any, resources pdfDict, depth int) {
+		if ref, ok := v.(pdfRef); ok {
+			if seen[ref.num] {
+				return
+			}
+			seen[ref.num] = true
+		}
+		node := f.dict(v)
+		if node == nil || depth > 64 {
+			return
+		}
+		if r := f.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		if node["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: node, resources: resources})
+			return
+		}
+		kids, _ := f.resolve(node["Kids"]).([]any)
+		for _, kid := range kids {
+			visit(kid, resources, depth+1)
+		}
+	}
+
+	nums := make([]int, 0, len(f.objects))
+	for num := range f.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if dict, ok := f.objects[num].value.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			visit(dict["Pages"], nil, 0)
+			break
+		}
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+	for _, num := range nums {
+		if dict, ok := f.objects[num].value.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: dict, resources: f.dict(dict["Resources"])})
+		}
+	}
+	return pages
+}
+
+func (f *pdfFile) pageText(page pdfPage) string {
+	var content []byte
+	contents := f.resolve(page.dict["Contents"])
+	refs, ok := contents.([]any)
+	if !ok {
+		refs = []any{page.dict["Contents"]}
+	}
+	for _, ref := range refs {
+		r, ok := ref.(pdfRef)
+		if !ok {
+			continue
+		}
+		if data, err := f.decode(f.objects[r.num]); err == nil {
+			content = append(content, data...)
+			content = append(content, '\n')
+		}
+	}
+	var sb strings.Builder
+	f.showText(&sb, content, page.resources, 0)
+	return sb.String()
+}
+
+// textState tracks where text is being drawn, to tell line breaks and word
+// gaps apart.
+type textState struct {
+	font      *pdfFont
+	y         float64
+	lineBreak bool
+	space     bool
+}
+
+// showText writes the text drawn by a content stream to sb.
+func (f *pdfFile) showText(sb *strings.Builder, content []byte, resources pdfDict, depth int) {
+	fonts := f.dict(resources["Font"])
+	st := &textState{}
+	p := &pdfParser{lex: pdfLexer{data: content}}
+	var operands []any
+	emit := func(s string) {
+		if s == "" {
+			return
+		}
+		if sb.Len() > 0 {
+			last := sb.String()[sb.Len()-1]
+			switch {
+			case st.lineBreak && last != '\n':
+				sb.WriteByte('\n')
+			case st.space && last != ' ' && last != '\n' && !strings.HasPrefix(s, " "):
+				sb.WriteByte(' ')
+			}
+		}
+		st.lineBreak, st.space = false, false
+		sb.WriteString(s)
+	}
+	moveTo := func(ty, tx float64) {
+		if math.Abs(ty) > 0.5 {
+			st.lineBreak = true
+		} else if tx > 0 {
+			st.space = true
+		}
+	}
+
+	for {
+		tok, ok := p.value()
+		if !ok {
+			break
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+		switch op {
+		case "BT":
+			st.space = true
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					st.font = f.font(fonts[string(name)])
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				tx, _ := operands[0].(float64)
+				ty, _ := operands[1].(float64)
+				moveTo(ty, tx)
+				st.y += ty
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y, _ := operands[5].(float64)
+				moveTo(y-st.y, 1)
+				st.y = y
+			}
+		case "T*":
+			st.lineBreak = true
+		case "Tj":
+			if len(operands) >= 1 {
+				emit(st.font.decode(operands[0]))
+			}
+		case "'", "\"":
+			st.lineBreak = true
+			if len(operands) >= 1 {
+				emit(st.font.decode(operands[len(operands)-1]))
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				items, _ := operands[0].([]any)
+				for _, item := range items {
+					if kern, ok := item.(float64); ok {
+						if kern < -200 {
+							st.space = true
+						}
+						continue
+					}
+					emit(st.font.decode(item))
+				}
+			}
+		case "Do":
+			if len(operands) >= 1 && depth < 8 {
+				name, _ := operands[0].(pdfName)
+				f.showForm(sb, f.dict(resources["XObject"])[string(name)], resources, depth)
+			}
+		case "ID":
+			// Skip inline image data up to EI.
+			if idx := bytes.Index(content[p.lex.pos:], []byte("EI")); idx >= 0 {
+				p.lex.pos += idx + 2
+			} else {
+				p.lex.pos = len(content)
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// showForm writes the text of a form XObject.
+func (f *pdfFile) showForm(sb *strings.Builder, ref any, resources pdfDict, depth int) {
+	r, ok := ref.(pdfRef)
+	if !ok {
+		return
+	}
+	obj := f.objects[r.num]
+	dict, _ := obj.value.(pdfDict)
+	if dict["Subtype"] != pdfName("Form") {
+		return
+	}
+	data, err := f.decode(obj)
+	if err != nil {
+		return
+	}
+	if own := f.dict(dict["Resources"]); own != nil {
+		resources = own
+	}
+	sb.WriteByte('\n')
+	f.showText(sb, data, resources, depth+1)
+	sb.WriteByte('\n')
+}
+
+// pdfFont maps the character codes of a font to text.
+type pdfFont struct {
+	codeLen int               // bytes per character code
+	toUni   map[string]string // character code -> text, from the ToUnicode CMap
+}
+
+func (f *pdfFile) font(v any) *pdfFont {
+	ref, isRef := v.(pdfRef)
+	if isRef {
+		if font, ok := f.fonts[ref.num]; ok {
+			return font
+		}
+	}
+	dict := f.dict(v)
+	font := &pdfFont{codeLen: 1}
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeLen = 2
+	}
+	if cmapRef, ok := dict["ToUnicode"].(pdfRef); ok {
+		if data, err := f.decode(f.objects[cmapRef.num]); err == nil {
+			font.parseCMap(data)
+		}
+	}
+	if isRef {
+		f.fonts[ref.num] = font
+	}
+	return font
+}
+
+func (font *pdfFont) parseCMap(data []byte) {
+	font.toUni = make(map[string]string)
+	p := &pdfParser{lex: pdfLexer{data: data}}
+	var operands []any
+	for {
+		tok, ok := p.value()
+		if !ok {
+			return
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].(pdfString); ok && len(lo) > 0 {
+					font.codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					font.toUni[string(src)] = utf16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					font.addRange(lo, hi, operands[i+2])
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func (font *pdfFont) addRange(lo, hi pdfString, dst any) {
+	start, end := codeValue(lo), codeValue(hi)
+	if end < start || end-start > 0xFFFF {
+		return
+	}
+	for code := start; code <= end; code++ {
+		key := codeBytes(code, len(lo))
+		i := code - start
+		switch d := dst.(type) {
+		case []any:
+			if i >= len(d) {
+				return
+			}
+			if s, ok := d[i].(pdfString); ok {
+				font.toUni[key] = utf16BE(s)
+			}
+		case pdfString:
+			if len(d) < 2 {
+				continue
+			}
+			units := make([]byte, len(d))
+			copy(units, d)
+			last := int(units[len(units)-2])<<8 | int(units[len(units)-1])
+			last += i
+			units[len(units)-2], units[len(units)-1] = byte(last>>8), byte(last)
+			font.toUni[key] = utf16BE(units)
+		}
+	}
+}
+
+// decode returns the text a string operand draws in font.
+func (font *pdfFont) decode(v any) string {
+	s, ok := v.(pdfString)
+	if !ok {
+		return ""
+	}
+	if font == nil || font.toUni == nil {
+		if font != nil && font.codeLen == 2 {
+			return "" // CID fonts without a ToUnicode map cannot be read
+		}
+		return winAnsi(s)
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		n := min(font.codeLen, len(s)-i)
+		if text, ok := font.toUni[string(s[i:i+n])]; ok {
+			sb.WriteString(text)
+		} else if n == 1 {
+			sb.WriteString(winAnsi(s[i : i+1]))
+		}
+		i += n
+	}
+	return sb.String()
+}
+
+func codeValue(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+func codeBytes(v, n int) string {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return string(b)
+}
+
+func utf16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// winAnsiHigh maps the WinAnsiEncoding codes that differ from Latin-1.
+var winAnsiHigh = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡', 0x88: 'ˆ', 0x89: '‰',
+	0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž', 0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•',
+	0x96: '–', 0x97: '—', 0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+func winAnsi(b []byte) string {
+	runes := make([]rune, 0, len(b))
+	for _, c := range b {
+		if r, ok := winAnsiHigh[c]; ok {
+			runes = append(runes, r)
+		} else if c >= 0x20 || c == '\n' || c == '\t' {
+			runes = append(runes, rune(c))
+		}
+	}
+	return string(runes)
+}
+
+var reSpaceRun = regexp.MustCompile(`[ \t]+`)
+
+func cleanPDFText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(reSpaceRun.ReplaceAllString(line, " "))
+	}
+	return strings.TrimSpace(reBlank.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// pdfLexer splits PDF syntax into tokens.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == '\f' || b == 0
+}
+
+func isPDFDelim(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch b := l.data[l.pos]; {
+		case isPDFSpace(b):
+			l.pos++
+		case b == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token returns the next token: a number, name, string, keyword or one of
+// the delimiters "[", "]", "<<" and ">>" as a keyword.
+func (l *pdfLexer) token() (any, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+	b := l.data[l.pos]
+	switch {
+	case b == '(':
+		return l.literalString(), true
+	case b == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return pdfKeyword("<<"), true
+	case b == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), true
+	case b == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			end = len(l.data) - l.pos
+		}
+		s, _ := decodeHex(l.data[l.pos+1 : l.pos+end])
+		l.pos += end + 1
+		return pdfString(s), true
+	case b == '/':
+		l.pos++
+		return pdfName(l.name()), true
+	case b == '[' || b == ']' || b == '{' || b == '}':
+		l.pos++
+		return pdfKeyword(string(b)), true
+	case b == ')' || b == '>':
+		l.pos++
+		return l.token()
+	}
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if n, ok := parseNumber(word); ok {
+		return n, true
+	}
+	return pdfKeyword(word), true
+}
+
+func (l *pdfLexer) name() string {
+	var sb strings.Builder
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		if l.data[l.pos] == '#' && l.pos+2 < len(l.data) {
+			if v, err := hex.DecodeString(string(l.data[l.pos+1 : l.pos+3])); err == nil {
+				sb.Write(v)
+				l.pos += 3
+				continue
+			}
+		}
+		sb.WriteByte(l.data[l.pos])
+		l.pos++
+	}
+	return sb.String()
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	var out []byte
+	depth := 0
+	l.pos++ // (
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		l.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				b = '\n'
+			case 'r':
+				b = '\r'
+			case 't':
+				b = '\t'
+			case 'b':
+				b = '\b'
+			case 'f':
+				b = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b = byte(v)
+				} else {
+					b = e
+				}
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func parseNumber(s string) (float64, bool) {
+	if s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+'
+	}) >= 0 {
+		return 0, false
+	}
+	var v float64
+	_, err := fmt.Sscan(s, &v)
+	return v, err == nil
+}
+
+func atoiBytes(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v*10 + int(c-'0')
+	}
+	return v
+}
+
+// pdfParser builds values from tokens: arrays, dictionaries and indirect
+// references ("N G R") on top of the lexer's tokens.
+type pdfParser struct {
+	lex    pdfLexer
+	peeked []any
+	depth  int
+}
+
+func (p *pdfParser) token() (any, bool) {
+	if n := len(p.peeked); n > 0 {
+		tok := p.peeked[n-1]
+		p.peeked = p.peeked[:n-1]
+		return tok, true
+	}
+	return p.lex.token()
+}
+
+func (p *pdfParser) unread(tok any) {
+	p.peeked = append(p.peeked, tok)
+}
+
+// skip reads past the rest of an array or dictionary whose opening token was
+// just read, without building it.
+func (p *pdfParser) skip() {
+	for depth := 1; depth > 0; {
+		tok, ok := p.token()
+		if !ok {
+			return
+		}
+		switch tok {
+		case pdfKeyword("["), pdfKeyword("<<"):
+			depth++
+		case pdfKeyword("]"), pdfKeyword(">>"):
+			depth--
+		}
+	}
+}
+
+func (p *pdfParser) value() (any, bool) {
+	tok, ok := p.token()
+	if !ok {
+		return nil, false
+	}
+	switch t := tok.(type) {
+	case pdfKeyword:
+		if t == "[" || t == "<<" {
+			if p.depth >= maxPDFNesting {
+				p.skip()
+				return nil, true
+			}
+			p.depth++
+			defer func() { p.depth-- }()
+		}
+		switch t {
+		case "[":
+			var arr []any
+			for {
+				next, ok := p.token()
+				if !ok || next == pdfKeyword("]") {
+					return arr, true
+				}
+				p.unread(next)
+				v, ok := p.value()
+				if !ok {
+					return arr, true
+				}
+				arr = append(arr, v)
+			}
+		case "<<":
+			dict := make(pdfDict)
+			for {
+				key, ok := p.token()
+				if !ok || key == pdfKeyword(">>") {
+					return dict, true
+				}
+				name, isName := key.(pdfName)
+				if !isName {
+					continue
+				}
+				v, ok := p.value()
+				if !ok {
+					return dict, true
+				}
+				if v == pdfKeyword(">>") {
+					return dict, true
+				}
+				dict[string(name)] = v
+			}
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		case "null":
+			return nil, true
+		}
+	case float64:
+		gen, ok := p.token()
+		if !ok {
+			return t, true
+		}
+		genNum, isNum := gen.(float64)
+		if !isNum {
+			p.unread(gen)
+			return t, true
+		}
+		r, ok := p.token()
+		if ok && r == pdfKeyword("R") {
+			return pdfRef{num: int(t), gen: int(genNum)}, true
+		}
+		if ok {
+			p.unread(r)
+		}
+		p.unread(gen)
+		return t, true
+	}
+	return tok, true
+}
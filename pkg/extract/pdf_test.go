@@ -0,0 +1,141 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func flate(s string) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+// buildPDF writes a minimal PDF with one page per content stream. Content
+// streams are compressed; fonts are F1 (Helvetica) and F2 (a Type0 font whose
+// ToUnicode CMap maps 0x0001-0x0003 to "Héê").
+func buildPDF(contents ...string) []byte {
+	var objs []string
+	add := func(s string) int {
+		objs = append(objs, s)
+		return len(objs)
+	}
+	stream := func(dict string, data []byte) string {
+		return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+	}
+
+	cmap := "/CIDInit /ProcSet findresource begin\nbegincmap\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"1 beginbfchar <0001> <0048> endbfchar\n1 beginbfrange <0002> <0003> <00E9> endbfrange\nendcmap"
+	toUnicode := add(stream("", []byte(cmap)))
+	f1 := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	f2 := add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /X /Encoding /Identity-H /ToUnicode %d 0 R >>",
+		toUnicode))
+	pagesNum := len(objs) + 1
+	add("") // pages, filled in below
+	var kids []string
+	for _, c := range contents {
+		content := add(stream("/Filter /FlateDecode", flate(c)))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /Contents %d 0 R >>", pagesNum, content))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objs[pagesNum-1] = fmt.Sprintf(
+		"<< /Type /Pages /Kids [%s] /Count %d /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> >>",
+		strings.Join(kids, " "), len(kids), f1, f2)
+	catalog := add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesNum))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	for i, o := range objs {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Root %d 0 R /Size %d >>\n%%%%EOF\n", catalog, len(objs)+1)
+	return buf.Bytes()
+}
+
+func TestPDF_Text(t *testing.T) {
+	data := buildPDF(
+		"BT /F1 24 Tf 72 700 Td (Annual Report) Tj 0 -30 Td [(Sales) -300 (grew \\(a lot\\))] TJ ET",
+		"BT /F2 12 Tf 72 700 Td <000100020003> Tj T* /F1 12 Tf (Caf\\351 \\223ok\\224) Tj ET",
+	)
+	text, err := PDF(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "## Page 1\n\nAnnual Report\nSales grew (a lot)\n\n## Page 2\n\nHéê\nCafé “ok”"
+	if text != want {
+		t.Errorf("PDF() =\n%s\nwant\n%s", text, want)
+	}
+
+	// A cut-off download still gives the pages that arrived.
+	cut := data[:bytes.Index(data, []byte("<< /Type /Page /Parent"))+60]
+	if text, _ := PDF(cut, 0); !strings.Contains(text, "Annual Report") {
+		t.Errorf("truncated PDF text = %q", text)
+	}
+}
+
+func TestPDF_Errors(t *testing.T) {
+	if _, err := PDF([]byte("<html></html>"), 0); err == nil {
+		t.Error("non-PDF input should fail")
+	}
+	encrypted := bytes.Replace(buildPDF("BT (x) Tj ET"), []byte("/Size"), []byte("/Encrypt 99 0 R /Size"), 1)
+	if _, err := PDF(encrypted, 0); !errors.Is(err, ErrEncryptedPDF) {
+		t.Errorf("encrypted PDF error = %v", err)
+	}
+	if text, err := PDF(buildPDF("0 0 m 100 100 l S"), 0); err != nil || text != "" {
+		t.Errorf("PDF without text = %q, %v", text, err)
+	}
+}
+
+func TestPDF_DeepNesting(t *testing.T) {
+	data := buildPDF("BT /F1 12 Tf (Hello) Tj ET")
+	// Without the nesting limit this overflows the stack, which kills the process.
+	deep := "1000 0 obj\n" + strings.Repeat("[", 3<<20) + "\nendobj\n" +
+		"1001 0 obj\n" + strings.Repeat("<< /A ", 200) + "1" + strings.Repeat(" >>", 200) + "\nendobj\n"
+	data = bytes.Replace(data, []byte("trailer"), []byte(deep+"trailer"), 1)
+	text, err := PDF(data, 0)
+	if err != nil || text != "Hello" {
+		t.Errorf("PDF() = %q, %v", text, err)
+	}
+
+	p := &pdfParser{lex: pdfLexer{data: []byte(strings.Repeat("[", 150) + "1" + strings.Repeat("]", 150) + " 2")}}
+	v, _ := p.value()
+	for depth := 1; depth < maxPDFNesting; depth++ {
+		arr, ok := v.([]any)
+		if !ok || len(arr) != 1 {
+			t.Fatalf("value at depth %d = %v", depth, v)
+		}
+		v = arr[0]
+	}
+	if next, _ := p.value(); next != 2.0 {
+		t.Errorf("value after the deep array = %v, want 2", next)
+	}
+}
+
+func TestPDF_DecompressionLimit(t *testing.T) {
+	bomb := "BT /F1 12 Tf (Hello) Tj ET\n" + strings.Repeat(" ", 8<<20)
+	text, err := PDF(buildPDF(bomb), 1<<20)
+	if err != nil || text != "Hello" {
+		t.Errorf("PDF() = %q, %v", text, err)
+	}
+
+	// Every page decompresses to the stream limit, so the document budget
+	// runs out after four of them.
+	pages := make([]string, 8)
+	for i := range pages {
+		pages[i] = fmt.Sprintf("BT /F1 12 Tf (Page%d) Tj ET\n", i+1) + strings.Repeat(" ", 2<<20)
+	}
+	text, err = PDF(buildPDF(pages...), 1<<20)
+	if err != nil || !strings.Contains(text, "Page4") || strings.Contains(text, "Page5") {
+		t.Errorf("PDF() over the document limit = %q, %v", text, err)
+	}
+
+	if out, err := inflate(flate(strings.Repeat("a", 1<<20)), 1000); err != nil || len(out) != 1000 {
+		t.Errorf("inflate() returned %d bytes, %v; want 1000", len(out), err)
+	}
+}
@@ -10,11 +10,13 @@ import (
 )
 
 type Server struct {
-	server    *http.Server
-	mu        sync.RWMutex
-	ready     bool
-	checks    map[string]Check
-	startTime time.Time
+	server        *http.Server
+	mu            sync.RWMutex
+	ready         bool
+	checks        map[string]Check
+	liveChecks    map[string]func() (bool, string)
+	providersFunc func() any
+	startTime     time.Time
 }
 
 type Check struct {
@@ -33,13 +35,15 @@ type StatusResponse struct {
 func NewServer(host string, port int) *Server {
 	mux := http.NewServeMux()
 	s := &Server{
-		ready:     false,
-		checks:    make(map[string]Check),
-		startTime: time.Now(),
+		ready:      false,
+		checks:     make(map[string]Check),
+		liveChecks: make(map[string]func() (bool, string)),
+		startTime:  time.Now(),
 	}
 
 	mux.HandleFunc("/health", s.healthHandler)
 	mux.HandleFunc("/ready", s.readyHandler)
+	mux.HandleFunc("/providers", s.providersHandler)
 
 	addr := fmt.Sprintf("%s:%d", host, port)
 	s.server = &http.Server{
@@ -103,6 +107,21 @@ func (s *Server) RegisterCheck(name string, checkFn func() (bool, string)) {
 	}
 }
 
+// RegisterLiveCheck registers a check that is re-evaluated on every /ready
+// request, unlike RegisterCheck which records a single result.
+func (s *Server) RegisterLiveCheck(name string, checkFn func() (bool, string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.liveChecks[name] = checkFn
+}
+
+// SetProvidersFunc sets the function whose result is served as JSON on /providers.
+func (s *Server) SetProvidersFunc(fn func() any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.providersFunc = fn
+}
+
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -125,8 +144,22 @@ func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 	for k, v := range s.checks {
 		checks[k] = v
 	}
+	liveChecks := make(map[string]func() (bool, string), len(s.liveChecks))
+	for k, v := range s.liveChecks {
+		liveChecks[k] = v
+	}
 	s.mu.RUnlock()
 
+	for name, checkFn := range liveChecks {
+		status, msg := checkFn()
+		checks[name] = Check{
+			Name:      name,
+			Status:    statusString(status),
+			Message:   msg,
+			Timestamp: time.Now(),
+		}
+	}
+
 	if !ready {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		json.NewEncoder(w).Encode(StatusResponse{
@@ -156,11 +189,29 @@ func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// RegisterOnMux registers /health and /ready handlers onto the given mux.
+func (s *Server) providersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	s.mu.RLock()
+	fn := s.providersFunc
+	s.mu.RUnlock()
+
+	if fn == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "provider status not available"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(fn())
+}
+
+// RegisterOnMux registers /health, /ready and /providers handlers onto the given mux.
 // This allows the health endpoints to be served by a shared HTTP server.
 func (s *Server) RegisterOnMux(mux *http.ServeMux) {
 	mux.HandleFunc("/health", s.healthHandler)
 	mux.HandleFunc("/ready", s.readyHandler)
+	mux.HandleFunc("/providers", s.providersHandler)
 }
 
 func statusString(ok bool) string {
@@ -0,0 +1,63 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// FormatLog lists entries newest first, at most limit of them.
+func FormatLog(entries []Entry, showPath bool, limit int) string {
+	if len(entries) == 0 {
+		return "No versions recorded."
+	}
+	var sb strings.Builder
+	shown := 0
+	for i := len(entries) - 1; i >= 0 && shown < limit; i-- {
+		e := entries[i]
+		size := "deleted"
+		if !e.Deleted() {
+			size = formatSize(e.Size)
+		}
+		fmt.Fprintf(&sb, "#%-5d %s  %-12s", e.Seq, e.Time.Local().Format(time.DateTime), e.Source)
+		if showPath {
+			fmt.Fprintf(&sb, " %s", e.Path)
+		}
+		fmt.Fprintf(&sb, "  %s\n", size)
+		shown++
+	}
+	if rest := len(entries) - shown; rest > 0 {
+		fmt.Fprintf(&sb, "[%d older versions not shown]\n", rest)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Diff shows how the current content of a file differs from entry.
+func Diff(entry Entry, content, current []byte, exists bool) string {
+	oldName := fmt.Sprintf("%s (version #%d)", entry.Path, entry.Seq)
+	newName := entry.Path + " (current)"
+	if entry.Deleted() {
+		oldName = "/dev/null"
+	}
+	if !exists {
+		newName = "/dev/null"
+	}
+	diff, added, removed := utils.UnifiedDiff(oldName, newName, content, current)
+	if added == 0 && removed == 0 && entry.Deleted() == !exists {
+		return fmt.Sprintf("Version #%d matches the current file.", entry.Seq)
+	}
+	return diff
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,494 @@
+// Package history keeps earlier versions of workspace files written by the
+// agent, so that bad edits can be inspected and rolled back.
+//
+// Versions live in Dir under the workspace: file contents are stored once
+// per SHA-256 hash as gzip-compressed objects, and log.jsonl lists the
+// versions of every file in the order they were recorded.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Dir is the directory under the workspace that holds the history.
+const Dir = ".history"
+
+const (
+	logFile    = "log.jsonl"
+	objectsDir = "objects"
+)
+
+// Sources with a special meaning; other entries name the tool that wrote the
+// file.
+const (
+	SourceOriginal = "original" // content before the first recorded write
+	SourceExternal = "external" // content changed outside the agent since the previous version
+	SourceRestore  = "restore"  // restored with picoclaw workspace restore
+)
+
+// Entry is one recorded version of a file.
+type Entry struct {
+	Seq    int64     `json:"seq"`
+	Time   time.Time `json:"time"`
+	Path   string    `json:"path"`             // slash-separated, relative to the workspace
+	Hash   string    `json:"hash,omitempty"`   // SHA-256 of the content; empty when the file was deleted
+	Size   int64     `json:"size,omitempty"`   // content size in bytes
+	Stored int64     `json:"stored,omitempty"` // compressed object size in bytes
+	Source string    `json:"source"`
+}
+
+// Deleted reports whether the entry records the removal of the file.
+func (e Entry) Deleted() bool {
+	return e.Hash == ""
+}
+
+// Change describes one write to a file.
+type Change struct {
+	Path    string // absolute, or relative to the workspace
+	Old     []byte
+	New     []byte
+	Created bool // the file did not exist before; Old is ignored
+	Deleted bool // the file was removed; New is ignored
+	Source  string
+}
+
+// Store records file versions for one workspace. It is safe for concurrent
+// use within a process.
+type Store struct {
+	workspace   string
+	dir         string
+	maxVersions int
+	maxAge      time.Duration
+	maxBytes    int64
+	maxFileSize int64
+	now         func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewStore returns the history of workspace with the retention limits of
+// cfg. Limits that are not positive are not enforced.
+func NewStore(workspace string, cfg config.FileHistoryConfig) *Store {
+	return &Store{
+		workspace:   workspace,
+		dir:         filepath.Join(workspace, Dir),
+		maxVersions: cfg.MaxVersions,
+		maxAge:      time.Duration(cfg.MaxAgeDays) * 24 * time.Hour,
+		maxBytes:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxFileSize: int64(cfg.MaxFileSizeKB) * 1024,
+		now:         time.Now,
+	}
+}
+
+// Workspace returns the workspace directory the store belongs to.
+func (s *Store) Workspace() string {
+	return s.workspace
+}
+
+// Rel returns path relative to the workspace in slash form. Relative paths
+// are taken as relative to the workspace. It reports false for paths outside
+// the workspace and inside the history itself.
+func (s *Store) Rel(path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.workspace, path)
+	}
+	rel, err := filepath.Rel(s.workspace, path)
+	if err != nil || !filepath.IsLocal(rel) || s.IsHistoryPath(path) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// IsHistoryPath reports whether path lies inside the history directory.
+func (s *Store) IsHistoryPath(path string) bool {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.workspace, path)
+	}
+	rel, err := filepath.Rel(s.dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// AbsPath returns the absolute path of a workspace-relative entry path.
+func (s *Store) AbsPath(rel string) string {
+	return filepath.Join(s.workspace, filepath.FromSlash(rel))
+}
+
+// Record stores the new version of the changed file. If the content before
+// the change is not the latest recorded version, it is recorded first, so
+// the change can always be undone. Paths outside the workspace and files
+// larger than the size limit are ignored.
+func (s *Store) Record(c Change) error {
+	rel, ok := s.Rel(c.Path)
+	if !ok {
+		return nil
+	}
+	if c.Created && c.Deleted || !c.Created && !c.Deleted && bytes.Equal(c.Old, c.New) {
+		return nil // nothing changed
+	}
+	if s.maxFileSize > 0 &&
+		(!c.Created && int64(len(c.Old)) > s.maxFileSize || !c.Deleted && int64(len(c.New)) > s.maxFileSize) {
+		logger.DebugCF("history", "File too large to version",
+			map[string]any{"path": rel, "max_bytes": s.maxFileSize})
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		return err
+	}
+	seq := int64(1)
+	var latest *Entry
+	for i := range entries {
+		seq = max(seq, entries[i].Seq+1)
+		if entries[i].Path == rel {
+			latest = &entries[i]
+		}
+	}
+
+	now := s.now().UTC()
+	var added []Entry
+	add := func(content []byte, deleted bool, source string) error {
+		e := Entry{Seq: seq, Time: now, Path: rel, Source: source}
+		if !deleted {
+			e.Hash = hashContent(content)
+			e.Size = int64(len(content))
+			stored, err := s.writeObject(e.Hash, content)
+			if err != nil {
+				return err
+			}
+			e.Stored = stored
+		}
+		added = append(added, e)
+		seq++
+		return nil
+	}
+
+	oldHash := ""
+	if !c.Created {
+		oldHash = hashContent(c.Old)
+	}
+	switch {
+	case latest == nil && !c.Created:
+		err = add(c.Old, false, SourceOriginal)
+	case latest != nil && latest.Hash != oldHash:
+		err = add(c.Old, c.Created, SourceExternal)
+	}
+	if err != nil {
+		return err
+	}
+	if err := add(c.New, c.Deleted, c.Source); err != nil {
+		return err
+	}
+
+	kept, dropped := s.prune(append(entries, added...))
+	if len(dropped) == 0 {
+		return s.appendLog(added)
+	}
+	if err := s.writeLog(kept); err != nil {
+		return err
+	}
+	s.removeObjects(kept, dropped)
+	return nil
+}
+
+// Log returns the recorded versions of path, oldest first, or of all files
+// when path is empty.
+func (s *Store) Log(path string) ([]Entry, error) {
+	rel := ""
+	if path != "" {
+		var ok bool
+		if rel, ok = s.Rel(path); !ok {
+			return nil, fmt.Errorf("%s is not in the workspace", path)
+		}
+	}
+
+	s.mu.Lock()
+	entries, err := s.load()
+	s.mu.Unlock()
+	if err != nil || rel == "" {
+		return entries, err
+	}
+	var out []Entry
+	for _, e := range entries {
+		if e.Path == rel {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// Content returns the file content recorded in e.
+func (s *Store) Content(e Entry) ([]byte, error) {
+	if e.Deleted() {
+		return nil, nil
+	}
+	f, err := os.Open(s.objectPath(e.Hash))
+	if err != nil {
+		return nil, fmt.Errorf("version #%d is missing from the history: %w", e.Seq, err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("version #%d is corrupt: %w", e.Seq, err)
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("version #%d is corrupt: %w", e.Seq, err)
+	}
+	return data, nil
+}
+
+// Restore writes the content of version seq back to its file, or removes the
+// file if the version records its deletion, and records the result.
+func (s *Store) Restore(seq int64) error {
+	entries, err := s.Log("")
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Seq != seq {
+			continue
+		}
+		content, err := s.Content(e)
+		if err != nil {
+			return err
+		}
+		path := s.AbsPath(e.Path)
+		old, readErr := os.ReadFile(path)
+		if readErr != nil && !errors.Is(readErr, os.ErrNotExist) {
+			return readErr
+		}
+		change := Change{Path: path, Old: old, Created: readErr != nil, Source: SourceRestore}
+		if e.Deleted() {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			change.Deleted = true
+		} else {
+			if err := fileutil.WriteFileAtomic(path, content, 0o600); err != nil {
+				return err
+			}
+			change.New = content
+		}
+		return s.Record(change)
+	}
+	return fmt.Errorf("no version #%d in the history", seq)
+}
+
+// SelectVersion picks the version of a file to diff against or restore.
+// With seq 0 it is the newest version that differs from the current content
+// (exists false when the file is missing), which undoes the last change.
+func SelectVersion(entries []Entry, seq int64, current []byte, exists bool) (Entry, error) {
+	if len(entries) == 0 {
+		return Entry{}, errors.New("no versions recorded for this file")
+	}
+	if seq != 0 {
+		for _, e := range entries {
+			if e.Seq == seq {
+				return e, nil
+			}
+		}
+		return Entry{}, fmt.Errorf("no version #%d of %s", seq, entries[0].Path)
+	}
+	currentHash := ""
+	if exists {
+		currentHash = hashContent(current)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Hash != currentHash {
+			return entries[i], nil
+		}
+	}
+	return Entry{}, errors.New("no earlier version differs from the current file")
+}
+
+// prune applies the retention limits. The newest version of every file is
+// only dropped when the size limit cannot be met otherwise.
+func (s *Store) prune(entries []Entry) (kept, dropped []Entry) {
+	newest := make(map[string]int64, len(entries))
+	for _, e := range entries {
+		newest[e.Path] = e.Seq
+	}
+	cutoff := s.now().Add(-s.maxAge)
+	versions := make(map[string]int)
+	drop := make([]bool, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		versions[e.Path]++
+		if newest[e.Path] == e.Seq {
+			continue
+		}
+		if s.maxVersions > 0 && versions[e.Path] > s.maxVersions || s.maxAge > 0 && e.Time.Before(cutoff) {
+			drop[i] = true
+		}
+	}
+
+	if s.maxBytes > 0 {
+		refs := make(map[string]int)
+		var total int64
+		for i, e := range entries {
+			if drop[i] || e.Deleted() {
+				continue
+			}
+			if refs[e.Hash] == 0 {
+				total += e.Stored
+			}
+			refs[e.Hash]++
+		}
+		for _, newestToo := range []bool{false, true} {
+			for i := 0; i < len(entries) && total > s.maxBytes; i++ {
+				e := entries[i]
+				if drop[i] || !newestToo && newest[e.Path] == e.Seq {
+					continue
+				}
+				drop[i] = true
+				if e.Deleted() {
+					continue
+				}
+				if refs[e.Hash]--; refs[e.Hash] == 0 {
+					total -= e.Stored
+				}
+			}
+		}
+	}
+
+	for i, e := range entries {
+		if drop[i] {
+			dropped = append(dropped, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	return kept, dropped
+}
+
+func (s *Store) load() ([]Entry, error) {
+	f, err := os.Open(filepath.Join(s.dir, logFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Path == "" {
+			continue // skip a line torn by a crash rather than losing the history
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func (s *Store) appendLog(entries []Entry) error {
+	data, err := encodeEntries(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, logFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write history log: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) writeLog(entries []Entry) error {
+	data, err := encodeEntries(entries)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(filepath.Join(s.dir, logFile), data, 0o600)
+}
+
+func encodeEntries(entries []Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.dir, objectsDir, hash[:2], hash[2:])
+}
+
+// writeObject stores content under its hash unless it is already there and
+// returns the size of the object.
+func (s *Store) writeObject(hash string, content []byte) (int64, error) {
+	path := s.objectPath(hash)
+	if info, err := os.Stat(path); err == nil {
+		return info.Size(), nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(content)
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	if err := fileutil.WriteFileAtomic(path, buf.Bytes(), 0o600); err != nil {
+		return 0, fmt.Errorf("failed to store version: %w", err)
+	}
+	return int64(buf.Len()), nil
+}
+
+// removeObjects deletes the objects of dropped entries that no kept entry
+// refers to.
+func (s *Store) removeObjects(kept, dropped []Entry) {
+	inUse := make(map[string]bool, len(kept))
+	for _, e := range kept {
+		inUse[e.Hash] = true
+	}
+	for _, e := range dropped {
+		if e.Deleted() || inUse[e.Hash] {
+			continue
+		}
+		inUse[e.Hash] = true // remove once
+		path := s.objectPath(e.Hash)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.WarnCF("history", "Failed to remove pruned version",
+				map[string]any{"path": path, "error": err.Error()})
+		}
+		os.Remove(filepath.Dir(path)) // only succeeds once the fan-out directory is empty
+	}
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
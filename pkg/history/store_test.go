@@ -0,0 +1,151 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestStore(t *testing.T, cfg config.FileHistoryConfig) *Store {
+	t.Helper()
+	return NewStore(t.TempDir(), cfg)
+}
+
+func sources(entries []Entry) string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Source)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestStore_RecordAndLog(t *testing.T) {
+	s := newTestStore(t, config.FileHistoryConfig{})
+	mem := filepath.Join(s.Workspace(), "MEMORY.md")
+
+	changes := []Change{
+		{Path: mem, Old: []byte("v1\n"), New: []byte("v2\n"), Source: "edit_file"},
+		{Path: "MEMORY.md", Old: []byte("v2\n"), New: []byte("v3\n"), Source: "write_file"},
+		{Path: mem, Old: []byte("v3 edited by hand\n"), New: []byte("v4\n"), Source: "append_file"},
+		{Path: mem, Old: []byte("v4\n"), New: []byte("v4\n"), Source: "write_file"}, // unchanged
+		{Path: "notes/new.md", New: []byte("hello\n"), Created: true, Source: "write_file"},
+		{Path: "notes/new.md", Old: []byte("hello\n"), Deleted: true, Source: "apply_patch"},
+		{Path: filepath.Join(s.Workspace(), "..", "outside.txt"), Old: []byte("a"), New: []byte("b")},
+		{Path: filepath.Join(Dir, "log.jsonl"), Old: []byte("a"), New: []byte("b")},
+	}
+	for _, c := range changes {
+		if err := s.Record(c); err != nil {
+			t.Fatalf("Record(%s) error = %v", c.Path, err)
+		}
+	}
+
+	entries, err := s.Log("MEMORY.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sources(entries), "original,edit_file,write_file,external,append_file"; got != want {
+		t.Errorf("MEMORY.md sources = %s, want %s", got, want)
+	}
+	for i, want := range []string{"v1\n", "v2\n", "v3\n", "v3 edited by hand\n", "v4\n"} {
+		got, err := s.Content(entries[i])
+		if err != nil || string(got) != want {
+			t.Errorf("version #%d content = %q, %v; want %q", entries[i].Seq, got, err, want)
+		}
+	}
+
+	all, _ := s.Log("")
+	if len(all) != 7 || all[6].Path != "notes/new.md" || !all[6].Deleted() || all[5].Source != "write_file" {
+		t.Errorf("full log = %+v", all)
+	}
+	if _, err := s.Log("../outside.txt"); err == nil {
+		t.Error("Log outside the workspace should fail")
+	}
+}
+
+func TestStore_Restore(t *testing.T) {
+	s := newTestStore(t, config.FileHistoryConfig{})
+	path := filepath.Join(s.Workspace(), "SOUL.md")
+	os.WriteFile(path, []byte("broken\n"), 0o600)
+	s.Record(Change{Path: path, Old: []byte("kind\n"), New: []byte("broken\n"), Source: "write_file"})
+
+	entries, _ := s.Log(path)
+	current, _ := os.ReadFile(path)
+	v, err := SelectVersion(entries, 0, current, true)
+	if err != nil || v.Source != SourceOriginal {
+		t.Fatalf("SelectVersion() = %+v, %v; want the original version", v, err)
+	}
+	if err := s.Restore(v.Seq); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "kind\n" {
+		t.Errorf("restored content = %q", data)
+	}
+	entries, _ = s.Log(path)
+	if got := sources(entries); got != "original,write_file,restore" {
+		t.Errorf("sources after restore = %s", got)
+	}
+
+	if _, err := SelectVersion(entries, 99, nil, false); err == nil {
+		t.Error("unknown version should fail")
+	}
+	if err := s.Restore(99); err == nil {
+		t.Error("restoring an unknown version should fail")
+	}
+}
+
+func TestStore_Retention(t *testing.T) {
+	s := newTestStore(t, config.FileHistoryConfig{MaxVersions: 3, MaxAgeDays: 1})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	for i := range 5 {
+		s.Record(Change{Path: "a.txt", Old: []byte{byte('0' + i)}, New: []byte{byte('1' + i)}, Source: "edit_file"})
+	}
+	entries, _ := s.Log("a.txt")
+	if len(entries) != 3 || entries[0].Seq != 4 {
+		t.Errorf("versions kept = %+v, want the newest 3", entries)
+	}
+	if _, err := s.Content(Entry{Seq: 1, Hash: hashContent([]byte("0"))}); err == nil {
+		t.Error("object of a pruned version should be removed")
+	}
+
+	s.Record(Change{Path: "b.txt", Old: []byte("old"), New: []byte("new"), Source: "edit_file"})
+	now = now.Add(48 * time.Hour)
+	s.Record(Change{Path: "c.txt", New: []byte("c"), Created: true, Source: "write_file"})
+	all, _ := s.Log("")
+	var paths []string
+	for _, e := range all {
+		paths = append(paths, e.Path)
+	}
+	if got := strings.Join(paths, ","); got != "a.txt,b.txt,c.txt" {
+		t.Errorf("after expiry the log holds %s, want only the newest version of each file", got)
+	}
+}
+
+func TestStore_SizeLimit(t *testing.T) {
+	s := newTestStore(t, config.FileHistoryConfig{MaxSizeMB: 1, MaxFileSizeKB: 1024})
+	big := func(b byte) []byte { return []byte(strings.Repeat(string(rune(b)), 10)) }
+	s.Record(Change{Path: "a.txt", Old: big('a'), New: big('b'), Source: "edit_file"})
+	s.maxBytes = 60 // room for about two compressed objects
+
+	s.Record(Change{Path: "b.txt", Old: big('c'), New: big('d'), Source: "edit_file"})
+	all, _ := s.Log("")
+	for _, e := range all {
+		if e.Path == "a.txt" && e.Source == SourceOriginal {
+			t.Errorf("oldest version should be pruned first: %+v", all)
+		}
+	}
+	if len(all) == 0 || all[len(all)-1].Path != "b.txt" {
+		t.Errorf("newest version must be kept: %+v", all)
+	}
+
+	s.maxFileSize = 4
+	s.Record(Change{Path: "huge.txt", New: []byte("too large"), Created: true, Source: "write_file"})
+	if entries, _ := s.Log("huge.txt"); len(entries) != 0 {
+		t.Errorf("files over the size limit must not be versioned: %+v", entries)
+	}
+}
@@ -0,0 +1,339 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const defaultCallTimeout = 60 * time.Second
+
+// Client is a connection to a single MCP server.
+type Client struct {
+	name string
+	t    transport
+
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	pending map[string]chan *message
+	done    chan struct{}
+
+	onNotification func(method string, params json.RawMessage)
+
+	info InitializeResult
+}
+
+// Connect starts or dials the server described by cfg and performs the
+// initialize handshake.
+func Connect(ctx context.Context, name string, cfg config.MCPServerConfig) (*Client, error) {
+	timeout := defaultCallTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	httpClient := &http.Client{Timeout: timeout}
+
+	var t transport
+	var err error
+	switch transportKind(cfg) {
+	case "stdio":
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("mcp server %q: command is required for stdio transport", name)
+		}
+		t, err = newStdioTransport(name, cfg.Command, cfg.Args, cfg.Env, cfg.Dir)
+	case "http":
+		t = newHTTPTransport(cfg.URL, cfg.Headers, httpClient)
+	case "sse":
+		t, err = newSSETransport(ctx, cfg.URL, cfg.Headers, httpClient)
+	default:
+		return nil, fmt.Errorf("mcp server %q: unknown transport %q", name, cfg.Transport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %q: %w", name, err)
+	}
+
+	c := newClient(name, t)
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mcp server %q: initialize: %w", name, err)
+	}
+	return c, nil
+}
+
+// transportKind returns the configured transport, inferring it from the
+// presence of command or url when unset.
+func transportKind(cfg config.MCPServerConfig) string {
+	if cfg.Transport != "" {
+		return cfg.Transport
+	}
+	if cfg.URL != "" {
+		return "http"
+	}
+	return "stdio"
+}
+
+func newClient(name string, t transport) *Client {
+	c := &Client{
+		name:    name,
+		t:       t,
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+func (c *Client) readLoop() {
+	defer func() {
+		c.mu.Lock()
+		close(c.done)
+		for id, ch := range c.pending {
+			close(ch)
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}()
+
+	for data := range c.t.incoming() {
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			logger.WarnCF("mcp", "Ignoring malformed message", map[string]any{
+				"server": c.name,
+				"error":  err.Error(),
+			})
+			continue
+		}
+
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			go c.handleServerRequest(&msg)
+		case msg.Method != "":
+			c.mu.Lock()
+			handler := c.onNotification
+			c.mu.Unlock()
+			if handler != nil {
+				handler(msg.Method, msg.Params)
+			}
+		case msg.ID != nil:
+			c.mu.Lock()
+			ch, ok := c.pending[string(*msg.ID)]
+			delete(c.pending, string(*msg.ID))
+			c.mu.Unlock()
+			if ok {
+				ch <- &msg
+			}
+		}
+	}
+}
+
+// handleServerRequest answers requests the server sends to the client.
+// Only ping is supported; everything else gets "method not found".
+func (c *Client) handleServerRequest(msg *message) {
+	resp := Response{JSONRPC: "2.0", ID: msg.ID}
+	if msg.Method == "ping" {
+		resp.Result = json.RawMessage(`{}`)
+	} else {
+		resp.Error = &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	data, _ := json.Marshal(resp)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c.t.send(ctx, data)
+}
+
+// SetNotificationHandler registers fn to receive server notifications.
+func (c *Client) SetNotificationHandler(fn func(method string, params json.RawMessage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onNotification = fn
+}
+
+// Done is closed when the connection to the server is lost or closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// ServerInfo returns the result of the initialize handshake.
+func (c *Client) ServerInfo() InitializeResult {
+	return c.info
+}
+
+// Close shuts down the connection and, for stdio servers, the process.
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	req := Request{JSONRPC: "2.0", ID: &id, Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = raw
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan *message, 1)
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		return ErrClosed
+	default:
+	}
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+
+	if err := c.t.send(ctx, data); err != nil {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+		return err
+	}
+
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			return ErrClosed
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+		c.notify(context.Background(), "notifications/cancelled", map[string]any{
+			"requestId": id,
+			"reason":    ctx.Err().Error(),
+		})
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(ctx context.Context, method string, params any) error {
+	req := Request{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = raw
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return c.t.send(ctx, data)
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      Implementation{Name: "picoclaw", Version: "1.0"},
+	}
+	if err := c.call(ctx, "initialize", params, &c.info); err != nil {
+		return err
+	}
+	return c.notify(ctx, "notifications/initialized", nil)
+}
+
+// ListTools returns all tools of the server, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var all []ToolInfo
+	cursor := ""
+	for {
+		var res ListToolsResult
+		if err := c.call(ctx, "tools/list", cursorParams{Cursor: cursor}, &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Tools...)
+		if res.NextCursor == "" {
+			return all, nil
+		}
+		cursor = res.NextCursor
+	}
+}
+
+// CallTool invokes a tool by its server-side name.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	var res CallToolResult
+	if err := c.call(ctx, "tools/call", CallToolParams{Name: name, Arguments: args}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListResources returns all resources of the server, following pagination.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var all []Resource
+	cursor := ""
+	for {
+		var res ListResourcesResult
+		if err := c.call(ctx, "resources/list", cursorParams{Cursor: cursor}, &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Resources...)
+		if res.NextCursor == "" {
+			return all, nil
+		}
+		cursor = res.NextCursor
+	}
+}
+
+// ReadResource fetches the contents of a resource.
+func (c *Client) ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error) {
+	var res ReadResourceResult
+	if err := c.call(ctx, "resources/read", ReadResourceParams{URI: uri}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListPrompts returns all prompts of the server, following pagination.
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var all []Prompt
+	cursor := ""
+	for {
+		var res ListPromptsResult
+		if err := c.call(ctx, "prompts/list", cursorParams{Cursor: cursor}, &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Prompts...)
+		if res.NextCursor == "" {
+			return all, nil
+		}
+		cursor = res.NextCursor
+	}
+}
+
+// GetPrompt renders a prompt with the given arguments.
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var res GetPromptResult
+	if err := c.call(ctx, "prompts/get", GetPromptParams{Name: name, Arguments: args}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,195 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func stdioStubConfig() config.MCPServerConfig {
+	return config.MCPServerConfig{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^$"},
+		Env:     map[string]string{stubEnv: "1"},
+	}
+}
+
+func TestClient_Stdio(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := Connect(ctx, "stub", stdioStubConfig())
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer c.Close()
+
+	if c.ServerInfo().ServerInfo.Name != "stub" {
+		t.Errorf("ServerInfo = %+v", c.ServerInfo())
+	}
+
+	toolList, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools() error = %v", err)
+	}
+	if len(toolList) != 4 {
+		t.Errorf("ListTools() = %d tools, want 4 across two pages", len(toolList))
+	}
+
+	res, err := c.CallTool(ctx, "echo", map[string]any{"text": "hi"})
+	if err != nil {
+		t.Fatalf("CallTool() error = %v", err)
+	}
+	if formatContent(res.Content) != "hi" {
+		t.Errorf("CallTool() content = %+v", res.Content)
+	}
+
+	var rpcErr *RPCError
+	if _, err := c.CallTool(ctx, "nope", nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("CallTool(nope) error = %v, want RPC error", err)
+	}
+}
+
+func TestClient_StreamableHTTP(t *testing.T) {
+	for _, sse := range []bool{false, true} {
+		server := newHTTPStub(t, sse)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		c, err := Connect(ctx, "remote", config.MCPServerConfig{
+			URL:     server.URL,
+			Headers: map[string]string{"Authorization": "Bearer token"},
+		})
+		if err != nil {
+			t.Fatalf("sse=%v: Connect() error = %v", sse, err)
+		}
+
+		res, err := c.ReadResource(ctx, "file:///hello.txt")
+		if err != nil || len(res.Contents) != 1 || res.Contents[0].Text != "hello world" {
+			t.Errorf("sse=%v: ReadResource() = %+v, %v", sse, res, err)
+		}
+		prompt, err := c.GetPrompt(ctx, "greet", map[string]string{"who": "Ada"})
+		if err != nil || prompt.Messages[0].Content.Text != "Say hello to Ada" {
+			t.Errorf("sse=%v: GetPrompt() = %+v, %v", sse, prompt, err)
+		}
+
+		c.Close()
+		cancel()
+		server.Close()
+	}
+}
+
+func TestClient_HTTPAuthFailure(t *testing.T) {
+	server := newHTTPStub(t, false)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := Connect(ctx, "remote", config.MCPServerConfig{URL: server.URL}); err == nil ||
+		!strings.Contains(err.Error(), "401") {
+		t.Errorf("Connect() error = %v, want 401", err)
+	}
+}
+
+func TestManager_ToolsAndReconnect(t *testing.T) {
+	prefix := "stub_"
+	cfg := stdioStubConfig()
+	cfg.ToolPrefix = &prefix
+	cfg.Tools = []string{"echo", "fail", "crash", "weird.name/v2"}
+	cfg.Agents = []string{"main"}
+
+	m := NewManager(config.MCPConfig{
+		Enabled: true,
+		Servers: map[string]config.MCPServerConfig{
+			"stub":     cfg,
+			"disabled": {Disabled: true, Command: "/nonexistent"},
+		},
+	})
+	var connects atomic.Int32
+	m.Start(context.Background(), func(*Server) { connects.Add(1) })
+	defer m.Close()
+
+	if len(m.Servers()) != 1 || connects.Load() != 1 {
+		t.Fatalf("servers = %d, connects = %d", len(m.Servers()), connects.Load())
+	}
+	s := m.Servers()[0]
+	if !s.AllowsAgent("main") || s.AllowsAgent("other") {
+		t.Error("agent allowlist not applied")
+	}
+
+	names := map[string]bool{}
+	for _, tool := range s.Tools() {
+		names[tool.Name()] = true
+	}
+	for _, want := range []string{"stub_echo", "stub_fail", "stub_weird_name_v2", "stub_resources", "stub_prompts"} {
+		if !names[want] {
+			t.Errorf("missing tool %q in %v", want, names)
+		}
+	}
+
+	ctx := context.Background()
+	echo := &Tool{server: s, info: ToolInfo{Name: "echo"}, name: "stub_echo"}
+	if r := echo.Execute(ctx, map[string]any{"text": "ping"}); r.IsError || r.ForLLM != "ping" {
+		t.Errorf("echo = %+v", r)
+	}
+	fail := &Tool{server: s, info: ToolInfo{Name: "fail"}, name: "stub_fail"}
+	if r := fail.Execute(ctx, nil); !r.IsError || r.ForLLM != "it broke" {
+		t.Errorf("fail = %+v", r)
+	}
+
+	// Crashing the server drops the connection; the manager reconnects and
+	// the next call succeeds.
+	crash := &Tool{server: s, info: ToolInfo{Name: "crash"}, name: "stub_crash"}
+	if r := crash.Execute(ctx, nil); !r.IsError {
+		t.Error("crash should fail")
+	}
+	if r := echo.Execute(ctx, map[string]any{"text": "again"}); r.IsError || r.ForLLM != "again" {
+		t.Errorf("echo after reconnect = %+v", r)
+	}
+	if connects.Load() < 2 {
+		t.Errorf("connects = %d, want a reconnect", connects.Load())
+	}
+
+	resources := &ResourcesTool{server: s, name: "stub_resources"}
+	if r := resources.Execute(ctx, map[string]any{"action": "read", "uri": "file:///hello.txt"}); r.IsError ||
+		!strings.Contains(r.ForLLM, "hello world") {
+		t.Errorf("resources read = %+v", r)
+	}
+	prompts := &PromptsTool{server: s, name: "stub_prompts"}
+	if r := prompts.Execute(ctx, map[string]any{"action": "list"}); r.IsError || !strings.Contains(r.ForLLM, "greet") {
+		t.Errorf("prompts list = %+v", r)
+	}
+}
+
+func TestToolName(t *testing.T) {
+	if got := toolName("mcp_fs_", "read.file"); got != "mcp_fs_read_file" {
+		t.Errorf("toolName() = %q", got)
+	}
+	if got := toolName("mcp_x_", strings.Repeat("a", 100)); len(got) != maxToolNameLen {
+		t.Errorf("toolName() length = %d", len(got))
+	}
+}
+
+func TestServer_Owns(t *testing.T) {
+	empty := ""
+	a := newServer("a", config.MCPServerConfig{ToolPrefix: &empty})
+	b := newServer("b", config.MCPServerConfig{ToolPrefix: &empty})
+	a.tools = []ToolInfo{{Name: "search"}}
+	b.tools = []ToolInfo{{Name: "search"}}
+
+	tool := a.Tools()[0]
+	if !a.Owns(tool) {
+		t.Error("a should own its own tool")
+	}
+	if b.Owns(tool) {
+		t.Error("b should not own a's tool of the same name")
+	}
+	if a.Owns(&ResourcesTool{server: b, name: "resources"}) {
+		t.Error("a should not own b's resources tool")
+	}
+}
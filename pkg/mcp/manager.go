@@ -0,0 +1,379 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	connectTimeout    = 30 * time.Second
+	reconnectWait     = 5 * time.Second
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// Manager owns the connections to all configured MCP servers and keeps them
+// alive, reconnecting with backoff when a server goes away.
+type Manager struct {
+	servers []*Server
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewManager creates a manager for the enabled servers in cfg. Nothing is
+// started until Start is called.
+func NewManager(cfg config.MCPConfig) *Manager {
+	m := &Manager{}
+	if !cfg.Enabled {
+		return m
+	}
+	names := make([]string, 0, len(cfg.Servers))
+	for name := range cfg.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sc := cfg.Servers[name]; !sc.Disabled {
+			m.servers = append(m.servers, newServer(name, sc))
+		}
+	}
+	return m
+}
+
+// Servers returns the managed servers sorted by name.
+func (m *Manager) Servers() []*Server {
+	return m.servers
+}
+
+// Start connects to every server in parallel and waits for the initial
+// attempts, so tools of reachable servers are available on return. onTools
+// is called after each successful connect and whenever a server's tool list
+// changes. Servers that fail keep retrying in the background.
+func (m *Manager) Start(ctx context.Context, onTools func(*Server)) {
+	if len(m.servers) == 0 {
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	var initial sync.WaitGroup
+	for _, s := range m.servers {
+		s.onTools = onTools
+		initial.Add(1)
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			s.run(ctx, initial.Done)
+		}()
+	}
+	initial.Wait()
+}
+
+// Close disconnects all servers and stops reconnecting.
+func (m *Manager) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	for _, s := range m.servers {
+		s.disconnect()
+	}
+	m.wg.Wait()
+}
+
+// Server is one configured MCP server and its current connection.
+type Server struct {
+	name string
+	cfg  config.MCPServerConfig
+
+	onTools func(*Server)
+
+	mu     sync.Mutex
+	client *Client
+	tools  []ToolInfo
+	ready  chan struct{} // closed when a client is connected
+}
+
+func newServer(name string, cfg config.MCPServerConfig) *Server {
+	return &Server{name: name, cfg: cfg, ready: make(chan struct{})}
+}
+
+// Name returns the server name from the config.
+func (s *Server) Name() string {
+	return s.name
+}
+
+// Prefix returns the string prepended to tool names from this server.
+func (s *Server) Prefix() string {
+	if s.cfg.ToolPrefix != nil {
+		return *s.cfg.ToolPrefix
+	}
+	return "mcp_" + s.name + "_"
+}
+
+// AllowsAgent reports whether the server's tools should be given to agentID.
+func (s *Server) AllowsAgent(agentID string) bool {
+	return len(s.cfg.Agents) == 0 || slices.Contains(s.cfg.Agents, agentID)
+}
+
+// Connected reports whether the server currently has a live connection.
+func (s *Server) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.client != nil
+}
+
+// run keeps the server connected until ctx is done. started is called once
+// the first connection attempt has finished.
+func (s *Server) run(ctx context.Context, started func()) {
+	delay := minReconnectDelay
+	first := true
+	for {
+		client, err := s.connect(ctx)
+		if first {
+			started()
+			first = false
+		}
+		if err != nil {
+			logger.WarnCF("mcp", "Failed to connect to MCP server", map[string]any{
+				"server":   s.name,
+				"error":    err.Error(),
+				"retry_in": delay.String(),
+			})
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxReconnectDelay)
+			continue
+		}
+		delay = minReconnectDelay
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-client.Done():
+			s.dropClient(client)
+			logger.WarnCF("mcp", "MCP server disconnected, reconnecting", map[string]any{"server": s.name})
+		}
+	}
+}
+
+func (s *Server) connect(ctx context.Context) (*Client, error) {
+	connCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	client, err := Connect(connCtx, s.name, s.cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.SetNotificationHandler(func(method string, _ json.RawMessage) {
+		if method == "notifications/tools/list_changed" {
+			go s.refreshTools(client)
+		}
+	})
+
+	var toolList []ToolInfo
+	if client.ServerInfo().Capabilities.Tools != nil {
+		if toolList, err = client.ListTools(connCtx); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("listing tools: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.client = client
+	s.tools = toolList
+	close(s.ready)
+	s.mu.Unlock()
+
+	logger.InfoCF("mcp", "Connected to MCP server", map[string]any{
+		"server":  s.name,
+		"name":    client.ServerInfo().ServerInfo.Name,
+		"version": client.ServerInfo().ServerInfo.Version,
+		"tools":   len(toolList),
+	})
+	if s.onTools != nil {
+		s.onTools(s)
+	}
+	return client, nil
+}
+
+func (s *Server) refreshTools(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	toolList, err := client.ListTools(ctx)
+	if err != nil {
+		logger.WarnCF("mcp", "Failed to refresh MCP tools", map[string]any{"server": s.name, "error": err.Error()})
+		return
+	}
+	s.mu.Lock()
+	s.tools = toolList
+	s.mu.Unlock()
+	if s.onTools != nil {
+		s.onTools(s)
+	}
+}
+
+func (s *Server) dropClient(client *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == client {
+		s.client = nil
+		s.ready = make(chan struct{})
+	}
+}
+
+func (s *Server) disconnect() {
+	s.mu.Lock()
+	client := s.client
+	s.mu.Unlock()
+	if client != nil {
+		client.Close()
+		s.dropClient(client)
+	}
+}
+
+// getClient returns the live client, waiting up to wait for a reconnect.
+func (s *Server) getClient(ctx context.Context, wait time.Duration) (*Client, error) {
+	s.mu.Lock()
+	client, ready := s.client, s.ready
+	s.mu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.client != nil {
+			return s.client, nil
+		}
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return nil, fmt.Errorf("mcp server %q is not connected", s.name)
+}
+
+// do runs fn against the live client with the per-request timeout. If the
+// connection drops during the call, it waits for the reconnect and retries once.
+func (s *Server) do(ctx context.Context, fn func(ctx context.Context, c *Client) error) error {
+	timeout := defaultCallTimeout
+	if s.cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(s.cfg.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := s.getClient(ctx, reconnectWait)
+	if err != nil {
+		return err
+	}
+	err = fn(ctx, client)
+	if !errors.Is(err, ErrClosed) {
+		return err
+	}
+
+	client.Close()
+	s.dropClient(client)
+	if client, err = s.getClient(ctx, reconnectWait); err != nil {
+		return err
+	}
+	return fn(ctx, client)
+}
+
+// ToolInfos returns the tools last listed by the server, filtered by the
+// tools allowlist in the config.
+func (s *Server) ToolInfos() []ToolInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []ToolInfo
+	for _, t := range s.tools {
+		if len(s.cfg.Tools) == 0 || slices.Contains(s.cfg.Tools, t.Name) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Capabilities returns what the connected server supports.
+func (s *Server) Capabilities() ServerCapabilities {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return ServerCapabilities{}
+	}
+	return s.client.ServerInfo().Capabilities
+}
+
+// CallTool invokes a tool by its server-side name.
+func (s *Server) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	var res *CallToolResult
+	err := s.do(ctx, func(ctx context.Context, c *Client) error {
+		var err error
+		res, err = c.CallTool(ctx, name, args)
+		return err
+	})
+	return res, err
+}
+
+// ListResources lists the server's resources.
+func (s *Server) ListResources(ctx context.Context) ([]Resource, error) {
+	var res []Resource
+	err := s.do(ctx, func(ctx context.Context, c *Client) error {
+		var err error
+		res, err = c.ListResources(ctx)
+		return err
+	})
+	return res, err
+}
+
+// ReadResource reads one resource.
+func (s *Server) ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error) {
+	var res *ReadResourceResult
+	err := s.do(ctx, func(ctx context.Context, c *Client) error {
+		var err error
+		res, err = c.ReadResource(ctx, uri)
+		return err
+	})
+	return res, err
+}
+
+// ListPrompts lists the server's prompts.
+func (s *Server) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var res []Prompt
+	err := s.do(ctx, func(ctx context.Context, c *Client) error {
+		var err error
+		res, err = c.ListPrompts(ctx)
+		return err
+	})
+	return res, err
+}
+
+// GetPrompt renders one prompt.
+func (s *Server) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var res *GetPromptResult
+	err := s.do(ctx, func(ctx context.Context, c *Client) error {
+		var err error
+		res, err = c.GetPrompt(ctx, name, args)
+		return err
+	})
+	return res, err
+}
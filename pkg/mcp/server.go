@@ -0,0 +1,319 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/approval"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// ToolServer exposes the tools of a ToolRegistry to MCP clients over stdio
+// or streamable HTTP.
+type ToolServer struct {
+	info     Implementation
+	registry *tools.ToolRegistry
+	channel  string
+	chatID   string
+
+	approvals *approval.Manager
+	agentID   string
+
+	mu       sync.Mutex
+	sessions map[string]struct{}
+}
+
+// NewToolServer creates a server for registry. Tools that implement
+// ContextualTool see channel and chatID as their message context.
+func NewToolServer(info Implementation, registry *tools.ToolRegistry, channel, chatID string) *ToolServer {
+	return &ToolServer{
+		info:     info,
+		registry: registry,
+		channel:  channel,
+		chatID:   chatID,
+		sessions: make(map[string]struct{}),
+	}
+}
+
+// SetApprovals applies the tool approval policy of agentID to calls. Tools
+// the policy never allows are not served, and calls that need approval are
+// refused: MCP clients have no way to answer an approval prompt.
+func (s *ToolServer) SetApprovals(m *approval.Manager, agentID string) {
+	s.approvals = m
+	s.agentID = agentID
+	if m != nil {
+		m.SetPrompter(s.channel, func(context.Context, *approval.Request) error {
+			return errors.New("MCP clients cannot answer approval prompts")
+		})
+	}
+}
+
+// exposed reports whether a registered tool can be served. Async tools are
+// left out because their results arrive after the MCP call has returned.
+func (s *ToolServer) exposed(name string) bool {
+	tool, ok := s.registry.Get(name)
+	if !ok {
+		return false
+	}
+	if s.approvals != nil && s.approvals.Mode(name) == approval.ModeNever {
+		return false
+	}
+	_, async := tool.(tools.AsyncTool)
+	return !async
+}
+
+// Handle processes one request and returns the response, or nil for
+// notifications.
+func (s *ToolServer) Handle(ctx context.Context, req *Request) *Response {
+	if req.IsNotification() {
+		return nil
+	}
+	resp := &Response{JSONRPC: "2.0", ID: req.ID}
+	result, rpcErr := s.dispatch(ctx, req)
+	if rpcErr != nil {
+		resp.Error = rpcErr
+		return resp
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = &RPCError{Code: CodeInternalError, Message: err.Error()}
+		return resp
+	}
+	resp.Result = raw
+	return resp
+}
+
+func (s *ToolServer) dispatch(ctx context.Context, req *Request) (any, *RPCError) {
+	switch req.Method {
+	case "initialize":
+		var params InitializeParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+			}
+		}
+		version := params.ProtocolVersion
+		if version == "" {
+			version = ProtocolVersion
+		}
+		return InitializeResult{
+			ProtocolVersion: version,
+			Capabilities:    ServerCapabilities{Tools: &struct{}{}},
+			ServerInfo:      s.info,
+		}, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "tools/list":
+		var list []ToolInfo
+		for _, def := range s.registry.ToProviderDefs() {
+			if !s.exposed(def.Function.Name) {
+				continue
+			}
+			list = append(list, ToolInfo{
+				Name:        def.Function.Name,
+				Description: def.Function.Description,
+				InputSchema: def.Function.Parameters,
+			})
+		}
+		return ListToolsResult{Tools: list}, nil
+
+	case "tools/call":
+		var params CallToolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		if !s.exposed(params.Name) {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: "unknown tool: " + params.Name}
+		}
+		if params.Arguments == nil {
+			params.Arguments = map[string]any{}
+		}
+		if s.approvals != nil {
+			err := s.approvals.Check(ctx, approval.Call{
+				AgentID:    s.agentID,
+				SessionKey: s.channel + ":" + s.chatID,
+				Channel:    s.channel,
+				ChatID:     s.chatID,
+				Tool:       params.Name,
+				Args:       params.Arguments,
+			})
+			if err != nil {
+				msg := fmt.Sprintf("%s was not run: %v", params.Name, err)
+				return toCallToolResult(tools.ErrorResult(msg).WithError(err)), nil
+			}
+		}
+		result := s.registry.ExecuteWithContext(ctx, params.Name, params.Arguments, s.channel, s.chatID, nil)
+		return toCallToolResult(result), nil
+
+	default:
+		return nil, &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+func toCallToolResult(result *tools.ToolResult) CallToolResult {
+	text := result.ForLLM
+	if len(result.Media) > 0 {
+		text += "\n[media: " + strings.Join(result.Media, ", ") + "]"
+	}
+	return CallToolResult{Content: []Content{TextContent(text)}, IsError: result.IsError}
+}
+
+// ServeStdio reads newline-delimited requests from in and writes responses
+// to out until in is exhausted or ctx is done. Requests run concurrently;
+// notifications/cancelled aborts the named request.
+func (s *ToolServer) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		writeMu  sync.Mutex
+		wg       sync.WaitGroup
+		cancelMu sync.Mutex
+		inFlight = make(map[string]context.CancelFunc)
+	)
+	write := func(resp *Response) {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		out.Write(append(data, '\n'))
+	}
+
+	reader := bufio.NewReaderSize(in, 64*1024)
+	for {
+		line, err := readLine(reader)
+		if trimmed := strings.TrimSpace(string(line)); trimmed != "" {
+			var req Request
+			if jsonErr := json.Unmarshal([]byte(trimmed), &req); jsonErr != nil {
+				write(&Response{JSONRPC: "2.0", Error: &RPCError{Code: CodeParseError, Message: jsonErr.Error()}})
+			} else if req.Method == "notifications/cancelled" {
+				var p struct {
+					RequestID json.RawMessage `json:"requestId"`
+				}
+				json.Unmarshal(req.Params, &p)
+				cancelMu.Lock()
+				if cancelReq, ok := inFlight[string(p.RequestID)]; ok {
+					cancelReq()
+				}
+				cancelMu.Unlock()
+			} else if !req.IsNotification() {
+				reqCtx, cancelReq := context.WithCancel(ctx)
+				id := string(*req.ID)
+				cancelMu.Lock()
+				inFlight[id] = cancelReq
+				cancelMu.Unlock()
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() {
+						cancelMu.Lock()
+						delete(inFlight, id)
+						cancelMu.Unlock()
+						cancelReq()
+					}()
+					write(s.Handle(reqCtx, &req))
+				}()
+			}
+		}
+		if err != nil {
+			wg.Wait()
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			wg.Wait()
+			return nil
+		}
+	}
+}
+
+// ServeHTTP implements the streamable HTTP transport. Responses are always
+// plain JSON; the server never initiates messages, so GET is not supported.
+func (s *ToolServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.Header.Get("Mcp-Session-Id")
+
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.sessions, sessionID)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxMessageBytes)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, &Response{
+			JSONRPC: "2.0",
+			Error:   &RPCError{Code: CodeParseError, Message: err.Error()},
+		})
+		return
+	}
+
+	if req.Method == "initialize" {
+		sessionID = uuid.NewString()
+		s.mu.Lock()
+		s.sessions[sessionID] = struct{}{}
+		s.mu.Unlock()
+		w.Header().Set("Mcp-Session-Id", sessionID)
+	} else {
+		s.mu.Lock()
+		_, known := s.sessions[sessionID]
+		s.mu.Unlock()
+		if !known {
+			// Unknown sessions get 404 so clients start over with initialize.
+			status := http.StatusNotFound
+			if sessionID == "" {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, "invalid or missing Mcp-Session-Id", status)
+			return
+		}
+	}
+
+	resp := s.Handle(r.Context(), &req)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logger.ErrorCF("mcp", "Failed to encode response", map[string]any{"error": err.Error()})
+		http.Error(w, fmt.Sprintf("encoding response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
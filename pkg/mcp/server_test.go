@@ -0,0 +1,188 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/approval"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+type asyncStub struct{}
+
+func (asyncStub) Name() string               { return "background" }
+func (asyncStub) Description() string        { return "async" }
+func (asyncStub) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (asyncStub) Execute(context.Context, map[string]any) *tools.ToolResult {
+	return tools.AsyncResult("started")
+}
+func (asyncStub) SetCallback(tools.AsyncCallback) {}
+
+func newTestToolServer(t *testing.T) (*ToolServer, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "notes.txt"), []byte("remember the milk"), 0o644)
+
+	registry := tools.NewToolRegistry()
+	registry.Register(tools.NewReadFileTool(workspace, true))
+	registry.Register(asyncStub{})
+	return NewToolServer(Implementation{Name: "picoclaw", Version: "test"}, registry, "mcp", "main"), workspace
+}
+
+func TestToolServer_Stdio(t *testing.T) {
+	server, _ := newTestToolServer(t)
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"read_file","arguments":{"path":"notes.txt"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"resources/list"}`,
+		`not json`,
+	}, "\n")
+	var out bytes.Buffer
+	if err := server.ServeStdio(context.Background(), strings.NewReader(in), &out); err != nil {
+		t.Fatalf("ServeStdio() error = %v", err)
+	}
+
+	responses := map[string]Response{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var resp Response
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("bad output line %q: %v", line, err)
+		}
+		id := "null"
+		if resp.ID != nil {
+			id = string(*resp.ID)
+		}
+		responses[id] = resp
+	}
+	if len(responses) != 5 {
+		t.Fatalf("got %d responses, want 5 (no reply to the notification): %s", len(responses), out.String())
+	}
+
+	var list ListToolsResult
+	json.Unmarshal(responses["2"].Result, &list)
+	if len(list.Tools) != 1 || list.Tools[0].Name != "read_file" {
+		t.Errorf("tools/list = %+v, want only read_file (async tools hidden)", list.Tools)
+	}
+
+	var call CallToolResult
+	json.Unmarshal(responses["3"].Result, &call)
+	if call.IsError || !strings.Contains(call.Content[0].Text, "remember the milk") {
+		t.Errorf("tools/call = %+v", call)
+	}
+	if responses["4"].Error == nil || responses["4"].Error.Code != CodeMethodNotFound {
+		t.Errorf("resources/list error = %+v", responses["4"].Error)
+	}
+	if responses["null"].Error == nil || responses["null"].Error.Code != CodeParseError {
+		t.Errorf("parse error response = %+v", responses["null"])
+	}
+}
+
+func TestToolServer_HTTPRoundTrip(t *testing.T) {
+	server, _ := newTestToolServer(t)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Connect(ctx, "self", config.MCPServerConfig{URL: httpServer.URL})
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+
+	if client.ServerInfo().ServerInfo.Name != "picoclaw" {
+		t.Errorf("ServerInfo = %+v", client.ServerInfo())
+	}
+
+	// The workspace restriction of the served tool still applies.
+	res, err := client.CallTool(ctx, "read_file", map[string]any{"path": "/etc/passwd"})
+	if err != nil {
+		t.Fatalf("CallTool() error = %v", err)
+	}
+	if !res.IsError {
+		t.Errorf("reading outside the workspace should fail: %+v", res)
+	}
+
+	if _, err := client.CallTool(ctx, "background", nil); err == nil {
+		t.Error("async tools should not be callable")
+	}
+}
+
+func TestToolServer_HTTPRequiresSession(t *testing.T) {
+	server, _ := newTestToolServer(t)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`
+	resp, err := http.Post(httpServer.URL, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status without session = %d, want 400", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, httpServer.URL, strings.NewReader(body))
+	req.Header.Set("Mcp-Session-Id", "stale")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status with unknown session = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestToolServer_Approvals(t *testing.T) {
+	server, workspace := newTestToolServer(t)
+	server.registry.Register(tools.NewListDirTool(workspace, true))
+	approvals, err := approval.NewManager(config.ApprovalConfig{
+		Enabled: true,
+		Tools:   map[string]string{"read_file": "ask", "list_dir": "never"},
+	}, t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetApprovals(approvals, "main")
+
+	ctx := context.Background()
+	result, rpcErr := server.dispatch(ctx, &Request{Method: "tools/list"})
+	if rpcErr != nil {
+		t.Fatalf("tools/list error = %+v", rpcErr)
+	}
+	for _, tool := range result.(ListToolsResult).Tools {
+		if tool.Name == "list_dir" {
+			t.Error("tools/list should hide tools the policy never allows")
+		}
+	}
+
+	call := func(name string) (any, *RPCError) {
+		params, _ := json.Marshal(CallToolParams{Name: name, Arguments: map[string]any{"path": "notes.txt"}})
+		return server.dispatch(ctx, &Request{Method: "tools/call", Params: params})
+	}
+	if _, rpcErr := call("list_dir"); rpcErr == nil {
+		t.Error("tools/call should reject tools the policy never allows")
+	}
+	result, rpcErr = call("read_file")
+	if rpcErr != nil {
+		t.Fatalf("tools/call error = %+v", rpcErr)
+	}
+	res := result.(CallToolResult)
+	if !res.IsError || strings.Contains(res.Content[0].Text, "remember the milk") {
+		t.Errorf("a call that needs approval should be refused, got %+v", res)
+	}
+}
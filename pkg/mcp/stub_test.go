@@ -0,0 +1,161 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// The stub MCP server runs in a re-executed test binary for stdio tests and
+// behind httptest for HTTP tests.
+const stubEnv = "PICOCLAW_MCP_STUB_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stubEnv) == "1" {
+		runStdioStub()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func runStdioStub() {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			continue
+		}
+		if req.Method == "tools/call" {
+			var p CallToolParams
+			json.Unmarshal(req.Params, &p)
+			if p.Name == "crash" {
+				os.Exit(3)
+			}
+		}
+		if resp := stubHandle(&req); resp != nil {
+			data, _ := json.Marshal(resp)
+			fmt.Fprintf(os.Stdout, "%s\n", data)
+		}
+	}
+}
+
+// stubHandle answers one request the way a small MCP server would.
+func stubHandle(req *Request) *Response {
+	if req.IsNotification() {
+		return nil
+	}
+	resp := &Response{JSONRPC: "2.0", ID: req.ID}
+	var result any
+	switch req.Method {
+	case "initialize":
+		result = map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}, "resources": map[string]any{}, "prompts": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "stub", "version": "0.1"},
+		}
+	case "tools/list":
+		var p cursorParams
+		json.Unmarshal(req.Params, &p)
+		// Two pages to exercise pagination.
+		if p.Cursor == "" {
+			result = ListToolsResult{
+				Tools: []ToolInfo{{
+					Name:        "echo",
+					Description: "Echo text back",
+					InputSchema: map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"text": map[string]any{"type": "string"}},
+					},
+				}},
+				NextCursor: "page2",
+			}
+		} else {
+			result = ListToolsResult{Tools: []ToolInfo{
+				{Name: "fail", Description: "Always fails"},
+				{Name: "crash", Description: "Exits the server"},
+				{Name: "weird.name/v2"},
+			}}
+		}
+	case "tools/call":
+		var p CallToolParams
+		json.Unmarshal(req.Params, &p)
+		switch p.Name {
+		case "echo":
+			result = CallToolResult{Content: []Content{TextContent(fmt.Sprint(p.Arguments["text"]))}}
+		case "fail":
+			result = CallToolResult{Content: []Content{TextContent("it broke")}, IsError: true}
+		default:
+			resp.Error = &RPCError{Code: CodeInvalidParams, Message: "unknown tool " + p.Name}
+		}
+	case "resources/list":
+		result = ListResourcesResult{Resources: []Resource{{URI: "file:///hello.txt", Name: "hello"}}}
+	case "resources/read":
+		var p ReadResourceParams
+		json.Unmarshal(req.Params, &p)
+		result = ReadResourceResult{Contents: []ResourceContents{{URI: p.URI, Text: "hello world"}}}
+	case "prompts/list":
+		result = ListPromptsResult{Prompts: []Prompt{{
+			Name:      "greet",
+			Arguments: []PromptArgument{{Name: "who", Required: true}},
+		}}}
+	case "prompts/get":
+		var p GetPromptParams
+		json.Unmarshal(req.Params, &p)
+		result = GetPromptResult{Messages: []PromptMessage{{
+			Role:    "user",
+			Content: TextContent("Say hello to " + p.Arguments["who"]),
+		}}}
+	default:
+		resp.Error = &RPCError{Code: CodeMethodNotFound, Message: "method not found"}
+	}
+	if result != nil {
+		resp.Result, _ = json.Marshal(result)
+	}
+	return resp
+}
+
+// newHTTPStub serves the stub over streamable HTTP. With sse set, responses
+// are sent as an event stream instead of plain JSON.
+func newHTTPStub(t *testing.T, sse bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", "session-1")
+		} else if r.Header.Get("Mcp-Session-Id") != "session-1" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+
+		resp := stubHandle(&req)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := json.Marshal(resp)
+		if sse {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+}
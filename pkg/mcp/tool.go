@@ -0,0 +1,306 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// maxToolNameLen is the longest function name most LLM APIs accept.
+const maxToolNameLen = 64
+
+// Tools returns tool adapters for the server: one per allowed remote tool,
+// plus resource and prompt tools when the server supports them.
+func (s *Server) Tools() []tools.Tool {
+	var out []tools.Tool
+	for _, info := range s.ToolInfos() {
+		out = append(out, &Tool{server: s, info: info, name: toolName(s.Prefix(), info.Name)})
+	}
+	caps := s.Capabilities()
+	if caps.Resources != nil {
+		out = append(out, &ResourcesTool{server: s, name: toolName(s.Prefix(), "resources")})
+	}
+	if caps.Prompts != nil {
+		out = append(out, &PromptsTool{server: s, name: toolName(s.Prefix(), "prompts")})
+	}
+	return out
+}
+
+// Owns reports whether tool is one of the adapters returned by s.Tools.
+func (s *Server) Owns(tool tools.Tool) bool {
+	switch t := tool.(type) {
+	case *Tool:
+		return t.server == s
+	case *ResourcesTool:
+		return t.server == s
+	case *PromptsTool:
+		return t.server == s
+	}
+	return false
+}
+
+// toolName joins prefix and name, replacing characters that LLM APIs reject
+// in function names and truncating to maxToolNameLen.
+func toolName(prefix, name string) string {
+	var sb strings.Builder
+	for _, r := range prefix + name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	full := sb.String()
+	if len(full) > maxToolNameLen {
+		full = full[:maxToolNameLen]
+	}
+	return full
+}
+
+// Tool adapts a remote MCP tool to tools.Tool.
+type Tool struct {
+	server *Server
+	info   ToolInfo
+	name   string
+}
+
+func (t *Tool) Name() string {
+	return t.name
+}
+
+func (t *Tool) Description() string {
+	desc := t.info.Description
+	if desc == "" {
+		desc = t.info.Name
+	}
+	return fmt.Sprintf("[MCP %s] %s", t.server.name, desc)
+}
+
+func (t *Tool) Parameters() map[string]any {
+	schema := make(map[string]any, len(t.info.InputSchema)+2)
+	for k, v := range t.info.InputSchema {
+		schema[k] = v
+	}
+	if _, ok := schema["type"]; !ok {
+		schema["type"] = "object"
+	}
+	if _, ok := schema["properties"]; !ok {
+		schema["properties"] = map[string]any{}
+	}
+	return schema
+}
+
+func (t *Tool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	res, err := t.server.CallTool(ctx, t.info.Name, args)
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("MCP tool %s failed: %v", t.info.Name, err)).WithError(err)
+	}
+	text := formatContent(res.Content)
+	if res.IsError {
+		return tools.ErrorResult(text)
+	}
+	return tools.NewToolResult(text)
+}
+
+// formatContent renders tool result content as text for the LLM. Binary
+// items are summarized rather than inlined.
+func formatContent(content []Content) string {
+	parts := make([]string, 0, len(content))
+	for _, c := range content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "resource":
+			if c.Resource != nil {
+				parts = append(parts, formatResourceContents(*c.Resource))
+			}
+		default:
+			size := base64.StdEncoding.DecodedLen(len(c.Data))
+			parts = append(parts, fmt.Sprintf("[%s content: %s, ~%d bytes]", c.Type, c.MimeType, size))
+		}
+	}
+	if len(parts) == 0 {
+		return "(no output)"
+	}
+	return strings.Join(parts, "\n")
+}
+
+func formatResourceContents(rc ResourceContents) string {
+	if rc.Blob != "" {
+		return fmt.Sprintf("[resource %s: %s, ~%d bytes]",
+			rc.URI, rc.MimeType, base64.StdEncoding.DecodedLen(len(rc.Blob)))
+	}
+	return fmt.Sprintf("--- %s ---\n%s", rc.URI, rc.Text)
+}
+
+// ResourcesTool lists and reads the resources of a server.
+type ResourcesTool struct {
+	server *Server
+	name   string
+}
+
+func (t *ResourcesTool) Name() string {
+	return t.name
+}
+
+func (t *ResourcesTool) Description() string {
+	return fmt.Sprintf("[MCP %s] List the server's resources or read one by URI.", t.server.name)
+}
+
+func (t *ResourcesTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "read"},
+				"description": "list resources, or read the resource at uri",
+			},
+			"uri": map[string]any{
+				"type":        "string",
+				"description": "Resource URI (required for read)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ResourcesTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	action, _ := args["action"].(string)
+	switch action {
+	case "list":
+		resources, err := t.server.ListResources(ctx)
+		if err != nil {
+			return tools.ErrorResult(fmt.Sprintf("listing resources failed: %v", err)).WithError(err)
+		}
+		if len(resources) == 0 {
+			return tools.NewToolResult("No resources available.")
+		}
+		var sb strings.Builder
+		for _, r := range resources {
+			fmt.Fprintf(&sb, "- %s (%s)", r.URI, r.Name)
+			if r.Description != "" {
+				fmt.Fprintf(&sb, ": %s", r.Description)
+			}
+			sb.WriteString("\n")
+		}
+		return tools.NewToolResult(sb.String())
+	case "read":
+		uri, _ := args["uri"].(string)
+		if uri == "" {
+			return tools.ErrorResult("uri is required for read")
+		}
+		res, err := t.server.ReadResource(ctx, uri)
+		if err != nil {
+			return tools.ErrorResult(fmt.Sprintf("reading resource failed: %v", err)).WithError(err)
+		}
+		parts := make([]string, 0, len(res.Contents))
+		for _, rc := range res.Contents {
+			parts = append(parts, formatResourceContents(rc))
+		}
+		return tools.NewToolResult(strings.Join(parts, "\n"))
+	default:
+		return tools.ErrorResult(fmt.Sprintf("unknown action %q (use list or read)", action))
+	}
+}
+
+// PromptsTool lists and renders the prompt templates of a server.
+type PromptsTool struct {
+	server *Server
+	name   string
+}
+
+func (t *PromptsTool) Name() string {
+	return t.name
+}
+
+func (t *PromptsTool) Description() string {
+	return fmt.Sprintf("[MCP %s] List the server's prompt templates or render one with arguments.", t.server.name)
+}
+
+func (t *PromptsTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "get"},
+				"description": "list prompts, or get (render) the prompt called name",
+			},
+			"name": map[string]any{
+				"type":        "string",
+				"description": "Prompt name (required for get)",
+			},
+			"arguments": map[string]any{
+				"type":                 "object",
+				"description":          "Prompt arguments as string values",
+				"additionalProperties": map[string]any{"type": "string"},
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *PromptsTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	action, _ := args["action"].(string)
+	switch action {
+	case "list":
+		prompts, err := t.server.ListPrompts(ctx)
+		if err != nil {
+			return tools.ErrorResult(fmt.Sprintf("listing prompts failed: %v", err)).WithError(err)
+		}
+		if len(prompts) == 0 {
+			return tools.NewToolResult("No prompts available.")
+		}
+		var sb strings.Builder
+		for _, p := range prompts {
+			fmt.Fprintf(&sb, "- %s", p.Name)
+			if p.Description != "" {
+				fmt.Fprintf(&sb, ": %s", p.Description)
+			}
+			for _, a := range p.Arguments {
+				req := ""
+				if a.Required {
+					req = ", required"
+				}
+				fmt.Fprintf(&sb, "\n  - %s (argument%s) %s", a.Name, req, a.Description)
+			}
+			sb.WriteString("\n")
+		}
+		return tools.NewToolResult(sb.String())
+	case "get":
+		name, _ := args["name"].(string)
+		if name == "" {
+			return tools.ErrorResult("name is required for get")
+		}
+		promptArgs := map[string]string{}
+		if raw, ok := args["arguments"].(map[string]any); ok {
+			for k, v := range raw {
+				promptArgs[k] = fmt.Sprint(v)
+			}
+		}
+		res, err := t.server.GetPrompt(ctx, name, promptArgs)
+		if err != nil {
+			return tools.ErrorResult(fmt.Sprintf("getting prompt failed: %v", err)).WithError(err)
+		}
+		var sb strings.Builder
+		if res.Description != "" {
+			sb.WriteString(res.Description + "\n\n")
+		}
+		for _, m := range res.Messages {
+			fmt.Fprintf(&sb, "[%s]\n%s\n", m.Role, formatContent([]Content{m.Content}))
+		}
+		return tools.NewToolResult(sb.String())
+	default:
+		return tools.ErrorResult(fmt.Sprintf("unknown action %q (use list or get)", action))
+	}
+}
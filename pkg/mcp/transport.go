@@ -0,0 +1,411 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ErrClosed is returned by calls on a client whose connection has gone away.
+var ErrClosed = errors.New("mcp: connection closed")
+
+// maxMessageBytes bounds a single JSON-RPC message read from a server.
+const maxMessageBytes = 16 << 20
+
+// transport moves raw JSON-RPC messages between client and server.
+type transport interface {
+	send(ctx context.Context, data []byte) error
+	// incoming is closed when the connection ends.
+	incoming() <-chan []byte
+	close() error
+}
+
+// stdioTransport runs the server as a child process speaking newline-delimited
+// JSON on stdin/stdout.
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	in     chan []byte
+	writeM sync.Mutex
+	once   sync.Once
+}
+
+func newStdioTransport(name, command string, args []string, env map[string]string, dir string) (*stdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", command, err)
+	}
+
+	t := &stdioTransport{cmd: cmd, stdin: stdin, in: make(chan []byte, 16)}
+
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			logger.DebugCF("mcp", "Server stderr", map[string]any{"server": name, "line": scanner.Text()})
+		}
+	}()
+
+	go func() {
+		defer close(t.in)
+		reader := bufio.NewReaderSize(stdout, 64*1024)
+		for {
+			line, err := readLine(reader)
+			if len(bytes.TrimSpace(line)) > 0 {
+				t.in <- line
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					logger.WarnCF("mcp", "Reading server output failed", map[string]any{
+						"server": name,
+						"error":  err.Error(),
+					})
+				}
+				return
+			}
+		}
+	}()
+
+	return t, nil
+}
+
+// readLine reads one newline-terminated message, rejecting oversized ones.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var buf []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		buf = append(buf, chunk...)
+		if len(buf) > maxMessageBytes {
+			return nil, fmt.Errorf("message exceeds %d bytes", maxMessageBytes)
+		}
+		if err != nil || !isPrefix {
+			return buf, err
+		}
+	}
+}
+
+func (t *stdioTransport) send(_ context.Context, data []byte) error {
+	t.writeM.Lock()
+	defer t.writeM.Unlock()
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) incoming() <-chan []byte {
+	return t.in
+}
+
+func (t *stdioTransport) close() error {
+	t.once.Do(func() {
+		t.stdin.Close()
+		if t.cmd.Process != nil {
+			t.cmd.Process.Kill()
+		}
+		t.cmd.Wait()
+	})
+	return nil
+}
+
+// httpTransport implements the streamable HTTP transport: every message is
+// POSTed to one endpoint, which answers with JSON or an SSE stream.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+
+	in     chan []byte
+	done   chan struct{}
+	once   sync.Once
+	sendWG sync.WaitGroup
+}
+
+func newHTTPTransport(endpoint string, headers map[string]string, client *http.Client) *httpTransport {
+	return &httpTransport{
+		url:     endpoint,
+		headers: headers,
+		client:  client,
+		in:      make(chan []byte, 16),
+		done:    make(chan struct{}),
+	}
+}
+
+func (t *httpTransport) send(ctx context.Context, data []byte) error {
+	select {
+	case <-t.done:
+		return ErrClosed
+	default:
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted:
+		resp.Body.Close()
+		return nil
+	case resp.StatusCode == http.StatusNotFound && t.hasSession():
+		// The server dropped our session; the caller has to reconnect.
+		resp.Body.Close()
+		return fmt.Errorf("%w: session expired", ErrClosed)
+	case resp.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		resp.Body.Close()
+		return fmt.Errorf("mcp http: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		t.sendWG.Add(1)
+		go func() {
+			defer t.sendWG.Done()
+			defer resp.Body.Close()
+			readSSE(resp.Body, func(event, data string) {
+				if event == "" || event == "message" {
+					t.deliver([]byte(data))
+				}
+			})
+		}()
+		return nil
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageBytes))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		t.deliver(body)
+	}
+	return nil
+}
+
+func (t *httpTransport) hasSession() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID != ""
+}
+
+func (t *httpTransport) deliver(data []byte) {
+	select {
+	case t.in <- data:
+	case <-t.done:
+	}
+}
+
+func (t *httpTransport) incoming() <-chan []byte {
+	return t.in
+}
+
+func (t *httpTransport) close() error {
+	t.once.Do(func() {
+		close(t.done)
+		t.mu.Lock()
+		sessionID := t.sessionID
+		t.mu.Unlock()
+		if sessionID != "" {
+			// Best effort: tell the server the session is over.
+			if req, err := http.NewRequest(http.MethodDelete, t.url, nil); err == nil {
+				req.Header.Set("Mcp-Session-Id", sessionID)
+				for k, v := range t.headers {
+					req.Header.Set(k, v)
+				}
+				if resp, err := t.client.Do(req); err == nil {
+					resp.Body.Close()
+				}
+			}
+		}
+		go func() {
+			t.sendWG.Wait()
+			close(t.in)
+		}()
+	})
+	return nil
+}
+
+// sseTransport implements the older HTTP+SSE transport: a long-lived GET
+// stream carries server messages and announces the endpoint to POST to.
+type sseTransport struct {
+	headers  map[string]string
+	client   *http.Client
+	body     io.ReadCloser
+	endpoint string
+	in       chan []byte
+	once     sync.Once
+}
+
+func newSSETransport(ctx context.Context, streamURL string, headers map[string]string, client *http.Client) (*sseTransport, error) {
+	req, err := http.NewRequest(http.MethodGet, streamURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	// The stream outlives any request timeout, so use a client without one.
+	streamClient := &http.Client{Transport: client.Transport}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("mcp sse: status %d", resp.StatusCode)
+	}
+
+	t := &sseTransport{headers: headers, client: client, body: resp.Body, in: make(chan []byte, 16)}
+	endpointCh := make(chan string, 1)
+
+	go func() {
+		defer close(t.in)
+		readSSE(resp.Body, func(event, data string) {
+			switch event {
+			case "endpoint":
+				select {
+				case endpointCh <- data:
+				default:
+				}
+			case "", "message":
+				t.in <- []byte(data)
+			}
+		})
+	}()
+
+	select {
+	case ep := <-endpointCh:
+		base, _ := url.Parse(streamURL)
+		ref, err := url.Parse(ep)
+		if err != nil {
+			t.close()
+			return nil, fmt.Errorf("mcp sse: invalid endpoint %q", ep)
+		}
+		t.endpoint = base.ResolveReference(ref).String()
+	case <-ctx.Done():
+		t.close()
+		return nil, ctx.Err()
+	}
+
+	return t, nil
+}
+
+func (t *sseTransport) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("mcp sse: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (t *sseTransport) incoming() <-chan []byte {
+	return t.in
+}
+
+func (t *sseTransport) close() error {
+	t.once.Do(func() {
+		t.body.Close()
+	})
+	return nil
+}
+
+// readSSE parses a text/event-stream and calls fn for every event.
+func readSSE(r io.Reader, fn func(event, data string)) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageBytes)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				fn(event, strings.Join(data, "\n"))
+			}
+			event, data = "", nil
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if len(data) > 0 {
+		fn(event, strings.Join(data, "\n"))
+	}
+}
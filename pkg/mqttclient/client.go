@@ -0,0 +1,175 @@
+// Package mqttclient connects to the MQTT broker configured under
+// channels.mqtt. It is shared by the mqtt channel and the mqtt_publish tool.
+package mqttclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ConnectTimeout bounds a single connection attempt.
+const ConnectTimeout = 10 * time.Second
+
+// NewOptions builds client options for cfg. The client ID is cfg.ClientID
+// plus suffix, or a random "picoclaw-" ID when cfg.ClientID is empty; two
+// clients with the same ID would keep disconnecting each other.
+func NewOptions(cfg config.MQTTConfig, suffix string) (*paho.ClientOptions, error) {
+	if cfg.Broker == "" {
+		return nil, fmt.Errorf("mqtt broker is required")
+	}
+	u, err := url.Parse(cfg.Broker)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid mqtt broker %q (expected e.g. tcp://host:1883 or ssl://host:8883)", cfg.Broker)
+	}
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return nil, fmt.Errorf("mqtt qos must be 0, 1 or 2")
+	}
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = "picoclaw-" + randomHex(4)
+	}
+	clientID += suffix
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetCleanSession(true).
+		SetOrderMatters(false).
+		SetKeepAlive(30 * time.Second).
+		SetConnectTimeout(ConnectTimeout).
+		SetWriteTimeout(ConnectTimeout).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(time.Minute)
+	if cfg.Username != "" {
+		opts.SetUsername(cfg.Username)
+		opts.SetPassword(cfg.Password)
+	}
+
+	if isTLS(u.Scheme) || cfg.CAFile != "" || cfg.CertFile != "" {
+		tlsCfg, err := tlsConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsCfg)
+	}
+	return opts, nil
+}
+
+func isTLS(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "ssl", "tls", "mqtts", "mqtt+ssl", "tcps", "wss":
+		return true
+	}
+	return false
+}
+
+func tlsConfig(cfg config.MQTTConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // opt-in for self-signed brokers
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read mqtt ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mqtt ca_file %s contains no certificates", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load mqtt client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// Wait blocks until token completes or ctx is done.
+func Wait(ctx context.Context, token paho.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ValidateTopic reports whether topic can be published to: non-empty and
+// free of wildcards and NUL characters.
+func ValidateTopic(topic string) error {
+	if topic == "" {
+		return fmt.Errorf("topic is empty")
+	}
+	if strings.ContainsAny(topic, "+#\x00") {
+		return fmt.Errorf("topic %q must not contain wildcards", topic)
+	}
+	return nil
+}
+
+// ValidateFilter reports whether filter is a well-formed subscription
+// filter: "+" must fill a whole level and "#" must be the last level.
+func ValidateFilter(filter string) error {
+	if filter == "" || strings.Contains(filter, "\x00") {
+		return fmt.Errorf("invalid topic filter %q", filter)
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.ContainsAny(level, "+#") && len(level) > 1 {
+			return fmt.Errorf("invalid topic filter %q: wildcards must fill a whole level", filter)
+		}
+		if level == "#" && i != len(levels)-1 {
+			return fmt.Errorf("invalid topic filter %q: # must be the last level", filter)
+		}
+	}
+	return nil
+}
+
+// MatchTopic reports whether topic matches the subscription filter, with
+// "+" matching one level and "#" the remaining levels (including none).
+// As the MQTT spec requires, a filter starting with a wildcard does not
+// match topics starting with "$".
+func MatchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) {
+			return false
+		}
+		if level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
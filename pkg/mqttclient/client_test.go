@@ -0,0 +1,97 @@
+package mqttclient
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"home/kitchen/temp", "home/kitchen/temp", true},
+		{"home/kitchen/temp", "home/kitchen", false},
+		{"home/+/temp", "home/kitchen/temp", true},
+		{"home/+/temp", "home/kitchen/hall/temp", false},
+		{"home/+", "home/", true},
+		{"home/#", "home", true},
+		{"home/#", "home/kitchen/temp", true},
+		{"home/#", "garden/temp", false},
+		{"#", "anything/at/all", true},
+		{"#", "$SYS/broker/uptime", false},
+		{"+/broker/uptime", "$SYS/broker/uptime", false},
+		{"$SYS/#", "$SYS/broker/uptime", true},
+	}
+	for _, tt := range tests {
+		if got := MatchTopic(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	for _, filter := range []string{"a", "a/b", "a/+/c", "+", "#", "a/#", "+/+/#"} {
+		if err := ValidateFilter(filter); err != nil {
+			t.Errorf("ValidateFilter(%q) = %v", filter, err)
+		}
+	}
+	for _, filter := range []string{"", "a/#/c", "a+", "a/b#", "a\x00b"} {
+		if err := ValidateFilter(filter); err == nil {
+			t.Errorf("ValidateFilter(%q) succeeded", filter)
+		}
+	}
+}
+
+func TestValidateTopic(t *testing.T) {
+	if err := ValidateTopic("home/lamp/set"); err != nil {
+		t.Errorf("ValidateTopic() = %v", err)
+	}
+	for _, topic := range []string{"", "home/+/set", "home/#"} {
+		if err := ValidateTopic(topic); err == nil {
+			t.Errorf("ValidateTopic(%q) succeeded", topic)
+		}
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	opts, err := NewOptions(config.MQTTConfig{
+		Broker:   "tcp://broker:1883",
+		ClientID: "picoclaw",
+		Username: "agent",
+		Password: "secret",
+	}, "-tool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.ClientID != "picoclaw-tool" || opts.Username != "agent" || opts.Password != "secret" {
+		t.Errorf("options = %q %q %q", opts.ClientID, opts.Username, opts.Password)
+	}
+	if opts.TLSConfig != nil {
+		t.Error("plain tcp broker got a TLS config")
+	}
+
+	opts, err = NewOptions(config.MQTTConfig{Broker: "ssl://broker:8883", InsecureSkipVerify: true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(opts.ClientID, "picoclaw-") || len(opts.ClientID) <= len("picoclaw-") {
+		t.Errorf("generated client ID = %q", opts.ClientID)
+	}
+	if opts.TLSConfig == nil || !opts.TLSConfig.InsecureSkipVerify {
+		t.Errorf("TLS config = %+v", opts.TLSConfig)
+	}
+
+	for _, cfg := range []config.MQTTConfig{
+		{},
+		{Broker: "broker:1883"},
+		{Broker: "tcp://broker:1883", QoS: -1},
+		{Broker: "ssl://broker:8883", CertFile: "/nonexistent.pem", KeyFile: "/nonexistent.key"},
+	} {
+		if _, err := NewOptions(cfg, ""); err == nil {
+			t.Errorf("NewOptions(%+v) succeeded", cfg)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+// Package netpolicy decides which network destinations tools may reach.
+//
+// A Policy blocks loopback, private, link-local and cloud metadata addresses
+// by default and can restrict hosts to allowed domains. Applied to an
+// http.Client, it checks every request, including redirects, and the IP
+// address each connection is actually made to, so DNS rebinding cannot be
+// used to reach a blocked address after the host name was checked.
+package netpolicy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// DefaultMaxResponseBytes caps response bodies when the config sets no limit.
+const DefaultMaxResponseBytes = 4 * 1024 * 1024
+
+type namedPrefix struct {
+	prefix netip.Prefix
+	name   string
+}
+
+func prefixes(name string, cidrs ...string) []namedPrefix {
+	out := make([]namedPrefix, len(cidrs))
+	for i, cidr := range cidrs {
+		out[i] = namedPrefix{netip.MustParsePrefix(cidr), name}
+	}
+	return out
+}
+
+var (
+	// alwaysBlocked is never reachable unless listed in AllowedCIDRs:
+	// instance metadata services hand out cloud credentials, and the rest
+	// is not a real unicast destination.
+	alwaysBlocked = concat(
+		prefixes("cloud metadata", "169.254.169.254/32", "169.254.170.2/32", "100.100.100.200/32",
+			"fd00:ec2::254/128"),
+		prefixes("unspecified address", "0.0.0.0/8", "::/128"),
+		prefixes("multicast", "224.0.0.0/4", "ff00::/8"),
+		prefixes("reserved", "240.0.0.0/4"),
+	)
+	// privateNetworks are blocked unless AllowPrivate is set.
+	privateNetworks = concat(
+		prefixes("loopback", "127.0.0.0/8", "::1/128"),
+		prefixes("link-local", "169.254.0.0/16", "fe80::/10"),
+		prefixes("private network", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"),
+		prefixes("shared address space", "100.64.0.0/10"),
+		prefixes("special-purpose network", "192.0.0.0/24", "198.18.0.0/15"),
+	)
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFour   = netip.MustParsePrefix("2002::/16")
+)
+
+func concat(lists ...[]namedPrefix) []namedPrefix {
+	var out []namedPrefix
+	for _, l := range lists {
+		out = append(out, l...)
+	}
+	return out
+}
+
+// BlockedError reports a destination the policy does not allow.
+type BlockedError struct {
+	Host   string
+	IP     netip.Addr // set when an address was blocked
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	if e.IP.IsValid() && e.Host != "" && e.Host != e.IP.String() {
+		return fmt.Sprintf("blocked by network policy: %s resolves to %s (%s)", e.Host, e.IP, e.Reason)
+	}
+	if e.IP.IsValid() {
+		return fmt.Sprintf("blocked by network policy: %s (%s)", e.IP, e.Reason)
+	}
+	return fmt.Sprintf("blocked by network policy: %s (%s)", e.Host, e.Reason)
+}
+
+// domainRules is one layer of allowed and denied domains. A host must pass
+// every layer of a policy.
+type domainRules struct {
+	allowed []string
+	denied  []string
+}
+
+// Policy is an outbound network policy. It is immutable and safe for
+// concurrent use.
+type Policy struct {
+	allowPrivate     bool
+	allowedNets      []netip.Prefix
+	deniedNets       []netip.Prefix
+	domains          []domainRules
+	maxResponseBytes int64
+
+	lookup func(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// New builds a Policy from cfg.
+func New(cfg config.NetworkConfig) (*Policy, error) {
+	p := &Policy{
+		allowPrivate:     cfg.AllowPrivate,
+		maxResponseBytes: int64(cfg.MaxResponseKB) * 1024,
+		lookup:           net.DefaultResolver.LookupNetIP,
+	}
+	if p.maxResponseBytes <= 0 {
+		p.maxResponseBytes = DefaultMaxResponseBytes
+	}
+	var err error
+	if p.allowedNets, err = parsePrefixes(cfg.AllowedCIDRs); err != nil {
+		return nil, fmt.Errorf("allowed_cidrs: %w", err)
+	}
+	if p.deniedNets, err = parsePrefixes(cfg.DeniedCIDRs); err != nil {
+		return nil, fmt.Errorf("denied_cidrs: %w", err)
+	}
+	if len(cfg.AllowedDomains) > 0 || len(cfg.DeniedDomains) > 0 {
+		p.domains = []domainRules{{normalizeDomains(cfg.AllowedDomains), normalizeDomains(cfg.DeniedDomains)}}
+	}
+	return p, nil
+}
+
+// Default returns the policy used when nothing is configured: public
+// addresses only.
+func Default() *Policy {
+	p, _ := New(config.NetworkConfig{})
+	return p
+}
+
+// WithDomains returns a copy of p that also requires hosts to be in allowed
+// (when not empty) and not in denied. It is used for per-agent lists, which
+// narrow the global policy.
+func (p *Policy) WithDomains(allowed, denied []string) *Policy {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return p
+	}
+	cp := *p
+	cp.domains = append(append([]domainRules(nil), p.domains...),
+		domainRules{normalizeDomains(allowed), normalizeDomains(denied)})
+	return &cp
+}
+
+// MaxResponseBytes returns the response body limit.
+func (p *Policy) MaxResponseBytes() int64 {
+	return p.maxResponseBytes
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(cidrs))
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+			out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+		out = append(out, prefix.Masked())
+	}
+	return out, nil
+}
+
+func normalizeDomains(domains []string) []string {
+	out := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "*.")
+		if d = strings.TrimSuffix(d, "."); d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// matchDomain reports whether host is one of domains or a subdomain of one.
+func matchDomain(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL checks the scheme and host of u, and its address when the host
+// is an IP literal. Host names are resolved and checked when connecting.
+func (p *Policy) CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("blocked by network policy: scheme %q is not allowed", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("blocked by network policy: missing host")
+	}
+	if err := p.CheckHost(host); err != nil {
+		return err
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.checkAddr(host, addr)
+	}
+	return nil
+}
+
+// CheckHost checks host against the allowed and denied domains.
+func (p *Policy) CheckHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, rules := range p.domains {
+		if matchDomain(host, rules.denied) {
+			return &BlockedError{Host: host, Reason: "denied domain"}
+		}
+		if len(rules.allowed) > 0 && !matchDomain(host, rules.allowed) {
+			return &BlockedError{Host: host, Reason: "not in allowed domains"}
+		}
+	}
+	return nil
+}
+
+// CheckIP reports whether connections to addr are allowed.
+func (p *Policy) CheckIP(addr netip.Addr) error {
+	return p.checkAddr("", addr)
+}
+
+func (p *Policy) checkAddr(host string, addr netip.Addr) error {
+	addr = addr.Unmap().WithZone("")
+	if inner, ok := embeddedIPv4(addr); ok {
+		if err := p.checkAddr(host, inner); err != nil {
+			return err
+		}
+	}
+	blocked := func(reason string) error {
+		return &BlockedError{Host: host, IP: addr, Reason: reason}
+	}
+	for _, n := range p.deniedNets {
+		if n.Contains(addr) {
+			return blocked("denied network")
+		}
+	}
+	for _, n := range p.allowedNets {
+		if n.Contains(addr) {
+			return nil
+		}
+	}
+	for _, n := range alwaysBlocked {
+		if n.prefix.Contains(addr) {
+			return blocked(n.name)
+		}
+	}
+	if !p.allowPrivate {
+		for _, n := range privateNetworks {
+			if n.prefix.Contains(addr) {
+				return blocked(n.name)
+			}
+		}
+	}
+	return nil
+}
+
+// embeddedIPv4 returns the IPv4 address carried by NAT64 and 6to4 addresses,
+// which would otherwise let IPv6 reach blocked IPv4 networks.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	if !addr.Is6() {
+		return netip.Addr{}, false
+	}
+	b := addr.As16()
+	switch {
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[12:16])), true
+	case sixToFour.Contains(addr):
+		return netip.AddrFrom4([4]byte(b[2:6])), true
+	}
+	return netip.Addr{}, false
+}
+
+// resolve returns the addresses host stands for.
+func (p *Policy) resolve(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+	ipNetwork := "ip"
+	switch network {
+	case "tcp4", "udp4":
+		ipNetwork = "ip4"
+	case "tcp6", "udp6":
+		ipNetwork = "ip6"
+	}
+	return p.lookup(ctx, ipNetwork, host)
+}
+
+// Dialer returns a DialContext function that resolves the host itself and
+// only connects to addresses the policy allows.
+func (p *Policy) Dialer(d *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.CheckHost(host); err != nil {
+			return nil, err
+		}
+		addrs, err := p.resolve(ctx, network, host)
+		if err != nil {
+			return nil, err
+		}
+		var blockErr, dialErr error
+		for _, addr := range addrs {
+			if err := p.checkAddr(host, addr); err != nil {
+				if blockErr == nil {
+					blockErr = err
+				}
+				continue
+			}
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if dialErr == nil {
+				dialErr = err
+			}
+		}
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		if blockErr != nil {
+			return nil, blockErr
+		}
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+}
+
+// Apply makes client enforce the policy on every request and connection.
+// Connections to the client's proxy are allowed; for proxied requests the
+// target is checked by name, and by address if it resolves locally.
+func (p *Policy) Apply(client *http.Client) {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		client.Transport = transport
+	}
+
+	var proxies sync.Map // proxy host:port -> true
+	guarded := p.Dialer(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if _, ok := proxies.Load(address); ok {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		}
+		return guarded(ctx, network, address)
+	}
+
+	baseProxy := transport.Proxy
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if err := p.CheckURL(req.URL); err != nil {
+			return nil, err
+		}
+		if baseProxy == nil {
+			return nil, nil
+		}
+		proxyURL, err := baseProxy(req)
+		if err != nil || proxyURL == nil {
+			return proxyURL, err
+		}
+		if addrs, err := p.resolve(req.Context(), "tcp", req.URL.Hostname()); err == nil {
+			for _, addr := range addrs {
+				if err := p.checkAddr(req.URL.Hostname(), addr); err != nil {
+					return nil, err
+				}
+			}
+		}
+		proxies.Store(proxyAddress(proxyURL), true)
+		return proxyURL, nil
+	}
+}
+
+// proxyAddress returns the host:port the transport dials for proxyURL.
+func proxyAddress(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// ReadBody reads r up to the response limit. It reports whether the body
+// was longer than the limit, in which case the returned data is cut off.
+func (p *Policy) ReadBody(r io.Reader) ([]byte, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(r, p.maxResponseBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > p.maxResponseBytes {
+		return data[:p.maxResponseBytes], true, nil
+	}
+	return data, false, nil
+}
@@ -0,0 +1,189 @@
+package netpolicy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func mustNew(t *testing.T, cfg config.NetworkConfig) *Policy {
+	t.Helper()
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPolicy_CheckIP(t *testing.T) {
+	def := Default()
+	private := mustNew(t, config.NetworkConfig{AllowPrivate: true})
+	custom := mustNew(t, config.NetworkConfig{
+		AllowedCIDRs: []string{"192.168.1.0/24", "169.254.169.254"},
+		DeniedCIDRs:  []string{"8.8.4.0/24"},
+	})
+
+	tests := []struct {
+		policy  *Policy
+		addr    string
+		blocked string // reason, empty when allowed
+	}{
+		{def, "93.184.216.34", ""},
+		{def, "2606:2800:220:1:248:1893:25c8:1946", ""},
+		{def, "127.0.0.1", "loopback"},
+		{def, "::1", "loopback"},
+		{def, "10.1.2.3", "private network"},
+		{def, "172.31.255.1", "private network"},
+		{def, "192.168.1.10", "private network"},
+		{def, "fd12::1", "private network"},
+		{def, "100.64.0.1", "shared address space"},
+		{def, "169.254.1.1", "link-local"},
+		{def, "fe80::1", "link-local"},
+		{def, "169.254.169.254", "cloud metadata"},
+		{def, "0.0.0.0", "unspecified address"},
+		{def, "::ffff:127.0.0.1", "loopback"},
+		{def, "64:ff9b::a9fe:a9fe", "cloud metadata"},
+		{def, "2002:c0a8:0101::1", "private network"},
+		{private, "192.168.1.10", ""},
+		{private, "127.0.0.1", ""},
+		{private, "169.254.169.254", "cloud metadata"},
+		{private, "fd00:ec2::254", "cloud metadata"},
+		{private, "224.0.0.1", "multicast"},
+		{custom, "192.168.1.10", ""},
+		{custom, "192.168.2.10", "private network"},
+		{custom, "169.254.169.254", ""},
+		{custom, "8.8.4.4", "denied network"},
+		{custom, "8.8.8.8", ""},
+	}
+	for _, tt := range tests {
+		err := tt.policy.CheckIP(netip.MustParseAddr(tt.addr))
+		var blockErr *BlockedError
+		switch {
+		case tt.blocked == "" && err != nil:
+			t.Errorf("CheckIP(%s) = %v, want allowed", tt.addr, err)
+		case tt.blocked != "" && (!errors.As(err, &blockErr) || blockErr.Reason != tt.blocked):
+			t.Errorf("CheckIP(%s) = %v, want blocked as %s", tt.addr, err, tt.blocked)
+		}
+	}
+}
+
+func TestPolicy_CheckURL(t *testing.T) {
+	p := mustNew(t, config.NetworkConfig{DeniedDomains: []string{"evil.example"}}).
+		WithDomains([]string{"*.example", "docs.go.dev"}, nil)
+
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://api.example/v1", true},
+		{"https://API.Example./v1", true},
+		{"https://sub.evil.example/", false},
+		{"https://docs.go.dev/doc", true},
+		{"https://go.dev/", false},
+		{"ftp://api.example/", false},
+		{"http://127.0.0.1:18790/", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if err := p.CheckURL(u); (err == nil) != tt.ok {
+			t.Errorf("CheckURL(%s) = %v, want ok=%v", tt.url, err, tt.ok)
+		}
+	}
+
+	// The per-agent layer narrows but cannot widen the global lists.
+	if err := p.CheckHost("evil.example"); err == nil {
+		t.Error("globally denied domain should stay denied")
+	}
+}
+
+func TestPolicy_DialerChecksResolvedAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// A public-looking name that resolves to loopback, as in DNS rebinding.
+	p := Default()
+	p.lookup = func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, nil
+	}
+	client := &http.Client{}
+	p.Apply(client)
+
+	_, err := client.Get("http://rebind.example:" + port + "/")
+	var blockErr *BlockedError
+	if !errors.As(err, &blockErr) || blockErr.Host != "rebind.example" || blockErr.Reason != "loopback" {
+		t.Fatalf("Get() error = %v, want blocked loopback", err)
+	}
+
+	allowed := mustNew(t, config.NetworkConfig{AllowPrivate: true})
+	allowed.lookup = p.lookup
+	client = &http.Client{}
+	allowed.Apply(client)
+	resp, err := client.Get("http://rebind.example:" + port + "/")
+	if err != nil {
+		t.Fatalf("Get() with private networks allowed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestPolicy_ReadBody(t *testing.T) {
+	p := mustNew(t, config.NetworkConfig{MaxResponseKB: 1})
+	data, truncated, err := p.ReadBody(strings.NewReader(strings.Repeat("a", 1500)))
+	if err != nil || !truncated || len(data) != 1024 {
+		t.Errorf("ReadBody() = %d bytes, truncated=%v, %v", len(data), truncated, err)
+	}
+	data, truncated, _ = p.ReadBody(strings.NewReader("small"))
+	if truncated || string(data) != "small" {
+		t.Errorf("ReadBody() = %q, truncated=%v", data, truncated)
+	}
+	if Default().MaxResponseBytes() != DefaultMaxResponseBytes {
+		t.Errorf("default limit = %d", Default().MaxResponseBytes())
+	}
+}
+
+func TestNew_InvalidCIDR(t *testing.T) {
+	if _, err := New(config.NetworkConfig{AllowedCIDRs: []string{"10.0.0.0/33"}}); err == nil {
+		t.Error("invalid CIDR should fail")
+	}
+}
+
+func TestPolicy_ApplyAllowsConfiguredProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("via proxy " + r.URL.Host))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	p := Default()
+	p.lookup = func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		if host == "internal.example" {
+			return []netip.Addr{netip.MustParseAddr("10.0.0.5")}, nil
+		}
+		return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+	}
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	p.Apply(client)
+
+	resp, err := client.Get("http://public.example/")
+	if err != nil {
+		t.Fatalf("Get() through a loopback proxy: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := client.Get("http://internal.example/"); err == nil {
+		t.Error("proxied request to a private address should be blocked")
+	}
+	if _, err := client.Get("http://10.0.0.5/"); err == nil {
+		t.Error("proxied request to a private IP literal should be blocked")
+	}
+}
@@ -170,10 +170,16 @@ type antigravityPart struct {
 	Text                  string                       `json:"text,omitempty"`
 	ThoughtSignature      string                       `json:"thoughtSignature,omitempty"`
 	ThoughtSignatureSnake string                       `json:"thought_signature,omitempty"`
+	InlineData            *antigravityInlineData       `json:"inlineData,omitempty"`
 	FunctionCall          *antigravityFunctionCall     `json:"functionCall,omitempty"`
 	FunctionResponse      *antigravityFunctionResponse `json:"functionResponse,omitempty"`
 }
 
+type antigravityInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64
+}
+
 type antigravityFunctionCall struct {
 	Name string         `json:"name"`
 	Args map[string]any `json:"args"`
@@ -199,8 +205,9 @@ type antigravitySystemPrompt struct {
 }
 
 type antigravityGenConfig struct {
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
-	Temperature     float64 `json:"temperature,omitempty"`
+	MaxOutputTokens    int      `json:"maxOutputTokens,omitempty"`
+	Temperature        float64  `json:"temperature,omitempty"`
+	ResponseModalities []string `json:"responseModalities,omitempty"`
 }
 
 func (p *AntigravityProvider) buildRequest(
@@ -208,6 +215,16 @@ func (p *AntigravityProvider) buildRequest(
 	tools []ToolDefinition,
 	model string,
 	options map[string]any,
+) antigravityRequest {
+	return buildGeminiRequest(messages, tools, options)
+}
+
+// buildGeminiRequest converts messages and tools into the standard Gemini
+// generateContent request shared by the Antigravity and native Gemini providers.
+func buildGeminiRequest(
+	messages []Message,
+	tools []ToolDefinition,
+	options map[string]any,
 ) antigravityRequest {
 	req := antigravityRequest{}
 	toolCallNames := make(map[string]string)
@@ -235,9 +252,17 @@ func (p *AntigravityProvider) buildRequest(
 					}},
 				})
 			} else {
+				var parts []antigravityPart
+				if msg.Content != "" {
+					parts = append(parts, antigravityPart{Text: msg.Content})
+				}
+				parts = append(parts, geminiMediaParts(msg.Media)...)
+				if len(parts) == 0 {
+					parts = []antigravityPart{{Text: msg.Content}}
+				}
 				req.Contents = append(req.Contents, antigravityContent{
 					Role:  "user",
-					Parts: []antigravityPart{{Text: msg.Content}},
+					Parts: parts,
 				})
 			}
 		case "assistant":
@@ -389,6 +414,8 @@ type antigravityJSONResponse struct {
 		Content struct {
 			Parts []struct {
 				Text                  string                   `json:"text,omitempty"`
+				Thought               bool                     `json:"thought,omitempty"`
+				InlineData            *antigravityInlineData   `json:"inlineData,omitempty"`
 				ThoughtSignature      string                   `json:"thoughtSignature,omitempty"`
 				ThoughtSignatureSnake string                   `json:"thought_signature,omitempty"`
 				FunctionCall          *antigravityFunctionCall `json:"functionCall,omitempty"`
@@ -405,10 +432,7 @@ type antigravityJSONResponse struct {
 }
 
 func (p *AntigravityProvider) parseSSEResponse(body string) (*LLMResponse, error) {
-	var contentParts []string
-	var toolCalls []ToolCall
-	var usage *UsageInfo
-	var finishReason string
+	var collector geminiResponseCollector
 
 	scanner := bufio.NewScanner(strings.NewReader(body))
 	for scanner.Scan() {
@@ -428,58 +452,79 @@ func (p *AntigravityProvider) parseSSEResponse(body string) (*LLMResponse, error
 		if err := json.Unmarshal([]byte(data), &sseChunk); err != nil {
 			continue
 		}
-		resp := sseChunk.Response
+		collector.add(sseChunk.Response)
+	}
 
-		for _, candidate := range resp.Candidates {
-			for _, part := range candidate.Content.Parts {
-				if part.Text != "" {
-					contentParts = append(contentParts, part.Text)
-				}
-				if part.FunctionCall != nil {
-					argumentsJSON, _ := json.Marshal(part.FunctionCall.Args)
-					toolCalls = append(toolCalls, ToolCall{
-						ID:        fmt.Sprintf("call_%s_%d", part.FunctionCall.Name, time.Now().UnixNano()),
-						Name:      part.FunctionCall.Name,
-						Arguments: part.FunctionCall.Args,
-						Function: &FunctionCall{
-							Name:      part.FunctionCall.Name,
-							Arguments: string(argumentsJSON),
-							ThoughtSignature: extractPartThoughtSignature(
-								part.ThoughtSignature,
-								part.ThoughtSignatureSnake,
-							),
-						},
-					})
+	return collector.result(), nil
+}
+
+// geminiResponseCollector merges one or more Gemini response chunks into a
+// single LLMResponse, preserving thought signatures on function calls.
+type geminiResponseCollector struct {
+	contentParts   []string
+	reasoningParts []string
+	toolCalls      []ToolCall
+	usage          *UsageInfo
+	finishReason   string
+}
+
+func (c *geminiResponseCollector) add(resp antigravityJSONResponse) {
+	for _, candidate := range resp.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.Text != "" {
+				if part.Thought {
+					c.reasoningParts = append(c.reasoningParts, part.Text)
+				} else {
+					c.contentParts = append(c.contentParts, part.Text)
 				}
 			}
-			if candidate.FinishReason != "" {
-				finishReason = candidate.FinishReason
+			if part.FunctionCall != nil {
+				argumentsJSON, _ := json.Marshal(part.FunctionCall.Args)
+				c.toolCalls = append(c.toolCalls, ToolCall{
+					ID:        fmt.Sprintf("call_%s_%d", part.FunctionCall.Name, time.Now().UnixNano()),
+					Name:      part.FunctionCall.Name,
+					Arguments: part.FunctionCall.Args,
+					Function: &FunctionCall{
+						Name:      part.FunctionCall.Name,
+						Arguments: string(argumentsJSON),
+						ThoughtSignature: extractPartThoughtSignature(
+							part.ThoughtSignature,
+							part.ThoughtSignatureSnake,
+						),
+					},
+				})
 			}
 		}
+		if candidate.FinishReason != "" {
+			c.finishReason = candidate.FinishReason
+		}
+	}
 
-		if resp.UsageMetadata.TotalTokenCount > 0 {
-			usage = &UsageInfo{
-				PromptTokens:     resp.UsageMetadata.PromptTokenCount,
-				CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
-				TotalTokens:      resp.UsageMetadata.TotalTokenCount,
-			}
+	if resp.UsageMetadata.TotalTokenCount > 0 {
+		c.usage = &UsageInfo{
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
 		}
 	}
+}
 
+func (c *geminiResponseCollector) result() *LLMResponse {
 	mappedFinish := "stop"
-	if len(toolCalls) > 0 {
+	if len(c.toolCalls) > 0 {
 		mappedFinish = "tool_calls"
 	}
-	if finishReason == "MAX_TOKENS" {
+	if c.finishReason == "MAX_TOKENS" {
 		mappedFinish = "length"
 	}
 
 	return &LLMResponse{
-		Content:      strings.Join(contentParts, ""),
-		ToolCalls:    toolCalls,
-		FinishReason: mappedFinish,
-		Usage:        usage,
-	}, nil
+		Content:          strings.Join(c.contentParts, ""),
+		ReasoningContent: strings.Join(c.reasoningParts, ""),
+		ToolCalls:        c.toolCalls,
+		FinishReason:     mappedFinish,
+		Usage:            c.usage,
+	}
 }
 
 func extractPartThoughtSignature(thoughtSignature string, thoughtSignatureSnake string) string {
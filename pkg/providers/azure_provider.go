@@ -0,0 +1,54 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/openai_compat"
+)
+
+const azureDefaultAPIVersion = "2024-10-21"
+
+// NewAzureOpenAIProvider creates a provider for an Azure OpenAI resource.
+// apiBase is the resource endpoint (https://<resource>.openai.azure.com) and
+// the model passed to Chat is the deployment name. Requests go to
+// /openai/deployments/<deployment>/chat/completions?api-version=... with the
+// key in the api-key header. An apiBase ending in /openai/v1 uses Azure's
+// version-less v1 API, where the deployment is sent as the model field.
+func NewAzureOpenAIProvider(
+	apiKey, apiBase, apiVersion, proxy, maxTokensField string,
+	requestTimeoutSeconds int,
+) *HTTPProvider {
+	apiBase = strings.TrimRight(apiBase, "/")
+	if apiVersion == "" {
+		apiVersion = azureDefaultAPIVersion
+	}
+	if maxTokensField == "" {
+		// Deployment names don't reveal the model family; every model on
+		// current API versions accepts max_completion_tokens.
+		maxTokensField = "max_completion_tokens"
+	}
+
+	opts := []openai_compat.Option{
+		openai_compat.WithAPIKeyHeader("api-key"),
+		openai_compat.WithMaxTokensField(maxTokensField),
+		openai_compat.WithRequestTimeout(time.Duration(requestTimeoutSeconds) * time.Second),
+	}
+	if !strings.HasSuffix(apiBase, "/openai/v1") {
+		opts = append(opts, openai_compat.WithChatURL(func(deployment string) string {
+			return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+				apiBase, url.PathEscape(deployment), url.QueryEscape(apiVersion))
+		}))
+	}
+
+	return &HTTPProvider{
+		delegate: openai_compat.NewProvider(apiKey, apiBase, proxy, opts...),
+	}
+}
@@ -0,0 +1,117 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestAzureOpenAIProvider_DeploymentURL(t *testing.T) {
+	var gotPath, gotVersion, gotKey, gotBearer string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		gotKey = r.Header.Get("api-key")
+		gotBearer = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write(readFixture(t, "azure/chat_completion.json"))
+	}))
+	defer server.Close()
+
+	provider, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "gpt-4o",
+		Model:     "azure/my-gpt4o-deployment",
+		APIBase:   server.URL,
+		APIKey:    "azure-key",
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+
+	resp, err := provider.Chat(context.Background(), []Message{{Role: "user", Content: "read notes"}},
+		nil, modelID, map[string]any{"max_tokens": 64})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if gotPath != "/openai/deployments/my-gpt4o-deployment/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotVersion != azureDefaultAPIVersion {
+		t.Errorf("api-version = %q, want %q", gotVersion, azureDefaultAPIVersion)
+	}
+	if gotKey != "azure-key" || gotBearer != "" {
+		t.Errorf("api-key = %q, Authorization = %q", gotKey, gotBearer)
+	}
+	if gotBody["max_completion_tokens"] != float64(64) {
+		t.Errorf("max_completion_tokens = %v", gotBody["max_completion_tokens"])
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "read_file" ||
+		resp.ToolCalls[0].Arguments["path"] != "notes.md" {
+		t.Errorf("ToolCalls = %+v", resp.ToolCalls)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 114 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestAzureOpenAIProvider_V1API(t *testing.T) {
+	var gotPath, gotModel string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		gotModel, _ = body["model"].(string)
+		w.Write(readFixture(t, "azure/chat_completion.json"))
+	}))
+	defer server.Close()
+
+	p := NewAzureOpenAIProvider("k", server.URL+"/openai/v1/", "", "", "", 0)
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "dep", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if gotPath != "/openai/v1/chat/completions" || gotModel != "dep" {
+		t.Errorf("path = %q, model = %q", gotPath, gotModel)
+	}
+}
+
+func TestAzureOpenAIProvider_RateLimitClassified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write(readFixture(t, "azure/error_rate_limit.json"))
+	}))
+	defer server.Close()
+
+	p := NewAzureOpenAIProvider("k", server.URL, "2025-01-01-preview", "", "", 0)
+	_, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "dep", nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if failErr := ClassifyError(err, "azure", "dep"); failErr == nil || failErr.Reason != FailoverRateLimit {
+		t.Errorf("ClassifyError() = %+v, want rate_limit", failErr)
+	}
+}
+
+func TestCreateProviderFromConfig_AzureRequiresBaseAndKey(t *testing.T) {
+	if _, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "x", Model: "azure/dep", APIKey: "k",
+	}); err == nil {
+		t.Error("expected error without api_base")
+	}
+	if _, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "x", Model: "azure/dep", APIBase: "https://r.openai.azure.com",
+	}); err == nil {
+		t.Error("expected error without api_key")
+	}
+}
@@ -0,0 +1,457 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// BedrockProvider implements LLMProvider using the AWS Bedrock Runtime
+// Converse API. Requests are signed with SigV4, or sent with a Bedrock API
+// key as a Bearer token when one is configured.
+type BedrockProvider struct {
+	region     string
+	apiBase    string
+	apiKey     string
+	creds      awsCredentials
+	httpClient *http.Client
+	now        func() time.Time // for testing
+}
+
+// NewBedrockProvider creates a Bedrock provider. Empty region and credential
+// arguments fall back to AWS_REGION/AWS_DEFAULT_REGION, AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_BEARER_TOKEN_BEDROCK.
+// apiBase overrides the regional endpoint (e.g. for VPC endpoints).
+func NewBedrockProvider(
+	region, accessKeyID, secretAccessKey, sessionToken, apiKey, apiBase, proxy string,
+	requestTimeoutSeconds int,
+) (*BedrockProvider, error) {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("aws_region is required for bedrock protocol")
+	}
+
+	if apiKey == "" {
+		apiKey = os.Getenv("AWS_BEARER_TOKEN_BEDROCK")
+	}
+	creds := awsCredentials{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+	}
+	if creds.AccessKeyID == "" && creds.SecretAccessKey == "" {
+		creds.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		creds.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if creds.SessionToken == "" {
+			creds.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+	}
+	if apiKey == "" && (creds.AccessKeyID == "" || creds.SecretAccessKey == "") {
+		return nil, fmt.Errorf("bedrock: no credentials found (set aws_access_key_id/aws_secret_access_key or api_key)")
+	}
+
+	if apiBase == "" {
+		apiBase = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)
+	}
+	timeout := 120 * time.Second
+	if requestTimeoutSeconds > 0 {
+		timeout = time.Duration(requestTimeoutSeconds) * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	if proxy != "" {
+		if parsed, err := url.Parse(proxy); err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(parsed)}
+		}
+	}
+
+	return &BedrockProvider{
+		region:     region,
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		apiKey:     apiKey,
+		creds:      creds,
+		httpClient: client,
+		now:        time.Now,
+	}, nil
+}
+
+// Chat implements LLMProvider.Chat using POST /model/{modelId}/converse.
+func (p *BedrockProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	model = strings.TrimPrefix(model, "bedrock/")
+	if model == "" {
+		return nil, fmt.Errorf("bedrock: model ID is required")
+	}
+
+	bodyBytes, err := json.Marshal(buildConverseRequest(messages, tools, options))
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	endpoint, err := url.Parse(p.apiBase)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: invalid api_base: %w", err)
+	}
+	// Model IDs and ARNs contain ':' and '/', which must be escaped in the path.
+	basePath := strings.TrimRight(endpoint.EscapedPath(), "/")
+	endpoint.Path = strings.TrimRight(endpoint.Path, "/") + "/model/" + model + "/converse"
+	endpoint.RawPath = basePath + "/model/" + awsURIEncode(model) + "/converse"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	} else {
+		signAWSRequestV4(req, bodyBytes, p.creds, p.region, "bedrock", p.now())
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock API call: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		logger.ErrorCF("provider.bedrock", "API call failed", map[string]any{
+			"status_code": resp.StatusCode,
+			"response":    truncateString(string(respBody), 500),
+			"model":       model,
+		})
+		return nil, parseBedrockError(resp.StatusCode, resp.Header.Get("X-Amzn-ErrorType"), respBody)
+	}
+
+	return parseConverseResponse(respBody)
+}
+
+// GetDefaultModel returns an empty string; Bedrock has no implicit default model.
+func (p *BedrockProvider) GetDefaultModel() string {
+	return ""
+}
+
+// --- Request building ---
+
+type converseRequest struct {
+	Messages        []converseMessage        `json:"messages"`
+	System          []converseContentBlock   `json:"system,omitempty"`
+	InferenceConfig *converseInferenceConfig `json:"inferenceConfig,omitempty"`
+	ToolConfig      *converseToolConfig      `json:"toolConfig,omitempty"`
+}
+
+type converseMessage struct {
+	Role    string                 `json:"role"`
+	Content []converseContentBlock `json:"content"`
+}
+
+type converseContentBlock struct {
+	Text             string                    `json:"text,omitempty"`
+	Image            *converseImage            `json:"image,omitempty"`
+	ToolUse          *converseToolUse          `json:"toolUse,omitempty"`
+	ToolResult       *converseToolResult       `json:"toolResult,omitempty"`
+	ReasoningContent *converseReasoningContent `json:"reasoningContent,omitempty"`
+}
+
+type converseImage struct {
+	Format string `json:"format"`
+	Source struct {
+		Bytes string `json:"bytes"` // base64
+	} `json:"source"`
+}
+
+type converseToolUse struct {
+	ToolUseID string         `json:"toolUseId"`
+	Name      string         `json:"name"`
+	Input     map[string]any `json:"input"`
+}
+
+type converseToolResult struct {
+	ToolUseID string                 `json:"toolUseId"`
+	Content   []converseContentBlock `json:"content"`
+	Status    string                 `json:"status,omitempty"`
+}
+
+type converseReasoningContent struct {
+	ReasoningText *struct {
+		Text      string `json:"text"`
+		Signature string `json:"signature,omitempty"`
+	} `json:"reasoningText,omitempty"`
+}
+
+type converseInferenceConfig struct {
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type converseToolConfig struct {
+	Tools []converseTool `json:"tools"`
+}
+
+type converseTool struct {
+	ToolSpec converseToolSpec `json:"toolSpec"`
+}
+
+type converseToolSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema struct {
+		JSON map[string]any `json:"json"`
+	} `json:"inputSchema"`
+}
+
+func buildConverseRequest(messages []Message, tools []ToolDefinition, options map[string]any) converseRequest {
+	req := converseRequest{Messages: []converseMessage{}}
+
+	// Converse requires strictly alternating user/assistant turns, so
+	// consecutive blocks with the same role (e.g. several tool results) are merged.
+	appendBlocks := func(role string, blocks ...converseContentBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, blocks...)
+			return
+		}
+		req.Messages = append(req.Messages, converseMessage{Role: role, Content: blocks})
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				req.System = append(req.System, converseContentBlock{Text: msg.Content})
+			}
+		case "user", "tool":
+			if msg.ToolCallID != "" {
+				appendBlocks("user", converseContentBlock{ToolResult: &converseToolResult{
+					ToolUseID: msg.ToolCallID,
+					Content:   []converseContentBlock{{Text: nonEmpty(msg.Content)}},
+				}})
+				continue
+			}
+			var blocks []converseContentBlock
+			if msg.Content != "" {
+				blocks = append(blocks, converseContentBlock{Text: msg.Content})
+			}
+			blocks = append(blocks, converseImageBlocks(msg.Media)...)
+			appendBlocks("user", blocks...)
+		case "assistant":
+			var blocks []converseContentBlock
+			if msg.Content != "" {
+				blocks = append(blocks, converseContentBlock{Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				name, args, _ := normalizeStoredToolCall(tc)
+				if name == "" {
+					continue
+				}
+				blocks = append(blocks, converseContentBlock{ToolUse: &converseToolUse{
+					ToolUseID: tc.ID,
+					Name:      name,
+					Input:     args,
+				}})
+			}
+			appendBlocks("assistant", blocks...)
+		}
+	}
+
+	if len(tools) > 0 {
+		cfg := &converseToolConfig{}
+		for _, t := range tools {
+			if t.Type != "function" {
+				continue
+			}
+			spec := converseToolSpec{Name: t.Function.Name, Description: t.Function.Description}
+			spec.InputSchema.JSON = t.Function.Parameters
+			if spec.InputSchema.JSON == nil {
+				spec.InputSchema.JSON = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			cfg.Tools = append(cfg.Tools, converseTool{ToolSpec: spec})
+		}
+		if len(cfg.Tools) > 0 {
+			req.ToolConfig = cfg
+		}
+	}
+
+	inference := &converseInferenceConfig{}
+	if val, ok := options["max_tokens"]; ok {
+		if maxTokens, ok := val.(int); ok && maxTokens > 0 {
+			inference.MaxTokens = maxTokens
+		} else if maxTokens, ok := val.(float64); ok && maxTokens > 0 {
+			inference.MaxTokens = int(maxTokens)
+		}
+	}
+	if temp, ok := options["temperature"].(float64); ok {
+		inference.Temperature = &temp
+	}
+	if inference.MaxTokens > 0 || inference.Temperature != nil {
+		req.InferenceConfig = inference
+	}
+
+	return req
+}
+
+// converseImageBlocks converts attached images into Converse image blocks.
+// Converse only accepts png, jpeg, gif and webp; other media is skipped.
+func converseImageBlocks(media []string) []converseContentBlock {
+	var blocks []converseContentBlock
+	for _, m := range media {
+		mimeType, data, err := loadInlineMedia(m)
+		if err != nil {
+			logger.WarnCF("provider.bedrock", "Skipping media attachment", map[string]any{
+				"media": truncateString(m, 80),
+				"error": err.Error(),
+			})
+			continue
+		}
+		format := strings.TrimPrefix(mimeType, "image/")
+		switch format {
+		case "png", "jpeg", "gif", "webp":
+		default:
+			continue
+		}
+		img := &converseImage{Format: format}
+		img.Source.Bytes = data
+		blocks = append(blocks, converseContentBlock{Image: img})
+	}
+	return blocks
+}
+
+// nonEmpty returns s, or a placeholder when s is blank: Converse rejects
+// empty text blocks.
+func nonEmpty(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "(empty)"
+	}
+	return s
+}
+
+// --- Response parsing ---
+
+type converseResponse struct {
+	Output struct {
+		Message converseMessage `json:"message"`
+	} `json:"output"`
+	StopReason string `json:"stopReason"`
+	Usage      struct {
+		InputTokens  int `json:"inputTokens"`
+		OutputTokens int `json:"outputTokens"`
+		TotalTokens  int `json:"totalTokens"`
+	} `json:"usage"`
+}
+
+func parseConverseResponse(body []byte) (*LLMResponse, error) {
+	var resp converseResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("bedrock: decoding response: %w", err)
+	}
+
+	var content, reasoning strings.Builder
+	var toolCalls []ToolCall
+	for _, block := range resp.Output.Message.Content {
+		switch {
+		case block.ToolUse != nil:
+			args := block.ToolUse.Input
+			if args == nil {
+				args = map[string]any{}
+			}
+			argumentsJSON, _ := json.Marshal(args)
+			toolCalls = append(toolCalls, ToolCall{
+				ID:        block.ToolUse.ToolUseID,
+				Type:      "function",
+				Name:      block.ToolUse.Name,
+				Arguments: args,
+				Function: &FunctionCall{
+					Name:      block.ToolUse.Name,
+					Arguments: string(argumentsJSON),
+				},
+			})
+		case block.ReasoningContent != nil && block.ReasoningContent.ReasoningText != nil:
+			reasoning.WriteString(block.ReasoningContent.ReasoningText.Text)
+		case block.Text != "":
+			content.WriteString(block.Text)
+		}
+	}
+
+	finishReason := "stop"
+	switch resp.StopReason {
+	case "tool_use":
+		finishReason = "tool_calls"
+	case "max_tokens":
+		finishReason = "length"
+	}
+	if len(toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	var usage *UsageInfo
+	if resp.Usage.TotalTokens > 0 {
+		usage = &UsageInfo{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		}
+	}
+
+	return &LLMResponse{
+		Content:          content.String(),
+		ReasoningContent: reasoning.String(),
+		ToolCalls:        toolCalls,
+		FinishReason:     finishReason,
+		Usage:            usage,
+	}, nil
+}
+
+// parseBedrockError formats a Bedrock error with its HTTP status and AWS
+// exception name so ClassifyError can map it to a failover reason.
+func parseBedrockError(statusCode int, errorType string, body []byte) error {
+	// X-Amzn-ErrorType looks like "ThrottlingException:http://internal.amazon.com/..."
+	errorType, _, _ = strings.Cut(errorType, ":")
+
+	var errResp struct {
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	msg := truncateString(string(body), 500)
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		if errResp.Message != "" {
+			msg = errResp.Message
+		} else if errResp.MessageUpper != "" {
+			msg = errResp.MessageUpper
+		}
+	}
+	if errorType == "" {
+		errorType = "UnknownError"
+	}
+	return fmt.Errorf("bedrock API request failed:\n  Status: %d (%s)\n  Message: %s", statusCode, errorType, msg)
+}
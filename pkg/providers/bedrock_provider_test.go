@@ -0,0 +1,281 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("reading fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func newTestBedrockProvider(t *testing.T, apiBase string) *BedrockProvider {
+	t.Helper()
+	p, err := NewBedrockProvider("us-east-1", "AKIDEXAMPLE", "secret", "", "", apiBase, "", 0)
+	if err != nil {
+		t.Fatalf("NewBedrockProvider() error = %v", err)
+	}
+	p.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return p
+}
+
+func TestBedrockProvider_ConverseText(t *testing.T) {
+	var gotPath, gotAuth, gotDate string
+	var gotBody converseRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		gotDate = r.Header.Get("X-Amz-Date")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write(readFixture(t, "bedrock/converse_text.json"))
+	}))
+	defer server.Close()
+
+	p := newTestBedrockProvider(t, server.URL)
+	resp, err := p.Chat(context.Background(), []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hi"},
+	}, nil, "anthropic.claude-3-5-sonnet-20240620-v1:0", map[string]any{"max_tokens": 256, "temperature": 0.0})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if gotPath != "/model/anthropic.claude-3-5-sonnet-20240620-v1%3A0/converse" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20260102/us-east-1/bedrock/aws4_request") {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if gotDate != "20260102T030405Z" {
+		t.Errorf("X-Amz-Date = %q", gotDate)
+	}
+	if len(gotBody.System) != 1 || gotBody.System[0].Text != "Be brief." {
+		t.Errorf("system = %+v", gotBody.System)
+	}
+	if gotBody.InferenceConfig == nil || gotBody.InferenceConfig.MaxTokens != 256 ||
+		gotBody.InferenceConfig.Temperature == nil || *gotBody.InferenceConfig.Temperature != 0 {
+		t.Errorf("inferenceConfig = %+v", gotBody.InferenceConfig)
+	}
+
+	if resp.Content != "Hello! How can I help you today?" {
+		t.Errorf("Content = %q", resp.Content)
+	}
+	if resp.ReasoningContent != "The user greets me." {
+		t.Errorf("ReasoningContent = %q", resp.ReasoningContent)
+	}
+	if resp.FinishReason != "stop" {
+		t.Errorf("FinishReason = %q, want stop", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 36 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestBedrockProvider_ToolUse(t *testing.T) {
+	var gotBody converseRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write(readFixture(t, "bedrock/converse_tool_use.json"))
+	}))
+	defer server.Close()
+
+	p := newTestBedrockProvider(t, server.URL)
+	tools := []ToolDefinition{{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name:        "get_weather",
+			Description: "Get the weather",
+			Parameters: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"city": map[string]any{"type": "string"}},
+			},
+		},
+	}}
+	history := []Message{
+		{Role: "user", Content: "Weather in Paris and Rome?"},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "tooluse_a", Name: "get_weather", Arguments: map[string]any{"city": "Paris"}},
+			{ID: "tooluse_b", Name: "get_weather", Arguments: map[string]any{"city": "Rome"}},
+		}},
+		{Role: "tool", ToolCallID: "tooluse_a", Content: "sunny"},
+		{Role: "tool", ToolCallID: "tooluse_b", Content: ""},
+		{Role: "user", Content: "And Seattle?"},
+	}
+
+	resp, err := p.Chat(context.Background(), history, tools, "bedrock/amazon.nova-pro-v1:0", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	// Tool results and the follow-up question merge into one user turn.
+	if len(gotBody.Messages) != 3 {
+		t.Fatalf("messages = %d, want 3 alternating turns: %+v", len(gotBody.Messages), gotBody.Messages)
+	}
+	if got := gotBody.Messages[1].Content; len(got) != 2 || got[0].ToolUse == nil || got[0].ToolUse.Input["city"] != "Paris" {
+		t.Errorf("assistant turn = %+v", got)
+	}
+	userTurn := gotBody.Messages[2].Content
+	if len(userTurn) != 3 || userTurn[0].ToolResult == nil || userTurn[0].ToolResult.ToolUseID != "tooluse_a" {
+		t.Fatalf("user turn = %+v", userTurn)
+	}
+	if text := userTurn[1].ToolResult.Content[0].Text; text == "" {
+		t.Error("empty tool result should be replaced with a placeholder")
+	}
+	if userTurn[2].Text != "And Seattle?" {
+		t.Errorf("last block = %+v", userTurn[2])
+	}
+	if gotBody.ToolConfig == nil || gotBody.ToolConfig.Tools[0].ToolSpec.Name != "get_weather" {
+		t.Errorf("toolConfig = %+v", gotBody.ToolConfig)
+	}
+
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("ToolCalls = %d, want 1", len(resp.ToolCalls))
+	}
+	tc := resp.ToolCalls[0]
+	if tc.ID != "tooluse_kZJMlvQmRJ6eAyJE5GIl7Q" || tc.Name != "get_weather" || tc.Arguments["city"] != "Seattle" {
+		t.Errorf("tool call = %+v", tc)
+	}
+	if resp.FinishReason != "tool_calls" {
+		t.Errorf("FinishReason = %q, want tool_calls", resp.FinishReason)
+	}
+}
+
+func TestBedrockProvider_APIKeyUsesBearer(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Write(readFixture(t, "bedrock/converse_text.json"))
+	}))
+	defer server.Close()
+
+	p, err := NewBedrockProvider("eu-west-1", "", "", "", "bedrock-api-key", server.URL, "", 0)
+	if err != nil {
+		t.Fatalf("NewBedrockProvider() error = %v", err)
+	}
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "amazon.nova-lite-v1:0", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if gotAuth != "Bearer bedrock-api-key" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+}
+
+func TestBedrockProvider_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		errorType string
+		body      string
+		want      FailoverReason
+	}{
+		{"throttling", 429, "ThrottlingException:http://internal.amazon.com/coral/com.amazon.bedrock/",
+			string(readFixture(t, "bedrock/error_throttling.json")), FailoverRateLimit},
+		{"access denied", 403, "AccessDeniedException", `{"message":"You don't have access to the model"}`, FailoverAuth},
+		{"validation", 400, "ValidationException", `{"message":"Malformed input request"}`, FailoverFormat},
+		{"not ready", 429, "ModelNotReadyException", `{"message":"Model is not ready"}`, FailoverRateLimit},
+		{"unavailable", 503, "ServiceUnavailableException", `{"message":"Service unavailable"}`, FailoverTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				w.Header().Set("X-Amzn-ErrorType", tt.errorType)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			p := newTestBedrockProvider(t, server.URL)
+			_, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "m", nil)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			failErr := ClassifyError(err, "bedrock", "m")
+			if failErr == nil || failErr.Reason != tt.want {
+				t.Errorf("ClassifyError() = %+v, want %s", failErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestBedrockErrorPatternsWithoutStatus(t *testing.T) {
+	// Exception names alone (e.g. from a wrapped SDK error) are enough.
+	for msg, want := range map[string]FailoverReason{
+		"ThrottlingException: Rate exceeded":               FailoverRateLimit,
+		"UnrecognizedClientException: invalid token":       FailoverAuth,
+		"ModelTimeoutException: model took too long":       FailoverTimeout,
+		"ServiceQuotaExceededException: quota for account": FailoverRateLimit,
+	} {
+		failErr := ClassifyError(errString(msg), "bedrock", "m")
+		if failErr == nil || failErr.Reason != want {
+			t.Errorf("ClassifyError(%q) = %+v, want %s", msg, failErr, want)
+		}
+	}
+}
+
+type errString string
+
+func (e errString) Error() string { return string(e) }
+
+func TestNewBedrockProvider_RequiresRegionAndCredentials(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_BEARER_TOKEN_BEDROCK", "")
+
+	if _, err := NewBedrockProvider("", "a", "b", "", "", "", "", 0); err == nil {
+		t.Error("expected error without region")
+	}
+	if _, err := NewBedrockProvider("us-east-1", "", "", "", "", "", "", 0); err == nil {
+		t.Error("expected error without credentials")
+	}
+
+	t.Setenv("AWS_DEFAULT_REGION", "us-west-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	p, err := NewBedrockProvider("", "", "", "", "", "", "", 0)
+	if err != nil {
+		t.Fatalf("env fallback: %v", err)
+	}
+	if p.apiBase != "https://bedrock-runtime.us-west-2.amazonaws.com" {
+		t.Errorf("apiBase = %q", p.apiBase)
+	}
+}
+
+func TestSignAWSRequestV4_KnownVector(t *testing.T) {
+	// Example from the AWS SigV4 documentation (IAM ListUsers).
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	signAWSRequestV4(req, nil, awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n  %s\nwant\n  %s", got, want)
+	}
+}
@@ -1,31 +1,61 @@
 package providers
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
+	"os"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 const (
 	defaultFailureWindow = 24 * time.Hour
+
+	// failingStreakTimeout ends a failure streak that has gone quiet: a
+	// provider that is out of cooldown and has not failed for this long is
+	// no longer reported as failing, even without a success in between.
+	failingStreakTimeout = time.Hour
 )
 
 // CooldownTracker manages per-provider cooldown state for the fallback chain.
-// Thread-safe via sync.RWMutex. In-memory only (resets on restart).
+// Thread-safe via sync.RWMutex. In-memory by default; trackers created with
+// NewPersistentCooldownTracker survive restarts by saving to a JSON file.
 type CooldownTracker struct {
 	mu            sync.RWMutex
 	entries       map[string]*cooldownEntry
 	failureWindow time.Duration
+	storePath     string           // empty means in-memory only
 	nowFunc       func() time.Time // for testing
 }
 
 type cooldownEntry struct {
-	ErrorCount     int
-	FailureCounts  map[FailoverReason]int
-	CooldownEnd    time.Time      // standard cooldown expiry
-	DisabledUntil  time.Time      // billing-specific disable expiry
-	DisabledReason FailoverReason // reason for disable (billing)
-	LastFailure    time.Time
+	ErrorCount     int                    `json:"error_count"`
+	FailureCounts  map[FailoverReason]int `json:"failure_counts,omitempty"`
+	CooldownEnd    time.Time              `json:"cooldown_end"`    // standard cooldown expiry
+	DisabledUntil  time.Time              `json:"disabled_until"`  // billing-specific disable expiry
+	DisabledReason FailoverReason         `json:"disabled_reason"` // reason for disable (billing)
+	LastFailure    time.Time              `json:"last_failure"`
+	LastSuccess    time.Time              `json:"last_success"`
+	FailingSince   time.Time              `json:"failing_since"` // first failure since the last success
+}
+
+// CooldownStatus is a point-in-time view of one provider's cooldown state,
+// suitable for JSON status endpoints.
+type CooldownStatus struct {
+	Provider          string                 `json:"provider"`
+	Available         bool                   `json:"available"`
+	ErrorCount        int                    `json:"error_count"`
+	FailureCounts     map[FailoverReason]int `json:"failure_counts,omitempty"`
+	CooldownRemaining string                 `json:"cooldown_remaining,omitempty"`
+	DisabledReason    FailoverReason         `json:"disabled_reason,omitempty"`
+	LastFailure       *time.Time             `json:"last_failure,omitempty"`
+	LastSuccess       *time.Time             `json:"last_success,omitempty"`
+	FailingSince      *time.Time             `json:"failing_since,omitempty"`
 }
 
 // NewCooldownTracker creates a tracker with default 24h failure window.
@@ -37,6 +67,22 @@ func NewCooldownTracker() *CooldownTracker {
 	}
 }
 
+// NewPersistentCooldownTracker creates a tracker that loads its state from
+// path and writes it back atomically after every change. A missing or
+// unreadable file starts the tracker empty.
+func NewPersistentCooldownTracker(path string) *CooldownTracker {
+	ct := NewCooldownTracker()
+	ct.storePath = path
+
+	if err := ct.load(); err != nil {
+		logger.WarnCF("providers", "Failed to load cooldown state", map[string]any{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+	return ct
+}
+
 // MarkFailure records a failure for a provider and sets appropriate cooldown.
 // Resets error counts if last failure was more than failureWindow ago.
 func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
@@ -55,6 +101,9 @@ func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
 	entry.ErrorCount++
 	entry.FailureCounts[reason]++
 	entry.LastFailure = now
+	if entry.FailingSince.IsZero() {
+		entry.FailingSince = now
+	}
 
 	if reason == FailoverBilling {
 		billingCount := entry.FailureCounts[FailoverBilling]
@@ -63,6 +112,8 @@ func (ct *CooldownTracker) MarkFailure(provider string, reason FailoverReason) {
 	} else {
 		entry.CooldownEnd = now.Add(calculateStandardCooldown(entry.ErrorCount))
 	}
+
+	ct.saveLocked()
 }
 
 // MarkSuccess resets all counters and cooldowns for a provider.
@@ -71,8 +122,11 @@ func (ct *CooldownTracker) MarkSuccess(provider string) {
 	defer ct.mu.Unlock()
 
 	entry := ct.entries[provider]
+	// Only touch the disk when the provider's health actually changed;
+	// successes are far more frequent than failures on flash-backed devices.
+	changed := entry == nil || entry.ErrorCount > 0 || !entry.FailingSince.IsZero()
 	if entry == nil {
-		return
+		entry = ct.getOrCreate(provider)
 	}
 
 	entry.ErrorCount = 0
@@ -80,6 +134,12 @@ func (ct *CooldownTracker) MarkSuccess(provider string) {
 	entry.CooldownEnd = time.Time{}
 	entry.DisabledUntil = time.Time{}
 	entry.DisabledReason = ""
+	entry.FailingSince = time.Time{}
+	entry.LastSuccess = ct.nowFunc()
+
+	if changed {
+		ct.saveLocked()
+	}
 }
 
 // IsAvailable returns true if the provider is not in cooldown or disabled.
@@ -162,6 +222,116 @@ func (ct *CooldownTracker) FailureCount(provider string, reason FailoverReason)
 	return entry.FailureCounts[reason]
 }
 
+// FailingFor returns how long a provider has been failing without a single
+// success in between. Returns 0 if the provider is healthy, or if it is out
+// of cooldown and has not failed for an hour, so one failure followed by an
+// idle period does not count as an outage.
+func (ct *CooldownTracker) FailingFor(provider string) time.Duration {
+	ct.mu.RLock()
+	defer ct.mu.RUnlock()
+
+	entry := ct.entries[provider]
+	if entry == nil || entry.FailingSince.IsZero() {
+		return 0
+	}
+	now := ct.nowFunc()
+	inCooldown := now.Before(entry.CooldownEnd) || now.Before(entry.DisabledUntil)
+	if !inCooldown && now.Sub(entry.LastFailure) >= failingStreakTimeout {
+		return 0
+	}
+	return now.Sub(entry.FailingSince)
+}
+
+// Snapshot returns the cooldown state of every tracked provider, sorted by name.
+func (ct *CooldownTracker) Snapshot() []CooldownStatus {
+	ct.mu.RLock()
+	names := make([]string, 0, len(ct.entries))
+	for name := range ct.entries {
+		names = append(names, name)
+	}
+	ct.mu.RUnlock()
+	sort.Strings(names)
+
+	statuses := make([]CooldownStatus, 0, len(names))
+	for _, name := range names {
+		remaining := ct.CooldownRemaining(name)
+
+		ct.mu.RLock()
+		entry := ct.entries[name]
+		status := CooldownStatus{
+			Provider:       name,
+			Available:      remaining == 0,
+			ErrorCount:     entry.ErrorCount,
+			FailureCounts:  make(map[FailoverReason]int, len(entry.FailureCounts)),
+			DisabledReason: entry.DisabledReason,
+			LastFailure:    timePtr(entry.LastFailure),
+			LastSuccess:    timePtr(entry.LastSuccess),
+			FailingSince:   timePtr(entry.FailingSince),
+		}
+		for reason, count := range entry.FailureCounts {
+			status.FailureCounts[reason] = count
+		}
+		ct.mu.RUnlock()
+
+		if remaining > 0 {
+			status.CooldownRemaining = remaining.Round(time.Second).String()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// load reads persisted entries from storePath.
+func (ct *CooldownTracker) load() error {
+	data, err := os.ReadFile(ct.storePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read cooldown state: %w", err)
+	}
+
+	entries := make(map[string]*cooldownEntry)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to unmarshal cooldown state: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.FailureCounts == nil {
+			entry.FailureCounts = make(map[FailoverReason]int)
+		}
+	}
+
+	ct.mu.Lock()
+	ct.entries = entries
+	ct.mu.Unlock()
+	return nil
+}
+
+// saveLocked persists all entries to storePath. Must be called with the lock held.
+func (ct *CooldownTracker) saveLocked() {
+	if ct.storePath == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(ct.entries, "", "  ")
+	if err == nil {
+		err = fileutil.WriteFileAtomic(ct.storePath, data, 0o600)
+	}
+	if err != nil {
+		logger.WarnCF("providers", "Failed to save cooldown state", map[string]any{
+			"path":  ct.storePath,
+			"error": err.Error(),
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
 func (ct *CooldownTracker) getOrCreate(provider string) *cooldownEntry {
 	entry := ct.entries[provider]
 	if entry == nil {
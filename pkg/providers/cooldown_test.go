@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -267,3 +268,97 @@ func TestCooldown_MultipleProviders(t *testing.T) {
 		t.Error("groq should be available")
 	}
 }
+
+func TestCooldown_FailingFor(t *testing.T) {
+	now := time.Now()
+	ct, current := newTestTracker(now)
+
+	if ct.FailingFor("openai") != 0 {
+		t.Error("untouched provider should not be failing")
+	}
+
+	ct.MarkFailure("openai", FailoverTimeout)
+	*current = now.Add(30 * time.Minute)
+	ct.MarkFailure("openai", FailoverTimeout)
+	*current = now.Add(61 * time.Minute)
+
+	if got := ct.FailingFor("openai"); got != 61*time.Minute {
+		t.Errorf("FailingFor = %v, want 61m (measured from first failure)", got)
+	}
+
+	ct.MarkSuccess("openai")
+	if ct.FailingFor("openai") != 0 {
+		t.Error("success should clear failing state")
+	}
+}
+
+func TestCooldown_FailingForEndsWhenIdle(t *testing.T) {
+	now := time.Now()
+	ct, current := newTestTracker(now)
+
+	ct.MarkFailure("openai", FailoverTimeout)
+	*current = now.Add(30 * time.Minute)
+	if got := ct.FailingFor("openai"); got != 30*time.Minute {
+		t.Errorf("FailingFor = %v, want 30m while the failure is recent", got)
+	}
+
+	// One failure followed by an idle hour is not an ongoing outage.
+	*current = now.Add(61 * time.Minute)
+	if got := ct.FailingFor("openai"); got != 0 {
+		t.Errorf("FailingFor = %v, want 0 after an idle hour", got)
+	}
+
+	// A billing disable keeps the streak alive while it lasts.
+	ct.MarkFailure("anthropic", FailoverBilling)
+	*current = now.Add(3 * time.Hour)
+	if got := ct.FailingFor("anthropic"); got != 119*time.Minute {
+		t.Errorf("FailingFor = %v, want 119m during the billing disable", got)
+	}
+}
+
+func TestCooldown_PersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "provider_cooldown.json")
+
+	ct := NewPersistentCooldownTracker(path)
+	ct.MarkFailure("openai", FailoverRateLimit)
+	ct.MarkFailure("anthropic", FailoverBilling)
+
+	restored := NewPersistentCooldownTracker(path)
+	if restored.IsAvailable("openai") {
+		t.Error("openai cooldown should survive restart")
+	}
+	if restored.IsAvailable("anthropic") {
+		t.Error("anthropic billing disable should survive restart")
+	}
+	if got := restored.FailureCount("anthropic", FailoverBilling); got != 1 {
+		t.Errorf("billing failure count = %d, want 1", got)
+	}
+
+	restored.MarkSuccess("openai")
+	again := NewPersistentCooldownTracker(path)
+	if !again.IsAvailable("openai") {
+		t.Error("recovery should be persisted too")
+	}
+}
+
+func TestCooldown_Snapshot(t *testing.T) {
+	now := time.Now()
+	ct, _ := newTestTracker(now)
+
+	ct.MarkFailure("openai", FailoverRateLimit)
+	ct.MarkSuccess("anthropic")
+
+	snap := ct.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("snapshot len = %d, want 2", len(snap))
+	}
+	if snap[0].Provider != "anthropic" || !snap[0].Available || snap[0].LastSuccess == nil {
+		t.Errorf("unexpected anthropic status: %+v", snap[0])
+	}
+	if snap[1].Provider != "openai" || snap[1].Available || snap[1].CooldownRemaining == "" {
+		t.Errorf("unexpected openai status: %+v", snap[1])
+	}
+	if snap[1].FailureCounts[FailoverRateLimit] != 1 {
+		t.Errorf("openai rate_limit count = %d, want 1", snap[1].FailureCounts[FailoverRateLimit])
+	}
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"regexp"
 	"strings"
 )
@@ -28,12 +29,18 @@ var (
 		substr("resource_exhausted"),
 		substr("quota exceeded"),
 		substr("usage limit"),
+		substr("throttlingexception"),            // AWS Bedrock
+		substr("servicequotaexceededexception"),  // AWS Bedrock
+		substr("too many tokens"),                // AWS Bedrock
+		rxp(`requests? to the .* have exceeded`), // Azure OpenAI
 	}
 
 	overloadedPatterns = []errorPattern{
 		rxp(`overloaded_error`),
 		rxp(`"type"\s*:\s*"overloaded_error"`),
 		substr("overloaded"),
+		substr("modelnotreadyexception"),      // AWS Bedrock
+		substr("serviceunavailableexception"), // AWS Bedrock
 	}
 
 	timeoutPatterns = []errorPattern{
@@ -41,6 +48,7 @@ var (
 		substr("timed out"),
 		substr("deadline exceeded"),
 		substr("context deadline exceeded"),
+		substr("modeltimeoutexception"), // AWS Bedrock
 	}
 
 	billingPatterns = []errorPattern{
@@ -68,6 +76,10 @@ var (
 		rxp(`\b403\b`),
 		substr("no credentials found"),
 		substr("no api key found"),
+		substr("unrecognizedclientexception"),    // AWS Bedrock
+		substr("invalidsignatureexception"),      // AWS Bedrock
+		substr("accessdeniedexception"),          // AWS Bedrock
+		substr("principal does not have access"), // Azure OpenAI
 	}
 
 	formatPatterns = []errorPattern{
@@ -76,6 +88,7 @@ var (
 		substr("tool_use_id"),
 		substr("messages.1.content.1.tool_use.id"),
 		substr("invalid request format"),
+		substr("validationexception"), // AWS Bedrock
 	}
 
 	imageDimensionPatterns = []errorPattern{
@@ -116,6 +129,19 @@ func ClassifyError(err error, provider, model string) *FailoverError {
 		}
 	}
 
+	// Already classified (e.g. by a load-balanced provider): keep the reason,
+	// but attribute it to the candidate being evaluated.
+	var classified *FailoverError
+	if errors.As(err, &classified) {
+		return &FailoverError{
+			Reason:   classified.Reason,
+			Provider: provider,
+			Model:    model,
+			Status:   classified.Status,
+			Wrapped:  err,
+		}
+	}
+
 	msg := strings.ToLower(err.Error())
 
 	// Image dimension/size errors: non-retriable, non-fallback.
@@ -53,7 +53,8 @@ func ExtractProtocol(model string) (protocol, modelID string) {
 
 // CreateProviderFromConfig creates a provider based on the ModelConfig.
 // It uses the protocol prefix in the Model field to determine which provider to create.
-// Supported protocols: openai, anthropic, antigravity, claude-cli, codex-cli, github-copilot
+// Supported protocols: openai, anthropic, gemini, azure, bedrock, antigravity, claude-cli, codex-cli,
+// github-copilot and the OpenAI-compatible vendors.
 // Returns the provider, the model ID (without protocol prefix), and any error.
 func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, error) {
 	if cfg == nil {
@@ -92,7 +93,31 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 			cfg.RequestTimeout,
 		), modelID, nil
 
-	case "openrouter", "groq", "zhipu", "gemini", "nvidia",
+	case "gemini":
+		if cfg.APIKey == "" && cfg.APIBase == "" {
+			return nil, "", fmt.Errorf("api_key or api_base is required for HTTP-based protocol %q", protocol)
+		}
+		if !cfg.NativeAPI {
+			// OpenAI-compatible requests, as gemini/ entries have always used.
+			apiBase := cfg.APIBase
+			if apiBase == "" {
+				apiBase = getDefaultAPIBase(protocol)
+			}
+			return NewHTTPProviderWithMaxTokensFieldAndRequestTimeout(
+				cfg.APIKey,
+				apiBase,
+				cfg.Proxy,
+				cfg.MaxTokensField,
+				cfg.RequestTimeout,
+			), modelID, nil
+		}
+		if strings.HasSuffix(strings.TrimRight(cfg.APIBase, "/"), "/openai") {
+			return nil, "", fmt.Errorf("native_api needs the native Gemini api_base, not the OpenAI-compatible %q",
+				cfg.APIBase)
+		}
+		return NewGeminiProvider(cfg.APIKey, cfg.APIBase, cfg.Proxy, cfg.RequestTimeout), modelID, nil
+
+	case "openrouter", "groq", "zhipu", "nvidia",
 		"ollama", "moonshot", "shengsuanyun", "deepseek", "cerebras",
 		"volcengine", "vllm", "qwen", "mistral":
 		// All other OpenAI-compatible HTTP providers
@@ -136,6 +161,38 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 			cfg.RequestTimeout,
 		), modelID, nil
 
+	case "azure", "azure-openai":
+		if cfg.APIBase == "" {
+			return nil, "", fmt.Errorf("api_base is required for azure protocol (model: %s)", cfg.Model)
+		}
+		if cfg.APIKey == "" {
+			return nil, "", fmt.Errorf("api_key is required for azure protocol (model: %s)", cfg.Model)
+		}
+		return NewAzureOpenAIProvider(
+			cfg.APIKey,
+			cfg.APIBase,
+			cfg.APIVersion,
+			cfg.Proxy,
+			cfg.MaxTokensField,
+			cfg.RequestTimeout,
+		), modelID, nil
+
+	case "bedrock", "aws-bedrock":
+		provider, err := NewBedrockProvider(
+			cfg.AWSRegion,
+			cfg.AWSAccessKeyID,
+			cfg.AWSSecretAccessKey,
+			cfg.AWSSessionToken,
+			cfg.APIKey,
+			cfg.APIBase,
+			cfg.Proxy,
+			cfg.RequestTimeout,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+		return provider, modelID, nil
+
 	case "antigravity":
 		return NewAntigravityProvider(), modelID, nil
 
@@ -290,3 +290,50 @@ func TestCreateProviderFromConfig_RequestTimeoutPropagation(t *testing.T) {
 		t.Fatalf("Chat() error = %q, want timeout-related error", errMsg)
 	}
 }
+
+func TestCreateProviderFromConfig_GeminiNative(t *testing.T) {
+	// Without native_api, gemini/ keeps the OpenAI-compatible provider, also
+	// with the default api_base.
+	for _, apiBase := range []string{"", "https://generativelanguage.googleapis.com/v1beta"} {
+		provider, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+			ModelName: "gemini",
+			Model:     "gemini/gemini-2.5-flash",
+			APIKey:    "test-key",
+			APIBase:   apiBase,
+		})
+		if err != nil {
+			t.Fatalf("CreateProviderFromConfig(api_base %q) error = %v", apiBase, err)
+		}
+		if _, ok := provider.(*HTTPProvider); !ok {
+			t.Errorf("api_base %q: provider = %T, want *HTTPProvider", apiBase, provider)
+		}
+		if modelID != "gemini-2.5-flash" {
+			t.Errorf("modelID = %q, want %q", modelID, "gemini-2.5-flash")
+		}
+	}
+
+	provider, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "gemini",
+		Model:     "gemini/gemini-2.5-flash",
+		APIKey:    "test-key",
+		NativeAPI: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*GeminiProvider); !ok {
+		t.Errorf("provider = %T, want *GeminiProvider", provider)
+	}
+
+	// native_api with the OpenAI-compatible base is a configuration mistake.
+	_, _, err = CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "gemini-compat",
+		Model:     "gemini/gemini-2.5-flash",
+		APIKey:    "test-key",
+		APIBase:   "https://generativelanguage.googleapis.com/v1beta/openai/",
+		NativeAPI: true,
+	})
+	if err == nil {
+		t.Error("native_api with an /openai api_base should fail")
+	}
+}
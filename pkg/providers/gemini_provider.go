@@ -0,0 +1,240 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	geminiDefaultAPIBase = "https://generativelanguage.googleapis.com/v1beta"
+	geminiDefaultModel   = "gemini-2.5-flash"
+
+	// geminiMaxInlineBytes keeps inline attachments well under the 20 MB
+	// request limit of generateContent.
+	geminiMaxInlineBytes = 15 << 20
+)
+
+// GeminiProvider implements LLMProvider using the native Google Gemini
+// generateContent API with an API key. Unlike the OpenAI-compatible shim it
+// keeps thought signatures, native function calling and inline image parts.
+type GeminiProvider struct {
+	apiKey     string
+	apiBase    string
+	httpClient *http.Client
+}
+
+// NewGeminiProvider creates a Gemini provider. apiBase defaults to the public
+// v1beta endpoint; requestTimeoutSeconds <= 0 uses the 120s default.
+func NewGeminiProvider(apiKey, apiBase, proxy string, requestTimeoutSeconds int) *GeminiProvider {
+	if apiBase == "" {
+		apiBase = geminiDefaultAPIBase
+	}
+	timeout := 120 * time.Second
+	if requestTimeoutSeconds > 0 {
+		timeout = time.Duration(requestTimeoutSeconds) * time.Second
+	}
+
+	client := &http.Client{Timeout: timeout}
+	if proxy != "" {
+		if parsed, err := url.Parse(proxy); err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(parsed)}
+		} else {
+			logger.WarnCF("provider.gemini", "Invalid proxy URL, ignoring", map[string]any{
+				"proxy": proxy,
+				"error": err.Error(),
+			})
+		}
+	}
+
+	return &GeminiProvider{
+		apiKey:     apiKey,
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		httpClient: client,
+	}
+}
+
+// Chat implements LLMProvider.Chat using models/{model}:generateContent.
+func (p *GeminiProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	model = strings.TrimPrefix(model, "gemini/")
+	model = strings.TrimPrefix(model, "models/")
+	if model == "" || model == "gemini" {
+		model = geminiDefaultModel
+	}
+
+	apiResp, err := p.generateContent(ctx, model, buildGeminiRequest(messages, tools, options))
+	if err != nil {
+		return nil, err
+	}
+
+	var collector geminiResponseCollector
+	collector.add(*apiResp)
+	llmResp := collector.result()
+
+	if llmResp.Content == "" && len(llmResp.ToolCalls) == 0 {
+		finish := ""
+		if len(apiResp.Candidates) > 0 {
+			finish = apiResp.Candidates[0].FinishReason
+		}
+		return nil, fmt.Errorf("gemini: model returned an empty response (finish reason: %q)", finish)
+	}
+
+	return llmResp, nil
+}
+
+// GetDefaultModel returns the default model identifier.
+func (p *GeminiProvider) GetDefaultModel() string {
+	return geminiDefaultModel
+}
+
+// generateContent posts a request to models/{model}:generateContent.
+func (p *GeminiProvider) generateContent(
+	ctx context.Context,
+	model string,
+	body antigravityRequest,
+) (*antigravityJSONResponse, error) {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	apiURL := fmt.Sprintf("%s/models/%s:generateContent", p.apiBase, url.PathEscape(model))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("x-goog-api-key", p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gemini API call: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		logger.ErrorCF("provider.gemini", "API call failed", map[string]any{
+			"status_code": resp.StatusCode,
+			"response":    truncateString(string(respBody), 500),
+			"model":       model,
+		})
+		return nil, parseGeminiError(resp.StatusCode, respBody)
+	}
+
+	var apiResp antigravityJSONResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("gemini: decoding response: %w", err)
+	}
+	return &apiResp, nil
+}
+
+// parseGeminiError formats a Google API error so ClassifyError can read the
+// HTTP status and reason (e.g. RESOURCE_EXHAUSTED) from the message.
+func parseGeminiError(statusCode int, body []byte) error {
+	var errResp struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error.Message == "" {
+		return fmt.Errorf("gemini API request failed:\n  Status: %d\n  Body:   %s",
+			statusCode, truncateString(string(body), 500))
+	}
+	return fmt.Errorf("gemini API request failed:\n  Status: %d (%s)\n  Message: %s",
+		statusCode, errResp.Error.Status, errResp.Error.Message)
+}
+
+// geminiMediaParts converts attached media (local file paths or data: URLs)
+// into inlineData parts. Unsupported or unreadable attachments are skipped.
+func geminiMediaParts(media []string) []antigravityPart {
+	var parts []antigravityPart
+	for _, m := range media {
+		mimeType, data, err := loadInlineMedia(m)
+		if err != nil {
+			logger.WarnCF("provider.gemini", "Skipping media attachment", map[string]any{
+				"media": truncateString(m, 80),
+				"error": err.Error(),
+			})
+			continue
+		}
+		parts = append(parts, antigravityPart{
+			InlineData: &antigravityInlineData{MimeType: mimeType, Data: data},
+		})
+	}
+	return parts
+}
+
+// loadInlineMedia returns the MIME type and base64 payload of a media reference.
+func loadInlineMedia(ref string) (string, string, error) {
+	if rest, ok := strings.CutPrefix(ref, "data:"); ok {
+		meta, payload, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(meta, ";base64") {
+			return "", "", fmt.Errorf("unsupported data URL")
+		}
+		return strings.TrimSuffix(meta, ";base64"), payload, nil
+	}
+
+	info, err := os.Stat(ref)
+	if err != nil {
+		return "", "", err
+	}
+	if info.Size() > geminiMaxInlineBytes {
+		return "", "", fmt.Errorf("file too large for inline data (%d bytes)", info.Size())
+	}
+	raw, err := os.ReadFile(ref)
+	if err != nil {
+		return "", "", err
+	}
+
+	mimeType := http.DetectContentType(raw)
+	if mimeType == "application/octet-stream" || strings.HasPrefix(mimeType, "text/plain") {
+		switch strings.ToLower(filepath.Ext(ref)) {
+		case ".pdf":
+			mimeType = "application/pdf"
+		case ".mp3":
+			mimeType = "audio/mpeg"
+		case ".ogg", ".oga":
+			mimeType = "audio/ogg"
+		case ".wav":
+			mimeType = "audio/wav"
+		}
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	if !strings.HasPrefix(mimeType, "image/") && !strings.HasPrefix(mimeType, "audio/") &&
+		mimeType != "application/pdf" {
+		return "", "", fmt.Errorf("unsupported media type %q", mimeType)
+	}
+
+	return mimeType, base64.StdEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,213 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeminiProvider_ChatSendsNativeRequest(t *testing.T) {
+	var gotPath, gotKey string
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("x-goog-api-key")
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"candidates": [{
+				"content": {"role": "model", "parts": [
+					{"text": "thinking...", "thought": true},
+					{"text": "Hello "},
+					{"text": "there"}
+				]},
+				"finishReason": "STOP"
+			}],
+			"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 3, "totalTokenCount": 13}
+		}`))
+	}))
+	defer server.Close()
+
+	p := NewGeminiProvider("test-key", server.URL, "", 0)
+	resp, err := p.Chat(context.Background(), []Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "Hi"},
+	}, nil, "gemini-2.5-pro", map[string]any{"max_tokens": 100})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if gotPath != "/models/gemini-2.5-pro:generateContent" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotKey != "test-key" {
+		t.Errorf("x-goog-api-key = %q, want test-key", gotKey)
+	}
+	sys, ok := gotBody["systemInstruction"].(map[string]any)
+	if !ok {
+		t.Fatalf("systemInstruction missing: %v", gotBody)
+	}
+	if text := sys["parts"].([]any)[0].(map[string]any)["text"]; text != "You are helpful." {
+		t.Errorf("systemInstruction text = %v", text)
+	}
+	if cfg := gotBody["generationConfig"].(map[string]any); cfg["maxOutputTokens"] != float64(100) {
+		t.Errorf("maxOutputTokens = %v", cfg["maxOutputTokens"])
+	}
+
+	if resp.Content != "Hello there" {
+		t.Errorf("Content = %q, want %q", resp.Content, "Hello there")
+	}
+	if resp.ReasoningContent != "thinking..." {
+		t.Errorf("ReasoningContent = %q", resp.ReasoningContent)
+	}
+	if resp.FinishReason != "stop" {
+		t.Errorf("FinishReason = %q, want stop", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 13 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestGeminiProvider_ToolCallRoundTrip(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		w.Write([]byte(`{"candidates": [{"content": {"role": "model", "parts": [{
+			"functionCall": {"name": "read_file", "args": {"path": "a.txt"}},
+			"thoughtSignature": "sig-123"
+		}]}, "finishReason": "STOP"}]}`))
+	}))
+	defer server.Close()
+
+	p := NewGeminiProvider("k", server.URL, "", 0)
+	tools := []ToolDefinition{{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name:        "read_file",
+			Description: "Read a file",
+			Parameters: map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"path": map[string]any{"type": "string", "minLength": 1},
+				},
+			},
+		},
+	}}
+
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "read a.txt"}}, tools, "gemini-2.5-flash", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("ToolCalls = %d, want 1", len(resp.ToolCalls))
+	}
+	tc := resp.ToolCalls[0]
+	if tc.Name != "read_file" || tc.Arguments["path"] != "a.txt" {
+		t.Errorf("tool call = %+v", tc)
+	}
+	if tc.Function == nil || tc.Function.ThoughtSignature != "sig-123" {
+		t.Errorf("thought signature not preserved: %+v", tc.Function)
+	}
+	if resp.FinishReason != "tool_calls" {
+		t.Errorf("FinishReason = %q, want tool_calls", resp.FinishReason)
+	}
+
+	decl := requests[0]["tools"].([]any)[0].(map[string]any)["functionDeclarations"].([]any)[0].(map[string]any)
+	params := decl["parameters"].(map[string]any)
+	if _, ok := params["additionalProperties"]; ok {
+		t.Error("additionalProperties should be stripped from tool schema")
+	}
+
+	// Second turn: the assistant tool call and its result go back with the signature.
+	_, err = p.Chat(context.Background(), []Message{
+		{Role: "user", Content: "read a.txt"},
+		{Role: "assistant", ToolCalls: resp.ToolCalls},
+		{Role: "tool", ToolCallID: tc.ID, Content: "file contents"},
+	}, tools, "gemini-2.5-flash", nil)
+	if err != nil {
+		t.Fatalf("second Chat() error = %v", err)
+	}
+
+	contents := requests[1]["contents"].([]any)
+	if len(contents) != 3 {
+		t.Fatalf("contents = %d, want 3", len(contents))
+	}
+	modelPart := contents[1].(map[string]any)["parts"].([]any)[0].(map[string]any)
+	if modelPart["thoughtSignature"] != "sig-123" {
+		t.Errorf("thoughtSignature = %v, want sig-123", modelPart["thoughtSignature"])
+	}
+	respPart := contents[2].(map[string]any)["parts"].([]any)[0].(map[string]any)
+	fr := respPart["functionResponse"].(map[string]any)
+	if fr["name"] != "read_file" {
+		t.Errorf("functionResponse name = %v, want read_file", fr["name"])
+	}
+}
+
+func TestGeminiProvider_InlineImage(t *testing.T) {
+	// Minimal PNG header is enough for content sniffing.
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	imgPath := filepath.Join(t.TempDir(), "photo.png")
+	if err := os.WriteFile(imgPath, png, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"candidates": [{"content": {"parts": [{"text": "a cat"}]}}]}`))
+	}))
+	defer server.Close()
+
+	p := NewGeminiProvider("k", server.URL, "", 0)
+	_, err := p.Chat(context.Background(), []Message{
+		{Role: "user", Content: "what is this?", Media: []string{imgPath, "data:image/jpeg;base64,AAAA"}},
+	}, nil, "", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	parts := gotBody["contents"].([]any)[0].(map[string]any)["parts"].([]any)
+	if len(parts) != 3 {
+		t.Fatalf("parts = %d, want 3 (text + 2 images)", len(parts))
+	}
+	inline := parts[1].(map[string]any)["inlineData"].(map[string]any)
+	if inline["mimeType"] != "image/png" {
+		t.Errorf("mimeType = %v, want image/png", inline["mimeType"])
+	}
+	inline = parts[2].(map[string]any)["inlineData"].(map[string]any)
+	if inline["mimeType"] != "image/jpeg" || inline["data"] != "AAAA" {
+		t.Errorf("data URL part = %v", inline)
+	}
+}
+
+func TestGeminiProvider_ErrorIsClassified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"code": 429, "message": "Quota exceeded", "status": "RESOURCE_EXHAUSTED"}}`))
+	}))
+	defer server.Close()
+
+	p := NewGeminiProvider("k", server.URL, "", 0)
+	_, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "gemini-2.5-flash", nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	failErr := ClassifyError(err, "gemini", "gemini-2.5-flash")
+	if failErr == nil || failErr.Reason != FailoverRateLimit {
+		t.Errorf("ClassifyError() = %+v, want rate_limit", failErr)
+	}
+}
@@ -0,0 +1,312 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultProbeInterval = 5 * time.Minute
+	defaultProbeTimeout  = 30 * time.Second
+)
+
+// ProbeStatus is the latest result of a background health probe for one
+// model_list entry.
+type ProbeStatus struct {
+	ModelName    string     `json:"model_name"`
+	Model        string     `json:"model"`
+	Provider     string     `json:"provider"`
+	Method       string     `json:"method"`
+	Healthy      bool       `json:"healthy"`
+	LastProbe    *time.Time `json:"last_probe,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	LatencyMs    int64      `json:"latency_ms"`
+	FailingSince *time.Time `json:"failing_since,omitempty"`
+}
+
+// HealthProber periodically probes model_list entries that have health_check
+// enabled and feeds the results into a CooldownTracker, so the fallback chain
+// skips providers that are known to be down and recovers them as soon as a
+// probe succeeds again.
+type HealthProber struct {
+	cooldown *CooldownTracker
+	targets  []probeTarget
+	client   *http.Client
+
+	mu       sync.RWMutex
+	statuses map[string]*ProbeStatus
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type probeTarget struct {
+	cfg      config.ModelConfig
+	id       string // model_name, or the endpoint key for a balanced alias
+	provider string
+	method   string
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// NewHealthProber creates a prober for every model_list entry with
+// health_check.enabled set. Returns a prober with no targets if none are enabled.
+func NewHealthProber(modelList []config.ModelConfig, cooldown *CooldownTracker) *HealthProber {
+	hp := &HealthProber{
+		cooldown: cooldown,
+		client:   &http.Client{},
+		statuses: make(map[string]*ProbeStatus),
+	}
+
+	entries := make(map[string]int)
+	for _, mc := range modelList {
+		entries[mc.ModelName]++
+	}
+	seen := make(map[string]int)
+	for _, mc := range modelList {
+		seen[mc.ModelName]++
+		if mc.HealthCheck == nil || !mc.HealthCheck.Enabled {
+			continue
+		}
+
+		// Entries of a load-balanced alias report on their own endpoint, like
+		// the balancer's live traffic does.
+		id, key := mc.ModelName, probeProviderKey(mc.Model)
+		if entries[mc.ModelName] > 1 {
+			id = EndpointKey(mc.ModelName, seen[mc.ModelName])
+			key = id
+		}
+		target := probeTarget{
+			cfg:      mc,
+			id:       id,
+			provider: key,
+			method:   strings.ToLower(strings.TrimSpace(mc.HealthCheck.Method)),
+			interval: time.Duration(mc.HealthCheck.IntervalSeconds) * time.Second,
+			timeout:  time.Duration(mc.HealthCheck.TimeoutSeconds) * time.Second,
+		}
+		if target.method == "" {
+			target.method = "chat"
+		}
+		if target.interval <= 0 {
+			target.interval = defaultProbeInterval
+		}
+		if target.timeout <= 0 {
+			target.timeout = defaultProbeTimeout
+		}
+
+		hp.targets = append(hp.targets, target)
+		hp.statuses[id] = &ProbeStatus{
+			ModelName: mc.ModelName,
+			Model:     mc.Model,
+			Provider:  target.provider,
+			Method:    target.method,
+			Healthy:   true,
+		}
+	}
+
+	return hp
+}
+
+// Start launches one probe goroutine per target. It is a no-op when no
+// model_list entry has health checks enabled.
+func (hp *HealthProber) Start(ctx context.Context) {
+	if len(hp.targets) == 0 {
+		return
+	}
+
+	ctx, hp.cancel = context.WithCancel(ctx)
+	for _, target := range hp.targets {
+		hp.wg.Add(1)
+		go hp.run(ctx, target)
+	}
+
+	logger.InfoCF("providers", "Health prober started", map[string]any{
+		"targets": len(hp.targets),
+	})
+}
+
+// Stop cancels all probe goroutines and waits for them to exit.
+func (hp *HealthProber) Stop() {
+	if hp.cancel != nil {
+		hp.cancel()
+	}
+	hp.wg.Wait()
+}
+
+// Status returns the latest probe result for every target, sorted by model name.
+func (hp *HealthProber) Status() []ProbeStatus {
+	hp.mu.RLock()
+	defer hp.mu.RUnlock()
+
+	statuses := make([]ProbeStatus, 0, len(hp.statuses))
+	for _, s := range hp.statuses {
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].ModelName != statuses[j].ModelName {
+			return statuses[i].ModelName < statuses[j].ModelName
+		}
+		return statuses[i].Provider < statuses[j].Provider
+	})
+	return statuses
+}
+
+func (hp *HealthProber) run(ctx context.Context, target probeTarget) {
+	defer hp.wg.Done()
+
+	ticker := time.NewTicker(target.interval)
+	defer ticker.Stop()
+
+	hp.probeOnce(ctx, target)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hp.probeOnce(ctx, target)
+		}
+	}
+}
+
+func (hp *HealthProber) probeOnce(ctx context.Context, target probeTarget) {
+	probeCtx, cancel := context.WithTimeout(ctx, target.timeout)
+	defer cancel()
+
+	start := time.Now()
+	var err error
+	switch target.method {
+	case "models":
+		err = hp.probeModels(probeCtx, target.cfg)
+	default:
+		err = probeChat(probeCtx, target.cfg)
+	}
+	elapsed := time.Since(start)
+
+	// Shutting down: don't record a spurious failure.
+	if ctx.Err() != nil {
+		return
+	}
+
+	hp.record(target, start, elapsed, err)
+}
+
+func (hp *HealthProber) record(target probeTarget, at time.Time, elapsed time.Duration, err error) {
+	hp.mu.Lock()
+	status := hp.statuses[target.id]
+	status.LastProbe = &at
+	status.LatencyMs = elapsed.Milliseconds()
+	if err == nil {
+		status.Healthy = true
+		status.LastError = ""
+		status.FailingSince = nil
+	} else {
+		status.Healthy = false
+		status.LastError = err.Error()
+		if status.FailingSince == nil {
+			status.FailingSince = &at
+		}
+	}
+	hp.mu.Unlock()
+
+	if hp.cooldown == nil {
+		return
+	}
+	if err == nil {
+		hp.cooldown.MarkSuccess(target.provider)
+		return
+	}
+
+	reason := FailoverUnknown
+	if failErr := ClassifyError(err, target.provider, target.cfg.Model); failErr != nil {
+		if !failErr.IsRetriable() {
+			// A malformed probe request says nothing about provider health.
+			return
+		}
+		reason = failErr.Reason
+	}
+	hp.cooldown.MarkFailure(target.provider, reason)
+
+	logger.WarnCF("providers", "Health probe failed", map[string]any{
+		"model_name": target.cfg.ModelName,
+		"provider":   target.provider,
+		"reason":     string(reason),
+		"error":      err.Error(),
+	})
+}
+
+// probeModels issues GET {api_base}/models, which OpenAI-compatible endpoints
+// answer without consuming tokens.
+func (hp *HealthProber) probeModels(ctx context.Context, mc config.ModelConfig) error {
+	protocol, _ := ExtractProtocol(mc.Model)
+	apiBase := mc.APIBase
+	if apiBase == "" {
+		apiBase = getDefaultAPIBase(protocol)
+	}
+	if apiBase == "" {
+		return fmt.Errorf("health probe: no api_base for protocol %q", protocol)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(apiBase, "/")+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("health probe: %w", err)
+	}
+	if mc.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+mc.APIKey)
+	}
+
+	resp, err := hp.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("health probe: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("health probe: API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// probeChat sends a minimal one-token completion through the regular provider.
+func probeChat(ctx context.Context, mc config.ModelConfig) error {
+	provider, modelID, err := CreateProviderFromConfig(&mc)
+	if err != nil {
+		return fmt.Errorf("health probe: %w", err)
+	}
+	if sp, ok := provider.(StatefulProvider); ok {
+		defer sp.Close()
+	}
+
+	_, err = provider.Chat(ctx, []Message{{Role: "user", Content: "ping"}}, nil, modelID, map[string]any{
+		"max_tokens":  1,
+		"temperature": 0.0,
+	})
+	return err
+}
+
+// probeProviderKey returns the cooldown key the fallback chain uses for a
+// model_list "model" field, so probe results and live traffic share state.
+func probeProviderKey(model string) string {
+	model = strings.TrimSpace(model)
+	if !strings.Contains(model, "/") {
+		model = "openai/" + model
+	}
+	if ref := ParseModelRef(model, "openai"); ref != nil {
+		return ref.Provider
+	}
+	return "openai"
+}
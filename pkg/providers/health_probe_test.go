@@ -0,0 +1,121 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestHealthProber_OnlyEnabledTargets(t *testing.T) {
+	hp := NewHealthProber([]config.ModelConfig{
+		{ModelName: "a", Model: "openai/gpt-4o"},
+		{ModelName: "b", Model: "openai/gpt-4o", HealthCheck: &config.HealthCheckConfig{Enabled: false}},
+		{ModelName: "c", Model: "groq/llama", HealthCheck: &config.HealthCheckConfig{Enabled: true}},
+	}, NewCooldownTracker())
+
+	status := hp.Status()
+	if len(status) != 1 || status[0].ModelName != "c" {
+		t.Fatalf("expected only model c to be probed, got %+v", status)
+	}
+	if status[0].Provider != "groq" || status[0].Method != "chat" {
+		t.Errorf("unexpected defaults: %+v", status[0])
+	}
+}
+
+func TestHealthProber_BalancedEntriesUseEndpointKeys(t *testing.T) {
+	check := &config.HealthCheckConfig{Enabled: true}
+	hp := NewHealthProber([]config.ModelConfig{
+		{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "k1", HealthCheck: check},
+		{ModelName: "solo", Model: "groq/llama", HealthCheck: check},
+		{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "k2", HealthCheck: check},
+	}, NewCooldownTracker())
+
+	var keys []string
+	for _, status := range hp.Status() {
+		keys = append(keys, status.Provider)
+	}
+	want := []string{"gpt#1", "gpt#2", "groq"}
+	if len(keys) != len(want) || keys[0] != want[0] || keys[1] != want[1] || keys[2] != want[2] {
+		t.Errorf("probe keys = %v, want %v", keys, want)
+	}
+}
+
+func TestHealthProber_ModelsProbeUpdatesCooldown(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("missing auth header")
+		}
+		if healthy {
+			w.Write([]byte(`{"data":[]}`))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":"unavailable"}`))
+	}))
+	defer server.Close()
+
+	cooldown := NewCooldownTracker()
+	hp := NewHealthProber([]config.ModelConfig{{
+		ModelName:   "primary",
+		Model:       "openai/gpt-4o",
+		APIBase:     server.URL,
+		APIKey:      "sk-test",
+		HealthCheck: &config.HealthCheckConfig{Enabled: true, Method: "models"},
+	}}, cooldown)
+	target := hp.targets[0]
+
+	healthy = false
+	hp.probeOnce(context.Background(), target)
+	if cooldown.IsAvailable("openai") {
+		t.Error("failed probe should put provider in cooldown")
+	}
+	status := hp.Status()[0]
+	if status.Healthy || status.FailingSince == nil || status.LastError == "" {
+		t.Errorf("unexpected status after failure: %+v", status)
+	}
+
+	healthy = true
+	hp.probeOnce(context.Background(), target)
+	if !cooldown.IsAvailable("openai") {
+		t.Error("successful probe should clear cooldown")
+	}
+	status = hp.Status()[0]
+	if !status.Healthy || status.FailingSince != nil {
+		t.Errorf("unexpected status after recovery: %+v", status)
+	}
+}
+
+func TestHealthProber_StartStop(t *testing.T) {
+	probed := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case probed <- struct{}{}:
+		default:
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	hp := NewHealthProber([]config.ModelConfig{{
+		ModelName:   "primary",
+		Model:       "vllm/local",
+		APIBase:     server.URL,
+		HealthCheck: &config.HealthCheckConfig{Enabled: true, Method: "models", IntervalSeconds: 3600},
+	}}, NewCooldownTracker())
+
+	hp.Start(context.Background())
+	select {
+	case <-probed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an immediate probe on start")
+	}
+	hp.Stop()
+}
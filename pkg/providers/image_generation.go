@@ -0,0 +1,366 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Image generation modes.
+const (
+	ImageModeGenerate  = "generate"
+	ImageModeEdit      = "edit"
+	ImageModeVariation = "variation"
+)
+
+// maxImageDownloadBytes caps images fetched from URL responses.
+const maxImageDownloadBytes = 32 << 20
+
+// ImageRequest describes a text-to-image, edit or variation request.
+type ImageRequest struct {
+	Mode       string // generate (default), edit or variation
+	Prompt     string
+	InputImage string // local path of the source image for edit/variation
+	Size       string // e.g. "1024x1024"; empty uses the endpoint default
+	Quality    string
+	N          int
+}
+
+// GeneratedImage is one image returned by an ImageGenerator.
+type GeneratedImage struct {
+	Data          []byte
+	MimeType      string
+	RevisedPrompt string
+}
+
+// ImageGenerator produces images from a prompt and optional source image.
+type ImageGenerator interface {
+	GenerateImages(ctx context.Context, req ImageRequest) ([]GeneratedImage, error)
+}
+
+// CreateImageGeneratorFromConfig creates an image generator for a model_list
+// entry. The gemini protocol uses native generateContent with image output;
+// azure and the OpenAI-compatible protocols use the /images API.
+func CreateImageGeneratorFromConfig(cfg *config.ModelConfig) (ImageGenerator, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	protocol, modelID := ExtractProtocol(cfg.Model)
+	timeout := 180 * time.Second
+	if cfg.RequestTimeout > 0 {
+		timeout = time.Duration(cfg.RequestTimeout) * time.Second
+	}
+
+	switch protocol {
+	case "gemini":
+		return &geminiImageGenerator{
+			provider: NewGeminiProvider(cfg.APIKey, cfg.APIBase, cfg.Proxy, int(timeout/time.Second)),
+			model:    modelID,
+		}, nil
+
+	case "azure", "azure-openai":
+		if cfg.APIBase == "" || cfg.APIKey == "" {
+			return nil, fmt.Errorf("api_base and api_key are required for azure image generation")
+		}
+		apiVersion := cfg.APIVersion
+		if apiVersion == "" {
+			apiVersion = azureDefaultAPIVersion
+		}
+		base := strings.TrimRight(cfg.APIBase, "/")
+		return &openAIImageGenerator{
+			endpoint: func(op string) string {
+				return fmt.Sprintf("%s/openai/deployments/%s/images/%s?api-version=%s",
+					base, url.PathEscape(modelID), op, url.QueryEscape(apiVersion))
+			},
+			apiKeyHeader: "api-key",
+			apiKey:       cfg.APIKey,
+			model:        modelID,
+			client:       newImageHTTPClient(cfg.Proxy, timeout),
+		}, nil
+
+	case "anthropic", "bedrock", "aws-bedrock", "antigravity", "claude-cli", "claudecli",
+		"codex-cli", "codexcli", "github-copilot", "copilot":
+		return nil, fmt.Errorf("protocol %q does not support image generation", protocol)
+
+	default:
+		apiBase := cfg.APIBase
+		if apiBase == "" {
+			apiBase = getDefaultAPIBase(protocol)
+		}
+		if apiBase == "" {
+			return nil, fmt.Errorf("api_base is required for image generation with protocol %q", protocol)
+		}
+		base := strings.TrimRight(apiBase, "/")
+		return &openAIImageGenerator{
+			endpoint: func(op string) string { return base + "/images/" + op },
+			apiKey:   cfg.APIKey,
+			model:    modelID,
+			client:   newImageHTTPClient(cfg.Proxy, timeout),
+		}, nil
+	}
+}
+
+func newImageHTTPClient(proxy string, timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if proxy != "" {
+		if parsed, err := url.Parse(proxy); err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(parsed)}
+		}
+	}
+	return client
+}
+
+// --- OpenAI-compatible /images API ---
+
+type openAIImageGenerator struct {
+	endpoint     func(op string) string // op: generations, edits, variations
+	apiKeyHeader string                 // empty: Authorization Bearer
+	apiKey       string
+	model        string
+	client       *http.Client
+}
+
+func (g *openAIImageGenerator) GenerateImages(ctx context.Context, req ImageRequest) ([]GeneratedImage, error) {
+	var httpReq *http.Request
+	var err error
+
+	switch req.Mode {
+	case "", ImageModeGenerate:
+		body := map[string]any{"prompt": req.Prompt}
+		if g.model != "" {
+			body["model"] = g.model
+		}
+		if req.Size != "" {
+			body["size"] = req.Size
+		}
+		if req.Quality != "" {
+			body["quality"] = req.Quality
+		}
+		if req.N > 0 {
+			body["n"] = req.N
+		}
+		data, _ := json.Marshal(body)
+		httpReq, err = http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint("generations"), bytes.NewReader(data))
+		if err == nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+
+	case ImageModeEdit, ImageModeVariation:
+		op := "edits"
+		if req.Mode == ImageModeVariation {
+			op = "variations"
+		}
+		httpReq, err = g.multipartRequest(ctx, op, req)
+
+	default:
+		return nil, fmt.Errorf("unknown image mode %q", req.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if g.apiKey != "" {
+		if g.apiKeyHeader != "" {
+			httpReq.Header.Set(g.apiKeyHeader, g.apiKey)
+		} else {
+			httpReq.Header.Set("Authorization", "Bearer "+g.apiKey)
+		}
+	}
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("image API call: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxImageDownloadBytes*4))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image API request failed:\n  Status: %d\n  Body:   %s",
+			resp.StatusCode, truncateString(string(respBody), 500))
+	}
+
+	var apiResp struct {
+		Data []struct {
+			B64JSON       string `json:"b64_json"`
+			URL           string `json:"url"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("decoding image response: %w", err)
+	}
+
+	images := make([]GeneratedImage, 0, len(apiResp.Data))
+	for _, item := range apiResp.Data {
+		var data []byte
+		switch {
+		case item.B64JSON != "":
+			data, err = base64.StdEncoding.DecodeString(item.B64JSON)
+			if err != nil {
+				return nil, fmt.Errorf("decoding image data: %w", err)
+			}
+		case item.URL != "":
+			data, err = g.download(ctx, item.URL)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+		images = append(images, GeneratedImage{
+			Data:          data,
+			MimeType:      http.DetectContentType(data),
+			RevisedPrompt: item.RevisedPrompt,
+		})
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("image API returned no images")
+	}
+	return images, nil
+}
+
+func (g *openAIImageGenerator) multipartRequest(ctx context.Context, op string, req ImageRequest) (*http.Request, error) {
+	if req.InputImage == "" {
+		return nil, fmt.Errorf("an input image is required for %s", op)
+	}
+	img, err := os.ReadFile(req.InputImage)
+	if err != nil {
+		return nil, fmt.Errorf("reading input image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if g.model != "" {
+		w.WriteField("model", g.model)
+	}
+	if op == "edits" {
+		w.WriteField("prompt", req.Prompt)
+	}
+	if req.Size != "" {
+		w.WriteField("size", req.Size)
+	}
+	if req.N > 0 {
+		w.WriteField("n", strconv.Itoa(req.N))
+	}
+	part, err := w.CreateFormFile("image", filepath.Base(req.InputImage))
+	if err != nil {
+		return nil, err
+	}
+	part.Write(img)
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint(op), &buf)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", w.FormDataContentType())
+	return httpReq, nil
+}
+
+func (g *openAIImageGenerator) download(ctx context.Context, imageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading image: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageDownloadBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("downloading image: %w", err)
+	}
+	if len(data) > maxImageDownloadBytes {
+		return nil, fmt.Errorf("downloading image: exceeds %d bytes", maxImageDownloadBytes)
+	}
+	return data, nil
+}
+
+// --- Gemini native image output ---
+
+type geminiImageGenerator struct {
+	provider *GeminiProvider
+	model    string
+}
+
+func (g *geminiImageGenerator) GenerateImages(ctx context.Context, req ImageRequest) ([]GeneratedImage, error) {
+	prompt := req.Prompt
+	msg := Message{Role: "user"}
+	switch req.Mode {
+	case "", ImageModeGenerate:
+	case ImageModeEdit, ImageModeVariation:
+		if req.InputImage == "" {
+			return nil, fmt.Errorf("an input image is required for %s", req.Mode)
+		}
+		msg.Media = []string{req.InputImage}
+		if req.Mode == ImageModeVariation && prompt == "" {
+			prompt = "Create a variation of this image."
+		}
+	default:
+		return nil, fmt.Errorf("unknown image mode %q", req.Mode)
+	}
+	if req.Size != "" {
+		prompt += fmt.Sprintf("\n\nTarget size: %s.", req.Size)
+	}
+	msg.Content = prompt
+
+	n := req.N
+	if n <= 0 {
+		n = 1
+	}
+
+	// generateContent returns one candidate per call, so each image is a request.
+	var images []GeneratedImage
+	for range n {
+		body := buildGeminiRequest([]Message{msg}, nil, nil)
+		body.Config = &antigravityGenConfig{ResponseModalities: []string{"TEXT", "IMAGE"}}
+
+		resp, err := g.provider.generateContent(ctx, g.model, body)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range resp.Candidates {
+			for _, part := range candidate.Content.Parts {
+				if part.InlineData == nil || !strings.HasPrefix(part.InlineData.MimeType, "image/") {
+					continue
+				}
+				data, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+				if err != nil {
+					return nil, fmt.Errorf("decoding image data: %w", err)
+				}
+				images = append(images, GeneratedImage{Data: data, MimeType: part.InlineData.MimeType})
+			}
+		}
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("gemini returned no images")
+	}
+	return images, nil
+}
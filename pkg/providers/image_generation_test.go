@@ -0,0 +1,95 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestOpenAIImageGenerator_DownloadsURLResults(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/images/generations":
+			if r.Header.Get("Authorization") != "Bearer k" {
+				t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": []map[string]any{{"url": server.URL + "/files/img.png"}},
+			})
+		case "/files/img.png":
+			w.Write([]byte("\x89PNG\r\n\x1a\nrest"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	gen, err := CreateImageGeneratorFromConfig(&config.ModelConfig{
+		ModelName: "dalle", Model: "openai/dall-e-3", APIBase: server.URL + "/v1", APIKey: "k",
+	})
+	if err != nil {
+		t.Fatalf("CreateImageGeneratorFromConfig() error = %v", err)
+	}
+	images, err := gen.GenerateImages(context.Background(), ImageRequest{Prompt: "a boat"})
+	if err != nil {
+		t.Fatalf("GenerateImages() error = %v", err)
+	}
+	if len(images) != 1 || images[0].MimeType != "image/png" {
+		t.Errorf("images = %+v", images)
+	}
+}
+
+func TestGeminiImageGenerator_InlineDataOutput(t *testing.T) {
+	var gotBody map[string]any
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"candidates": [{"content": {"parts": [
+			{"text": "Here is your image"},
+			{"inlineData": {"mimeType": "image/png", "data": "` +
+			base64.StdEncoding.EncodeToString([]byte("png-bytes")) + `"}}
+		]}}]}`))
+	}))
+	defer server.Close()
+
+	gen, err := CreateImageGeneratorFromConfig(&config.ModelConfig{
+		ModelName: "nano", Model: "gemini/gemini-2.5-flash-image", APIBase: server.URL, APIKey: "k",
+	})
+	if err != nil {
+		t.Fatalf("CreateImageGeneratorFromConfig() error = %v", err)
+	}
+	images, err := gen.GenerateImages(context.Background(), ImageRequest{Prompt: "a boat"})
+	if err != nil {
+		t.Fatalf("GenerateImages() error = %v", err)
+	}
+	if gotPath != "/models/gemini-2.5-flash-image:generateContent" {
+		t.Errorf("path = %q", gotPath)
+	}
+	cfg := gotBody["generationConfig"].(map[string]any)
+	if mods := cfg["responseModalities"].([]any); len(mods) != 2 || mods[1] != "IMAGE" {
+		t.Errorf("responseModalities = %v", mods)
+	}
+	if len(images) != 1 || string(images[0].Data) != "png-bytes" || images[0].MimeType != "image/png" {
+		t.Errorf("images = %+v", images)
+	}
+}
+
+func TestCreateImageGeneratorFromConfig_UnsupportedProtocol(t *testing.T) {
+	if _, err := CreateImageGeneratorFromConfig(&config.ModelConfig{
+		ModelName: "claude", Model: "anthropic/claude-sonnet-4.6", APIKey: "k",
+	}); err == nil {
+		t.Error("expected error for anthropic")
+	}
+}
@@ -7,6 +7,7 @@ package providers
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 )
@@ -40,22 +41,78 @@ func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
 		return nil, "", fmt.Errorf("no providers configured. Please add entries to model_list in your config")
 	}
 
-	// Get model config from model_list
-	modelCfg, err := cfg.GetModelConfig(model)
-	if err != nil {
-		return nil, "", fmt.Errorf("model %q not found in model_list: %w", model, err)
+	// Several entries with the same model_name: balance per request across
+	// them. This covers fallbacks and per-agent models too, since every
+	// request goes through the provider returned here.
+	var balancers []*LoadBalancedProvider
+	var primary *LoadBalancedProvider
+	for _, alias := range balancedAliases(cfg.ModelList) {
+		matches := cfg.GetModelConfigs(alias)
+		for i := range matches {
+			if matches[i].Workspace == "" {
+				matches[i].Workspace = cfg.WorkspacePath()
+			}
+		}
+		lb, err := NewLoadBalancedProvider(alias, matches)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create load balancer for model %q: %w", alias, err)
+		}
+		balancers = append(balancers, lb)
+		if alias == model {
+			primary = lb
+		}
 	}
 
-	// Inject global workspace if not set in model config
-	if modelCfg.Workspace == "" {
-		modelCfg.Workspace = cfg.WorkspacePath()
+	var provider LLMProvider
+	var modelID string
+	if primary != nil {
+		provider, modelID = primary, primary.GetDefaultModel()
+	} else {
+		// Get model config from model_list
+		modelCfg, err := cfg.GetModelConfig(model)
+		if err != nil {
+			return nil, "", fmt.Errorf("model %q not found in model_list: %w", model, err)
+		}
+
+		// Inject global workspace if not set in model config
+		if modelCfg.Workspace == "" {
+			modelCfg.Workspace = cfg.WorkspacePath()
+		}
+
+		// Use factory to create provider
+		provider, modelID, err = CreateProviderFromConfig(modelCfg)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create provider for model %q: %w", model, err)
+		}
 	}
 
-	// Use factory to create provider
-	provider, modelID, err := CreateProviderFromConfig(modelCfg)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create provider for model %q: %w", model, err)
+	if len(balancers) == 0 || (len(balancers) == 1 && primary != nil) {
+		return provider, modelID, nil
+	}
+	// The default model wins when several aliases serve the same model ID.
+	if primary != nil {
+		balancers = slices.DeleteFunc(balancers, func(lb *LoadBalancedProvider) bool { return lb == primary })
+		balancers = append([]*LoadBalancedProvider{primary}, balancers...)
 	}
+	return &balancedRouter{primary: provider, balancers: balancers}, modelID, nil
+}
 
-	return provider, modelID, nil
+// balancedAliases returns the model_name values that appear on more than one
+// model_list entry, in order of first appearance.
+func balancedAliases(modelList []config.ModelConfig) []string {
+	counts := make(map[string]int)
+	var order []string
+	for _, mc := range modelList {
+		if counts[mc.ModelName] == 0 {
+			order = append(order, mc.ModelName)
+		}
+		counts[mc.ModelName]++
+	}
+	var aliases []string
+	for _, alias := range order {
+		if counts[alias] > 1 {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
 }
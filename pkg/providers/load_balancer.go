@@ -0,0 +1,337 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Load balancing strategies for model_list entries sharing a model_name.
+const (
+	LoadBalanceRoundRobin   = "round_robin"
+	LoadBalanceWeighted     = "weighted"
+	LoadBalanceLeastLatency = "least_latency"
+)
+
+// latencyEWMAAlpha weights the newest latency sample in the moving average.
+const latencyEWMAAlpha = 0.3
+
+// CooldownAware is implemented by providers that keep per-endpoint cooldown
+// state and can share the agent loop's tracker instead of a private one.
+type CooldownAware interface {
+	SetCooldownTracker(ct *CooldownTracker)
+}
+
+// LoadBalancedProvider spreads requests for one model alias across several
+// endpoints (API keys and/or API bases). Every endpoint has its own cooldown
+// entry; retriable failures move on to the next endpoint of the same alias
+// before the error is returned to the fallback chain.
+type LoadBalancedProvider struct {
+	alias     string
+	strategy  string
+	endpoints []*lbEndpoint
+	rr        atomic.Uint64
+
+	mu       sync.Mutex // guards endpoint weights/latency and cooldown swap
+	cooldown *CooldownTracker
+}
+
+type lbEndpoint struct {
+	key      string // cooldown key, e.g. "gpt-5.2#2"
+	provider LLMProvider
+	modelID  string
+	weight   int
+
+	currentWeight int           // smooth weighted round-robin state
+	latency       time.Duration // EWMA of successful request latency
+}
+
+// NewLoadBalancedProvider builds a balancer for all model_list entries in cfgs,
+// which must share the same model_name. The strategy is taken from the first
+// entry that sets load_balance and defaults to round-robin.
+func NewLoadBalancedProvider(alias string, cfgs []config.ModelConfig) (*LoadBalancedProvider, error) {
+	if len(cfgs) == 0 {
+		return nil, fmt.Errorf("load balancer: no endpoints for model %q", alias)
+	}
+
+	lb := &LoadBalancedProvider{
+		alias:    alias,
+		strategy: LoadBalanceRoundRobin,
+		cooldown: NewCooldownTracker(),
+	}
+
+	for i := range cfgs {
+		mc := cfgs[i]
+		if s := strings.ToLower(strings.TrimSpace(mc.LoadBalance)); s != "" && lb.strategy == LoadBalanceRoundRobin {
+			lb.strategy = s
+		}
+
+		provider, modelID, err := CreateProviderFromConfig(&mc)
+		if err != nil {
+			return nil, fmt.Errorf("load balancer: endpoint %d of %q: %w", i+1, alias, err)
+		}
+		weight := mc.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		lb.endpoints = append(lb.endpoints, &lbEndpoint{
+			key:      EndpointKey(alias, i+1),
+			provider: provider,
+			modelID:  modelID,
+			weight:   weight,
+		})
+	}
+
+	switch lb.strategy {
+	case LoadBalanceRoundRobin, LoadBalanceWeighted, LoadBalanceLeastLatency:
+	default:
+		return nil, fmt.Errorf("load balancer: unknown strategy %q for model %q", lb.strategy, alias)
+	}
+
+	return lb, nil
+}
+
+// EndpointKey returns the cooldown key of the n-th (1-based) model_list entry
+// of a load-balanced alias. Balancers and health probes share it, so a probe
+// result takes effect on the endpoint it probed.
+func EndpointKey(alias string, n int) string {
+	return fmt.Sprintf("%s#%d", alias, n)
+}
+
+// SetCooldownTracker replaces the balancer's private tracker, so endpoint
+// cooldowns are persisted and reported together with the fallback chain's.
+func (lb *LoadBalancedProvider) SetCooldownTracker(ct *CooldownTracker) {
+	if ct == nil {
+		return
+	}
+	lb.mu.Lock()
+	lb.cooldown = ct
+	lb.mu.Unlock()
+}
+
+// Chat sends the request to the best available endpoint and fails over to the
+// remaining endpoints of the alias on retriable errors. Requests for a model
+// that none of the endpoints serve (e.g. a fallback candidate) are passed to
+// the first endpoint unchanged, matching single-provider behavior.
+func (lb *LoadBalancedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	if !lb.serves(model) {
+		return lb.endpoints[0].provider.Chat(ctx, messages, tools, model, options)
+	}
+
+	lb.mu.Lock()
+	cooldown := lb.cooldown
+	lb.mu.Unlock()
+
+	var lastErr *FailoverError
+	for _, ep := range lb.order(cooldown) {
+		// Past the deadline every endpoint would fail, through no fault of its own.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		start := time.Now()
+		resp, err := ep.provider.Chat(ctx, messages, tools, ep.modelID, options)
+		elapsed := time.Since(start)
+
+		if err == nil {
+			cooldown.MarkSuccess(ep.key)
+			lb.recordLatency(ep, elapsed)
+			return resp, nil
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		failErr := ClassifyError(err, ep.key, ep.modelID)
+		if failErr == nil || !failErr.IsRetriable() {
+			// Not an endpoint problem: the other endpoints would fail the same way.
+			return nil, err
+		}
+
+		cooldown.MarkFailure(ep.key, failErr.Reason)
+		lastErr = failErr
+		logger.WarnCF("providers", "Load balancer endpoint failed, trying next", map[string]any{
+			"model":    lb.alias,
+			"endpoint": ep.key,
+			"reason":   string(failErr.Reason),
+			"error":    err.Error(),
+		})
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, &FailoverError{
+		Reason:   FailoverRateLimit,
+		Provider: lb.alias,
+		Model:    model,
+		Wrapped:  fmt.Errorf("all %d endpoints of %q are in cooldown", len(lb.endpoints), lb.alias),
+	}
+}
+
+// GetDefaultModel returns the model ID of the first endpoint.
+func (lb *LoadBalancedProvider) GetDefaultModel() string {
+	return lb.endpoints[0].modelID
+}
+
+// Close releases endpoints that hold long-lived resources.
+func (lb *LoadBalancedProvider) Close() {
+	for _, ep := range lb.endpoints {
+		if sp, ok := ep.provider.(StatefulProvider); ok {
+			sp.Close()
+		}
+	}
+}
+
+// serves reports whether model refers to this balancer's alias.
+func (lb *LoadBalancedProvider) serves(model string) bool {
+	if model == "" || model == lb.alias {
+		return true
+	}
+	for _, ep := range lb.endpoints {
+		if ep.modelID == model {
+			return true
+		}
+	}
+	return false
+}
+
+// order returns the available endpoints in the order they should be tried.
+func (lb *LoadBalancedProvider) order(cooldown *CooldownTracker) []*lbEndpoint {
+	available := make([]*lbEndpoint, 0, len(lb.endpoints))
+	for _, ep := range lb.endpoints {
+		if cooldown.IsAvailable(ep.key) {
+			available = append(available, ep)
+		}
+	}
+	if len(available) <= 1 {
+		return available
+	}
+
+	switch lb.strategy {
+	case LoadBalanceWeighted:
+		return lb.orderWeighted(available)
+	case LoadBalanceLeastLatency:
+		return lb.orderLeastLatency(available)
+	default:
+		start := int((lb.rr.Add(1) - 1) % uint64(len(available)))
+		return append(available[start:], available[:start]...)
+	}
+}
+
+// orderWeighted picks the first endpoint with smooth weighted round-robin
+// (as in nginx) and orders the rest by weight for failover.
+func (lb *LoadBalancedProvider) orderWeighted(available []*lbEndpoint) []*lbEndpoint {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	total := 0
+	var best *lbEndpoint
+	for _, ep := range available {
+		ep.currentWeight += ep.weight
+		total += ep.weight
+		if best == nil || ep.currentWeight > best.currentWeight {
+			best = ep
+		}
+	}
+	best.currentWeight -= total
+
+	ordered := make([]*lbEndpoint, 0, len(available))
+	ordered = append(ordered, best)
+	rest := make([]*lbEndpoint, 0, len(available)-1)
+	for _, ep := range available {
+		if ep != best {
+			rest = append(rest, ep)
+		}
+	}
+	sort.SliceStable(rest, func(i, j int) bool { return rest[i].weight > rest[j].weight })
+	return append(ordered, rest...)
+}
+
+// orderLeastLatency sorts endpoints by observed latency; endpoints without
+// samples yet sort first so every endpoint gets measured.
+func (lb *LoadBalancedProvider) orderLeastLatency(available []*lbEndpoint) []*lbEndpoint {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	ordered := append([]*lbEndpoint(nil), available...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].latency < ordered[j].latency })
+	return ordered
+}
+
+func (lb *LoadBalancedProvider) recordLatency(ep *lbEndpoint, elapsed time.Duration) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if ep.latency == 0 {
+		ep.latency = elapsed
+		return
+	}
+	ep.latency = time.Duration(latencyEWMAAlpha*float64(elapsed) + (1-latencyEWMAAlpha)*float64(ep.latency))
+}
+
+// balancedRouter sends requests for a model served by one of its balancers
+// to that balancer, and everything else to the primary provider. It lets
+// fallback candidates and per-agent models that have several model_list
+// entries be balanced like the default model.
+type balancedRouter struct {
+	primary   LLMProvider
+	balancers []*LoadBalancedProvider // in model_list order; may include primary
+}
+
+func (r *balancedRouter) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	if model != "" {
+		for _, lb := range r.balancers {
+			if lb.serves(model) {
+				return lb.Chat(ctx, messages, tools, model, options)
+			}
+		}
+	}
+	return r.primary.Chat(ctx, messages, tools, model, options)
+}
+
+func (r *balancedRouter) GetDefaultModel() string {
+	return r.primary.GetDefaultModel()
+}
+
+func (r *balancedRouter) SetCooldownTracker(ct *CooldownTracker) {
+	for _, lb := range r.balancers {
+		lb.SetCooldownTracker(ct)
+	}
+}
+
+func (r *balancedRouter) Close() {
+	primaryClosed := false
+	for _, lb := range r.balancers {
+		lb.Close()
+		primaryClosed = primaryClosed || LLMProvider(lb) == r.primary
+	}
+	if sp, ok := r.primary.(StatefulProvider); ok && !primaryClosed {
+		sp.Close()
+	}
+}
@@ -0,0 +1,279 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// lbTestServer is an OpenAI-compatible endpoint that answers with its own name
+// or with the configured HTTP status.
+type lbTestServer struct {
+	*httptest.Server
+	status atomic.Int32
+	hits   atomic.Int32
+	stall  atomic.Bool // hold requests until the client gives up
+}
+
+func newLBTestServer(t *testing.T, name string) *lbTestServer {
+	t.Helper()
+	s := &lbTestServer{}
+	s.status.Store(http.StatusOK)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if s.stall.Load() {
+			// The server only notices the client going away once the body is read.
+			io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+			return
+		}
+		if code := int(s.status.Load()); code != http.StatusOK {
+			w.WriteHeader(code)
+			w.Write([]byte(`{"error":{"message":"endpoint failure"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{
+				"message":       map[string]any{"content": name},
+				"finish_reason": "stop",
+			}},
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestBalancer(t *testing.T, strategy string, servers []*lbTestServer, weights []int) *LoadBalancedProvider {
+	t.Helper()
+	cfgs := make([]config.ModelConfig, len(servers))
+	for i, s := range servers {
+		cfgs[i] = config.ModelConfig{
+			ModelName:   "gpt",
+			Model:       "openai/gpt-test",
+			APIBase:     s.URL,
+			APIKey:      "sk-test",
+			LoadBalance: strategy,
+		}
+		if weights != nil {
+			cfgs[i].Weight = weights[i]
+		}
+	}
+	lb, err := NewLoadBalancedProvider("gpt", cfgs)
+	if err != nil {
+		t.Fatalf("NewLoadBalancedProvider() error = %v", err)
+	}
+	return lb
+}
+
+func chatContent(t *testing.T, lb *LoadBalancedProvider) string {
+	t.Helper()
+	resp, err := lb.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-test", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	return resp.Content
+}
+
+func TestLoadBalancer_RoundRobin(t *testing.T) {
+	a, b := newLBTestServer(t, "a"), newLBTestServer(t, "b")
+	lb := newTestBalancer(t, "", []*lbTestServer{a, b}, nil)
+
+	got := []string{chatContent(t, lb), chatContent(t, lb), chatContent(t, lb), chatContent(t, lb)}
+	want := []string{"a", "b", "a", "b"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("round robin sequence = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLoadBalancer_Weighted(t *testing.T) {
+	a, b := newLBTestServer(t, "a"), newLBTestServer(t, "b")
+	lb := newTestBalancer(t, LoadBalanceWeighted, []*lbTestServer{a, b}, []int{3, 1})
+
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		counts[chatContent(t, lb)]++
+	}
+	if counts["a"] != 6 || counts["b"] != 2 {
+		t.Errorf("weighted distribution = %v, want a:6 b:2", counts)
+	}
+}
+
+func TestLoadBalancer_LeastLatencyPrefersMeasuredFastest(t *testing.T) {
+	a, b := newLBTestServer(t, "a"), newLBTestServer(t, "b")
+	lb := newTestBalancer(t, LoadBalanceLeastLatency, []*lbTestServer{a, b}, nil)
+	lb.endpoints[0].latency = 500_000_000
+	lb.endpoints[1].latency = 1_000_000
+
+	for i := 0; i < 3; i++ {
+		if got := chatContent(t, lb); got != "b" {
+			t.Fatalf("least latency picked %q, want b", got)
+		}
+	}
+}
+
+func TestLoadBalancer_FailsOverAndCoolsDownDeadEndpoint(t *testing.T) {
+	a, b := newLBTestServer(t, "a"), newLBTestServer(t, "b")
+	a.status.Store(http.StatusUnauthorized) // revoked key
+	lb := newTestBalancer(t, "", []*lbTestServer{a, b}, nil)
+	cooldown := NewCooldownTracker()
+	lb.SetCooldownTracker(cooldown)
+
+	for i := 0; i < 4; i++ {
+		if got := chatContent(t, lb); got != "b" {
+			t.Fatalf("request %d served by %q, want b", i, got)
+		}
+	}
+	if a.hits.Load() != 1 {
+		t.Errorf("dead endpoint hit %d times, want 1 (then cooled down)", a.hits.Load())
+	}
+	if cooldown.IsAvailable("gpt#1") {
+		t.Error("dead endpoint should be in cooldown")
+	}
+	if !cooldown.IsAvailable("gpt#2") {
+		t.Error("healthy endpoint should be available")
+	}
+}
+
+func TestLoadBalancer_AllEndpointsFailReturnsClassifiedError(t *testing.T) {
+	a, b := newLBTestServer(t, "a"), newLBTestServer(t, "b")
+	a.status.Store(http.StatusTooManyRequests)
+	b.status.Store(http.StatusTooManyRequests)
+	lb := newTestBalancer(t, "", []*lbTestServer{a, b}, nil)
+
+	_, err := lb.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-test", nil)
+	if err == nil {
+		t.Fatal("expected error when all endpoints fail")
+	}
+	failErr := ClassifyError(err, "openai", "gpt-test")
+	if failErr == nil || failErr.Reason != FailoverRateLimit {
+		t.Fatalf("ClassifyError() = %v, want rate_limit so the fallback chain moves on", failErr)
+	}
+
+	// Second call: everything is cooling down, endpoints are not contacted.
+	hits := a.hits.Load() + b.hits.Load()
+	_, err = lb.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-test", nil)
+	if err == nil || a.hits.Load()+b.hits.Load() != hits {
+		t.Errorf("expected cooldown error without contacting endpoints, err=%v", err)
+	}
+	if ClassifyError(err, "openai", "gpt-test") == nil {
+		t.Error("cooldown error should be classifiable for fallback")
+	}
+}
+
+func TestLoadBalancer_DeadlineDoesNotCoolDownEndpoints(t *testing.T) {
+	a, b := newLBTestServer(t, "a"), newLBTestServer(t, "b")
+	a.stall.Store(true)
+	lb := newTestBalancer(t, "", []*lbTestServer{a, b}, nil)
+	cooldown := NewCooldownTracker()
+	lb.SetCooldownTracker(cooldown)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := lb.Chat(ctx, []Message{{Role: "user", Content: "hi"}}, nil, "gpt-test", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Chat() error = %v, want context.DeadlineExceeded", err)
+	}
+	if b.hits.Load() != 0 {
+		t.Error("no endpoint should be tried after the deadline")
+	}
+	if !cooldown.IsAvailable("gpt#1") {
+		t.Error("an endpoint should not be cooled down for the caller's deadline")
+	}
+}
+
+func TestLoadBalancer_FormatErrorDoesNotFailOver(t *testing.T) {
+	a, b := newLBTestServer(t, "a"), newLBTestServer(t, "b")
+	a.status.Store(http.StatusBadRequest)
+	b.status.Store(http.StatusBadRequest)
+	lb := newTestBalancer(t, "", []*lbTestServer{a, b}, nil)
+
+	if _, err := lb.Chat(context.Background(), nil, nil, "gpt-test", nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if a.hits.Load()+b.hits.Load() != 1 {
+		t.Errorf("bad request should not be retried on other endpoints, hits=%d", a.hits.Load()+b.hits.Load())
+	}
+}
+
+func TestLoadBalancer_UnknownStrategy(t *testing.T) {
+	_, err := NewLoadBalancedProvider("gpt", []config.ModelConfig{
+		{ModelName: "gpt", Model: "openai/gpt", APIKey: "k", LoadBalance: "random"},
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
+
+func TestCreateProvider_DuplicateModelNamesBuildBalancer(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.ModelName = "gpt"
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "gpt", Model: "openai/gpt-test", APIKey: "k1"},
+		{ModelName: "gpt", Model: "openai/gpt-test", APIKey: "k2"},
+	}
+
+	provider, modelID, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	if _, ok := provider.(*LoadBalancedProvider); !ok {
+		t.Fatalf("provider = %T, want *LoadBalancedProvider", provider)
+	}
+	if modelID != "gpt-test" {
+		t.Errorf("modelID = %q, want gpt-test", modelID)
+	}
+}
+
+func TestCreateProvider_BalancesEveryAlias(t *testing.T) {
+	main := newLBTestServer(t, "main")
+	backupA := newLBTestServer(t, "backup-a")
+	backupB := newLBTestServer(t, "backup-b")
+
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.ModelName = "main"
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "backup", Model: "openai/backup-model", APIBase: backupA.URL, APIKey: "k1"},
+		{ModelName: "main", Model: "openai/main-model", APIBase: main.URL, APIKey: "k0"},
+		{ModelName: "backup", Model: "openai/backup-model", APIBase: backupB.URL, APIKey: "k2"},
+	}
+
+	provider, modelID, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatalf("CreateProvider() error = %v", err)
+	}
+	if modelID != "main-model" {
+		t.Errorf("modelID = %q, want main-model", modelID)
+	}
+
+	chat := func(model string) string {
+		t.Helper()
+		resp, err := provider.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, model, nil)
+		if err != nil {
+			t.Fatalf("Chat(%q) error = %v", model, err)
+		}
+		return resp.Content
+	}
+
+	if got := chat("main-model"); got != "main" {
+		t.Errorf("default model answered by %q", got)
+	}
+	// A fallback candidate resolves to the alias' model ID and is balanced.
+	seen := map[string]bool{chat("backup-model"): true, chat("backup-model"): true}
+	if !seen["backup-a"] || !seen["backup-b"] {
+		t.Errorf("fallback alias answered by %v, want both endpoints", seen)
+	}
+
+	if _, ok := provider.(CooldownAware); !ok {
+		t.Error("router does not accept the shared cooldown tracker")
+	}
+}
@@ -32,6 +32,8 @@ type Provider struct {
 	apiKey         string
 	apiBase        string
 	maxTokensField string // Field name for max tokens (e.g., "max_completion_tokens" for o1/glm models)
+	apiKeyHeader   string // Header carrying the raw API key instead of "Authorization: Bearer"
+	chatURL        func(model string) string
 	httpClient     *http.Client
 }
 
@@ -53,6 +55,22 @@ func WithRequestTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithAPIKeyHeader sends the API key verbatim in the named header
+// (e.g. Azure's "api-key") instead of as a Bearer token.
+func WithAPIKeyHeader(header string) Option {
+	return func(p *Provider) {
+		p.apiKeyHeader = header
+	}
+}
+
+// WithChatURL overrides the chat completions URL, for endpoints that route by
+// model in the path (e.g. Azure deployments).
+func WithChatURL(chatURL func(model string) string) Option {
+	return func(p *Provider) {
+		p.chatURL = chatURL
+	}
+}
+
 func NewProvider(apiKey, apiBase, proxy string, opts ...Option) *Provider {
 	client := &http.Client{
 		Timeout: defaultRequestTimeout,
@@ -167,14 +185,23 @@ func (p *Provider) Chat(
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+"/chat/completions", bytes.NewReader(jsonData))
+	chatURL := p.apiBase + "/chat/completions"
+	if p.chatURL != nil {
+		chatURL = p.chatURL(model)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", chatURL, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	if p.apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		if p.apiKeyHeader != "" {
+			req.Header.Set(p.apiKeyHeader, p.apiKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		}
 	}
 
 	resp, err := p.httpClient.Do(req)
@@ -69,6 +69,7 @@ type Message struct {
 	SystemParts      []ContentBlock `json:"system_parts,omitempty"` // structured system blocks for cache-aware adapters
 	ToolCalls        []ToolCall     `json:"tool_calls,omitempty"`
 	ToolCallID       string         `json:"tool_call_id,omitempty"`
+	Media            []string       `json:"media,omitempty"` // local paths or data: URLs attached to a user message
 }
 
 type ToolDefinition struct {
@@ -0,0 +1,45 @@
+package providers
+
+import (
+	"context"
+	"sync"
+)
+
+// UsageCounter sums the token usage of the LLM calls made on behalf of one
+// request, e.g. a scheduled job run.
+type UsageCounter struct {
+	mu               sync.Mutex
+	promptTokens     int
+	completionTokens int
+}
+
+type usageCounterKey struct{}
+
+// WithUsageCounter returns a context whose LLM calls are counted in the
+// returned counter.
+func WithUsageCounter(ctx context.Context) (context.Context, *UsageCounter) {
+	c := &UsageCounter{}
+	return context.WithValue(ctx, usageCounterKey{}, c), c
+}
+
+// RecordUsage adds usage to the counter attached to ctx, if any.
+func RecordUsage(ctx context.Context, usage *UsageInfo) {
+	if usage == nil {
+		return
+	}
+	c, ok := ctx.Value(usageCounterKey{}).(*UsageCounter)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.promptTokens += usage.PromptTokens
+	c.completionTokens += usage.CompletionTokens
+}
+
+// Tokens returns the prompt and completion tokens counted so far.
+func (c *UsageCounter) Tokens() (prompt, completion int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.promptTokens, c.completionTokens
+}
@@ -0,0 +1,20 @@
+package providers
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUsageCounter(t *testing.T) {
+	RecordUsage(context.Background(), &UsageInfo{PromptTokens: 5}) // no counter: ignored
+
+	ctx, counter := WithUsageCounter(context.Background())
+	RecordUsage(ctx, &UsageInfo{PromptTokens: 10, CompletionTokens: 2})
+	RecordUsage(ctx, nil)
+	RecordUsage(ctx, &UsageInfo{PromptTokens: 7, CompletionTokens: 1})
+
+	prompt, completion := counter.Tokens()
+	if prompt != 17 || completion != 3 {
+		t.Errorf("Tokens() = %d, %d; want 17, 3", prompt, completion)
+	}
+}
@@ -0,0 +1,111 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package sandbox runs shell commands in an isolated environment. On Linux it
+// uses user, mount and PID namespaces, a read-only view of the system with a
+// writable workspace, no_new_privs, a seccomp allowlist and rlimits.
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Config describes the sandbox for one command.
+type Config struct {
+	// Workspace is bind-mounted writable; everything else is read-only or absent.
+	Workspace string
+	// ReadOnlyPaths are extra host paths made visible read-only.
+	ReadOnlyPaths []string
+	// WritablePaths are extra host paths made visible read-write.
+	WritablePaths []string
+	// DenyNetwork runs the command in an empty network namespace.
+	DenyNetwork bool
+	// CPUSeconds, MemoryMB and MaxProcesses set rlimits; 0 means unlimited.
+	// MaxProcesses counts all processes of the host user, including those
+	// outside the sandbox.
+	CPUSeconds   int
+	MemoryMB     int
+	MaxProcesses int
+}
+
+// Exit codes used by the sandbox init process to report why it stopped.
+const (
+	// exitSetupFailed means the sandbox could not be set up.
+	exitSetupFailed = 125
+	// exitSignalBase is added to the signal number that killed the command.
+	exitSignalBase = 128
+	sigXCPU        = 24
+	sigSYS         = 31
+)
+
+// setupErrorPrefix marks sandbox setup errors on stderr.
+const setupErrorPrefix = "picoclaw-sandbox: "
+
+// ErrUnsupported is returned on platforms without sandbox support.
+var ErrUnsupported = errors.New("sandbox is only supported on Linux")
+
+// Violation describes a command that was stopped or hindered by the sandbox.
+type Violation struct {
+	// Kind is one of setup, seccomp, cpu_limit, memory_limit, process_limit,
+	// read_only_filesystem, network or permission.
+	Kind   string
+	Detail string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("sandbox violation (%s): %s", v.Kind, v.Detail)
+}
+
+// Classify inspects a failed sandboxed command and reports the sandbox rule
+// it ran into, or nil when the failure looks unrelated to the sandbox.
+func Classify(runErr error, stderr string, cfg Config) *Violation {
+	var exitErr *exec.ExitError
+	if !errors.As(runErr, &exitErr) {
+		return nil
+	}
+	lower := strings.ToLower(stderr)
+
+	switch exitErr.ExitCode() {
+	case exitSetupFailed:
+		if i := strings.Index(stderr, setupErrorPrefix); i >= 0 {
+			line, _, _ := strings.Cut(stderr[i+len(setupErrorPrefix):], "\n")
+			return &Violation{Kind: "setup", Detail: line}
+		}
+	case exitSignalBase + sigSYS:
+		return &Violation{Kind: "seccomp", Detail: "the command made a forbidden system call and was killed"}
+	case exitSignalBase + sigXCPU:
+		return &Violation{Kind: "cpu_limit", Detail: fmt.Sprintf("CPU time limit of %ds exceeded", cfg.CPUSeconds)}
+	}
+
+	switch {
+	case strings.Contains(lower, "read-only file system"):
+		return &Violation{
+			Kind:   "read_only_filesystem",
+			Detail: "only the workspace and /tmp are writable inside the sandbox",
+		}
+	case cfg.DenyNetwork && containsAny(lower, "network is unreachable", "could not resolve",
+		"temporary failure in name resolution", "name or service not known"):
+		return &Violation{Kind: "network", Detail: "network access is disabled in the sandbox"}
+	case cfg.MaxProcesses > 0 && containsAny(lower, "can't fork", "cannot fork", "resource temporarily unavailable"):
+		return &Violation{Kind: "process_limit", Detail: fmt.Sprintf("process limit of %d reached", cfg.MaxProcesses)}
+	case cfg.MemoryMB > 0 && containsAny(lower, "cannot allocate memory", "out of memory", "memoryerror"):
+		return &Violation{Kind: "memory_limit", Detail: fmt.Sprintf("memory limit of %d MB reached", cfg.MemoryMB)}
+	case strings.Contains(lower, "operation not permitted"):
+		return &Violation{Kind: "permission", Detail: "the operation is not permitted inside the sandbox"}
+	}
+	return nil
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,501 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// initArg0 marks a re-executed picoclaw binary as the sandbox init.
+	initArg0 = "picoclaw-sandbox-init"
+	// specEnv carries the JSON-encoded spec to the sandbox init.
+	specEnv = "PICOCLAW_SANDBOX_SPEC"
+)
+
+// systemPaths are bound read-only so ordinary tools keep working.
+var systemPaths = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/etc", "/opt"}
+
+// maxMemoryMB is the largest memory limit whose size in bytes fits an int.
+const maxMemoryMB = math.MaxInt >> 20
+
+// devices are bound from the host into the sandbox's /dev. There is no tty:
+// the command has no controlling terminal to inject keystrokes into.
+var devices = []string{"null", "zero", "full", "random", "urandom"}
+
+// spec is what the sandbox init needs to build the environment.
+type spec struct {
+	Command       string   `json:"command"`
+	Dir           string   `json:"dir"`
+	Workspace     string   `json:"workspace"`
+	ReadOnlyPaths []string `json:"read_only_paths,omitempty"`
+	WritablePaths []string `json:"writable_paths,omitempty"`
+	DenyNetwork   bool     `json:"deny_network,omitempty"`
+	CPUSeconds    int      `json:"cpu_seconds,omitempty"`
+	MemoryMB      int      `json:"memory_mb,omitempty"`
+	MaxProcesses  int      `json:"max_processes,omitempty"`
+}
+
+func init() {
+	if len(os.Args) == 0 || os.Args[0] != initArg0 {
+		return
+	}
+	// Mounts, seccomp and no_new_privs must all apply to the thread that
+	// starts the command.
+	runtime.LockOSThread()
+
+	var s spec
+	err := json.Unmarshal([]byte(os.Getenv(specEnv)), &s)
+	if err == nil {
+		os.Unsetenv(specEnv)
+		err = runInit(&s)
+	}
+	fmt.Fprintf(os.Stderr, "%s%v\n", setupErrorPrefix, err)
+	os.Exit(exitSetupFailed)
+}
+
+var (
+	availableOnce sync.Once
+	availableErr  error
+)
+
+// Available reports whether commands can be sandboxed on this system. The
+// result of the first probe is cached.
+func Available() error {
+	availableOnce.Do(func() {
+		availableErr = probe()
+	})
+	return availableErr
+}
+
+func probe() error {
+	dir, err := os.MkdirTemp("", "picoclaw-sandbox-probe-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd, err := Command(ctx, Config{Workspace: dir}, "true", dir)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if v := Classify(err, stderr.String(), Config{}); v != nil {
+			return v
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// Command returns a command that runs `sh -c command` in dir inside the
+// sandbox. Stdio and context cancellation work as with exec.CommandContext;
+// killing the process kills everything started inside the sandbox.
+func Command(ctx context.Context, cfg Config, command, dir string) (*exec.Cmd, error) {
+	if cfg.Workspace == "" {
+		return nil, fmt.Errorf("sandbox workspace is required")
+	}
+	workspace, err := filepath.Abs(cfg.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		dir = workspace
+	}
+	if cfg.MemoryMB > maxMemoryMB {
+		return nil, fmt.Errorf("sandbox memory limit of %d MB exceeds the maximum of %d MB", cfg.MemoryMB, maxMemoryMB)
+	}
+	s := spec{
+		Command:       command,
+		Dir:           dir,
+		Workspace:     workspace,
+		ReadOnlyPaths: cfg.ReadOnlyPaths,
+		WritablePaths: cfg.WritablePaths,
+		DenyNetwork:   cfg.DenyNetwork,
+		CPUSeconds:    cfg.CPUSeconds,
+		MemoryMB:      cfg.MemoryMB,
+		MaxProcesses:  cfg.MaxProcesses,
+	}
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+		syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS)
+	if cfg.DenyNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{initArg0}
+	cmd.Env = append(os.Environ(), specEnv+"="+string(encoded))
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 flags,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		// A new session detaches the sandbox from the operator's terminal;
+		// it also makes the init a process group leader, so the whole tree
+		// can be killed at once.
+		Setsid:    true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+	return cmd, nil
+}
+
+// runInit runs inside the new namespaces as PID 1. It builds the sandbox,
+// starts the command and exits with its status. It only returns on setup
+// errors.
+func runInit(s *spec) error {
+	if err := setupFilesystem(s); err != nil {
+		return err
+	}
+	_ = unix.Sethostname([]byte("sandbox"))
+	if s.DenyNetwork {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("loopback: %w", err)
+		}
+	}
+	if err := setRlimits(s); err != nil {
+		return fmt.Errorf("rlimits: %w", err)
+	}
+	if err := dropCapabilities(); err != nil {
+		return fmt.Errorf("capabilities: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("no_new_privs: %w", err)
+	}
+	if err := installSeccomp(); err != nil {
+		return fmt.Errorf("seccomp: %w", err)
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", s.Command)
+	cmd.Dir = s.Dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(waitReaping(cmd.Process.Pid))
+	return nil
+}
+
+// waitReaping waits for the command while reaping orphans reparented to us,
+// and maps its status to an exit code.
+func waitReaping(pid int) int {
+	for {
+		var status unix.WaitStatus
+		got, err := unix.Wait4(-1, &status, 0, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return exitSetupFailed
+		}
+		if got != pid {
+			continue
+		}
+		if status.Signaled() {
+			return exitSignalBase + int(status.Signal())
+		}
+		return status.ExitStatus()
+	}
+}
+
+func setupFilesystem(s *spec) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+
+	// Pivot into a scratch tmpfs so the host root is reachable as /oldroot
+	// while the new root is assembled, then pivot again into /newroot.
+	if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount scratch tmpfs: %w", err)
+	}
+	if err := os.Mkdir("/tmp/oldroot", 0o755); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot("/tmp", "/tmp/oldroot"); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+
+	const root = "/newroot"
+	if err := os.Mkdir(root, 0o755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount root tmpfs: %w", err)
+	}
+
+	// /tmp comes first so a workspace below it is not hidden.
+	if err := mountTmpfs(filepath.Join(root, "tmp"), "mode=1777"); err != nil {
+		return err
+	}
+	for _, p := range append(append([]string{}, systemPaths...), s.ReadOnlyPaths...) {
+		if err := bindPath(root, p, true, false); err != nil {
+			return err
+		}
+	}
+	for _, p := range append([]string{s.Workspace}, s.WritablePaths...) {
+		if err := bindPath(root, p, false, true); err != nil {
+			return err
+		}
+	}
+
+	if err := setupDev(filepath.Join(root, "dev")); err != nil {
+		return err
+	}
+	if err := setupProc(filepath.Join(root, "proc")); err != nil {
+		return err
+	}
+
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %w", err)
+	}
+	if err := unix.Mount("", "/", "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, ""); err != nil {
+		return fmt.Errorf("remount root read-only: %w", err)
+	}
+	if err := os.Chdir(s.Dir); err != nil {
+		return fmt.Errorf("working directory %s is not visible in the sandbox", s.Dir)
+	}
+	return nil
+}
+
+// bindPath makes the host path p visible at root+p. Missing optional paths
+// are skipped; symlinks (as in merged-/usr layouts) are recreated as-is.
+func bindPath(root, p string, readOnly, required bool) error {
+	p = filepath.Clean(p)
+	if !filepath.IsAbs(p) {
+		return fmt.Errorf("sandbox path %q must be absolute", p)
+	}
+	src := "/oldroot" + p
+	dst := root + p
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return fmt.Errorf("sandbox path %s: %w", p, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if _, err := os.Lstat(dst); err == nil {
+			return nil
+		}
+		return os.Symlink(target, dst)
+	}
+
+	if info.IsDir() {
+		err = os.MkdirAll(dst, 0o755)
+	} else {
+		err = createFile(dst)
+	}
+	if err != nil {
+		return err
+	}
+	if err := unix.Mount(src, dst, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", p, err)
+	}
+	if !readOnly {
+		return nil
+	}
+	// Flags locked by the parent mount (nosuid, nodev, ...) have to be kept
+	// or the read-only remount is refused inside a user namespace.
+	var st unix.Statfs_t
+	if err := unix.Statfs(dst, &st); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+	for _, f := range []struct{ st, ms int64 }{
+		{unix.ST_NOSUID, unix.MS_NOSUID},
+		{unix.ST_NODEV, unix.MS_NODEV},
+		{unix.ST_NOEXEC, unix.MS_NOEXEC},
+		{unix.ST_NOATIME, unix.MS_NOATIME},
+		{unix.ST_NODIRATIME, unix.MS_NODIRATIME},
+		{unix.ST_RELATIME, unix.MS_RELATIME},
+	} {
+		if int64(st.Flags)&f.st != 0 {
+			flags |= uintptr(f.ms)
+		}
+	}
+	if err := unix.Mount("", dst, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", p, err)
+	}
+	return nil
+}
+
+func createFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func mountTmpfs(dst, opts string) error {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", dst, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, opts); err != nil {
+		return fmt.Errorf("mount tmpfs on %s: %w", dst, err)
+	}
+	return nil
+}
+
+func setupDev(dev string) error {
+	if err := mountTmpfs(dev, "mode=0755"); err != nil {
+		return err
+	}
+	for _, name := range devices {
+		src := "/oldroot/dev/" + name
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		dst := filepath.Join(dev, name)
+		if err := createFile(dst); err != nil {
+			return err
+		}
+		if err := unix.Mount(src, dst, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind /dev/%s: %w", name, err)
+		}
+	}
+	for name, target := range map[string]string{
+		"fd":     "/proc/self/fd",
+		"stdin":  "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1",
+		"stderr": "/proc/self/fd/2",
+	} {
+		if err := os.Symlink(target, filepath.Join(dev, name)); err != nil {
+			return err
+		}
+	}
+	return mountTmpfs(filepath.Join(dev, "shm"), "mode=1777")
+}
+
+// mountProcFS mounts a fresh proc filesystem at dst; tests replace it.
+var mountProcFS = func(dst string) error {
+	return unix.Mount("proc", dst, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+}
+
+// setupProc mounts a proc filesystem for the new PID namespace. Kernels
+// refuse that when the host's /proc is partially masked (as inside many
+// containers). The host /proc is never bound instead: it would expose
+// /proc/<pid>/root and /proc/<pid>/environ of PicoClaw itself, which runs
+// as the same user. The sandbox is reported as unavailable instead, and
+// exec falls back to the command guard.
+func setupProc(dst string) error {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	if err := mountProcFS(dst); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+	return nil
+}
+
+// loopbackUp brings up lo in the new network namespace so local servers
+// still work without network access.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr)
+}
+
+func setRlimits(s *spec) error {
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{unix.RLIMIT_CPU, uint64(max(s.CPUSeconds, 0))},
+		{unix.RLIMIT_AS, uint64(max(s.MemoryMB, 0)) << 20},
+		// RLIMIT_NPROC counts all processes of the host user, not only
+		// those in the sandbox.
+		{unix.RLIMIT_NPROC, uint64(max(s.MaxProcesses, 0))},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		cur := l.value
+		max := cur
+		if l.resource == unix.RLIMIT_CPU {
+			// SIGXCPU at the soft limit, SIGKILL one second later.
+			max = cur + 1
+		}
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: cur, Max: max}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropCapabilities empties the bounding, ambient and inheritable sets so the
+// command runs without capabilities even though it is root in its user
+// namespace. The init keeps its own effective set until it execs.
+func dropCapabilities() error {
+	for c := 0; c <= unix.CAP_LAST_CAP; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil && err != unix.EINVAL {
+			return err
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil && err != unix.EINVAL {
+		return err
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return err
+	}
+	data[0].Inheritable, data[1].Inheritable = 0, 0
+	return unix.Capset(&hdr, &data[0])
+}
@@ -0,0 +1,171 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func requireSandbox(t *testing.T) {
+	t.Helper()
+	if err := Available(); err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+}
+
+func runSandboxed(t *testing.T, cfg Config, command string) (string, string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cmd, err := Command(ctx, cfg, command, cfg.Workspace)
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestCommand_WorkspaceWritableSystemReadOnly(t *testing.T) {
+	requireSandbox(t)
+	workspace := t.TempDir()
+	cfg := Config{Workspace: workspace}
+
+	stdout, stderr, err := runSandboxed(t, cfg, "echo hi > out.txt && cat out.txt && echo $$")
+	if err != nil {
+		t.Fatalf("run error = %v, stderr = %s", err, stderr)
+	}
+	if got := strings.Fields(stdout); len(got) != 2 || got[0] != "hi" || got[1] == "1" {
+		t.Errorf("stdout = %q, want hi and a non-init pid", stdout)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "out.txt")); string(data) != "hi\n" {
+		t.Errorf("workspace file = %q", data)
+	}
+
+	_, stderr, err = runSandboxed(t, cfg, "touch /etc/picoclaw-sandbox-test")
+	v := Classify(err, stderr, cfg)
+	if v == nil || v.Kind != "read_only_filesystem" {
+		t.Errorf("writing /etc: violation = %v, stderr = %s", v, stderr)
+	}
+}
+
+func TestCommand_HidesHostPaths(t *testing.T) {
+	requireSandbox(t)
+	secret := t.TempDir()
+	os.WriteFile(filepath.Join(secret, "key"), []byte("secret"), 0o600)
+
+	stdout, _, _ := runSandboxed(t, Config{Workspace: t.TempDir()}, "cat "+filepath.Join(secret, "key"))
+	if strings.Contains(stdout, "secret") {
+		t.Error("paths outside the workspace should not be visible")
+	}
+	stdout, _, _ = runSandboxed(t, Config{Workspace: t.TempDir(), ReadOnlyPaths: []string{secret}},
+		"cat "+filepath.Join(secret, "key"))
+	if stdout != "secret" {
+		t.Errorf("read-only path content = %q", stdout)
+	}
+}
+
+func TestCommand_DenyNetwork(t *testing.T) {
+	requireSandbox(t)
+	stdout, stderr, err := runSandboxed(t, Config{Workspace: t.TempDir(), DenyNetwork: true},
+		"cat /proc/net/dev")
+	if err != nil {
+		t.Fatalf("run error = %v, stderr = %s", err, stderr)
+	}
+	for _, line := range strings.Split(stdout, "\n")[2:] {
+		name, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		if name != "" && name != "lo" {
+			t.Errorf("unexpected interface %q in network-less sandbox", name)
+		}
+	}
+}
+
+func TestCommand_SeccompKillsEscapeSyscalls(t *testing.T) {
+	requireSandbox(t)
+	if _, err := exec.LookPath("unshare"); err != nil {
+		t.Skip("unshare not installed")
+	}
+	cfg := Config{Workspace: t.TempDir()}
+	_, stderr, err := runSandboxed(t, cfg, "unshare -U true")
+	if v := Classify(err, stderr, cfg); v == nil || v.Kind != "seccomp" {
+		t.Errorf("violation = %v, err = %v, stderr = %s", v, err, stderr)
+	}
+}
+
+func TestCommand_NoTerminalInjection(t *testing.T) {
+	requireSandbox(t)
+	cfg := Config{Workspace: t.TempDir()}
+
+	// The session field of /proc/self/stat is 0 when the session leader is
+	// outside the sandbox, as it is when the operator's terminal is shared.
+	stdout, stderr, err := runSandboxed(t, cfg, "cut -d' ' -f6 /proc/self/stat; test ! -e /dev/tty")
+	if err != nil || strings.TrimSpace(stdout) != "1" {
+		t.Errorf("session = %q, err = %v, stderr = %s; want a session of its own and no /dev/tty", stdout, err, stderr)
+	}
+
+	script := `import fcntl, termios
+def ioctl_errno(req):
+    try:
+        fcntl.ioctl(0, req, bytes(64))
+    except OSError as e:
+        return e.errno
+    return 0
+print(ioctl_errno(termios.TIOCSTI), ioctl_errno(termios.TIOCLINUX), ioctl_errno(termios.TCGETS))`
+	stdout, stderr, err = runSandboxed(t, cfg, "python3 -c '"+script+"'")
+	if err != nil && strings.Contains(stderr, "not found") {
+		t.Skip("python3 not installed")
+	}
+	// EPERM comes from seccomp; stdin is /dev/null, so allowed ioctls fail
+	// with ENOTTY.
+	if got := strings.Fields(stdout); len(got) != 3 || got[0] != "1" || got[1] != "1" || got[2] != "25" {
+		t.Errorf("ioctl errnos = %q, err = %v, stderr = %s; want EPERM, EPERM, ENOTTY", stdout, err, stderr)
+	}
+}
+
+func TestCommand_RejectsOversizedMemoryLimit(t *testing.T) {
+	cfg := Config{Workspace: t.TempDir(), MemoryMB: maxMemoryMB + 1}
+	if _, err := Command(context.Background(), cfg, "true", ""); err == nil {
+		t.Error("expected an error for a memory limit that overflows int")
+	}
+}
+
+func TestSetupProc_FailsWithoutBindingHostProc(t *testing.T) {
+	orig := mountProcFS
+	mountProcFS = func(string) error { return os.ErrPermission }
+	defer func() { mountProcFS = orig }()
+
+	dst := filepath.Join(t.TempDir(), "proc")
+	err := setupProc(dst)
+	if err == nil || !strings.Contains(err.Error(), "mount /proc") {
+		t.Fatalf("setupProc() error = %v, want a mount /proc error", err)
+	}
+	entries, _ := os.ReadDir(dst)
+	if len(entries) != 0 {
+		t.Errorf("%s has %d entries, want nothing mounted", dst, len(entries))
+	}
+}
+
+func TestCommand_CPULimit(t *testing.T) {
+	requireSandbox(t)
+	cfg := Config{Workspace: t.TempDir(), CPUSeconds: 1}
+	_, stderr, err := runSandboxed(t, cfg, "while :; do :; done")
+	if v := Classify(err, stderr, cfg); v == nil || v.Kind != "cpu_limit" {
+		t.Errorf("violation = %v, err = %v", v, err)
+	}
+}
+
+func TestClassify_IgnoresOrdinaryFailures(t *testing.T) {
+	err := exec.Command("sh", "-c", "exit 1").Run()
+	if v := Classify(err, "grep: pattern not found", Config{}); v != nil {
+		t.Errorf("Classify() = %v, want nil", v)
+	}
+	if v := Classify(nil, "", Config{}); v != nil {
+		t.Errorf("Classify(nil) = %v, want nil", v)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Available reports whether commands can be sandboxed on this system.
+func Available() error {
+	return ErrUnsupported
+}
+
+// Command is not supported outside Linux.
+func Command(_ context.Context, _ Config, _, _ string) (*exec.Cmd, error) {
+	return nil, ErrUnsupported
+}
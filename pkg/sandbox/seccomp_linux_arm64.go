@@ -0,0 +1,12 @@
+package sandbox
+
+import "golang.org/x/sys/unix"
+
+const (
+	seccompAuditArch      = unix.AUDIT_ARCH_AARCH64
+	seccompSyscallBitMask = 0
+)
+
+var archAllowedSyscalls = []uintptr{unix.SYS_RENAMEAT}
+
+var archKilledSyscalls = []uintptr{unix.SYS_KEXEC_FILE_LOAD}
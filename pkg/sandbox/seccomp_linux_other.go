@@ -0,0 +1,9 @@
+//go:build linux && !amd64 && !arm64 && !riscv64
+
+package sandbox
+
+// installSeccomp is a no-op on architectures without a syscall table here;
+// namespaces, rlimits and no_new_privs still apply.
+func installSeccomp() error {
+	return nil
+}
@@ -0,0 +1,13 @@
+package sandbox
+
+import "golang.org/x/sys/unix"
+
+const (
+	seccompAuditArch      = unix.AUDIT_ARCH_RISCV64
+	seccompSyscallBitMask = 0
+)
+
+var (
+	archAllowedSyscalls []uintptr
+	archKilledSyscalls  = []uintptr{unix.SYS_KEXEC_FILE_LOAD}
+)
@@ -0,0 +1,236 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+var (
+	iioDeviceName  = regexp.MustCompile(`^iio:device\d+$`)
+	iioChannelName = regexp.MustCompile(`^([a-z]+)\d*(-[a-z]+\d*)?$`)
+)
+
+const adcMaxSamples = 64
+
+// iioUnits are the units of processed IIO values, per the sysfs ABI.
+var iioUnits = map[string]string{
+	"voltage": "mV",
+	"current": "mA",
+	"temp":    "m°C",
+}
+
+// ADCTool reads analog inputs through the Linux IIO sysfs interface. Only the
+// channels allowed in the config can be read.
+type ADCTool struct {
+	allowed map[string][]string // device -> channel names
+	root    string              // /sys/bus/iio/devices
+}
+
+// NewADCTool creates an ADC tool limited to the given channels (e.g.
+// "voltage0"), keyed by IIO device (e.g. "iio:device0").
+func NewADCTool(allowed map[string][]string) *ADCTool {
+	return &ADCTool{allowed: allowed, root: "/sys/bus/iio/devices"}
+}
+
+func (t *ADCTool) Name() string {
+	return "adc"
+}
+
+func (t *ADCTool) Description() string {
+	return "Read analog sensors (potentiometers, light sensors, battery voltage) through the Linux IIO subsystem. Actions: list (allowed channels with a current reading), read (read a channel, optionally averaging several samples). Values are scaled to mV for voltage channels. Only channels allowed in the config can be read. Linux only."
+}
+
+func (t *ADCTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "read"},
+				"description": "Action to perform",
+			},
+			"device": map[string]any{
+				"type":        "string",
+				"description": "IIO device, e.g. \"iio:device0\". Required for read.",
+			},
+			"channel": map[string]any{
+				"type":        "string",
+				"description": "IIO channel, e.g. \"voltage0\". Required for read.",
+			},
+			"samples": map[string]any{
+				"type":        "integer",
+				"description": "Number of samples to average (1-64). Default: 1.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ADCTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "list":
+		return t.list()
+	case "read":
+		return t.read(args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, read)", action))
+	}
+}
+
+type adcReading struct {
+	Device  string   `json:"device"`
+	Name    string   `json:"name,omitempty"`
+	Channel string   `json:"channel"`
+	Raw     *float64 `json:"raw,omitempty"`
+	Scale   float64  `json:"scale,omitempty"`
+	Offset  float64  `json:"offset,omitempty"`
+	Value   float64  `json:"value"`
+	Unit    string   `json:"unit,omitempty"`
+	Samples int      `json:"samples"`
+	Error   string   `json:"error,omitempty"`
+}
+
+func (t *ADCTool) list() *ToolResult {
+	devices := make([]string, 0, len(t.allowed))
+	for dev := range t.allowed {
+		devices = append(devices, dev)
+	}
+	sort.Strings(devices)
+
+	var readings []adcReading
+	for _, dev := range devices {
+		for _, ch := range t.allowed[dev] {
+			r, err := t.measure(dev, ch, 1)
+			if err != nil {
+				r = adcReading{Device: dev, Channel: ch, Error: err.Error()}
+			}
+			readings = append(readings, r)
+		}
+	}
+	if len(readings) == 0 {
+		return SilentResult("No ADC channels are allowed. Add them to tools.adc.channels in the config.")
+	}
+	result, _ := json.MarshalIndent(readings, "", "  ")
+	return SilentResult(fmt.Sprintf("Allowed ADC channels:\n%s", string(result)))
+}
+
+func (t *ADCTool) read(args map[string]any) *ToolResult {
+	dev, ok := args["device"].(string)
+	if !ok || dev == "" {
+		return ErrorResult("device is required (e.g. \"iio:device0\")")
+	}
+	ch, ok := args["channel"].(string)
+	if !ok || ch == "" {
+		return ErrorResult("channel is required (e.g. \"voltage0\")")
+	}
+	if !slices.Contains(t.allowed[dev], ch) {
+		return ErrorResult(fmt.Sprintf(
+			"%s channel %s is not allowed. Allowed channels are listed under tools.adc.channels in the config.", dev, ch))
+	}
+	samples := 1
+	if s, ok := args["samples"].(float64); ok {
+		if s < 1 || s > adcMaxSamples {
+			return ErrorResult(fmt.Sprintf("samples must be between 1 and %d", adcMaxSamples))
+		}
+		samples = int(s)
+	}
+
+	r, err := t.measure(dev, ch, samples)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read %s channel %s: %v", dev, ch, err))
+	}
+	result, _ := json.MarshalIndent(r, "", "  ")
+	return SilentResult(string(result))
+}
+
+// measure averages samples readings of a channel. Processed values
+// (in_<channel>_input) are used when the driver provides them; otherwise the
+// raw value is converted with the channel's scale and offset.
+func (t *ADCTool) measure(dev, ch string, samples int) (adcReading, error) {
+	if !iioDeviceName.MatchString(dev) {
+		return adcReading{}, fmt.Errorf("invalid device name %q (expected e.g. iio:device0)", dev)
+	}
+	m := iioChannelName.FindStringSubmatch(ch)
+	if m == nil {
+		return adcReading{}, fmt.Errorf("invalid channel name %q (expected e.g. voltage0)", ch)
+	}
+	kind := m[1]
+	dir := filepath.Join(t.root, dev)
+	if _, err := os.Stat(dir); err != nil {
+		return adcReading{}, fmt.Errorf("%s not found", dev)
+	}
+
+	r := adcReading{Device: dev, Channel: ch, Samples: samples, Unit: iioUnits[kind]}
+	r.Name, _ = readSysfs(filepath.Join(dir, "name"))
+
+	input := filepath.Join(dir, "in_"+ch+"_input")
+	if _, err := os.Stat(input); err == nil {
+		v, err := averageSysfs(input, samples)
+		if err != nil {
+			return adcReading{}, err
+		}
+		r.Value = v
+		return r, nil
+	}
+
+	raw, err := averageSysfs(filepath.Join(dir, "in_"+ch+"_raw"), samples)
+	if err != nil {
+		return adcReading{}, err
+	}
+	r.Raw = &raw
+	r.Scale = 1
+	if v, ok := iioAttr(dir, ch, kind, "scale"); ok {
+		r.Scale = v
+	} else {
+		r.Unit = ""
+	}
+	if v, ok := iioAttr(dir, ch, kind, "offset"); ok {
+		r.Offset = v
+	}
+	r.Value = math.Round((raw+r.Offset)*r.Scale*1000) / 1000
+	return r, nil
+}
+
+// iioAttr reads a per-channel attribute (in_voltage0_scale), falling back to
+// the one shared by the channel type (in_voltage_scale).
+func iioAttr(dir, ch, kind, attr string) (float64, bool) {
+	for _, name := range []string{"in_" + ch + "_" + attr, "in_" + kind + "_" + attr} {
+		s, err := readSysfs(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func averageSysfs(path string, samples int) (float64, error) {
+	var sum float64
+	for range samples {
+		s, err := readSysfs(path)
+		if err != nil {
+			return 0, err
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		sum += v
+	}
+	return sum / float64(samples), nil
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fakeIIOTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"iio:device0/name":              "saradc\n",
+		"iio:device0/in_voltage0_raw":   "1000\n",
+		"iio:device0/in_voltage1_raw":   "2000\n",
+		"iio:device0/in_voltage_scale":  "0.5\n",
+		"iio:device0/in_voltage1_scale": "2\n",
+		"iio:device1/in_temp_input":     "42500\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestADCRead(t *testing.T) {
+	tool := NewADCTool(map[string][]string{
+		"iio:device0": {"voltage0", "voltage1"},
+		"iio:device1": {"temp"},
+	})
+	tool.root = fakeIIOTree(t)
+
+	tests := []struct {
+		dev, ch string
+		want    string
+	}{
+		{"iio:device0", "voltage0", `"value": 500,`},
+		{"iio:device0", "voltage1", `"value": 4000,`},
+		{"iio:device1", "temp", `"value": 42500,`},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(context.Background(), map[string]any{
+			"action": "read", "device": tt.dev, "channel": tt.ch, "samples": float64(4),
+		})
+		if result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%s %s: %s", tt.dev, tt.ch, result.ForLLM)
+		}
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"action": "list"})
+	if !strings.Contains(result.ForLLM, `"name": "saradc"`) || !strings.Contains(result.ForLLM, `"unit": "mV"`) {
+		t.Errorf("list: %s", result.ForLLM)
+	}
+}
+
+func TestADCValidation(t *testing.T) {
+	tool := NewADCTool(map[string][]string{"iio:device0": {"voltage0", "../../x"}})
+	tool.root = fakeIIOTree(t)
+
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"device": "iio:device0", "channel": "voltage1"}, "not allowed"},
+		{map[string]any{"device": "iio:device0", "channel": "../../x"}, "invalid channel name"},
+		{map[string]any{"device": "iio:device0", "channel": "voltage0", "samples": float64(100)}, "samples"},
+	}
+	for _, tt := range tests {
+		args := map[string]any{"action": "read"}
+		for k, v := range tt.args {
+			args[k] = v
+		}
+		result := tool.Execute(context.Background(), args)
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%v: got %q, want %q", tt.args, result.ForLLM, tt.want)
+		}
+	}
+}
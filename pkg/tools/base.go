@@ -1,6 +1,10 @@
 package tools
 
-import "context"
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/media"
+)
 
 // Tool is the interface that all tools must implement.
 type Tool interface {
@@ -17,6 +21,12 @@ type ContextualTool interface {
 	SetContext(channel, chatID string)
 }
 
+// MediaStoreAware is an optional interface for tools that register the files
+// they produce in the MediaStore and return the refs in ToolResult.Media.
+type MediaStoreAware interface {
+	SetMediaStore(store media.MediaStore)
+}
+
 // AsyncCallback is a function type that async tools use to notify completion.
 // When an async tool finishes its work, it calls this callback with the result.
 //
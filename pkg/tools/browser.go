@@ -0,0 +1,464 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/browser"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/netpolicy"
+)
+
+const (
+	// browserActionTimeout bounds one browser action, page loads included.
+	browserActionTimeout = 90 * time.Second
+	// browserMaxChars is the size of the page text parts the tool returns.
+	browserMaxChars = 20000
+)
+
+// browserTab is the page of one chat session.
+type browserTab struct {
+	mu       sync.Mutex // one action at a time
+	page     *browser.Page
+	lastUsed time.Time
+	text     string // last snapshot, for reading it in parts
+}
+
+// BrowserTool drives a headless Chromium for pages that need JavaScript,
+// logins or forms. Every chat session gets a tab of its own, in a separate
+// browser context. The browser is started on first use and shut down when
+// all tabs have been idle for the idle timeout.
+type BrowserTool struct {
+	cdpURL      string
+	binaryPath  string
+	idleTimeout time.Duration
+	policy      *netpolicy.Policy
+	mediaDir    string
+
+	mu      sync.RWMutex
+	store   media.MediaStore
+	channel string
+	chatID  string
+
+	tabsMu  sync.Mutex
+	browser *browser.Browser
+	tabs    map[string]*browserTab // by session
+	stop    chan struct{}          // stops the idle janitor
+}
+
+func NewBrowserTool(cfg config.BrowserToolsConfig) *BrowserTool {
+	idle := time.Duration(cfg.IdleTimeout) * time.Second
+	if idle <= 0 {
+		idle = 5 * time.Minute
+	}
+	return &BrowserTool{
+		cdpURL:      cfg.CDPURL,
+		binaryPath:  cfg.BinaryPath,
+		idleTimeout: idle,
+		policy:      netpolicy.Default(),
+		mediaDir:    filepath.Join(os.TempDir(), "picoclaw_media"),
+		tabs:        make(map[string]*browserTab),
+	}
+}
+
+// SetNetworkPolicy limits where pages may connect, for navigation as well as
+// for redirects and everything pages load. It applies to browsers started
+// afterwards.
+func (t *BrowserTool) SetNetworkPolicy(policy *netpolicy.Policy) {
+	if policy != nil {
+		t.policy = policy
+	}
+}
+
+func (t *BrowserTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+// SetMediaStore sets the store screenshots are registered in. Without a
+// store, they are only saved to disk.
+func (t *BrowserTool) SetMediaStore(store media.MediaStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.store = store
+}
+
+func (t *BrowserTool) Name() string {
+	return "browser"
+}
+
+func (t *BrowserTool) Description() string {
+	return "Control a headless web browser, for pages that need JavaScript, logins or forms " +
+		"(use web_fetch for plain pages). navigate opens a URL and returns the page as text, " +
+		"in which links, buttons and form fields appear as [ref role \"name\"]. " +
+		"Use the ref with click and type, which return the updated page. " +
+		"snapshot returns the current page again, screenshot sends an image of it to the user, " +
+		"evaluate runs JavaScript in it and close closes the tab. The tab is kept between calls."
+}
+
+func (t *BrowserTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"navigate", "snapshot", "click", "type", "screenshot", "evaluate", "close"},
+				"description": "Action to perform",
+			},
+			"url": map[string]any{
+				"type":        "string",
+				"description": "URL to open (navigate)",
+			},
+			"ref": map[string]any{
+				"type":        "integer",
+				"description": "Element ref from the last page text (click, type)",
+			},
+			"text": map[string]any{
+				"type":        "string",
+				"description": "Text to enter, or the option to choose in a select (type)",
+			},
+			"clear": map[string]any{
+				"type":        "boolean",
+				"description": "Replace the current content of the field (type, default true)",
+			},
+			"submit": map[string]any{
+				"type":        "boolean",
+				"description": "Press Enter after typing (type)",
+			},
+			"full_page": map[string]any{
+				"type":        "boolean",
+				"description": "Capture the whole page instead of the visible part (screenshot)",
+			},
+			"expression": map[string]any{
+				"type":        "string",
+				"description": "JavaScript expression to evaluate; promises are awaited (evaluate)",
+			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Character offset to continue reading long page text at (snapshot)",
+				"minimum":     0.0,
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *BrowserTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	t.mu.RLock()
+	session := t.channel + ":" + t.chatID
+	t.mu.RUnlock()
+
+	var ref int
+	switch action {
+	case "navigate":
+		rawURL, _ := args["url"].(string)
+		if rawURL == "" {
+			return ErrorResult("url is required for navigate")
+		}
+		// Checked here too, so that no browser is started for a blocked URL.
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("invalid URL: %v", err))
+		}
+		if err := t.policy.CheckURL(u); err != nil {
+			return ErrorResult(err.Error()).WithError(err)
+		}
+	case "click", "type":
+		v, ok := args["ref"].(float64)
+		if !ok || v < 1 || v != float64(int(v)) {
+			return ErrorResult(fmt.Sprintf("ref is required for %s: use a ref from the page text", action))
+		}
+		ref = int(v)
+		if _, ok := args["text"].(string); action == "type" && !ok {
+			return ErrorResult("text is required for type")
+		}
+	case "evaluate":
+		if expr, _ := args["expression"].(string); strings.TrimSpace(expr) == "" {
+			return ErrorResult("expression is required for evaluate")
+		}
+	case "close":
+		if t.closeTab(session) {
+			return SilentResult("Browser tab closed")
+		}
+		return SilentResult("No browser tab was open")
+	case "snapshot", "screenshot":
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q", action))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, browserActionTimeout)
+	defer cancel()
+
+	tab, err := t.tab(ctx, session, action == "navigate")
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("browser unavailable: %v", err)).WithError(err)
+	}
+	if tab == nil {
+		return ErrorResult("no page is open: use navigate first")
+	}
+	tab.mu.Lock()
+	defer func() {
+		tab.lastUsed = time.Now()
+		tab.mu.Unlock()
+	}()
+
+	result := t.run(ctx, tab, action, ref, args)
+	if tab.page.Closed() {
+		// The tab crashed or the browser went away; the next call starts over.
+		t.dropTab(session, tab)
+	}
+	return result
+}
+
+// run performs action on the page of tab.
+func (t *BrowserTool) run(
+	ctx context.Context,
+	tab *browserTab,
+	action string,
+	ref int,
+	args map[string]any,
+) *ToolResult {
+	page := tab.page
+	var err error
+	switch action {
+	case "navigate":
+		err = page.Navigate(ctx, args["url"].(string))
+	case "click":
+		err = page.Click(ctx, ref)
+	case "type":
+		replace, ok := args["clear"].(bool)
+		if !ok {
+			replace = true
+		}
+		submit, _ := args["submit"].(bool)
+		err = page.Type(ctx, ref, args["text"].(string), replace, submit)
+	case "screenshot":
+		fullPage, _ := args["full_page"].(bool)
+		png, err := page.Screenshot(ctx, fullPage)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("screenshot failed: %v", err)).WithError(err)
+		}
+		return t.saveScreenshot(png)
+	case "evaluate":
+		value, err := page.Evaluate(ctx, args["expression"].(string))
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("evaluate failed: %v", err)).WithError(err)
+		}
+		text := string(value)
+		if len([]rune(text)) > browserMaxChars {
+			text = string([]rune(text)[:browserMaxChars]) + "\n[result truncated]"
+		}
+		return SilentResult(dialogNotes(page) + text)
+	case "snapshot":
+		// Further parts are read from the snapshot the first part came from,
+		// so that the offsets match.
+		if offset, ok := args["offset"].(float64); ok && offset > 0 && tab.text != "" {
+			return t.pageResult(ctx, tab, int(offset))
+		}
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("%s failed: %v", action, err)).WithError(err)
+	}
+
+	if tab.text, err = page.Snapshot(ctx); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read the page: %v", err)).WithError(err)
+	}
+	return t.pageResult(ctx, tab, 0)
+}
+
+// pageResult returns the snapshot text of tab from offset on.
+func (t *BrowserTool) pageResult(ctx context.Context, tab *browserTab, offset int) *ToolResult {
+	title, pageURL, err := tab.page.Info(ctx)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read the page: %v", err)).WithError(err)
+	}
+
+	runes := []rune(tab.text)
+	total := len(runes)
+	offset = min(offset, total)
+	end := min(offset+browserMaxChars, total)
+
+	var sb strings.Builder
+	sb.WriteString(dialogNotes(tab.page))
+	fmt.Fprintf(&sb, "Page: %s\nURL: %s\n", title, pageURL)
+	if offset > 0 || end < total {
+		fmt.Fprintf(&sb, "Showing characters %d-%d of %d.", offset, end, total)
+		if end < total {
+			fmt.Fprintf(&sb, " Call browser with action=snapshot and offset=%d to read more.", end)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	if total == 0 {
+		sb.WriteString("(the page has no text)")
+	}
+	sb.WriteString(string(runes[offset:end]))
+	return SilentResult(sb.String())
+}
+
+// dialogNotes lists the dialogs page opened, which were accepted.
+func dialogNotes(page *browser.Page) string {
+	var sb strings.Builder
+	for _, d := range page.Dialogs() {
+		fmt.Fprintf(&sb, "The page opened a dialog, which was accepted: %s\n", d)
+	}
+	return sb.String()
+}
+
+func (t *BrowserTool) saveScreenshot(png []byte) *ToolResult {
+	if err := os.MkdirAll(t.mediaDir, 0o700); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create media directory: %v", err)).WithError(err)
+	}
+	filename := fmt.Sprintf("screenshot_%s.png", uuid.New().String()[:8])
+	localPath := filepath.Join(t.mediaDir, filename)
+	if err := os.WriteFile(localPath, png, 0o600); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save screenshot: %v", err)).WithError(err)
+	}
+
+	t.mu.RLock()
+	store, channel, chatID := t.store, t.channel, t.chatID
+	t.mu.RUnlock()
+	if store == nil {
+		return NewToolResult(fmt.Sprintf("Screenshot saved to %s", localPath))
+	}
+	ref, err := store.Store(localPath, media.MediaMeta{
+		Filename:    filename,
+		ContentType: "image/png",
+		Source:      "tool:browser",
+	}, fmt.Sprintf("tool:browser:%s:%s", channel, chatID))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to store screenshot: %v", err)).WithError(err)
+	}
+	return MediaResult(fmt.Sprintf("Screenshot sent to the user: %s", ref), []string{ref})
+}
+
+// tab returns the tab of session, opening one if create is set. The
+// browser is started when needed.
+func (t *BrowserTool) tab(ctx context.Context, session string, create bool) (*browserTab, error) {
+	t.tabsMu.Lock()
+	defer t.tabsMu.Unlock()
+
+	if tab := t.tabs[session]; tab != nil || !create {
+		return tab, nil
+	}
+	if t.browser != nil && t.browser.Closed() {
+		t.shutdownLocked()
+	}
+	if t.browser == nil {
+		b, err := browser.Start(ctx, browser.Options{
+			CDPURL:     t.cdpURL,
+			BinaryPath: t.binaryPath,
+			Policy:     t.policy,
+		})
+		if err != nil {
+			return nil, err
+		}
+		t.browser = b
+		t.stop = make(chan struct{})
+		go t.janitor(t.stop)
+	}
+	page, err := t.browser.NewPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tab := &browserTab{page: page, lastUsed: time.Now()}
+	t.tabs[session] = tab
+	return tab, nil
+}
+
+// closeTab closes the tab of session, and reports whether there was one.
+func (t *BrowserTool) closeTab(session string) bool {
+	t.tabsMu.Lock()
+	tab := t.tabs[session]
+	delete(t.tabs, session)
+	t.tabsMu.Unlock()
+	if tab == nil {
+		return false
+	}
+	tab.mu.Lock()
+	defer tab.mu.Unlock()
+	_ = tab.page.Close()
+	return true
+}
+
+// dropTab forgets tab, which has gone away, if it is still the tab of session.
+func (t *BrowserTool) dropTab(session string, tab *browserTab) {
+	t.tabsMu.Lock()
+	defer t.tabsMu.Unlock()
+	if t.tabs[session] == tab {
+		delete(t.tabs, session)
+	}
+}
+
+// janitor closes idle tabs, and the browser once no tabs are left.
+func (t *BrowserTool) janitor(stop chan struct{}) {
+	ticker := time.NewTicker(min(max(t.idleTimeout/4, time.Second), 30*time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.closeIdle(time.Now())
+		}
+	}
+}
+
+func (t *BrowserTool) closeIdle(now time.Time) {
+	t.tabsMu.Lock()
+	defer t.tabsMu.Unlock()
+	for session, tab := range t.tabs {
+		// A busy tab is in use, whatever its last use was.
+		if !tab.mu.TryLock() {
+			continue
+		}
+		if now.Sub(tab.lastUsed) >= t.idleTimeout || tab.page.Closed() {
+			_ = tab.page.Close()
+			delete(t.tabs, session)
+			logger.DebugCF("tool", "Closed idle browser tab", map[string]any{"session": session})
+		}
+		tab.mu.Unlock()
+	}
+	if len(t.tabs) == 0 && t.browser != nil {
+		t.shutdownLocked()
+	}
+}
+
+// shutdownLocked closes the browser and stops the janitor. tabsMu is held.
+func (t *BrowserTool) shutdownLocked() {
+	for session, tab := range t.tabs {
+		_ = tab.page.Close()
+		delete(t.tabs, session)
+	}
+	if t.browser != nil {
+		if err := t.browser.Close(); err != nil && !errors.Is(err, browser.ErrClosed) {
+			logger.WarnCF("tool", "Failed to close browser", map[string]any{"error": err.Error()})
+		}
+		t.browser = nil
+	}
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+}
+
+// Close closes all tabs and shuts the browser down.
+func (t *BrowserTool) Close() {
+	t.tabsMu.Lock()
+	defer t.tabsMu.Unlock()
+	t.shutdownLocked()
+}
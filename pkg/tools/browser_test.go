@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+// startFakeBrowser serves a DevTools endpoint whose page shows pageText,
+// and returns its URL.
+func startFakeBrowser(t *testing.T, pageText string) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			var req struct {
+				ID        int64  `json:"id"`
+				SessionID string `json:"sessionId"`
+				Method    string `json:"method"`
+				Params    struct {
+					Expression string `json:"expression"`
+				} `json:"params"`
+			}
+			if err := ws.ReadJSON(&req); err != nil {
+				return
+			}
+			var result any = map[string]any{}
+			switch req.Method {
+			case "Target.createBrowserContext":
+				result = map[string]any{"browserContextId": "ctx"}
+			case "Target.createTarget":
+				result = map[string]any{"targetId": "target"}
+			case "Target.attachToTarget":
+				result = map[string]any{"sessionId": "session"}
+			case "Page.captureScreenshot":
+				result = map[string]any{"data": base64.StdEncoding.EncodeToString([]byte("\x89PNG"))}
+			case "Runtime.evaluate":
+				var value any = "complete"
+				switch expr := req.Params.Expression; {
+				case strings.Contains(expr, "__picoclawRefs = refs"):
+					value = pageText
+				case strings.Contains(expr, "document.title"):
+					value = map[string]any{"title": "Test page", "url": "https://example.com/"}
+				case strings.Contains(expr, "getBoundingClientRect"):
+					value = map[string]any{"x": 10, "y": 10, "w": 50, "h": 20}
+				case expr == "document.links.length":
+					value = 3
+				}
+				result = map[string]any{"result": map[string]any{"type": "object", "value": value}}
+			}
+			err := ws.WriteJSON(map[string]any{"id": req.ID, "sessionId": req.SessionID, "result": result})
+			if err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/devtools/browser/test"
+}
+
+func TestBrowserTool_Session(t *testing.T) {
+	pageText := "# Test\n[1 link \"Next\" -> /next]\n" + strings.Repeat("x", browserMaxChars)
+	tool := NewBrowserTool(config.BrowserToolsConfig{CDPURL: startFakeBrowser(t, pageText)})
+	defer tool.Close()
+	store := media.NewFileMediaStore()
+	tool.SetMediaStore(store)
+	tool.SetContext("telegram", "42")
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"action": "navigate", "url": "https://example.com/"})
+	if result.IsError {
+		t.Fatalf("navigate error: %s", result.ForLLM)
+	}
+	for _, want := range []string{
+		"Page: Test page",
+		"URL: https://example.com/",
+		`[1 link "Next" -> /next]`,
+		"offset=20000",
+	} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("navigate result misses %q:\n%s", want, result.ForLLM[:200])
+		}
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "snapshot", "offset": 20000.0})
+	if result.IsError || !strings.Contains(result.ForLLM, "Showing characters 20000-") ||
+		strings.Contains(result.ForLLM, "Next") {
+		t.Errorf("snapshot with offset = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "click", "ref": 1.0})
+	if result.IsError {
+		t.Errorf("click error: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "evaluate", "expression": "document.links.length"})
+	if result.IsError || result.ForLLM != "3" {
+		t.Errorf("evaluate = %+v", result)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "screenshot"})
+	if result.IsError || len(result.Media) != 1 {
+		t.Fatalf("screenshot = %+v", result)
+	}
+	if _, meta, err := store.ResolveWithMeta(result.Media[0]); err != nil || meta.ContentType != "image/png" {
+		t.Errorf("stored screenshot: %+v, %v", meta, err)
+	}
+
+	// Other chats have no tab yet.
+	tool.SetContext("telegram", "7")
+	if result := tool.Execute(ctx, map[string]any{"action": "snapshot"}); !result.IsError {
+		t.Errorf("snapshot in another chat = %q, want an error", result.ForLLM)
+	}
+
+	tool.SetContext("telegram", "42")
+	if result := tool.Execute(ctx, map[string]any{"action": "close"}); result.ForLLM != "Browser tab closed" {
+		t.Errorf("close = %q", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "snapshot"}); !result.IsError {
+		t.Errorf("snapshot after close = %q, want an error", result.ForLLM)
+	}
+}
+
+func TestBrowserTool_IdleCleanup(t *testing.T) {
+	tool := NewBrowserTool(config.BrowserToolsConfig{CDPURL: startFakeBrowser(t, "page"), IdleTimeout: 60})
+	defer tool.Close()
+	tool.SetContext("cli", "direct")
+
+	if result := tool.Execute(context.Background(), map[string]any{
+		"action": "navigate",
+		"url":    "https://example.com/",
+	}); result.IsError {
+		t.Fatalf("navigate error: %s", result.ForLLM)
+	}
+
+	tool.closeIdle(time.Now().Add(30 * time.Second))
+	if len(tool.tabs) != 1 || tool.browser == nil {
+		t.Fatal("tab closed before the idle timeout")
+	}
+	tool.closeIdle(time.Now().Add(61 * time.Second))
+	if len(tool.tabs) != 0 || tool.browser != nil {
+		t.Errorf("idle tab or browser left: %d tabs, browser %v", len(tool.tabs), tool.browser != nil)
+	}
+
+	// The browser is started again on demand.
+	if result := tool.Execute(context.Background(), map[string]any{
+		"action": "navigate",
+		"url":    "https://example.com/",
+	}); result.IsError {
+		t.Errorf("navigate after cleanup error: %s", result.ForLLM)
+	}
+}
+
+func TestBrowserTool_Rejects(t *testing.T) {
+	tool := NewBrowserTool(config.BrowserToolsConfig{CDPURL: "ws://127.0.0.1:1/devtools/browser/x"})
+	defer tool.Close()
+	for name, args := range map[string]map[string]any{
+		"unknown action":  {"action": "scroll"},
+		"no url":          {"action": "navigate"},
+		"no ref":          {"action": "click"},
+		"bad ref":         {"action": "click", "ref": 1.5},
+		"no text":         {"action": "type", "ref": 2.0},
+		"no expression":   {"action": "evaluate", "expression": " "},
+		"no page":         {"action": "snapshot"},
+		"blocked address": {"action": "navigate", "url": "http://169.254.169.254/"},
+	} {
+		if result := tool.Execute(context.Background(), args); !result.IsError {
+			t.Errorf("%s: expected an error, got %q", name, result.ForLLM)
+		}
+	}
+
+	// Nothing listens at the CDP URL.
+	result := tool.Execute(context.Background(), map[string]any{"action": "navigate", "url": "https://example.com/"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "browser unavailable") {
+		t.Errorf("result = %+v", result)
+	}
+}
@@ -2,13 +2,17 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
@@ -23,6 +27,7 @@ type CronTool struct {
 	executor    JobExecutor
 	msgBus      *bus.MessageBus
 	execTool    *ExecTool
+	defaultTZ   string
 	channel     string
 	chatID      string
 	mu          sync.RWMutex
@@ -36,12 +41,16 @@ func NewCronTool(
 ) *CronTool {
 	execTool := NewExecToolWithConfig(workspace, restrict, config)
 	execTool.SetTimeout(execTimeout)
-	return &CronTool{
+	t := &CronTool{
 		cronService: cronService,
 		executor:    executor,
 		msgBus:      msgBus,
 		execTool:    execTool,
 	}
+	if config != nil {
+		t.defaultTZ = config.Tools.Cron.Timezone
+	}
+	return t
 }
 
 // Name returns the tool name
@@ -51,7 +60,7 @@ func (t *CronTool) Name() string {
 
 // Description returns the tool description
 func (t *CronTool) Description() string {
-	return "Schedule reminders, tasks, or system commands. IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). Use 'cron_expr' for complex recurring schedules. Use 'command' to execute shell commands directly."
+	return "Schedule reminders, tasks, or system commands. IMPORTANT: When user asks to be reminded or scheduled, you MUST call this tool. Use 'at_seconds' for one-time reminders (e.g., 'remind me in 10 minutes' → at_seconds=600). Use 'every_seconds' ONLY for recurring tasks (e.g., 'every 2 hours' → every_seconds=7200). Use 'cron_expr' for complex recurring schedules; it is evaluated in the user's time zone. When the user mentions where they live or their time zone, save it once with action='set_timezone' and tz (e.g., 'Europe/Berlin'). Use 'command' to execute shell commands directly. Use 'history' with job_id to see a job's recent runs and errors."
 }
 
 // Parameters returns the tool parameters schema
@@ -61,7 +70,7 @@ func (t *CronTool) Parameters() map[string]any {
 		"properties": map[string]any{
 			"action": map[string]any{
 				"type":        "string",
-				"enum":        []string{"add", "list", "remove", "enable", "disable"},
+				"enum":        []string{"add", "list", "remove", "enable", "disable", "set_timezone", "history"},
 				"description": "Action to perform. Use 'add' when user wants to schedule a reminder or task.",
 			},
 			"message": map[string]any{
@@ -84,9 +93,39 @@ func (t *CronTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Cron expression for complex recurring schedules (e.g., '0 9 * * *' for daily at 9am). Use this for complex recurring schedules.",
 			},
+			"tz": map[string]any{
+				"type":        "string",
+				"description": "IANA time zone such as 'America/New_York'. For add: overrides the user's time zone for cron_expr. For set_timezone: the user's time zone (empty clears it).",
+			},
+			"misfire": map[string]any{
+				"type":        "string",
+				"enum":        []string{cron.MisfireSkip, cron.MisfireRunOnce, cron.MisfireRunAll},
+				"description": "What to do with runs missed while the device was off: skip them, run once to catch up (default), or run each missed run.",
+			},
+			"misfire_limit": map[string]any{
+				"type":        "integer",
+				"description": "With misfire=run_all: the most missed runs to catch up (default 10).",
+			},
+			"retries": map[string]any{
+				"type":        "integer",
+				"description": "Optional: how many times to retry a failed run, with exponential backoff. Default: 0",
+			},
+			"retry_backoff_seconds": map[string]any{
+				"type":        "integer",
+				"description": "Optional: delay before the first retry, doubled for each further retry. Default: 30",
+			},
+			"timeout_seconds": map[string]any{
+				"type":        "integer",
+				"description": "Optional: stop a run that takes longer than this. Default: the configured job timeout",
+			},
+			"overlap": map[string]any{
+				"type":        "string",
+				"enum":        []string{cron.OverlapSkip, cron.OverlapQueue, cron.OverlapAllow},
+				"description": "What to do when the job is due while its previous run is still going: skip the new run (default), queue it, or allow both at once.",
+			},
 			"job_id": map[string]any{
 				"type":        "string",
-				"description": "Job ID (for remove/enable/disable)",
+				"description": "Job ID (for remove/enable/disable/history)",
 			},
 			"deliver": map[string]any{
 				"type":        "boolean",
@@ -116,13 +155,17 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	case "add":
 		return t.addJob(args)
 	case "list":
-		return t.listJobs()
+		return t.listJobs(nil)
 	case "remove":
 		return t.removeJob(args)
 	case "enable":
 		return t.enableJob(args, true)
 	case "disable":
 		return t.enableJob(args, false)
+	case "set_timezone":
+		return t.setTimezone(args)
+	case "history":
+		return t.jobHistory(args)
 	default:
 		return ErrorResult(fmt.Sprintf("unknown action: %s", action))
 	}
@@ -164,14 +207,27 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 			EveryMS: &everyMS,
 		}
 	} else if hasCron {
+		tz, _ := args["tz"].(string)
+		if tz == "" {
+			tz = t.cronService.Timezone(channel, chatID)
+		}
+		if tz == "" {
+			tz = t.defaultTZ
+		}
 		schedule = cron.CronSchedule{
 			Kind: "cron",
 			Expr: cronExpr,
+			TZ:   tz,
 		}
 	} else {
 		return ErrorResult("one of at_seconds, every_seconds, or cron_expr is required")
 	}
 
+	schedule.Misfire, _ = args["misfire"].(string)
+	if limit, ok := args["misfire_limit"].(float64); ok {
+		schedule.MisfireLimit = int(limit)
+	}
+
 	// Read deliver parameter, default to true
 	deliver := true
 	if d, ok := args["deliver"].(bool); ok {
@@ -202,17 +258,72 @@ func (t *CronTool) addJob(args map[string]any) *ToolResult {
 		return ErrorResult(fmt.Sprintf("Error adding job: %v", err))
 	}
 
+	retries, _ := args["retries"].(float64)
+	if retries > 0 {
+		job.Retry = &cron.RetryPolicy{MaxRetries: int(retries)}
+		if backoff, ok := args["retry_backoff_seconds"].(float64); ok && backoff > 0 {
+			job.Retry.BackoffMS = int64(backoff * 1000)
+		}
+	}
 	if command != "" {
 		job.Payload.Command = command
+	}
+	if timeout, ok := args["timeout_seconds"].(float64); ok && timeout > 0 {
+		job.TimeoutSec = int(timeout)
+	}
+	job.Overlap, _ = args["overlap"].(string)
+	if command != "" || job.Retry != nil || job.TimeoutSec > 0 || job.Overlap != "" {
 		// Need to save the updated payload
-		t.cronService.UpdateJob(job)
+		if err := t.cronService.UpdateJob(job); err != nil {
+			t.cronService.RemoveJob(job.ID)
+			return ErrorResult(fmt.Sprintf("Error adding job: %v", err))
+		}
 	}
 
-	return SilentResult(fmt.Sprintf("Cron job added: %s (id: %s)", job.Name, job.ID))
+	result := fmt.Sprintf("Cron job added: %s (id: %s)", job.Name, job.ID)
+	if job.State.NextRunAtMS != nil {
+		result += fmt.Sprintf(", next run %s", formatNextRun(job))
+	}
+	return SilentResult(result)
 }
 
-func (t *CronTool) listJobs() *ToolResult {
-	jobs := t.cronService.ListJobs(false)
+func (t *CronTool) setTimezone(args map[string]any) *ToolResult {
+	t.mu.RLock()
+	channel := t.channel
+	chatID := t.chatID
+	t.mu.RUnlock()
+
+	if channel == "" || chatID == "" {
+		return ErrorResult("no session context (channel/chat_id not set). Use this tool in an active conversation.")
+	}
+
+	tz, _ := args["tz"].(string)
+	if err := t.cronService.SetTimezone(channel, chatID, tz); err != nil {
+		return ErrorResult(fmt.Sprintf("Error setting time zone: %v", err)).WithError(err)
+	}
+	if tz == "" {
+		return SilentResult("Time zone cleared; cron expressions use the default time zone")
+	}
+	return SilentResult(fmt.Sprintf("Time zone set to %s for new cron jobs in this chat", tz))
+}
+
+// formatNextRun renders the job's next run in the job's time zone.
+func formatNextRun(job *cron.CronJob) string {
+	next := time.UnixMilli(*job.State.NextRunAtMS)
+	if loc, err := cron.LoadLocation(job.Schedule.TZ); err == nil {
+		next = next.In(loc)
+	}
+	return next.Format("2006-01-02 15:04 MST")
+}
+
+// listJobs lists the enabled jobs that match, or all of them when match is nil.
+func (t *CronTool) listJobs(match func(*cron.CronJob) bool) *ToolResult {
+	var jobs []cron.CronJob
+	for _, j := range t.cronService.ListJobs(false) {
+		if match == nil || match(&j) {
+			jobs = append(jobs, j)
+		}
+	}
 
 	if len(jobs) == 0 {
 		return SilentResult("No scheduled jobs")
@@ -225,17 +336,104 @@ func (t *CronTool) listJobs() *ToolResult {
 			scheduleInfo = fmt.Sprintf("every %ds", *j.Schedule.EveryMS/1000)
 		} else if j.Schedule.Kind == "cron" {
 			scheduleInfo = j.Schedule.Expr
+			if j.Schedule.TZ != "" {
+				scheduleInfo += " " + j.Schedule.TZ
+			}
 		} else if j.Schedule.Kind == "at" {
 			scheduleInfo = "one-time"
 		} else {
 			scheduleInfo = "unknown"
 		}
+		if j.State.NextRunAtMS != nil {
+			scheduleInfo += ", next " + formatNextRun(&j)
+		}
 		result += fmt.Sprintf("- %s (id: %s, %s)\n", j.Name, j.ID, scheduleInfo)
 	}
 
 	return SilentResult(result)
 }
 
+func (t *CronTool) jobHistory(args map[string]any) *ToolResult {
+	jobID, ok := args["job_id"].(string)
+	if !ok || jobID == "" {
+		return ErrorResult("job_id is required for history")
+	}
+
+	runs, found := t.cronService.History(jobID)
+	if !found {
+		return ErrorResult(fmt.Sprintf("Job %s not found", jobID))
+	}
+	if len(runs) == 0 {
+		return SilentResult(fmt.Sprintf("Job %s has not run yet", jobID))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Recent runs of %s (newest first):\n", jobID)
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		fmt.Fprintf(&sb, "- %s %s in %s",
+			time.UnixMilli(run.StartedAtMS).Format("2006-01-02 15:04:05"), run.Status,
+			time.Duration(run.DurationMS)*time.Millisecond)
+		if run.Attempt > 0 {
+			fmt.Fprintf(&sb, " (retry %d)", run.Attempt)
+		}
+		if run.PromptTokens > 0 || run.CompletionTokens > 0 {
+			fmt.Fprintf(&sb, ", %d+%d tokens", run.PromptTokens, run.CompletionTokens)
+		}
+		if run.Error != "" {
+			fmt.Fprintf(&sb, "\n  error: %s", run.Error)
+		} else if run.Output != "" {
+			fmt.Fprintf(&sb, "\n  output: %s", utils.Truncate(run.Output, 200))
+		}
+		sb.WriteString("\n")
+	}
+	return SilentResult(sb.String())
+}
+
+// HandleCommand serves the /cron chat command sent from channel/chatID. It
+// only lists and changes jobs that deliver to that chat. authorize, when
+// set, is asked before the command runs its tool call, so the approval
+// policy for cron applies to the command too.
+func (t *CronTool) HandleCommand(
+	ctx context.Context,
+	channel, chatID string,
+	args []string,
+	authorize func(args map[string]any) error,
+) string {
+	usage := "Usage: /cron [list|history <id>|enable <id>|disable <id>|remove <id>]"
+	if len(args) == 0 {
+		return usage
+	}
+	inChat := func(j *cron.CronJob) bool {
+		return j.Payload.Channel == channel && j.Payload.To == chatID
+	}
+	toolArgs := map[string]any{"action": args[0]}
+	switch args[0] {
+	case "list":
+	case "history", "enable", "disable", "remove":
+		if len(args) < 2 {
+			return usage
+		}
+		toolArgs["job_id"] = args[1]
+		if !slices.ContainsFunc(t.cronService.ListJobs(true), func(j cron.CronJob) bool {
+			return j.ID == args[1] && inChat(&j)
+		}) {
+			return fmt.Sprintf("Job %s not found", args[1])
+		}
+	default:
+		return usage
+	}
+	if authorize != nil {
+		if err := authorize(toolArgs); err != nil {
+			return fmt.Sprintf("/cron %s was not run: %v", args[0], err)
+		}
+	}
+	if args[0] == "list" {
+		return t.listJobs(inChat).ForLLM
+	}
+	return t.Execute(ctx, toolArgs).ForLLM
+}
+
 func (t *CronTool) removeJob(args map[string]any) *ToolResult {
 	jobID, ok := args["job_id"].(string)
 	if !ok || jobID == "" {
@@ -266,8 +464,9 @@ func (t *CronTool) enableJob(args map[string]any, enable bool) *ToolResult {
 	return SilentResult(fmt.Sprintf("Cron job '%s' %s", job.Name, status))
 }
 
-// ExecuteJob executes a cron job through the agent
-func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
+// ExecuteJob executes a cron job through the agent and reports its output
+// and token usage to the cron service.
+func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) (cron.JobResult, error) {
 	// Get channel/chatID from job payload
 	channel := job.Payload.Channel
 	chatID := job.Payload.To
@@ -288,8 +487,10 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 
 		result := t.execTool.Execute(ctx, args)
 		var output string
+		var err error
 		if result.IsError {
 			output = fmt.Sprintf("Error executing scheduled command: %s", result.ForLLM)
+			err = errors.New(result.ForLLM)
 		} else {
 			output = fmt.Sprintf("Scheduled command '%s' executed:\n%s", job.Payload.Command, result.ForLLM)
 		}
@@ -301,25 +502,26 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 			ChatID:  chatID,
 			Content: output,
 		})
-		return "ok"
+		return cron.JobResult{Output: result.ForLLM}, err
 	}
 
 	// If deliver=true, send message directly without agent processing
 	if job.Payload.Deliver {
 		pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer pubCancel()
-		t.msgBus.PublishOutbound(pubCtx, bus.OutboundMessage{
+		err := t.msgBus.PublishOutbound(pubCtx, bus.OutboundMessage{
 			Channel: channel,
 			ChatID:  chatID,
 			Content: job.Payload.Message,
 		})
-		return "ok"
+		return cron.JobResult{Output: job.Payload.Message}, err
 	}
 
 	// For deliver=false, process through agent (for complex tasks)
 	sessionKey := fmt.Sprintf("cron-%s", job.ID)
 
-	// Call agent with job's message
+	// Call agent with job's message; the response is sent via MessageBus by AgentLoop
+	ctx, usage := providers.WithUsageCounter(ctx)
 	response, err := t.executor.ProcessDirectWithChannel(
 		ctx,
 		job.Payload.Message,
@@ -327,11 +529,26 @@ func (t *CronTool) ExecuteJob(ctx context.Context, job *cron.CronJob) string {
 		channel,
 		chatID,
 	)
-	if err != nil {
-		return fmt.Sprintf("Error: %v", err)
-	}
+	result := cron.JobResult{Output: response}
+	result.PromptTokens, result.CompletionTokens = usage.Tokens()
+	return result, err
+}
 
-	// Response is automatically sent via MessageBus by AgentLoop
-	_ = response // Will be sent by AgentLoop
-	return "ok"
+// SendAlert tells the alert chat, or the job's own chat, that a job keeps
+// failing. channel and chatID are the configured alert target.
+func (t *CronTool) SendAlert(job *cron.CronJob, failures int, channel, chatID string) {
+	if channel == "" || chatID == "" {
+		channel, chatID = job.Payload.Channel, job.Payload.To
+	}
+	if channel == "" || chatID == "" {
+		return
+	}
+	pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer pubCancel()
+	t.msgBus.PublishOutbound(pubCtx, bus.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: fmt.Sprintf("⚠️ Scheduled job '%s' (%s) has failed %d times in a row. Last error: %s",
+			job.Name, job.ID, failures, job.State.LastError),
+	})
 }
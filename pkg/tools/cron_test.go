@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+func TestCronTool_HandleCommandScopedToChat(t *testing.T) {
+	service := cron.NewCronService(filepath.Join(t.TempDir(), "jobs.json"), nil)
+	every := int64(3600_000)
+	schedule := cron.CronSchedule{Kind: "every", EveryMS: &every}
+	mine, err := service.AddJob("mine", schedule, "hello", true, "telegram", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := service.AddJob("other", schedule, "secret", true, "telegram", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := NewCronTool(service, nil, nil, t.TempDir(), true, 0, nil)
+	ctx := context.Background()
+
+	list := tool.HandleCommand(ctx, "telegram", "1", []string{"list"}, nil)
+	if !strings.Contains(list, mine.ID) || strings.Contains(list, other.ID) {
+		t.Errorf("list shows other chats' jobs: %s", list)
+	}
+	for _, action := range []string{"history", "disable", "remove"} {
+		got := tool.HandleCommand(ctx, "telegram", "1", []string{action, other.ID}, nil)
+		if got != "Job "+other.ID+" not found" {
+			t.Errorf("/cron %s on another chat's job = %q", action, got)
+		}
+	}
+	if jobs := service.ListJobs(false); len(jobs) != 2 {
+		t.Fatalf("jobs = %d, want 2", len(jobs))
+	}
+
+	deny := func(map[string]any) error { return errors.New("denied by policy") }
+	if got := tool.HandleCommand(ctx, "telegram", "1", []string{"remove", mine.ID}, deny); !strings.Contains(got, "denied") {
+		t.Errorf("denied remove = %q", got)
+	}
+	if got := tool.HandleCommand(ctx, "telegram", "1", []string{"remove", mine.ID}, nil); !strings.Contains(got, "removed") {
+		t.Errorf("remove = %q", got)
+	}
+}
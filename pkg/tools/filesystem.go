@@ -1,13 +1,16 @@
 package tools
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/fileutil"
 )
@@ -83,8 +86,12 @@ func isWithinWorkspace(candidate, workspace string) bool {
 	return err == nil && filepath.IsLocal(rel)
 }
 
+// defaultReadFileMaxBytes caps how much of a file read_file returns at once.
+const defaultReadFileMaxBytes = 64 * 1024
+
 type ReadFileTool struct {
-	fs fileSystem
+	fs       fileSystem
+	maxBytes int
 }
 
 func NewReadFileTool(workspace string, restrict bool) *ReadFileTool {
@@ -94,7 +101,7 @@ func NewReadFileTool(workspace string, restrict bool) *ReadFileTool {
 	} else {
 		fs = &hostFs{}
 	}
-	return &ReadFileTool{fs: fs}
+	return &ReadFileTool{fs: fs, maxBytes: defaultReadFileMaxBytes}
 }
 
 func (t *ReadFileTool) Name() string {
@@ -102,7 +109,8 @@ func (t *ReadFileTool) Name() string {
 }
 
 func (t *ReadFileTool) Description() string {
-	return "Read the contents of a file"
+	return fmt.Sprintf("Read the contents of a file. At most %d KB are returned per call; "+
+		"use offset and limit to read large files in parts.", t.maxBytes/1024)
 }
 
 func (t *ReadFileTool) Parameters() map[string]any {
@@ -113,6 +121,14 @@ func (t *ReadFileTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Path to the file to read",
 			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Line number to start reading from (1-based, default 1)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of lines to read (default: until the size cap)",
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -124,11 +140,102 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("path is required")
 	}
 
-	content, err := t.fs.ReadFile(path)
+	offset := 1
+	if v, ok := args["offset"].(float64); ok && v > 1 {
+		offset = int(v)
+	}
+	limit := 0
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
+	}
+
+	f, err := t.fs.Open(path)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
-	return NewToolResult(string(content))
+	defer f.Close()
+
+	maxBytes := t.maxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultReadFileMaxBytes
+	}
+	content, err := readLineRange(f, offset, limit, maxBytes)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	return NewToolResult(content)
+}
+
+// readLineRange returns up to limit lines (0 means no limit) starting at the
+// 1-based line offset, cut at maxBytes. When the result does not reach the
+// end of the file, a notice says where to continue. The rest of the file is
+// still scanned to report its line count.
+func readLineRange(r io.Reader, offset, limit, maxBytes int) (string, error) {
+	br := bufio.NewReader(r)
+	var out strings.Builder
+	line, first, last := 0, 0, 0
+	inLine, full, cutLine := false, false, false
+
+	for {
+		frag, err := br.ReadSlice('\n')
+		if len(frag) > 0 {
+			if !inLine {
+				line++
+			}
+			inRange := line >= offset && (limit == 0 || line < offset+limit)
+			if inRange && !full {
+				if first == 0 {
+					first = line
+				}
+				last = line
+				if remaining := maxBytes - out.Len(); len(frag) > remaining {
+					out.Write(validUTF8Prefix(frag[:remaining]))
+					full, cutLine = true, true
+				} else {
+					out.Write(frag)
+					full = out.Len() >= maxBytes
+				}
+			}
+			inLine = frag[len(frag)-1] != '\n'
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	total := line
+	if offset > 1 && offset > total {
+		return "", fmt.Errorf("offset %d is beyond the end of the file (%d lines)", offset, total)
+	}
+	if last == total && !cutLine {
+		return out.String(), nil
+	}
+
+	next := last + 1
+	if cutLine {
+		next = last
+	}
+	content := strings.TrimSuffix(out.String(), "\n")
+	if full {
+		return fmt.Sprintf("%s\n\n[Output truncated at %d bytes in line %d of %d. Use offset=%d to continue.]",
+			content, maxBytes, last, total, next), nil
+	}
+	return fmt.Sprintf("%s\n\n[Showing lines %d-%d of %d. Use offset=%d to continue.]",
+		content, first, last, total, next), nil
+}
+
+// validUTF8Prefix drops a multi-byte character cut off at the end of b.
+func validUTF8Prefix(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if utf8.Valid(b) {
+			return b
+		}
+		b = b[:len(b)-1]
+	}
+	return b
 }
 
 type WriteFileTool struct {
@@ -254,6 +361,13 @@ type fileSystem interface {
 	ReadFile(path string) ([]byte, error)
 	WriteFile(path string, data []byte) error
 	ReadDir(path string) ([]os.DirEntry, error)
+	// Open opens a file for streaming reads.
+	Open(path string) (fs.File, error)
+	// WalkDir walks the tree at root like filepath.WalkDir. Paths passed to
+	// fn start with root, so they can be given back to Open.
+	WalkDir(root string, fn fs.WalkDirFunc) error
+	// Remove deletes a file.
+	Remove(path string) error
 }
 
 // hostFs is an unrestricted fileReadWriter that operates directly on the host filesystem.
@@ -277,6 +391,28 @@ func (h *hostFs) ReadDir(path string) ([]os.DirEntry, error) {
 	return os.ReadDir(path)
 }
 
+func (h *hostFs) Open(path string) (fs.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read file: file not found: %w", err)
+		}
+		if os.IsPermission(err) {
+			return nil, fmt.Errorf("failed to read file: access denied: %w", err)
+		}
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return f, nil
+}
+
+func (h *hostFs) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, fn)
+}
+
+func (h *hostFs) Remove(path string) error {
+	return os.Remove(path)
+}
+
 func (h *hostFs) WriteFile(path string, data []byte) error {
 	// Use unified atomic write utility with explicit sync for flash storage reliability.
 	// Using 0o600 (owner read/write only) for secure default permissions.
@@ -312,15 +448,7 @@ func (r *sandboxFs) ReadFile(path string) ([]byte, error) {
 	err := r.execute(path, func(root *os.Root, relPath string) error {
 		fileContent, err := root.ReadFile(relPath)
 		if err != nil {
-			if os.IsNotExist(err) {
-				return fmt.Errorf("failed to read file: file not found: %w", err)
-			}
-			// os.Root returns "escapes from parent" for paths outside the root
-			if os.IsPermission(err) || strings.Contains(err.Error(), "escapes from parent") ||
-				strings.Contains(err.Error(), "permission denied") {
-				return fmt.Errorf("failed to read file: access denied: %w", err)
-			}
-			return fmt.Errorf("failed to read file: %w", err)
+			return sandboxReadError(err)
 		}
 		content = fileContent
 		return nil
@@ -328,6 +456,52 @@ func (r *sandboxFs) ReadFile(path string) ([]byte, error) {
 	return content, err
 }
 
+func sandboxReadError(err error) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("failed to read file: file not found: %w", err)
+	}
+	// os.Root returns "escapes from parent" for paths outside the root
+	if os.IsPermission(err) || strings.Contains(err.Error(), "escapes from parent") ||
+		strings.Contains(err.Error(), "permission denied") {
+		return fmt.Errorf("failed to read file: access denied: %w", err)
+	}
+	return fmt.Errorf("failed to read file: %w", err)
+}
+
+// Open opens path through os.Root; the file stays usable after the root is
+// closed.
+func (r *sandboxFs) Open(path string) (fs.File, error) {
+	var file fs.File
+	err := r.execute(path, func(root *os.Root, relPath string) error {
+		f, err := root.Open(relPath)
+		if err != nil {
+			return sandboxReadError(err)
+		}
+		file = f
+		return nil
+	})
+	return file, err
+}
+
+func (r *sandboxFs) Remove(path string) error {
+	return r.execute(path, func(root *os.Root, relPath string) error {
+		return root.Remove(relPath)
+	})
+}
+
+func (r *sandboxFs) WalkDir(rootPath string, fn fs.WalkDirFunc) error {
+	return r.execute(rootPath, func(root *os.Root, relPath string) error {
+		walkRoot := filepath.ToSlash(relPath)
+		return fs.WalkDir(root.FS(), walkRoot, func(p string, d fs.DirEntry, err error) error {
+			sub, relErr := filepath.Rel(walkRoot, p)
+			if relErr != nil {
+				return relErr
+			}
+			return fn(filepath.Join(rootPath, sub), d, err)
+		})
+	})
+}
+
 func (r *sandboxFs) WriteFile(path string, data []byte) error {
 	return r.execute(path, func(root *os.Root, relPath string) error {
 		dir := filepath.Dir(relPath)
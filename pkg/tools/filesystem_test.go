@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -486,3 +487,43 @@ func TestRootRW_Write(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, newData, content)
 }
+
+func TestFilesystemTool_ReadFile_OffsetLimit(t *testing.T) {
+	workspace := t.TempDir()
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	os.WriteFile(filepath.Join(workspace, "log.txt"), []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+	tool := NewReadFileTool(workspace, true)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"path": "log.txt", "offset": float64(3), "limit": float64(2)})
+	want := "line 3\nline 4\n\n[Showing lines 3-4 of 10. Use offset=5 to continue.]"
+	if result.IsError || result.ForLLM != want {
+		t.Errorf("ForLLM = %q, want %q", result.ForLLM, want)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"path": "log.txt", "offset": float64(9)})
+	if result.ForLLM != "line 9\nline 10\n" {
+		t.Errorf("reading to the end should not add a notice: %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"path": "log.txt", "offset": float64(11)})
+	if !result.IsError || !strings.Contains(result.ForLLM, "beyond the end") {
+		t.Errorf("offset past EOF = %q", result.ForLLM)
+	}
+}
+
+func TestFilesystemTool_ReadFile_ByteCap(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "big.txt"), []byte("aaaa\nbbbb\nééééé\ndddd\n"), 0o644)
+	tool := NewReadFileTool(workspace, true)
+	tool.maxBytes = 13
+
+	result := tool.Execute(context.Background(), map[string]any{"path": "big.txt"})
+	want := "aaaa\nbbbb\n\xc3\xa9\n\n[Output truncated at 13 bytes in line 3 of 4. Use offset=3 to continue.]"
+	if result.ForLLM != want {
+		t.Errorf("ForLLM = %q, want %q", result.ForLLM, want)
+	}
+}
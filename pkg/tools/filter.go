@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// toolGroups maps the group names accepted in an agent's tools.allow and
+// tools.deny lists to the tool name patterns they stand for.
+var toolGroups = map[string][]string{
+	"fs": {
+		"read_file", "write_file", "edit_file", "append_file", "apply_patch",
+		"list_dir", "grep", "glob", "file_history",
+	},
+	"web":      {"web_search", "web_fetch", "http_request", "browser"},
+	"hardware": {"i2c", "spi", "gpio", "pwm", "adc", "serial", "mqtt_publish"},
+	"exec":     {"exec", "process"},
+	"agents":   {"spawn", "subagent"},
+	"skills":   {"find_skills", "install_skill"},
+	"mcp":      {"mcp_*"},
+}
+
+// ToolFilter decides which tools an agent may use. Entries are tool names,
+// path.Match wildcards such as "mcp_github_*", or group names such as "fs".
+// A tool is allowed when it matches no deny entry and, if the allow list is
+// not empty, at least one allow entry.
+type ToolFilter struct {
+	allow        []string
+	deny         []string
+	allowPattern []string
+	denyPattern  []string
+}
+
+// NewToolFilter builds a filter from allow and deny lists. It returns an
+// error for malformed wildcards.
+func NewToolFilter(allow, deny []string) (*ToolFilter, error) {
+	f := &ToolFilter{allow: cleanEntries(allow), deny: cleanEntries(deny)}
+	var err error
+	if f.allowPattern, err = expandToolEntries(f.allow); err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	if f.denyPattern, err = expandToolEntries(f.deny); err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	return f, nil
+}
+
+// Allows reports whether the tool may be used. A nil filter allows every tool.
+func (f *ToolFilter) Allows(name string) bool {
+	if f == nil {
+		return true
+	}
+	if matchesAny(f.denyPattern, name) {
+		return false
+	}
+	return len(f.allowPattern) == 0 || matchesAny(f.allowPattern, name)
+}
+
+// Rules returns the allow and deny entries as configured.
+func (f *ToolFilter) Rules() (allow, deny []string) {
+	if f == nil {
+		return nil, nil
+	}
+	return f.allow, f.deny
+}
+
+func cleanEntries(entries []string) []string {
+	var out []string
+	for _, entry := range entries {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+func expandToolEntries(entries []string) ([]string, error) {
+	var patterns []string
+	for _, entry := range entries {
+		if group, ok := toolGroups[entry]; ok {
+			patterns = append(patterns, group...)
+			continue
+		}
+		if _, err := path.Match(entry, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", entry, err)
+		}
+		patterns = append(patterns, entry)
+	}
+	return patterns, nil
+}
+
+func matchesAny(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package tools
+
+import "testing"
+
+func TestToolFilter_Allows(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow, deny []string
+		allowed     []string
+		denied      []string
+	}{
+		{
+			name:    "nil lists allow everything",
+			allowed: []string{"exec", "read_file", "mcp_github_search"},
+		},
+		{
+			name:    "deny group",
+			deny:    []string{"exec", "hardware"},
+			allowed: []string{"read_file", "web_fetch", "message"},
+			denied:  []string{"exec", "process", "i2c", "spi", "serial"},
+		},
+		{
+			name:    "allow groups and names",
+			allow:   []string{"web", " Message "},
+			allowed: []string{"web_search", "browser", "message"},
+			denied:  []string{"exec", "read_file", "cron"},
+		},
+		{
+			name:    "deny wins over allow",
+			allow:   []string{"fs", "exec"},
+			deny:    []string{"write_file", "process"},
+			allowed: []string{"read_file", "exec"},
+			denied:  []string{"write_file", "process", "web_fetch"},
+		},
+		{
+			name:    "wildcards",
+			allow:   []string{"mcp_github_*", "*_file"},
+			deny:    []string{"mcp_github_delete*"},
+			allowed: []string{"mcp_github_search", "read_file", "edit_file"},
+			denied:  []string{"mcp_github_delete_repo", "mcp_slack_post", "list_dir"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewToolFilter(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatalf("NewToolFilter() error: %v", err)
+			}
+			for _, name := range tt.allowed {
+				if !f.Allows(name) {
+					t.Errorf("Allows(%q) = false, want true", name)
+				}
+			}
+			for _, name := range tt.denied {
+				if f.Allows(name) {
+					t.Errorf("Allows(%q) = true, want false", name)
+				}
+			}
+		})
+	}
+
+	var nilFilter *ToolFilter
+	if !nilFilter.Allows("exec") {
+		t.Error("nil filter denied a tool")
+	}
+}
+
+func TestNewToolFilter_InvalidPattern(t *testing.T) {
+	if _, err := NewToolFilter(nil, []string{"exec["}); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
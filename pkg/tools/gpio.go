@@ -0,0 +1,423 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	gpioDefaultWait = 10 * time.Second
+	gpioMaxWait     = 60 * time.Second
+)
+
+var gpioChipName = regexp.MustCompile(`^gpiochip\d+$`)
+
+// gpioChip is an open GPIO character device.
+type gpioChip interface {
+	Info() (label string, lines int, err error)
+	LineInfo(offset int) (gpioLineInfo, error)
+	Request(offset int, cfg gpioLineConfig) (gpioLine, error)
+	Close() error
+}
+
+// gpioLine is a requested line; Close releases it.
+type gpioLine interface {
+	Value() (int, error)
+	SetValue(value int) error
+	// WaitEdge returns the edge events seen before timeout or ctx ends.
+	WaitEdge(ctx context.Context, timeout time.Duration) ([]gpioEdge, error)
+	Close() error
+}
+
+type gpioLineInfo struct {
+	Name      string `json:"name,omitempty"`
+	Consumer  string `json:"consumer,omitempty"`
+	Used      bool   `json:"used"`
+	Direction string `json:"direction"`
+	ActiveLow bool   `json:"active_low,omitempty"`
+	Bias      string `json:"bias,omitempty"`
+}
+
+type gpioLineConfig struct {
+	Output     bool
+	Value      int // initial output value
+	ActiveLow  bool
+	Bias       string // "", "pull_up", "pull_down" or "disabled"
+	Edge       string // "", "rising", "falling" or "both"
+	DebounceUS int
+}
+
+type gpioEdge struct {
+	Edge        string `json:"edge"`
+	TimestampNS uint64 `json:"timestamp_ns"`
+	Seqno       uint32 `json:"seqno"`
+}
+
+// GPIOTool reads and drives GPIO lines through the Linux GPIO character
+// device (uAPI v2). Only the lines allowed in the config can be used. Lines
+// written as outputs stay requested, and so keep their value, until released.
+type GPIOTool struct {
+	allowed  map[string][]int // chip name -> line offsets
+	openChip func(name string) (gpioChip, error)
+
+	mu   sync.Mutex
+	held map[string]gpioLine // "chip:line" -> output line
+}
+
+// NewGPIOTool creates a GPIO tool limited to the given lines, keyed by chip
+// name (e.g. "gpiochip0").
+func NewGPIOTool(allowed map[string][]int) *GPIOTool {
+	return &GPIOTool{
+		allowed:  allowed,
+		openChip: openGPIOChip,
+		held:     make(map[string]gpioLine),
+	}
+}
+
+func (t *GPIOTool) Name() string {
+	return "gpio"
+}
+
+func (t *GPIOTool) Description() string {
+	return "Read and control GPIO lines (relays, LEDs, buttons) through the Linux GPIO character device. Actions: list (allowed lines and their state), read (line value), write (drive a line as output; it stays driven until released), wait (wait for an edge on an input), release (stop driving a line). Only lines allowed in the config can be used. Linux only."
+}
+
+func (t *GPIOTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "read", "write", "wait", "release"},
+				"description": "Action to perform",
+			},
+			"chip": map[string]any{
+				"type":        "string",
+				"description": "GPIO chip name, e.g. \"gpiochip0\". Required except for list.",
+			},
+			"line": map[string]any{
+				"type":        "integer",
+				"description": "Line offset on the chip. Required except for list.",
+			},
+			"value": map[string]any{
+				"type":        "integer",
+				"enum":        []int{0, 1},
+				"description": "Value to drive (write only)",
+			},
+			"active_low": map[string]any{
+				"type":        "boolean",
+				"description": "Treat the line as active low, so 1 drives it low",
+			},
+			"bias": map[string]any{
+				"type":        "string",
+				"enum":        []string{"pull_up", "pull_down", "disabled"},
+				"description": "Bias for read, write or wait; the line's current bias is kept when unset",
+			},
+			"edge": map[string]any{
+				"type":        "string",
+				"enum":        []string{"rising", "falling", "both"},
+				"description": "Edge to wait for (wait only). Default: both.",
+			},
+			"timeout_ms": map[string]any{
+				"type":        "integer",
+				"description": "How long to wait for an edge, up to 60000. Default: 10000.",
+			},
+			"debounce_us": map[string]any{
+				"type":        "integer",
+				"description": "Debounce period in microseconds for wait, e.g. 5000 for a push button",
+			},
+			"confirm": map[string]any{
+				"type":        "boolean",
+				"description": "Must be true for write operations. Safety guard to prevent accidental writes.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *GPIOTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "list":
+		return t.list()
+	case "read":
+		return t.read(args)
+	case "write":
+		return t.write(args)
+	case "wait":
+		return t.wait(ctx, args)
+	case "release":
+		return t.release(args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, read, write, wait, release)", action))
+	}
+}
+
+// list reports the allowed lines of every allowed chip.
+func (t *GPIOTool) list() *ToolResult {
+	type lineEntry struct {
+		Chip string `json:"chip"`
+		Line int    `json:"line"`
+		gpioLineInfo
+		Held  bool   `json:"held,omitempty"`
+		Error string `json:"error,omitempty"`
+	}
+
+	chips := make([]string, 0, len(t.allowed))
+	for chip := range t.allowed {
+		chips = append(chips, chip)
+	}
+	sort.Strings(chips)
+
+	var entries []lineEntry
+	for _, chip := range chips {
+		c, err := t.open(chip)
+		if err != nil {
+			entries = append(entries, lineEntry{Chip: chip, Line: -1, Error: err.Error()})
+			continue
+		}
+		for _, offset := range t.allowed[chip] {
+			entry := lineEntry{Chip: chip, Line: offset, Held: t.isHeld(chip, offset)}
+			if info, err := c.LineInfo(offset); err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.gpioLineInfo = info
+			}
+			entries = append(entries, entry)
+		}
+		c.Close()
+	}
+
+	if len(entries) == 0 {
+		return SilentResult("No GPIO lines are allowed. Add them to tools.gpio.lines in the config.")
+	}
+	result, _ := json.MarshalIndent(entries, "", "  ")
+	return SilentResult(fmt.Sprintf("Allowed GPIO lines:\n%s", string(result)))
+}
+
+// read returns the value of a line. Lines this tool drives report their
+// output value; others are read as inputs.
+func (t *GPIOTool) read(args map[string]any) *ToolResult {
+	chip, offset, errResult := t.parseLine(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if l, ok := t.held[gpioKey(chip, offset)]; ok {
+		value, err := l.Value()
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to read %s line %d: %v", chip, offset, err))
+		}
+		return SilentResult(fmt.Sprintf("%s line %d = %d (output)", chip, offset, value))
+	}
+
+	cfg, errResult := parseGPIOConfig(args)
+	if errResult != nil {
+		return errResult
+	}
+	l, errResult := t.request(chip, offset, cfg)
+	if errResult != nil {
+		return errResult
+	}
+	defer l.Close()
+
+	value, err := l.Value()
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read %s line %d: %v", chip, offset, err))
+	}
+	return SilentResult(fmt.Sprintf("%s line %d = %d", chip, offset, value))
+}
+
+// write drives a line as an output and keeps it requested.
+func (t *GPIOTool) write(args map[string]any) *ToolResult {
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		return ErrorResult(
+			"write operations require confirm: true. Please confirm with the user before driving GPIO lines, as they may switch connected equipment.",
+		)
+	}
+
+	chip, offset, errResult := t.parseLine(args)
+	if errResult != nil {
+		return errResult
+	}
+	v, ok := args["value"].(float64)
+	if !ok || (v != 0 && v != 1) {
+		return ErrorResult("value is required and must be 0 or 1")
+	}
+	value := int(v)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := gpioKey(chip, offset)
+	if l, ok := t.held[key]; ok {
+		if err := l.SetValue(value); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to set %s line %d: %v", chip, offset, err))
+		}
+		return SilentResult(fmt.Sprintf("Set %s line %d to %d", chip, offset, value))
+	}
+
+	cfg, errResult := parseGPIOConfig(args)
+	if errResult != nil {
+		return errResult
+	}
+	cfg.Output = true
+	cfg.Value = value
+	l, errResult := t.request(chip, offset, cfg)
+	if errResult != nil {
+		return errResult
+	}
+	t.held[key] = l
+	return SilentResult(fmt.Sprintf(
+		"Set %s line %d to %d. The line stays driven until released with action release.", chip, offset, value))
+}
+
+// wait blocks until an edge on an input line or the timeout.
+func (t *GPIOTool) wait(ctx context.Context, args map[string]any) *ToolResult {
+	chip, offset, errResult := t.parseLine(args)
+	if errResult != nil {
+		return errResult
+	}
+	if t.isHeld(chip, offset) {
+		return ErrorResult(fmt.Sprintf("%s line %d is driven as an output; release it first", chip, offset))
+	}
+
+	cfg, errResult := parseGPIOConfig(args)
+	if errResult != nil {
+		return errResult
+	}
+	cfg.Edge = "both"
+	if edge, ok := args["edge"].(string); ok && edge != "" {
+		if edge != "rising" && edge != "falling" && edge != "both" {
+			return ErrorResult("edge must be rising, falling or both")
+		}
+		cfg.Edge = edge
+	}
+	if d, ok := args["debounce_us"].(float64); ok {
+		if d < 0 || d > 1e6 {
+			return ErrorResult("debounce_us must be between 0 and 1000000")
+		}
+		cfg.DebounceUS = int(d)
+	}
+	timeout := gpioDefaultWait
+	if ms, ok := args["timeout_ms"].(float64); ok && ms > 0 {
+		timeout = min(time.Duration(ms)*time.Millisecond, gpioMaxWait)
+	}
+
+	l, errResult := t.request(chip, offset, cfg)
+	if errResult != nil {
+		return errResult
+	}
+	defer l.Close()
+
+	edges, err := l.WaitEdge(ctx, timeout)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to wait for %s line %d: %v", chip, offset, err))
+	}
+	if len(edges) == 0 {
+		return SilentResult(fmt.Sprintf("No %s edge on %s line %d within %s", cfg.Edge, chip, offset, timeout))
+	}
+	result, _ := json.MarshalIndent(edges, "", "  ")
+	return SilentResult(fmt.Sprintf("%d edge(s) on %s line %d:\n%s", len(edges), chip, offset, string(result)))
+}
+
+// release stops driving a line written earlier.
+func (t *GPIOTool) release(args map[string]any) *ToolResult {
+	chip, offset, errResult := t.parseLine(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := gpioKey(chip, offset)
+	l, ok := t.held[key]
+	if !ok {
+		return SilentResult(fmt.Sprintf("%s line %d is not driven by this tool", chip, offset))
+	}
+	delete(t.held, key)
+	if err := l.Close(); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to release %s line %d: %v", chip, offset, err))
+	}
+	return SilentResult(fmt.Sprintf("Released %s line %d", chip, offset))
+}
+
+func (t *GPIOTool) open(chip string) (gpioChip, error) {
+	if !gpioChipName.MatchString(chip) {
+		return nil, fmt.Errorf("invalid chip name %q (expected e.g. gpiochip0)", chip)
+	}
+	return t.openChip(chip)
+}
+
+// request opens chip and requests one of its lines.
+func (t *GPIOTool) request(chip string, offset int, cfg gpioLineConfig) (gpioLine, *ToolResult) {
+	c, err := t.open(chip)
+	if err != nil {
+		return nil, ErrorResult(fmt.Sprintf("failed to open %s: %v (check permissions)", chip, err))
+	}
+	defer c.Close()
+
+	l, err := c.Request(offset, cfg)
+	if err != nil {
+		return nil, ErrorResult(fmt.Sprintf("failed to request %s line %d: %v (is it used by another driver?)",
+			chip, offset, err))
+	}
+	return l, nil
+}
+
+func (t *GPIOTool) isHeld(chip string, offset int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.held[gpioKey(chip, offset)]
+	return ok
+}
+
+// parseLine extracts chip and line from args and checks them against the
+// allowlist.
+func (t *GPIOTool) parseLine(args map[string]any) (string, int, *ToolResult) {
+	chip, ok := args["chip"].(string)
+	if !ok || chip == "" {
+		return "", 0, ErrorResult("chip is required (e.g. \"gpiochip0\")")
+	}
+	line, ok := args["line"].(float64)
+	if !ok || line < 0 || line != float64(int(line)) {
+		return "", 0, ErrorResult("line is required and must be a non-negative integer")
+	}
+	offset := int(line)
+	if !slices.Contains(t.allowed[chip], offset) {
+		return "", 0, ErrorResult(fmt.Sprintf(
+			"%s line %d is not allowed. Allowed lines are listed under tools.gpio.lines in the config.", chip, offset))
+	}
+	return chip, offset, nil
+}
+
+func parseGPIOConfig(args map[string]any) (gpioLineConfig, *ToolResult) {
+	var cfg gpioLineConfig
+	cfg.ActiveLow, _ = args["active_low"].(bool)
+	if bias, ok := args["bias"].(string); ok && bias != "" {
+		if bias != "pull_up" && bias != "pull_down" && bias != "disabled" {
+			return cfg, ErrorResult("bias must be pull_up, pull_down or disabled")
+		}
+		cfg.Bias = bias
+	}
+	return cfg, nil
+}
+
+func gpioKey(chip string, offset int) string {
+	return fmt.Sprintf("%s:%d", chip, offset)
+}
@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// GPIO uAPI v2 ioctls from <linux/gpio.h>:
+//
+//	_IOR/_IOWR(0xB4, nr, struct)
+const (
+	gpioGetChipInfo     = 0x8044B401 // _IOR(0xB4, 0x01, struct gpiochip_info)
+	gpioV2GetLineInfo   = 0xC100B405 // _IOWR(0xB4, 0x05, struct gpio_v2_line_info)
+	gpioV2GetLine       = 0xC250B407 // _IOWR(0xB4, 0x07, struct gpio_v2_line_request)
+	gpioV2LineGetValues = 0xC010B40E // _IOWR(0xB4, 0x0E, struct gpio_v2_line_values)
+	gpioV2LineSetValues = 0xC010B40F // _IOWR(0xB4, 0x0F, struct gpio_v2_line_values)
+)
+
+// enum gpio_v2_line_flag
+const (
+	gpioV2LineFlagUsed         = 1 << 0
+	gpioV2LineFlagActiveLow    = 1 << 1
+	gpioV2LineFlagInput        = 1 << 2
+	gpioV2LineFlagOutput       = 1 << 3
+	gpioV2LineFlagEdgeRising   = 1 << 4
+	gpioV2LineFlagEdgeFalling  = 1 << 5
+	gpioV2LineFlagBiasPullUp   = 1 << 8
+	gpioV2LineFlagBiasPullDown = 1 << 9
+	gpioV2LineFlagBiasDisabled = 1 << 10
+)
+
+// enum gpio_v2_line_attr_id and enum gpio_v2_line_event_id
+const (
+	gpioV2LineAttrIDOutputValues = 2
+	gpioV2LineAttrIDDebounce     = 3
+
+	gpioV2LineEventRisingEdge = 1
+)
+
+// The structs below match the kernel layout; every 64-bit field is
+// naturally aligned, so they are the same size on 32- and 64-bit targets.
+
+type gpioChipInfoRaw struct {
+	name  [32]byte
+	label [32]byte
+	lines uint32
+}
+
+// gpioV2LineAttribute holds flags, output values or the debounce period in
+// value, depending on id. The debounce period is a u32 at the start of the
+// union, which is the low half of value on little-endian targets.
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64
+}
+
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [10]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	offsets         [64]uint32
+	consumer        [32]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+type gpioV2LineInfo struct {
+	name     [32]byte
+	consumer [32]byte
+	offset   uint32
+	numAttrs uint32
+	flags    uint64
+	attrs    [10]gpioV2LineAttribute
+	padding  [4]uint32
+}
+
+type gpioV2LineEvent struct {
+	timestampNS uint64
+	id          uint32
+	offset      uint32
+	seqno       uint32
+	lineSeqno   uint32
+	padding     [6]uint32
+}
+
+func gpioIoctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+type cdevChip struct {
+	fd int
+}
+
+// openGPIOChip opens /dev/<name>.
+func openGPIOChip(name string) (gpioChip, error) {
+	fd, err := unix.Open("/dev/"+name, unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &cdevChip{fd: fd}, nil
+}
+
+func (c *cdevChip) Info() (string, int, error) {
+	var info gpioChipInfoRaw
+	if err := gpioIoctl(c.fd, gpioGetChipInfo, unsafe.Pointer(&info)); err != nil {
+		return "", 0, err
+	}
+	return cString(info.label[:]), int(info.lines), nil
+}
+
+func (c *cdevChip) LineInfo(offset int) (gpioLineInfo, error) {
+	raw := gpioV2LineInfo{offset: uint32(offset)}
+	if err := gpioIoctl(c.fd, gpioV2GetLineInfo, unsafe.Pointer(&raw)); err != nil {
+		return gpioLineInfo{}, err
+	}
+	info := gpioLineInfo{
+		Name:      cString(raw.name[:]),
+		Consumer:  cString(raw.consumer[:]),
+		Used:      raw.flags&gpioV2LineFlagUsed != 0,
+		Direction: "input",
+		ActiveLow: raw.flags&gpioV2LineFlagActiveLow != 0,
+	}
+	if raw.flags&gpioV2LineFlagOutput != 0 {
+		info.Direction = "output"
+	}
+	switch {
+	case raw.flags&gpioV2LineFlagBiasPullUp != 0:
+		info.Bias = "pull_up"
+	case raw.flags&gpioV2LineFlagBiasPullDown != 0:
+		info.Bias = "pull_down"
+	case raw.flags&gpioV2LineFlagBiasDisabled != 0:
+		info.Bias = "disabled"
+	}
+	return info, nil
+}
+
+func (c *cdevChip) Request(offset int, cfg gpioLineConfig) (gpioLine, error) {
+	var req gpioV2LineRequest
+	req.offsets[0] = uint32(offset)
+	req.numLines = 1
+	copy(req.consumer[:len(req.consumer)-1], "picoclaw")
+
+	flags := uint64(gpioV2LineFlagInput)
+	if cfg.Output {
+		flags = gpioV2LineFlagOutput
+		req.config.attrs[0] = gpioV2LineConfigAttribute{
+			attr: gpioV2LineAttribute{id: gpioV2LineAttrIDOutputValues, value: uint64(cfg.Value & 1)},
+			mask: 1,
+		}
+		req.config.numAttrs = 1
+	}
+	if cfg.ActiveLow {
+		flags |= gpioV2LineFlagActiveLow
+	}
+	switch cfg.Bias {
+	case "pull_up":
+		flags |= gpioV2LineFlagBiasPullUp
+	case "pull_down":
+		flags |= gpioV2LineFlagBiasPullDown
+	case "disabled":
+		flags |= gpioV2LineFlagBiasDisabled
+	}
+	switch cfg.Edge {
+	case "rising":
+		flags |= gpioV2LineFlagEdgeRising
+	case "falling":
+		flags |= gpioV2LineFlagEdgeFalling
+	case "both":
+		flags |= gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+	}
+	if cfg.DebounceUS > 0 {
+		req.config.attrs[req.config.numAttrs] = gpioV2LineConfigAttribute{
+			attr: gpioV2LineAttribute{id: gpioV2LineAttrIDDebounce, value: uint64(cfg.DebounceUS)},
+			mask: 1,
+		}
+		req.config.numAttrs++
+	}
+	req.config.flags = flags
+
+	if err := gpioIoctl(c.fd, gpioV2GetLine, unsafe.Pointer(&req)); err != nil {
+		return nil, err
+	}
+	return &cdevLine{fd: int(req.fd)}, nil
+}
+
+func (c *cdevChip) Close() error {
+	return unix.Close(c.fd)
+}
+
+type cdevLine struct {
+	fd int
+}
+
+func (l *cdevLine) Value() (int, error) {
+	vals := gpioV2LineValues{mask: 1}
+	if err := gpioIoctl(l.fd, gpioV2LineGetValues, unsafe.Pointer(&vals)); err != nil {
+		return 0, err
+	}
+	return int(vals.bits & 1), nil
+}
+
+func (l *cdevLine) SetValue(value int) error {
+	vals := gpioV2LineValues{bits: uint64(value & 1), mask: 1}
+	return gpioIoctl(l.fd, gpioV2LineSetValues, unsafe.Pointer(&vals))
+}
+
+func (l *cdevLine) WaitEdge(ctx context.Context, timeout time.Duration) ([]gpioEdge, error) {
+	deadline := time.Now().Add(timeout)
+	fds := []unix.PollFd{{Fd: int32(l.fd), Events: unix.POLLIN}}
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 || ctx.Err() != nil {
+			return nil, nil
+		}
+		// Poll in short slices so cancellation is noticed.
+		n, err := unix.Poll(fds, int(min(remaining, 100*time.Millisecond).Milliseconds())+1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			return l.readEdges()
+		}
+	}
+}
+
+func (l *cdevLine) readEdges() ([]gpioEdge, error) {
+	var events [16]gpioV2LineEvent
+	size := int(unsafe.Sizeof(events[0]))
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&events[0])), len(events)*size)
+	n, err := unix.Read(l.fd, buf)
+	if err != nil {
+		return nil, fmt.Errorf("read events: %w", err)
+	}
+
+	edges := make([]gpioEdge, 0, n/size)
+	for _, ev := range events[:n/size] {
+		edge := gpioEdge{Edge: "falling", TimestampNS: ev.timestampNS, Seqno: ev.lineSeqno}
+		if ev.id == gpioV2LineEventRisingEdge {
+			edge.Edge = "rising"
+		}
+		edges = append(edges, edge)
+	}
+	return edges, nil
+}
+
+func (l *cdevLine) Close() error {
+	return unix.Close(l.fd)
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
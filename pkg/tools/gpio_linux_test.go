@@ -0,0 +1,23 @@
+package tools
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// TestGPIOStructSizes checks the uAPI structs against the sizes encoded in
+// the ioctl numbers.
+func TestGPIOStructSizes(t *testing.T) {
+	sizes := map[string][2]uintptr{
+		"gpiochip_info":        {unsafe.Sizeof(gpioChipInfoRaw{}), (gpioGetChipInfo >> 16) & 0x3FFF},
+		"gpio_v2_line_info":    {unsafe.Sizeof(gpioV2LineInfo{}), (gpioV2GetLineInfo >> 16) & 0x3FFF},
+		"gpio_v2_line_request": {unsafe.Sizeof(gpioV2LineRequest{}), (gpioV2GetLine >> 16) & 0x3FFF},
+		"gpio_v2_line_values":  {unsafe.Sizeof(gpioV2LineValues{}), (gpioV2LineGetValues >> 16) & 0x3FFF},
+		"gpio_v2_line_event":   {unsafe.Sizeof(gpioV2LineEvent{}), 48},
+	}
+	for name, s := range sizes {
+		if s[0] != s[1] {
+			t.Errorf("%s: size %d, want %d", name, s[0], s[1])
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tools
+
+import "errors"
+
+// openGPIOChip is a stub for non-Linux platforms.
+func openGPIOChip(name string) (gpioChip, error) {
+	return nil, errors.New("GPIO is only supported on Linux")
+}
@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGPIO simulates the lines of one chip.
+type fakeGPIO struct {
+	values    map[int]int
+	requested map[int]gpioLineConfig
+	edges     []gpioEdge
+}
+
+type fakeChip struct{ g *fakeGPIO }
+
+func (c *fakeChip) Info() (string, int, error) { return "fake", 32, nil }
+
+func (c *fakeChip) LineInfo(offset int) (gpioLineInfo, error) {
+	cfg, used := c.g.requested[offset]
+	info := gpioLineInfo{Used: used, Direction: "input"}
+	if cfg.Output {
+		info.Direction = "output"
+	}
+	return info, nil
+}
+
+func (c *fakeChip) Request(offset int, cfg gpioLineConfig) (gpioLine, error) {
+	if _, busy := c.g.requested[offset]; busy {
+		return nil, errors.New("device or resource busy")
+	}
+	c.g.requested[offset] = cfg
+	if cfg.Output {
+		c.g.values[offset] = cfg.Value
+	}
+	return &fakeLine{g: c.g, offset: offset}, nil
+}
+
+func (c *fakeChip) Close() error { return nil }
+
+type fakeLine struct {
+	g      *fakeGPIO
+	offset int
+}
+
+func (l *fakeLine) Value() (int, error) { return l.g.values[l.offset], nil }
+
+func (l *fakeLine) SetValue(value int) error {
+	l.g.values[l.offset] = value
+	return nil
+}
+
+func (l *fakeLine) WaitEdge(context.Context, time.Duration) ([]gpioEdge, error) {
+	return l.g.edges, nil
+}
+
+func (l *fakeLine) Close() error {
+	delete(l.g.requested, l.offset)
+	return nil
+}
+
+func newFakeGPIOTool() (*GPIOTool, *fakeGPIO) {
+	g := &fakeGPIO{values: map[int]int{5: 1}, requested: map[int]gpioLineConfig{}}
+	tool := NewGPIOTool(map[string][]int{"gpiochip0": {5, 6}})
+	tool.openChip = func(name string) (gpioChip, error) {
+		if name != "gpiochip0" {
+			return nil, errors.New("no such file or directory")
+		}
+		return &fakeChip{g: g}, nil
+	}
+	return tool, g
+}
+
+func TestGPIOAllowlist(t *testing.T) {
+	tool, _ := newFakeGPIOTool()
+	for _, args := range []map[string]any{
+		{"action": "read", "chip": "gpiochip0", "line": float64(7)},
+		{"action": "read", "chip": "gpiochip1", "line": float64(5)},
+		{"action": "write", "chip": "gpiochip0", "line": float64(7), "value": float64(1), "confirm": true},
+	} {
+		result := tool.Execute(context.Background(), args)
+		if !result.IsError || !strings.Contains(result.ForLLM, "not allowed") {
+			t.Errorf("%v: got %q, want not allowed", args, result.ForLLM)
+		}
+	}
+}
+
+func TestGPIOReadWriteRelease(t *testing.T) {
+	tool, g := newFakeGPIOTool()
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"action": "read", "chip": "gpiochip0", "line": float64(5)})
+	if result.IsError || !strings.Contains(result.ForLLM, "line 5 = 1") {
+		t.Fatalf("read: %q", result.ForLLM)
+	}
+	if len(g.requested) != 0 {
+		t.Error("read should release the line")
+	}
+
+	write := map[string]any{"action": "write", "chip": "gpiochip0", "line": float64(6), "value": float64(1)}
+	if result := tool.Execute(ctx, write); !result.IsError || !strings.Contains(result.ForLLM, "confirm") {
+		t.Errorf("write without confirm: %q", result.ForLLM)
+	}
+	write["confirm"] = true
+	if result := tool.Execute(ctx, write); result.IsError {
+		t.Fatalf("write: %q", result.ForLLM)
+	}
+	if !g.requested[6].Output || g.values[6] != 1 {
+		t.Fatalf("line 6 = %+v value %d, want a held output at 1", g.requested[6], g.values[6])
+	}
+
+	write["value"] = float64(0)
+	if result := tool.Execute(ctx, write); result.IsError || g.values[6] != 0 {
+		t.Fatalf("second write: %q, value %d", result.ForLLM, g.values[6])
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "read", "chip": "gpiochip0", "line": float64(6)})
+	if !strings.Contains(result.ForLLM, "= 0 (output)") {
+		t.Errorf("read of held line: %q", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "wait", "chip": "gpiochip0", "line": float64(6)})
+	if !result.IsError {
+		t.Error("wait on a held output should fail")
+	}
+
+	tool.Execute(ctx, map[string]any{"action": "release", "chip": "gpiochip0", "line": float64(6)})
+	if _, held := g.requested[6]; held {
+		t.Error("release should free the line")
+	}
+}
+
+func TestGPIOWait(t *testing.T) {
+	tool, g := newFakeGPIOTool()
+	args := map[string]any{
+		"action":      "wait",
+		"chip":        "gpiochip0",
+		"line":        float64(5),
+		"edge":        "falling",
+		"bias":        "pull_up",
+		"debounce_us": float64(5000),
+	}
+
+	result := tool.Execute(context.Background(), args)
+	if result.IsError || !strings.Contains(result.ForLLM, "No falling edge") {
+		t.Errorf("wait without events: %q", result.ForLLM)
+	}
+
+	g.edges = []gpioEdge{{Edge: "falling", TimestampNS: 1, Seqno: 1}}
+	result = tool.Execute(context.Background(), args)
+	if result.IsError || !strings.Contains(result.ForLLM, `"edge": "falling"`) {
+		t.Errorf("wait: %q", result.ForLLM)
+	}
+
+	args["bias"] = "weak"
+	if result := tool.Execute(context.Background(), args); !result.IsError {
+		t.Error("expected error for invalid bias")
+	}
+}
+
+func TestGPIOList(t *testing.T) {
+	tool, _ := newFakeGPIOTool()
+	tool.allowed["gpiochip9"] = []int{1}
+	result := tool.Execute(context.Background(), map[string]any{"action": "list"})
+	for _, want := range []string{`"chip": "gpiochip0"`, `"line": 6`, "no such file"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("list output missing %q:\n%s", want, result.ForLLM)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/history"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const defaultHistoryLogLimit = 20
+
+// versionedFs records every write and removal made through it in the
+// workspace file history, and keeps the tools from touching the history
+// itself.
+type versionedFs struct {
+	fileSystem
+	store  *history.Store
+	source string
+}
+
+func newVersionedFs(inner fileSystem, store *history.Store, source string) fileSystem {
+	if v, ok := inner.(*versionedFs); ok {
+		inner = v.fileSystem
+	}
+	return &versionedFs{fileSystem: inner, store: store, source: source}
+}
+
+// absPath resolves path the way the wrapped fileSystem does.
+func (v *versionedFs) absPath(path string) (string, error) {
+	if sandbox, ok := v.fileSystem.(*sandboxFs); ok {
+		rel, err := getSafeRelPath(sandbox.workspace, path)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(sandbox.workspace, rel), nil
+	}
+	return filepath.Abs(path)
+}
+
+func (v *versionedFs) WriteFile(path string, data []byte) error {
+	return v.change(path, data, false)
+}
+
+func (v *versionedFs) Remove(path string) error {
+	return v.change(path, nil, true)
+}
+
+func (v *versionedFs) change(path string, data []byte, remove bool) error {
+	abs, err := v.absPath(path)
+	if err == nil && v.store.IsHistoryPath(abs) {
+		return fmt.Errorf("%s is part of the file history and cannot be changed; use file_history", path)
+	}
+
+	old, readErr := v.fileSystem.ReadFile(path)
+	if remove {
+		err = v.fileSystem.Remove(path)
+	} else {
+		err = v.fileSystem.WriteFile(path, data)
+	}
+	if err != nil || abs == "" {
+		return err
+	}
+	if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+		return nil
+	}
+
+	change := history.Change{
+		Path:    abs,
+		Old:     old,
+		New:     data,
+		Created: readErr != nil,
+		Deleted: remove,
+		Source:  v.source,
+	}
+	if err := v.store.Record(change); err != nil {
+		logger.WarnCF("history", "Failed to record file version",
+			map[string]any{"path": path, "error": err.Error()})
+	}
+	return nil
+}
+
+// SetHistory records the files written by the tool in store.
+func (t *WriteFileTool) SetHistory(store *history.Store) {
+	t.fs = newVersionedFs(t.fs, store, t.Name())
+}
+
+// SetHistory records the files edited by the tool in store.
+func (t *EditFileTool) SetHistory(store *history.Store) {
+	t.fs = newVersionedFs(t.fs, store, t.Name())
+}
+
+// SetHistory records the files appended to by the tool in store.
+func (t *AppendFileTool) SetHistory(store *history.Store) {
+	t.fs = newVersionedFs(t.fs, store, t.Name())
+}
+
+// SetHistory records the files patched by the tool in store.
+func (t *ApplyPatchTool) SetHistory(store *history.Store) {
+	t.fs = newVersionedFs(t.fs, store, t.Name())
+}
+
+// FileHistoryTool lists, compares and restores earlier versions of files the
+// agent wrote.
+type FileHistoryTool struct {
+	fs    *versionedFs
+	store *history.Store
+}
+
+func NewFileHistoryTool(workspace string, restrict bool, store *history.Store) *FileHistoryTool {
+	var fs fileSystem
+	if restrict {
+		fs = &sandboxFs{workspace: workspace}
+	} else {
+		fs = &hostFs{}
+	}
+	return &FileHistoryTool{
+		fs:    &versionedFs{fileSystem: fs, store: store, source: "file_history"},
+		store: store,
+	}
+}
+
+func (t *FileHistoryTool) Name() string {
+	return "file_history"
+}
+
+func (t *FileHistoryTool) Description() string {
+	return "Earlier versions of workspace files written by write_file, edit_file, append_file and apply_patch. " +
+		"action=log lists versions, diff shows how a version differs from the current file, and " +
+		"restore brings it back. Without a version, diff and restore use the last version that differs " +
+		"from the current file, which undoes the most recent change."
+}
+
+func (t *FileHistoryTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"log", "diff", "restore"},
+				"description": "What to do (default: log)",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "The file; required for diff and restore, optional for log",
+			},
+			"version": map[string]any{
+				"type":        "integer",
+				"description": "Version number as shown by log (#N)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum versions to list (default %d)", defaultHistoryLogLimit),
+			},
+		},
+	}
+}
+
+func (t *FileHistoryTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	if action == "" {
+		action = "log"
+	}
+	path, _ := args["path"].(string)
+	var seq int64
+	if v, ok := args["version"].(float64); ok {
+		seq = int64(v)
+	}
+
+	rel := ""
+	if path != "" {
+		abs, err := t.fs.absPath(path)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		var ok bool
+		if rel, ok = t.store.Rel(abs); !ok {
+			return ErrorResult(fmt.Sprintf("%s is outside the workspace and has no history", path))
+		}
+	}
+
+	switch action {
+	case "log":
+		limit := defaultHistoryLogLimit
+		if v, ok := args["limit"].(float64); ok && v > 0 {
+			limit = int(v)
+		}
+		entries, err := t.store.Log(rel)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		return SilentResult(history.FormatLog(entries, rel == "", limit))
+	case "diff", "restore":
+		if path == "" {
+			return ErrorResult(fmt.Sprintf("path is required for %s", action))
+		}
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q; use log, diff or restore", action))
+	}
+
+	entries, err := t.store.Log(rel)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	current, readErr := t.fs.ReadFile(path)
+	if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+		return ErrorResult(readErr.Error())
+	}
+	entry, err := history.SelectVersion(entries, seq, current, readErr == nil)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("%s: %v", path, err))
+	}
+	content, err := t.store.Content(entry)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	if action == "diff" {
+		return SilentResult(history.Diff(entry, content, current, readErr == nil))
+	}
+	if entry.Deleted() {
+		err = t.fs.Remove(path)
+	} else {
+		err = t.fs.WriteFile(path, content)
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to restore %s: %v", path, err))
+	}
+	if entry.Deleted() {
+		return SilentResult(fmt.Sprintf("Restored %s to version #%d: the file was deleted", path, entry.Seq))
+	}
+	return SilentResult(fmt.Sprintf("Restored %s to version #%d (%s, %s)",
+		path, entry.Seq, entry.Source, entry.Time.Local().Format(time.DateTime)))
+}
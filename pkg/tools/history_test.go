@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/history"
+)
+
+func TestFileHistoryTool_LogDiffRestore(t *testing.T) {
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "MEMORY.md"), []byte("User likes tea.\n"), 0o644)
+	store := history.NewStore(workspace, config.FileHistoryConfig{MaxVersions: 10})
+	ctx := context.Background()
+
+	edit := NewEditFileTool(workspace, true)
+	edit.SetHistory(store)
+	write := NewWriteFileTool(workspace, true)
+	write.SetHistory(store)
+	tool := NewFileHistoryTool(workspace, true, store)
+
+	result := edit.Execute(ctx, map[string]any{"path": "MEMORY.md", "old_text": "tea", "new_text": "coffee"})
+	if result.IsError {
+		t.Fatal(result.ForLLM)
+	}
+	write.Execute(ctx, map[string]any{"path": "notes.md", "content": "draft\n"})
+
+	result = tool.Execute(ctx, map[string]any{"action": "log"})
+	lines := strings.Split(result.ForLLM, "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "write_file") || !strings.Contains(lines[0], "notes.md") ||
+		!strings.Contains(lines[2], "original") {
+		t.Errorf("log = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "diff", "path": "MEMORY.md"})
+	if !strings.Contains(result.ForLLM, "-User likes tea.\n+User likes coffee.\n") {
+		t.Errorf("diff = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "restore", "path": "MEMORY.md"})
+	if result.IsError || !strings.Contains(result.ForLLM, "version #1") {
+		t.Fatalf("restore = %q", result.ForLLM)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "MEMORY.md")); string(data) != "User likes tea.\n" {
+		t.Errorf("restored MEMORY.md = %q", data)
+	}
+
+	// Restoring again undoes the restore.
+	tool.Execute(ctx, map[string]any{"action": "restore", "path": "MEMORY.md"})
+	if data, _ := os.ReadFile(filepath.Join(workspace, "MEMORY.md")); string(data) != "User likes coffee.\n" {
+		t.Errorf("MEMORY.md after undoing the restore = %q", data)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "restore", "path": "notes.md", "version": float64(1)})
+	if !result.IsError {
+		t.Errorf("restoring a version of another file should fail: %q", result.ForLLM)
+	}
+}
+
+func TestFileHistoryTool_ProtectsHistory(t *testing.T) {
+	workspace := t.TempDir()
+	store := history.NewStore(workspace, config.FileHistoryConfig{})
+	write := NewWriteFileTool(workspace, true)
+	write.SetHistory(store)
+
+	result := write.Execute(context.Background(), map[string]any{
+		"path":    filepath.Join(history.Dir, "log.jsonl"),
+		"content": "",
+	})
+	if !result.IsError || !strings.Contains(result.ForLLM, "file history") {
+		t.Errorf("writing into the history should fail: %q", result.ForLLM)
+	}
+
+	tool := NewFileHistoryTool(workspace, true, store)
+	result = tool.Execute(context.Background(), map[string]any{"action": "diff", "path": "/etc/passwd"})
+	if !result.IsError {
+		t.Errorf("diff outside the workspace should fail: %q", result.ForLLM)
+	}
+}
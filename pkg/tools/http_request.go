@@ -0,0 +1,685 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/netpolicy"
+)
+
+// httpRequestMaxChars caps the response text returned to the model.
+const httpRequestMaxChars = 20000
+
+// redacted replaces secrets that show up in responses, such as APIs that
+// echo the request.
+const redacted = "[REDACTED]"
+
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// httpProfile is a credential profile ready for use.
+type httpProfile struct {
+	name      string
+	baseURL   *url.URL
+	endpoints []httpEndpoint
+	allowHTTP bool
+	headers   http.Header
+	query     url.Values
+	policy    *netpolicy.Policy
+	secrets   []string
+}
+
+// httpEndpoint is where a profile's credentials may be sent. An empty scheme
+// means https, or either scheme when the profile allows http; an empty port
+// means the scheme's default port.
+type httpEndpoint struct {
+	scheme string
+	host   string // "*." matches subdomains
+	port   string
+}
+
+func (e httpEndpoint) String() string {
+	s := e.host
+	if e.port != "" {
+		s = net.JoinHostPort(s, e.port)
+	}
+	if e.scheme != "" {
+		s = e.scheme + "://" + s
+	}
+	return s
+}
+
+// allows reports whether the profile's credentials may be sent to u.
+func (p *httpProfile) allows(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "https" && (scheme != "http" || !p.allowHTTP) {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(scheme)
+	}
+	for _, e := range p.endpoints {
+		if e.scheme != "" && e.scheme != scheme {
+			continue
+		}
+		if (e.port == "" && port != defaultPort(scheme)) || (e.port != "" && e.port != port) {
+			continue
+		}
+		if host == e.host || strings.HasPrefix(e.host, "*.") && strings.HasSuffix(host, e.host[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultPort(scheme string) string {
+	if scheme == "http" {
+		return "80"
+	}
+	return "443"
+}
+
+// HTTPRequestTool calls HTTP APIs with any method, headers and body.
+// Requests that name a profile get its credentials added by the tool, and
+// may only go to the profile's hosts. Other requests follow the agent's
+// network policy like web_fetch.
+type HTTPRequestTool struct {
+	policy   *netpolicy.Policy
+	profiles map[string]*httpProfile
+	maxBytes int64
+	timeout  time.Duration
+}
+
+// NewHTTPRequestTool creates the tool for agentID, with the profiles that
+// agent may use. network is the global policy, whose denied networks and
+// domains also apply to profiles.
+func NewHTTPRequestTool(
+	cfg config.HTTPRequestToolsConfig,
+	network config.NetworkConfig,
+	agentID string,
+) *HTTPRequestTool {
+	t := &HTTPRequestTool{
+		policy:   netpolicy.Default(),
+		profiles: make(map[string]*httpProfile),
+		maxBytes: int64(cfg.MaxResponseKB) * 1024,
+		timeout:  time.Duration(cfg.TimeoutSeconds) * time.Second,
+	}
+	if t.maxBytes <= 0 {
+		t.maxBytes = 1024 * 1024
+	}
+	if t.timeout <= 0 {
+		t.timeout = 30 * time.Second
+	}
+	for name, pc := range cfg.Profiles {
+		if len(pc.Agents) > 0 && !slices.Contains(pc.Agents, agentID) {
+			continue
+		}
+		profile, err := newHTTPProfile(name, pc, network)
+		if err != nil {
+			logger.ErrorCF("tool", "Invalid http_request profile, skipping it", map[string]any{
+				"profile": name,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		t.profiles[name] = profile
+	}
+	return t
+}
+
+func newHTTPProfile(name string, cfg config.HTTPProfileConfig, network config.NetworkConfig) (*httpProfile, error) {
+	p := &httpProfile{
+		name:      name,
+		allowHTTP: cfg.AllowHTTP,
+		headers:   make(http.Header),
+		query:     make(url.Values),
+	}
+	if cfg.BaseURL != "" {
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid base_url %q", cfg.BaseURL)
+		}
+		p.baseURL = u
+		if pw, ok := u.User.Password(); ok {
+			p.secrets = append(p.secrets, pw)
+		}
+	}
+	for _, h := range cfg.AllowedHosts {
+		e, err := parseEndpoint(h)
+		if err != nil {
+			return nil, err
+		}
+		if e.host != "" {
+			p.endpoints = append(p.endpoints, e)
+		}
+	}
+	if len(p.endpoints) == 0 {
+		if p.baseURL == nil {
+			return nil, errors.New("set base_url or allowed_hosts")
+		}
+		e, _ := parseEndpoint(p.baseURL.Scheme + "://" + p.baseURL.Host)
+		p.endpoints = []httpEndpoint{e}
+	}
+	for _, e := range p.endpoints {
+		if e.scheme == "http" && !cfg.AllowHTTP {
+			return nil, fmt.Errorf("%s uses http; set allow_http to send credentials unencrypted", e)
+		}
+	}
+
+	for k, v := range cfg.Headers {
+		p.headers.Set(k, v)
+		p.secrets = append(p.secrets, v)
+	}
+	if cfg.BearerToken != "" {
+		p.headers.Set("Authorization", "Bearer "+cfg.BearerToken)
+		p.secrets = append(p.secrets, cfg.BearerToken)
+	}
+	if cfg.Username != "" || cfg.Password != "" {
+		basic := base64.StdEncoding.EncodeToString([]byte(cfg.Username + ":" + cfg.Password))
+		p.headers.Set("Authorization", "Basic "+basic)
+		p.secrets = append(p.secrets, cfg.Password, basic)
+	}
+	for k, v := range cfg.Query {
+		p.query.Set(k, v)
+		p.secrets = append(p.secrets, v, url.QueryEscape(v))
+	}
+	// Short values would mangle unrelated text.
+	p.secrets = slices.DeleteFunc(p.secrets, func(s string) bool { return len(s) < 6 })
+
+	// The hosts were listed on purpose, so they may be on the LAN.
+	domains := make([]string, len(p.endpoints))
+	for i, e := range p.endpoints {
+		domains[i] = strings.TrimPrefix(e.host, "*.")
+	}
+	policy, err := netpolicy.New(config.NetworkConfig{
+		AllowPrivate:   true,
+		AllowedCIDRs:   network.AllowedCIDRs,
+		DeniedCIDRs:    network.DeniedCIDRs,
+		AllowedDomains: domains,
+		DeniedDomains:  network.DeniedDomains,
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.policy = policy
+	return p, nil
+}
+
+// parseEndpoint parses an allowed_hosts entry: "host", "host:port" or
+// "scheme://host[:port]".
+func parseEndpoint(s string) (httpEndpoint, error) {
+	var e httpEndpoint
+	s = strings.ToLower(strings.TrimSpace(s))
+	if scheme, rest, ok := strings.Cut(s, "://"); ok {
+		if scheme != "http" && scheme != "https" {
+			return e, fmt.Errorf("invalid allowed_hosts entry %q", s)
+		}
+		e.scheme, s = scheme, strings.TrimSuffix(rest, "/")
+	}
+	e.host = s
+	if host, port, err := net.SplitHostPort(s); err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return e, fmt.Errorf("invalid port in allowed_hosts entry %q", s)
+		}
+		e.host, e.port = host, port
+	}
+	e.host = strings.TrimSuffix(strings.Trim(e.host, "[]"), ".")
+	// An explicit default port is the same as none.
+	if e.scheme != "" && e.port == defaultPort(e.scheme) {
+		e.port = ""
+	}
+	return e, nil
+}
+
+// SetNetworkPolicy limits where requests without a profile may go. The
+// default policy blocks loopback, private, link-local and cloud metadata
+// addresses.
+func (t *HTTPRequestTool) SetNetworkPolicy(policy *netpolicy.Policy) {
+	if policy != nil {
+		t.policy = policy
+	}
+}
+
+func (t *HTTPRequestTool) Name() string {
+	return "http_request"
+}
+
+func (t *HTTPRequestTool) Description() string {
+	desc := "Send an HTTP request to an API with any method, headers and a JSON or text body, " +
+		"and return the status and response body. json_path extracts part of a JSON response, " +
+		"e.g. \"data.items[0].name\" or \"items[*].id\"."
+	if len(t.profiles) == 0 {
+		return desc
+	}
+	names := make([]string, 0, len(t.profiles))
+	for name := range t.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString(desc)
+	sb.WriteString(" Set profile to call an API with stored credentials, which are added automatically " +
+		"(never put secrets in headers yourself). Profiles:")
+	for _, name := range names {
+		p := t.profiles[name]
+		if p.baseURL != nil {
+			// Userinfo and query may hold credentials.
+			base := *p.baseURL
+			base.User, base.RawQuery = nil, ""
+			fmt.Fprintf(&sb, " %s (%s, url may be a path),", name, &base)
+		} else {
+			hosts := make([]string, len(p.endpoints))
+			for i, e := range p.endpoints {
+				hosts[i] = e.String()
+			}
+			fmt.Fprintf(&sb, " %s (%s),", name, strings.Join(hosts, ", "))
+		}
+	}
+	return strings.TrimSuffix(sb.String(), ",")
+}
+
+func (t *HTTPRequestTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"method": map[string]any{
+				"type":        "string",
+				"enum":        httpMethods,
+				"description": "HTTP method (default GET)",
+			},
+			"url": map[string]any{
+				"type":        "string",
+				"description": "Absolute URL, or a path relative to the profile's base URL",
+			},
+			"profile": map[string]any{
+				"type":        "string",
+				"description": "Credential profile to authenticate with",
+			},
+			"headers": map[string]any{
+				"type":                 "object",
+				"description":          "Additional request headers",
+				"additionalProperties": map[string]any{"type": "string"},
+			},
+			"query": map[string]any{
+				"type":                 "object",
+				"description":          "Query parameters to add to the URL",
+				"additionalProperties": map[string]any{"type": "string"},
+			},
+			"json": map[string]any{
+				"type":        "object",
+				"description": "JSON body; sets Content-Type: application/json",
+			},
+			"body": map[string]any{
+				"type":        "string",
+				"description": "Raw request body, when json is not used",
+			},
+			"json_path": map[string]any{
+				"type":        "string",
+				"description": "Path of the part of a JSON response to return, e.g. \"result.entities[0].state\"",
+			},
+		},
+		"required": []string{"url"},
+	}
+}
+
+func (t *HTTPRequestTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	method := "GET"
+	if m, ok := args["method"].(string); ok && m != "" {
+		method = strings.ToUpper(m)
+	}
+	if !slices.Contains(httpMethods, method) {
+		return ErrorResult(fmt.Sprintf("unsupported method %q", method))
+	}
+	rawURL, _ := args["url"].(string)
+	if rawURL == "" {
+		return ErrorResult("url is required")
+	}
+
+	var profile *httpProfile
+	if name, _ := args["profile"].(string); name != "" {
+		if profile = t.profiles[name]; profile == nil {
+			return ErrorResult(fmt.Sprintf("unknown profile %q", name))
+		}
+	}
+	target, err := t.resolveURL(rawURL, profile)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if q, ok := args["query"].(map[string]any); ok {
+		values := target.Query()
+		for k, v := range q {
+			values.Set(k, fmt.Sprint(v))
+		}
+		target.RawQuery = values.Encode()
+	}
+
+	policy := t.policy
+	if profile != nil {
+		policy = profile.policy
+		if !profile.allows(target) {
+			return ErrorResult(fmt.Sprintf("%s://%s is not allowed for profile %q",
+				target.Scheme, target.Host, profile.name))
+		}
+	}
+	if err := policy.CheckURL(target); err != nil {
+		return ErrorResult(err.Error()).WithError(err)
+	}
+
+	var body io.Reader
+	contentType := ""
+	if v, ok := args["json"]; ok && v != nil {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("invalid json body: %v", err))
+		}
+		body, contentType = bytes.NewReader(data), "application/json"
+	} else if s, ok := args["body"].(string); ok && s != "" {
+		body = strings.NewReader(s)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create request: %v", err))
+	}
+	req.Header.Set("User-Agent", "picoclaw")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if h, ok := args["headers"].(map[string]any); ok {
+		for k, v := range h {
+			if strings.EqualFold(k, "Host") {
+				continue
+			}
+			req.Header.Set(k, fmt.Sprint(v))
+		}
+	}
+	if profile != nil {
+		// Set last, so the model cannot replace them.
+		for k, vs := range profile.headers {
+			req.Header[k] = vs
+		}
+		q := req.URL.Query()
+		for k, vs := range profile.query {
+			q[k] = vs
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+
+	client, err := createPolicyClient(policy, "", t.timeout)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create HTTP client: %v", err))
+	}
+	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("stopped after 5 redirects")
+		}
+		// Credentials must not follow a redirect to another host.
+		if profile != nil && !profile.allows(r.URL) {
+			return fmt.Errorf("redirect to %s://%s is not allowed for profile %q",
+				r.URL.Scheme, r.URL.Host, profile.name)
+		}
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ErrorResult(t.redact(profile, fmt.Sprintf("request failed: %v", err))).WithError(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBytes+1))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read response: %v", err))
+	}
+	cutOff := int64(len(data)) > t.maxBytes
+	if cutOff {
+		data = data[:t.maxBytes]
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s\nHTTP %s", method, t.redact(profile, redactQuery(target, profile)), resp.Status)
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		fmt.Fprintf(&sb, " (%s)", ct)
+	}
+	if loc := resp.Header.Get("Location"); loc != "" {
+		fmt.Fprintf(&sb, "\nLocation: %s", t.redact(profile, loc))
+	}
+	if cutOff {
+		fmt.Fprintf(&sb, "\nThe response was cut off at the %d KB size limit.", t.maxBytes/1024)
+	}
+
+	text := string(data)
+	if path, _ := args["json_path"].(string); path != "" && !cutOff {
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return ErrorResult(fmt.Sprintf("%s\njson_path: the response is not JSON: %v", sb.String(), err))
+		}
+		value, err := jsonPath(doc, path)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("%s\n%v", sb.String(), err))
+		}
+		out, _ := json.MarshalIndent(value, "", "  ")
+		text = string(out)
+	} else if json.Valid(data) && len(data) > 0 {
+		var buf bytes.Buffer
+		if json.Indent(&buf, data, "", "  ") == nil {
+			text = buf.String()
+		}
+	}
+	text = t.redact(profile, text)
+	if runes := []rune(text); len(runes) > httpRequestMaxChars {
+		text = string(runes[:httpRequestMaxChars]) +
+			fmt.Sprintf("\n[truncated: showing %d of %d characters, use json_path to select less]",
+				httpRequestMaxChars, len(runes))
+	}
+	if text != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(text)
+	}
+	return SilentResult(sb.String())
+}
+
+// resolveURL makes rawURL absolute against the profile's base URL.
+func (t *HTTPRequestTool) resolveURL(rawURL string, profile *httpProfile) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %v", err)
+	}
+	if !u.IsAbs() {
+		if profile == nil || profile.baseURL == nil {
+			return nil, errors.New("url must be absolute unless the profile has a base URL")
+		}
+		base := *profile.baseURL
+		base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+		u = base.ResolveReference(&url.URL{
+			Path:     strings.TrimPrefix(u.Path, "/"),
+			RawQuery: u.RawQuery,
+		})
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("only http/https URLs are allowed")
+	}
+	if u.Host == "" {
+		return nil, errors.New("missing host in URL")
+	}
+	return u, nil
+}
+
+// redactQuery returns target without the query parameters the profile adds.
+func redactQuery(target *url.URL, profile *httpProfile) string {
+	if profile == nil || len(profile.query) == 0 {
+		return target.String()
+	}
+	u := *target
+	q := u.Query()
+	for k := range profile.query {
+		q.Del(k)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// redact replaces the profile's secrets in s.
+func (t *HTTPRequestTool) redact(profile *httpProfile, s string) string {
+	if profile == nil {
+		return s
+	}
+	for _, secret := range profile.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// jsonPathStep is one step of a JSON path: an object key, an array index
+// (negative counts from the end) or a wildcard over all elements.
+type jsonPathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses paths like "a.b[0].c", "$.items[*].name" and
+// `data["key with.dots"]`.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var steps []jsonPathStep
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			if s == "" || s[0] == '.' || s[0] == '[' {
+				return nil, fmt.Errorf("invalid json_path %q", path)
+			}
+			if s[0] == '*' {
+				steps = append(steps, jsonPathStep{wildcard: true})
+				s = s[1:]
+				continue
+			}
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json_path %q: missing ]", path)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid json_path %q: bad index [%s]", path, inner)
+				}
+				steps = append(steps, jsonPathStep{index: n, isIndex: true})
+			}
+			continue
+		}
+		end := strings.IndexAny(s, ".[")
+		if end < 0 {
+			end = len(s)
+		}
+		steps = append(steps, jsonPathStep{key: s[:end]})
+		s = s[end:]
+	}
+	return steps, nil
+}
+
+// jsonPath returns the part of doc at path. After a wildcard, the result is
+// the list of matches, and elements without the rest of the path are left
+// out.
+func jsonPath(doc any, path string) (any, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	values := []any{doc}
+	multi := false
+	for i, step := range steps {
+		var next []any
+		for _, v := range values {
+			switch {
+			case step.wildcard:
+				switch v := v.(type) {
+				case []any:
+					next = append(next, v...)
+				case map[string]any:
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				}
+			case step.isIndex:
+				arr, ok := v.([]any)
+				idx := step.index
+				if ok && idx < 0 {
+					idx += len(arr)
+				}
+				if !ok || idx < 0 || idx >= len(arr) {
+					if multi {
+						continue
+					}
+					return nil, fmt.Errorf("json_path %q: no element [%d] at %s", path, step.index, pathPrefix(steps[:i]))
+				}
+				next = append(next, arr[idx])
+			default:
+				obj, ok := v.(map[string]any)
+				value, found := obj[step.key]
+				if !ok || !found {
+					if multi {
+						continue
+					}
+					return nil, fmt.Errorf("json_path %q: no key %q at %s", path, step.key, pathPrefix(steps[:i]))
+				}
+				next = append(next, value)
+			}
+		}
+		multi = multi || step.wildcard
+		values = next
+	}
+	if multi {
+		if values == nil {
+			values = []any{}
+		}
+		return values, nil
+	}
+	return values[0], nil
+}
+
+// pathPrefix formats steps for error messages.
+func pathPrefix(steps []jsonPathStep) string {
+	var sb strings.Builder
+	sb.WriteString("$")
+	for _, s := range steps {
+		switch {
+		case s.wildcard:
+			sb.WriteString("[*]")
+		case s.isIndex:
+			fmt.Fprintf(&sb, "[%d]", s.index)
+		default:
+			sb.WriteString("." + s.key)
+		}
+	}
+	return sb.String()
+}
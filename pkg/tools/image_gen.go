@@ -0,0 +1,238 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// ImageGenerateTool creates or edits images with a model from model_list and
+// returns them as media refs, which the agent publishes to the chat.
+type ImageGenerateTool struct {
+	generator   providers.ImageGenerator
+	workspace   string
+	restrict    bool
+	defaultSize string
+	maxN        int
+
+	mu       sync.RWMutex
+	store    media.MediaStore
+	channel  string
+	chatID   string
+	mediaDir string
+}
+
+// NewImageGenerateTool creates the tool. maxN <= 0 allows a single image per call.
+func NewImageGenerateTool(
+	generator providers.ImageGenerator,
+	workspace string,
+	restrict bool,
+	defaultSize string,
+	maxN int,
+) *ImageGenerateTool {
+	if maxN <= 0 {
+		maxN = 1
+	}
+	return &ImageGenerateTool{
+		generator:   generator,
+		workspace:   workspace,
+		restrict:    restrict,
+		defaultSize: defaultSize,
+		maxN:        maxN,
+		mediaDir:    filepath.Join(os.TempDir(), "picoclaw_media"),
+	}
+}
+
+func (t *ImageGenerateTool) Name() string {
+	return "image_generate"
+}
+
+func (t *ImageGenerateTool) Description() string {
+	return "Generate an image from a text prompt, or edit/vary an existing image. " +
+		"Generated images are sent to the user automatically."
+}
+
+func (t *ImageGenerateTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"prompt": map[string]any{
+				"type":        "string",
+				"description": "Description of the image to create, or of the changes to make when editing",
+			},
+			"mode": map[string]any{
+				"type":        "string",
+				"enum":        []string{providers.ImageModeGenerate, providers.ImageModeEdit, providers.ImageModeVariation},
+				"description": "generate (default), edit (apply prompt to image) or variation (similar image)",
+			},
+			"image": map[string]any{
+				"type":        "string",
+				"description": "Source image for edit/variation: a media:// ref from the conversation or a workspace file path",
+			},
+			"size": map[string]any{
+				"type":        "string",
+				"description": "Image size such as 1024x1024, 1024x1536 or 1536x1024",
+			},
+			"n": map[string]any{
+				"type":        "integer",
+				"description": "Number of images to create (default 1)",
+			},
+		},
+		"required": []string{"prompt"},
+	}
+}
+
+func (t *ImageGenerateTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+// SetMediaStore sets the store generated images are registered in. Without
+// a store, images are only saved to disk and their paths returned.
+func (t *ImageGenerateTool) SetMediaStore(store media.MediaStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.store = store
+}
+
+func (t *ImageGenerateTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	prompt, _ := args["prompt"].(string)
+	mode, _ := args["mode"].(string)
+	imageArg, _ := args["image"].(string)
+	size, _ := args["size"].(string)
+
+	if mode == "" {
+		mode = providers.ImageModeGenerate
+		if imageArg != "" {
+			mode = providers.ImageModeEdit
+		}
+	}
+	if strings.TrimSpace(prompt) == "" && mode != providers.ImageModeVariation {
+		return ErrorResult("prompt is required")
+	}
+	if size == "" {
+		size = t.defaultSize
+	}
+
+	n := 1
+	if v, ok := args["n"].(float64); ok && v > 0 {
+		n = int(v)
+	}
+	if n > t.maxN {
+		n = t.maxN
+	}
+
+	t.mu.RLock()
+	store, channel, chatID := t.store, t.channel, t.chatID
+	t.mu.RUnlock()
+
+	req := providers.ImageRequest{Mode: mode, Prompt: prompt, Size: size, N: n}
+	if mode != providers.ImageModeGenerate {
+		if imageArg == "" {
+			return ErrorResult(fmt.Sprintf("image is required for mode %q", mode))
+		}
+		inputPath, err := t.resolveInput(imageArg, store)
+		if err != nil {
+			return ErrorResult(err.Error()).WithError(err)
+		}
+		req.InputImage = inputPath
+	}
+
+	images, err := t.generator.GenerateImages(ctx, req)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("image generation failed: %v", err)).WithError(err)
+	}
+
+	if err := os.MkdirAll(t.mediaDir, 0o700); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create media directory: %v", err)).WithError(err)
+	}
+
+	scope := fmt.Sprintf("tool:image_generate:%s:%s", channel, chatID)
+	var refs, paths, notes []string
+	for i, img := range images {
+		filename := fmt.Sprintf("image_%s%s", uuid.New().String()[:8], imageExtension(img.MimeType))
+		localPath := filepath.Join(t.mediaDir, filename)
+		if err := os.WriteFile(localPath, img.Data, 0o600); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to save image: %v", err)).WithError(err)
+		}
+		paths = append(paths, localPath)
+		if img.RevisedPrompt != "" {
+			notes = append(notes, fmt.Sprintf("image %d revised prompt: %s", i+1, img.RevisedPrompt))
+		}
+
+		if store == nil {
+			continue
+		}
+		ref, err := store.Store(localPath, media.MediaMeta{
+			Filename:    filename,
+			ContentType: img.MimeType,
+			Source:      "tool:image-gen",
+		}, scope)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to store image: %v", err)).WithError(err)
+		}
+		refs = append(refs, ref)
+	}
+
+	var sb strings.Builder
+	if store == nil {
+		fmt.Fprintf(&sb, "Generated %d image(s), saved to: %s", len(paths), strings.Join(paths, ", "))
+	} else {
+		fmt.Fprintf(&sb, "Generated %d image(s) and sent them to the user: %s", len(refs), strings.Join(refs, ", "))
+	}
+	for _, note := range notes {
+		sb.WriteString("\n")
+		sb.WriteString(note)
+	}
+
+	if store == nil {
+		return NewToolResult(sb.String())
+	}
+	return MediaResult(sb.String(), refs)
+}
+
+// resolveInput maps a media ref or workspace path to a local file path.
+func (t *ImageGenerateTool) resolveInput(image string, store media.MediaStore) (string, error) {
+	if strings.HasPrefix(image, "media://") {
+		if store == nil {
+			return "", fmt.Errorf("cannot resolve %s: no media store available", image)
+		}
+		path, err := store.Resolve(image)
+		if err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+
+	path, err := validatePath(image, t.workspace, t.restrict)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("image not found: %s", image)
+	}
+	return path, nil
+}
+
+func imageExtension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ".png"
+	}
+}
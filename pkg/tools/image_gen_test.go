@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// fakePNG is enough for content sniffing to report image/png.
+var fakePNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// newImageStandIn serves the OpenAI images API and records the last request.
+func newImageStandIn(t *testing.T, lastPath *string, lastForm *map[string]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*lastPath = r.URL.Path
+		n := 1
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("parse multipart: %v", err)
+			}
+			form := map[string]string{}
+			for k, v := range r.MultipartForm.Value {
+				form[k] = v[0]
+			}
+			if fh, ok := r.MultipartForm.File["image"]; ok {
+				f, _ := fh[0].Open()
+				data, _ := io.ReadAll(f)
+				f.Close()
+				form["image"] = string(data)
+			}
+			*lastForm = form
+		} else {
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			if v, ok := body["n"].(float64); ok {
+				n = int(v)
+			}
+			*lastForm = map[string]string{"prompt": body["prompt"].(string), "size": body["size"].(string)}
+		}
+
+		data := make([]map[string]any, 0, n)
+		for range n {
+			data = append(data, map[string]any{
+				"b64_json":       base64.StdEncoding.EncodeToString(fakePNG),
+				"revised_prompt": "a cute cat, watercolor",
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+}
+
+func newTestImageTool(t *testing.T, serverURL, workspace string) *ImageGenerateTool {
+	t.Helper()
+	gen, err := providers.CreateImageGeneratorFromConfig(&config.ModelConfig{
+		ModelName: "images",
+		Model:     "openai/gpt-image-1",
+		APIBase:   serverURL,
+		APIKey:    "test",
+	})
+	if err != nil {
+		t.Fatalf("CreateImageGeneratorFromConfig() error = %v", err)
+	}
+	tool := NewImageGenerateTool(gen, workspace, true, "1024x1024", 2)
+	tool.mediaDir = t.TempDir()
+	return tool
+}
+
+func TestImageGenerateTool_GenerateStoresMedia(t *testing.T) {
+	var path string
+	var form map[string]string
+	server := newImageStandIn(t, &path, &form)
+	defer server.Close()
+
+	tool := newTestImageTool(t, server.URL, t.TempDir())
+	store := media.NewFileMediaStore()
+	tool.SetMediaStore(store)
+	tool.SetContext("telegram", "42")
+
+	result := tool.Execute(context.Background(), map[string]any{"prompt": "a cat", "n": float64(5)})
+	if result.IsError {
+		t.Fatalf("Execute() error: %s", result.ForLLM)
+	}
+	if path != "/images/generations" {
+		t.Errorf("path = %q", path)
+	}
+	if form["prompt"] != "a cat" || form["size"] != "1024x1024" {
+		t.Errorf("request = %v", form)
+	}
+	// n is capped at maxN.
+	if len(result.Media) != 2 {
+		t.Fatalf("Media = %v, want 2 refs", result.Media)
+	}
+
+	localPath, meta, err := store.ResolveWithMeta(result.Media[0])
+	if err != nil {
+		t.Fatalf("ResolveWithMeta() error = %v", err)
+	}
+	if meta.ContentType != "image/png" || meta.Source != "tool:image-gen" {
+		t.Errorf("meta = %+v", meta)
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil || string(data) != string(fakePNG) {
+		t.Errorf("stored file = %q, %v", data, err)
+	}
+	if !strings.Contains(result.ForLLM, "revised prompt: a cute cat, watercolor") {
+		t.Errorf("ForLLM = %q", result.ForLLM)
+	}
+}
+
+func TestImageGenerateTool_EditFromMediaRef(t *testing.T) {
+	var path string
+	var form map[string]string
+	server := newImageStandIn(t, &path, &form)
+	defer server.Close()
+
+	tool := newTestImageTool(t, server.URL, t.TempDir())
+	store := media.NewFileMediaStore()
+	tool.SetMediaStore(store)
+
+	src := filepath.Join(t.TempDir(), "input.png")
+	os.WriteFile(src, []byte("source-image"), 0o644)
+	ref, err := store.Store(src, media.MediaMeta{Filename: "input.png"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"prompt": "add a hat", "image": ref})
+	if result.IsError {
+		t.Fatalf("Execute() error: %s", result.ForLLM)
+	}
+	if path != "/images/edits" {
+		t.Errorf("path = %q, want /images/edits (mode defaults to edit with an image)", path)
+	}
+	if form["prompt"] != "add a hat" || form["image"] != "source-image" || form["model"] != "gpt-image-1" {
+		t.Errorf("form = %v", form)
+	}
+	if len(result.Media) != 1 {
+		t.Errorf("Media = %v", result.Media)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"mode": "variation", "image": ref})
+	if result.IsError {
+		t.Fatalf("variation error: %s", result.ForLLM)
+	}
+	if path != "/images/variations" {
+		t.Errorf("path = %q, want /images/variations", path)
+	}
+}
+
+func TestImageGenerateTool_Errors(t *testing.T) {
+	var path string
+	var form map[string]string
+	server := newImageStandIn(t, &path, &form)
+	defer server.Close()
+
+	workspace := t.TempDir()
+	tool := newTestImageTool(t, server.URL, workspace)
+
+	if r := tool.Execute(context.Background(), map[string]any{}); !r.IsError {
+		t.Error("expected error without prompt")
+	}
+	if r := tool.Execute(context.Background(), map[string]any{"prompt": "x", "mode": "edit"}); !r.IsError {
+		t.Error("expected error for edit without image")
+	}
+	if r := tool.Execute(context.Background(), map[string]any{"prompt": "x", "image": "media://unknown"}); !r.IsError {
+		t.Error("expected error for media ref without store")
+	}
+	if r := tool.Execute(context.Background(), map[string]any{"prompt": "x", "image": "/etc/passwd"}); !r.IsError {
+		t.Error("expected error for path outside workspace")
+	}
+}
+
+func TestImageGenerateTool_NoStoreReturnsPaths(t *testing.T) {
+	var path string
+	var form map[string]string
+	server := newImageStandIn(t, &path, &form)
+	defer server.Close()
+
+	tool := newTestImageTool(t, server.URL, t.TempDir())
+	result := tool.Execute(context.Background(), map[string]any{"prompt": "a cat"})
+	if result.IsError {
+		t.Fatalf("Execute() error: %s", result.ForLLM)
+	}
+	if len(result.Media) != 0 {
+		t.Errorf("Media = %v, want none without a store", result.Media)
+	}
+	if !strings.Contains(result.ForLLM, tool.mediaDir) {
+		t.Errorf("ForLLM should contain the saved path: %q", result.ForLLM)
+	}
+}
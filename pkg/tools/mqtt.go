@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/mqttclient"
+)
+
+const mqttPublishTimeout = 15 * time.Second
+
+// MQTTPublishTool publishes messages to the MQTT broker configured under
+// channels.mqtt. It connects on first use and stays connected.
+type MQTTPublishTool struct {
+	cfg     config.MQTTConfig
+	allowed []string // topic filters; empty allows all topics
+
+	mu     sync.Mutex
+	client paho.Client
+}
+
+// NewMQTTPublishTool creates an mqtt_publish tool for the broker in cfg,
+// limited to topics matching the allowed filters.
+func NewMQTTPublishTool(cfg config.MQTTConfig, allowed []string) *MQTTPublishTool {
+	return &MQTTPublishTool{cfg: cfg, allowed: allowed}
+}
+
+func (t *MQTTPublishTool) Name() string {
+	return "mqtt_publish"
+}
+
+func (t *MQTTPublishTool) Description() string {
+	return "Publish a message to an MQTT topic, e.g. to switch a light (zigbee2mqtt/lamp/set with {\"state\":\"ON\"}) or send a command to a device. Set retain to make the broker keep the message as the topic's last known value. Only topics allowed in the config can be published to."
+}
+
+func (t *MQTTPublishTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"topic": map[string]any{
+				"type":        "string",
+				"description": "Topic to publish to, without wildcards, e.g. \"home/livingroom/lamp/set\"",
+			},
+			"payload": map[string]any{
+				"type":        "string",
+				"description": "Message payload, e.g. \"ON\" or a JSON document",
+			},
+			"qos": map[string]any{
+				"type":        "integer",
+				"enum":        []int{0, 1, 2},
+				"description": "Quality of service. Default: the channels.mqtt qos setting.",
+			},
+			"retain": map[string]any{
+				"type":        "boolean",
+				"description": "Ask the broker to retain the message. Default: false.",
+			},
+		},
+		"required": []string{"topic", "payload"},
+	}
+}
+
+func (t *MQTTPublishTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	topic, _ := args["topic"].(string)
+	if err := mqttclient.ValidateTopic(topic); err != nil {
+		return ErrorResult(fmt.Sprintf("invalid topic: %v", err))
+	}
+	payload, ok := args["payload"].(string)
+	if !ok {
+		return ErrorResult("payload is required")
+	}
+	if !t.isAllowed(topic) {
+		return ErrorResult(fmt.Sprintf(
+			"topic %s is not allowed. Allowed topics: %s (tools.mqtt.allowed_topics in the config).",
+			topic, strings.Join(t.allowed, ", ")))
+	}
+	qos := t.cfg.QoS
+	if q, ok := args["qos"].(float64); ok {
+		if q != 0 && q != 1 && q != 2 {
+			return ErrorResult("qos must be 0, 1 or 2")
+		}
+		qos = int(q)
+	}
+	retain, _ := args["retain"].(bool)
+
+	ctx, cancel := context.WithTimeout(ctx, mqttPublishTimeout)
+	defer cancel()
+
+	client, err := t.connect(ctx)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to connect to MQTT broker %s: %v", t.cfg.Broker, err))
+	}
+	if err := mqttclient.Wait(ctx, client.Publish(topic, byte(qos), retain, payload)); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to publish to %s: %v", topic, err))
+	}
+
+	msg := fmt.Sprintf("Published %d bytes to %s (qos %d", len(payload), topic, qos)
+	if retain {
+		msg += ", retained"
+	}
+	return SilentResult(msg + ")")
+}
+
+func (t *MQTTPublishTool) isAllowed(topic string) bool {
+	if len(t.allowed) == 0 {
+		return true
+	}
+	for _, filter := range t.allowed {
+		if mqttclient.MatchTopic(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// connect returns the shared client, connecting it first if needed. Once
+// connected the client reconnects by itself after a lost connection.
+func (t *MQTTPublishTool) connect(ctx context.Context) (paho.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// IsConnected is also true while the client reconnects in the
+	// background; the publish then waits for it.
+	if t.client != nil && t.client.IsConnected() {
+		return t.client, nil
+	}
+
+	opts, err := mqttclient.NewOptions(t.cfg, "-tool")
+	if err != nil {
+		return nil, err
+	}
+	client := paho.NewClient(opts)
+	if err := mqttclient.Wait(ctx, client.Connect()); err != nil {
+		client.Disconnect(0)
+		return nil, err
+	}
+	t.client = client
+	return client, nil
+}
+
+// Close disconnects from the broker.
+func (t *MQTTPublishTool) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		t.client.Disconnect(250)
+		t.client = nil
+	}
+}
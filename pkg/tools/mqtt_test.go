@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// startMQTTBroker runs an in-process broker and returns its URL and the
+// messages clients publish to it.
+func startMQTTBroker(t *testing.T) (string, chan packets.Packet) {
+	t.Helper()
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddListener(listeners.NewNet("test", ln)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	published := make(chan packets.Packet, 16)
+	err = server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		published <- pk
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "tcp://" + ln.Addr().String(), published
+}
+
+func TestMQTTPublishTool_Publish(t *testing.T) {
+	url, published := startMQTTBroker(t)
+	tool := NewMQTTPublishTool(config.MQTTConfig{Broker: url, ClientID: "picoclaw", QoS: 1}, []string{"home/+/set"})
+	defer tool.Close()
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"topic":   "home/lamp/set",
+		"payload": `{"state":"ON"}`,
+		"retain":  true,
+	})
+	if result.IsError {
+		t.Fatalf("Execute() error: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "home/lamp/set") || !strings.Contains(result.ForLLM, "retained") {
+		t.Errorf("result = %q", result.ForLLM)
+	}
+
+	select {
+	case pk := <-published:
+		if pk.TopicName != "home/lamp/set" || string(pk.Payload) != `{"state":"ON"}` || !pk.FixedHeader.Retain {
+			t.Errorf("published %s %q retain=%v", pk.TopicName, pk.Payload, pk.FixedHeader.Retain)
+		}
+		if pk.Origin != "picoclaw-tool" {
+			t.Errorf("client ID = %q, want picoclaw-tool", pk.Origin)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing published")
+	}
+
+	// The connection is reused.
+	result = tool.Execute(context.Background(), map[string]any{"topic": "home/fan/set", "payload": "OFF", "qos": 0.0})
+	if result.IsError {
+		t.Fatalf("second Execute() error: %s", result.ForLLM)
+	}
+	if pk := <-published; pk.TopicName != "home/fan/set" || pk.FixedHeader.Qos != 0 {
+		t.Errorf("published %s qos %d", pk.TopicName, pk.FixedHeader.Qos)
+	}
+}
+
+func TestMQTTPublishTool_Rejects(t *testing.T) {
+	tool := NewMQTTPublishTool(config.MQTTConfig{Broker: "tcp://127.0.0.1:1"}, []string{"home/#"})
+	for name, args := range map[string]map[string]any{
+		"not allowed": {"topic": "garage/door/set", "payload": "open"},
+		"wildcard":    {"topic": "home/+/set", "payload": "ON"},
+		"no payload":  {"topic": "home/lamp/set"},
+		"bad qos":     {"topic": "home/lamp/set", "payload": "ON", "qos": 3.0},
+	} {
+		if result := tool.Execute(context.Background(), args); !result.IsError {
+			t.Errorf("%s: expected an error, got %q", name, result.ForLLM)
+		}
+	}
+}
+
+func TestMQTTPublishTool_BrokerDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	tool := NewMQTTPublishTool(config.MQTTConfig{Broker: "tcp://" + addr}, nil)
+	result := tool.Execute(context.Background(), map[string]any{"topic": "a/b", "payload": "x"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "failed to connect") {
+		t.Errorf("result = %+v", result)
+	}
+}
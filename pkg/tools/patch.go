@@ -0,0 +1,728 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	maxPatchOutputBytes  = 64 * 1024
+	maxPatchHintScanCost = 10_000_000
+)
+
+// ApplyPatchTool applies a unified diff or a list of edits across one or more
+// files. All changes are checked before anything is written, so a patch
+// either applies completely or not at all.
+type ApplyPatchTool struct {
+	fs fileSystem
+}
+
+func NewApplyPatchTool(workspace string, restrict bool) *ApplyPatchTool {
+	var fs fileSystem
+	if restrict {
+		fs = &sandboxFs{workspace: workspace}
+	} else {
+		fs = &hostFs{}
+	}
+	return &ApplyPatchTool{fs: fs}
+}
+
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return "Apply several changes at once, either as a unified diff (patch) or as a list of " +
+		"old_text/new_text edits. Changes may span multiple files, create, delete and rename files. " +
+		"Nothing is written unless every change applies; use dry_run to preview the resulting diff."
+}
+
+func (t *ApplyPatchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"patch": map[string]any{
+				"type": "string",
+				"description": "Unified diff with ---/+++ file headers and @@ hunks. " +
+					"Use /dev/null as the old file to create a file or as the new file to delete one; " +
+					"different old and new paths (or git rename from/rename to lines) rename the file",
+			},
+			"edits": map[string]any{
+				"type":        "array",
+				"description": "Edits applied in order; later edits see the result of earlier ones",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{
+							"type":        "string",
+							"description": "The file path to edit",
+						},
+						"old_text": map[string]any{
+							"type":        "string",
+							"description": "The exact text to replace; empty to create a new file",
+						},
+						"new_text": map[string]any{
+							"type":        "string",
+							"description": "The text to replace with",
+						},
+						"replace_all": map[string]any{
+							"type":        "boolean",
+							"description": "Replace every occurrence instead of requiring exactly one",
+						},
+					},
+					"required": []string{"path", "old_text", "new_text"},
+				},
+			},
+			"dry_run": map[string]any{
+				"type":        "boolean",
+				"description": "Check the changes and return the resulting diff without writing anything",
+			},
+		},
+	}
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	patch, _ := args["patch"].(string)
+	edits, hasEdits := args["edits"].([]any)
+	if (patch == "") == (!hasEdits || len(edits) == 0) {
+		return ErrorResult("provide either patch or edits")
+	}
+	dryRun, _ := args["dry_run"].(bool)
+
+	set := newPatchSet(t.fs)
+	var err error
+	if patch != "" {
+		err = set.applyUnifiedDiff(patch)
+	} else {
+		err = set.applyEdits(edits)
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("patch not applied: %v", err)).WithError(err)
+	}
+
+	changed := set.changed()
+	if len(changed) == 0 {
+		return SilentResult("No changes: the patch leaves every file as it is")
+	}
+
+	if dryRun {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Dry run: the patch applies cleanly to %d file(s). Nothing was written.\n\n", len(changed))
+		for _, f := range changed {
+			sb.WriteString(f.diff())
+		}
+		return SilentResult(truncatePatchOutput(sb.String()))
+	}
+
+	if err := set.commit(changed); err != nil {
+		return ErrorResult(err.Error()).WithError(err)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Patched %d file(s):", len(changed))
+	for _, f := range changed {
+		fmt.Fprintf(&sb, "\n%s", f.summary())
+	}
+	return SilentResult(sb.String())
+}
+
+func truncatePatchOutput(s string) string {
+	if len(s) <= maxPatchOutputBytes {
+		return s
+	}
+	cut := strings.LastIndexByte(s[:maxPatchOutputBytes], '\n') + 1
+	return s[:cut] + fmt.Sprintf("[Diff truncated at %d bytes]", cut)
+}
+
+// patchFile tracks the original and patched state of one file.
+type patchFile struct {
+	path        string
+	orig        []byte
+	origExists  bool
+	content     []byte
+	exists      bool
+	added       int
+	removed     int
+	diffOutput  string
+	diffCounted bool
+}
+
+func (f *patchFile) isChanged() bool {
+	return f.exists != f.origExists || string(f.content) != string(f.orig)
+}
+
+// diff returns the unified diff from the original to the patched content.
+func (f *patchFile) diff() string {
+	f.countDiff()
+	return f.diffOutput
+}
+
+func (f *patchFile) summary() string {
+	f.countDiff()
+	switch {
+	case !f.origExists:
+		return fmt.Sprintf("%s (created, %d lines)", f.path, f.added)
+	case !f.exists:
+		return fmt.Sprintf("%s (deleted)", f.path)
+	default:
+		return fmt.Sprintf("%s (+%d -%d)", f.path, f.added, f.removed)
+	}
+}
+
+func (f *patchFile) countDiff() {
+	if f.diffCounted {
+		return
+	}
+	f.diffCounted = true
+	oldName, newName := "a/"+filepath.ToSlash(f.path), "b/"+filepath.ToSlash(f.path)
+	var oldContent, newContent []byte
+	if f.origExists {
+		oldContent = f.orig
+	} else {
+		oldName = "/dev/null"
+	}
+	if f.exists {
+		newContent = f.content
+	} else {
+		newName = "/dev/null"
+	}
+	f.diffOutput, f.added, f.removed = utils.UnifiedDiff(oldName, newName, oldContent, newContent)
+}
+
+// patchSet applies changes in memory and writes them out in commit.
+type patchSet struct {
+	fs     fileSystem
+	files  []*patchFile
+	byPath map[string]*patchFile
+}
+
+func newPatchSet(sysFs fileSystem) *patchSet {
+	return &patchSet{fs: sysFs, byPath: make(map[string]*patchFile)}
+}
+
+// load returns the in-memory state of path, reading it on first use.
+func (s *patchSet) load(path string) (*patchFile, error) {
+	key := filepath.Clean(path)
+	if f, ok := s.byPath[key]; ok {
+		return f, nil
+	}
+	f := &patchFile{path: path}
+	content, err := s.fs.ReadFile(path)
+	switch {
+	case err == nil:
+		f.orig, f.content = content, content
+		f.origExists, f.exists = true, true
+	case errors.Is(err, fs.ErrNotExist):
+	default:
+		return nil, err
+	}
+	s.byPath[key] = f
+	s.files = append(s.files, f)
+	return f, nil
+}
+
+func (s *patchSet) changed() []*patchFile {
+	var out []*patchFile
+	for _, f := range s.files {
+		if f.isChanged() {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// commit writes the changed files. Each write is atomic; if one fails, files
+// written before it are restored so the workspace is left as it was.
+func (s *patchSet) commit(changed []*patchFile) error {
+	for i, f := range changed {
+		var err error
+		if f.exists {
+			err = s.fs.WriteFile(f.path, f.content)
+		} else {
+			err = s.fs.Remove(f.path)
+		}
+		if err == nil {
+			continue
+		}
+		for _, done := range changed[:i] {
+			if done.origExists {
+				s.fs.WriteFile(done.path, done.orig)
+			} else {
+				s.fs.Remove(done.path)
+			}
+		}
+		return fmt.Errorf("failed to write %s, no files were changed: %w", f.path, err)
+	}
+	return nil
+}
+
+func (s *patchSet) applyEdits(edits []any) error {
+	for i, raw := range edits {
+		edit, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("edit %d: must be an object", i+1)
+		}
+		path, _ := edit["path"].(string)
+		if path == "" {
+			return fmt.Errorf("edit %d: path is required", i+1)
+		}
+		oldText, ok := edit["old_text"].(string)
+		if !ok {
+			return fmt.Errorf("edit %d: old_text is required", i+1)
+		}
+		newText, ok := edit["new_text"].(string)
+		if !ok {
+			return fmt.Errorf("edit %d: new_text is required", i+1)
+		}
+		replaceAll, _ := edit["replace_all"].(bool)
+
+		f, err := s.load(path)
+		if err != nil {
+			return fmt.Errorf("edit %d: %w", i+1, err)
+		}
+		if err := applyEdit(f, oldText, newText, replaceAll); err != nil {
+			return fmt.Errorf("edit %d (%s): %w", i+1, path, err)
+		}
+	}
+	return nil
+}
+
+func applyEdit(f *patchFile, oldText, newText string, replaceAll bool) error {
+	if !f.exists {
+		if oldText != "" {
+			return errors.New("file not found")
+		}
+		f.content, f.exists = []byte(newText), true
+		return nil
+	}
+	if oldText == "" {
+		return errors.New("old_text is empty; it may only be empty when creating a new file")
+	}
+
+	content := string(f.content)
+	count := strings.Count(content, oldText)
+	switch {
+	case count == 0:
+		msg := "old_text not found in file"
+		if hint := closestMatchHint(splitPatchLines(content), strings.Split(oldText, "\n")); hint != "" {
+			msg += ". " + hint
+		}
+		return errors.New(msg)
+	case count > 1 && !replaceAll:
+		return fmt.Errorf("old_text appears %d times (at lines %s). "+
+			"Add more context to make it unique or set replace_all",
+			count, occurrenceLines(content, oldText))
+	}
+	if replaceAll {
+		content = strings.ReplaceAll(content, oldText, newText)
+	} else {
+		content = strings.Replace(content, oldText, newText, 1)
+	}
+	f.content = []byte(content)
+	return nil
+}
+
+// occurrenceLines lists the line numbers at which sub starts in s.
+func occurrenceLines(s, sub string) string {
+	var lines []string
+	offset := 0
+	for len(lines) < 10 {
+		i := strings.Index(s[offset:], sub)
+		if i < 0 {
+			break
+		}
+		lines = append(lines, strconv.Itoa(strings.Count(s[:offset+i], "\n")+1))
+		offset += i + len(sub)
+	}
+	return strings.Join(lines, ", ")
+}
+
+// filePatch is the part of a unified diff that concerns one file. An empty
+// oldPath means the file is created, an empty newPath that it is deleted,
+// and different paths that it is renamed.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []patchHunk
+}
+
+type patchHunk struct {
+	oldStart int // 1-based; 0 when the header has no line numbers
+	header   string
+	oldLines []string
+	newLines []string
+	oldNoEOL bool // "\ No newline at end of file" after the last old line
+	newNoEOL bool
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// parseUnifiedDiff parses diffs as produced by diff -u and git diff. The
+// line counts in hunk headers are not trusted: a hunk ends at the first line
+// that is not part of it, which tolerates hand-written patches.
+func parseUnifiedDiff(patch string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	isFileHeader := func(i int) bool {
+		return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+	}
+
+	var files []*filePatch
+	var cur *filePatch
+	// git diff describes a rename without content changes by "rename from"
+	// and "rename to" lines alone, with no ---/+++ headers.
+	var renameFrom, renameTo string
+	flushRename := func() {
+		if renameFrom != "" && renameTo != "" {
+			cur = &filePatch{oldPath: renameFrom, newPath: renameTo}
+			files = append(files, cur)
+		}
+		renameFrom, renameTo = "", ""
+	}
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isFileHeader(i):
+			renameFrom, renameTo = "", ""
+			cur = &filePatch{
+				oldPath: patchPath(lines[i][4:], "a/"),
+				newPath: patchPath(lines[i+1][4:], "b/"),
+			}
+			if cur.oldPath == "" && cur.newPath == "" {
+				return nil, fmt.Errorf("line %d: both files are /dev/null", i+1)
+			}
+			files = append(files, cur)
+			i += 2
+		case strings.HasPrefix(line, "diff --git "):
+			flushRename()
+			i++
+		case strings.HasPrefix(line, "rename from "):
+			renameFrom = strings.TrimSpace(strings.TrimPrefix(line, "rename from "))
+			i++
+		case strings.HasPrefix(line, "rename to "):
+			renameTo = strings.TrimSpace(strings.TrimPrefix(line, "rename to "))
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk before the first ---/+++ file header", i+1)
+			}
+			h := patchHunk{header: line}
+			if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+				h.oldStart, _ = strconv.Atoi(m[1])
+			}
+			i++
+			last := byte(0)
+		hunkLines:
+			for ; i < len(lines); i++ {
+				l := lines[i]
+				if l == "" {
+					// Editors and models often strip the space of empty
+					// context lines; keep the line only if the hunk goes on.
+					if !hunkContinues(lines, i+1, isFileHeader) {
+						break
+					}
+					l = " "
+				}
+				if strings.HasPrefix(l, "@@") || isFileHeader(i) {
+					break
+				}
+				switch l[0] {
+				case ' ':
+					h.oldLines = append(h.oldLines, l[1:])
+					h.newLines = append(h.newLines, l[1:])
+				case '-':
+					h.oldLines = append(h.oldLines, l[1:])
+				case '+':
+					h.newLines = append(h.newLines, l[1:])
+				case '\\':
+					switch last {
+					case '-':
+						h.oldNoEOL = true
+					case '+':
+						h.newNoEOL = true
+					default:
+						h.oldNoEOL, h.newNoEOL = true, true
+					}
+					continue
+				default:
+					break hunkLines
+				}
+				last = l[0]
+			}
+			cur.hunks = append(cur.hunks, h)
+		default:
+			// diff --git, index, mode lines and free text between files
+			i++
+		}
+	}
+	flushRename()
+	if len(files) == 0 {
+		return nil, errors.New("no ---/+++ file headers found in patch")
+	}
+	return files, nil
+}
+
+// hunkContinues reports whether a hunk line follows the empty lines
+// starting at i.
+func hunkContinues(lines []string, i int, isFileHeader func(int) bool) bool {
+	for ; i < len(lines); i++ {
+		l := lines[i]
+		if l == "" {
+			continue
+		}
+		return strings.ContainsRune(" -+\\", rune(l[0])) && !isFileHeader(i)
+	}
+	return false
+}
+
+// patchPath extracts the file name from a ---/+++ header, dropping an
+// optional timestamp and the a/ or b/ prefix used by git.
+func patchPath(s, prefix string) string {
+	s, _, _ = strings.Cut(s, "\t")
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+func (s *patchSet) applyUnifiedDiff(patch string) error {
+	files, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return err
+	}
+	for _, fp := range files {
+		if fp.oldPath != "" && fp.newPath != "" && filepath.Clean(fp.oldPath) != filepath.Clean(fp.newPath) {
+			if err := s.applyRename(fp); err != nil {
+				return fmt.Errorf("%s -> %s: %w", fp.oldPath, fp.newPath, err)
+			}
+			continue
+		}
+		path := fp.newPath
+		if path == "" {
+			path = fp.oldPath
+		}
+		f, err := s.load(path)
+		if err != nil {
+			return err
+		}
+		if err := applyFilePatch(f, fp); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// applyRename applies the hunks of fp to the old file, writes the result to
+// the new path and removes the old file.
+func (s *patchSet) applyRename(fp *filePatch) error {
+	from, err := s.load(fp.oldPath)
+	if err != nil {
+		return err
+	}
+	to, err := s.load(fp.newPath)
+	if err != nil {
+		return err
+	}
+	if to.exists {
+		return fmt.Errorf("%s already exists", fp.newPath)
+	}
+	if err := applyFilePatch(from, fp); err != nil {
+		return err
+	}
+	to.content, to.exists = from.content, true
+	from.content, from.exists = nil, false
+	return nil
+}
+
+func applyFilePatch(f *patchFile, fp *filePatch) error {
+	switch {
+	case fp.oldPath == "" && f.exists:
+		return errors.New("file already exists")
+	case fp.oldPath != "" && !f.exists:
+		return errors.New("file not found")
+	}
+
+	if fp.newPath == "" && len(fp.hunks) == 0 {
+		f.content, f.exists = nil, false
+		return nil
+	}
+
+	lines, eol := splitPatchText(string(f.content))
+	if len(lines) == 0 {
+		eol = true // lines added to an empty file end with a newline unless marked
+	}
+	crlf := strings.Contains(string(f.content), "\r\n")
+	if crlf {
+		for i := range lines {
+			lines[i] = strings.TrimSuffix(lines[i], "\r")
+		}
+	}
+
+	pos := 0 // hunks apply in order and may not overlap
+	offset := 0
+	for n, h := range fp.hunks {
+		at, err := locateHunk(lines, h, pos, offset)
+		if err != nil {
+			return fmt.Errorf("hunk %d (%s) does not apply: %w", n+1, h.header, err)
+		}
+		if h.oldStart > 0 {
+			offset = at - (h.oldStart - 1)
+		}
+		end := at + len(h.oldLines)
+		if end == len(lines) && (h.oldNoEOL || h.newNoEOL) {
+			eol = !h.newNoEOL
+		}
+		next := make([]string, 0, len(lines)-len(h.oldLines)+len(h.newLines))
+		next = append(next, lines[:at]...)
+		next = append(next, h.newLines...)
+		next = append(next, lines[end:]...)
+		lines = next
+		pos = at + len(h.newLines)
+	}
+
+	if fp.newPath == "" {
+		if len(lines) > 0 {
+			return fmt.Errorf("the patch deletes the file but %d lines would remain", len(lines))
+		}
+		f.content, f.exists = nil, false
+		return nil
+	}
+
+	sep := "\n"
+	if crlf {
+		sep = "\r\n"
+	}
+	content := strings.Join(lines, sep)
+	if eol && len(lines) > 0 {
+		content += sep
+	}
+	f.content, f.exists = []byte(content), true
+	return nil
+}
+
+// locateHunk finds where the old side of h matches lines at or after pos,
+// preferring the match nearest to the line given in the hunk header (shifted
+// by the offset at which the previous hunk matched). Trailing whitespace is
+// ignored if there is no exact match.
+func locateHunk(lines []string, h patchHunk, pos, offset int) (int, error) {
+	if len(h.oldLines) == 0 {
+		// Pure insertion: the header's old line is the one to insert after.
+		at := h.oldStart + offset
+		if h.oldStart == 0 || at < pos {
+			at = pos
+		}
+		return min(at, len(lines)), nil
+	}
+
+	want := max(h.oldStart-1+offset, 0)
+	for _, eq := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		best := -1
+		for at := pos; at+len(h.oldLines) <= len(lines); at++ {
+			if !linesMatch(lines[at:], h.oldLines, eq) {
+				continue
+			}
+			if best < 0 || abs(at-want) < abs(best-want) {
+				best = at
+			}
+			if at >= want {
+				break
+			}
+		}
+		if best >= 0 {
+			return best, nil
+		}
+	}
+
+	msg := "context not found"
+	if hint := closestMatchHint(lines, h.oldLines); hint != "" {
+		msg += ". " + hint
+	}
+	return 0, errors.New(msg)
+}
+
+func linesMatch(lines, want []string, eq func(a, b string) bool) bool {
+	for i, w := range want {
+		if !eq(lines[i], w) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// closestMatchHint describes the window of lines that agrees with want on
+// the most lines, ignoring surrounding whitespace, and the first line in it
+// that differs. It returns "" when nothing is similar.
+func closestMatchHint(lines, want []string) string {
+	if len(lines) == 0 || len(want) == 0 || len(lines)*len(want) > maxPatchHintScanCost {
+		return ""
+	}
+	trimmed := func(ss []string) []string {
+		out := make([]string, len(ss))
+		for i, s := range ss {
+			out[i] = strings.TrimSpace(s)
+		}
+		return out
+	}
+	tl, tw := trimmed(lines), trimmed(want)
+
+	bestAt, bestScore := 0, 0
+	for at := 0; at < len(tl); at++ {
+		score := 0
+		for i := 0; i < len(tw) && at+i < len(tl); i++ {
+			if tw[i] != "" && tl[at+i] == tw[i] {
+				score++
+			}
+		}
+		if score > bestScore {
+			bestAt, bestScore = at, score
+		}
+	}
+	if bestScore == 0 {
+		return ""
+	}
+
+	hint := fmt.Sprintf("Closest match starts at line %d (%d of %d lines match)", bestAt+1, bestScore, len(want))
+	for i, w := range want {
+		at := bestAt + i
+		if at >= len(lines) {
+			return hint + fmt.Sprintf("; the file ends at line %d, before expected line %q", len(lines), w)
+		}
+		if lines[at] != w {
+			return hint + fmt.Sprintf("; first difference at line %d:\n  expected: %q\n  found:    %q",
+				at+1, w, lines[at])
+		}
+	}
+	return hint
+}
+
+// splitPatchText splits s into lines without terminators and reports whether
+// the last line ended with a newline.
+func splitPatchText(s string) ([]string, bool) {
+	if s == "" {
+		return nil, false
+	}
+	eol := strings.HasSuffix(s, "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n"), eol
+}
+
+func splitPatchLines(s string) []string {
+	lines, _ := splitPatchText(s)
+	return lines
+}
@@ -0,0 +1,247 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePatchFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	workspace := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(workspace, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(content), 0o644)
+	}
+	return workspace
+}
+
+func readPatchFile(t *testing.T, workspace, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(workspace, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApplyPatchTool_UnifiedDiff(t *testing.T) {
+	workspace := writePatchFiles(t, map[string]string{
+		"config.yaml": "name: demo\nport: 8080\nhost: localhost\n\nlog: info\ndebug: false\n",
+		"old.txt":     "obsolete\n",
+	})
+	tool := NewApplyPatchTool(workspace, true)
+
+	// Hand-written patch: wrong line counts, a stripped empty context line
+	// and a line number that is off by one.
+	patch := `diff --git a/config.yaml b/config.yaml
+--- a/config.yaml
++++ b/config.yaml
+@@ -1,3 +1,3 @@
+ name: demo
+-port: 8080
++port: 9090
+ host: localhost
+@@ -5,1 +5,1 @@
+
+-log: info
++log: debug
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-obsolete
+--- /dev/null
++++ b/docs/NOTES.md
+@@ -0,0 +1,2 @@
++# Notes
++Port changed to 9090.
+`
+	result := tool.Execute(context.Background(), map[string]any{"patch": patch})
+	if result.IsError {
+		t.Fatalf("apply_patch failed: %s", result.ForLLM)
+	}
+	if got, want := readPatchFile(t, workspace, "config.yaml"),
+		"name: demo\nport: 9090\nhost: localhost\n\nlog: debug\ndebug: false\n"; got != want {
+		t.Errorf("config.yaml = %q, want %q", got, want)
+	}
+	if got := readPatchFile(t, workspace, "docs/NOTES.md"); got != "# Notes\nPort changed to 9090.\n" {
+		t.Errorf("NOTES.md = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "old.txt")); !os.IsNotExist(err) {
+		t.Errorf("old.txt should be deleted, stat err = %v", err)
+	}
+	for _, want := range []string{"config.yaml (+2 -2)", "old.txt (deleted)", "docs/NOTES.md (created, 2 lines)"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("summary %q does not mention %q", result.ForLLM, want)
+		}
+	}
+}
+
+func TestApplyPatchTool_Rename(t *testing.T) {
+	workspace := writePatchFiles(t, map[string]string{
+		"src/util.go": "package src\n\nfunc helper() {}\n",
+		"README.txt":  "readme\n",
+		"taken.txt":   "keep\n",
+	})
+	tool := NewApplyPatchTool(workspace, true)
+
+	// A rename with changes, and a pure git rename without ---/+++ headers.
+	patch := `diff --git a/src/util.go b/src/helpers.go
+similarity index 80%
+rename from src/util.go
+rename to src/helpers.go
+--- a/src/util.go
++++ b/src/helpers.go
+@@ -1,3 +1,3 @@
+ package src
+
+-func helper() {}
++func Helper() {}
+diff --git a/README.txt b/docs/README.txt
+similarity index 100%
+rename from README.txt
+rename to docs/README.txt
+`
+	result := tool.Execute(context.Background(), map[string]any{"patch": patch})
+	if result.IsError {
+		t.Fatalf("apply_patch failed: %s", result.ForLLM)
+	}
+	if got := readPatchFile(t, workspace, "src/helpers.go"); got != "package src\n\nfunc Helper() {}\n" {
+		t.Errorf("helpers.go = %q", got)
+	}
+	if got := readPatchFile(t, workspace, "docs/README.txt"); got != "readme\n" {
+		t.Errorf("docs/README.txt = %q", got)
+	}
+	for _, gone := range []string{"src/util.go", "README.txt"} {
+		if _, err := os.Stat(filepath.Join(workspace, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed, stat err = %v", gone, err)
+		}
+	}
+
+	// Renaming onto an existing file is refused.
+	result = tool.Execute(context.Background(), map[string]any{"patch": `--- a/src/helpers.go
++++ b/taken.txt
+`})
+	if !result.IsError || !strings.Contains(result.ForLLM, "taken.txt already exists") {
+		t.Errorf("rename onto an existing file: %s", result.ForLLM)
+	}
+	if got := readPatchFile(t, workspace, "taken.txt"); got != "keep\n" {
+		t.Errorf("taken.txt = %q", got)
+	}
+}
+
+func TestApplyPatchTool_AllOrNothingWithHint(t *testing.T) {
+	original := "server:\n  port: 8080\n  timeout: 30\n"
+	workspace := writePatchFiles(t, map[string]string{"a.yaml": "x: 1\n", "b.yaml": original})
+	tool := NewApplyPatchTool(workspace, true)
+
+	patch := `--- a/a.yaml
++++ b/a.yaml
+@@ -1 +1 @@
+-x: 1
++x: 2
+--- a/b.yaml
++++ b/b.yaml
+@@ -1,3 +1,3 @@
+ server:
+   port: 9000
+-  timeout: 30
++  timeout: 60
+`
+	result := tool.Execute(context.Background(), map[string]any{"patch": patch})
+	if !result.IsError {
+		t.Fatalf("mismatched context should fail: %s", result.ForLLM)
+	}
+	for _, want := range []string{
+		"b.yaml: hunk 1",
+		"Closest match starts at line 1 (2 of 3 lines match)",
+		`expected: "  port: 9000"`,
+		`found:    "  port: 8080"`,
+	} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("error %q does not contain %q", result.ForLLM, want)
+		}
+	}
+	if got := readPatchFile(t, workspace, "a.yaml"); got != "x: 1\n" {
+		t.Errorf("a.yaml was written although the patch failed: %q", got)
+	}
+}
+
+func TestApplyPatchTool_EditsAndDryRun(t *testing.T) {
+	original := "a = 1\nb = 2\na = 1\n"
+	workspace := writePatchFiles(t, map[string]string{"vars.ini": original})
+	tool := NewApplyPatchTool(workspace, true)
+	ctx := context.Background()
+
+	edits := []any{
+		map[string]any{"path": "vars.ini", "old_text": "a = 1", "new_text": "a = 3", "replace_all": true},
+		map[string]any{"path": "vars.ini", "old_text": "b = 2\n", "new_text": ""},
+		map[string]any{"path": "new.ini", "old_text": "", "new_text": "c = 4\n"},
+	}
+	result := tool.Execute(ctx, map[string]any{"edits": edits, "dry_run": true})
+	if result.IsError {
+		t.Fatalf("dry run failed: %s", result.ForLLM)
+	}
+	wantDiff := "--- a/vars.ini\n+++ b/vars.ini\n@@ -1,3 +1,2 @@\n-a = 1\n-b = 2\n-a = 1\n+a = 3\n+a = 3\n" +
+		"--- /dev/null\n+++ b/new.ini\n@@ -0,0 +1,1 @@\n+c = 4\n"
+	if !strings.HasSuffix(result.ForLLM, wantDiff) {
+		t.Errorf("dry run output = %q, want suffix %q", result.ForLLM, wantDiff)
+	}
+	if got := readPatchFile(t, workspace, "vars.ini"); got != original {
+		t.Errorf("dry run modified vars.ini: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "new.ini")); !os.IsNotExist(err) {
+		t.Error("dry run created new.ini")
+	}
+
+	result = tool.Execute(ctx, map[string]any{"edits": edits})
+	if result.IsError {
+		t.Fatalf("edits failed: %s", result.ForLLM)
+	}
+	if got := readPatchFile(t, workspace, "vars.ini"); got != "a = 3\na = 3\n" {
+		t.Errorf("vars.ini = %q", got)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"edits": []any{
+		map[string]any{"path": "vars.ini", "old_text": "a = 3", "new_text": "a = 4"},
+	}})
+	if !result.IsError || !strings.Contains(result.ForLLM, "appears 2 times (at lines 1, 2)") {
+		t.Errorf("ambiguous edit = %q", result.ForLLM)
+	}
+}
+
+func TestApplyPatchTool_RestrictedToWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	target := filepath.Join(outside, "secret.txt")
+	os.WriteFile(target, []byte("keep\n"), 0o600)
+	tool := NewApplyPatchTool(workspace, true)
+
+	for _, args := range []map[string]any{
+		{"edits": []any{map[string]any{"path": target, "old_text": "keep", "new_text": "gone"}}},
+		{"patch": "--- a/../" + filepath.Base(outside) + "/secret.txt\n+++ /dev/null\n"},
+	} {
+		if result := tool.Execute(context.Background(), args); !result.IsError {
+			t.Errorf("patch outside the workspace should fail: %s", result.ForLLM)
+		}
+	}
+	if data, _ := os.ReadFile(target); string(data) != "keep\n" {
+		t.Errorf("file outside the workspace changed: %q", data)
+	}
+}
+
+func TestParseUnifiedDiff_NoNewlineAtEOF(t *testing.T) {
+	workspace := writePatchFiles(t, map[string]string{"f.txt": "one\ntwo"})
+	tool := NewApplyPatchTool(workspace, true)
+
+	patch := "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,3 @@\n one\n-two\n\\ No newline at end of file\n+two\n+three\n"
+	if result := tool.Execute(context.Background(), map[string]any{"patch": patch}); result.IsError {
+		t.Fatalf("apply_patch failed: %s", result.ForLLM)
+	}
+	if got := readPatchFile(t, workspace, "f.txt"); got != "one\ntwo\nthree\n" {
+		t.Errorf("f.txt = %q", got)
+	}
+}
@@ -0,0 +1,565 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/sandbox"
+)
+
+const (
+	defaultMaxBackgroundProcesses = 4
+	defaultBackgroundOutputBytes  = 64 * 1024
+	// finishedProcessTTL is how long exited processes stay readable.
+	finishedProcessTTL = 30 * time.Minute
+	// announceTailBytes is how much output the completion notice carries.
+	announceTailBytes = 2000
+)
+
+// BackgroundProcess is a command started by exec with background=true.
+type BackgroundProcess struct {
+	ID        string
+	Command   string
+	Dir       string
+	Channel   string
+	ChatID    string
+	StartedAt time.Time
+
+	cmd     *exec.Cmd
+	cancel  context.CancelFunc
+	stdin   io.WriteCloser
+	output  *ringBuffer
+	sandbox *sandbox.Config
+	done    chan struct{}
+
+	mu        sync.Mutex
+	readPos   int64
+	endedAt   time.Time
+	exitCode  int
+	exitErr   error
+	violation *sandbox.Violation
+	killed    bool
+	timedOut  bool
+	timer     *time.Timer
+}
+
+// session identifies the conversation that owns the process.
+func (p *BackgroundProcess) session() string {
+	return p.Channel + ":" + p.ChatID
+}
+
+// Running reports whether the process has not exited yet.
+func (p *BackgroundProcess) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Status describes the process state in one line.
+func (p *BackgroundProcess) Status() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Running() {
+		return fmt.Sprintf("running for %s", time.Since(p.StartedAt).Round(time.Second))
+	}
+	duration := p.endedAt.Sub(p.StartedAt).Round(time.Second)
+	switch {
+	case p.killed:
+		return fmt.Sprintf("killed after %s", duration)
+	case p.timedOut:
+		return fmt.Sprintf("stopped at the runtime limit after %s", duration)
+	case p.violation != nil:
+		return fmt.Sprintf("stopped by sandbox after %s: %s", duration, p.violation.Error())
+	case p.exitErr != nil && p.exitCode < 0:
+		return fmt.Sprintf("failed after %s: %v", duration, p.exitErr)
+	default:
+		return fmt.Sprintf("exited with code %d after %s", p.exitCode, duration)
+	}
+}
+
+// ProcessManager runs background exec processes. Processes belong to the
+// session (channel and chat) that started them and are only visible there.
+// Completion is announced through the system inbound channel, like subagent
+// results.
+type ProcessManager struct {
+	bus           *bus.MessageBus
+	maxPerSession int
+	bufferSize    int
+	maxRuntime    time.Duration
+
+	mu        sync.Mutex
+	processes map[string]*BackgroundProcess
+	nextID    int
+	closed    bool
+}
+
+// NewProcessManager creates a manager. Non-positive limits use the defaults
+// of 4 running processes per session and 64 KB of output per process.
+func NewProcessManager(msgBus *bus.MessageBus, maxPerSession, bufferSize int) *ProcessManager {
+	if maxPerSession <= 0 {
+		maxPerSession = defaultMaxBackgroundProcesses
+	}
+	if bufferSize <= 0 {
+		bufferSize = defaultBackgroundOutputBytes
+	}
+	return &ProcessManager{
+		bus:           msgBus,
+		maxPerSession: maxPerSession,
+		bufferSize:    bufferSize,
+		processes:     make(map[string]*BackgroundProcess),
+		nextID:        1,
+	}
+}
+
+// SetMaxRuntime stops processes that run longer than d; 0 means no limit.
+// The conversation is told when a process is stopped this way.
+func (m *ProcessManager) SetMaxRuntime(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxRuntime = d
+}
+
+// Start runs cmd in the background. cancel is called when the process is
+// killed or exits; sandboxCfg, when set, is used to classify failures.
+func (m *ProcessManager) Start(
+	cmd *exec.Cmd,
+	cancel context.CancelFunc,
+	command, channel, chatID string,
+	sandboxCfg *sandbox.Config,
+) (*BackgroundProcess, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, errors.New("process manager is closed")
+	}
+	m.pruneLocked()
+
+	proc := &BackgroundProcess{
+		Command:   command,
+		Dir:       cmd.Dir,
+		Channel:   channel,
+		ChatID:    chatID,
+		StartedAt: time.Now(),
+		cmd:       cmd,
+		cancel:    cancel,
+		output:    newRingBuffer(m.bufferSize),
+		sandbox:   sandboxCfg,
+		done:      make(chan struct{}),
+	}
+	running := 0
+	for _, p := range m.processes {
+		if p.session() == proc.session() && p.Running() {
+			running++
+		}
+	}
+	if running >= m.maxPerSession {
+		return nil, fmt.Errorf("too many background processes in this session (limit %d); kill one first",
+			m.maxPerSession)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = proc.output
+	cmd.Stderr = proc.output
+	// Do not wait forever for output pipes held open by daemonized children.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	proc.stdin = stdin
+	proc.ID = fmt.Sprintf("proc_%d", m.nextID)
+	m.nextID++
+	m.processes[proc.ID] = proc
+	if m.maxRuntime > 0 {
+		proc.mu.Lock()
+		proc.timer = time.AfterFunc(m.maxRuntime, func() { m.expire(proc) })
+		proc.mu.Unlock()
+	}
+
+	go m.wait(proc)
+	return proc, nil
+}
+
+func (m *ProcessManager) wait(proc *BackgroundProcess) {
+	err := proc.cmd.Wait()
+	proc.cancel()
+
+	proc.mu.Lock()
+	if proc.timer != nil {
+		proc.timer.Stop()
+	}
+	proc.endedAt = time.Now()
+	proc.exitErr = err
+	proc.exitCode = 0
+	if err != nil {
+		proc.exitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			proc.exitCode = exitErr.ExitCode()
+		}
+		if proc.sandbox != nil && !proc.killed && !proc.timedOut {
+			proc.violation = sandbox.Classify(err, proc.output.tail(announceTailBytes), *proc.sandbox)
+		}
+	}
+	killed := proc.killed
+	proc.mu.Unlock()
+	close(proc.done)
+
+	logger.InfoCF("exec", "Background process finished", map[string]any{
+		"id":      proc.ID,
+		"command": proc.Command,
+		"status":  proc.Status(),
+	})
+	if !killed {
+		m.announce(proc)
+	}
+}
+
+// announce tells the originating conversation that the process finished.
+func (m *ProcessManager) announce(proc *BackgroundProcess) {
+	if m.bus == nil {
+		return
+	}
+	tail := proc.output.tail(announceTailBytes)
+	if tail == "" {
+		tail = "(no output)"
+	}
+	content := fmt.Sprintf("Background process %s (%s) %s.\n\nResult:\n%s",
+		proc.ID, proc.Command, proc.Status(), tail)
+
+	pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer pubCancel()
+	m.bus.PublishInbound(pubCtx, bus.InboundMessage{
+		Channel:  "system",
+		SenderID: fmt.Sprintf("process:%s", proc.ID),
+		// Format: "original_channel:original_chat_id" for routing back
+		ChatID:  proc.session(),
+		Content: content,
+	})
+}
+
+// Get returns a process owned by the given session.
+func (m *ProcessManager) Get(channel, chatID, id string) (*BackgroundProcess, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	proc, ok := m.processes[id]
+	if !ok || proc.session() != channel+":"+chatID {
+		return nil, false
+	}
+	return proc, true
+}
+
+// List returns the session's processes, oldest first.
+func (m *ProcessManager) List(channel, chatID string) []*BackgroundProcess {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked()
+
+	session := channel + ":" + chatID
+	var procs []*BackgroundProcess
+	for _, p := range m.processes {
+		if p.session() == session {
+			procs = append(procs, p)
+		}
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].StartedAt.Before(procs[j].StartedAt) })
+	return procs
+}
+
+// pruneLocked forgets processes that exited more than finishedProcessTTL ago.
+func (m *ProcessManager) pruneLocked() {
+	for id, p := range m.processes {
+		if p.Running() {
+			continue
+		}
+		p.mu.Lock()
+		expired := time.Since(p.endedAt) > finishedProcessTTL
+		p.mu.Unlock()
+		if expired {
+			delete(m.processes, id)
+		}
+	}
+}
+
+// Read returns the output produced since the previous Read and the number of
+// bytes lost because the buffer overflowed in between.
+func (m *ProcessManager) Read(proc *BackgroundProcess) (string, int64) {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	out, next, dropped := proc.output.since(proc.readPos)
+	proc.readPos = next
+	return strings.ToValidUTF8(string(out), "�"), dropped
+}
+
+// Write sends input to the process's stdin, optionally closing it afterwards.
+func (m *ProcessManager) Write(proc *BackgroundProcess, input string, closeStdin bool) error {
+	if !proc.Running() {
+		return errors.New("process has exited")
+	}
+	if input != "" {
+		if _, err := io.WriteString(proc.stdin, input); err != nil {
+			return err
+		}
+	}
+	if closeStdin {
+		return proc.stdin.Close()
+	}
+	return nil
+}
+
+// Kill terminates the process and everything it started.
+func (m *ProcessManager) Kill(proc *BackgroundProcess) {
+	if !proc.Running() {
+		return
+	}
+	proc.mu.Lock()
+	proc.killed = true
+	proc.mu.Unlock()
+	_ = terminateProcessTree(proc.cmd)
+	proc.cancel()
+	select {
+	case <-proc.done:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// expire stops a process that reached the runtime limit. Unlike Kill, the
+// conversation still gets the completion announcement.
+func (m *ProcessManager) expire(proc *BackgroundProcess) {
+	if !proc.Running() {
+		return
+	}
+	proc.mu.Lock()
+	proc.timedOut = true
+	proc.mu.Unlock()
+	logger.WarnCF("exec", "Background process reached the runtime limit", map[string]any{
+		"id":      proc.ID,
+		"command": proc.Command,
+	})
+	_ = terminateProcessTree(proc.cmd)
+	proc.cancel()
+}
+
+// KillSession kills all running processes of a session and returns how many
+// were running.
+func (m *ProcessManager) KillSession(channel, chatID string) int {
+	killed := 0
+	for _, proc := range m.List(channel, chatID) {
+		if proc.Running() {
+			m.Kill(proc)
+			killed++
+		}
+	}
+	return killed
+}
+
+// Close kills every running process; no new processes can be started.
+func (m *ProcessManager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	procs := make([]*BackgroundProcess, 0, len(m.processes))
+	for _, p := range m.processes {
+		procs = append(procs, p)
+	}
+	m.mu.Unlock()
+
+	for _, proc := range procs {
+		m.Kill(proc)
+	}
+}
+
+// ringBuffer keeps the last len(buf) bytes written to it and counts the total
+// so readers can resume from an offset.
+type ringBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	total int64
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{buf: make([]byte, size)}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(p)
+	size := len(r.buf)
+	if len(p) > size {
+		r.total += int64(len(p) - size)
+		p = p[len(p)-size:]
+	}
+	for len(p) > 0 {
+		pos := int(r.total % int64(size))
+		c := copy(r.buf[pos:], p)
+		r.total += int64(c)
+		p = p[c:]
+	}
+	return n, nil
+}
+
+// since returns the retained bytes written at or after offset, the offset to
+// resume from, and how many requested bytes were already overwritten.
+func (r *ringBuffer) since(offset int64) ([]byte, int64, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := int64(len(r.buf))
+	start := max(r.total-size, 0)
+	var dropped int64
+	if offset < start {
+		dropped = start - offset
+		offset = start
+	}
+	offset = min(offset, r.total)
+
+	out := make([]byte, 0, r.total-offset)
+	for o := offset; o < r.total; {
+		pos := o % size
+		end := min(size, pos+(r.total-o))
+		out = append(out, r.buf[pos:end]...)
+		o += end - pos
+	}
+	return out, r.total, dropped
+}
+
+// tail returns up to n of the most recent bytes.
+func (r *ringBuffer) tail(n int) string {
+	r.mu.Lock()
+	total := r.total
+	r.mu.Unlock()
+	out, _, _ := r.since(total - int64(n))
+	return strings.ToValidUTF8(string(out), "�")
+}
+
+// ProcessTool lets the agent follow background processes started by exec.
+type ProcessTool struct {
+	manager *ProcessManager
+	channel string
+	chatID  string
+}
+
+func NewProcessTool(manager *ProcessManager) *ProcessTool {
+	return &ProcessTool{manager: manager}
+}
+
+func (t *ProcessTool) Name() string {
+	return "process"
+}
+
+func (t *ProcessTool) Description() string {
+	return "Manage background processes started with exec background=true. Actions: list (all processes " +
+		"of this conversation), status, read (output produced since the last read), write (send input to " +
+		"stdin), kill."
+}
+
+func (t *ProcessTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "status", "read", "write", "kill"},
+				"description": "What to do",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "Process ID returned by exec, e.g. proc_1 (all actions except list); kill also accepts \"all\"",
+			},
+			"input": map[string]any{
+				"type":        "string",
+				"description": "Text to write to stdin (write); include a trailing newline for line input",
+			},
+			"close_stdin": map[string]any{
+				"type":        "boolean",
+				"description": "Close stdin after writing, signalling end of input (write)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ProcessTool) SetContext(channel, chatID string) {
+	t.channel = channel
+	t.chatID = chatID
+}
+
+func (t *ProcessTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	if action == "list" {
+		return t.list()
+	}
+
+	id, _ := args["id"].(string)
+	if id == "" {
+		return ErrorResult("id is required")
+	}
+	if action == "kill" && id == "all" {
+		killed := t.manager.KillSession(t.channel, t.chatID)
+		return NewToolResult(fmt.Sprintf("Killed %d background process(es).", killed))
+	}
+	proc, ok := t.manager.Get(t.channel, t.chatID, id)
+	if !ok {
+		return ErrorResult(fmt.Sprintf("no background process %q in this conversation", id))
+	}
+
+	switch action {
+	case "status":
+		return NewToolResult(fmt.Sprintf("%s: %s\nCommand: %s", proc.ID, proc.Status(), proc.Command))
+	case "read":
+		output, dropped := t.manager.Read(proc)
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%s: %s\n", proc.ID, proc.Status())
+		if dropped > 0 {
+			fmt.Fprintf(&sb, "(%d bytes of earlier output were dropped from the buffer)\n", dropped)
+		}
+		if output == "" {
+			sb.WriteString("(no new output)")
+		} else {
+			sb.WriteString(truncateOutput(output))
+		}
+		return NewToolResult(sb.String())
+	case "write":
+		input, _ := args["input"].(string)
+		closeStdin, _ := args["close_stdin"].(bool)
+		if input == "" && !closeStdin {
+			return ErrorResult("input or close_stdin is required")
+		}
+		if err := t.manager.Write(proc, input, closeStdin); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to write to %s: %v", proc.ID, err)).WithError(err)
+		}
+		return NewToolResult(fmt.Sprintf("Wrote %d bytes to %s", len(input), proc.ID))
+	case "kill":
+		t.manager.Kill(proc)
+		return NewToolResult(fmt.Sprintf("%s: %s", proc.ID, proc.Status()))
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q", action))
+	}
+}
+
+func (t *ProcessTool) list() *ToolResult {
+	procs := t.manager.List(t.channel, t.chatID)
+	if len(procs) == 0 {
+		return NewToolResult("No background processes in this conversation.")
+	}
+	var sb strings.Builder
+	for _, p := range procs {
+		fmt.Fprintf(&sb, "- %s: %s — %s\n", p.ID, p.Command, p.Status())
+	}
+	return NewToolResult(strings.TrimRight(sb.String(), "\n"))
+}
@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestRingBuffer_KeepsTailAndOffsets(t *testing.T) {
+	r := newRingBuffer(8)
+	r.Write([]byte("hello"))
+
+	out, next, dropped := r.since(0)
+	if string(out) != "hello" || next != 5 || dropped != 0 {
+		t.Fatalf("since(0) = %q, %d, %d", out, next, dropped)
+	}
+
+	r.Write([]byte(" world!"))
+	out, next, dropped = r.since(5)
+	if string(out) != " world!" || next != 12 || dropped != 0 {
+		t.Errorf("since(5) = %q, %d, %d", out, next, dropped)
+	}
+	out, _, dropped = r.since(0)
+	if string(out) != "o world!" || dropped != 4 {
+		t.Errorf("since(0) after wrap = %q, dropped %d", out, dropped)
+	}
+
+	r.Write([]byte("0123456789abc"))
+	if got := r.tail(3); got != "abc" {
+		t.Errorf("tail(3) = %q", got)
+	}
+	if got := r.tail(100); got != "56789abc" {
+		t.Errorf("tail(100) = %q", got)
+	}
+}
+
+func newBackgroundExecTool(t *testing.T, msgBus *bus.MessageBus) (*ExecTool, *ProcessTool, *ProcessManager) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("background tests use POSIX shell commands")
+	}
+	manager := NewProcessManager(msgBus, 2, 1024)
+	t.Cleanup(manager.Close)
+
+	execTool := NewExecTool(t.TempDir(), false)
+	execTool.SetProcessManager(manager)
+	execTool.SetContext("telegram", "42")
+	processTool := NewProcessTool(manager)
+	processTool.SetContext("telegram", "42")
+	return execTool, processTool, manager
+}
+
+func startBackground(t *testing.T, tool *ExecTool, command string) string {
+	t.Helper()
+	result := tool.Execute(context.Background(), map[string]any{"command": command, "background": true})
+	if result.IsError {
+		t.Fatalf("background exec failed: %s", result.ForLLM)
+	}
+	id := strings.Fields(strings.TrimPrefix(result.ForLLM, "Started background process "))[0]
+	if !strings.HasPrefix(id, "proc_") {
+		t.Fatalf("unexpected result: %s", result.ForLLM)
+	}
+	return id
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestProcessTool_InteractiveProcess(t *testing.T) {
+	execTool, processTool, manager := newBackgroundExecTool(t, nil)
+	ctx := context.Background()
+
+	id := startBackground(t, execTool, "echo ready; while read line; do echo got:$line; done")
+
+	waitFor(t, func() bool {
+		proc, _ := manager.Get("telegram", "42", id)
+		return proc.output.tail(100) != ""
+	})
+	res := processTool.Execute(ctx, map[string]any{"action": "read", "id": id})
+	if !strings.Contains(res.ForLLM, "ready") || !strings.Contains(res.ForLLM, "running") {
+		t.Fatalf("first read = %s", res.ForLLM)
+	}
+
+	res = processTool.Execute(ctx, map[string]any{"action": "write", "id": id, "input": "ping\n"})
+	if res.IsError {
+		t.Fatalf("write: %s", res.ForLLM)
+	}
+	waitFor(t, func() bool {
+		proc, _ := manager.Get("telegram", "42", id)
+		return strings.Contains(proc.output.tail(100), "got:ping")
+	})
+	res = processTool.Execute(ctx, map[string]any{"action": "read", "id": id})
+	if strings.Contains(res.ForLLM, "ready") || !strings.Contains(res.ForLLM, "got:ping") {
+		t.Errorf("incremental read = %s", res.ForLLM)
+	}
+
+	res = processTool.Execute(ctx, map[string]any{"action": "write", "id": id, "close_stdin": true})
+	if res.IsError {
+		t.Fatalf("close stdin: %s", res.ForLLM)
+	}
+	waitFor(t, func() bool {
+		proc, _ := manager.Get("telegram", "42", id)
+		return !proc.Running()
+	})
+	res = processTool.Execute(ctx, map[string]any{"action": "status", "id": id})
+	if !strings.Contains(res.ForLLM, "exited with code 0") {
+		t.Errorf("status = %s", res.ForLLM)
+	}
+}
+
+func TestProcessTool_SessionScopeLimitAndKill(t *testing.T) {
+	execTool, processTool, _ := newBackgroundExecTool(t, nil)
+	ctx := context.Background()
+
+	first := startBackground(t, execTool, "sleep 60")
+	startBackground(t, execTool, "sleep 60")
+	res := execTool.Execute(ctx, map[string]any{"command": "sleep 60", "background": true})
+	if !res.IsError || !strings.Contains(res.ForLLM, "too many") {
+		t.Errorf("third process should hit the per-session limit: %s", res.ForLLM)
+	}
+
+	other := NewProcessTool(processTool.manager)
+	other.SetContext("discord", "7")
+	res = other.Execute(ctx, map[string]any{"action": "status", "id": first})
+	if !res.IsError {
+		t.Errorf("other sessions must not see the process: %s", res.ForLLM)
+	}
+	if res = other.Execute(ctx, map[string]any{"action": "list"}); !strings.Contains(res.ForLLM, "No background") {
+		t.Errorf("other session list = %s", res.ForLLM)
+	}
+
+	res = processTool.Execute(ctx, map[string]any{"action": "kill", "id": first})
+	if !strings.Contains(res.ForLLM, "killed") {
+		t.Errorf("kill = %s", res.ForLLM)
+	}
+	res = processTool.Execute(ctx, map[string]any{"action": "kill", "id": "all"})
+	if !strings.Contains(res.ForLLM, "Killed 1") {
+		t.Errorf("kill all = %s", res.ForLLM)
+	}
+	if res = processTool.Execute(ctx, map[string]any{"action": "list"}); strings.Contains(res.ForLLM, "running") {
+		t.Errorf("list after kill = %s", res.ForLLM)
+	}
+}
+
+func TestProcessManager_AnnouncesCompletion(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	execTool, _, _ := newBackgroundExecTool(t, msgBus)
+
+	id := startBackground(t, execTool, "echo build done; exit 3")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no completion announcement")
+	}
+	if msg.Channel != "system" || msg.ChatID != "telegram:42" || msg.SenderID != fmt.Sprintf("process:%s", id) {
+		t.Errorf("announcement routing = %+v", msg)
+	}
+	if !strings.Contains(msg.Content, "exited with code 3") || !strings.Contains(msg.Content, "build done") {
+		t.Errorf("announcement content = %s", msg.Content)
+	}
+}
+
+func TestProcessManager_MaxRuntime(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	execTool, _, manager := newBackgroundExecTool(t, msgBus)
+	manager.SetMaxRuntime(200 * time.Millisecond)
+
+	startBackground(t, execTool, "echo serving; sleep 30")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("a process stopped at the runtime limit should be announced")
+	}
+	if !strings.Contains(msg.Content, "stopped at the runtime limit") || !strings.Contains(msg.Content, "serving") {
+		t.Errorf("announcement content = %s", msg.Content)
+	}
+}
+
+func TestExecTool_BackgroundRequiresManager(t *testing.T) {
+	tool := NewExecTool(t.TempDir(), false)
+	res := tool.Execute(context.Background(), map[string]any{"command": "echo hi", "background": true})
+	if !res.IsError {
+		t.Errorf("background without a process manager should fail: %s", res.ForLLM)
+	}
+}
@@ -0,0 +1,387 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var pwmChipName = regexp.MustCompile(`^pwmchip\d+$`)
+
+// pwmExportWait bounds how long to wait for udev to set up an exported channel.
+const pwmExportWait = time.Second
+
+// PWMTool drives PWM channels (LED dimming, servos, fans) through the Linux
+// sysfs PWM interface. Only the channels allowed in the config can be used.
+type PWMTool struct {
+	allowed map[string][]int // chip name -> channel numbers
+	root    string           // /sys/class/pwm
+}
+
+// NewPWMTool creates a PWM tool limited to the given channels, keyed by chip
+// name (e.g. "pwmchip0").
+func NewPWMTool(allowed map[string][]int) *PWMTool {
+	return &PWMTool{allowed: allowed, root: "/sys/class/pwm"}
+}
+
+func (t *PWMTool) Name() string {
+	return "pwm"
+}
+
+func (t *PWMTool) Description() string {
+	return "Control PWM outputs (LED brightness, servos, fans, buzzers) through Linux sysfs. Actions: list (allowed channels and their state), get (channel state), set (frequency or period, duty cycle and polarity; enables the output), disable (stop the output). Only channels allowed in the config can be used. Linux only."
+}
+
+func (t *PWMTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "get", "set", "disable"},
+				"description": "Action to perform",
+			},
+			"chip": map[string]any{
+				"type":        "string",
+				"description": "PWM chip name, e.g. \"pwmchip0\". Required except for list.",
+			},
+			"channel": map[string]any{
+				"type":        "integer",
+				"description": "PWM channel on the chip. Required except for list.",
+			},
+			"frequency_hz": map[string]any{
+				"type":        "number",
+				"description": "Output frequency in Hz (set). Alternative to period_ns; the current period is kept when both are unset.",
+			},
+			"period_ns": map[string]any{
+				"type":        "integer",
+				"description": "Period in nanoseconds (set)",
+			},
+			"duty_percent": map[string]any{
+				"type":        "number",
+				"description": "Duty cycle in percent, 0-100 (set). Alternative to duty_ns.",
+			},
+			"duty_ns": map[string]any{
+				"type":        "integer",
+				"description": "Active time per period in nanoseconds (set)",
+			},
+			"polarity": map[string]any{
+				"type":        "string",
+				"enum":        []string{"normal", "inversed"},
+				"description": "Output polarity (set)",
+			},
+			"confirm": map[string]any{
+				"type":        "boolean",
+				"description": "Must be true for set and disable. Safety guard to prevent accidental writes.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *PWMTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "list":
+		return t.list()
+	case "get":
+		return t.get(args)
+	case "set":
+		return t.set(args)
+	case "disable":
+		return t.disable(args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, get, set, disable)", action))
+	}
+}
+
+type pwmState struct {
+	Chip        string  `json:"chip"`
+	Channel     int     `json:"channel"`
+	Exported    bool    `json:"exported"`
+	Enabled     bool    `json:"enabled"`
+	PeriodNS    int64   `json:"period_ns,omitempty"`
+	DutyNS      int64   `json:"duty_ns,omitempty"`
+	DutyPercent float64 `json:"duty_percent,omitempty"`
+	FrequencyHz float64 `json:"frequency_hz,omitempty"`
+	Polarity    string  `json:"polarity,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+func (t *PWMTool) list() *ToolResult {
+	chips := make([]string, 0, len(t.allowed))
+	for chip := range t.allowed {
+		chips = append(chips, chip)
+	}
+	sort.Strings(chips)
+
+	var states []pwmState
+	for _, chip := range chips {
+		for _, ch := range t.allowed[chip] {
+			states = append(states, t.state(chip, ch))
+		}
+	}
+	if len(states) == 0 {
+		return SilentResult("No PWM channels are allowed. Add them to tools.pwm.channels in the config.")
+	}
+	result, _ := json.MarshalIndent(states, "", "  ")
+	return SilentResult(fmt.Sprintf("Allowed PWM channels:\n%s", string(result)))
+}
+
+func (t *PWMTool) get(args map[string]any) *ToolResult {
+	chip, ch, errResult := t.parseChannel(args)
+	if errResult != nil {
+		return errResult
+	}
+	st := t.state(chip, ch)
+	if st.Error != "" {
+		return ErrorResult(fmt.Sprintf("failed to read %s channel %d: %s", chip, ch, st.Error))
+	}
+	result, _ := json.MarshalIndent(st, "", "  ")
+	return SilentResult(string(result))
+}
+
+// state reads the sysfs attributes of a channel.
+func (t *PWMTool) state(chip string, ch int) pwmState {
+	st := pwmState{Chip: chip, Channel: ch}
+	dir := t.channelDir(chip, ch)
+	if _, err := os.Stat(dir); err != nil {
+		if _, err := os.Stat(filepath.Join(t.root, chip)); err != nil {
+			st.Error = fmt.Sprintf("%s not found", chip)
+		}
+		return st
+	}
+	st.Exported = true
+
+	var err error
+	if st.PeriodNS, err = readSysfsInt(filepath.Join(dir, "period")); err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	if st.DutyNS, err = readSysfsInt(filepath.Join(dir, "duty_cycle")); err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	enable, err := readSysfsInt(filepath.Join(dir, "enable"))
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	st.Enabled = enable == 1
+	if p, err := readSysfs(filepath.Join(dir, "polarity")); err == nil {
+		st.Polarity = p
+	}
+	if st.PeriodNS > 0 {
+		st.FrequencyHz = math.Round(1e9/float64(st.PeriodNS)*1000) / 1000
+		st.DutyPercent = math.Round(float64(st.DutyNS)/float64(st.PeriodNS)*10000) / 100
+	}
+	return st
+}
+
+func (t *PWMTool) set(args map[string]any) *ToolResult {
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		return ErrorResult(
+			"set operations require confirm: true. Please confirm with the user before driving PWM outputs, as they may move or power connected equipment.",
+		)
+	}
+	chip, ch, errResult := t.parseChannel(args)
+	if errResult != nil {
+		return errResult
+	}
+	if err := t.export(chip, ch); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to export %s channel %d: %v", chip, ch, err))
+	}
+	dir := t.channelDir(chip, ch)
+	cur := t.state(chip, ch)
+	if cur.Error != "" {
+		return ErrorResult(fmt.Sprintf("failed to read %s channel %d: %s", chip, ch, cur.Error))
+	}
+
+	period := cur.PeriodNS
+	if f, ok := args["frequency_hz"].(float64); ok {
+		if f <= 0 || f > 1e9 {
+			return ErrorResult("frequency_hz must be between 0 and 1e9")
+		}
+		period = int64(math.Round(1e9 / f))
+	} else if p, ok := args["period_ns"].(float64); ok {
+		if p < 1 {
+			return ErrorResult("period_ns must be positive")
+		}
+		period = int64(p)
+	}
+	if period <= 0 {
+		return ErrorResult("frequency_hz or period_ns is required: the channel has no period set")
+	}
+
+	// Without a new duty cycle, keep the current ratio.
+	duty := cur.DutyNS
+	if cur.PeriodNS > 0 && period != cur.PeriodNS {
+		duty = int64(math.Round(float64(period) * float64(cur.DutyNS) / float64(cur.PeriodNS)))
+	}
+	if d, ok := args["duty_percent"].(float64); ok {
+		if d < 0 || d > 100 {
+			return ErrorResult("duty_percent must be between 0 and 100")
+		}
+		duty = int64(math.Round(float64(period) * d / 100))
+	} else if d, ok := args["duty_ns"].(float64); ok {
+		if d < 0 {
+			return ErrorResult("duty_ns must not be negative")
+		}
+		duty = int64(d)
+	}
+	if duty > period {
+		return ErrorResult(fmt.Sprintf("duty cycle %dns is longer than the period %dns", duty, period))
+	}
+
+	// Polarity can only change while the output is disabled.
+	if polarity, ok := args["polarity"].(string); ok && polarity != "" && polarity != cur.Polarity {
+		if polarity != "normal" && polarity != "inversed" {
+			return ErrorResult("polarity must be normal or inversed")
+		}
+		if err := writeSysfs(filepath.Join(dir, "enable"), "0"); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to disable %s channel %d: %v", chip, ch, err))
+		}
+		if err := writeSysfs(filepath.Join(dir, "polarity"), polarity); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to set polarity: %v", err))
+		}
+	}
+
+	// The kernel rejects a duty cycle longer than the period, so shrink the
+	// duty cycle first when the new period is shorter than it.
+	order := []string{"period", "duty_cycle"}
+	values := map[string]int64{"period": period, "duty_cycle": duty}
+	if period < cur.DutyNS {
+		order = []string{"duty_cycle", "period"}
+	}
+	for _, attr := range order {
+		if err := writeSysfs(filepath.Join(dir, attr), strconv.FormatInt(values[attr], 10)); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to set %s: %v", attr, err))
+		}
+	}
+	if err := writeSysfs(filepath.Join(dir, "enable"), "1"); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to enable %s channel %d: %v", chip, ch, err))
+	}
+
+	st := t.state(chip, ch)
+	return SilentResult(fmt.Sprintf("%s channel %d enabled: %.3f Hz, duty %.2f%% (%dns of %dns)",
+		chip, ch, st.FrequencyHz, st.DutyPercent, st.DutyNS, st.PeriodNS))
+}
+
+func (t *PWMTool) disable(args map[string]any) *ToolResult {
+	confirm, _ := args["confirm"].(bool)
+	if !confirm {
+		return ErrorResult("disable requires confirm: true. Please confirm with the user before stopping PWM outputs.")
+	}
+	chip, ch, errResult := t.parseChannel(args)
+	if errResult != nil {
+		return errResult
+	}
+	dir := t.channelDir(chip, ch)
+	if _, err := os.Stat(dir); err != nil {
+		return SilentResult(fmt.Sprintf("%s channel %d is not exported", chip, ch))
+	}
+	if err := writeSysfs(filepath.Join(dir, "enable"), "0"); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to disable %s channel %d: %v", chip, ch, err))
+	}
+	return SilentResult(fmt.Sprintf("%s channel %d disabled", chip, ch))
+}
+
+// export makes a channel available in sysfs and waits until its attributes
+// are writable.
+func (t *PWMTool) export(chip string, ch int) error {
+	dir := t.channelDir(chip, ch)
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := writeSysfs(filepath.Join(t.root, chip, "export"), strconv.Itoa(ch)); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(pwmExportWait)
+	for {
+		f, err := os.OpenFile(filepath.Join(dir, "period"), os.O_WRONLY, 0)
+		if err == nil {
+			f.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (t *PWMTool) channelDir(chip string, ch int) string {
+	return filepath.Join(t.root, chip, fmt.Sprintf("pwm%d", ch))
+}
+
+// parseChannel extracts chip and channel from args and checks them against
+// the allowlist.
+func (t *PWMTool) parseChannel(args map[string]any) (string, int, *ToolResult) {
+	chip, ok := args["chip"].(string)
+	if !ok || chip == "" {
+		return "", 0, ErrorResult("chip is required (e.g. \"pwmchip0\")")
+	}
+	if !pwmChipName.MatchString(chip) {
+		return "", 0, ErrorResult("invalid chip name: must look like \"pwmchip0\"")
+	}
+	v, ok := args["channel"].(float64)
+	if !ok || v < 0 || v != float64(int(v)) {
+		return "", 0, ErrorResult("channel is required and must be a non-negative integer")
+	}
+	ch := int(v)
+	if !slices.Contains(t.allowed[chip], ch) {
+		return "", 0, ErrorResult(fmt.Sprintf(
+			"%s channel %d is not allowed. Allowed channels are listed under tools.pwm.channels in the config.", chip, ch))
+	}
+	return chip, ch, nil
+}
+
+// Helper functions for sysfs attributes (shared with the ADC tool)
+
+func readSysfs(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readSysfsInt(path string) (int64, error) {
+	s, err := readSysfs(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return v, nil
+}
+
+// writeSysfs writes an attribute. Unlike os.WriteFile it does not create
+// missing files, so a typo cannot leave stray files in sysfs.
+func writeSysfs(path, value string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakePWMTree creates pwmchip0 with channel 0 exported.
+func fakePWMTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	ch := filepath.Join(root, "pwmchip0", "pwm0")
+	if err := os.MkdirAll(ch, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"pwmchip0/npwm":            "2\n",
+		"pwmchip0/export":          "",
+		"pwmchip0/pwm0/period":     "0\n",
+		"pwmchip0/pwm0/duty_cycle": "0\n",
+		"pwmchip0/pwm0/enable":     "0\n",
+		"pwmchip0/pwm0/polarity":   "normal\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func readFakeSysfs(t *testing.T, path string) string {
+	t.Helper()
+	s, err := readSysfs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPWMSet(t *testing.T) {
+	root := fakePWMTree(t)
+	tool := NewPWMTool(map[string][]int{"pwmchip0": {0}})
+	tool.root = root
+	ch := filepath.Join(root, "pwmchip0", "pwm0")
+
+	args := map[string]any{
+		"action":       "set",
+		"chip":         "pwmchip0",
+		"channel":      float64(0),
+		"frequency_hz": float64(1000),
+		"duty_percent": float64(25),
+	}
+	if result := tool.Execute(context.Background(), args); !result.IsError {
+		t.Fatal("set without confirm should fail")
+	}
+	args["confirm"] = true
+	result := tool.Execute(context.Background(), args)
+	if result.IsError {
+		t.Fatalf("set: %s", result.ForLLM)
+	}
+	for attr, want := range map[string]string{"period": "1000000", "duty_cycle": "250000", "enable": "1"} {
+		if got := readFakeSysfs(t, filepath.Join(ch, attr)); got != want {
+			t.Errorf("%s = %q, want %q", attr, got, want)
+		}
+	}
+	if !strings.Contains(result.ForLLM, "1000.000 Hz, duty 25.00%") {
+		t.Errorf("result = %q", result.ForLLM)
+	}
+
+	// A new frequency keeps the duty ratio.
+	result = tool.Execute(context.Background(), map[string]any{
+		"action": "set", "chip": "pwmchip0", "channel": float64(0), "frequency_hz": float64(2000), "confirm": true,
+	})
+	if result.IsError || readFakeSysfs(t, filepath.Join(ch, "duty_cycle")) != "125000" {
+		t.Errorf("frequency change: %s, duty %s", result.ForLLM, readFakeSysfs(t, filepath.Join(ch, "duty_cycle")))
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"action": "disable", "chip": "pwmchip0", "channel": float64(0), "confirm": true,
+	})
+	if result.IsError || readFakeSysfs(t, filepath.Join(ch, "enable")) != "0" {
+		t.Errorf("disable: %s", result.ForLLM)
+	}
+}
+
+func TestPWMValidation(t *testing.T) {
+	tool := NewPWMTool(map[string][]int{"pwmchip0": {0}})
+	tool.root = fakePWMTree(t)
+
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"channel": float64(1)}, "not allowed"},
+		{map[string]any{"chip": "../pwmchip0"}, "invalid chip name"},
+		{map[string]any{"duty_percent": float64(50)}, "period_ns is required"},
+		{map[string]any{"frequency_hz": float64(1000), "duty_percent": float64(150)}, "between 0 and 100"},
+		{map[string]any{"period_ns": float64(1000), "duty_ns": float64(2000)}, "longer than the period"},
+	}
+	for _, tt := range tests {
+		args := map[string]any{"action": "set", "chip": "pwmchip0", "channel": float64(0), "confirm": true}
+		for k, v := range tt.args {
+			args[k] = v
+		}
+		result := tool.Execute(context.Background(), args)
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%v: got %q, want %q", tt.args, result.ForLLM, tt.want)
+		}
+	}
+}
+
+func TestPWMExport(t *testing.T) {
+	root := fakePWMTree(t)
+	tool := NewPWMTool(map[string][]int{"pwmchip0": {1}})
+	tool.root = root
+
+	// The fake tree has no kernel behind it, so the channel never appears.
+	err := tool.export("pwmchip0", 1)
+	if err == nil {
+		t.Fatal("export should time out when the channel does not appear")
+	}
+	if got := readFakeSysfs(t, filepath.Join(root, "pwmchip0", "export")); got != "1" {
+		t.Errorf("export = %q, want 1", got)
+	}
+}
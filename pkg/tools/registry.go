@@ -12,8 +12,9 @@ import (
 )
 
 type ToolRegistry struct {
-	tools map[string]Tool
-	mu    sync.RWMutex
+	tools  map[string]Tool
+	filter *ToolFilter
+	mu     sync.RWMutex
 }
 
 func NewToolRegistry() *ToolRegistry {
@@ -22,12 +23,48 @@ func NewToolRegistry() *ToolRegistry {
 	}
 }
 
+// Register adds a tool to the registry. Tools the registry's filter denies
+// are skipped.
 func (r *ToolRegistry) Register(tool Tool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if !r.filter.Allows(tool.Name()) {
+		logger.DebugCF("tool", "Tool not registered, denied by tool filter",
+			map[string]any{
+				"tool": tool.Name(),
+			})
+		return
+	}
 	r.tools[tool.Name()] = tool
 }
 
+// Unregister removes the named tool, if registered.
+func (r *ToolRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tools, name)
+}
+
+// SetFilter restricts the registry to the tools the filter allows and removes
+// registered tools it denies. A nil filter allows every tool.
+func (r *ToolRegistry) SetFilter(filter *ToolFilter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.filter = filter
+	for name := range r.tools {
+		if !filter.Allows(name) {
+			delete(r.tools, name)
+		}
+	}
+}
+
+// Filter returns the registry's tool filter, or nil when every tool is allowed.
+func (r *ToolRegistry) Filter() *ToolFilter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.filter
+}
+
 func (r *ToolRegistry) Get(name string) (Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -134,7 +171,8 @@ func (r *ToolRegistry) GetDefinitions() []map[string]any {
 }
 
 // ToProviderDefs converts tool definitions to provider-compatible format.
-// This is the format expected by LLM provider APIs.
+// This is the format expected by LLM provider APIs. Tools the registry's
+// filter denies are left out.
 func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -142,6 +180,9 @@ func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
 	sorted := r.sortedToolNames()
 	definitions := make([]providers.ToolDefinition, 0, len(sorted))
 	for _, name := range sorted {
+		if !r.filter.Allows(name) {
+			continue
+		}
 		tool := r.tools[name]
 		schema := ToolToSchema(tool)
 
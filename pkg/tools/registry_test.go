@@ -250,6 +250,50 @@ func TestToolRegistry_ToProviderDefs(t *testing.T) {
 	}
 }
 
+func TestToolRegistry_Filter(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(newMockTool("exec", ""))
+	r.Register(newMockTool("read_file", ""))
+
+	filter, err := NewToolFilter(nil, []string{"exec"})
+	if err != nil {
+		t.Fatalf("NewToolFilter() error: %v", err)
+	}
+	r.SetFilter(filter)
+	if _, ok := r.Get("exec"); ok {
+		t.Error("denied tool registered before SetFilter was kept")
+	}
+
+	r.Register(newMockTool("exec", ""))
+	r.Register(newMockTool("web_fetch", ""))
+	if got := r.List(); len(got) != 2 || got[0] != "read_file" || got[1] != "web_fetch" {
+		t.Errorf("List() = %v, want [read_file web_fetch]", got)
+	}
+	for _, def := range r.ToProviderDefs() {
+		if def.Function.Name == "exec" {
+			t.Error("ToProviderDefs() includes a denied tool")
+		}
+	}
+	if result := r.Execute(context.Background(), "exec", nil); !result.IsError {
+		t.Error("executing a denied tool succeeded")
+	}
+}
+
+func TestToolRegistry_Unregister(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(newMockTool("x", ""))
+	r.Register(newMockTool("y", ""))
+
+	r.Unregister("x")
+	r.Unregister("missing")
+	if _, ok := r.Get("x"); ok {
+		t.Error("x should be gone")
+	}
+	if _, ok := r.Get("y"); !ok {
+		t.Error("y should still be registered")
+	}
+}
+
 func TestToolRegistry_List(t *testing.T) {
 	r := NewToolRegistry()
 	r.Register(newMockTool("x", ""))
@@ -0,0 +1,446 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultGrepMaxResults = 100
+	maxGrepMaxResults     = 1000
+	maxGrepContext        = 10
+	maxGrepLineLen        = 300
+	maxGrepFileSize       = 10 * 1024 * 1024
+	maxSearchOutputBytes  = 64 * 1024
+	maxGlobResults        = 200
+)
+
+// searchSkipDirs are not descended into by grep and glob unless given as the
+// search path itself.
+var searchSkipDirs = map[string]bool{
+	".git":         true,
+	".history":     true,
+	".hg":          true,
+	".svn":         true,
+	"node_modules": true,
+}
+
+// GrepTool searches file contents with a regular expression.
+type GrepTool struct {
+	fs        fileSystem
+	workspace string
+}
+
+func NewGrepTool(workspace string, restrict bool) *GrepTool {
+	var fs fileSystem
+	if restrict {
+		fs = &sandboxFs{workspace: workspace}
+	} else {
+		fs = &hostFs{}
+	}
+	return &GrepTool{fs: fs, workspace: workspace}
+}
+
+func (t *GrepTool) Name() string {
+	return "grep"
+}
+
+func (t *GrepTool) Description() string {
+	return "Search file contents with a regular expression (RE2 syntax). " +
+		"Prints matching lines as path:line:text. Skips binary files and .git/node_modules."
+}
+
+func (t *GrepTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Regular expression to search for",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "File or directory to search (default: workspace)",
+			},
+			"include": map[string]any{
+				"type":        "string",
+				"description": "Only search files matching this glob, e.g. \"*.go\", \"*.{ts,tsx}\" or \"src/**/*.py\"",
+			},
+			"context": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Lines of context before and after each match (max %d)", maxGrepContext),
+			},
+			"ignore_case": map[string]any{
+				"type":        "boolean",
+				"description": "Match case-insensitively",
+			},
+			"max_results": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum matching lines to return (default %d)", defaultGrepMaxResults),
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GrepTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	if ignoreCase, _ := args["ignore_case"].(bool); ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("invalid pattern: %v", err))
+	}
+
+	var include *regexp.Regexp
+	includeBase := false
+	if glob, _ := args["include"].(string); glob != "" {
+		if include, err = globToRegexp(glob); err != nil {
+			return ErrorResult(fmt.Sprintf("invalid include glob: %v", err))
+		}
+		includeBase = !strings.Contains(glob, "/")
+	}
+
+	g := &grepSearch{
+		re:         re,
+		maxResults: defaultGrepMaxResults,
+	}
+	if v, ok := args["context"].(float64); ok && v > 0 {
+		g.context = min(int(v), maxGrepContext)
+	}
+	if v, ok := args["max_results"].(float64); ok && v > 0 {
+		g.maxResults = min(int(v), maxGrepMaxResults)
+	}
+
+	root := searchRoot(args, t.workspace)
+	err = t.fs.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // unreadable entries are skipped
+		}
+		if d.IsDir() {
+			if path != root && searchSkipDirs[d.Name()] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel := searchRelPath(root, path)
+		if include != nil {
+			name := rel
+			if includeBase {
+				name = d.Name()
+			}
+			if !include.MatchString(filepath.ToSlash(name)) {
+				return nil
+			}
+		}
+		if info, err := d.Info(); err == nil && info.Size() > maxGrepFileSize {
+			return nil
+		}
+
+		f, err := t.fs.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		if g.searchFile(f, rel) {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("grep failed: %v", err))
+	}
+
+	if g.matches == 0 {
+		return NewToolResult(fmt.Sprintf("No matches for %q in %s", pattern, root))
+	}
+	output := strings.TrimSuffix(g.out.String(), "\n")
+	if g.truncated {
+		output += fmt.Sprintf("\n\n[Results truncated after %d matching lines in %d files. "+
+			"Narrow the pattern, path or include glob.]", g.matches, g.files)
+	}
+	return NewToolResult(output)
+}
+
+// grepSearch accumulates grep output across files.
+type grepSearch struct {
+	re         *regexp.Regexp
+	context    int
+	maxResults int
+
+	out       strings.Builder
+	matches   int
+	files     int
+	truncated bool
+}
+
+// searchFile greps one file. It reports whether the search should stop
+// because a result limit was reached.
+func (g *grepSearch) searchFile(r io.Reader, name string) bool {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(8000); bytes.IndexByte(head, 0) >= 0 {
+		return false // binary
+	}
+
+	type line struct {
+		n    int
+		text string
+	}
+	var before []line
+	lineNo, lastPrinted, afterLeft := 0, 0, 0
+	matched := false
+
+	emit := func(n int, text string, sep byte) {
+		if g.context > 0 && lastPrinted > 0 && n > lastPrinted+1 {
+			g.out.WriteString("--\n")
+		}
+		if len(text) > maxGrepLineLen {
+			text = string(validUTF8Prefix([]byte(text[:maxGrepLineLen]))) + "..."
+		}
+		fmt.Fprintf(&g.out, "%s%c%d%c%s\n", filepath.ToSlash(name), sep, n, sep, text)
+		lastPrinted = n
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNo++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if g.re.MatchString(text) {
+			if g.matches >= g.maxResults || g.out.Len() >= maxSearchOutputBytes {
+				g.truncated = true
+				return true
+			}
+			if !matched {
+				if g.files > 0 && g.context > 0 {
+					g.out.WriteString("--\n")
+				}
+				matched = true
+				g.files++
+				lastPrinted = 0
+			}
+			for _, b := range before {
+				emit(b.n, b.text, '-')
+			}
+			before = before[:0]
+			emit(lineNo, text, ':')
+			g.matches++
+			afterLeft = g.context
+			continue
+		}
+
+		if afterLeft > 0 {
+			emit(lineNo, text, '-')
+			afterLeft--
+			continue
+		}
+		if g.context > 0 {
+			before = append(before, line{lineNo, text})
+			if len(before) > g.context {
+				before = before[1:]
+			}
+		}
+	}
+	return false
+}
+
+// GlobTool lists files whose paths match a glob pattern.
+type GlobTool struct {
+	fs        fileSystem
+	workspace string
+}
+
+func NewGlobTool(workspace string, restrict bool) *GlobTool {
+	var fs fileSystem
+	if restrict {
+		fs = &sandboxFs{workspace: workspace}
+	} else {
+		fs = &hostFs{}
+	}
+	return &GlobTool{fs: fs, workspace: workspace}
+}
+
+func (t *GlobTool) Name() string {
+	return "glob"
+}
+
+func (t *GlobTool) Description() string {
+	return "Find files by path pattern. Supports * and ? within a path segment, ** across directories " +
+		"and {a,b} alternatives, e.g. \"**/*.go\" or \"docs/*.{md,txt}\". Paths are relative to the search path."
+}
+
+func (t *GlobTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Glob pattern matched against paths relative to path",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory to search (default: workspace)",
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GlobTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	re, err := globToRegexp(strings.TrimPrefix(pattern, "./"))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("invalid glob: %v", err))
+	}
+
+	root := searchRoot(args, t.workspace)
+	var matches []string
+	err = t.fs.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && searchSkipDirs[d.Name()] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if rel := filepath.ToSlash(searchRelPath(root, path)); re.MatchString(rel) {
+			matches = append(matches, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("glob failed: %v", err))
+	}
+
+	if len(matches) == 0 {
+		return NewToolResult(fmt.Sprintf("No files match %q in %s", pattern, root))
+	}
+	sort.Strings(matches)
+	output := strings.Join(matches[:min(len(matches), maxGlobResults)], "\n")
+	if len(matches) > maxGlobResults {
+		output += fmt.Sprintf("\n\n[%d more files not shown. Use a narrower pattern.]", len(matches)-maxGlobResults)
+	}
+	return NewToolResult(output)
+}
+
+// searchRoot returns the path argument, or the workspace when it is empty.
+func searchRoot(args map[string]any, workspace string) string {
+	if path, _ := args["path"].(string); path != "" {
+		return path
+	}
+	if workspace != "" {
+		return workspace
+	}
+	return "."
+}
+
+// searchRelPath returns path relative to the search root, or its base name
+// when the root is the file itself.
+func searchRelPath(root, path string) string {
+	if path == root {
+		return filepath.Base(path)
+	}
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// globToRegexp converts a glob to an anchored regular expression over
+// slash-separated paths: * and ? stay within a segment, ** crosses
+// segments, "**/" also matches no directory, and {a,b} are alternatives.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	inClass := false
+	braces := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+			if c == '\\' {
+				b.WriteString(`\\`)
+				continue
+			}
+			b.WriteByte(c)
+		case c == '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				if i+2 < len(pattern) && pattern[i+2] == '/' {
+					b.WriteString("(?:.*/)?")
+					i += 2
+				} else {
+					b.WriteString(".*")
+					i++
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			inClass = true
+			b.WriteByte('[')
+			if i+1 < len(pattern) && pattern[i+1] == '!' {
+				b.WriteByte('^')
+				i++
+			}
+		case c == '{':
+			braces++
+			b.WriteString("(?:")
+		case c == '}' && braces > 0:
+			braces--
+			b.WriteString(")")
+		case c == ',' && braces > 0:
+			b.WriteString("|")
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	if inClass {
+		return nil, fmt.Errorf("unterminated [ in %q", pattern)
+	}
+	if braces > 0 {
+		return nil, fmt.Errorf("unterminated { in %q", pattern)
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
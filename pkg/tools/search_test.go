@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSearchTree(t *testing.T) string {
+	t.Helper()
+	workspace := t.TempDir()
+	files := map[string]string{
+		"main.go":               "package main\n\nfunc main() {\n\tstart()\n}\n",
+		"server/server.go":      "package server\n\n// Start starts the server.\nfunc Start() {}\n",
+		"server/server_test.go": "package server\n",
+		"web/app.ts":            "export function start() {}\n",
+		"web/app.tsx":           "const App = () => null\n",
+		"README.md":             "Run start to begin.\n",
+		"node_modules/x/x.js":   "start()\n",
+		".git/config":           "start\n",
+		"blob.bin":              "start\x00\x01",
+	}
+	for name, content := range files {
+		path := filepath.Join(workspace, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(content), 0o644)
+	}
+	return workspace
+}
+
+func TestGrepTool_SearchesWorkspace(t *testing.T) {
+	workspace := writeSearchTree(t)
+	tool := NewGrepTool(workspace, true)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"pattern": `start\(`})
+	if result.IsError {
+		t.Fatalf("grep failed: %s", result.ForLLM)
+	}
+	want := []string{"main.go:4:\tstart()", "web/app.ts:1:export function start() {}"}
+	if got := strings.Split(result.ForLLM, "\n"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("grep output = %q, want %q", got, want)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": `start\(`, "ignore_case": true})
+	if !strings.Contains(result.ForLLM, "server/server.go:4:func Start() {}") {
+		t.Errorf("ignore_case grep = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": "Start", "include": "*.go", "context": float64(1)})
+	want = []string{
+		"server/server.go-2-",
+		"server/server.go:3:// Start starts the server.",
+		"server/server.go:4:func Start() {}",
+	}
+	if got := strings.Split(result.ForLLM, "\n"); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("grep with context = %q, want %q", got, want)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": "start", "include": "web/*.{ts,tsx}"})
+	if result.ForLLM != "web/app.ts:1:export function start() {}" {
+		t.Errorf("grep with include path glob = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"pattern": "start", "max_results": float64(1)})
+	if !strings.Contains(result.ForLLM, "[Results truncated after 1 matching lines") {
+		t.Errorf("grep max_results = %q", result.ForLLM)
+	}
+
+	if result = tool.Execute(ctx, map[string]any{"pattern": "("}); !result.IsError {
+		t.Errorf("invalid pattern should fail: %q", result.ForLLM)
+	}
+}
+
+func TestGrepTool_RestrictedToWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("password=hunter2\n"), 0o600)
+	os.Symlink(outside, filepath.Join(workspace, "link"))
+
+	tool := NewGrepTool(workspace, true)
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "password", "path": outside})
+	if !result.IsError {
+		t.Errorf("grep outside the workspace should fail: %s", result.ForLLM)
+	}
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "password", "path": "link"})
+	if strings.Contains(result.ForLLM, "hunter2") {
+		t.Errorf("grep followed a symlink out of the workspace: %s", result.ForLLM)
+	}
+}
+
+func TestGlobTool_MatchesPaths(t *testing.T) {
+	workspace := writeSearchTree(t)
+	tool := NewGlobTool(workspace, true)
+	ctx := context.Background()
+
+	tests := []struct {
+		pattern string
+		path    string
+		want    string
+	}{
+		{"**/*.go", "", "main.go\nserver/server.go\nserver/server_test.go"},
+		{"*.go", "", "main.go"},
+		{"web/*.{ts,tsx}", "", "web/app.ts\nweb/app.tsx"},
+		{"*_test.go", "server", "server_test.go"},
+		{"**/x.js", "", "No files match"},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(ctx, map[string]any{"pattern": tt.pattern, "path": tt.path})
+		if result.IsError || !strings.HasPrefix(result.ForLLM, tt.want) {
+			t.Errorf("glob(%q, %q) = %q, want %q", tt.pattern, tt.path, result.ForLLM, tt.want)
+		}
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"pattern": "*", "path": "/"}); !result.IsError {
+		t.Errorf("glob outside the workspace should fail: %q", result.ForLLM)
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"src/**", "src/a/b", true},
+		{"file?.txt", "file1.txt", true},
+		{"file[!0-9].txt", "file1.txt", false},
+		{"*.{js,jsx}", "app.jsx", true},
+		{"a+b.txt", "a+b.txt", true},
+	}
+	for _, tt := range tests {
+		re, err := globToRegexp(tt.glob)
+		if err != nil {
+			t.Fatalf("globToRegexp(%q) error = %v", tt.glob, err)
+		}
+		if got := re.MatchString(tt.path); got != tt.match {
+			t.Errorf("%q matches %q = %v, want %v", tt.glob, tt.path, got, tt.match)
+		}
+	}
+	if _, err := globToRegexp("*.{go"); err == nil {
+		t.Error("unterminated brace should fail")
+	}
+}
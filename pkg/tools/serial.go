@@ -0,0 +1,552 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	serialDefaultBaud    = 115200
+	serialDefaultTimeout = time.Second
+	serialMaxTimeout     = 30 * time.Second
+	serialDefaultRead    = 4096
+	serialMaxRead        = 64 * 1024
+	// serialIdleGap ends a read without a delimiter once data stops arriving.
+	serialIdleGap = 100 * time.Millisecond
+)
+
+// serialConfig is the line setting of a port.
+type serialConfig struct {
+	Baud     int    `json:"baud"`
+	DataBits int    `json:"data_bits"`
+	Parity   string `json:"parity"` // none, even or odd
+	StopBits int    `json:"stop_bits"`
+}
+
+// serialPort is an open, configured port.
+type serialPort interface {
+	// Read waits up to timeout for data and returns what is available; it
+	// returns 0, nil on timeout.
+	Read(buf []byte, timeout time.Duration) (int, error)
+	Write(data []byte) (int, error)
+	// Flush discards unread input.
+	Flush() error
+	Close() error
+}
+
+type serialSession struct {
+	port    serialPort
+	cfg     serialConfig
+	pending []byte // read past the last delimiter
+}
+
+// SerialTool talks to microcontrollers and modems over serial ports. Only
+// ports matching the allowlist can be opened. Ports opened with the open
+// action stay open across calls until closed; other actions open the port for
+// the duration of the call.
+type SerialTool struct {
+	allowed     []string // glob patterns
+	defaultBaud int
+	openPort    func(path string, cfg serialConfig) (serialPort, error)
+
+	mu       sync.Mutex
+	sessions map[string]*serialSession
+}
+
+// NewSerialTool creates a serial tool limited to ports matching the allowed
+// glob patterns (e.g. "/dev/ttyUSB*").
+func NewSerialTool(allowed []string, defaultBaud int) *SerialTool {
+	if defaultBaud <= 0 {
+		defaultBaud = serialDefaultBaud
+	}
+	return &SerialTool{
+		allowed:     allowed,
+		defaultBaud: defaultBaud,
+		openPort:    openSerialPort,
+		sessions:    make(map[string]*serialSession),
+	}
+}
+
+func (t *SerialTool) Name() string {
+	return "serial"
+}
+
+func (t *SerialTool) Description() string {
+	return "Talk to microcontrollers, modems and other devices over serial ports (/dev/ttyUSB*, /dev/ttyACM*). Actions: list (allowed ports with USB vendor/product), open (keep a port open across calls), write (send data), read (read with a timeout or until a delimiter), query (write then read, e.g. AT commands), close. Only ports allowed in the config can be used. Linux only."
+}
+
+func (t *SerialTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "open", "write", "read", "query", "close"},
+				"description": "Action to perform",
+			},
+			"port": map[string]any{
+				"type":        "string",
+				"description": "Serial device path, e.g. \"/dev/ttyUSB0\". Required except for list.",
+			},
+			"baud": map[string]any{
+				"type":        "integer",
+				"description": "Baud rate, e.g. 9600 or 115200. Used when the port is opened.",
+			},
+			"data_bits": map[string]any{
+				"type":        "integer",
+				"enum":        []int{5, 6, 7, 8},
+				"description": "Data bits. Default: 8.",
+			},
+			"parity": map[string]any{
+				"type":        "string",
+				"enum":        []string{"none", "even", "odd"},
+				"description": "Parity. Default: none.",
+			},
+			"stop_bits": map[string]any{
+				"type":        "integer",
+				"enum":        []int{1, 2},
+				"description": "Stop bits. Default: 1.",
+			},
+			"data": map[string]any{
+				"type":        "string",
+				"description": "Data to send (write, query). Text, or hex bytes when encoding is hex.",
+			},
+			"line_ending": map[string]any{
+				"type":        "string",
+				"enum":        []string{"none", "lf", "cr", "crlf"},
+				"description": "Appended to data when writing. Default: none.",
+			},
+			"encoding": map[string]any{
+				"type":        "string",
+				"enum":        []string{"text", "hex"},
+				"description": "Encoding of data and of the data read back. Default: text.",
+			},
+			"until": map[string]any{
+				"type":        "string",
+				"description": "Stop reading once this text arrives, e.g. \"\\n\" or \"OK\". Without it, reading stops when data stops arriving.",
+			},
+			"timeout_ms": map[string]any{
+				"type":        "integer",
+				"description": "Read timeout in milliseconds, up to 30000. Default: 1000.",
+			},
+			"max_bytes": map[string]any{
+				"type":        "integer",
+				"description": "Maximum bytes to read, up to 65536. Default: 4096.",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *SerialTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, ok := args["action"].(string)
+	if !ok {
+		return ErrorResult("action is required")
+	}
+
+	switch action {
+	case "list":
+		return t.list()
+	case "open":
+		return t.open(args)
+	case "write", "read", "query":
+		return t.transfer(ctx, action, args)
+	case "close":
+		return t.closePort(args)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s (valid: list, open, write, read, query, close)", action))
+	}
+}
+
+// list reports the existing ports that match the allowlist.
+func (t *SerialTool) list() *ToolResult {
+	type portEntry struct {
+		Port    string        `json:"port"`
+		Device  string        `json:"device,omitempty"` // resolved /dev/tty* node
+		Vendor  string        `json:"vendor,omitempty"`
+		Product string        `json:"product,omitempty"`
+		Serial  string        `json:"serial,omitempty"`
+		Open    *serialConfig `json:"open,omitempty"` // settings of the open session
+	}
+
+	seen := make(map[string]bool)
+	var ports []portEntry
+	for _, pattern := range t.allowed {
+		matches, _ := filepath.Glob(pattern)
+		for _, m := range matches {
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+			entry := portEntry{Port: m, Open: t.sessionConfig(m)}
+			if dev, err := filepath.EvalSymlinks(m); err == nil && dev != m {
+				entry.Device = dev
+			}
+			entry.Vendor, entry.Product, entry.Serial = usbSerialInfo(m)
+			ports = append(ports, entry)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+
+	if len(ports) == 0 {
+		return SilentResult(fmt.Sprintf(
+			"No serial ports found. Allowed patterns: %s. Check that the device is plugged in.",
+			strings.Join(t.allowed, ", ")))
+	}
+	result, _ := json.MarshalIndent(ports, "", "  ")
+	return SilentResult(fmt.Sprintf("Serial ports:\n%s", string(result)))
+}
+
+// open opens a port and keeps it open until close.
+func (t *SerialTool) open(args map[string]any) *ToolResult {
+	path, errResult := t.parsePort(args)
+	if errResult != nil {
+		return errResult
+	}
+	cfg, errResult := t.parseConfig(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s, ok := t.sessions[path]; ok {
+		s.port.Close()
+		delete(t.sessions, path)
+	}
+	port, err := t.openPort(path, cfg)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to open %s: %v (check permissions, e.g. the dialout group)", path, err))
+	}
+	t.sessions[path] = &serialSession{port: port, cfg: cfg}
+	return SilentResult(fmt.Sprintf("Opened %s at %d baud, %d%s%d. It stays open until closed.",
+		path, cfg.Baud, cfg.DataBits, strings.ToUpper(cfg.Parity[:1]), cfg.StopBits))
+}
+
+// transfer runs write, read and query, on the open session of the port or
+// on a port opened for this call.
+func (t *SerialTool) transfer(ctx context.Context, action string, args map[string]any) *ToolResult {
+	path, errResult := t.parsePort(args)
+	if errResult != nil {
+		return errResult
+	}
+	encoding, _ := args["encoding"].(string)
+	if encoding == "" {
+		encoding = "text"
+	}
+	if encoding != "text" && encoding != "hex" {
+		return ErrorResult("encoding must be text or hex")
+	}
+
+	var out []byte
+	if action != "read" {
+		data, errResult := parseSerialData(args, encoding)
+		if errResult != nil {
+			return errResult
+		}
+		out = data
+	}
+	opts, errResult := parseSerialRead(args, encoding)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	session := t.sessions[path]
+	if session == nil {
+		cfg, errResult := t.parseConfig(args)
+		if errResult != nil {
+			return errResult
+		}
+		p, err := t.openPort(path, cfg)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to open %s: %v (check permissions, e.g. the dialout group)", path, err))
+		}
+		defer p.Close()
+		session = &serialSession{port: p, cfg: cfg}
+	}
+
+	if action == "query" {
+		// Drop stale input so the reply is not mixed with earlier output.
+		session.pending = nil
+		session.port.Flush()
+	}
+	if out != nil {
+		if _, err := session.port.Write(out); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to write to %s: %v", path, err))
+		}
+		if action == "write" {
+			return SilentResult(fmt.Sprintf("Wrote %d bytes to %s", len(out), path))
+		}
+	}
+
+	data, found, err := readSerial(ctx, session, opts)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read from %s: %v", path, err))
+	}
+	if len(data) == 0 {
+		return SilentResult(fmt.Sprintf("No data from %s within %s", path, opts.timeout))
+	}
+	note := ""
+	if len(opts.until) > 0 && !found {
+		note = fmt.Sprintf(" (delimiter not seen within %s)", opts.timeout)
+	}
+	return SilentResult(fmt.Sprintf("Read %d bytes from %s%s:\n%s", len(data), path, note,
+		formatSerialData(data, encoding)))
+}
+
+func (t *SerialTool) closePort(args map[string]any) *ToolResult {
+	path, errResult := t.parsePort(args)
+	if errResult != nil {
+		return errResult
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[path]
+	if !ok {
+		return SilentResult(fmt.Sprintf("%s is not open", path))
+	}
+	delete(t.sessions, path)
+	if err := s.port.Close(); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to close %s: %v", path, err))
+	}
+	return SilentResult(fmt.Sprintf("Closed %s", path))
+}
+
+func (t *SerialTool) sessionConfig(path string) *serialConfig {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s, ok := t.sessions[path]; ok {
+		cfg := s.cfg
+		return &cfg
+	}
+	return nil
+}
+
+// parsePort extracts the port from args and checks it against the allowlist.
+func (t *SerialTool) parsePort(args map[string]any) (string, *ToolResult) {
+	port, ok := args["port"].(string)
+	if !ok || port == "" {
+		return "", ErrorResult("port is required (e.g. \"/dev/ttyUSB0\")")
+	}
+	port = filepath.Clean(port)
+	for _, pattern := range t.allowed {
+		if ok, _ := filepath.Match(pattern, port); ok {
+			return port, nil
+		}
+	}
+	return "", ErrorResult(fmt.Sprintf(
+		"%s is not allowed. Allowed ports are listed under tools.serial.allowed_ports in the config.", port))
+}
+
+func (t *SerialTool) parseConfig(args map[string]any) (serialConfig, *ToolResult) {
+	cfg := serialConfig{Baud: t.defaultBaud, DataBits: 8, Parity: "none", StopBits: 1}
+	if v, ok := args["baud"].(float64); ok {
+		cfg.Baud = int(v)
+	}
+	if !isSupportedBaud(cfg.Baud) {
+		return cfg, ErrorResult(fmt.Sprintf("unsupported baud rate %d", cfg.Baud))
+	}
+	if v, ok := args["data_bits"].(float64); ok {
+		if v < 5 || v > 8 {
+			return cfg, ErrorResult("data_bits must be 5, 6, 7 or 8")
+		}
+		cfg.DataBits = int(v)
+	}
+	if v, ok := args["parity"].(string); ok && v != "" {
+		if v != "none" && v != "even" && v != "odd" {
+			return cfg, ErrorResult("parity must be none, even or odd")
+		}
+		cfg.Parity = v
+	}
+	if v, ok := args["stop_bits"].(float64); ok {
+		if v != 1 && v != 2 {
+			return cfg, ErrorResult("stop_bits must be 1 or 2")
+		}
+		cfg.StopBits = int(v)
+	}
+	return cfg, nil
+}
+
+func parseSerialData(args map[string]any, encoding string) ([]byte, *ToolResult) {
+	s, ok := args["data"].(string)
+	if !ok {
+		return nil, ErrorResult("data is required")
+	}
+	var data []byte
+	if encoding == "hex" {
+		b, err := hex.DecodeString(strings.NewReplacer(" ", "", ":", "", "0x", "").Replace(s))
+		if err != nil {
+			return nil, ErrorResult(fmt.Sprintf("invalid hex data: %v", err))
+		}
+		data = b
+	} else {
+		data = []byte(s)
+	}
+
+	ending, _ := args["line_ending"].(string)
+	switch ending {
+	case "", "none":
+	case "lf":
+		data = append(data, '\n')
+	case "cr":
+		data = append(data, '\r')
+	case "crlf":
+		data = append(data, '\r', '\n')
+	default:
+		return nil, ErrorResult("line_ending must be none, lf, cr or crlf")
+	}
+	if len(data) == 0 {
+		return nil, ErrorResult("data is empty")
+	}
+	return data, nil
+}
+
+type serialReadOptions struct {
+	until    []byte
+	timeout  time.Duration
+	maxBytes int
+}
+
+func parseSerialRead(args map[string]any, encoding string) (serialReadOptions, *ToolResult) {
+	opts := serialReadOptions{timeout: serialDefaultTimeout, maxBytes: serialDefaultRead}
+	if ms, ok := args["timeout_ms"].(float64); ok && ms > 0 {
+		opts.timeout = min(time.Duration(ms)*time.Millisecond, serialMaxTimeout)
+	}
+	if n, ok := args["max_bytes"].(float64); ok && n > 0 {
+		opts.maxBytes = min(int(n), serialMaxRead)
+	}
+	if until, ok := args["until"].(string); ok && until != "" {
+		if encoding == "hex" {
+			b, err := hex.DecodeString(strings.ReplaceAll(until, " ", ""))
+			if err != nil {
+				return opts, ErrorResult(fmt.Sprintf("invalid hex delimiter: %v", err))
+			}
+			opts.until = b
+		} else {
+			opts.until = []byte(until)
+		}
+	}
+	return opts, nil
+}
+
+// readSerial reads until the delimiter, maxBytes or the timeout. Without a
+// delimiter it also stops once data has arrived and the line goes quiet.
+// Bytes read past the delimiter are kept for the next read of the session.
+// found reports whether the delimiter was seen.
+func readSerial(ctx context.Context, s *serialSession, opts serialReadOptions) ([]byte, bool, error) {
+	deadline := time.Now().Add(opts.timeout)
+	buf := make([]byte, 1024)
+	data := s.pending
+	s.pending = nil
+	for {
+		if len(opts.until) > 0 {
+			if i := bytes.Index(data, opts.until); i >= 0 {
+				return s.keep(data, i+len(opts.until)), true, nil
+			}
+		}
+		if len(data) >= opts.maxBytes {
+			return s.keep(data, opts.maxBytes), false, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return data, false, err
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return data, false, nil
+		}
+		if len(opts.until) == 0 && len(data) > 0 {
+			wait = min(wait, serialIdleGap)
+		}
+		// Wake up regularly to notice cancellation.
+		n, err := s.port.Read(buf, min(wait, 200*time.Millisecond))
+		if err != nil {
+			return data, false, err
+		}
+		if n == 0 && len(opts.until) == 0 && len(data) > 0 && wait <= serialIdleGap {
+			return data, false, nil
+		}
+		data = append(data, buf[:n]...)
+	}
+}
+
+// keep returns data[:n] and saves the rest for the next read.
+func (s *serialSession) keep(data []byte, n int) []byte {
+	if n < len(data) {
+		s.pending = append([]byte(nil), data[n:]...)
+	}
+	return data[:n]
+}
+
+// formatSerialData renders data for the model: hex, plain text, or quoted
+// text when it contains control characters.
+func formatSerialData(data []byte, encoding string) string {
+	if encoding == "hex" {
+		var sb strings.Builder
+		for i, b := range data {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			fmt.Fprintf(&sb, "%02x", b)
+		}
+		return sb.String()
+	}
+	if !utf8.Valid(data) {
+		return strconv.Quote(string(data))
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+			return strconv.Quote(string(data))
+		}
+	}
+	return string(data)
+}
+
+// usbSerialInfo looks up the USB device behind a tty in sysfs.
+func usbSerialInfo(port string) (vendor, product, serial string) {
+	dev, err := filepath.EvalSymlinks(port)
+	if err != nil {
+		return "", "", ""
+	}
+	// /sys/class/tty/ttyUSB0/device points into the USB interface; the device
+	// attributes are one or two levels up.
+	sysDev, err := filepath.EvalSymlinks(filepath.Join("/sys/class/tty", filepath.Base(dev), "device"))
+	if err != nil {
+		return "", "", ""
+	}
+	for dir := sysDev; dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "idVendor")); err != nil {
+			continue
+		}
+		read := func(name string) string {
+			s, _ := readSysfs(filepath.Join(dir, name))
+			return s
+		}
+		vendor, product, serial = read("manufacturer"), read("product"), read("serial")
+		if vendor == "" {
+			vendor = read("idVendor")
+		}
+		if product == "" {
+			product = read("idProduct")
+		}
+		return vendor, product, serial
+	}
+	return "", "", ""
+}
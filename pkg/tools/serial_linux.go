@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// serialBauds maps baud rates to termios speed constants.
+var serialBauds = map[int]uint32{
+	1200:    unix.B1200,
+	2400:    unix.B2400,
+	4800:    unix.B4800,
+	9600:    unix.B9600,
+	19200:   unix.B19200,
+	38400:   unix.B38400,
+	57600:   unix.B57600,
+	115200:  unix.B115200,
+	230400:  unix.B230400,
+	460800:  unix.B460800,
+	500000:  unix.B500000,
+	576000:  unix.B576000,
+	921600:  unix.B921600,
+	1000000: unix.B1000000,
+	1500000: unix.B1500000,
+	2000000: unix.B2000000,
+	3000000: unix.B3000000,
+}
+
+var serialDataBits = map[int]uint32{5: unix.CS5, 6: unix.CS6, 7: unix.CS7, 8: unix.CS8}
+
+func isSupportedBaud(baud int) bool {
+	_, ok := serialBauds[baud]
+	return ok
+}
+
+type ttyPort struct {
+	fd int
+}
+
+// openSerialPort opens a tty in raw mode with exclusive access.
+func openSerialPort(path string, cfg serialConfig) (serialPort, error) {
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &ttyPort{fd: fd}
+	if err := p.configure(cfg); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *ttyPort) configure(cfg serialConfig) error {
+	var st unix.Stat_t
+	if err := unix.Fstat(p.fd, &st); err != nil {
+		return err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFCHR {
+		return fmt.Errorf("not a character device")
+	}
+
+	t, err := unix.IoctlGetTermios(p.fd, unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("not a serial port: %w", err)
+	}
+	baud := serialBauds[cfg.Baud]
+
+	// Raw mode, like cfmakeraw(3)
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL |
+		unix.IXON | unix.IXOFF | unix.IXANY | unix.INPCK
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CBAUD | unix.CRTSCTS
+	t.Cflag |= unix.CREAD | unix.CLOCAL | serialDataBits[cfg.DataBits] | baud
+	switch cfg.Parity {
+	case "even":
+		t.Cflag |= unix.PARENB
+		t.Iflag |= unix.INPCK
+	case "odd":
+		t.Cflag |= unix.PARENB | unix.PARODD
+		t.Iflag |= unix.INPCK
+	}
+	if cfg.StopBits == 2 {
+		t.Cflag |= unix.CSTOPB
+	}
+	t.Ispeed = baud
+	t.Ospeed = baud
+	t.Cc[unix.VMIN] = 0
+	t.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(p.fd, unix.TCSETS, t); err != nil {
+		return fmt.Errorf("set line settings: %w", err)
+	}
+
+	// Keep other programs from opening the port while we use it.
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(p.fd), unix.TIOCEXCL, 0); errno != 0 {
+		return fmt.Errorf("exclusive access: %w", errno)
+	}
+	return nil
+}
+
+func (p *ttyPort) Read(buf []byte, timeout time.Duration) (int, error) {
+	fds := []unix.PollFd{{Fd: int32(p.fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout.Milliseconds()))
+	if err == unix.EINTR || n == 0 {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if fds[0].Revents&(unix.POLLHUP|unix.POLLERR) != 0 && fds[0].Revents&unix.POLLIN == 0 {
+		return 0, fmt.Errorf("device disconnected")
+	}
+	n, err = unix.Read(p.fd, buf)
+	if err == unix.EAGAIN || err == unix.EINTR {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (p *ttyPort) Write(data []byte) (int, error) {
+	written := 0
+	for written < len(data) {
+		n, err := unix.Write(p.fd, data[written:])
+		if err == unix.EAGAIN || err == unix.EINTR {
+			fds := []unix.PollFd{{Fd: int32(p.fd), Events: unix.POLLOUT}}
+			if n, _ := unix.Poll(fds, int(serialMaxTimeout.Milliseconds())); n == 0 {
+				return written, fmt.Errorf("write timed out")
+			}
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+func (p *ttyPort) Flush() error {
+	return unix.IoctlSetInt(p.fd, unix.TCFLSH, unix.TCIFLUSH)
+}
+
+func (p *ttyPort) Close() error {
+	return unix.Close(p.fd)
+}
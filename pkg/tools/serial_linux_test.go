@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY returns the master side of a new pty and the path of its slave,
+// which stands in for a serial port.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pty support: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatalf("unlockpt: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatalf("ptsname: %v", err)
+	}
+	// Keep the master from echoing what the tool writes back to it.
+	if tio, err := unix.IoctlGetTermios(fd, unix.TCGETS); err == nil {
+		tio.Lflag &^= unix.ECHO | unix.ICANON
+		unix.IoctlSetTermios(fd, unix.TCSETS, tio)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+// readMaster reads what the tool sent, waiting up to a second.
+func readMaster(t *testing.T, master *os.File, n int) string {
+	t.Helper()
+	master.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, n)
+	got := 0
+	for got < n {
+		m, err := master.Read(buf[got:])
+		if err != nil {
+			t.Fatalf("read master after %q: %v", buf[:got], err)
+		}
+		got += m
+	}
+	return string(buf)
+}
+
+func TestSerialQueryOnPTY(t *testing.T) {
+	master, port := openPTY(t)
+	tool := NewSerialTool([]string{"/dev/pts/*"}, 0)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"action": "open", "port": port, "baud": float64(9600)})
+	if result.IsError {
+		t.Fatalf("open: %s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "list"})
+	if !strings.Contains(result.ForLLM, `"port": "`+port+`"`) || !strings.Contains(result.ForLLM, `"baud": 9600`) {
+		t.Errorf("list: %s", result.ForLLM)
+	}
+
+	// Reply from the "board" once the command arrives.
+	done := make(chan string, 1)
+	go func() {
+		cmd := readMaster(t, master, len("AT\r\n"))
+		master.Write([]byte("\r\nOK\r\nextra"))
+		done <- cmd
+	}()
+	result = tool.Execute(ctx, map[string]any{
+		"action":      "query",
+		"port":        port,
+		"data":        "AT",
+		"line_ending": "crlf",
+		"until":       "OK\r\n",
+		"timeout_ms":  float64(2000),
+	})
+	if result.IsError || !strings.HasSuffix(result.ForLLM, ":\n\r\nOK\r\n") {
+		t.Errorf("query: %s", result.ForLLM)
+	}
+	if cmd := <-done; cmd != "AT\r\n" {
+		t.Errorf("board received %q", cmd)
+	}
+
+	// The session keeps unread input until the next read.
+	result = tool.Execute(ctx, map[string]any{"action": "read", "port": port, "timeout_ms": float64(500)})
+	if result.IsError || !strings.HasSuffix(result.ForLLM, "\nextra") {
+		t.Errorf("read: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "read", "port": port, "timeout_ms": float64(50)})
+	if result.IsError || !strings.Contains(result.ForLLM, "No data") {
+		t.Errorf("read without data: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "write", "port": port, "data": "01 ff", "encoding": "hex"})
+	if result.IsError {
+		t.Fatalf("write: %s", result.ForLLM)
+	}
+	if got := readMaster(t, master, 2); got != "\x01\xff" {
+		t.Errorf("board received %q", got)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "close", "port": port})
+	if result.IsError || len(tool.sessions) != 0 {
+		t.Errorf("close: %s", result.ForLLM)
+	}
+}
+
+func TestSerialOneShotWrite(t *testing.T) {
+	master, port := openPTY(t)
+	tool := NewSerialTool([]string{"/dev/pts/*"}, 0)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"action": "write", "port": port, "data": "reset", "line_ending": "lf",
+	})
+	if result.IsError {
+		t.Fatalf("write: %s", result.ForLLM)
+	}
+	if got := readMaster(t, master, 6); got != "reset\n" {
+		t.Errorf("board received %q", got)
+	}
+	if len(tool.sessions) != 0 {
+		t.Error("a write without open should not keep the port open")
+	}
+}
+
+func TestSerialRejectsRegularFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/ttyUSB0"
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewSerialTool([]string{dir + "/*"}, 0)
+	result := tool.Execute(context.Background(), map[string]any{"action": "open", "port": path})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not a character device") {
+		t.Errorf("open: %s", result.ForLLM)
+	}
+}
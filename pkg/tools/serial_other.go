@@ -0,0 +1,14 @@
+//go:build !linux
+
+package tools
+
+import "errors"
+
+func isSupportedBaud(baud int) bool {
+	return baud > 0
+}
+
+// openSerialPort is a stub for non-Linux platforms.
+func openSerialPort(path string, cfg serialConfig) (serialPort, error) {
+	return nil, errors.New("serial ports are only supported on Linux")
+}
@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSerialValidation(t *testing.T) {
+	tool := NewSerialTool([]string{"/dev/ttyUSB*"}, 0)
+	tests := []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"action": "open", "port": "/dev/ttyS0"}, "not allowed"},
+		{map[string]any{"action": "open", "port": "/dev/ttyUSB0/../ttyS0"}, "not allowed"},
+		{map[string]any{"action": "open", "port": "/dev/ttyUSB0", "baud": float64(12345)}, "unsupported baud"},
+		{map[string]any{"action": "open", "port": "/dev/ttyUSB0", "parity": "mark"}, "parity"},
+		{map[string]any{"action": "write", "port": "/dev/ttyUSB0"}, "data is required"},
+		{map[string]any{"action": "write", "port": "/dev/ttyUSB0", "data": "zz", "encoding": "hex"}, "invalid hex"},
+		{map[string]any{"action": "write", "port": "/dev/ttyUSB0", "data": "x", "line_ending": "nl"}, "line_ending"},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(context.Background(), tt.args)
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%v: got %q, want %q", tt.args, result.ForLLM, tt.want)
+		}
+	}
+}
+
+func TestFormatSerialData(t *testing.T) {
+	tests := []struct {
+		data     string
+		encoding string
+		want     string
+	}{
+		{"OK\r\n", "text", "OK\r\n"},
+		{"\x01\x02", "text", `"\x01\x02"`},
+		{"\xff", "text", `"\xff"`},
+		{"\x01\xff", "hex", "01 ff"},
+	}
+	for _, tt := range tests {
+		if got := formatSerialData([]byte(tt.data), tt.encoding); got != tt.want {
+			t.Errorf("formatSerialData(%q, %s) = %q, want %q", tt.data, tt.encoding, got, tt.want)
+		}
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/sandbox"
 )
 
 type ExecTool struct {
@@ -22,6 +24,13 @@ type ExecTool struct {
 	denyPatterns        []*regexp.Regexp
 	allowPatterns       []*regexp.Regexp
 	restrictToWorkspace bool
+	// sandbox is set when commands run in the namespace sandbox; the deny
+	// patterns are then only a fallback and are not applied.
+	sandbox *sandbox.Config
+	// processes runs background commands; nil disables background=true.
+	processes *ProcessManager
+	channel   string
+	chatID    string
 }
 
 var defaultDenyPatterns = []*regexp.Regexp{
@@ -75,6 +84,7 @@ func NewExecTool(workingDir string, restrict bool) *ExecTool {
 
 func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Config) *ExecTool {
 	denyPatterns := make([]*regexp.Regexp, 0)
+	var sandboxCfg *sandbox.Config
 
 	if config != nil {
 		execConfig := config.Tools.Exec
@@ -96,6 +106,7 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 			// If deny patterns are disabled, we won't add any patterns, allowing all commands.
 			fmt.Println("Warning: deny patterns are disabled. All commands will be allowed.")
 		}
+		sandboxCfg = newSandboxConfig(workingDir, execConfig.Sandbox)
 	} else {
 		denyPatterns = append(denyPatterns, defaultDenyPatterns...)
 	}
@@ -106,6 +117,33 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 		denyPatterns:        denyPatterns,
 		allowPatterns:       nil,
 		restrictToWorkspace: restrict,
+		sandbox:             sandboxCfg,
+	}
+}
+
+// sandboxAvailable reports whether exec can sandbox commands; tests replace it.
+var sandboxAvailable = sandbox.Available
+
+// newSandboxConfig returns the sandbox settings for exec, or nil when the
+// sandbox is disabled or unavailable on this system.
+func newSandboxConfig(workingDir string, cfg config.ExecSandboxConfig) *sandbox.Config {
+	if !cfg.Enabled || workingDir == "" {
+		return nil
+	}
+	if err := sandboxAvailable(); err != nil {
+		logger.WarnCF("exec", "Sandbox unavailable, falling back to the command guard", map[string]any{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	return &sandbox.Config{
+		Workspace:     workingDir,
+		ReadOnlyPaths: cfg.ReadOnlyPaths,
+		WritablePaths: cfg.WritablePaths,
+		DenyNetwork:   cfg.DenyNetwork,
+		CPUSeconds:    cfg.CPUSeconds,
+		MemoryMB:      cfg.MemoryMB,
+		MaxProcesses:  cfg.MaxProcesses,
 	}
 }
 
@@ -114,7 +152,9 @@ func (t *ExecTool) Name() string {
 }
 
 func (t *ExecTool) Description() string {
-	return "Execute a shell command and return its output. Use with caution."
+	return "Execute a shell command and return its output. Use with caution. Set background=true for " +
+		"servers, watchers and long builds: the command keeps running, you get a process ID to use with " +
+		"the process tool, and you are notified when it exits."
 }
 
 func (t *ExecTool) Parameters() map[string]any {
@@ -129,11 +169,25 @@ func (t *ExecTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional working directory for the command",
 			},
+			"background": map[string]any{
+				"type":        "boolean",
+				"description": "Run the command in the background without a timeout and return a process ID",
+			},
 		},
 		"required": []string{"command"},
 	}
 }
 
+func (t *ExecTool) SetContext(channel, chatID string) {
+	t.channel = channel
+	t.chatID = chatID
+}
+
+// SetProcessManager enables background commands.
+func (t *ExecTool) SetProcessManager(manager *ProcessManager) {
+	t.processes = manager
+}
+
 func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	command, ok := args["command"].(string)
 	if !ok {
@@ -160,10 +214,18 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		}
 	}
 
-	if guardError := t.guardCommand(command, cwd); guardError != "" {
+	if t.sandbox != nil {
+		if guardError := t.checkAllowlist(command); guardError != "" {
+			return ErrorResult(guardError)
+		}
+	} else if guardError := t.guardCommand(command, cwd); guardError != "" {
 		return ErrorResult(guardError)
 	}
 
+	if background, _ := args["background"].(bool); background {
+		return t.startBackground(command, cwd)
+	}
+
 	// timeout == 0 means no timeout
 	var cmdCtx context.Context
 	var cancel context.CancelFunc
@@ -174,18 +236,11 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 	defer cancel()
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(cmdCtx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
-	} else {
-		cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
-	}
-	if cwd != "" {
-		cmd.Dir = cwd
+	cmd, err := t.buildCommand(cmdCtx, command, cwd)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to start command: %v", err)).WithError(err)
 	}
 
-	prepareCommandForTermination(cmd)
-
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -199,7 +254,6 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		done <- cmd.Wait()
 	}()
 
-	var err error
 	select {
 	case err = <-done:
 	case <-cmdCtx.Done():
@@ -231,14 +285,18 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		output += fmt.Sprintf("\nExit code: %v", err)
 	}
 
+	if err != nil && t.sandbox != nil {
+		if v := sandbox.Classify(err, stderr.String(), *t.sandbox); v != nil {
+			return ErrorResult(fmt.Sprintf("Sandbox violation (%s): %s\n\n%s", v.Kind, v.Detail,
+				truncateOutput(output))).WithError(v)
+		}
+	}
+
 	if output == "" {
 		output = "(no output)"
 	}
 
-	maxLen := 10000
-	if len(output) > maxLen {
-		output = output[:maxLen] + fmt.Sprintf("\n... (truncated, %d more chars)", len(output)-maxLen)
-	}
+	output = truncateOutput(output)
 
 	if err != nil {
 		return &ToolResult{
@@ -255,6 +313,57 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 }
 
+// buildCommand prepares the shell command, inside the sandbox when enabled.
+// The command runs in its own process group so it can be killed as a tree.
+func (t *ExecTool) buildCommand(ctx context.Context, command, cwd string) (*exec.Cmd, error) {
+	if t.sandbox != nil {
+		// The sandbox sets up its own process group.
+		return sandbox.Command(ctx, *t.sandbox, command, cwd)
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	if cwd != "" {
+		cmd.Dir = cwd
+	}
+	prepareCommandForTermination(cmd)
+	return cmd, nil
+}
+
+func (t *ExecTool) startBackground(command, cwd string) *ToolResult {
+	if t.processes == nil {
+		return ErrorResult("background commands are not available here")
+	}
+	// Background commands outlive the tool call; they stop when killed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd, err := t.buildCommand(ctx, command, cwd)
+	if err != nil {
+		cancel()
+		return ErrorResult(fmt.Sprintf("failed to start command: %v", err)).WithError(err)
+	}
+	proc, err := t.processes.Start(cmd, cancel, command, t.channel, t.chatID, t.sandbox)
+	if err != nil {
+		cancel()
+		return ErrorResult(fmt.Sprintf("failed to start background command: %v", err)).WithError(err)
+	}
+	return NewToolResult(fmt.Sprintf(
+		"Started background process %s (pid %d). Use the process tool to read its output, write to its "+
+			"stdin, check its status or kill it. You will be notified when it exits.",
+		proc.ID, cmd.Process.Pid,
+	))
+}
+
+func truncateOutput(output string) string {
+	maxLen := 10000
+	if len(output) > maxLen {
+		output = output[:maxLen] + fmt.Sprintf("\n... (truncated, %d more chars)", len(output)-maxLen)
+	}
+	return output
+}
+
 func (t *ExecTool) guardCommand(command, cwd string) string {
 	cmd := strings.TrimSpace(command)
 	lower := strings.ToLower(cmd)
@@ -265,17 +374,8 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 		}
 	}
 
-	if len(t.allowPatterns) > 0 {
-		allowed := false
-		for _, pattern := range t.allowPatterns {
-			if pattern.MatchString(lower) {
-				allowed = true
-				break
-			}
-		}
-		if !allowed {
-			return "Command blocked by safety guard (not in allowlist)"
-		}
+	if guardError := t.checkAllowlist(command); guardError != "" {
+		return guardError
 	}
 
 	if t.restrictToWorkspace {
@@ -311,6 +411,21 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	return ""
 }
 
+// checkAllowlist enforces the configured allow patterns. Unlike the deny
+// patterns it also applies inside the sandbox.
+func (t *ExecTool) checkAllowlist(command string) string {
+	if len(t.allowPatterns) == 0 {
+		return ""
+	}
+	lower := strings.ToLower(strings.TrimSpace(command))
+	for _, pattern := range t.allowPatterns {
+		if pattern.MatchString(lower) {
+			return ""
+		}
+	}
+	return "Command blocked by safety guard (not in allowlist)"
+}
+
 func (t *ExecTool) SetTimeout(timeout time.Duration) {
 	t.timeout = timeout
 }
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/sandbox"
+)
+
+func newSandboxedExecTool(t *testing.T, workspace string) *ExecTool {
+	t.Helper()
+	if err := sandbox.Available(); err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.Sandbox.Enabled = true
+	tool := NewExecToolWithConfig(workspace, true, cfg)
+	if tool.sandbox == nil {
+		t.Fatal("sandbox should be enabled")
+	}
+	return tool
+}
+
+func TestShellTool_SandboxWritesWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	tool := newSandboxedExecTool(t, workspace)
+
+	// The deny patterns are not needed inside the sandbox.
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": "mkdir -p build && echo $(echo ok) > build/out.txt && cat build/out.txt",
+	})
+	if result.IsError {
+		t.Fatalf("sandboxed command failed: %s", result.ForLLM)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "build", "out.txt")); string(data) != "ok\n" {
+		t.Errorf("workspace file = %q", data)
+	}
+}
+
+func TestShellTool_SandboxViolation(t *testing.T) {
+	tool := newSandboxedExecTool(t, t.TempDir())
+
+	const target = "/usr/picoclaw-sandbox-test"
+	t.Cleanup(func() { os.Remove(target) })
+	result := tool.Execute(context.Background(), map[string]any{"command": "touch " + target})
+	if !result.IsError {
+		t.Fatalf("writing a system path should fail: %s", result.ForLLM)
+	}
+	var v *sandbox.Violation
+	if !errors.As(result.Err, &v) || v.Kind != "read_only_filesystem" {
+		t.Fatalf("Err = %v, want read_only_filesystem violation", result.Err)
+	}
+	if !strings.HasPrefix(result.ForLLM, "Sandbox violation (read_only_filesystem)") {
+		t.Errorf("ForLLM = %q", result.ForLLM)
+	}
+	if _, err := os.Stat(target); err == nil {
+		t.Error("the sandboxed command wrote outside the workspace")
+	}
+}
+
+func TestShellTool_SandboxKeepsAllowlist(t *testing.T) {
+	tool := newSandboxedExecTool(t, t.TempDir())
+	if err := tool.SetAllowPatterns([]string{`^echo\b`}); err != nil {
+		t.Fatal(err)
+	}
+	result := tool.Execute(context.Background(), map[string]any{"command": "ls"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not in allowlist") {
+		t.Errorf("allowlist should still apply in the sandbox: %s", result.ForLLM)
+	}
+}
+
+func TestShellTool_SandboxBackgroundProcess(t *testing.T) {
+	tool := newSandboxedExecTool(t, t.TempDir())
+	manager := NewProcessManager(nil, 1, 1024)
+	defer manager.Close()
+	tool.SetProcessManager(manager)
+
+	id := startBackground(t, tool, "echo up; sleep 60")
+	proc, ok := manager.Get("", "", id)
+	if !ok {
+		t.Fatal("process not tracked")
+	}
+	waitFor(t, func() bool { return strings.Contains(proc.output.tail(100), "up") })
+	manager.Kill(proc)
+	if proc.Running() || !strings.Contains(proc.Status(), "killed") {
+		t.Errorf("status after kill = %s", proc.Status())
+	}
+}
+
+func TestShellTool_SandboxSetupFailureFallsBackToGuard(t *testing.T) {
+	orig := sandboxAvailable
+	sandboxAvailable = func() error { return errors.New("mount /proc: operation not permitted") }
+	defer func() { sandboxAvailable = orig }()
+
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.Sandbox.Enabled = true
+	tool := NewExecToolWithConfig(t.TempDir(), true, cfg)
+	if tool.sandbox != nil {
+		t.Fatal("sandbox should be disabled when it cannot be set up")
+	}
+	result := tool.Execute(context.Background(), map[string]any{"command": "rm -rf /"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "blocked") {
+		t.Errorf("the command guard should apply without the sandbox: %s", result.ForLLM)
+	}
+}
@@ -30,6 +30,7 @@ type SubagentManager struct {
 	bus            *bus.MessageBus
 	workspace      string
 	tools          *ToolRegistry
+	filter         *ToolFilter
 	maxIterations  int
 	maxTokens      int
 	temperature    float64
@@ -67,12 +68,25 @@ func (sm *SubagentManager) SetLLMOptions(maxTokens int, temperature float64) {
 
 // SetTools sets the tool registry for subagent execution.
 // If not set, subagent will have access to the provided tools.
+// A filter set with SetToolFilter is applied to the new registry.
 func (sm *SubagentManager) SetTools(tools *ToolRegistry) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	if sm.filter != nil {
+		tools.SetFilter(sm.filter)
+	}
 	sm.tools = tools
 }
 
+// SetToolFilter restricts subagents to the tools the filter allows, so they
+// inherit the restrictions of the agent that spawns them.
+func (sm *SubagentManager) SetToolFilter(filter *ToolFilter) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.filter = filter
+	sm.tools.SetFilter(filter)
+}
+
 // RegisterTool registers a tool for subagent execution.
 func (sm *SubagentManager) RegisterTool(tool Tool) {
 	sm.mu.Lock()
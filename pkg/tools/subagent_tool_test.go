@@ -71,6 +71,28 @@ func TestSubagentManager_SetLLMOptions_AppliesToRunToolLoop(t *testing.T) {
 	}
 }
 
+func TestSubagentManager_SetToolFilter_AppliesToSubagentTools(t *testing.T) {
+	manager := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	manager.RegisterTool(newMockTool("exec", ""))
+	filter, err := NewToolFilter(nil, []string{"exec"})
+	if err != nil {
+		t.Fatalf("NewToolFilter() error: %v", err)
+	}
+	manager.SetToolFilter(filter)
+	manager.RegisterTool(newMockTool("read_file", ""))
+	manager.RegisterTool(newMockTool("process", ""))
+	if got := manager.tools.List(); len(got) != 1 || got[0] != "read_file" {
+		t.Errorf("subagent tools = %v, want [read_file]", got)
+	}
+
+	registry := NewToolRegistry()
+	registry.Register(newMockTool("exec", ""))
+	manager.SetTools(registry)
+	if _, ok := registry.Get("exec"); ok {
+		t.Error("SetTools kept a tool the filter denies")
+	}
+}
+
 // TestSubagentTool_Name verifies tool name
 func TestSubagentTool_Name(t *testing.T) {
 	provider := &MockLLMProvider{}
@@ -5,12 +5,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/extract"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/netpolicy"
 )
 
 const (
@@ -67,6 +78,29 @@ func createHTTPClient(proxyURL string, timeout time.Duration) (*http.Client, err
 	return client, nil
 }
 
+// createPolicyClient creates an HTTP client that only reaches destinations
+// allowed by policy, including after redirects.
+func createPolicyClient(policy *netpolicy.Policy, proxyURL string, timeout time.Duration) (*http.Client, error) {
+	client, err := createHTTPClient(proxyURL, timeout)
+	if err != nil {
+		return nil, err
+	}
+	policy.Apply(client)
+	return client, nil
+}
+
+// readSearchResponse reads a search API response within the policy's size cap.
+func readSearchResponse(policy *netpolicy.Policy, resp *http.Response) ([]byte, error) {
+	body, truncated, err := policy.ReadBody(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if truncated {
+		return nil, fmt.Errorf("response exceeds %d bytes", policy.MaxResponseBytes())
+	}
+	return body, nil
+}
+
 type SearchProvider interface {
 	Search(ctx context.Context, query string, count int) (string, error)
 }
@@ -74,6 +108,7 @@ type SearchProvider interface {
 type BraveSearchProvider struct {
 	apiKey string
 	proxy  string
+	policy *netpolicy.Policy
 }
 
 func (p *BraveSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
@@ -88,7 +123,7 @@ func (p *BraveSearchProvider) Search(ctx context.Context, query string, count in
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("X-Subscription-Token", p.apiKey)
 
-	client, err := createHTTPClient(p.proxy, 10*time.Second)
+	client, err := createPolicyClient(p.policy, p.proxy, 10*time.Second)
 	if err != nil {
 		return "", fmt.Errorf("failed to create HTTP client: %w", err)
 	}
@@ -98,9 +133,9 @@ func (p *BraveSearchProvider) Search(ctx context.Context, query string, count in
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := readSearchResponse(p.policy, resp)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", err
 	}
 
 	var searchResp struct {
@@ -143,6 +178,7 @@ type TavilySearchProvider struct {
 	apiKey  string
 	baseURL string
 	proxy   string
+	policy  *netpolicy.Policy
 }
 
 func (p *TavilySearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
@@ -174,7 +210,7 @@ func (p *TavilySearchProvider) Search(ctx context.Context, query string, count i
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", userAgent)
 
-	client, err := createHTTPClient(p.proxy, 10*time.Second)
+	client, err := createPolicyClient(p.policy, p.proxy, 10*time.Second)
 	if err != nil {
 		return "", fmt.Errorf("failed to create HTTP client: %w", err)
 	}
@@ -184,9 +220,9 @@ func (p *TavilySearchProvider) Search(ctx context.Context, query string, count i
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := readSearchResponse(p.policy, resp)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", err
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -226,7 +262,8 @@ func (p *TavilySearchProvider) Search(ctx context.Context, query string, count i
 }
 
 type DuckDuckGoSearchProvider struct {
-	proxy string
+	proxy  string
+	policy *netpolicy.Policy
 }
 
 func (p *DuckDuckGoSearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
@@ -239,7 +276,7 @@ func (p *DuckDuckGoSearchProvider) Search(ctx context.Context, query string, cou
 
 	req.Header.Set("User-Agent", userAgent)
 
-	client, err := createHTTPClient(p.proxy, 10*time.Second)
+	client, err := createPolicyClient(p.policy, p.proxy, 10*time.Second)
 	if err != nil {
 		return "", fmt.Errorf("failed to create HTTP client: %w", err)
 	}
@@ -249,9 +286,9 @@ func (p *DuckDuckGoSearchProvider) Search(ctx context.Context, query string, cou
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := readSearchResponse(p.policy, resp)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", err
 	}
 
 	return p.extractResults(string(body), count, query)
@@ -322,6 +359,7 @@ func stripTags(content string) string {
 type PerplexitySearchProvider struct {
 	apiKey string
 	proxy  string
+	policy *netpolicy.Policy
 }
 
 func (p *PerplexitySearchProvider) Search(ctx context.Context, query string, count int) (string, error) {
@@ -356,7 +394,7 @@ func (p *PerplexitySearchProvider) Search(ctx context.Context, query string, cou
 	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	req.Header.Set("User-Agent", userAgent)
 
-	client, err := createHTTPClient(p.proxy, 30*time.Second)
+	client, err := createPolicyClient(p.policy, p.proxy, 30*time.Second)
 	if err != nil {
 		return "", fmt.Errorf("failed to create HTTP client: %w", err)
 	}
@@ -366,9 +404,9 @@ func (p *PerplexitySearchProvider) Search(ctx context.Context, query string, cou
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := readSearchResponse(p.policy, resp)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", err
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -413,20 +451,28 @@ type WebSearchToolOptions struct {
 	PerplexityMaxResults int
 	PerplexityEnabled    bool
 	Proxy                string
+	// Network limits where the providers connect and the size of their
+	// responses; nil uses netpolicy.Default. Pass the global policy rather
+	// than an agent's, whose domain allowlist would block the API hosts.
+	Network *netpolicy.Policy
 }
 
 func NewWebSearchTool(opts WebSearchToolOptions) *WebSearchTool {
 	var provider SearchProvider
 	maxResults := 5
+	policy := opts.Network
+	if policy == nil {
+		policy = netpolicy.Default()
+	}
 
 	// Priority: Perplexity > Brave > Tavily > DuckDuckGo
 	if opts.PerplexityEnabled && opts.PerplexityAPIKey != "" {
-		provider = &PerplexitySearchProvider{apiKey: opts.PerplexityAPIKey, proxy: opts.Proxy}
+		provider = &PerplexitySearchProvider{apiKey: opts.PerplexityAPIKey, proxy: opts.Proxy, policy: policy}
 		if opts.PerplexityMaxResults > 0 {
 			maxResults = opts.PerplexityMaxResults
 		}
 	} else if opts.BraveEnabled && opts.BraveAPIKey != "" {
-		provider = &BraveSearchProvider{apiKey: opts.BraveAPIKey, proxy: opts.Proxy}
+		provider = &BraveSearchProvider{apiKey: opts.BraveAPIKey, proxy: opts.Proxy, policy: policy}
 		if opts.BraveMaxResults > 0 {
 			maxResults = opts.BraveMaxResults
 		}
@@ -435,12 +481,13 @@ func NewWebSearchTool(opts WebSearchToolOptions) *WebSearchTool {
 			apiKey:  opts.TavilyAPIKey,
 			baseURL: opts.TavilyBaseURL,
 			proxy:   opts.Proxy,
+			policy:  policy,
 		}
 		if opts.TavilyMaxResults > 0 {
 			maxResults = opts.TavilyMaxResults
 		}
 	} else if opts.DuckDuckGoEnabled {
-		provider = &DuckDuckGoSearchProvider{proxy: opts.Proxy}
+		provider = &DuckDuckGoSearchProvider{proxy: opts.Proxy, policy: policy}
 		if opts.DuckDuckGoMaxResults > 0 {
 			maxResults = opts.DuckDuckGoMaxResults
 		}
@@ -508,15 +555,17 @@ func (t *WebSearchTool) Execute(ctx context.Context, args map[string]any) *ToolR
 type WebFetchTool struct {
 	maxChars int
 	proxy    string
+	policy   *netpolicy.Policy
+	mediaDir string
+
+	mu      sync.RWMutex
+	store   media.MediaStore
+	channel string
+	chatID  string
 }
 
 func NewWebFetchTool(maxChars int) *WebFetchTool {
-	if maxChars <= 0 {
-		maxChars = 50000
-	}
-	return &WebFetchTool{
-		maxChars: maxChars,
-	}
+	return NewWebFetchToolWithProxy(maxChars, "")
 }
 
 func NewWebFetchToolWithProxy(maxChars int, proxy string) *WebFetchTool {
@@ -526,15 +575,43 @@ func NewWebFetchToolWithProxy(maxChars int, proxy string) *WebFetchTool {
 	return &WebFetchTool{
 		maxChars: maxChars,
 		proxy:    proxy,
+		policy:   netpolicy.Default(),
+		mediaDir: filepath.Join(os.TempDir(), "picoclaw_media"),
 	}
 }
 
+// SetNetworkPolicy limits where the tool may fetch from. The default policy
+// blocks loopback, private, link-local and cloud metadata addresses.
+func (t *WebFetchTool) SetNetworkPolicy(policy *netpolicy.Policy) {
+	if policy != nil {
+		t.policy = policy
+	}
+}
+
+func (t *WebFetchTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+// SetMediaStore sets the store downloaded files that are not text are
+// registered in. Without a store, they are only saved to disk.
+func (t *WebFetchTool) SetMediaStore(store media.MediaStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.store = store
+}
+
 func (t *WebFetchTool) Name() string {
 	return "web_fetch"
 }
 
 func (t *WebFetchTool) Description() string {
-	return "Fetch a URL and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
+	return "Fetch a URL and return its main content as Markdown (headings, links, lists, tables), " +
+		"or the text of a PDF. Long content is returned in parts: use offset to read further. " +
+		"Images and other binary files are saved and returned as a media ref. " +
+		"Use this to get weather info, news, articles, or any web content."
 }
 
 func (t *WebFetchTool) Parameters() map[string]any {
@@ -550,6 +627,11 @@ func (t *WebFetchTool) Parameters() map[string]any {
 				"description": "Maximum characters to extract",
 				"minimum":     100.0,
 			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Character offset to start at, to continue reading long content (see next_offset)",
+				"minimum":     0.0,
+			},
 		},
 		"required": []string{"url"},
 	}
@@ -574,12 +656,20 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("missing domain in URL")
 	}
 
+	if err := t.policy.CheckURL(parsedURL); err != nil {
+		return ErrorResult(err.Error()).WithError(err)
+	}
+
 	maxChars := t.maxChars
 	if mc, ok := args["maxChars"].(float64); ok {
 		if int(mc) > 100 {
 			maxChars = int(mc)
 		}
 	}
+	offset := 0
+	if v, ok := args["offset"].(float64); ok && v > 0 {
+		offset = int(v)
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
@@ -588,7 +678,7 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 
 	req.Header.Set("User-Agent", userAgent)
 
-	client, err := createHTTPClient(t.proxy, 60*time.Second)
+	client, err := createPolicyClient(t.policy, t.proxy, 60*time.Second)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to create HTTP client: %v", err))
 	}
@@ -607,16 +697,31 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, bodyTruncated, err := t.policy.ReadBody(resp.Body)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to read response: %v", err))
 	}
 
-	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
 
-	var text, extractor string
+	var text, extractor, title string
 
-	if strings.Contains(contentType, "application/json") {
+	switch {
+	case mediaType == "application/pdf" || bytes.HasPrefix(body, []byte("%PDF-")):
+		// A cut-off PDF still yields the pages that arrived.
+		text, err = extract.PDF(body, t.policy.MaxResponseBytes())
+		if err != nil || strings.TrimSpace(text) == "" {
+			note := "the PDF has no extractable text, it may be scanned"
+			if err != nil {
+				note = err.Error()
+			}
+			return t.saveMedia(body, bodyTruncated, "application/pdf", resp.Request.URL, note)
+		}
+		extractor = "pdf"
+	case strings.Contains(mediaType, "json"):
 		var jsonData any
 		if err := json.Unmarshal(body, &jsonData); err == nil {
 			formatted, _ := json.MarshalIndent(jsonData, "", "  ")
@@ -626,43 +731,134 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 			text = string(body)
 			extractor = "raw"
 		}
-	} else if strings.Contains(contentType, "text/html") || len(body) > 0 &&
-		(strings.HasPrefix(string(body), "<!DOCTYPE") || strings.HasPrefix(strings.ToLower(string(body)), "<html")) {
-		text = t.extractText(string(body))
-		extractor = "text"
-	} else {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || len(body) > 0 &&
+		(strings.HasPrefix(string(body), "<!DOCTYPE") || strings.HasPrefix(strings.ToLower(string(body)), "<html")):
+		if doc, err := extract.HTML(body, resp.Request.URL); err == nil && doc.Markdown != "" {
+			text, title, extractor = doc.Markdown, doc.Title, "readability"
+			if title != "" && !strings.HasPrefix(text, "# ") {
+				text = "# " + title + "\n\n" + text
+			}
+		} else {
+			text = t.extractText(string(body))
+			extractor = "text"
+		}
+	case isTextMediaType(mediaType) || utf8.Valid(body) && !bytes.ContainsRune(body, 0):
 		text = string(body)
 		extractor = "raw"
+	default:
+		return t.saveMedia(body, bodyTruncated, mediaType, resp.Request.URL, "")
 	}
 
-	truncated := len(text) > maxChars
-	if truncated {
-		text = text[:maxChars]
-	}
+	runes := []rune(text)
+	total := len(runes)
+	offset = min(offset, total)
+	end := min(offset+maxChars, total)
+	text = string(runes[offset:end])
+	truncated := bodyTruncated || end < total
 
 	result := map[string]any{
 		"url":       urlStr,
 		"status":    resp.StatusCode,
 		"extractor": extractor,
 		"truncated": truncated,
+		"offset":    offset,
 		"length":    len(text),
+		"total":     total,
 		"text":      text,
 	}
+	if title != "" {
+		result["title"] = title
+	}
+	if end < total {
+		result["next_offset"] = end
+	}
 
 	resultJSON, _ := json.MarshalIndent(result, "", "  ")
 
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Fetched %d bytes from %s (extractor: %s, truncated: %v)",
+		len(text), urlStr, extractor, truncated)
+	if end < total {
+		fmt.Fprintf(&summary, "\nShowing characters %d-%d of %d. Call web_fetch with offset=%d to read more.",
+			offset, end, total, end)
+	}
+	if bodyTruncated {
+		fmt.Fprintf(&summary, "\nThe response was cut off at the %d KB size limit.", t.policy.MaxResponseBytes()/1024)
+	}
+
 	return &ToolResult{
-		ForLLM: fmt.Sprintf(
-			"Fetched %d bytes from %s (extractor: %s, truncated: %v)",
-			len(text),
-			urlStr,
-			extractor,
-			truncated,
-		),
+		ForLLM:  summary.String() + "\n\n" + text,
 		ForUser: string(resultJSON),
 	}
 }
 
+// saveMedia stores a response that is not text as a file and returns a ref
+// to it instead of its content.
+func (t *WebFetchTool) saveMedia(
+	body []byte,
+	truncated bool,
+	mediaType string,
+	source *url.URL,
+	note string,
+) *ToolResult {
+	if truncated {
+		return ErrorResult(fmt.Sprintf("%s file from %s is larger than the %d KB response limit",
+			mediaType, source, t.policy.MaxResponseBytes()/1024))
+	}
+	if err := os.MkdirAll(t.mediaDir, 0o700); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create media directory: %v", err)).WithError(err)
+	}
+	filename := path.Base(source.Path)
+	if filename == "." || filename == "/" || path.Ext(filename) == "" {
+		ext := ".bin"
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			ext = exts[0]
+		}
+		filename = "download" + ext
+	}
+	localPath := filepath.Join(t.mediaDir, fmt.Sprintf("fetch_%s_%s", uuid.New().String()[:8], filename))
+	if err := os.WriteFile(localPath, body, 0o600); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save download: %v", err)).WithError(err)
+	}
+
+	t.mu.RLock()
+	store, channel, chatID := t.store, t.channel, t.chatID
+	t.mu.RUnlock()
+
+	saved := localPath
+	if store != nil {
+		ref, err := store.Store(localPath, media.MediaMeta{
+			Filename:    filename,
+			ContentType: mediaType,
+			Source:      "tool:web-fetch",
+		}, fmt.Sprintf("tool:web_fetch:%s:%s", channel, chatID))
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to store download: %v", err)).WithError(err)
+		}
+		saved = ref
+	}
+
+	msg := fmt.Sprintf("Fetched %s (%s, %d bytes). The content is not text, so it was saved as %s",
+		source, mediaType, len(body), saved)
+	if note != "" {
+		msg += " (" + note + ")"
+	}
+	return NewToolResult(msg)
+}
+
+// isTextMediaType reports whether content of mediaType can be shown as text.
+func isTextMediaType(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, suffix := range []string{"xml", "json", "javascript", "yaml", "toml", "csv"} {
+		if strings.HasSuffix(mediaType, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *WebFetchTool) extractText(htmlContent string) string {
 	result := reScript.ReplaceAllLiteralString(htmlContent, "")
 	result = reStyle.ReplaceAllLiteralString(result, "")
@@ -1,15 +1,40 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/netpolicy"
 )
 
+// loopbackPolicy lets tests reach httptest servers, which the default
+// network policy blocks.
+func loopbackPolicy(t *testing.T) *netpolicy.Policy {
+	t.Helper()
+	policy, err := netpolicy.New(config.NetworkConfig{AllowedCIDRs: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func newLoopbackFetchTool(t *testing.T, maxChars int) *WebFetchTool {
+	t.Helper()
+	tool := NewWebFetchTool(maxChars)
+	tool.SetNetworkPolicy(loopbackPolicy(t))
+	return tool
+}
+
 // TestWebTool_WebFetch_Success verifies successful URL fetching
 func TestWebTool_WebFetch_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -19,7 +44,7 @@ func TestWebTool_WebFetch_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(50000)
+	tool := newLoopbackFetchTool(t, 50000)
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -55,7 +80,7 @@ func TestWebTool_WebFetch_JSON(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(50000)
+	tool := newLoopbackFetchTool(t, 50000)
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -146,7 +171,7 @@ func TestWebTool_WebFetch_Truncation(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(1000) // Limit to 1000 chars
+	tool := newLoopbackFetchTool(t, 1000) // Limit to 1000 chars
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -215,7 +240,7 @@ func TestWebTool_WebFetch_HTMLExtraction(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(50000)
+	tool := newLoopbackFetchTool(t, 50000)
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -547,6 +572,7 @@ func TestWebTool_TavilySearch_Success(t *testing.T) {
 		TavilyAPIKey:     "test-key",
 		TavilyBaseURL:    server.URL,
 		TavilyMaxResults: 5,
+		Network:          loopbackPolicy(t),
 	})
 
 	ctx := context.Background()
@@ -572,3 +598,143 @@ func TestWebTool_TavilySearch_Success(t *testing.T) {
 		t.Errorf("Expected 'via Tavily' in output, got: %s", result.ForUser)
 	}
 }
+
+// TestWebTool_TavilySearch_NetworkPolicy checks that a search endpoint is
+// held to the address rules, so a base_url cannot reach private addresses.
+func TestWebTool_TavilySearch_NetworkPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request to a loopback search endpoint should have been blocked")
+	}))
+	defer server.Close()
+
+	tool := NewWebSearchTool(WebSearchToolOptions{
+		TavilyEnabled: true,
+		TavilyAPIKey:  "test-key",
+		TavilyBaseURL: server.URL,
+	})
+
+	result := tool.Execute(context.Background(), map[string]any{"query": "test query"})
+	if !result.IsError {
+		t.Fatalf("Expected the loopback endpoint to be blocked, got: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "blocked by network policy") {
+		t.Errorf("Expected a network policy error, got: %s", result.ForLLM)
+	}
+}
+
+func TestWebTool_WebFetch_NetworkPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	result := NewWebFetchTool(50000).Execute(ctx, map[string]any{"url": server.URL})
+	if !result.IsError || !strings.Contains(result.ForLLM, "loopback") {
+		t.Errorf("loopback should be blocked by default: %q", result.ForLLM)
+	}
+
+	result = newLoopbackFetchTool(t, 50000).Execute(ctx, map[string]any{"url": server.URL})
+	if !result.IsError || !strings.Contains(result.ForLLM, "cloud metadata") {
+		t.Errorf("redirect to the metadata service should be blocked: %q", result.ForLLM)
+	}
+}
+
+func TestWebTool_WebFetch_ResponseSizeCap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("x", 3000)))
+	}))
+	defer server.Close()
+
+	policy, _ := netpolicy.New(config.NetworkConfig{AllowedCIDRs: []string{"127.0.0.1"}, MaxResponseKB: 1})
+	tool := NewWebFetchTool(50000)
+	tool.SetNetworkPolicy(policy)
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	if result.IsError || !strings.Contains(result.ForLLM, "Fetched 1024 bytes") ||
+		!strings.Contains(result.ForLLM, "truncated: true") {
+		t.Errorf("body should be cut off at the policy limit: %q", result.ForLLM)
+	}
+}
+
+func TestWebTool_WebFetch_MarkdownAndOffset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Guide</title></head><body><nav><a href="/">Home</a></nav>
+<article><h2>Install</h2><p>Run the <a href="/dl">installer</a> and follow the steps shown on screen.</p>
+<ul><li>Step one</li><li>Step two</li></ul></article></body></html>`))
+	}))
+	defer server.Close()
+	tool := newLoopbackFetchTool(t, 50000)
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{"url": server.URL})
+	want := "# Guide\n\n## Install\n\nRun the [installer](" + server.URL + "/dl) and follow the steps shown on screen." +
+		"\n\n- Step one\n- Step two"
+	if result.IsError || !strings.HasSuffix(result.ForLLM, "\n\n"+want) ||
+		!strings.Contains(result.ForLLM, "extractor: readability") || strings.Contains(result.ForLLM, "Home") {
+		t.Fatalf("ForLLM = %q", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"url": server.URL, "maxChars": float64(200), "offset": float64(10)})
+	if !strings.HasSuffix(result.ForLLM, "\n\n"+want[10:]) {
+		t.Errorf("ForLLM with offset = %q", result.ForLLM)
+	}
+
+	short := newLoopbackFetchTool(t, 120)
+	result = short.Execute(ctx, map[string]any{"url": server.URL})
+	var resultMap map[string]any
+	json.Unmarshal([]byte(result.ForUser), &resultMap)
+	if resultMap["next_offset"] != float64(120) || !strings.Contains(result.ForLLM, "offset=120") {
+		t.Errorf("long content should point to the next part: %q", result.ForLLM)
+	}
+}
+
+func TestWebTool_WebFetch_PDF(t *testing.T) {
+	content := "BT /F1 12 Tf 72 700 Td (Quarterly results) Tj 0 -14 Td (Revenue up 12%) Tj ET"
+	pdf := "%PDF-1.4\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+		"3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>\nendobj\n" +
+		"4 0 obj\n<< /Length " + strconv.Itoa(len(content)) + " >>\nstream\n" + content + "\nendstream\nendobj\n" +
+		"trailer\n<< /Root 1 0 R >>\n%%EOF\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte(pdf))
+	}))
+	defer server.Close()
+
+	result := newLoopbackFetchTool(t, 50000).Execute(context.Background(), map[string]any{"url": server.URL})
+	if result.IsError || !strings.Contains(result.ForLLM, "extractor: pdf") ||
+		!strings.HasSuffix(result.ForLLM, "\n\nQuarterly results\nRevenue up 12%") {
+		t.Errorf("ForLLM = %q", result.ForLLM)
+	}
+}
+
+func TestWebTool_WebFetch_BinaryToMediaStore(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	}))
+	defer server.Close()
+
+	tool := newLoopbackFetchTool(t, 50000)
+	tool.mediaDir = t.TempDir()
+	store := media.NewFileMediaStore()
+	tool.SetMediaStore(store)
+	tool.SetContext("telegram", "42")
+
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL + "/logo.png"})
+	if result.IsError || strings.Contains(result.ForLLM, "PNG") {
+		t.Fatalf("binary content should not reach the model: %q", result.ForLLM)
+	}
+	ref := result.ForLLM[strings.Index(result.ForLLM, "media://"):]
+	localPath, meta, err := store.ResolveWithMeta(ref)
+	if err != nil || meta.ContentType != "image/png" || meta.Filename != "logo.png" {
+		t.Fatalf("ResolveWithMeta(%q) = %+v, %v", ref, meta, err)
+	}
+	if data, _ := os.ReadFile(localPath); !bytes.Equal(data, png) {
+		t.Errorf("saved file = %q", data)
+	}
+}
@@ -0,0 +1,201 @@
+package triggers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/devices/events"
+	"github.com/sipeed/picoclaw/pkg/state"
+)
+
+func newTestService(t *testing.T, rules map[string]config.TriggerConfig) (*Service, *bus.MessageBus) {
+	t.Helper()
+	workspace := t.TempDir()
+	s, err := NewService(config.TriggersConfig{Enabled: true, Rules: rules}, workspace, state.NewManager(workspace))
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	msgBus := bus.NewMessageBus()
+	t.Cleanup(msgBus.Close)
+	s.SetBus(msgBus)
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(s.Stop)
+	return s, msgBus
+}
+
+func receive(t *testing.T, msgBus *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func expectNone(t *testing.T, msgBus *bus.MessageBus, wait time.Duration) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	if msg, ok := msgBus.ConsumeInbound(ctx); ok {
+		t.Fatalf("unexpected inbound message: %q", msg.Content)
+	}
+}
+
+func TestNewServiceValidates(t *testing.T) {
+	workspace := t.TempDir()
+	for name, tc := range map[string]config.TriggerConfig{
+		"no token":     {Type: TypeWebhook},
+		"no paths":     {Type: TypeFile},
+		"escape":       {Type: TypeFile, Paths: []string{"../etc"}},
+		"unknown type": {Type: "mail"},
+		"bad template": {Type: TypeDevice, Prompt: "{{.Kind"},
+	} {
+		cfg := config.TriggersConfig{Enabled: true, Rules: map[string]config.TriggerConfig{name: tc}}
+		if _, err := NewService(cfg, workspace, nil); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	cfg := config.TriggersConfig{Rules: map[string]config.TriggerConfig{"x": {Type: "mail"}}}
+	if s, err := NewService(cfg, workspace, nil); err != nil || len(s.triggers) != 0 {
+		t.Errorf("disabled service: %v, %d triggers", err, len(s.triggers))
+	}
+}
+
+func TestDebounceMergesEvents(t *testing.T) {
+	s, msgBus := newTestService(t, map[string]config.TriggerConfig{
+		"notes": {
+			Type:       TypeFile,
+			Paths:      []string{"notes"},
+			Agent:      "Writer",
+			DebounceMS: 50,
+			Prompt:     "{{.Count}} changes: {{range .Paths}}{{.}} {{end}}",
+		},
+	})
+	tr := s.triggers["notes"]
+	s.submit(tr, Event{Source: TypeFile, Kind: "write", Path: "notes/b.md"})
+	s.submit(tr, Event{Source: TypeFile, Kind: "write", Path: "notes/a.md"})
+	s.submit(tr, Event{Source: TypeFile, Kind: "write", Path: "notes/b.md"})
+
+	msg := receive(t, msgBus)
+	if msg.Content != "3 changes: notes/a.md notes/b.md" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if msg.SessionKey != "agent:writer:trigger:notes" {
+		t.Errorf("session key = %q", msg.SessionKey)
+	}
+	if msg.Channel != "cli" || msg.ChatID != "direct" || msg.SenderID != "trigger:notes" {
+		t.Errorf("target = %s:%s from %s", msg.Channel, msg.ChatID, msg.SenderID)
+	}
+	if msg.Metadata["trigger"] != "notes" || msg.Metadata["trigger_source"] != TypeFile {
+		t.Errorf("metadata = %v", msg.Metadata)
+	}
+	expectNone(t, msgBus, 150*time.Millisecond)
+}
+
+func TestDebounceMaxWait(t *testing.T) {
+	s, msgBus := newTestService(t, map[string]config.TriggerConfig{
+		"logs": {Type: TypeFile, Paths: []string{"logs"}, DebounceMS: 20},
+	})
+	tr := s.triggers["logs"]
+
+	// Events arriving faster than the debounce period must not hold the
+	// message back forever.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(5 * time.Millisecond):
+				s.submit(tr, Event{Source: TypeFile, Kind: "write", Path: "logs/app.log"})
+			}
+		}
+	}()
+
+	start := time.Now()
+	receive(t, msgBus)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("first message after %v, want about %v", elapsed, maxWaitFactor*20*time.Millisecond)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s, msgBus := newTestService(t, map[string]config.TriggerConfig{
+		"hook": {Type: TypeWebhook, Token: "secret", DebounceMS: 10, MaxPerHour: 1},
+	})
+	tr := s.triggers["hook"]
+	s.submit(tr, Event{Source: TypeWebhook, Kind: "push"})
+	receive(t, msgBus)
+
+	s.submit(tr, Event{Source: TypeWebhook, Kind: "push"})
+	expectNone(t, msgBus, 100*time.Millisecond)
+}
+
+func TestTargetAndSession(t *testing.T) {
+	s, msgBus := newTestService(t, map[string]config.TriggerConfig{
+		"hook": {
+			Type:       TypeWebhook,
+			Token:      "secret",
+			Session:    "ops",
+			Channel:    "telegram",
+			ChatID:     "42",
+			DebounceMS: 10,
+		},
+	})
+	s.submit(s.triggers["hook"], Event{Source: TypeWebhook, Kind: "push", Body: `{"ref":"main"}`})
+
+	msg := receive(t, msgBus)
+	if msg.Channel != "telegram" || msg.ChatID != "42" || msg.SessionKey != "agent:main:ops" {
+		t.Errorf("message = %+v", msg)
+	}
+	if !strings.Contains(msg.Content, "[Trigger hook] webhook event: push") ||
+		!strings.Contains(msg.Content, `{"ref":"main"}`) {
+		t.Errorf("default prompt = %q", msg.Content)
+	}
+}
+
+func TestTargetFallsBackToLastChannel(t *testing.T) {
+	s, _ := newTestService(t, nil)
+	if err := s.state.SetLastChannel("discord:7"); err != nil {
+		t.Fatal(err)
+	}
+	if c, id := s.target(&trigger{}); c != "discord" || id != "7" {
+		t.Errorf("target = %s:%s, want discord:7", c, id)
+	}
+}
+
+func TestDeviceTriggers(t *testing.T) {
+	s, msgBus := newTestService(t, map[string]config.TriggerConfig{
+		"usb": {
+			Type:        TypeDevice,
+			DeviceKinds: []string{"usb"},
+			Events:      []string{"add"},
+			DebounceMS:  10,
+			Prompt:      "{{.Kind}} {{.Fields.vendor}} {{.Fields.product}}",
+		},
+	})
+
+	s.HandleDeviceEvent(&events.DeviceEvent{Action: events.ActionAdd, Kind: events.KindBluetooth, Vendor: "x"})
+	s.HandleDeviceEvent(&events.DeviceEvent{Action: events.ActionRemove, Kind: events.KindUSB, Vendor: "x"})
+	expectNone(t, msgBus, 100*time.Millisecond)
+
+	s.HandleDeviceEvent(&events.DeviceEvent{
+		Action:  events.ActionAdd,
+		Kind:    events.KindUSB,
+		Vendor:  "FTDI",
+		Product: "FT232",
+	})
+	if msg := receive(t, msgBus); msg.Content != "add FTDI FT232" {
+		t.Errorf("content = %q", msg.Content)
+	}
+}
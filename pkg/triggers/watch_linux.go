@@ -0,0 +1,300 @@
+//go:build linux
+
+package triggers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// watchEntry is an inotify watch on a directory and the triggers fed by it.
+type watchEntry struct {
+	dir   string
+	rules []watchRule
+}
+
+// watchRule feeds a trigger from a watched directory. An empty name matches
+// every entry in the directory; otherwise only the named file matches.
+// Recursive rules remember the configured directory they started from.
+type watchRule struct {
+	t         *trigger
+	name      string
+	recursive bool
+	root      string
+}
+
+// excludedDirs are workspace directories PicoClaw writes to on every turn.
+// Recursive watches skip them, so a trigger on the whole workspace does not
+// fire on its own session and history files. Naming one of them in paths
+// still watches it.
+var excludedDirs = []string{"sessions", "state", ".history"}
+
+// watcher reports file changes in the workspace using inotify.
+type watcher struct {
+	workspace string
+	submit    func(*trigger, Event)
+
+	fd        int
+	wake      int           // eventfd that interrupts run's poll on close
+	done      chan struct{} // closed when run returns; nil until start
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	watches map[int32]*watchEntry
+	dirs    map[string]int32
+}
+
+func newWatcher(workspace string, submit func(*trigger, Event)) (*watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	wake, err := unix.Eventfd(0, unix.EFD_NONBLOCK|unix.EFD_CLOEXEC)
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("eventfd: %w", err)
+	}
+	return &watcher{
+		workspace: workspace,
+		submit:    submit,
+		fd:        fd,
+		wake:      wake,
+		watches:   make(map[int32]*watchEntry),
+		dirs:      make(map[string]int32),
+	}, nil
+}
+
+// add watches the paths of a file trigger. Directories are watched directly
+// (with their subdirectories when the trigger is recursive); files are
+// watched through their parent directory so that replacing them is seen.
+func (w *watcher) add(t *trigger) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, p := range t.cfg.Paths {
+		abs, err := workspacePath(w.workspace, p)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(abs)
+		if err == nil && info.IsDir() {
+			if t.cfg.Recursive {
+				err = w.addTreeLocked(abs, t)
+			} else {
+				err = w.addDirLocked(abs, watchRule{t: t})
+			}
+		} else {
+			err = w.addDirLocked(filepath.Dir(abs), watchRule{t: t, name: filepath.Base(abs)})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *watcher) addTreeLocked(root string, t *trigger) error {
+	return w.addSubtreeLocked(root, root, t)
+}
+
+// addSubtreeLocked watches dir and its subdirectories for a recursive
+// trigger configured on root.
+func (w *watcher) addSubtreeLocked(dir, root string, t *trigger) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if w.excluded(path, root) {
+			return filepath.SkipDir
+		}
+		return w.addDirLocked(path, watchRule{t: t, recursive: true, root: root})
+	})
+}
+
+// excluded reports whether dir lies in one of excludedDirs and the trigger's
+// configured root does not.
+func (w *watcher) excluded(dir, root string) bool {
+	inExcluded := func(p string) bool {
+		rel, err := filepath.Rel(w.workspace, p)
+		if err != nil {
+			return false
+		}
+		first, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+		return slices.Contains(excludedDirs, first)
+	}
+	return inExcluded(dir) && !inExcluded(root)
+}
+
+func (w *watcher) addDirLocked(dir string, rule watchRule) error {
+	wd, err := unix.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		return fmt.Errorf("watch %s: %w", dir, err)
+	}
+	entry, ok := w.watches[int32(wd)]
+	if !ok {
+		entry = &watchEntry{dir: dir}
+		w.watches[int32(wd)] = entry
+		w.dirs[dir] = int32(wd)
+	}
+	for _, r := range entry.rules {
+		if r.t == rule.t && r.name == rule.name {
+			return nil
+		}
+	}
+	entry.rules = append(entry.rules, rule)
+	return nil
+}
+
+// start runs the event loop in the background until ctx is done or the
+// watcher is closed.
+func (w *watcher) start(ctx context.Context) {
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		w.run(ctx)
+	}()
+}
+
+// run reads events until ctx is done or close wakes it up.
+func (w *watcher) run(ctx context.Context) {
+	buf := make([]byte, 64*1024)
+	fds := []unix.PollFd{
+		{Fd: int32(w.fd), Events: unix.POLLIN},
+		{Fd: int32(w.wake), Events: unix.POLLIN},
+	}
+	for ctx.Err() == nil {
+		n, err := unix.Poll(fds, 200)
+		if err != nil && err != unix.EINTR {
+			logger.ErrorCF("triggers", "inotify poll failed", map[string]any{"error": err.Error()})
+			return
+		}
+		if fds[1].Revents != 0 {
+			return
+		}
+		if n <= 0 {
+			continue
+		}
+		n, err = unix.Read(w.fd, buf)
+		if err != nil {
+			if err == unix.EAGAIN || err == unix.EINTR {
+				continue
+			}
+			if ctx.Err() == nil {
+				logger.ErrorCF("triggers", "inotify read failed", map[string]any{"error": err.Error()})
+			}
+			return
+		}
+		w.handle(buf[:n])
+	}
+}
+
+func (w *watcher) handle(buf []byte) {
+	for off := 0; off+unix.SizeofInotifyEvent <= len(buf); {
+		raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+		nameBytes := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(raw.Len)]
+		off += unix.SizeofInotifyEvent + int(raw.Len)
+		if raw.Mask&unix.IN_IGNORED != 0 {
+			w.forget(raw.Wd)
+			continue
+		}
+		name := strings.TrimRight(string(nameBytes), "\x00")
+		if name == "" {
+			continue
+		}
+		w.dispatch(raw.Wd, raw.Mask, name)
+	}
+}
+
+func (w *watcher) dispatch(wd int32, mask uint32, name string) {
+	kind := eventKind(mask)
+	if kind == "" {
+		return
+	}
+
+	w.mu.Lock()
+	entry, ok := w.watches[wd]
+	if !ok {
+		w.mu.Unlock()
+		return
+	}
+	path := filepath.Join(entry.dir, name)
+	rules := append([]watchRule(nil), entry.rules...)
+	if mask&unix.IN_ISDIR != 0 && kind == "create" {
+		for _, r := range rules {
+			if r.recursive {
+				if err := w.addSubtreeLocked(path, r.root, r.t); err != nil {
+					logger.WarnCF("triggers", "Failed to watch new directory", map[string]any{
+						"path":  path,
+						"error": err.Error(),
+					})
+				}
+			}
+		}
+	}
+	w.mu.Unlock()
+
+	rel, err := filepath.Rel(w.workspace, path)
+	if err != nil {
+		rel = path
+	}
+	for _, r := range rules {
+		if r.name != "" && r.name != name {
+			continue
+		}
+		w.submit(r.t, Event{Source: TypeFile, Kind: kind, Path: filepath.ToSlash(rel)})
+	}
+}
+
+// forget drops a watch the kernel removed, e.g. because its directory was
+// deleted.
+func (w *watcher) forget(wd int32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if entry, ok := w.watches[wd]; ok {
+		delete(w.dirs, entry.dir)
+		delete(w.watches, wd)
+	}
+}
+
+func eventKind(mask uint32) string {
+	switch {
+	case mask&unix.IN_CREATE != 0, mask&unix.IN_MOVED_TO != 0:
+		return "create"
+	case mask&unix.IN_CLOSE_WRITE != 0:
+		return "write"
+	case mask&unix.IN_DELETE != 0:
+		return "remove"
+	case mask&unix.IN_MOVED_FROM != 0:
+		return "rename"
+	}
+	return ""
+}
+
+// close stops the event loop, waits for it to return and only then closes
+// the inotify descriptor, so run never polls or reads a closed (or reused)
+// file descriptor.
+func (w *watcher) close() {
+	w.closeOnce.Do(func() {
+		if w.done != nil {
+			var one [8]byte
+			one[0] = 1 // eventfd counters are host-endian; any non-zero value wakes poll
+			unix.Write(w.wake, one[:])
+			<-w.done
+		}
+		unix.Close(w.fd)
+		unix.Close(w.wake)
+	})
+}
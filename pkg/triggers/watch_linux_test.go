@@ -0,0 +1,121 @@
+package triggers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestFileTrigger(t *testing.T) {
+	workspace := t.TempDir()
+	inbox := filepath.Join(workspace, "inbox")
+	if err := os.MkdirAll(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewService(config.TriggersConfig{Enabled: true, Rules: map[string]config.TriggerConfig{
+		"inbox": {
+			Type:       TypeFile,
+			Paths:      []string{"inbox"},
+			Recursive:  true,
+			Events:     []string{"write"},
+			DebounceMS: 50,
+			Prompt:     "{{.Kind}} {{.Path}}",
+		},
+	}}, workspace, nil)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	s.SetBus(msgBus)
+	if err := s.Start(t.Context()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Stop()
+
+	sub := filepath.Join(inbox, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Creating the directory is filtered out; give the watcher time to add it.
+	expectNone(t, msgBus, 100*time.Millisecond)
+	if err := os.WriteFile(filepath.Join(sub, "task.md"), []byte("todo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := receive(t, msgBus); msg.Content != "write inbox/sub/task.md" {
+		t.Errorf("content = %q", msg.Content)
+	}
+}
+
+func TestFileTriggerSkipsInternalDirs(t *testing.T) {
+	workspace := t.TempDir()
+	for _, dir := range []string{"sessions", "state", ".history", "notes"} {
+		if err := os.MkdirAll(filepath.Join(workspace, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := NewService(config.TriggersConfig{Enabled: true, Rules: map[string]config.TriggerConfig{
+		"all": {
+			Type:       TypeFile,
+			Paths:      []string{"."},
+			Recursive:  true,
+			Events:     []string{"write"},
+			DebounceMS: 20,
+			Prompt:     "{{.Kind}} {{.Path}}",
+		},
+	}}, workspace, nil)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	s.SetBus(msgBus)
+	if err := s.Start(t.Context()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Stop()
+
+	for _, p := range []string{"sessions/cli.json", "state/state.json", ".history/notes.md"} {
+		if err := os.WriteFile(filepath.Join(workspace, p), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectNone(t, msgBus, 100*time.Millisecond)
+
+	if err := os.WriteFile(filepath.Join(workspace, "notes", "todo.md"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, msgBus); msg.Content != "write notes/todo.md" {
+		t.Errorf("content = %q", msg.Content)
+	}
+}
+
+func TestWatcherCloseStopsRun(t *testing.T) {
+	w, err := newWatcher(t.TempDir(), func(*trigger, Event) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.start(context.Background())
+
+	closed := make(chan struct{})
+	go func() {
+		w.close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close did not return")
+	}
+	select {
+	case <-w.done:
+	default:
+		t.Error("run still running after close")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package triggers
+
+import (
+	"context"
+	"fmt"
+)
+
+type watcher struct{}
+
+func newWatcher(string, func(*trigger, Event)) (*watcher, error) {
+	return nil, fmt.Errorf("file triggers are only supported on Linux")
+}
+
+func (w *watcher) add(*trigger) error { return nil }
+
+func (w *watcher) start(context.Context) {}
+
+func (w *watcher) close() {}
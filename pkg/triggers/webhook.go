@@ -0,0 +1,105 @@
+package triggers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// WebhookPathPrefix is where webhook triggers are mounted; a trigger named
+// "deploy" receives POST /triggers/deploy.
+const WebhookPathPrefix = "/triggers/"
+
+const (
+	maxWebhookBody = 1 << 20 // bytes accepted per request
+	maxPromptBody  = 8000    // bytes of the body put into the prompt
+)
+
+// eventHeaders name the event in common webhook senders.
+var eventHeaders = []string{"X-GitHub-Event", "X-Gitlab-Event", "X-Gitea-Event", "X-Event-Type"}
+
+// WebhookPath implements channels.WebhookHandler.
+func (s *Service) WebhookPath() string {
+	return WebhookPathPrefix
+}
+
+// ServeHTTP accepts webhook trigger requests. Callers authenticate with
+// "Authorization: Bearer <token>" or by signing the body with the token as
+// an HMAC-SHA256 key in X-Hub-Signature-256, as GitHub does.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, WebhookPathPrefix)
+	t, ok := s.triggers[name]
+	if !ok || t.cfg.Type != TypeWebhook {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !authorized(t.cfg.Token, r, body) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ev := Event{
+		Source: TypeWebhook,
+		Kind:   "request",
+		Fields: map[string]string{},
+	}
+	for _, h := range eventHeaders {
+		if v := r.Header.Get(h); v != "" {
+			ev.Kind = v
+			break
+		}
+	}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			ev.Fields[k] = v[0]
+		}
+	}
+	var data map[string]any
+	if json.Unmarshal(body, &data) == nil {
+		ev.Data = data
+	}
+	ev.Body = string(body)
+	if len(ev.Body) > maxPromptBody {
+		cut := maxPromptBody
+		for cut > 0 && !utf8.RuneStart(ev.Body[cut]) {
+			cut--
+		}
+		ev.Body = ev.Body[:cut] + "\n[truncated]"
+	}
+
+	s.submit(t, ev)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(`{"status":"accepted"}`))
+}
+
+func authorized(token string, r *http.Request, body []byte) bool {
+	if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return subtle.ConstantTimeCompare([]byte(auth), []byte(token)) == 1
+	}
+	if sig, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="); ok {
+		mac := hmac.New(sha256.New, []byte(token))
+		mac.Write(body)
+		want := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(sig), []byte(want))
+	}
+	return false
+}
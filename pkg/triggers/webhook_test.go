@@ -0,0 +1,86 @@
+package triggers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestWebhook(t *testing.T) {
+	s, msgBus := newTestService(t, map[string]config.TriggerConfig{
+		"deploy": {
+			Type:       TypeWebhook,
+			Token:      "secret",
+			DebounceMS: 10,
+			Prompt:     "{{.Kind}} {{.Data.ref}} {{.Fields.env}}",
+		},
+		"usb": {Type: TypeDevice},
+	})
+	body := `{"ref":"main"}`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header map[string]string
+		want   int
+	}{
+		{"unknown trigger", http.MethodPost, "/triggers/nope", nil, http.StatusNotFound},
+		{"not a webhook", http.MethodPost, "/triggers/usb", nil, http.StatusNotFound},
+		{"wrong method", http.MethodGet, "/triggers/deploy", nil, http.StatusMethodNotAllowed},
+		{"no auth", http.MethodPost, "/triggers/deploy", nil, http.StatusUnauthorized},
+		{"bad token", http.MethodPost, "/triggers/deploy",
+			map[string]string{"Authorization": "Bearer wrong"}, http.StatusUnauthorized},
+		{"bad signature", http.MethodPost, "/triggers/deploy",
+			map[string]string{"X-Hub-Signature-256": "sha256=00"}, http.StatusUnauthorized},
+		{"bearer", http.MethodPost, "/triggers/deploy?env=prod",
+			map[string]string{"Authorization": "Bearer secret"}, http.StatusAccepted},
+		{"signature", http.MethodPost, "/triggers/deploy?env=prod",
+			map[string]string{"X-Hub-Signature-256": signature, "X-GitHub-Event": "push"}, http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+			continue
+		}
+		if tt.want != http.StatusAccepted {
+			continue
+		}
+		want := "request main prod"
+		if tt.header["X-GitHub-Event"] != "" {
+			want = "push main prod"
+		}
+		if msg := receive(t, msgBus); msg.Content != want {
+			t.Errorf("%s: content = %q, want %q", tt.name, msg.Content, want)
+		}
+	}
+}
+
+func TestWebhookBodyLimit(t *testing.T) {
+	s, _ := newTestService(t, map[string]config.TriggerConfig{
+		"deploy": {Type: TypeWebhook, Token: "secret"},
+	})
+	body := strings.NewReader(strings.Repeat("x", maxWebhookBody+1))
+	req := httptest.NewRequest(http.MethodPost, "/triggers/deploy", body)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", rec.Code)
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []byte("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n")
+	b := []byte("one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven")
+
+	diff, added, removed := UnifiedDiff("a/f", "b/f", a, b)
+	want := "--- a/f\n+++ b/f\n" +
+		"@@ -1,5 +1,5 @@\n one\n-two\n+2\n three\n four\n five\n" +
+		"@@ -8,3 +8,4 @@\n eight\n nine\n ten\n+eleven\n\\ No newline at end of file\n"
+	if diff != want {
+		t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", diff, want)
+	}
+	if added != 2 || removed != 1 {
+		t.Errorf("added, removed = %d, %d; want 2, 1", added, removed)
+	}
+
+	diff, _, _ = UnifiedDiff("/dev/null", "b/new", nil, []byte("x\n"))
+	if want := "--- /dev/null\n+++ b/new\n@@ -0,0 +1,1 @@\n+x\n"; diff != want {
+		t.Errorf("UnifiedDiff() for a new file = %q, want %q", diff, want)
+	}
+}